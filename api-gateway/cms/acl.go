@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"stock/api-gateway/dao"
+	"stock/api-gateway/db"
+	"stock/api-gateway/model"
+	"stock/api-gateway/serr"
+	"stock/common/log"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// modulePublic 登录后即可访问的接口
+const modulePublic = 0
+
+// routeModules 后台接口所属目录模块:GET请求需要模块读权限,其他请求需要模块写权限
+var routeModules = map[string]int64{
+	"/cms/get_user_name":     modulePublic,
+	"/cms/agents":            modulePublic,
+	"/cms/agent/get_modules": modulePublic,
+
+	"/cms/user/list":          model.UserPage,
+	"/cms/user/get_by_id":     model.UserPage,
+	"/cms/user/update":        model.UserPage,
+	"/cms/user/update_status": model.UserPage,
+	"/cms/user/recharge":      model.UserPage,
+	"/cms/user/withdraw":      model.UserPage,
+	"/cms/user/set_recharge":  model.UserPage,
+	"/cms/user/set_withdraw":  model.UserPage,
+
+	"/cms/trade/buy":                           model.TradePage,
+	"/cms/trade/sell":                          model.TradePage,
+	"/cms/trade/entrust":                       model.TradePage,
+	"/cms/trade/detail":                        model.TradePage,
+	"/cms/trade/condition":                     model.TradePage,
+	"/cms/trade/repo":                          model.TradePage,
+	"/cms/trade/position":                      model.TradePage,
+	"/cms/trade/position/dividend":             model.TradePage,
+	"/cms/trade/position/get_sell_stock_by_id": model.TradePage,
+	"/cms/trade/position/sell_stock":           model.TradePage,
+
+	"/cms/contract/list":              model.ContractPage,
+	"/cms/contract/get_by_id":         model.ContractPage,
+	"/cms/contract/update":            model.ContractPage,
+	"/cms/contract/fund_detail":       model.ContractPage,
+	"/cms/contract/fund_detail/items": model.ContractPage,
+
+	"/cms/broker/list":               model.BrokerPage,
+	"/cms/broker/create":             model.BrokerPage,
+	"/cms/broker/status":             model.BrokerPage,
+	"/cms/broker/entrust":            model.BrokerPage,
+	"/cms/broker/position":           model.BrokerPage,
+	"/cms/broker/position/get_by_id": model.BrokerPage,
+	"/cms/broker/reconcile":          model.BrokerPage,
+	"/cms/broker/reconcile/run":      model.BrokerPage,
+	"/cms/broker/reconcile/entrusts": model.BrokerPage,
+
+	"/cms/stock/list":   model.StockPage,
+	"/cms/stock/update": model.StockPage,
+
+	"/cms/system/get":              model.SystemPage,
+	"/cms/system/set":              model.SystemPage,
+	"/cms/system/jobs":             model.SystemPage,
+	"/cms/system/job/runs":         model.SystemPage,
+	"/cms/system/job/run":          model.SystemPage,
+	"/cms/system/reconcile":        model.SystemPage,
+	"/cms/system/reconcile/upload": model.SystemPage,
+	"/cms/system/channel":          model.SystemPage,
+	"/cms/system/channel/set":      model.SystemPage,
+	"/cms/ledger/entries":          model.SystemPage,
+	"/cms/ledger/check":            model.SystemPage,
+	"/cms/ledger/open":             model.SystemPage,
+	"/cms/file":                    model.SystemPage,
+
+	"/cms/agent/list":      model.AgentPage,
+	"/cms/agent/get_by_id": model.AgentPage,
+	"/cms/agent/create":    model.AgentPage,
+
+	"/cms/log/sms":   model.LogPage,
+	"/cms/log/audit": model.LogPage,
+}
+
+// checkRoutes 启动时检查所有后台接口均已配置目录模块,避免新增接口遗漏权限控制
+func checkRoutes(e *gin.Engine) {
+	for _, it := range e.Routes() {
+		if !strings.HasPrefix(it.Path, "/cms/") || excludeMap[it.Path] {
+			continue
+		}
+		if _, ok := routeModules[it.Path]; !ok {
+			panic(fmt.Sprintf("后台接口[%s %s]未配置目录模块", it.Method, it.Path))
+		}
+	}
+}
+
+// ACL 目录模块权限:按接口所属模块校验当前角色的读写权限,代理商只能访问名下用户的数据
+func ACL(c *gin.Context) {
+	if excludeMap[c.Request.URL.Path] {
+		c.Next()
+		return
+	}
+	// 不存在的接口交由404处理
+	path := c.FullPath()
+	if len(path) == 0 {
+		c.Next()
+		return
+	}
+	ctx := c.Request.Context()
+	role, err := Role(c)
+	if err != nil || !role.Status {
+		deny(c)
+		return
+	}
+	// 会话中的角色可能已被修改,以数据库为准
+	c.Set("__ROLE", role)
+	if role.IsAdmin {
+		c.Next()
+		return
+	}
+
+	module, ok := routeModules[path]
+	if !ok {
+		// 未配置模块的接口仅超级管理员可访问
+		deny(c)
+		return
+	}
+	if module != modulePublic {
+		modules, err := dao.RoleModuleDaoInstance().GetModulesByRoleID(ctx, role.ID)
+		if err != nil {
+			deny(c)
+			return
+		}
+		write := c.Request.Method != http.MethodGet
+		allowed := false
+		for _, it := range modules {
+			if it.ModuleID == module && (!write || it.CanWrite) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			deny(c)
+			return
+		}
+	} else if c.Request.Method != http.MethodGet {
+		deny(c)
+		return
+	}
+
+	// 代理商数据范围:限定为名下用户
+	users, err := dao.UserDaoInstance().GetUserByRoleIDs(ctx, []int64{role.ID})
+	if err != nil {
+		log.Errorf("GetUserByRoleIDs err:%+v", err)
+		deny(c)
+		return
+	}
+	uids := make([]int64, 0, len(users))
+	for _, it := range users {
+		uids = append(uids, it.ID)
+	}
+	c.Request = c.Request.WithContext(db.WithUIDScope(ctx, uids))
+	c.Next()
+}
+
+// deny 权限不足
+func deny(c *gin.Context) {
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"code": serr.ErrCodeBusinessFail,
+		"msg":  "权限不足",
+	})
+	c.Abort()
+}
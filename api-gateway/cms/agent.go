@@ -1,8 +1,11 @@
 package handler
 
 import (
+	"context"
 	"stock/api-gateway/dao"
 	"stock/api-gateway/model"
+	"stock/api-gateway/serr"
+	"stock/api-gateway/service"
 	"stock/api-gateway/util"
 
 	"github.com/gin-gonic/gin"
@@ -45,16 +48,21 @@ func (h *AgentHandler) GetByID(c *gin.Context) (interface{}, error) {
 		return nil, err
 	}
 	roleModules := make([]int64, 0)
+	writeModules := make([]int64, 0)
 	for _, it := range modules {
 		roleModules = append(roleModules, it.ModuleID)
+		if it.CanWrite {
+			writeModules = append(writeModules, it.ModuleID)
+		}
 	}
 	return map[string]interface{}{
-		"id":        role.ID,
-		"user_name": role.UserName,
-		"password":  role.Password,
-		"name":      role.UserName,
-		"status":    role.Status,
-		"module":    roleModules,
+		"id":           role.ID,
+		"user_name":    role.UserName,
+		"password":     "",
+		"name":         role.UserName,
+		"status":       role.Status,
+		"module":       roleModules,
+		"write_module": writeModules,
 	}, nil
 }
 
@@ -62,21 +70,60 @@ func (h *AgentHandler) GetByID(c *gin.Context) (interface{}, error) {
 func (h *AgentHandler) Create(c *gin.Context) (interface{}, error) {
 	ctx := util.RPCContext(c)
 	type request struct {
-		ID       int64   `form:"id" json:"id"`
-		UserName string  `form:"user_name" json:"user_name"`
-		Password string  `form:"password" json:"password"`
-		Name     string  `form:"name" json:"name"`
-		Status   bool    `form:"status" json:"status"`
-		Module   []int64 `form:"module" json:"module"`
+		ID          int64   `form:"id" json:"id"`
+		UserName    string  `form:"user_name" json:"user_name"`
+		Password    string  `form:"password" json:"password"`
+		Name        string  `form:"name" json:"name"`
+		Status      bool    `form:"status" json:"status"`
+		Module      []int64 `form:"module" json:"module"`
+		WriteModule []int64 `form:"write_module" json:"write_module"` // 有写权限的模块,须同时在module中
 	}
 	var req request
 	if err := c.Bind(&req); err != nil {
 		return nil, err
 	}
+	writable := make(map[int64]bool)
+	for _, it := range req.WriteModule {
+		writable[it] = true
+	}
+	if !IsAdmin(c) {
+		if err := h.checkGrant(c, req.ID, req.UserName, req.Module, writable); err != nil {
+			return nil, err
+		}
+	}
+	if req.ID > 0 {
+		before, err := h.snapshot(ctx, req.ID)
+		if err != nil {
+			return nil, err
+		}
+		AuditTarget(c, "role", req.ID, before)
+	} else {
+		AuditTarget(c, "role", 0, nil)
+	}
+	// 编辑代理时未填写密码则保留原密码,否则按密码策略校验后hash存储
+	var password string
+	if len(req.Password) > 0 {
+		if err := service.PasswordServiceInstance().CheckPolicy(ctx, req.UserName, req.Password); err != nil {
+			return nil, err
+		}
+		hashed, err := service.PasswordServiceInstance().Hash(req.Password)
+		if err != nil {
+			return nil, err
+		}
+		password = hashed
+	} else if req.ID > 0 {
+		old, err := dao.RoleDaoInstance().GetRoleByID(ctx, req.ID)
+		if err != nil {
+			return nil, err
+		}
+		password = old.Password
+	} else {
+		return nil, serr.ErrBusiness("请输入密码")
+	}
 	role, err := dao.RoleDaoInstance().Create(ctx, &model.Role{
 		ID:       req.ID,
 		UserName: req.UserName,
-		Password: req.Password,
+		Password: password,
 		Status:   req.Status,
 		IsAdmin:  req.UserName == "admin", // 超级管理员=admin
 	})
@@ -93,16 +140,76 @@ func (h *AgentHandler) Create(c *gin.Context) (interface{}, error) {
 			RoleID:   role.ID,
 			Module:   model.RoleModuleMap[moduleID],
 			ModuleID: moduleID,
+			CanWrite: writable[moduleID],
 		})
 	}
 	if err := dao.RoleModuleDaoInstance().Create(ctx, modules); err != nil {
 		return nil, err
 	}
+	if after, err := h.snapshot(ctx, role.ID); err == nil {
+		AuditAfter(c, after)
+	}
 	return map[string]interface{}{
 		"result": true,
 	}, nil
 }
 
+// snapshot 代理账户及目录权限,用于审计
+func (h *AgentHandler) snapshot(ctx context.Context, id int64) (map[string]interface{}, error) {
+	role, err := dao.RoleDaoInstance().GetRoleByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	modules, err := dao.RoleModuleDaoInstance().GetModulesByRoleID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	read := make([]int64, 0)
+	write := make([]int64, 0)
+	for _, it := range modules {
+		read = append(read, it.ModuleID)
+		if it.CanWrite {
+			write = append(write, it.ModuleID)
+		}
+	}
+	return map[string]interface{}{
+		"id":           role.ID,
+		"user_name":    role.UserName,
+		"password":     role.Password,
+		"status":       role.Status,
+		"is_admin":     role.IsAdmin,
+		"module":       read,
+		"write_module": write,
+	}, nil
+}
+
+// checkGrant 非管理员只能编辑自己的账户,且授予的模块读写权限不能超出自身权限
+func (h *AgentHandler) checkGrant(c *gin.Context, id int64, userName string, modules []int64, writable map[int64]bool) error {
+	ctx := util.RPCContext(c)
+	role, err := Role(c)
+	if err != nil {
+		return err
+	}
+	if id != role.ID || userName != role.UserName {
+		return serr.ErrBusiness("权限不足")
+	}
+	owned, err := dao.RoleModuleDaoInstance().GetModulesByRoleID(ctx, role.ID)
+	if err != nil {
+		return err
+	}
+	ownedMap := make(map[int64]*model.RoleModule)
+	for _, it := range owned {
+		ownedMap[it.ModuleID] = it
+	}
+	for _, it := range modules {
+		m, ok := ownedMap[it]
+		if !ok || (writable[it] && !m.CanWrite) {
+			return serr.ErrBusiness("权限不足")
+		}
+	}
+	return nil
+}
+
 // GetModules 获取目录模块
 func (h *AgentHandler) GetModules(c *gin.Context) (interface{}, error) {
 	ctx := util.RPCContext(c)
@@ -110,9 +217,11 @@ func (h *AgentHandler) GetModules(c *gin.Context) (interface{}, error) {
 		ID int64 `json:"id"` // 模块ID
 	}
 	list := make([]int64, 0)
+	writeList := make([]int64, 0)
 	if IsAdmin(c) {
 		for k, _ := range model.RoleModuleMap {
 			list = append(list, k)
+			writeList = append(writeList, k)
 		}
 	} else {
 		role, err := dao.RoleDaoInstance().GetRoleByUserName(ctx, Username(c))
@@ -125,10 +234,14 @@ func (h *AgentHandler) GetModules(c *gin.Context) (interface{}, error) {
 		}
 		for _, it := range modules {
 			list = append(list, it.ModuleID)
+			if it.CanWrite {
+				writeList = append(writeList, it.ModuleID)
+			}
 		}
 	}
 	return map[string]interface{}{
-		"list": list,
+		"list":       list,
+		"write_list": writeList,
 	}, nil
 }
 
@@ -165,7 +278,7 @@ func (h *AgentHandler) Agent(c *gin.Context) (interface{}, error) {
 		agents = append(agents, &agent{
 			ID:       it.ID,
 			UserName: it.UserName,
-			Password: it.Password,
+			Password: "",
 			Name:     it.UserName,
 			Status:   status,
 		})
@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"stock/api-gateway/dao"
+	"stock/api-gateway/model"
+	"stock/api-gateway/util"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	auditKey     = "__AUDIT"
+	auditMaxBody = 4096 // 记录的返回内容上限
+)
+
+// auditTarget 本次操作的对象及修改前后快照
+type auditTarget struct {
+	Type   string
+	ID     int64
+	Before map[string]interface{}
+	After  map[string]interface{}
+}
+
+// AuditTarget 记录操作对象及修改前快照,需在修改前调用
+func AuditTarget(c *gin.Context, targetType string, targetID int64, before interface{}) {
+	c.Set(auditKey, &auditTarget{Type: targetType, ID: targetID, Before: util.Snapshot(before)})
+}
+
+// AuditAfter 记录修改后快照,需在修改成功后调用
+func AuditAfter(c *gin.Context, after interface{}) {
+	v, ok := c.Get(auditKey)
+	if !ok {
+		return
+	}
+	target := v.(*auditTarget)
+	target.After = util.Snapshot(after)
+	if target.ID == 0 {
+		// 新建对象修改前无ID
+		if id, ok := target.After["id"].(float64); ok {
+			target.ID = int64(id)
+		}
+		if id, ok := target.After["ID"].(float64); ok {
+			target.ID = int64(id)
+		}
+	}
+}
+
+// auditWriter 记录返回内容
+type auditWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *auditWriter) Write(b []byte) (int, error) {
+	if w.body.Len() < auditMaxBody {
+		w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Audit 审计后台所有修改类请求:操作员、IP、接口、操作对象及修改前后差异,含被拒绝的请求
+func Audit(c *gin.Context) {
+	if c.Request.Method == http.MethodGet || excludeMap[c.Request.URL.Path] || len(c.FullPath()) == 0 {
+		c.Next()
+		return
+	}
+	// JSON请求体由handler读取,先保存一份用于记录请求参数
+	var body []byte
+	if c.ContentType() == gin.MIMEJSON {
+		body, _ = ioutil.ReadAll(c.Request.Body)
+		c.Request.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+	}
+	writer := &auditWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+	c.Next()
+
+	record := &model.AuditLog{
+		Operator:   Username(c),
+		IP:         c.ClientIP(),
+		Method:     c.Request.Method,
+		Path:       c.FullPath(),
+		Module:     routeModules[c.FullPath()],
+		Params:     auditParams(c, body),
+		CreateTime: time.Now(),
+	}
+	var resp struct {
+		Code int64  `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err := json.Unmarshal(writer.body.Bytes(), &resp); err == nil {
+		record.Code = resp.Code
+		record.Msg = resp.Msg
+	}
+	if v, ok := c.Get(auditKey); ok {
+		target := v.(*auditTarget)
+		record.TargetType = target.Type
+		record.TargetID = target.ID
+		record.BeforeData = auditJSON(target.Before)
+		record.AfterData = auditJSON(target.After)
+		if target.After != nil {
+			record.Diff = auditJSON(util.Diff(target.Before, target.After))
+		}
+	}
+	// 请求已结束,不使用请求ctx(包含数据范围)
+	_ = dao.AuditLogDaoInstance().Create(context.Background(), record)
+}
+
+// auditParams 请求参数:表单参数及JSON请求体,敏感字段脱敏,上传的文件不记录
+func auditParams(c *gin.Context, body []byte) string {
+	params := util.BodySnapshot(body)
+	if params == nil {
+		params = make(map[string]interface{})
+	}
+	for k, v := range c.Request.Form {
+		if util.IsSensitiveKey(k) {
+			params[k] = "***"
+			continue
+		}
+		if len(v) == 1 {
+			params[k] = v[0]
+		} else {
+			params[k] = v
+		}
+	}
+	return auditJSON(params)
+}
+
+// auditJSON 序列化,空值返回空字符串
+func auditJSON(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil || string(data) == "null" {
+		return ""
+	}
+	return string(data)
+}
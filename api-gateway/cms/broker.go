@@ -1,10 +1,12 @@
 package handler
 
 import (
+	"context"
 	"sort"
 	"stock/api-gateway/dao"
 	"stock/api-gateway/db"
 	"stock/api-gateway/model"
+	"stock/api-gateway/serr"
 	"stock/api-gateway/service"
 	"stock/api-gateway/util"
 	"stock/common/log"
@@ -32,6 +34,9 @@ func (h *BrokerHandler) Register(e *gin.Engine) {
 	e.GET("/cms/broker/entrust", JSONWrapper(h.EntrustList))
 	e.GET("/cms/broker/position", JSONWrapper(h.BrokerPosition))
 	e.POST("/cms/broker/status", JSONWrapper(h.UpdateStatus))
+	e.GET("/cms/broker/reconcile", JSONWrapper(h.ReconcileList))              // 券商对账列表
+	e.POST("/cms/broker/reconcile/run", JSONWrapper(h.ReconcileRun))          // 立即对账
+	e.GET("/cms/broker/reconcile/entrusts", JSONWrapper(h.ReconcileEntrusts)) // 对账差异相关的券商委托
 
 }
 
@@ -51,6 +56,7 @@ func (h *BrokerHandler) UpdateStatus(c *gin.Context) (interface{}, error) {
 		log.Errorf("GetBroker err:%+v", err)
 		return nil, err
 	}
+	AuditTarget(c, "broker", broker.ID, broker)
 	if broker.Status == model.BrokerStatusEnable {
 		broker.Status = model.BrokerStatusDisabled
 	} else {
@@ -60,6 +66,7 @@ func (h *BrokerHandler) UpdateStatus(c *gin.Context) (interface{}, error) {
 	if err := dao.BrokerDaoInstance().Create(ctx, broker); err != nil {
 		return nil, err
 	}
+	AuditAfter(c, broker)
 	return map[string]interface{}{
 		"result": true,
 	}, nil
@@ -135,6 +142,34 @@ func (h *BrokerHandler) EntrustList(c *gin.Context) (interface{}, error) {
 		return timeconv.TimeToInt64(brokerEntrust[i].OrderTime) > timeconv.TimeToInt64(brokerEntrust[j].OrderTime)
 	})
 
+	list, err := brokerEntrustResps(ctx, brokerEntrust)
+	if err != nil {
+		return nil, err
+	}
+
+	// 下载则下发文件
+	if IsDownload(c) {
+		var res []interface{}
+		for _, it := range list {
+			res = append(res, it)
+		}
+		Download(c, []string{
+			"券商编号", "券商名称", "用户名称", "用户姓名", "代理机构", "委托时间", "股票代码", "股票名称", "委托价格", "委托数量",
+			"成交数量", "状态", "交易类型", "委托类型", "券商委托编号", "分配策略", "分配依据", "成交金额", "券商手续费",
+		}, res)
+	}
+
+	count := len(list)
+	start, end := SlicePage(c, count)
+
+	return map[string]interface{}{
+		"list":  list[start:end],
+		"total": count,
+	}, nil
+}
+
+// brokerEntrustResps 券商委托记录展示
+func brokerEntrustResps(ctx context.Context, brokerEntrust []*model.BrokerEntrust) ([]*model.CmsBrokerEntrustResp, error) {
 	// 券商
 	brokerMap := make(map[int64]*model.Broker)
 	brokers, err := dao.BrokerDaoInstance().GetBrokers(ctx)
@@ -167,43 +202,28 @@ func (h *BrokerHandler) EntrustList(c *gin.Context) (interface{}, error) {
 			prop = "市价"
 		}
 		list = append(list, &model.CmsBrokerEntrustResp{
-			ID:         it.BrokerID,                                // 券商编号
-			BrokerName: broker.BrokerName,                          // 券商名称
-			UserName:   user.UserName,                              // 用户账户
-			Name:       user.Name,                                  // 用户姓名
-			Agent:      roleMap[user.RoleID],                       // 代理机构
-			Time:       it.OrderTime.Format("2006-01-02 15:04:05"), // 时间
-			StockCode:  it.StockCode,                               // 股票代码
-			StockName:  it.StockName,                               // 股票名称
-			Price:      it.EntrustPrice,                            // 委托价格
-			Amount:     it.EntrustAmount,                           // 委托数量
-			DealAmount: it.DealAmount,                              // 成交数量
-			Status:     model.EntrustStatusMap[it.Status],          // 状态
-			Type:       entrustBs,                                  // 交易类型
-			Prop:       prop,                                       // 委托类型
-			EntrustNo:  it.BrokerEntrustNo,                         // 券商委托编号
+			ID:          it.BrokerID,                                // 券商编号
+			BrokerName:  broker.BrokerName,                          // 券商名称
+			UserName:    user.UserName,                              // 用户账户
+			Name:        user.Name,                                  // 用户姓名
+			Agent:       roleMap[user.RoleID],                       // 代理机构
+			Time:        it.OrderTime.Format("2006-01-02 15:04:05"), // 时间
+			StockCode:   it.StockCode,                               // 股票代码
+			StockName:   it.StockName,                               // 股票名称
+			Price:       it.EntrustPrice,                            // 委托价格
+			Amount:      it.EntrustAmount,                           // 委托数量
+			DealAmount:  it.DealAmount,                              // 成交数量
+			Status:      model.EntrustStatusMap[it.Status],          // 状态
+			Type:        entrustBs,                                  // 交易类型
+			Prop:        prop,                                       // 委托类型
+			EntrustNo:   it.BrokerEntrustNo,                         // 券商委托编号
+			Route:       it.RouteStrategy,                           // 分配策略
+			Reason:      it.RouteReason,                             // 分配依据
+			DealBalance: it.DealBalance,                             // 成交金额
+			Fee:         it.Fee,                                     // 券商手续费
 		})
 	}
-
-	// 下载则下发文件
-	if IsDownload(c) {
-		var res []interface{}
-		for _, it := range list {
-			res = append(res, it)
-		}
-		Download(c, []string{
-			"券商编号", "券商名称", "用户名称", "用户姓名", "代理机构", "委托时间", "股票代码", "股票名称", "委托价格", "委托数量",
-			"成交数量", "状态", "交易类型", "委托类型", "券商委托编号",
-		}, res)
-	}
-
-	count := len(list)
-	start, end := SlicePage(c, count)
-
-	return map[string]interface{}{
-		"list":  list[start:end],
-		"total": count,
-	}, nil
+	return list, nil
 }
 
 // GetByID 根据ID查询券商
@@ -226,10 +246,13 @@ func (h *BrokerHandler) GetByID(c *gin.Context) (interface{}, error) {
 		Version:         broker.Version,
 		BranchNo:        broker.BranchNo,
 		Account:         broker.FundAccount,
-		Password:        broker.TradePassword,
-		CommPassword:    broker.TxPassword,
+		Password:        maskPassword(broker.TradePassword),
+		CommPassword:    maskPassword(broker.TxPassword),
 		SHHolderAccount: broker.SHHolderAccount,
 		SZHolderAccount: broker.SZHolderAccount,
+		RouteStrategy:   broker.RouteStrategy,
+		DayTurnoverCap:  broker.DayTurnoverCap,
+		StockCapRatio:   broker.StockCapRatio,
 	}, nil
 }
 
@@ -240,6 +263,12 @@ func (h *BrokerHandler) Create(c *gin.Context) (interface{}, error) {
 	if err := c.Bind(&req); err != nil {
 		return nil, err
 	}
+	if !model.ValidBrokerRoute(req.RouteStrategy) {
+		return nil, serr.ErrBusiness("无效的分配策略")
+	}
+	if req.DayTurnoverCap < 0 || req.StockCapRatio < 0 || req.StockCapRatio > 1 {
+		return nil, serr.ErrBusiness("券商限额参数错误")
+	}
 	broker := &model.Broker{
 		ID:              req.ID,
 		IP:              req.IP,
@@ -248,18 +277,38 @@ func (h *BrokerHandler) Create(c *gin.Context) (interface{}, error) {
 		BranchNo:        req.BranchNo,
 		FundAccount:     req.Account,
 		TradeAccount:    req.Account,
-		TradePassword:   req.Password,
-		TxPassword:      req.CommPassword,
+		TradePassword:   model.Secret(req.Password),
+		TxPassword:      model.Secret(req.CommPassword),
 		SHHolderAccount: req.SHHolderAccount,
 		SZHolderAccount: req.SZHolderAccount,
 		Priority:        req.Priority, // 顺序,数字越大,优先级越高
 		Status:          1,            // 状态:1激活 2冻结
 		BrokerName:      req.Name,     // 券商名称
-		CreateTime:      time.Now(),   // 时间
+		RouteStrategy:   req.RouteStrategy,
+		DayTurnoverCap:  req.DayTurnoverCap,
+		StockCapRatio:   req.StockCapRatio,
+		CreateTime:      time.Now(), // 时间
 	}
+	var before *model.Broker
+	if req.ID > 0 {
+		old, err := dao.BrokerDaoInstance().GetBroker(ctx, req.ID)
+		if err != nil {
+			return nil, err
+		}
+		before = old
+		// 未修改密码时提交的是脱敏后的密码
+		if req.Password == maskedPassword {
+			broker.TradePassword = old.TradePassword
+		}
+		if req.CommPassword == maskedPassword {
+			broker.TxPassword = old.TxPassword
+		}
+	}
+	AuditTarget(c, "broker", req.ID, before)
 	if err := service.BrokerServiceInstance().Create(ctx, broker); err != nil {
 		return nil, err
 	}
+	AuditAfter(c, broker)
 	return map[string]interface{}{
 		"result": true,
 	}, nil
@@ -287,19 +336,30 @@ func (h *BrokerHandler) BrokerList(c *gin.Context) (interface{}, error) {
 			IP:              it.IP,
 			Port:            it.Port,
 			Account:         it.FundAccount,
-			Password:        it.TradePassword,
-			CommPassword:    it.TxPassword,
+			Password:        maskPassword(it.TradePassword),
+			CommPassword:    maskPassword(it.TxPassword),
 			Priority:        it.Priority,
 			BranchNo:        it.BranchNo,
 			SHHolderAccount: it.SHHolderAccount,
 			SZHolderAccount: it.SZHolderAccount,
 			Status:          it.Status == 1,
+			RouteStrategy:   it.RouteStrategy,
+			DayTurnoverCap:  it.DayTurnoverCap,
+			StockCapRatio:   it.StockCapRatio,
 		}
 		if onlineBroker, ok := onlineBrokerMap[it.ID]; ok {
 			broker.ValMoney = onlineBroker.ValMoney
 			broker.Asset = onlineBroker.Asset
 			broker.Version = onlineBroker.Version
 		}
+		broker.Health = "未连接"
+		if health := service.BrokerServiceInstance().Health(it.ID); health != nil {
+			broker.Health = model.BrokerHealthMap[health.State]
+			broker.HealthError = health.LastError
+			if health.State == model.BrokerHealthDown && !health.RetryTime.IsZero() {
+				broker.RetryTime = health.RetryTime.Format("2006-01-02 15:04:05")
+			}
+		}
 
 		list = append(list, broker)
 	}
@@ -307,3 +367,13 @@ func (h *BrokerHandler) BrokerList(c *gin.Context) (interface{}, error) {
 		"list": list,
 	}, nil
 }
+
+// maskedPassword 券商密码脱敏展示
+const maskedPassword = "******"
+
+func maskPassword(s model.Secret) string {
+	if len(s) == 0 {
+		return ""
+	}
+	return maskedPassword
+}
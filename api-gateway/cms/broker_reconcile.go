@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"stock/api-gateway/dao"
+	"stock/api-gateway/model"
+	"stock/api-gateway/serr"
+	"stock/api-gateway/service"
+	"stock/api-gateway/util"
+	"stock/common/timeconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type brokerBreak struct {
+	ID        int64   `json:"id"`
+	Type      string  `json:"type"`       // 差异类型:持仓 资金
+	StockCode string  `json:"stock_code"` // 股票代码
+	StockName string  `json:"stock_name"` // 股票名称
+	Expected  float64 `json:"expected"`   // 推算股数/资金
+	Actual    float64 `json:"actual"`     // 券商股数/资金
+	Diff      float64 `json:"diff"`       // 差异:券商-推算
+	Severity  string  `json:"severity"`   // 差异级别
+}
+
+type brokerReconcile struct {
+	ID           int64          `json:"id"`
+	BrokerID     int64          `json:"broker_id"`
+	BrokerName   string         `json:"broker_name"`
+	BillDate     int32          `json:"bill_date"`
+	ExpectedCash float64        `json:"expected_cash"` // 推算资金余额
+	BrokerCash   float64        `json:"broker_cash"`   // 券商资金余额
+	BreakCount   int64          `json:"break_count"`
+	Breaks       []*brokerBreak `json:"breaks"`
+	Status       string         `json:"status"`
+	Remark       string         `json:"remark"`
+	Operator     string         `json:"operator"`
+	CreateTime   string         `json:"create_time"`
+}
+
+// ReconcileList 券商管理-对账列表,含差异明细
+func (h *BrokerHandler) ReconcileList(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	brokerID := Int64WithDefault(c, "id", 0)
+	reports, err := dao.BrokerReconcileDaoInstance().List(ctx, brokerID, 100)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(reports))
+	for _, it := range reports {
+		ids = append(ids, it.ID)
+	}
+	breaks, err := dao.BrokerReconcileDaoInstance().GetBreaks(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	breakMap := make(map[int64][]*brokerBreak)
+	for _, it := range breaks {
+		breakMap[it.ReconcileID] = append(breakMap[it.ReconcileID], &brokerBreak{
+			ID:        it.ID,
+			Type:      model.BrokerBreakTypeMap[it.Type],
+			StockCode: it.StockCode,
+			StockName: it.StockName,
+			Expected:  it.Expected,
+			Actual:    it.Actual,
+			Diff:      it.Diff,
+			Severity:  model.BrokerBreakSeverityMap[it.Severity],
+		})
+	}
+	brokers, err := dao.BrokerDaoInstance().GetBrokers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	brokerMap := make(map[int64]*model.Broker)
+	for _, it := range brokers {
+		brokerMap[it.ID] = it
+	}
+
+	list := make([]*brokerReconcile, 0)
+	for _, it := range reports {
+		item := &brokerReconcile{
+			ID:           it.ID,
+			BrokerID:     it.BrokerID,
+			BillDate:     it.BillDate,
+			ExpectedCash: it.ExpectedCash,
+			BrokerCash:   it.BrokerCash,
+			BreakCount:   it.BreakCount,
+			Breaks:       breakMap[it.ID],
+			Status:       model.BrokerReconcileStatusMap[it.Status],
+			Remark:       it.Remark,
+			Operator:     it.Operator,
+			CreateTime:   it.CreateTime.Format("2006-01-02 15:04:05"),
+		}
+		if item.Breaks == nil {
+			item.Breaks = make([]*brokerBreak, 0)
+		}
+		if broker, ok := brokerMap[it.BrokerID]; ok {
+			item.BrokerName = broker.BrokerName
+		}
+		list = append(list, item)
+	}
+	return list, nil
+}
+
+// ReconcileRun 券商管理-立即对账
+func (h *BrokerHandler) ReconcileRun(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	reports, err := service.BrokerServiceInstance().Reconcile(ctx, Username(c))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"result": true,
+		"count":  len(reports),
+	}, nil
+}
+
+// ReconcileEntrusts 券商管理-对账差异相关的券商委托:上次对账之后的成交,持仓差异只列该股票
+func (h *BrokerHandler) ReconcileEntrusts(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	id, err := Int64(c, "id")
+	if err != nil {
+		return nil, err
+	}
+	it, err := dao.BrokerReconcileDaoInstance().GetBreak(ctx, id)
+	if err != nil {
+		return nil, serr.ErrBusiness("对账差异不存在")
+	}
+
+	billDate := timeconv.Int32ToTime(it.BillDate)
+	end := billDate.AddDate(0, 0, 1)
+	last, err := dao.BrokerReconcileDaoInstance().GetLast(ctx, it.BrokerID, it.BillDate)
+	if err != nil {
+		return nil, err
+	}
+	begin := billDate
+	if last != nil {
+		begin = timeconv.Int32ToTime(last.BillDate).AddDate(0, 0, 1)
+	}
+	brokerEntrust, err := dao.BrokerEntrustDaoInstance().GetDeals(ctx, it.BrokerID, it.StockCode, begin, end)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := brokerEntrustResps(ctx, brokerEntrust)
+	if err != nil {
+		return nil, err
+	}
+	count := len(list)
+	start, stop := SlicePage(c, count)
+	return map[string]interface{}{
+		"list":  list[start:stop],
+		"total": count,
+	}, nil
+}
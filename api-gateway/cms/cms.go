@@ -10,6 +10,7 @@ import (
 	"stock/api-gateway/dao"
 	"stock/api-gateway/db"
 	"stock/api-gateway/serr"
+	"stock/api-gateway/service"
 	"stock/api-gateway/util"
 	"stock/common/env"
 	"stock/common/log"
@@ -153,9 +154,17 @@ func (h *CMSHandler) Login(c *gin.Context) (interface{}, error) {
 	if err != nil {
 		return nil, serr.ErrBusiness("登录失败")
 	}
-	if role.Password != req.Password {
+	ok, upgrade := service.PasswordServiceInstance().Verify(role.Password, req.Password)
+	if !ok {
 		return nil, serr.ErrBusiness("密码错误")
 	}
+	// 历史明文密码登录成功后升级为hash存储
+	if len(upgrade) > 0 {
+		if err := dao.RoleDaoInstance().UpdatePassword(ctx, role.ID, upgrade); err != nil {
+			log.Errorf("升级角色密码hash失败:%+v", err)
+		}
+		role.Password = upgrade
+	}
 
 	// 创建token
 	token, err := Session(ctx, role)
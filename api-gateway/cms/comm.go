@@ -40,7 +40,7 @@ func AgentFilter(c *gin.Context) []int64 {
 		}
 		return userIDs
 	}
-	// 非管理员,智能查询自己名下的
+	// 非管理员只查询自己名下的;数据范围已由ACL统一限定,此处仅作为筛选条件
 	if !IsAdmin(c) {
 		req.Agents = []string{Username(c)}
 	}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"fmt"
 	"sort"
 	"stock/api-gateway/dao"
 	"stock/api-gateway/db"
@@ -8,6 +9,7 @@ import (
 	"stock/api-gateway/quote"
 	"stock/api-gateway/service"
 	"stock/api-gateway/util"
+	"stock/common/log"
 	"stock/common/timeconv"
 
 	"github.com/gin-gonic/gin"
@@ -287,12 +289,28 @@ func (h *ContractHandler) Update(c *gin.Context) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
+	AuditTarget(c, "contract", contract.ID, contract)
+	// 操盘中合约修改保证金视为人工调账
+	journal := model.NewJournal(model.LedgerBizAdjust, contract.ID, fmt.Sprintf("后台修改合约资金,操作员:%s", Username(c)))
+	if contract.Status == model.ContractStatusEnable {
+		journal.Move(model.AdjustAccount, model.MarginAccount(contract.ID), req.Money-contract.Money)
+	}
 	contract.Money = req.Money
 	contract.InitMoney = req.InitMoney
 	contract.ValMoney = req.ValMoney
-	if err := dao.ContractDaoInstance().UpdateContract(ctx, contract); err != nil {
+	tx := db.StockDB().WithContext(ctx).Begin()
+	defer tx.Rollback()
+	if err := dao.ContractDaoInstance().UpdateWithTx(tx, contract); err != nil {
+		return nil, err
+	}
+	if err := service.LedgerServiceInstance().PostWithTx(tx, journal); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Errorf("事务提交失败:%+v", err)
 		return nil, err
 	}
+	AuditAfter(c, contract)
 	return map[string]interface{}{
 		"result": true,
 	}, nil
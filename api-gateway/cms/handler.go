@@ -19,14 +19,20 @@ var handlers = []Handler{
 	NewStockHandler(),
 	NewSystemHandler(),
 	NewLogHandler(),
+	NewJobHandler(),
+	NewLedgerHandler(),
+	NewReconcileHandler(),
+	NewPaymentHandler(),
 }
 
 // Register 注册所有的API入口
 func Register(e *gin.Engine) {
-	e.Use(Auth) // session 鉴权
-	e.Use(ACL)  // post,get 鉴权
+	e.Use(Auth)  // session 鉴权
+	e.Use(Audit) // 修改类请求审计
+	e.Use(ACL)   // 目录模块读写权限、代理商数据范围
 	e.Use(ParseFormMiddleware)
 	for _, h := range handlers {
 		h.Register(e)
 	}
+	checkRoutes(e)
 }
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"stock/api-gateway/dao"
+	"stock/api-gateway/db"
+	"stock/api-gateway/model"
+	task "stock/api-gateway/task"
+	"stock/api-gateway/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JobHandler 定时任务
+type JobHandler struct {
+}
+
+// NewJobHandler 单例
+func NewJobHandler() *JobHandler {
+	return &JobHandler{}
+}
+
+// Register 注册handler
+func (h *JobHandler) Register(e *gin.Engine) {
+	e.GET("/cms/system/jobs", JSONWrapper(h.Jobs))        // 系统管理-定时任务列表
+	e.GET("/cms/system/job/runs", JSONWrapper(h.Runs))    // 系统管理-定时任务执行记录
+	e.POST("/cms/system/job/run", JSONWrapper(h.Trigger)) // 系统管理-手动执行定时任务
+}
+
+type job struct {
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	Spec        string `json:"spec"`
+	TradeDay    bool   `json:"trade_day"`
+	Retry       int    `json:"retry"`
+	LastRunDate int32  `json:"last_run_date"`
+	LastStatus  string `json:"last_status"`
+	LastTime    string `json:"last_time"`
+	LastError   string `json:"last_error"`
+}
+
+type jobRun struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	RunDate   int32  `json:"run_date"`
+	Trigger   string `json:"trigger"`
+	Status    string `json:"status"`
+	Attempts  int64  `json:"attempts"`
+	Error     string `json:"error"`
+	Operator  string `json:"operator"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+}
+
+// Jobs 系统管理-定时任务列表,含最近一次执行结果
+func (h *JobHandler) Jobs(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	latest, err := dao.JobRunDaoInstance().GetLatest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]*job, 0)
+	for _, it := range task.TaskServiceInstance().Jobs() {
+		item := &job{
+			Name:     it.Name,
+			Title:    it.Title,
+			Spec:     it.Spec,
+			TradeDay: it.TradeDay,
+			Retry:    it.Retry,
+		}
+		if run, ok := latest[it.Name]; ok {
+			item.LastRunDate = run.RunDate
+			item.LastStatus = model.JobRunStatusMap[run.Status]
+			item.LastTime = run.StartTime.Format("2006-01-02 15:04:05")
+			item.LastError = run.Error
+		}
+		list = append(list, item)
+	}
+	return list, nil
+}
+
+// Runs 系统管理-定时任务执行记录
+func (h *JobHandler) Runs(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	type request struct {
+		Name      string `form:"name" json:"name"`
+		BeginDate int32  `form:"begin_date" json:"begin_date"`
+		EndDate   int32  `form:"end_date" json:"end_date"`
+	}
+	var req request
+	if err := c.Bind(&req); err != nil {
+		return nil, err
+	}
+	tx := db.StockDB().WithContext(ctx).Table("job_run").Order("id desc")
+	if req.Name != "" {
+		tx.Where("name = ?", req.Name)
+	}
+	if req.BeginDate > 0 {
+		tx.Where("run_date >= ?", req.BeginDate)
+	}
+	if req.EndDate > 0 {
+		tx.Where("run_date <= ?", req.EndDate)
+	}
+
+	var total int64
+	tx.Count(&total)
+
+	var runs []*model.JobRun
+	if err := tx.Limit(500).Find(&runs).Error; err != nil {
+		return nil, err
+	}
+
+	list := make([]*jobRun, 0)
+	for _, it := range runs {
+		item := &jobRun{
+			ID:        it.ID,
+			Name:      it.Name,
+			RunDate:   it.RunDate,
+			Trigger:   model.JobTriggerMap[it.Trigger],
+			Status:    model.JobRunStatusMap[it.Status],
+			Attempts:  it.Attempts,
+			Error:     it.Error,
+			Operator:  it.Operator,
+			StartTime: it.StartTime.Format("2006-01-02 15:04:05"),
+		}
+		if it.Status != model.JobRunStatusRunning {
+			item.EndTime = it.EndTime.Format("2006-01-02 15:04:05")
+		}
+		list = append(list, item)
+	}
+	return map[string]interface{}{
+		"list":  list,
+		"total": total,
+	}, nil
+}
+
+// Trigger 系统管理-手动执行定时任务:忽略交易日与当日是否已执行,异步执行
+func (h *JobHandler) Trigger(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	name, err := StringWithException(c, "name", "任务名称不能为空")
+	if err != nil {
+		return nil, err
+	}
+	if err := task.TaskServiceInstance().Trigger(ctx, name, Username(c)); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
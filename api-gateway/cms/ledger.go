@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"stock/api-gateway/dao"
+	"stock/api-gateway/model"
+	"stock/api-gateway/service"
+	"stock/api-gateway/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LedgerHandler 资金账簿
+type LedgerHandler struct {
+}
+
+// NewLedgerHandler 单例
+func NewLedgerHandler() *LedgerHandler {
+	return &LedgerHandler{}
+}
+
+// Register 注册handler
+func (h *LedgerHandler) Register(e *gin.Engine) {
+	e.GET("/cms/ledger/entries", JSONWrapper(h.Entries)) // 资金账簿-记账分录
+	e.GET("/cms/ledger/check", JSONWrapper(h.Check))     // 资金账簿-余额核对
+	e.POST("/cms/ledger/open", JSONWrapper(h.Open))      // 资金账簿-导入期初余额
+}
+
+type ledgerEntry struct {
+	ID          int64   `json:"id"`
+	JournalNo   string  `json:"journal_no"`
+	BizType     string  `json:"biz_type"`
+	BizID       int64   `json:"biz_id"`
+	AccountType string  `json:"account_type"`
+	OwnerID     int64   `json:"owner_id"`
+	Amount      float64 `json:"amount"`
+	Remark      string  `json:"remark"`
+	Time        string  `json:"time"`
+}
+
+type ledgerDiff struct {
+	AccountType string  `json:"account_type"`
+	OwnerID     int64   `json:"owner_id"`
+	Book        float64 `json:"book"`
+	Ledger      float64 `json:"ledger"`
+	Diff        float64 `json:"diff"`
+}
+
+// Entries 资金账簿-记账分录
+func (h *LedgerHandler) Entries(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	type request struct {
+		AccountType int64  `form:"account_type" json:"account_type"`
+		OwnerID     int64  `form:"owner_id" json:"owner_id"`
+		BizType     int64  `form:"biz_type" json:"biz_type"`
+		JournalNo   string `form:"journal_no" json:"journal_no"`
+		Offset      int    `form:"offset" json:"offset"`
+		Limit       int    `form:"limit" json:"limit"`
+	}
+	var req request
+	if err := c.Bind(&req); err != nil {
+		return nil, err
+	}
+	query := &model.LedgerEntryQuery{
+		AccountType: req.AccountType,
+		OwnerID:     req.OwnerID,
+		BizType:     req.BizType,
+		JournalNo:   req.JournalNo,
+		Offset:      req.Offset - 1,
+		Limit:       req.Limit,
+	}
+	if query.Offset < 0 {
+		query.Offset = 0
+	}
+	if query.Limit <= 0 || query.Limit > 500 {
+		query.Limit = 10
+	}
+	entries, total, err := dao.LedgerDaoInstance().Search(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*ledgerEntry, 0)
+	for _, it := range entries {
+		list = append(list, &ledgerEntry{
+			ID:          it.ID,
+			JournalNo:   it.JournalNo,
+			BizType:     model.LedgerBizMap[it.BizType],
+			BizID:       it.BizID,
+			AccountType: model.LedgerAccountMap[it.AccountType],
+			OwnerID:     it.OwnerID,
+			Amount:      it.Amount,
+			Remark:      it.Remark,
+			Time:        it.CreateTime.Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	return map[string]interface{}{
+		"list":  list,
+		"total": total,
+	}, nil
+}
+
+// Check 资金账簿-余额核对:列出账簿与业务表余额不一致的账户
+func (h *LedgerHandler) Check(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	diffs, err := service.LedgerServiceInstance().Check(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*ledgerDiff, 0)
+	for _, it := range diffs {
+		list = append(list, &ledgerDiff{
+			AccountType: model.LedgerAccountMap[it.AccountType],
+			OwnerID:     it.OwnerID,
+			Book:        it.Book,
+			Ledger:      it.Ledger,
+			Diff:        util.FloatRound(it.Book-it.Ledger, 2),
+		})
+	}
+	return map[string]interface{}{
+		"list":  list,
+		"total": len(list),
+	}, nil
+}
+
+// Open 资金账簿-导入期初余额:仅处理尚无分录的账户
+func (h *LedgerHandler) Open(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	count, err := service.LedgerServiceInstance().Open(ctx, Username(c))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"count": count,
+	}, nil
+}
@@ -43,28 +43,11 @@ func Auth(c *gin.Context) {
 	}
 
 	c.Set("__USERNAME", session.UserName)
+	c.Set("__ROLE_ID", session.ID)
 	log.Infof("request URL: %s, username: %s, req time: %s", c.Request.RequestURI, session.UserName, time.Now().Format("2006-01-02 15:04:05"))
 	c.Next()
 }
 
-// ACL 检查是否登录
-func ACL(c *gin.Context) {
-	if excludeMap[c.Request.URL.Path] {
-		c.Next()
-		return
-	}
-	if c.Request.Method != "GET" && Username(c) != "admin" {
-		c.JSON(http.StatusOK, map[string]interface{}{
-			"code": serr.ErrCodeBusinessFail,
-			"msg":  "权限不足",
-		})
-		c.Abort()
-		return
-	}
-
-	c.Next()
-}
-
 // ParseFormMiddleware parse form, such as device
 func ParseFormMiddleware(c *gin.Context) {
 	if err := c.Request.ParseForm(); err != nil {
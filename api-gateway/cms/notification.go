@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"fmt"
+	"stock/api-gateway/dao"
 	"stock/api-gateway/db"
 	"stock/api-gateway/model"
 	"stock/api-gateway/util"
@@ -21,6 +23,7 @@ func NewLogHandler() *LogHandler {
 // Register 注册handler
 func (h *LogHandler) Register(e *gin.Engine) {
 	e.GET("/cms/log/sms", JSONWrapper(h.Sms))
+	e.GET("/cms/log/audit", JSONWrapper(h.Audit)) // 日志-后台操作审计
 
 }
 
@@ -76,3 +79,89 @@ func (h *LogHandler) Sms(c *gin.Context) (interface{}, error) {
 		"total": total,
 	}, nil
 }
+
+type audit struct {
+	ID         int64  `json:"id"`
+	Operator   string `json:"operator"`
+	IP         string `json:"ip"`
+	Path       string `json:"path"`
+	Module     string `json:"module"`
+	TargetType string `json:"target_type"`
+	TargetID   int64  `json:"target_id"`
+	Params     string `json:"params"`
+	Before     string `json:"before"`
+	After      string `json:"after"`
+	Diff       string `json:"diff"`
+	Result     string `json:"result"`
+	Time       string `json:"time"`
+}
+
+// Audit 日志-后台操作审计
+func (h *LogHandler) Audit(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	type request struct {
+		Operator   string `form:"operator" json:"operator"`
+		Path       string `form:"path" json:"path"`
+		TargetType string `form:"target_type" json:"target_type"`
+		TargetID   int64  `form:"target_id" json:"target_id"`
+		BeginDate  int32  `form:"begin_date" json:"begin_date"`
+		EndDate    int32  `form:"end_date" json:"end_date"`
+		Offset     int    `form:"offset" json:"offset"`
+		Limit      int    `form:"limit" json:"limit"`
+	}
+	var req request
+	if err := c.Bind(&req); err != nil {
+		return nil, err
+	}
+	query := &model.AuditLogQuery{
+		Operator:   req.Operator,
+		Path:       req.Path,
+		TargetType: req.TargetType,
+		TargetID:   req.TargetID,
+		Offset:     req.Offset - 1,
+		Limit:      req.Limit,
+	}
+	if query.Offset < 0 {
+		query.Offset = 0
+	}
+	if query.Limit <= 0 || query.Limit > 500 {
+		query.Limit = 10
+	}
+	if req.BeginDate > 0 {
+		query.BeginTime = timeconv.Int32ToTime(req.BeginDate)
+	}
+	if req.EndDate > 0 {
+		query.EndTime = timeconv.Int32ToTime(req.EndDate).AddDate(0, 0, 1)
+	}
+	logs, total, err := dao.AuditLogDaoInstance().Search(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*audit, 0)
+	for _, it := range logs {
+		item := &audit{
+			ID:         it.ID,
+			Operator:   it.Operator,
+			IP:         it.IP,
+			Path:       it.Path,
+			Module:     model.RoleModuleMap[it.Module],
+			TargetType: it.TargetType,
+			TargetID:   it.TargetID,
+			Params:     it.Params,
+			Before:     it.BeforeData,
+			After:      it.AfterData,
+			Diff:       it.Diff,
+			Result:     "成功",
+			Time:       it.CreateTime.Format("2006-01-02 15:04:05"),
+		}
+		if it.Code != 0 {
+			item.Result = fmt.Sprintf("失败:%s", it.Msg)
+		}
+		result = append(result, item)
+	}
+	return map[string]interface{}{
+		"list":  result,
+		"total": total,
+	}, nil
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"io/ioutil"
+	"stock/api-gateway/dao"
+	"stock/api-gateway/model"
 	"stock/api-gateway/serr"
 	"strconv"
 
@@ -126,7 +128,22 @@ func Username(c *gin.Context) string {
 	return ""
 }
 
+// Role 当前登录角色,以数据库为准
+func Role(c *gin.Context) (*model.Role, error) {
+	if v, ok := c.Get("__ROLE"); ok {
+		return v.(*model.Role), nil
+	}
+	v, ok := c.Get("__ROLE_ID")
+	if !ok {
+		return nil, serr.ErrBusiness("未登录")
+	}
+	return dao.RoleDaoInstance().GetRoleByID(c.Request.Context(), v.(int64))
+}
+
 func IsAdmin(c *gin.Context) bool {
+	if v, ok := c.Get("__ROLE"); ok {
+		return v.(*model.Role).IsAdmin
+	}
 	return Username(c) == "admin"
 }
 
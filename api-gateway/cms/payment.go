@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"stock/api-gateway/dao"
+	"stock/api-gateway/model"
+	"stock/api-gateway/serr"
+	"stock/api-gateway/service"
+	"stock/api-gateway/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PaymentHandler 充值渠道
+type PaymentHandler struct {
+}
+
+// NewPaymentHandler 单例
+func NewPaymentHandler() *PaymentHandler {
+	return &PaymentHandler{}
+}
+
+// Register 注册handler
+func (h *PaymentHandler) Register(e *gin.Engine) {
+	e.GET("/cms/system/channel", JSONWrapper(h.List))     // 系统管理-充值渠道列表
+	e.POST("/cms/system/channel/set", JSONWrapper(h.Set)) // 系统管理-设置充值渠道金额限制与手续费
+}
+
+type paymentChannel struct {
+	Code     string  `json:"code" form:"code"`
+	Name     string  `json:"name" form:"-"`
+	Enabled  bool    `json:"enabled" form:"-"`
+	MinMoney float64 `json:"min_money" form:"min_money"`
+	MaxMoney float64 `json:"max_money" form:"max_money"`
+	FeeRate  float64 `json:"fee_rate" form:"fee_rate"`
+	MinFee   float64 `json:"min_fee" form:"min_fee"`
+	Sort     int64   `json:"sort" form:"sort"`
+}
+
+// List 系统管理-充值渠道列表,渠道开关在系统参数中设置
+func (h *PaymentHandler) List(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	sys, err := dao.SysDaoInstance().GetSysParam(ctx)
+	if err != nil {
+		return nil, err
+	}
+	configs, err := dao.PaymentChannelDaoInstance().GetConfigs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*paymentChannel, 0)
+	for _, it := range service.PaymentChannels() {
+		item := &paymentChannel{
+			Code:    it.Code(),
+			Name:    it.Name(),
+			Enabled: it.Enabled(sys),
+		}
+		if config, ok := configs[it.Code()]; ok {
+			item.MinMoney = config.MinMoney
+			item.MaxMoney = config.MaxMoney
+			item.FeeRate = config.FeeRate
+			item.MinFee = config.MinFee
+			item.Sort = config.Sort
+		}
+		list = append(list, item)
+	}
+	return list, nil
+}
+
+// Set 系统管理-设置充值渠道金额限制与手续费
+func (h *PaymentHandler) Set(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	var req paymentChannel
+	if err := c.Bind(&req); err != nil {
+		return nil, err
+	}
+	if _, ok := service.PaymentChannelByCode(req.Code); !ok {
+		return nil, serr.ErrBusiness("充值渠道不存在")
+	}
+	if req.MinMoney < 0 || req.MaxMoney < 0 || req.FeeRate < 0 || req.FeeRate >= 1 || req.MinFee < 0 {
+		return nil, serr.ErrBusiness("参数错误")
+	}
+	if req.MaxMoney > 0 && req.MaxMoney < req.MinMoney {
+		return nil, serr.ErrBusiness("最高金额不能低于最低金额")
+	}
+	configs, err := dao.PaymentChannelDaoInstance().GetConfigs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	config := &model.PaymentChannelConfig{Code: req.Code}
+	if before, ok := configs[req.Code]; ok {
+		config = before
+	}
+	AuditTarget(c, "payment_channel", config.ID, config)
+	config.MinMoney = req.MinMoney
+	config.MaxMoney = req.MaxMoney
+	config.FeeRate = req.FeeRate
+	config.MinFee = req.MinFee
+	config.Sort = req.Sort
+	if err := dao.PaymentChannelDaoInstance().Save(ctx, config); err != nil {
+		return nil, err
+	}
+	AuditAfter(c, config)
+	return map[string]interface{}{
+		"result": true,
+	}, nil
+}
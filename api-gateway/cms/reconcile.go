@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"encoding/json"
+	"stock/api-gateway/dao"
+	"stock/api-gateway/model"
+	"stock/api-gateway/serr"
+	"stock/api-gateway/service"
+	"stock/api-gateway/util"
+	"stock/common/log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReconcileHandler 充值渠道对账
+type ReconcileHandler struct {
+}
+
+// NewReconcileHandler 单例
+func NewReconcileHandler() *ReconcileHandler {
+	return &ReconcileHandler{}
+}
+
+// Register 注册handler
+func (h *ReconcileHandler) Register(e *gin.Engine) {
+	e.GET("/cms/system/reconcile", JSONWrapper(h.List))           // 系统管理-对账报告列表
+	e.POST("/cms/system/reconcile/upload", JSONWrapper(h.Upload)) // 系统管理-上传支付宝对账单对账
+}
+
+type reconcileDiff struct {
+	Type        string  `json:"type"`
+	OrderNo     string  `json:"order_no"`
+	TradeNo     string  `json:"trade_no"`
+	LocalMoney  float64 `json:"local_money"`
+	BillMoney   float64 `json:"bill_money"`
+	LocalStatus string  `json:"local_status"`
+}
+
+type reconcileReport struct {
+	ID           int64            `json:"id"`
+	Channel      string           `json:"channel"`
+	BillDate     int32            `json:"bill_date"`
+	Matched      int64            `json:"matched"`
+	MatchedMoney float64          `json:"matched_money"`
+	DiffCount    int64            `json:"diff_count"`
+	Diff         []*reconcileDiff `json:"diff"`
+	Status       string           `json:"status"`
+	Operator     string           `json:"operator"`
+	CreateTime   string           `json:"create_time"`
+}
+
+func newReconcileReport(it *model.ReconcileReport) *reconcileReport {
+	item := &reconcileReport{
+		ID:           it.ID,
+		Channel:      it.Channel,
+		BillDate:     it.BillDate,
+		Matched:      it.Matched,
+		MatchedMoney: it.MatchedMoney,
+		DiffCount:    it.DiffCount,
+		Diff:         make([]*reconcileDiff, 0),
+		Status:       model.ReconcileStatusMap[it.Status],
+		Operator:     it.Operator,
+		CreateTime:   it.CreateTime.Format("2006-01-02 15:04:05"),
+	}
+	var diffs []*model.ReconcileDiff
+	if err := json.Unmarshal([]byte(it.Diff), &diffs); err != nil {
+		log.Errorf("解析对账差异失败:%+v", err)
+	}
+	for _, d := range diffs {
+		item.Diff = append(item.Diff, &reconcileDiff{
+			Type:        model.ReconcileDiffMap[d.Type],
+			OrderNo:     d.OrderNo,
+			TradeNo:     d.TradeNo,
+			LocalMoney:  d.LocalMoney,
+			BillMoney:   d.BillMoney,
+			LocalStatus: model.TransferStatusMap[d.LocalStatus],
+		})
+	}
+	return item
+}
+
+// List 系统管理-对账报告列表
+func (h *ReconcileHandler) List(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	channel := StringWithDefault(c, "channel", "")
+	reports, err := dao.ReconcileReportDaoInstance().List(ctx, channel, 100)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*reconcileReport, 0)
+	for _, it := range reports {
+		list = append(list, newReconcileReport(it))
+	}
+	return list, nil
+}
+
+// Upload 系统管理-上传支付宝业务明细对账单(CSV),按对账日期核对
+func (h *ReconcileHandler) Upload(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	dateStr, err := StringWithException(c, "date", "对账日期不能为空")
+	if err != nil {
+		return nil, err
+	}
+	date, err := time.ParseInLocation("20060102", dateStr, time.Local)
+	if err != nil {
+		return nil, serr.ErrBusiness("对账日期格式错误")
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		return nil, serr.ErrBusiness("请上传对账单")
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	report, err := service.AlipayServiceInstance().Reconcile(ctx, date, file, Username(c))
+	if err != nil {
+		return nil, err
+	}
+	return newReconcileReport(report), nil
+}
@@ -42,9 +42,11 @@ func (h *StockHandler) UpdateStatus(c *gin.Context) (interface{}, error) {
 	} else {
 		status = model.StockDataStatusEnable
 	}
+	AuditTarget(c, "stock_data", req.ID, nil)
 	if err := service.StockDataServiceInstance().UpdateStatusByID(ctx, req.ID, status); err != nil {
 		return nil, err
 	}
+	AuditAfter(c, map[string]interface{}{"id": req.ID, "status": status})
 	return map[string]interface{}{
 		"result": true,
 	}, nil
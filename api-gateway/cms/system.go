@@ -3,6 +3,7 @@ package handler
 import (
 	"stock/api-gateway/dao"
 	"stock/api-gateway/model"
+	"stock/api-gateway/serr"
 	"stock/api-gateway/util"
 	"stock/common/log"
 	"strconv"
@@ -21,42 +22,58 @@ func NewSystemHandler() *SystemHandler {
 }
 
 type system struct {
-	LimitPct               float64 `json:"limit_pct" form:"limit_pct"`                         // 涨跌幅买入限制
-	CYBLimitPct            float64 `json:"cyb_limit_pct" form:"cyb_limit_pct"`                 // 创业板涨跌幅买入限制
-	KCBLimitPct            float64 `json:"kcb_limit_pct" form:"cyb_limit_pct"`                 // 科创板涨跌幅买入限制
-	STLimitPct             float64 `json:"st_limit_pct" form:"st_limit_pct"`                   // ST涨跌幅买入限制
-	IsSupportSTStock       bool    `json:"st_forbid" form:"st_forbid"`                         // ST股是否允许交易:true允许交易,false不允许交易
-	ClosePct               float64 `json:"close_pct" form:"close_pct"`                         // 平仓线率
-	WarnPct                float64 `json:"warn_pct" form:"warn_pct"`                           // 警戒线率
-	IsSupportKCBBoard      bool    `json:"sge_board_forbid" form:"sge_board_forbid"`           // 科创板是否允许交易:true允许交易,false不允许交易
-	BuyFee                 float64 `json:"buy_fee" form:"buy_fee"`                             // 买入手续费
-	MiniChargeFee          float64 `json:"mini_charge_fee" form:"mini_charge_fee"`             // 最低手续费
-	RegistCode             bool    `json:"regist_code" form:"regist_code"`                     // 注册须推荐码:true必须填写正确推荐码
-	WithdrawBeginTime      string  `json:"withdraw_begin_time" form:"withdraw_begin_time"`     // 提现开始时间
-	WithdrawEndTime        string  `json:"withdraw_end_time" form:"withdraw_end_time"`         // 提现结束时间
-	RechargeNotice         bool    `json:"recharge_notice" form:"recharge_notice"`             // 用户充值短信通知管理:true通知,false不通知
-	RegisterNotice         bool    `json:"register_notice" form:"register_notice"`             // 用户注册短信通知管理:true通知,false不通知
-	WithdrawNotice         bool    `json:"withdraw_notice" form:"withdraw_notice"`             // 用户提现通知管理:true通知,false不通知
-	Broker                 bool    `json:"broker" form:"broker"`                               // 是否对接券商:true对接,false不对接
-	WarnCanBuy             bool    `json:"warn_can_buy" form:"warn_can_buy"`                   // 触发警戒线允许买入:true允许买入,false不允许买入
-	IsSupportCYBBoard      bool    `json:"cyb_board_forbid" form:"cyb_board_forbid"`           // 创业板允许交易:true允许交易,false不允许交易
-	SellFee                float64 `json:"sell_fee" form:"sell_fee"`                           // 卖出手续费
-	SingleBuyPct           float64 `json:"single_buy_pct" form:"single_buy_pct"`               // 单只股票最大持仓比率
-	HolidayCharge          bool    `json:"holiday_charge" form:"holiday_charge"`               // 节假日收取管理费:true节假日收取留仓费,false不收取
-	BankName               string  `json:"bank_name" form:"bank_name"`                         // 收款人姓名
-	BankNo                 string  `json:"bank_no" form:"bank_no"`                             // 收款银行卡号
-	BankAddr               string  `json:"bank_addr" form:"bank_addr"`                         // 收款行地址
-	BankChannel            bool    `json:"bank_channel" form:"bank_channel"`                   // 银行卡收款渠道
-	QRCodeChannel          bool    `json:"qrcode_channel" form:"qrcode_channel"`               // 二维码收款渠道
-	AlipayChannel          bool    `json:"alipay_channel" form:"alipay_channel"`               // 支付宝H5渠道
-	IsSupportDayContract   bool    `json:"contract_day_status" form:"contract_day_status"`     // 按天合约类型
-	IsSupportWeekContract  bool    `json:"contract_week_status" form:"contract_week_status"`   // 按周合约类型
-	IsSupportMonthContract bool    `json:"contract_month_status" form:"contract_month_status"` // 按月合约类型
-	ContractDayFee         float64 `json:"contract_day_fee" form:"contract_day_fee"`           // 按天管理费率
-	ContractWeekFee        float64 `json:"contract_week_fee" form:"contract_week_fee"`         // 按周管理费率
-	ContractMonthFee       float64 `json:"contract_month_fee" form:"contract_month_fee"`       // 按月管理费率
-	ContractLever          []int64 `json:"contract_lever" form:"contract_lever"`               // 合约杠杆
-	AdminPhone             string  `json:"admin_phone" form:"admin_phone"`                     // 管理员手机号
+	LimitPct               float64 `json:"limit_pct" form:"limit_pct"`                             // 涨跌幅买入限制
+	CYBLimitPct            float64 `json:"cyb_limit_pct" form:"cyb_limit_pct"`                     // 创业板涨跌幅买入限制
+	KCBLimitPct            float64 `json:"kcb_limit_pct" form:"cyb_limit_pct"`                     // 科创板涨跌幅买入限制
+	STLimitPct             float64 `json:"st_limit_pct" form:"st_limit_pct"`                       // ST涨跌幅买入限制
+	IsSupportSTStock       bool    `json:"st_forbid" form:"st_forbid"`                             // ST股是否允许交易:true允许交易,false不允许交易
+	ClosePct               float64 `json:"close_pct" form:"close_pct"`                             // 平仓线率
+	WarnPct                float64 `json:"warn_pct" form:"warn_pct"`                               // 警戒线率
+	IsSupportKCBBoard      bool    `json:"sge_board_forbid" form:"sge_board_forbid"`               // 科创板是否允许交易:true允许交易,false不允许交易
+	BuyFee                 float64 `json:"buy_fee" form:"buy_fee"`                                 // 买入手续费
+	MiniChargeFee          float64 `json:"mini_charge_fee" form:"mini_charge_fee"`                 // 最低手续费
+	RegistCode             bool    `json:"regist_code" form:"regist_code"`                         // 注册须推荐码:true必须填写正确推荐码
+	WithdrawBeginTime      string  `json:"withdraw_begin_time" form:"withdraw_begin_time"`         // 提现开始时间
+	WithdrawEndTime        string  `json:"withdraw_end_time" form:"withdraw_end_time"`             // 提现结束时间
+	RechargeNotice         bool    `json:"recharge_notice" form:"recharge_notice"`                 // 用户充值短信通知管理:true通知,false不通知
+	RegisterNotice         bool    `json:"register_notice" form:"register_notice"`                 // 用户注册短信通知管理:true通知,false不通知
+	WithdrawNotice         bool    `json:"withdraw_notice" form:"withdraw_notice"`                 // 用户提现通知管理:true通知,false不通知
+	Broker                 bool    `json:"broker" form:"broker"`                                   // 是否对接券商:true对接,false不对接
+	WarnCanBuy             bool    `json:"warn_can_buy" form:"warn_can_buy"`                       // 触发警戒线允许买入:true允许买入,false不允许买入
+	IsSupportCYBBoard      bool    `json:"cyb_board_forbid" form:"cyb_board_forbid"`               // 创业板允许交易:true允许交易,false不允许交易
+	SellFee                float64 `json:"sell_fee" form:"sell_fee"`                               // 卖出手续费
+	SingleBuyPct           float64 `json:"single_buy_pct" form:"single_buy_pct"`                   // 单只股票最大持仓比率
+	HolidayCharge          bool    `json:"holiday_charge" form:"holiday_charge"`                   // 节假日收取管理费:true节假日收取留仓费,false不收取
+	BankName               string  `json:"bank_name" form:"bank_name"`                             // 收款人姓名
+	BankNo                 string  `json:"bank_no" form:"bank_no"`                                 // 收款银行卡号
+	BankAddr               string  `json:"bank_addr" form:"bank_addr"`                             // 收款行地址
+	BankChannel            bool    `json:"bank_channel" form:"bank_channel"`                       // 银行卡收款渠道
+	QRCodeChannel          bool    `json:"qrcode_channel" form:"qrcode_channel"`                   // 二维码收款渠道
+	AlipayChannel          bool    `json:"alipay_channel" form:"alipay_channel"`                   // 支付宝H5渠道
+	IsSupportDayContract   bool    `json:"contract_day_status" form:"contract_day_status"`         // 按天合约类型
+	IsSupportWeekContract  bool    `json:"contract_week_status" form:"contract_week_status"`       // 按周合约类型
+	IsSupportMonthContract bool    `json:"contract_month_status" form:"contract_month_status"`     // 按月合约类型
+	ContractDayFee         float64 `json:"contract_day_fee" form:"contract_day_fee"`               // 按天管理费率
+	ContractWeekFee        float64 `json:"contract_week_fee" form:"contract_week_fee"`             // 按周管理费率
+	ContractMonthFee       float64 `json:"contract_month_fee" form:"contract_month_fee"`           // 按月管理费率
+	ContractLever          []int64 `json:"contract_lever" form:"contract_lever"`                   // 合约杠杆
+	AdminPhone             string  `json:"admin_phone" form:"admin_phone"`                         // 管理员手机号
+	PasswordMinLen         int64   `json:"password_min_len" form:"password_min_len"`               // 密码最小长度
+	PasswordRequireLetter  bool    `json:"password_require_letter" form:"password_require_letter"` // 密码必须包含字母
+	PasswordRequireDigit   bool    `json:"password_require_digit" form:"password_require_digit"`   // 密码必须包含数字
+	PasswordRequireSymbol  bool    `json:"password_require_symbol" form:"password_require_symbol"` // 密码必须包含特殊字符
+	MatchMode              int64   `json:"match_mode" form:"match_mode"`                           // 模拟撮合方式:1最新价 2五档盘口 3成交量参与(VWAP)
+	MatchVolumePct         float64 `json:"match_volume_pct" form:"match_volume_pct"`               // 成交量参与比例
+	WithdrawReviewAmount   float64 `json:"withdraw_review_amount" form:"withdraw_review_amount"`   // 提现复核金额:0不复核
+	RechargeReviewAmount   float64 `json:"recharge_review_amount" form:"recharge_review_amount"`   // 人工充值复核金额:0不复核
+	WithdrawMinMoney       float64 `json:"withdraw_min_money" form:"withdraw_min_money"`           // 单笔最低提现金额
+	WithdrawMaxMoney       float64 `json:"withdraw_max_money" form:"withdraw_max_money"`           // 单笔最高提现金额:0不限制
+	WithdrawDayMoney       float64 `json:"withdraw_day_money" form:"withdraw_day_money"`           // 每日累计提现金额上限:0不限制
+	WithdrawDayCount       int64   `json:"withdraw_day_count" form:"withdraw_day_count"`           // 每日提现次数上限:0不限制
+	WithdrawWeekMoney      float64 `json:"withdraw_week_money" form:"withdraw_week_money"`         // 每周累计提现金额上限:0不限制
+	WithdrawCooldownHours  int64   `json:"withdraw_cooldown_hours" form:"withdraw_cooldown_hours"` // 充值后N小时内不能提现:0不限制
+	BrokerRouteStrategy    string  `json:"broker_route_strategy" form:"broker_route_strategy"`     // 券商分配策略
+	BrokerFailoverMode     int64   `json:"broker_failover_mode" form:"broker_failover_mode"`       // 券商断开后未成交委托:0等待重连 1转模拟撮合
 }
 
 // Register 注册handler
@@ -73,11 +90,19 @@ func (h *SystemHandler) Set(c *gin.Context) (interface{}, error) {
 	if err := c.Bind(&req); err != nil {
 		return nil, err
 	}
+	if !model.ValidBrokerRoute(req.BrokerRouteStrategy) {
+		return nil, serr.ErrBusiness("无效的券商分配策略")
+	}
 	levers := make([]string, 0)
 	for _, it := range req.ContractLever {
 		levers = append(levers, strconv.FormatInt(it, 10))
 	}
-	if err := dao.SysDaoInstance().Update(ctx, &model.SysParam{
+	before, err := dao.SysDaoInstance().GetSysParam(ctx)
+	if err != nil {
+		before = nil
+	}
+	AuditTarget(c, "sysparam", 0, before)
+	param := &model.SysParam{
 		StartWithdrawTime:      req.WithdrawBeginTime,
 		StopWithdrawTime:       req.WithdrawEndTime,
 		LimitPct:               req.LimitPct,
@@ -114,9 +139,27 @@ func (h *SystemHandler) Set(c *gin.Context) (interface{}, error) {
 		MiniChargeFee:          req.MiniChargeFee,
 		IsSupportBroker:        req.Broker,
 		AdminPhone:             req.AdminPhone,
-	}); err != nil {
+		PasswordMinLen:         req.PasswordMinLen,
+		PasswordRequireLetter:  req.PasswordRequireLetter,
+		PasswordRequireDigit:   req.PasswordRequireDigit,
+		PasswordRequireSymbol:  req.PasswordRequireSymbol,
+		MatchMode:              req.MatchMode,
+		MatchVolumePct:         req.MatchVolumePct,
+		WithdrawReviewAmount:   req.WithdrawReviewAmount,
+		RechargeReviewAmount:   req.RechargeReviewAmount,
+		WithdrawMinMoney:       req.WithdrawMinMoney,
+		WithdrawMaxMoney:       req.WithdrawMaxMoney,
+		WithdrawDayMoney:       req.WithdrawDayMoney,
+		WithdrawDayCount:       req.WithdrawDayCount,
+		WithdrawWeekMoney:      req.WithdrawWeekMoney,
+		WithdrawCooldownHours:  req.WithdrawCooldownHours,
+		BrokerRouteStrategy:    req.BrokerRouteStrategy,
+		BrokerFailoverMode:     req.BrokerFailoverMode,
+	}
+	if err := dao.SysDaoInstance().Update(ctx, param); err != nil {
 		return nil, err
 	}
+	AuditAfter(c, param)
 	return map[string]interface{}{
 		"result": true,
 	}, nil
@@ -175,5 +218,21 @@ func (h *SystemHandler) Get(c *gin.Context) (interface{}, error) {
 		ContractMonthFee:       sys.MonthContractFee,
 		ContractLever:          contractLevers,
 		AdminPhone:             sys.AdminPhone,
+		PasswordMinLen:         sys.PasswordMinLen,
+		PasswordRequireLetter:  sys.PasswordRequireLetter,
+		PasswordRequireDigit:   sys.PasswordRequireDigit,
+		PasswordRequireSymbol:  sys.PasswordRequireSymbol,
+		MatchMode:              sys.MatchMode,
+		MatchVolumePct:         sys.MatchVolumePct,
+		WithdrawReviewAmount:   sys.WithdrawReviewAmount,
+		RechargeReviewAmount:   sys.RechargeReviewAmount,
+		WithdrawMinMoney:       sys.WithdrawMinMoney,
+		WithdrawMaxMoney:       sys.WithdrawMaxMoney,
+		WithdrawDayMoney:       sys.WithdrawDayMoney,
+		WithdrawDayCount:       sys.WithdrawDayCount,
+		WithdrawWeekMoney:      sys.WithdrawWeekMoney,
+		WithdrawCooldownHours:  sys.WithdrawCooldownHours,
+		BrokerRouteStrategy:    sys.BrokerRouteStrategy,
+		BrokerFailoverMode:     sys.BrokerFailoverMode,
 	}, nil
 }
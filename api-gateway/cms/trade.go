@@ -35,6 +35,155 @@ func (h *TradeHandle) Register(e *gin.Engine) {
 	e.POST("/cms/trade/position/sell_stock", JSONWrapper(h.SellStock))                       // 股票交易-持仓-平仓
 	e.GET("/cms/trade/detail", JSONWrapper(h.TradeDetail))                                   // 股票交易-明细
 	e.GET("/cms/trade/entrust", JSONWrapper(h.TradeEntrust))                                 // 股票交易-委托
+	e.GET("/cms/trade/condition", JSONWrapper(h.TradeCondition))                             // 股票交易-条件单
+	e.GET("/cms/trade/repo", JSONWrapper(h.TradeRepo))                                       // 股票交易-国债逆回购
+}
+
+// TradeRepo 股票交易-国债逆回购
+func (h *TradeHandle) TradeRepo(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	tx := db.StockDB().WithContext(ctx).Table("reverse_repo")
+	tx.Where("uid in (?)", AgentFilter(c))
+	ContractIDFilter(c, tx)
+	UserNameFilter(c, tx)
+	if status, err := Int64(c, "status"); err == nil && status > 0 {
+		tx.Where("status = ?", status)
+	}
+
+	var repos []*model.ReverseRepo
+	if err := tx.Order("id desc").Find(&repos).Error; err != nil {
+		return nil, err
+	}
+
+	roleMap := RoleMap(ctx)
+	userMap := UsersMap(ctx)
+	contractMap := ContractMap(ctx)
+	list := make([]*model.TradeRepoResp, 0)
+	for _, it := range repos {
+		user, ok := userMap[it.UID]
+		if !ok {
+			continue
+		}
+		contract, ok := contractMap[it.ContractID]
+		if !ok {
+			contract = &model.Contract{}
+		}
+		list = append(list, &model.TradeRepoResp{
+			ID:              it.ID,
+			UserName:        user.UserName,
+			Name:            user.Name,
+			Agent:           roleMap[user.RoleID],
+			Time:            it.CreateTime.Format("2006-01-02 15:04:05"),
+			ContractID:      it.ContractID,
+			ContractName:    contract.FullName(),
+			Source:          model.ReverseRepoSourceMap[it.Source],
+			Code:            it.Code,
+			RepoName:        it.Name,
+			Tenor:           it.Tenor,
+			EntrustRate:     it.EntrustRate,
+			Money:           it.Money,
+			Rate:            it.Rate,
+			Fee:             it.Fee,
+			Interest:        it.Interest,
+			AccruedInterest: it.AccruedInterest,
+			InterestDays:    it.InterestDays,
+			ExpireDate:      it.ExpireDate,
+			AvailableDate:   it.AvailableDate,
+			Status:          model.ReverseRepoStatusMap[it.Status],
+			UpdateTime:      it.UpdateTime.Format("2006-01-02 15:04:05"),
+			Remark:          it.Remark,
+		})
+	}
+
+	// 下载则下发文件
+	if IsDownload(c) {
+		var res []interface{}
+		for _, it := range list {
+			res = append(res, it)
+		}
+		Download(c, []string{
+			"ID", "用户名称", "姓名", "代理机构", "时间", "合约ID", "合约名称", "资金来源", "证券代码", "证券简称", "期限(天)",
+			"委托利率", "融出金额", "成交利率", "手续费", "到期利息", "已计提利息", "计息天数", "到期交收日", "资金可用日", "状态", "更新时间", "备注",
+		}, res)
+	}
+
+	count := len(list)
+	start, end := SlicePage(c, count)
+	return map[string]interface{}{
+		"list":  list[start:end],
+		"total": count,
+	}, nil
+}
+
+// TradeCondition 股票交易-条件单
+func (h *TradeHandle) TradeCondition(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	tx := db.StockDB().WithContext(ctx).Table("conditional_order")
+	tx.Where("uid in (?)", AgentFilter(c))
+	ContractIDFilter(c, tx)
+	UserNameFilter(c, tx)
+	if status, err := Int64(c, "status"); err == nil && status > 0 {
+		tx.Where("status = ?", status)
+	}
+
+	var orders []*model.ConditionalOrder
+	if err := tx.Order("id desc").Find(&orders).Error; err != nil {
+		return nil, err
+	}
+
+	roleMap := RoleMap(ctx)
+	userMap := UsersMap(ctx)
+	contractMap := ContractMap(ctx)
+	list := make([]*model.TradeConditionResp, 0)
+	for _, it := range orders {
+		user, ok := userMap[it.UID]
+		if !ok {
+			continue
+		}
+		contract, ok := contractMap[it.ContractID]
+		if !ok {
+			contract = &model.Contract{}
+		}
+		list = append(list, &model.TradeConditionResp{
+			ID:           it.ID,
+			UserName:     user.UserName,
+			Name:         user.Name,
+			Agent:        roleMap[user.RoleID],
+			Time:         it.CreateTime.Format("2006-01-02 15:04:05"),
+			ContractID:   it.ContractID,
+			ContractName: contract.FullName(),
+			StockCode:    it.StockCode,
+			StockName:    it.StockName,
+			Type:         model.ConditionalOrderTypeMap[it.Type],
+			TriggerPrice: it.TriggerPrice,
+			TrailPct:     it.TrailPct,
+			HighPrice:    it.HighPrice,
+			Amount:       it.Amount,
+			TradeDate:    it.TradeDate,
+			Status:       model.ConditionalOrderStatusMap[it.Status],
+			UpdateTime:   it.UpdateTime.Format("2006-01-02 15:04:05"),
+			Remark:       it.Remark,
+		})
+	}
+
+	// 下载则下发文件
+	if IsDownload(c) {
+		var res []interface{}
+		for _, it := range list {
+			res = append(res, it)
+		}
+		Download(c, []string{
+			"ID", "用户名称", "姓名", "代理机构", "时间", "合约ID", "合约名称", "股票代码", "股票名称",
+			"条件单类型", "触发价格", "回撤比例", "最高价", "委托数量", "生效交易日", "状态", "更新时间", "备注",
+		}, res)
+	}
+
+	count := len(list)
+	start, end := SlicePage(c, count)
+	return map[string]interface{}{
+		"list":  list[start:end],
+		"total": count,
+	}, nil
 }
 
 // TradeEntrust 股票交易-委托
@@ -268,8 +417,11 @@ func (h *TradeHandle) TradeDetail(c *gin.Context) (interface{}, error) {
 			typ = "限价卖出"
 		}
 		mode := "主动卖出"
-		if it.Mode == 2 {
+		switch it.Mode {
+		case model.SystemMode:
 			mode = "系统卖出"
+		case model.ConditionalMode:
+			mode = "条件单卖出"
 		}
 		sellItems = append(sellItems, &model.SellDetailItem{
 			ID:            it.ID,
@@ -318,6 +470,7 @@ func (h *TradeHandle) SellStock(c *gin.Context) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
+	AuditTarget(c, "position", position.ID, position)
 	if err := service.TradeServiceInstance().Sell(ctx, &model.EntrustPackage{
 		UID:         position.UID,                    // 用户UID
 		ContractID:  position.ContractID,             // 合约ID
@@ -328,6 +481,9 @@ func (h *TradeHandle) SellStock(c *gin.Context) (interface{}, error) {
 	}); err != nil {
 		return nil, err
 	}
+	if after, err := dao.PositionDaoInstance().GetPositionByEntrustID(ctx, req.EntrustID); err == nil {
+		AuditAfter(c, after)
+	}
 	return map[string]interface{}{
 		"result": true,
 	}, nil
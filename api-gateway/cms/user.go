@@ -9,7 +9,6 @@ import (
 	"stock/api-gateway/serr"
 	"stock/api-gateway/service"
 	"stock/api-gateway/util"
-	"stock/common/errgroup"
 	"stock/common/log"
 	"stock/common/timeconv"
 
@@ -44,63 +43,40 @@ func (h *UserHandler) Register(e *gin.Engine) {
 
 }
 
-// SetWithdraw 用户提现-确认
+// SetWithdraw 用户提现-审批:通过或驳回,达到复核金额须两人审批
 func (h *UserHandler) SetWithdraw(c *gin.Context) (interface{}, error) {
 	ctx := util.RPCContext(c)
 	type request struct {
-		ID     int64 `form:"id" json:"id"`
-		Status bool  `form:"status" json:"status"`
+		ID     int64  `form:"id" json:"id"`
+		Status bool   `form:"status" json:"status"`
+		Reason string `form:"reason" json:"reason"` // 驳回原因
 	}
 	var req request
 	if err := c.Bind(&req); err != nil {
 		return nil, err
 	}
-	transfer, err := dao.TransferDaoInstance().GetByID(ctx, req.ID)
+	before, err := dao.TransferDaoInstance().GetByID(ctx, req.ID)
 	if err != nil {
 		return nil, err
 	}
-	user, err := dao.UserDaoInstance().GetUserByUID(ctx, transfer.UID)
-	if err != nil {
-		return nil, err
+	if before.Type != model.TransferTypeWithdraw {
+		return nil, serr.ErrBusiness("申请不存在")
 	}
+	AuditTarget(c, "transfer", before.ID, before)
 
+	var transfer *model.Transfer
 	if req.Status {
-		// 转出成功
-		transfer.Status = model.TransferStatusSuccess
-		user.FreezeMoney -= transfer.Money
+		transfer, err = service.TransferReviewServiceInstance().Approve(ctx, req.ID, Username(c))
 	} else {
-		// 转出失败
-		transfer.Status = model.TransferStatusFail
-		user.Money += transfer.Money
-		user.FreezeMoney -= transfer.Money
+		transfer, err = service.TransferReviewServiceInstance().Reject(ctx, req.ID, Username(c), req.Reason)
 	}
-	if err := dao.TransferDaoInstance().Create(ctx, transfer); err != nil {
-		log.Errorf("Create err:%+v", err)
-		return nil, err
-	}
-
-	wg := errgroup.GroupWithCount(2)
-	wg.Go(func() error {
-		if err := dao.UserDaoInstance().CreateUser(ctx, user); err != nil {
-			return err
-		}
-		return nil
-	})
-	wg.Go(func() error {
-		if req.Status {
-			if err := service.SmsServiceInstance().SendSms(ctx, fmt.Sprintf("您转出资金到账:%0.2f,请打开App查看", transfer.Money), user.UserName); err != nil {
-				log.Errorf("短信提醒失败:%+v", err)
-			}
-		}
-		return nil
-	})
-
-	if err := wg.Wait(); err != nil {
+	if err != nil {
 		return nil, err
 	}
-
+	AuditAfter(c, transfer)
 	return map[string]interface{}{
 		"result": true,
+		"status": transfer.Status,
 	}, nil
 }
 
@@ -139,8 +115,8 @@ func (h *UserHandler) Withdraw(c *gin.Context) (interface{}, error) {
 	a := make([]*model.Transfer, 0)
 	b := make([]*model.Transfer, 0)
 	for _, it := range transfer {
-		if it.Status == model.TransferStatusWaitExam {
-			a = append(a, it) // 待审核
+		if it.Status == model.TransferStatusWaitExam || it.Status == model.TransferStatusWaitReview {
+			a = append(a, it) // 待审核、待复核
 		} else {
 			b = append(b, it) // 成功、失败
 		}
@@ -172,8 +148,11 @@ func (h *UserHandler) Withdraw(c *gin.Context) (interface{}, error) {
 			Time:     it.OrderTime.Format("2006-01-02 15:04:05"),
 			Money:    it.Money,
 			BankName: it.Name,
-			BankNo:   it.BankNo,
-			Status:   it.Status, // 1待审核 2成功 3失败
+			BankNo:   it.BankNo.String(), // 审核打款需要完整卡号
+			Status:   it.Status,          // 1待审核 2成功 3失败 4待复核
+			Reviewer: it.Reviewer,
+			Checker:  it.Checker,
+			Reason:   it.Reason,
 		})
 	}
 
@@ -182,7 +161,7 @@ func (h *UserHandler) Withdraw(c *gin.Context) (interface{}, error) {
 		for _, it := range list {
 			res = append(res, it)
 		}
-		Download(c, []string{"流水号", "用户名称", "姓名", "代理机构", "时间", "金额", "收款人姓名", "银行卡号", "审核状态:1待审核2成功3失败"}, res)
+		Download(c, []string{"流水号", "用户名称", "姓名", "代理机构", "时间", "金额", "收款人姓名", "银行卡号", "审核状态:1待审核2成功3失败4待复核", "初审人", "复核人", "驳回原因"}, res)
 	}
 
 	count := len(list)
@@ -193,45 +172,40 @@ func (h *UserHandler) Withdraw(c *gin.Context) (interface{}, error) {
 	}, nil
 }
 
-// SetRecharge 用户管理-用户充值-确认
+// SetRecharge 用户管理-用户充值-审批:通过或驳回,达到复核金额须两人审批
 func (h *UserHandler) SetRecharge(c *gin.Context) (interface{}, error) {
 	ctx := util.RPCContext(c)
 	type request struct {
-		ID     int64 `form:"id" json:"id"`
-		Status bool  `form:"status" json:"status"`
+		ID     int64  `form:"id" json:"id"`
+		Status bool   `form:"status" json:"status"`
+		Reason string `form:"reason" json:"reason"` // 驳回原因
 	}
 	var req request
 	if err := c.Bind(&req); err != nil {
 		return nil, err
 	}
-	transfer, err := dao.TransferDaoInstance().GetByID(ctx, req.ID)
+	before, err := dao.TransferDaoInstance().GetByID(ctx, req.ID)
 	if err != nil {
 		return nil, err
 	}
-	user, err := dao.UserDaoInstance().GetUserByUID(ctx, transfer.UID)
-	if err != nil {
-		return nil, err
+	if before.Type != model.TransferTypeRecharge {
+		return nil, serr.ErrBusiness("申请不存在")
 	}
+	AuditTarget(c, "transfer", before.ID, before)
 
+	var transfer *model.Transfer
 	if req.Status {
-		transfer.Status = model.TransferStatusSuccess
-		user.Money += transfer.Money
-		if err := dao.UserDaoInstance().CreateUser(ctx, user); err != nil {
-			return nil, err
-		}
-		if err := service.SmsServiceInstance().SendSms(ctx, fmt.Sprintf("您转入资金到账:%0.2f,请打开App查看", transfer.Money), user.UserName); err != nil {
-			log.Errorf("短信提醒失败:%+v", err)
-		}
+		transfer, err = service.TransferReviewServiceInstance().Approve(ctx, req.ID, Username(c))
 	} else {
-		transfer.Status = model.TransferStatusFail
+		transfer, err = service.TransferReviewServiceInstance().Reject(ctx, req.ID, Username(c), req.Reason)
 	}
-	if err := dao.TransferDaoInstance().Create(ctx, transfer); err != nil {
-		log.Errorf("Create err:%+v", err)
+	if err != nil {
 		return nil, err
 	}
-
+	AuditAfter(c, transfer)
 	return map[string]interface{}{
 		"result": true,
+		"status": transfer.Status,
 	}, nil
 }
 
@@ -270,8 +244,8 @@ func (h *UserHandler) Recharge(c *gin.Context) (interface{}, error) {
 	a := make([]*model.Transfer, 0)
 	b := make([]*model.Transfer, 0)
 	for _, it := range transfer {
-		if it.Status == model.TransferStatusWaitExam {
-			a = append(a, it) // 待审核
+		if it.Status == model.TransferStatusWaitExam || it.Status == model.TransferStatusWaitReview {
+			a = append(a, it) // 待审核、待复核
 		} else {
 			b = append(b, it) // 成功、失败
 		}
@@ -304,6 +278,9 @@ func (h *UserHandler) Recharge(c *gin.Context) (interface{}, error) {
 			OrderNo:  it.OrderNo,
 			Channel:  it.Channel,
 			Status:   it.Status,
+			Reviewer: it.Reviewer,
+			Checker:  it.Checker,
+			Reason:   it.Reason,
 		})
 	}
 
@@ -312,7 +289,7 @@ func (h *UserHandler) Recharge(c *gin.Context) (interface{}, error) {
 		for _, it := range list {
 			res = append(res, it)
 		}
-		Download(c, []string{"流水号", "用户名称", "姓名", "代理机构", "时间", "金额", "订单流水号", "充值渠道", "审核状态:1待审核2成功3失败"}, res)
+		Download(c, []string{"流水号", "用户名称", "姓名", "代理机构", "时间", "金额", "订单流水号", "充值渠道", "审核状态:1待审核2成功3失败4待复核", "初审人", "复核人", "驳回原因"}, res)
 	}
 
 	count := len(list)
@@ -338,6 +315,7 @@ func (h *UserHandler) UpdateStatus(c *gin.Context) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
+	AuditTarget(c, "users", user.ID, user)
 
 	if !req.Status {
 		user.Status = model.UserStatusActive
@@ -347,6 +325,13 @@ func (h *UserHandler) UpdateStatus(c *gin.Context) (interface{}, error) {
 	if err := dao.UserDaoInstance().CreateUser(ctx, user); err != nil {
 		return nil, err
 	}
+	AuditAfter(c, user)
+	// 冻结用户后,注销其所有设备上的登录
+	if user.Status == model.UserStatusFrezze {
+		if err := service.SessionServiceInstance().LogoutAll(ctx, user.ID); err != nil {
+			log.Errorf("LogoutAll err:%+v", err)
+		}
+	}
 	return map[string]interface{}{
 		"result": true,
 	}, nil
@@ -372,6 +357,7 @@ func (h *UserHandler) UpdateUser(c *gin.Context) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
+	AuditTarget(c, "users", user.ID, user)
 	if len(req.Agent) > 0 {
 		role, err := dao.RoleDaoInstance().GetRoleByUserName(ctx, req.Agent)
 		if err != nil {
@@ -379,15 +365,29 @@ func (h *UserHandler) UpdateUser(c *gin.Context) (interface{}, error) {
 		}
 		user.RoleID = role.ID
 	}
+	// 直接修改余额视为人工调账
+	journal := model.NewJournal(model.LedgerBizAdjust, user.ID, fmt.Sprintf("后台修改用户资金,操作员:%s", Username(c)))
 	if req.Money > 0 {
+		journal.Move(model.AdjustAccount, model.WalletAccount(user.ID), req.Money-user.Money)
 		user.Money = req.Money
 	}
 	if req.FreezeMoney > 0 {
+		journal.Move(model.AdjustAccount, model.FreezeAccount(user.ID), req.FreezeMoney-user.FreezeMoney)
 		user.FreezeMoney = req.FreezeMoney
 	}
-	if err := dao.UserDaoInstance().CreateUser(ctx, user); err != nil {
+	tx := db.StockDB().WithContext(ctx).Begin()
+	defer tx.Rollback()
+	if err := dao.UserDaoInstance().UpdateUserWithTx(tx, user); err != nil {
+		return nil, err
+	}
+	if err := service.LedgerServiceInstance().PostWithTx(tx, journal); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Errorf("事务提交失败:%+v", err)
 		return nil, err
 	}
+	AuditAfter(c, user)
 	return map[string]interface{}{
 		"result": true,
 	}, nil
@@ -416,9 +416,9 @@ func (h *UserHandler) GetByID(c *gin.Context) (interface{}, error) {
 		"id":           user.ID,
 		"user_name":    user.UserName,
 		"name":         user.Name,
-		"password":     user.Password,
+		"password":     "",
 		"status":       status,
-		"id_no":        user.ICCID,
+		"id_no":        util.MaskIDCard(user.ICCID.String()),
 		"agent":        roleMap[user.RoleID],
 		"money":        user.Money,
 		"freeze_money": user.FreezeMoney,
@@ -479,14 +479,14 @@ func (h *UserHandler) UserList(c *gin.Context) (interface{}, error) {
 		userList = append(userList, &model.UserListResp{
 			ID:             it.ID,
 			UserName:       it.UserName,
-			Password:       it.Password,
+			Password:       "",
 			Name:           it.Name,
 			Agent:          roleMap[it.RoleID],
 			Money:          it.Money,
 			FreezeMoney:    it.FreezeMoney,
 			Broker:         true,
 			Contract:       contractMap[it.ID],
-			Authentication: len(it.ICCID) > 0,
+			Authentication: it.Verified(),
 			Online:         Online(ctx, it.ID),
 			RegisterTime:   it.CreateAt.Format("2006-01-02 15:04:05"),
 			Status:         it.Status == model.UserStatusActive,
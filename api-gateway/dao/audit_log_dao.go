@@ -0,0 +1,60 @@
+package dao
+
+import (
+	"context"
+	"stock/api-gateway/db"
+	"stock/api-gateway/model"
+	"stock/common/log"
+)
+
+// AuditLogDao 后台操作审计日志:只提供写入与查询
+type AuditLogDao struct{}
+
+var _auditLogDao = &AuditLogDao{}
+
+// AuditLogDaoInstance 提供一个可用的对象
+func AuditLogDaoInstance() *AuditLogDao {
+	return _auditLogDao
+}
+
+// Create 写入审计日志
+func (s *AuditLogDao) Create(ctx context.Context, audit *model.AuditLog) error {
+	if err := db.StockDB().WithContext(ctx).Table("audit_log").Create(audit).Error; err != nil {
+		log.Errorf("写入审计日志失败:%+v", err)
+		return err
+	}
+	return nil
+}
+
+// Search 按条件分页查询审计日志,按时间倒序
+func (s *AuditLogDao) Search(ctx context.Context, query *model.AuditLogQuery) ([]*model.AuditLog, int64, error) {
+	tx := db.StockDB().WithContext(ctx).Table("audit_log")
+	if query.Operator != "" {
+		tx.Where("operator = ?", query.Operator)
+	}
+	if query.Path != "" {
+		tx.Where("path like ?", query.Path+"%")
+	}
+	if query.TargetType != "" {
+		tx.Where("target_type = ?", query.TargetType)
+	}
+	if query.TargetID > 0 {
+		tx.Where("target_id = ?", query.TargetID)
+	}
+	if !query.BeginTime.IsZero() {
+		tx.Where("create_time >= ?", query.BeginTime)
+	}
+	if !query.EndTime.IsZero() {
+		tx.Where("create_time < ?", query.EndTime)
+	}
+
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var list []*model.AuditLog
+	if err := tx.Order("id desc").Offset(query.Offset).Limit(query.Limit).Find(&list).Error; err != nil {
+		return nil, 0, err
+	}
+	return list, total, nil
+}
@@ -6,6 +6,7 @@ import (
 	"stock/api-gateway/db"
 	"stock/api-gateway/model"
 	"stock/common/log"
+	"time"
 
 	"gorm.io/gorm/clause"
 )
@@ -139,6 +140,38 @@ func (s *BrokerEntrustDao) GetTodayEntrusts(ctx context.Context) ([]*model.Broke
 	return list, nil
 }
 
+// GetOpenByBrokerID 券商当日未终态的委托
+func (s *BrokerEntrustDao) GetOpenByBrokerID(ctx context.Context, brokerID int64) ([]*model.BrokerEntrust, error) {
+	var list []*model.BrokerEntrust
+	finally := []int64{model.EntrustStatusTypeDeal, model.EntrustStatusTypeWithdraw, model.EntrustStatusTypePartDealPartWithdraw, model.EntrustStatusTypeCancel}
+	if err := db.StockDB().WithContext(ctx).Table("broker_entrust").
+		Where("broker_id = ? and status not in (?) and date(order_time)=CURRENT_DATE", brokerID, finally).Find(&list).Error; err != nil {
+		log.Errorf("GetOpenByBrokerID err:%+v", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// GetDeals 券商有成交的委托,股票代码为空不过滤,时间为零值不过滤
+func (s *BrokerEntrustDao) GetDeals(ctx context.Context, brokerID int64, stockCode string, begin, end time.Time) ([]*model.BrokerEntrust, error) {
+	var list []*model.BrokerEntrust
+	query := db.StockDB().WithContext(ctx).Table("broker_entrust").Where("broker_id = ? and deal_amount > 0", brokerID)
+	if stockCode != "" {
+		query = query.Where("stock_code = ?", stockCode)
+	}
+	if !begin.IsZero() {
+		query = query.Where("order_time >= ?", begin)
+	}
+	if !end.IsZero() {
+		query = query.Where("order_time < ?", end)
+	}
+	if err := query.Order("order_time, id").Find(&list).Error; err != nil {
+		log.Errorf("GetDeals err:%+v", err)
+		return nil, err
+	}
+	return list, nil
+}
+
 // GetTodayEntrustsByEntrustNos 根据委托编号查询委托
 func (s *BrokerEntrustDao) GetTodayEntrustsByEntrustNos(ctx context.Context, entrustNos []string) ([]*model.BrokerEntrust, error) {
 	var list []*model.BrokerEntrust
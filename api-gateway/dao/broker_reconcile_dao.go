@@ -0,0 +1,114 @@
+package dao
+
+import (
+	"context"
+	"stock/api-gateway/db"
+	"stock/api-gateway/model"
+	"stock/common/log"
+
+	"gorm.io/gorm"
+)
+
+// BrokerReconcileDao 券商对账
+type BrokerReconcileDao struct{}
+
+var _brokerReconcileDao = &BrokerReconcileDao{}
+
+// BrokerReconcileDaoInstance 提供一个可用的对象
+func BrokerReconcileDaoInstance() *BrokerReconcileDao {
+	return _brokerReconcileDao
+}
+
+// Create 保存对账结果、差异及券商持仓
+func (s *BrokerReconcileDao) Create(ctx context.Context, report *model.BrokerReconcile, breaks []*model.BrokerReconcileBreak,
+	positions []*model.BrokerReconcilePosition) error {
+	return db.StockDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("broker_reconcile").Create(report).Error; err != nil {
+			log.Errorf("保存券商对账失败:%+v", err)
+			return err
+		}
+		if len(breaks) > 0 {
+			for _, it := range breaks {
+				it.ReconcileID = report.ID
+			}
+			if err := tx.Table("broker_reconcile_break").Create(&breaks).Error; err != nil {
+				log.Errorf("保存券商对账差异失败:%+v", err)
+				return err
+			}
+		}
+		if len(positions) > 0 {
+			for _, it := range positions {
+				it.ReconcileID = report.ID
+			}
+			if err := tx.Table("broker_reconcile_position").Create(&positions).Error; err != nil {
+				log.Errorf("保存券商对账持仓失败:%+v", err)
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetPositions 对账时的券商持仓
+func (s *BrokerReconcileDao) GetPositions(ctx context.Context, reconcileID int64) ([]*model.BrokerReconcilePosition, error) {
+	var list []*model.BrokerReconcilePosition
+	if err := db.StockDB().WithContext(ctx).Table("broker_reconcile_position").Where("reconcile_id = ?", reconcileID).
+		Find(&list).Error; err != nil {
+		log.Errorf("GetPositions err:%+v", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// GetLast 对账日期之前最近一次收盘后查询成功的对账,没有返回nil
+func (s *BrokerReconcileDao) GetLast(ctx context.Context, brokerID int64, billDate int32) (*model.BrokerReconcile, error) {
+	var list []*model.BrokerReconcile
+	if err := db.StockDB().WithContext(ctx).Table("broker_reconcile").
+		Where("broker_id = ? and bill_date < ? and status <> ? and time(create_time) >= ?",
+			brokerID, billDate, model.BrokerReconcileFail, model.BrokerReconcileCloseTime).
+		Order("bill_date desc, id desc").Limit(1).Find(&list).Error; err != nil {
+		log.Errorf("GetLast err:%+v", err)
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, nil
+	}
+	return list[0], nil
+}
+
+// List 对账列表,按对账时间倒序
+func (s *BrokerReconcileDao) List(ctx context.Context, brokerID int64, limit int) ([]*model.BrokerReconcile, error) {
+	var list []*model.BrokerReconcile
+	query := db.StockDB().WithContext(ctx).Table("broker_reconcile")
+	if brokerID > 0 {
+		query = query.Where("broker_id = ?", brokerID)
+	}
+	if err := query.Order("id desc").Limit(limit).Find(&list).Error; err != nil {
+		log.Errorf("查询券商对账失败:%+v", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// GetBreaks 对账差异
+func (s *BrokerReconcileDao) GetBreaks(ctx context.Context, reconcileIDs []int64) ([]*model.BrokerReconcileBreak, error) {
+	var list []*model.BrokerReconcileBreak
+	if len(reconcileIDs) == 0 {
+		return list, nil
+	}
+	if err := db.StockDB().WithContext(ctx).Table("broker_reconcile_break").Where("reconcile_id in (?)", reconcileIDs).
+		Order("severity desc, id").Find(&list).Error; err != nil {
+		log.Errorf("查询券商对账差异失败:%+v", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// GetBreak 根据ID查询对账差异
+func (s *BrokerReconcileDao) GetBreak(ctx context.Context, id int64) (*model.BrokerReconcileBreak, error) {
+	var it *model.BrokerReconcileBreak
+	if err := db.StockDB().WithContext(ctx).Table("broker_reconcile_break").Where("id = ?", id).Take(&it).Error; err != nil {
+		return nil, err
+	}
+	return it, nil
+}
@@ -22,8 +22,7 @@ func BuyDaoInstance() *BuyDao {
 
 func (s *BuyDao) GetBuyByPositionIDs(ctx context.Context, positionID []int64) ([]*model.Buy, error) {
 	var list []*model.Buy
-	sql := "select * from buy where position_id in ? "
-	err := db.StockDB().WithContext(ctx).Raw(sql, positionID).Find(&list).Error
+	err := db.StockDB().WithContext(ctx).Table("buy").Where("position_id in (?)", positionID).Find(&list).Error
 	if err != nil {
 		return nil, serr.New(serr.ErrCodeBusinessFail, "系统错误:查询买入订单错误")
 	}
@@ -54,8 +53,7 @@ func (s *BuyDao) CreateWithTx(tx *gorm.DB, buy *model.Buy) error {
 // GetByEntrustIDs 根据委托id查询买入记录
 func (s *BuyDao) GetByEntrustIDs(ctx context.Context, entrustIDs []int64) ([]*model.Buy, error) {
 	var list []*model.Buy
-	sql := "select * from buy where entrust_id in (?)"
-	if err := db.StockDB().WithContext(ctx).Raw(sql, entrustIDs).Find(&list).Error; err != nil {
+	if err := db.StockDB().WithContext(ctx).Table("buy").Where("entrust_id in (?)", entrustIDs).Find(&list).Error; err != nil {
 		log.Errorf("GetBuyByEntrustIDs 错误:%+v", err)
 		return nil, serr.ErrBusiness("查询记录失败")
 	}
@@ -65,8 +63,7 @@ func (s *BuyDao) GetByEntrustIDs(ctx context.Context, entrustIDs []int64) ([]*mo
 // GetByEntrustID 根据委托id查询买入记录
 func (s *BuyDao) GetByEntrustID(ctx context.Context, entrustID int64) (*model.Buy, error) {
 	var buy *model.Buy
-	sql := "select * from buy where entrust_id = ?"
-	if err := db.StockDB().WithContext(ctx).Raw(sql, entrustID).Take(&buy).Error; err != nil {
+	if err := db.StockDB().WithContext(ctx).Table("buy").Where("entrust_id = ?", entrustID).Take(&buy).Error; err != nil {
 		return nil, err
 	}
 	return buy, nil
@@ -0,0 +1,90 @@
+package dao
+
+import (
+	"context"
+	"stock/api-gateway/db"
+	"stock/api-gateway/model"
+	"stock/common/log"
+	"time"
+)
+
+// ConditionalOrderDao 条件单
+type ConditionalOrderDao struct{}
+
+var _conditionalOrderDao = &ConditionalOrderDao{}
+
+// ConditionalOrderDaoInstance 提供一个可用的对象
+func ConditionalOrderDaoInstance() *ConditionalOrderDao {
+	return _conditionalOrderDao
+}
+
+// Create 创建条件单
+func (s *ConditionalOrderDao) Create(ctx context.Context, order *model.ConditionalOrder) error {
+	order.CreateTime = time.Now()
+	order.UpdateTime = order.CreateTime
+	if err := db.StockDB().WithContext(ctx).Table("conditional_order").Create(order).Error; err != nil {
+		log.Errorf("创建条件单失败:%+v", err)
+		return err
+	}
+	return nil
+}
+
+// GetByID 根据ID查询条件单
+func (s *ConditionalOrderDao) GetByID(ctx context.Context, id int64) (*model.ConditionalOrder, error) {
+	var order *model.ConditionalOrder
+	if err := db.StockDB().WithContext(ctx).Table("conditional_order").Where("id = ?", id).Take(&order).Error; err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// GetByContractID 根据合约查询条件单
+func (s *ConditionalOrderDao) GetByContractID(ctx context.Context, contractID int64) ([]*model.ConditionalOrder, error) {
+	var list []*model.ConditionalOrder
+	if err := db.StockDB().WithContext(ctx).Table("conditional_order").Where("contract_id = ?", contractID).Order("id desc").Find(&list).Error; err != nil {
+		log.Errorf("查询条件单失败:%+v", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// GetActive 查询监控中的条件单
+func (s *ConditionalOrderDao) GetActive(ctx context.Context) ([]*model.ConditionalOrder, error) {
+	var list []*model.ConditionalOrder
+	if err := db.StockDB().WithContext(ctx).Table("conditional_order").Where("status = ?", model.ConditionalOrderStatusActive).Order("id").Find(&list).Error; err != nil {
+		log.Errorf("查询监控中的条件单失败:%+v", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// UpdateHighPrice 更新跟踪止损最高价
+func (s *ConditionalOrderDao) UpdateHighPrice(ctx context.Context, id int64, highPrice float64) error {
+	if err := db.StockDB().WithContext(ctx).Table("conditional_order").Where("id = ? and high_price < ?", id, highPrice).
+		Updates(map[string]interface{}{"high_price": highPrice, "update_time": time.Now()}).Error; err != nil {
+		log.Errorf("更新条件单最高价失败:%+v", err)
+		return err
+	}
+	return nil
+}
+
+// UpdateStatus 更新监控中的条件单状态,返回是否更新成功(条件单已不在监控中则返回false)
+func (s *ConditionalOrderDao) UpdateStatus(ctx context.Context, id, status int64, remark string) (bool, error) {
+	ret := db.StockDB().WithContext(ctx).Table("conditional_order").Where("id = ? and status = ?", id, model.ConditionalOrderStatusActive).
+		Updates(map[string]interface{}{"status": status, "remark": remark, "update_time": time.Now()})
+	if ret.Error != nil {
+		log.Errorf("更新条件单状态失败:%+v", ret.Error)
+		return false, ret.Error
+	}
+	return ret.RowsAffected == 1, nil
+}
+
+// UpdateResult 更新已触发条件单的结果
+func (s *ConditionalOrderDao) UpdateResult(ctx context.Context, id, status int64, remark string) error {
+	if err := db.StockDB().WithContext(ctx).Table("conditional_order").Where("id = ?", id).
+		Updates(map[string]interface{}{"status": status, "remark": remark, "update_time": time.Now()}).Error; err != nil {
+		log.Errorf("更新条件单结果失败:%+v", err)
+		return err
+	}
+	return nil
+}
@@ -63,8 +63,7 @@ func (s *ContractDao) CreateContract(ctx context.Context, contract *model.Contra
 
 // SetContractStatus 设置合约状态
 func (s *ContractDao) SetContractStatus(ctx context.Context, contractID, status int64) error {
-	sql := "update contract set status = ? where id = ?"
-	err := db.StockDB().WithContext(ctx).Exec(sql, status, contractID).Error
+	err := db.StockDB().WithContext(ctx).Table(contractTable).Where("id = ?", contractID).Update("status", status).Error
 	if err != nil {
 		log.Errorf("更新资金表失败:id[%v] status[%v]", contractID, status)
 		return serr.New(serr.ErrCodeBusinessFail, "设置合约状态失败")
@@ -75,8 +74,8 @@ func (s *ContractDao) SetContractStatus(ctx context.Context, contractID, status
 // GetContractByID 根据合约id查询合约
 func (s *ContractDao) GetContractByID(ctx context.Context, contractID int64) (*model.Contract, error) {
 	var contract *model.Contract
-	sql := "select * from contract where id = ? "
-	err := db.StockDB().WithContext(ctx).Raw(sql, contractID).Take(&contract).Error
+	// 使用查询构造,代理商只能查询名下用户的合约
+	err := db.StockDB().WithContext(ctx).Table(contractTable).Where("id = ?", contractID).Take(&contract).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, serr.New(serr.ErrCodeBusinessFail, "合约不存在")
@@ -87,11 +86,22 @@ func (s *ContractDao) GetContractByID(ctx context.Context, contractID int64) (*m
 	return contract, nil
 }
 
+// GetContractByIDForUpdateWithTx 事务内根据合约id查询合约并加行锁,直到事务结束
+func (s *ContractDao) GetContractByIDForUpdateWithTx(tx *gorm.DB, contractID int64) (*model.Contract, error) {
+	var contract *model.Contract
+	if err := tx.Table(contractTable).Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", contractID).Take(&contract).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.New("合约不存在")
+		}
+		return nil, err
+	}
+	return contract, nil
+}
+
 // GetContractByIDWithTx 根据合约id查询合约
 func (s *ContractDao) GetContractByIDWithTx(tx *gorm.DB, contractID int64) (*model.Contract, error) {
 	var contract *model.Contract
-	sql := "select * from contract where id = ? "
-	if err := tx.Raw(sql, contractID).Take(&contract).Error; err != nil {
+	if err := tx.Table(contractTable).Where("id = ?", contractID).Take(&contract).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, errors.New("合约不存在")
 		}
@@ -103,8 +113,7 @@ func (s *ContractDao) GetContractByIDWithTx(tx *gorm.DB, contractID int64) (*mod
 // GetEnableContractByUID 查找uid一个有效的contract
 func (s *ContractDao) GetEnableContractByUID(ctx context.Context, uid int64) (*model.Contract, error) {
 	var list []*model.Contract
-	sql := "select * from contract where uid = ? and status = ? "
-	err := db.StockDB().WithContext(ctx).Raw(sql, uid, model.ContractStatusEnable).Find(&list).Error
+	err := db.StockDB().WithContext(ctx).Table(contractTable).Where("uid = ? and status = ?", uid, model.ContractStatusEnable).Find(&list).Error
 	if err != nil {
 		log.Errorf("查询生效合约错误:%+v", err)
 		return nil, err
@@ -119,8 +128,7 @@ func (s *ContractDao) GetEnableContractByUID(ctx context.Context, uid int64) (*m
 
 // UpdateContractValMoney 更新合约可用资金
 func (s *ContractDao) UpdateContractValMoney(ctx context.Context, valMoney float64, contractID int64) error {
-	sql := "update contract set val_money = ? where id = ?"
-	if err := db.StockDB().WithContext(ctx).Exec(sql, valMoney, contractID).Error; err != nil {
+	if err := db.StockDB().WithContext(ctx).Table(contractTable).Where("id = ?", contractID).Update("val_money", valMoney).Error; err != nil {
 		log.Errorf("刷新可用资金失败:%+v", err)
 		return err
 	}
@@ -24,8 +24,7 @@ func ContractFeeDaoInstance() *ContractFeeDao {
 // GetContractFeeByID 查询合约费用by合约id
 func (s *ContractFeeDao) GetContractFeeByID(ctx context.Context, contractID int64) ([]*model.ContractFee, error) {
 	var list []*model.ContractFee
-	sql := "select * from contract_fee where contract_id = ?"
-	err := db.StockDB().WithContext(ctx).Raw(sql, contractID).Find(&list).Error
+	err := db.StockDB().WithContext(ctx).Table("contract_fee").Where("contract_id = ?", contractID).Find(&list).Error
 	if err != nil {
 		return nil, serr.New(serr.ErrCodeContractNoFound, "系统错误:查询失败")
 	}
@@ -22,8 +22,7 @@ func ContractRecordDaoInstance() *ContractRecordDao {
 // GetContractByID 根据合约id查询合约
 func (s *ContractRecordDao) GetContractByID(ctx context.Context, contractID int64) (*model.Contract, error) {
 	var contract *model.Contract
-	sql := "select * from contract_record where id = ? "
-	err := db.StockDB().WithContext(ctx).Raw(sql, contractID).Take(&contract).Error
+	err := db.StockDB().WithContext(ctx).Table("contract_record").Where("id = ?", contractID).Take(&contract).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, serr.New(serr.ErrCodeBusinessFail, "合约不存在")
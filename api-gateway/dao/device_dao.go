@@ -0,0 +1,45 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"stock/api-gateway/db"
+	"stock/api-gateway/model"
+	"stock/api-gateway/serr"
+	"stock/common/log"
+
+	"gorm.io/gorm"
+)
+
+// DeviceDao 设备
+type DeviceDao struct{}
+
+var _deviceDao = &DeviceDao{}
+
+// DeviceDaoInstance 提供一个可用的对象
+func DeviceDaoInstance() *DeviceDao {
+	return _deviceDao
+}
+
+// Create 注册设备
+func (s *DeviceDao) Create(ctx context.Context, device *model.Device) error {
+	if err := db.StockDB().WithContext(ctx).Table("device").Create(device).Error; err != nil {
+		log.Errorf("注册设备失败:%+v", err)
+		return err
+	}
+	return nil
+}
+
+// GetByDeviceID 根据设备ID查询设备
+func (s *DeviceDao) GetByDeviceID(ctx context.Context, deviceID string) (*model.Device, error) {
+	var device *model.Device
+	err := db.StockDB().WithContext(ctx).Table("device").Where("device_id = ?", deviceID).Take(&device).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, serr.New(serr.ErrCodeDataNoFound, "设备不存在")
+		}
+		log.Errorf("查询设备失败:device[%s],err:%+v", deviceID, err)
+		return nil, err
+	}
+	return device, nil
+}
@@ -21,8 +21,7 @@ func DividendDaoInstance() *DividendDao {
 // GetDividendByPositionIDs 根据持仓id查询分红表
 func (s *DividendDao) GetDividendByPositionIDs(ctx context.Context, positionID int64) ([]*model.Dividend, error) {
 	var list []*model.Dividend
-	sql := "select * from dividend where position_id = ? "
-	err := db.StockDB().WithContext(ctx).Raw(sql, positionID).Find(&list).Error
+	err := db.StockDB().WithContext(ctx).Table("dividend").Where("position_id = ?", positionID).Find(&list).Error
 	if err != nil {
 		return nil, serr.New(serr.ErrCodeBusinessFail, "系统错误:查询分红派息订单错误")
 	}
@@ -25,8 +25,7 @@ func EntrustDaoInstance() *EntrustDao {
 // GetTodayEntrust 查询今日委托
 func (s *EntrustDao) GetTodayEntrust(ctx context.Context, contractID int64) ([]*model.Entrust, error) {
 	var list []*model.Entrust
-	sql := "select * from entrust where contract_id = ? and date(order_time) = CURRENT_DATE"
-	err := db.StockDB().WithContext(ctx).Raw(sql, contractID).Find(&list).Error
+	err := db.StockDB().WithContext(ctx).Table("entrust").Where("contract_id = ? and date(order_time) = CURRENT_DATE", contractID).Find(&list).Error
 	if err != nil {
 		return nil, serr.New(serr.ErrCodeContractNoFound, "系统错误:查询持仓失败")
 	}
@@ -36,8 +35,7 @@ func (s *EntrustDao) GetTodayEntrust(ctx context.Context, contractID int64) ([]*
 // GetAllEntrusts 查询所有的委托记录
 func (s *EntrustDao) GetAllEntrusts(ctx context.Context, contractID int64) ([]*model.Entrust, error) {
 	var list []*model.Entrust
-	sql := "select * from entrust where contract_id = ? "
-	err := db.StockDB().WithContext(ctx).Raw(sql, contractID).Find(&list).Error
+	err := db.StockDB().WithContext(ctx).Table("entrust").Where("contract_id = ?", contractID).Find(&list).Error
 	if err != nil {
 		return nil, serr.New(serr.ErrCodeContractNoFound, "系统错误:查询持仓失败")
 	}
@@ -47,8 +45,9 @@ func (s *EntrustDao) GetAllEntrusts(ctx context.Context, contractID int64) ([]*m
 // GetEntrustByTimeRange 查询所有的委托记录
 func (s *EntrustDao) GetEntrustByTimeRange(ctx context.Context, contractID int64, beginDate, endDate string) ([]*model.Entrust, error) {
 	var list []*model.Entrust
-	sql := "select * from entrust where contract_id = ? and date(order_time) >= ? and date(order_time) <= ? "
-	err := db.StockDB().WithContext(ctx).Raw(sql, contractID, beginDate, endDate).Find(&list).Error
+	err := db.StockDB().WithContext(ctx).Table("entrust").
+		Where("contract_id = ? and date(order_time) >= ? and date(order_time) <= ?", contractID, beginDate, endDate).
+		Find(&list).Error
 	if err != nil {
 		return nil, serr.New(serr.ErrCodeContractNoFound, "系统错误:查询持仓失败")
 	}
@@ -58,8 +57,7 @@ func (s *EntrustDao) GetEntrustByTimeRange(ctx context.Context, contractID int64
 // GetEntrustByContractID 根据contractID查询委托记录
 func (s *EntrustDao) GetEntrustByContractID(ctx context.Context, contractID int64) ([]*model.Entrust, error) {
 	var list []*model.Entrust
-	sql := "select * from entrust where contract_id = ?"
-	err := db.StockDB().WithContext(ctx).Raw(sql, contractID).Find(&list).Error
+	err := db.StockDB().WithContext(ctx).Table("entrust").Where("contract_id = ?", contractID).Find(&list).Error
 	if err != nil {
 		return nil, serr.New(serr.ErrCodeContractNoFound, "系统错误:查询持仓失败")
 	}
@@ -69,8 +67,7 @@ func (s *EntrustDao) GetEntrustByContractID(ctx context.Context, contractID int6
 // GetEntrustByID 根据ID查询委托记录
 func (s *EntrustDao) GetEntrustByID(ctx context.Context, entrustID int64) (*model.Entrust, error) {
 	var entrust *model.Entrust
-	sql := "select * from entrust where id = ?"
-	err := db.StockDB().WithContext(ctx).Raw(sql, entrustID).Take(&entrust).Error
+	err := db.StockDB().WithContext(ctx).Table("entrust").Where("id = ?", entrustID).Take(&entrust).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, serr.New(serr.ErrCodeBusinessFail, "委托记录不存在")
@@ -143,8 +140,7 @@ func (s *EntrustDao) Create(ctx context.Context, entrust *model.Entrust) (*model
 // GetTodayEntrusts 查询当日的委托
 func (s *EntrustDao) GetTodayEntrusts(ctx context.Context) ([]*model.Entrust, error) {
 	var list []*model.Entrust
-	sql := "select * from entrust where date(order_time) = CURRENT_DATE"
-	err := db.StockDB().WithContext(ctx).Raw(sql).Find(&list).Error
+	err := db.StockDB().WithContext(ctx).Table("entrust").Where("date(order_time) = CURRENT_DATE").Find(&list).Error
 	if err != nil {
 		return nil, serr.New(serr.ErrCodeContractNoFound, "系统错误:查询委托表失败")
 	}
@@ -162,8 +158,7 @@ func (s *EntrustDao) GetEntrustsByIDs(ctx context.Context, entrustIDs []int64) (
 
 // UpdateStatusWithTx 事务:更新状态
 func (s *EntrustDao) UpdateStatusWithTx(tx *gorm.DB, entrust *model.Entrust) error {
-	sql := "update entrust set status = ? where id = ? and status != ?"
-	if err := tx.Exec(sql, entrust.Status, entrust.ID, entrust.Status).Error; err != nil {
+	if err := tx.Table("entrust").Where("id = ? and status != ?", entrust.ID, entrust.Status).Update("status", entrust.Status).Error; err != nil {
 		return err
 	}
 	return nil
@@ -172,8 +167,9 @@ func (s *EntrustDao) UpdateStatusWithTx(tx *gorm.DB, entrust *model.Entrust) err
 // GetFirstBuyEntrustByPositionID 根据entrust的持仓ID查询第一次买入的委托
 func (s *EntrustDao) GetFirstBuyEntrustByPositionID(ctx context.Context, entrust *model.Entrust) (*model.Entrust, error) {
 	var res *model.Entrust
-	sql := "select * from entrust where position_id = ? and entrust_bs = ? and status = 2"
-	if err := db.StockDB().WithContext(ctx).Raw(sql, entrust.PositionID, model.EntrustBsTypeBuy).Take(&res).Error; err != nil {
+	if err := db.StockDB().WithContext(ctx).Table("entrust").
+		Where("position_id = ? and entrust_bs = ? and status = 2", entrust.PositionID, model.EntrustBsTypeBuy).
+		Take(&res).Error; err != nil {
 		log.Errorf("查询失败:%+v,position_id:%+v entrust_bs:%+v", err, entrust.PositionID, model.EntrustBsTypeBuy)
 		return nil, err
 	}
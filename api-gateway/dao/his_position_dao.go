@@ -21,8 +21,8 @@ func HisPositionDaoInstance() *HisPositionDao {
 // GetYesterdayPositionByContractID 查询昨日持仓
 func (s *HisPositionDao) GetYesterdayPositionByContractID(ctx context.Context, contractID int64) ([]*model.Position, error) {
 	var list []*model.Position
-	sql := "select * from his_position where contract_id = ? and date(order_time)=DATE_SUB(CURDATE(), INTERVAL 1 DAY)"
-	err := db.StockDB().WithContext(ctx).Raw(sql, contractID).Find(&list).Error
+	err := db.StockDB().WithContext(ctx).Table("his_position").
+		Where("contract_id = ? and date(order_time) = DATE_SUB(CURDATE(), INTERVAL 1 DAY)", contractID).Find(&list).Error
 	if err != nil {
 		return nil, serr.New(serr.ErrCodeContractNoFound, "系统错误:查询历史持仓失败")
 	}
@@ -32,8 +32,8 @@ func (s *HisPositionDao) GetYesterdayPositionByContractID(ctx context.Context, c
 // GetYesterdayPositions 查询昨日持仓
 func (s *HisPositionDao) GetYesterdayPositions(ctx context.Context) ([]*model.Position, error) {
 	var list []*model.Position
-	sql := "select * from his_position where date(order_time)=DATE_SUB(CURDATE(), INTERVAL 1 DAY)"
-	if err := db.StockDB().WithContext(ctx).Raw(sql).Find(&list).Error; err != nil {
+	if err := db.StockDB().WithContext(ctx).Table("his_position").
+		Where("date(order_time) = DATE_SUB(CURDATE(), INTERVAL 1 DAY)").Find(&list).Error; err != nil {
 		return nil, err
 	}
 	return list, nil
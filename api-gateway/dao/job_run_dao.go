@@ -0,0 +1,71 @@
+package dao
+
+import (
+	"context"
+	"stock/api-gateway/db"
+	"stock/api-gateway/model"
+	"stock/common/log"
+
+	"gorm.io/gorm"
+)
+
+// JobRunDao 定时任务执行记录
+type JobRunDao struct{}
+
+var _jobRunDao = &JobRunDao{}
+
+// JobRunDaoInstance 提供一个可用的对象
+func JobRunDaoInstance() *JobRunDao {
+	return _jobRunDao
+}
+
+// Create 创建执行记录
+func (s *JobRunDao) Create(ctx context.Context, run *model.JobRun) error {
+	if err := db.StockDB().WithContext(ctx).Table("job_run").Create(run).Error; err != nil {
+		log.Errorf("创建任务执行记录失败:%+v", err)
+		return err
+	}
+	return nil
+}
+
+// Update 更新执行结果
+func (s *JobRunDao) Update(ctx context.Context, run *model.JobRun) error {
+	updateMap := make(map[string]interface{})
+	updateMap["status"] = run.Status
+	updateMap["attempts"] = run.Attempts
+	updateMap["error"] = run.Error
+	updateMap["end_time"] = run.EndTime
+	if err := db.StockDB().WithContext(ctx).Table("job_run").Where("id = ?", run.ID).Updates(updateMap).Error; err != nil {
+		log.Errorf("更新任务执行记录失败:%+v", err)
+		return err
+	}
+	return nil
+}
+
+// IsSuccess 任务在业务日期是否已执行成功
+func (s *JobRunDao) IsSuccess(ctx context.Context, name string, runDate int32) (bool, error) {
+	var run *model.JobRun
+	err := db.StockDB().WithContext(ctx).Table("job_run").
+		Where("name = ? and run_date = ? and status = ?", name, runDate, model.JobRunStatusSuccess).Take(&run).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// GetLatest 查询各任务最近一次执行记录
+func (s *JobRunDao) GetLatest(ctx context.Context) (map[string]*model.JobRun, error) {
+	var list []*model.JobRun
+	sql := "select * from job_run where id in (select max(id) from job_run group by name)"
+	if err := db.StockDB().WithContext(ctx).Raw(sql).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[string]*model.JobRun)
+	for _, it := range list {
+		result[it.Name] = it
+	}
+	return result, nil
+}
@@ -0,0 +1,79 @@
+package dao
+
+import (
+	"context"
+	"stock/api-gateway/db"
+	"stock/api-gateway/model"
+	"stock/common/log"
+
+	"gorm.io/gorm"
+)
+
+// LedgerDao 记账分录,只提供写入和查询,不提供修改和删除
+type LedgerDao struct{}
+
+var _ledgerDao = &LedgerDao{}
+
+// LedgerDaoInstance 提供一个可用的对象
+func LedgerDaoInstance() *LedgerDao {
+	return _ledgerDao
+}
+
+const ledgerTable = "ledger_entry"
+
+// CreateWithTx 写入一张凭证的全部分录
+func (s *LedgerDao) CreateWithTx(tx *gorm.DB, entries []*model.LedgerEntry) error {
+	if err := tx.Table(ledgerTable).Create(&entries).Error; err != nil {
+		log.Errorf("写入记账分录失败:%+v", err)
+		return err
+	}
+	return nil
+}
+
+// GetBalances 按账户所属ID汇总某类账户的余额,无分录的账户不返回
+func (s *LedgerDao) GetBalances(ctx context.Context, accountType int64) (map[int64]float64, error) {
+	type balance struct {
+		OwnerID int64   `gorm:"column:owner_id"`
+		Amount  float64 `gorm:"column:amount"`
+	}
+	var list []*balance
+	if err := db.StockDB().WithContext(ctx).Table(ledgerTable).Select("owner_id, sum(amount) as amount").
+		Where("account_type = ?", accountType).Group("owner_id").Find(&list).Error; err != nil {
+		log.Errorf("GetBalances err:%+v", err)
+		return nil, err
+	}
+	result := make(map[int64]float64)
+	for _, it := range list {
+		result[it.OwnerID] = it.Amount
+	}
+	return result, nil
+}
+
+// Search 按条件分页查询记账分录,按ID倒序
+func (s *LedgerDao) Search(ctx context.Context, query *model.LedgerEntryQuery) ([]*model.LedgerEntry, int64, error) {
+	tx := db.StockDB().WithContext(ctx).Table(ledgerTable)
+	if query.AccountType > 0 {
+		tx.Where("account_type = ?", query.AccountType)
+	}
+	if query.OwnerID > 0 {
+		tx.Where("owner_id = ?", query.OwnerID)
+	}
+	if query.BizType > 0 {
+		tx.Where("biz_type = ?", query.BizType)
+	}
+	if query.JournalNo != "" {
+		tx.Where("journal_no = ?", query.JournalNo)
+	}
+
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		log.Errorf("Search count err:%+v", err)
+		return nil, 0, err
+	}
+	var list []*model.LedgerEntry
+	if err := tx.Order("id desc").Offset(query.Offset).Limit(query.Limit).Find(&list).Error; err != nil {
+		log.Errorf("Search err:%+v", err)
+		return nil, 0, err
+	}
+	return list, total, nil
+}
@@ -0,0 +1,56 @@
+package dao
+
+import (
+	"context"
+	"stock/api-gateway/db"
+	"stock/api-gateway/model"
+	"stock/common/log"
+	"time"
+)
+
+// PaymentChannelDao 充值渠道配置
+type PaymentChannelDao struct{}
+
+var _paymentChannelDao = &PaymentChannelDao{}
+
+// PaymentChannelDaoInstance 提供一个可用的对象
+func PaymentChannelDaoInstance() *PaymentChannelDao {
+	return _paymentChannelDao
+}
+
+func paymentChannelCacheKey() string {
+	return "payment_channel_conf"
+}
+
+// GetConfigs 充值渠道配置,按渠道编码索引
+func (s *PaymentChannelDao) GetConfigs(ctx context.Context) (map[string]*model.PaymentChannelConfig, error) {
+	var list []*model.PaymentChannelConfig
+	err := db.GetOrLoad(ctx, paymentChannelCacheKey(), 24*time.Hour, &list, func() error {
+		if err := db.StockDB().WithContext(ctx).Table("payment_channel").Find(&list).Error; err != nil {
+			log.Errorf("查询充值渠道配置失败:%+v", err)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	configs := make(map[string]*model.PaymentChannelConfig)
+	for _, it := range list {
+		configs[it.Code] = it
+	}
+	return configs, nil
+}
+
+// Save 保存充值渠道配置
+func (s *PaymentChannelDao) Save(ctx context.Context, config *model.PaymentChannelConfig) error {
+	if err := db.StockDB().WithContext(ctx).Table("payment_channel").Save(config).Error; err != nil {
+		log.Errorf("保存充值渠道配置失败:%+v", err)
+		return err
+	}
+	if err := db.RedisClient().Del(ctx, paymentChannelCacheKey()).Err(); err != nil {
+		log.Errorf("删除缓存失败")
+		return err
+	}
+	return nil
+}
@@ -22,8 +22,7 @@ func PortfolioDaoInstance() *PortfolioDao {
 // GetPortfolioList 根据uid查询自选股
 func (s *PortfolioDao) GetPortfolioList(ctx context.Context, uid int64) ([]*model.Portfolio, error) {
 	var list []*model.Portfolio
-	sql := "select * from portfolio where uid = ? "
-	err := db.StockDB().WithContext(ctx).Raw(sql, uid).Find(&list).Error
+	err := db.StockDB().WithContext(ctx).Table("portfolio").Where("uid = ?", uid).Find(&list).Error
 	if err != nil {
 		return nil, serr.New(serr.ErrCodeBusinessFail, "系统错误:查询自选股失败")
 	}
@@ -32,8 +31,7 @@ func (s *PortfolioDao) GetPortfolioList(ctx context.Context, uid int64) ([]*mode
 
 // DeletePortfolio 删除自选股
 func (s *PortfolioDao) DeletePortfolio(ctx context.Context, uid int64, code string) error {
-	sql := "delete from portfolio where uid = ? and code = ?"
-	if err := db.StockDB().WithContext(ctx).Exec(sql, uid, code).Error; err != nil {
+	if err := db.StockDB().WithContext(ctx).Table("portfolio").Where("uid = ? and code = ?", uid, code).Delete(&model.Portfolio{}).Error; err != nil {
 		return err
 	}
 	return nil
@@ -25,8 +25,7 @@ func PositionDaoInstance() *PositionDao {
 // GetPositionByContractID 根据合约查询持仓
 func (s *PositionDao) GetPositionByContractID(ctx context.Context, contractID int64) ([]*model.Position, error) {
 	var list []*model.Position
-	sql := "select * from position where contract_id = ? "
-	err := db.StockDB().WithContext(ctx).Raw(sql, contractID).Find(&list).Error
+	err := db.StockDB().WithContext(ctx).Table("position").Where("contract_id = ?", contractID).Find(&list).Error
 	if err != nil {
 		return nil, serr.New(serr.ErrCodeBusinessFail, "系统错误:查询持仓失败")
 	}
@@ -36,8 +35,7 @@ func (s *PositionDao) GetPositionByContractID(ctx context.Context, contractID in
 // GetPositionByEntrustID 通过持仓编号查询持仓记录
 func (s *PositionDao) GetPositionByEntrustID(ctx context.Context, entrustID int64) (*model.Position, error) {
 	var result *model.Position
-	sql := "select * from position where entrust_id = ? "
-	err := db.StockDB().WithContext(ctx).Raw(sql, entrustID).Take(&result).Error
+	err := db.StockDB().WithContext(ctx).Table("position").Where("entrust_id = ?", entrustID).Take(&result).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, err
@@ -50,8 +48,7 @@ func (s *PositionDao) GetPositionByEntrustID(ctx context.Context, entrustID int6
 // GetPositions 查询所有持仓数据
 func (s *PositionDao) GetPositions(ctx context.Context) ([]*model.Position, error) {
 	var list []*model.Position
-	sql := "select * from position"
-	if err := db.StockDB().WithContext(ctx).Raw(sql).Find(&list).Error; err != nil {
+	if err := db.StockDB().WithContext(ctx).Table("position").Find(&list).Error; err != nil {
 		log.Errorf("查询持仓数据失败:%+v", err)
 		return nil, err
 	}
@@ -103,8 +100,7 @@ func (s *PositionDao) Update(ctx context.Context, position *model.Position) erro
 
 // DeleteWithTx 删除持仓记录
 func (s *PositionDao) DeleteWithTx(tx *gorm.DB, position *model.Position) error {
-	sql := "delete from position where id = ?"
-	if err := tx.Exec(sql, position.ID).Error; err != nil {
+	if err := tx.Table("position").Where("id = ?", position.ID).Delete(&model.Position{}).Error; err != nil {
 		log.Errorf("删除持仓记录错误:%+v", err)
 		return err
 	}
@@ -113,8 +109,8 @@ func (s *PositionDao) DeleteWithTx(tx *gorm.DB, position *model.Position) error
 
 // UnFreezeAmount 解冻股票
 func (s *PositionDao) UnFreezeAmount(ctx context.Context, contractID int64, code string, amount int64) error {
-	sql := "update position set freeze_amount = freeze_amount - ? where contract_id = ? and stock_code = ?"
-	if err := db.StockDB().WithContext(ctx).Exec(sql, amount, contractID, code).Error; err != nil {
+	if err := db.StockDB().WithContext(ctx).Table("position").Where("contract_id = ? and stock_code = ?", contractID, code).
+		Update("freeze_amount", gorm.Expr("freeze_amount - ?", amount)).Error; err != nil {
 		log.Errorf("解冻股票数量失败:%+v", err)
 		return err
 	}
@@ -123,8 +119,8 @@ func (s *PositionDao) UnFreezeAmount(ctx context.Context, contractID int64, code
 
 // UnFreezeAmountWithTx 事务:解冻股票
 func (s *PositionDao) UnFreezeAmountWithTx(tx *gorm.DB, contractID int64, code string, amount int64) error {
-	sql := "update position set freeze_amount = freeze_amount - ? where contract_id = ? and stock_code = ?"
-	if err := tx.Exec(sql, amount, contractID, code).Error; err != nil {
+	if err := tx.Table("position").Where("contract_id = ? and stock_code = ?", contractID, code).
+		Update("freeze_amount", gorm.Expr("freeze_amount - ?", amount)).Error; err != nil {
 		log.Errorf("解冻股票数量失败:%+v", err)
 		return err
 	}
@@ -134,8 +130,7 @@ func (s *PositionDao) UnFreezeAmountWithTx(tx *gorm.DB, contractID int64, code s
 // GetContractPositionByCode 根据ContractID和Code查询持仓
 func (s *PositionDao) GetContractPositionByCode(ctx context.Context, contractID int64, code string) (*model.Position, error) {
 	var position *model.Position
-	sql := "select * from position where contract_id = ? and stock_code = ?"
-	if err := db.StockDB().WithContext(ctx).Raw(sql, contractID, code).Take(&position).Error; err != nil {
+	if err := db.StockDB().WithContext(ctx).Table("position").Where("contract_id = ? and stock_code = ?", contractID, code).Take(&position).Error; err != nil {
 		return nil, err
 	}
 	return position, nil
@@ -144,8 +139,7 @@ func (s *PositionDao) GetContractPositionByCode(ctx context.Context, contractID
 // GetContractPositionByCodeWithTx 根据ContractID和Code查询持仓
 func (s *PositionDao) GetContractPositionByCodeWithTx(tx *gorm.DB, contractID int64, code string) (*model.Position, error) {
 	var position *model.Position
-	sql := "select * from position where contract_id = ? and stock_code = ?"
-	if err := tx.Raw(sql, contractID, code).Take(&position).Error; err != nil {
+	if err := tx.Table("position").Where("contract_id = ? and stock_code = ?", contractID, code).Take(&position).Error; err != nil {
 		return nil, err
 	}
 	return position, nil
@@ -153,8 +147,8 @@ func (s *PositionDao) GetContractPositionByCodeWithTx(tx *gorm.DB, contractID in
 
 // FreezeAmountWithTx 冻结股票
 func (s *PositionDao) FreezeAmountWithTx(tx *gorm.DB, contractID int64, code string, amount int64) error {
-	sql := "update position set freeze_amount = freeze_amount + ? where contract_id = ? and stock_code = ?"
-	if err := tx.Exec(sql, amount, contractID, code).Error; err != nil {
+	if err := tx.Table("position").Where("contract_id = ? and stock_code = ?", contractID, code).
+		Update("freeze_amount", gorm.Expr("freeze_amount + ?", amount)).Error; err != nil {
 		log.Errorf("冻结股票:%+v", err)
 		return err
 	}
@@ -0,0 +1,41 @@
+package dao
+
+import (
+	"context"
+	"stock/api-gateway/db"
+	"stock/api-gateway/model"
+	"stock/common/log"
+)
+
+// ReconcileReportDao 充值渠道对账报告
+type ReconcileReportDao struct{}
+
+var _reconcileReportDao = &ReconcileReportDao{}
+
+// ReconcileReportDaoInstance 提供一个可用的对象
+func ReconcileReportDaoInstance() *ReconcileReportDao {
+	return _reconcileReportDao
+}
+
+// Create 保存对账报告
+func (s *ReconcileReportDao) Create(ctx context.Context, report *model.ReconcileReport) error {
+	if err := db.StockDB().WithContext(ctx).Table("reconcile_report").Create(report).Error; err != nil {
+		log.Errorf("保存对账报告失败:%+v", err)
+		return err
+	}
+	return nil
+}
+
+// List 对账报告列表,按对账日期倒序
+func (s *ReconcileReportDao) List(ctx context.Context, channel string, limit int) ([]*model.ReconcileReport, error) {
+	var reports []*model.ReconcileReport
+	query := db.StockDB().WithContext(ctx).Table("reconcile_report")
+	if channel != "" {
+		query = query.Where("channel = ?", channel)
+	}
+	if err := query.Order("bill_date desc, id desc").Limit(limit).Find(&reports).Error; err != nil {
+		log.Errorf("查询对账报告失败:%+v", err)
+		return nil, err
+	}
+	return reports, nil
+}
@@ -0,0 +1,93 @@
+package dao
+
+import (
+	"context"
+	"stock/api-gateway/db"
+	"stock/api-gateway/model"
+	"stock/common/log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ReverseRepoDao 国债逆回购
+type ReverseRepoDao struct{}
+
+var _reverseRepoDao = &ReverseRepoDao{}
+
+// ReverseRepoDaoInstance 提供一个可用的对象
+func ReverseRepoDaoInstance() *ReverseRepoDao {
+	return _reverseRepoDao
+}
+
+// CreateWithTx 创建逆回购委托
+func (s *ReverseRepoDao) CreateWithTx(tx *gorm.DB, repo *model.ReverseRepo) error {
+	repo.CreateTime = time.Now()
+	repo.UpdateTime = repo.CreateTime
+	if err := tx.Table("reverse_repo").Create(repo).Error; err != nil {
+		log.Errorf("创建逆回购委托失败:%+v", err)
+		return err
+	}
+	return nil
+}
+
+// GetByID 根据ID查询逆回购
+func (s *ReverseRepoDao) GetByID(ctx context.Context, id int64) (*model.ReverseRepo, error) {
+	var repo *model.ReverseRepo
+	if err := db.StockDB().WithContext(ctx).Table("reverse_repo").Where("id = ?", id).Take(&repo).Error; err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+// GetByUID 查询用户逆回购
+func (s *ReverseRepoDao) GetByUID(ctx context.Context, uid int64) ([]*model.ReverseRepo, error) {
+	var list []*model.ReverseRepo
+	if err := db.StockDB().WithContext(ctx).Table("reverse_repo").Where("uid = ?", uid).Order("id desc").Find(&list).Error; err != nil {
+		log.Errorf("查询逆回购失败:%+v", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// GetByStatus 根据状态查询逆回购
+func (s *ReverseRepoDao) GetByStatus(ctx context.Context, status int64) ([]*model.ReverseRepo, error) {
+	var list []*model.ReverseRepo
+	if err := db.StockDB().WithContext(ctx).Table("reverse_repo").Where("status = ?", status).Order("id").Find(&list).Error; err != nil {
+		log.Errorf("查询逆回购失败:%+v", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// GetActiveByContractID 查询合约未成交、计息中的逆回购
+func (s *ReverseRepoDao) GetActiveByContractID(ctx context.Context, contractID int64) ([]*model.ReverseRepo, error) {
+	var list []*model.ReverseRepo
+	if err := db.StockDB().WithContext(ctx).Table("reverse_repo").Where("contract_id = ? and source = ? and status in (?)",
+		contractID, model.ReverseRepoSourceContract, []int64{model.ReverseRepoStatusUnDeal, model.ReverseRepoStatusDeal}).Find(&list).Error; err != nil {
+		log.Errorf("查询合约逆回购失败:%+v", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// UpdateWithTx 更新逆回购,仅当状态为from时更新成功,返回是否更新成功
+func (s *ReverseRepoDao) UpdateWithTx(tx *gorm.DB, repo *model.ReverseRepo, from int64) (bool, error) {
+	repo.UpdateTime = time.Now()
+	ret := tx.Table("reverse_repo").Where("id = ? and status = ?", repo.ID, from).Select("*").Omit("id", "create_time").Updates(repo)
+	if ret.Error != nil {
+		log.Errorf("更新逆回购失败:%+v", ret.Error)
+		return false, ret.Error
+	}
+	return ret.RowsAffected == 1, nil
+}
+
+// UpdateAccrued 更新已计提利息
+func (s *ReverseRepoDao) UpdateAccrued(ctx context.Context, id int64, accrued float64) error {
+	if err := db.StockDB().WithContext(ctx).Table("reverse_repo").Where("id = ? and status = ?", id, model.ReverseRepoStatusDeal).
+		Updates(map[string]interface{}{"accrued_interest": accrued, "update_time": time.Now()}).Error; err != nil {
+		log.Errorf("更新逆回购计提利息失败:%+v", err)
+		return err
+	}
+	return nil
+}
@@ -67,3 +67,12 @@ func (s *RoleDao) GetRoleByID(ctx context.Context, id int64) (*model.Role, error
 	}
 	return role, nil
 }
+
+// UpdatePassword 更新角色密码
+func (s *RoleDao) UpdatePassword(ctx context.Context, id int64, password string) error {
+	if err := db.StockDB().WithContext(ctx).Table("role").Where("id = ?", id).Update("password", password).Error; err != nil {
+		log.Errorf("更新角色密码失败:%+v", err)
+		return err
+	}
+	return nil
+}
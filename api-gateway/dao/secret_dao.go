@@ -0,0 +1,87 @@
+package dao
+
+import (
+	"context"
+	"stock/api-gateway/db"
+	"stock/api-gateway/util"
+	"stock/common/log"
+	"strings"
+)
+
+// SecretDao 加密字段迁移
+type SecretDao struct{}
+
+var _secretDao = &SecretDao{}
+
+// SecretDaoInstance 提供一个可用的对象
+func SecretDaoInstance() *SecretDao {
+	return _secretDao
+}
+
+// Rotate 按主键分批读取原始字段,未加密或非当前版本加密的使用当前密钥重新加密,返回更新的行数;可重复执行
+func (s *SecretDao) Rotate(ctx context.Context, cipher *util.FieldCipher, table string, columns []string, batch int) (int64, error) {
+	var lastID, count int64
+	for {
+		var rows []map[string]interface{}
+		err := db.StockDB().WithContext(ctx).Table(table).Select("id, "+strings.Join(columns, ", ")).
+			Where("id > ?", lastID).Order("id").Limit(batch).Find(&rows).Error
+		if err != nil {
+			log.Errorf("查询%s失败:%+v", table, err)
+			return count, err
+		}
+		for _, row := range rows {
+			id := rawInt64(row["id"])
+			lastID = id
+			updates := make(map[string]interface{})
+			for _, column := range columns {
+				raw := rawString(row[column])
+				if !cipher.NeedRotate(raw) {
+					continue
+				}
+				txt, err := cipher.Decrypt(raw)
+				if err != nil {
+					log.Errorf("解密%s.%s失败:id[%d],err:%+v", table, column, id, err)
+					return count, err
+				}
+				if updates[column], err = cipher.Encrypt(txt); err != nil {
+					return count, err
+				}
+			}
+			if len(updates) == 0 {
+				continue
+			}
+			if err := db.StockDB().WithContext(ctx).Table(table).Where("id = ?", id).Updates(updates).Error; err != nil {
+				log.Errorf("更新%s失败:id[%d],err:%+v", table, id, err)
+				return count, err
+			}
+			count++
+		}
+		if len(rows) < batch {
+			return count, nil
+		}
+	}
+}
+
+func rawInt64(v interface{}) int64 {
+	switch t := v.(type) {
+	case int64:
+		return t
+	case int32:
+		return int64(t)
+	case uint64:
+		return int64(t)
+	case []byte:
+		return util.String2Int64(string(t))
+	}
+	return 0
+}
+
+func rawString(v interface{}) string {
+	switch t := v.(type) {
+	case []byte:
+		return string(t)
+	case string:
+		return t
+	}
+	return ""
+}
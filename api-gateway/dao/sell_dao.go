@@ -25,8 +25,7 @@ func SellDaoInstance() *SellDao {
 // GetByPositionIDs 根据持仓id查询卖出记录
 func (s *SellDao) GetByPositionIDs(ctx context.Context, positionID []int64) ([]*model.Sell, error) {
 	var list []*model.Sell
-	sql := "select * from sell where position_id in ? "
-	err := db.StockDB().WithContext(ctx).Raw(sql, positionID).Find(&list).Error
+	err := db.StockDB().WithContext(ctx).Table("sell").Where("position_id in (?)", positionID).Find(&list).Error
 	if err != nil {
 		return nil, serr.New(serr.ErrCodeBusinessFail, "系统错误:查询卖出订单错误")
 	}
@@ -57,8 +56,7 @@ func (s *SellDao) CreateWithTx(tx *gorm.DB, sell *model.Sell) (*model.Sell, erro
 // GetSellByEntrustIDs 根据委托id查询卖出记录
 func (s *SellDao) GetSellByEntrustIDs(ctx context.Context, ids []int64) ([]*model.Sell, error) {
 	var list []*model.Sell
-	sql := "select * from sell where entrust_id in (?) "
-	err := db.StockDB().WithContext(ctx).Raw(sql, ids).Find(&list).Error
+	err := db.StockDB().WithContext(ctx).Table("sell").Where("entrust_id in (?)", ids).Find(&list).Error
 	if err != nil {
 		return nil, serr.ErrBusiness("查询订单失败")
 	}
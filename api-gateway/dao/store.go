@@ -0,0 +1,248 @@
+package dao
+
+import (
+	"context"
+	"stock/api-gateway/db"
+	"stock/api-gateway/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Transactor 开启事务
+type Transactor interface {
+	Begin(ctx context.Context) *gorm.DB
+}
+
+// ContractStore 合约表
+type ContractStore interface {
+	UpdateContract(ctx context.Context, contract *model.Contract) error
+	UpdateWithTx(tx *gorm.DB, contract *model.Contract) error
+	CreateContract(ctx context.Context, contract *model.Contract) (*model.Contract, error)
+	GetContractByID(ctx context.Context, contractID int64) (*model.Contract, error)
+	GetContractByIDWithTx(tx *gorm.DB, contractID int64) (*model.Contract, error)
+	GetContractByIDForUpdateWithTx(tx *gorm.DB, contractID int64) (*model.Contract, error)
+	GetEnableContractByUID(ctx context.Context, uid int64) (*model.Contract, error)
+	UpdateContractValMoney(ctx context.Context, valMoney float64, contractID int64) error
+	GetContractsByUID(ctx context.Context, uid int64) ([]*model.Contract, error)
+	GetContracts(ctx context.Context) ([]*model.Contract, error)
+}
+
+// PositionStore 持仓表
+type PositionStore interface {
+	GetPositionByContractID(ctx context.Context, contractID int64) ([]*model.Position, error)
+	GetPositionByEntrustID(ctx context.Context, entrustID int64) (*model.Position, error)
+	GetPositions(ctx context.Context) ([]*model.Position, error)
+	CreateWithTx(tx *gorm.DB, position *model.Position) (*model.Position, error)
+	UpdateWithTx(tx *gorm.DB, position *model.Position) error
+	Update(ctx context.Context, position *model.Position) error
+	DeleteWithTx(tx *gorm.DB, position *model.Position) error
+	UnFreezeAmount(ctx context.Context, contractID int64, code string, amount int64) error
+	UnFreezeAmountWithTx(tx *gorm.DB, contractID int64, code string, amount int64) error
+	GetContractPositionByCode(ctx context.Context, contractID int64, code string) (*model.Position, error)
+	GetContractPositionByCodeWithTx(tx *gorm.DB, contractID int64, code string) (*model.Position, error)
+	FreezeAmountWithTx(tx *gorm.DB, contractID int64, code string, amount int64) error
+	GetPositionByID(ctx context.Context, positionID int64) (*model.Position, error)
+}
+
+// EntrustStore 委托表
+type EntrustStore interface {
+	GetTodayEntrust(ctx context.Context, contractID int64) ([]*model.Entrust, error)
+	GetEntrustByContractID(ctx context.Context, contractID int64) ([]*model.Entrust, error)
+	GetEntrustByID(ctx context.Context, entrustID int64) (*model.Entrust, error)
+	CreateWithTx(tx *gorm.DB, entrust *model.Entrust) (*model.Entrust, error)
+	Update(ctx context.Context, entrust *model.Entrust) error
+	UpdateWithTx(tx *gorm.DB, entrust *model.Entrust) error
+	Create(ctx context.Context, entrust *model.Entrust) (*model.Entrust, error)
+	GetTodayEntrusts(ctx context.Context) ([]*model.Entrust, error)
+	UpdateStatusWithTx(tx *gorm.DB, entrust *model.Entrust) error
+}
+
+// BrokerEntrustStore 券商委托表
+type BrokerEntrustStore interface {
+	MCreate(ctx context.Context, list []*model.BrokerEntrust) error
+	MCreateWithTx(tx *gorm.DB, list []*model.BrokerEntrust) error
+	GetByEntrustID(ctx context.Context, entrustID int64) ([]*model.BrokerEntrust, error)
+	GetTodayEntrusts(ctx context.Context) ([]*model.BrokerEntrust, error)
+	GetOpenByBrokerID(ctx context.Context, brokerID int64) ([]*model.BrokerEntrust, error)
+	GetDeals(ctx context.Context, brokerID int64, stockCode string, begin, end time.Time) ([]*model.BrokerEntrust, error)
+}
+
+// BuyStore 买入记录表
+type BuyStore interface {
+	GetBuyByPositionIDs(ctx context.Context, positionID []int64) ([]*model.Buy, error)
+	CreateWithTx(tx *gorm.DB, buy *model.Buy) error
+	GetByContractID(ctx context.Context, contractID int64) ([]*model.Buy, error)
+}
+
+// SellStore 卖出记录表
+type SellStore interface {
+	GetByPositionIDs(ctx context.Context, positionID []int64) ([]*model.Sell, error)
+	CreateWithTx(tx *gorm.DB, sell *model.Sell) (*model.Sell, error)
+	GetByContractID(ctx context.Context, contractID int64) ([]*model.Sell, error)
+}
+
+// ContractFeeStore 合约费用表
+type ContractFeeStore interface {
+	GetContractFeeByID(ctx context.Context, contractID int64) ([]*model.ContractFee, error)
+	GetContractFeeByIDs(ctx context.Context, contractIDs []int64) ([]*model.ContractFee, error)
+	CreateWithTx(tx *gorm.DB, fee *model.ContractFee) error
+	Create(ctx context.Context, fee *model.ContractFee) error
+}
+
+// SysStore 系统参数
+type SysStore interface {
+	GetSysParam(ctx context.Context) (*model.SysParam, error)
+}
+
+// UserStore 用户表
+type UserStore interface {
+	GetUserByUID(ctx context.Context, uid int64) (*model.User, error)
+	CreateUser(ctx context.Context, user *model.User) error
+	UpdateUserWithTx(tx *gorm.DB, user *model.User) error
+	UpdateCurrentContractID(ctx context.Context, uid, contractID int64) error
+	UpdateVerify(ctx context.Context, user *model.User) error
+	GetUsers(ctx context.Context) ([]*model.User, error)
+	GetUserByUIDForUpdateWithTx(tx *gorm.DB, uid int64) (*model.User, error)
+}
+
+// MsgStore 消息表
+type MsgStore interface {
+	Create(ctx context.Context, msg *model.Msg) error
+	CreateWithTx(tx *gorm.DB, msg *model.Msg) error
+}
+
+// TransferStore 资金流水表
+type TransferStore interface {
+	Create(ctx context.Context, transfer *model.Transfer) error
+	CreateWithTx(tx *gorm.DB, transfer *model.Transfer) error
+	GetByID(ctx context.Context, id int64) (*model.Transfer, error)
+	GetByUid(ctx context.Context, uid int64) ([]*model.Transfer, error)
+	GetByIDForUpdateWithTx(tx *gorm.DB, id int64) (*model.Transfer, error)
+	GetByOrderNoForUpdateWithTx(tx *gorm.DB, orderNo string) (*model.Transfer, error)
+}
+
+// HisPositionStore 历史持仓表
+type HisPositionStore interface {
+	GetYesterdayPositionByContractID(ctx context.Context, contractID int64) ([]*model.Position, error)
+}
+
+// DividendStore 分红送股表
+type DividendStore interface {
+	GetDividendByPositionIDs(ctx context.Context, positionID int64) ([]*model.Dividend, error)
+	GetByContractID(ctx context.Context, contractID int64) ([]*model.Dividend, error)
+}
+
+// StockDataStore 股票池
+type StockDataStore interface {
+	GetStockDataByCode(ctx context.Context, code string) (*model.StockData, error)
+}
+
+// ReverseRepoStore 国债逆回购表
+type ReverseRepoStore interface {
+	GetActiveByContractID(ctx context.Context, contractID int64) ([]*model.ReverseRepo, error)
+}
+
+// LedgerStore 记账分录表
+type LedgerStore interface {
+	CreateWithTx(tx *gorm.DB, entries []*model.LedgerEntry) error
+	GetBalances(ctx context.Context, accountType int64) (map[int64]float64, error)
+}
+
+// BrokerStore 券商母账户表
+type BrokerStore interface {
+	GetBrokers(ctx context.Context) ([]*model.Broker, error)
+}
+
+// BrokerReconcileStore 券商对账表
+type BrokerReconcileStore interface {
+	Create(ctx context.Context, report *model.BrokerReconcile, breaks []*model.BrokerReconcileBreak, positions []*model.BrokerReconcilePosition) error
+	GetLast(ctx context.Context, brokerID int64, billDate int32) (*model.BrokerReconcile, error)
+	GetPositions(ctx context.Context, reconcileID int64) ([]*model.BrokerReconcilePosition, error)
+}
+
+// PaymentChannelStore 充值渠道配置表
+type PaymentChannelStore interface {
+	GetConfigs(ctx context.Context) (map[string]*model.PaymentChannelConfig, error)
+}
+
+var (
+	_ ContractStore        = (*ContractDao)(nil)
+	_ PositionStore        = (*PositionDao)(nil)
+	_ EntrustStore         = (*EntrustDao)(nil)
+	_ BrokerEntrustStore   = (*BrokerEntrustDao)(nil)
+	_ BuyStore             = (*BuyDao)(nil)
+	_ SellStore            = (*SellDao)(nil)
+	_ ContractFeeStore     = (*ContractFeeDao)(nil)
+	_ SysStore             = (*SysDao)(nil)
+	_ UserStore            = (*UserDao)(nil)
+	_ MsgStore             = (*MsgDao)(nil)
+	_ TransferStore        = (*TransferDao)(nil)
+	_ HisPositionStore     = (*HisPositionDao)(nil)
+	_ DividendStore        = (*DividendDao)(nil)
+	_ StockDataStore       = (*StockDataDao)(nil)
+	_ ReverseRepoStore     = (*ReverseRepoDao)(nil)
+	_ LedgerStore          = (*LedgerDao)(nil)
+	_ PaymentChannelStore  = (*PaymentChannelDao)(nil)
+	_ BrokerStore          = (*BrokerDao)(nil)
+	_ BrokerReconcileStore = (*BrokerReconcileDao)(nil)
+)
+
+// Store 交易核心依赖的数据访问集合,测试时可替换为内存实现
+type Store struct {
+	Tx              Transactor
+	Contract        ContractStore
+	Position        PositionStore
+	Entrust         EntrustStore
+	BrokerEntrust   BrokerEntrustStore
+	Buy             BuyStore
+	Sell            SellStore
+	ContractFee     ContractFeeStore
+	Sys             SysStore
+	User            UserStore
+	Msg             MsgStore
+	Transfer        TransferStore
+	HisPosition     HisPositionStore
+	Dividend        DividendStore
+	StockData       StockDataStore
+	ReverseRepo     ReverseRepoStore
+	Ledger          LedgerStore
+	PaymentChannel  PaymentChannelStore
+	BrokerAccount   BrokerStore
+	BrokerReconcile BrokerReconcileStore
+}
+
+// mysqlTransactor 数据库事务
+type mysqlTransactor struct {
+}
+
+// Begin 开启数据库事务
+func (t *mysqlTransactor) Begin(ctx context.Context) *gorm.DB {
+	return db.StockDB().WithContext(ctx).Begin()
+}
+
+// DefaultStore 基于MySQL的数据访问集合
+func DefaultStore() *Store {
+	return &Store{
+		Tx:              &mysqlTransactor{},
+		Contract:        ContractDaoInstance(),
+		Position:        PositionDaoInstance(),
+		Entrust:         EntrustDaoInstance(),
+		BrokerEntrust:   BrokerEntrustDaoInstance(),
+		Buy:             BuyDaoInstance(),
+		Sell:            SellDaoInstance(),
+		ContractFee:     ContractFeeDaoInstance(),
+		Sys:             SysDaoInstance(),
+		User:            UserDaoInstance(),
+		Msg:             MsgDaoInstance(),
+		Transfer:        TransferDaoInstance(),
+		HisPosition:     HisPositionDaoInstance(),
+		Dividend:        DividendDaoInstance(),
+		StockData:       StockDataDaoInstance(),
+		ReverseRepo:     ReverseRepoDaoInstance(),
+		Ledger:          LedgerDaoInstance(),
+		PaymentChannel:  PaymentChannelDaoInstance(),
+		BrokerAccount:   BrokerDaoInstance(),
+		BrokerReconcile: BrokerReconcileDaoInstance(),
+	}
+}
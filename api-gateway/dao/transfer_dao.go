@@ -6,6 +6,7 @@ import (
 	"stock/api-gateway/model"
 	"stock/api-gateway/serr"
 	"stock/common/log"
+	"time"
 
 	"gorm.io/gorm"
 
@@ -72,3 +73,57 @@ func (s *TransferDao) GetByID(ctx context.Context, id int64) (*model.Transfer, e
 	}
 	return transfer, nil
 }
+
+// GetByIDForUpdateWithTx 事务内根据ID查询并加行锁,直到事务结束
+func (s *TransferDao) GetByIDForUpdateWithTx(tx *gorm.DB, id int64) (*model.Transfer, error) {
+	var transfer *model.Transfer
+	if err := tx.Table("transfer").Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).Take(&transfer).Error; err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// GetByOrderNoForUpdateWithTx 事务内根据订单号查询并加行锁,直到事务结束
+func (s *TransferDao) GetByOrderNoForUpdateWithTx(tx *gorm.DB, orderNo string) (*model.Transfer, error) {
+	var transfer *model.Transfer
+	if err := tx.Table("transfer").Clauses(clause.Locking{Strength: "UPDATE"}).Where("order_no = ?", orderNo).Take(&transfer).Error; err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// GetPreByChannel 渠道下单后未支付完成的充值订单
+func (s *TransferDao) GetPreByChannel(ctx context.Context, channel string, begin, end time.Time) ([]*model.Transfer, error) {
+	var list []*model.Transfer
+	err := db.StockDB().WithContext(ctx).Table("transfer").
+		Where("type = ? and status = ? and channel = ? and order_time >= ? and order_time < ?",
+			model.TransferTypeRecharge, model.TransferStatusPre, channel, begin, end).
+		Find(&list).Error
+	if err != nil {
+		log.Errorf("GetPreByChannel err:%+v", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// GetRechargeByChannel 渠道充值订单
+func (s *TransferDao) GetRechargeByChannel(ctx context.Context, channel string, begin, end time.Time) ([]*model.Transfer, error) {
+	var list []*model.Transfer
+	err := db.StockDB().WithContext(ctx).Table("transfer").
+		Where("type = ? and channel = ? and order_time >= ? and order_time < ?", model.TransferTypeRecharge, channel, begin, end).
+		Find(&list).Error
+	if err != nil {
+		log.Errorf("GetRechargeByChannel err:%+v", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// UpdateStatusByOrderNoWithTx 根据订单号更新状态
+func (s *TransferDao) UpdateStatusByOrderNoWithTx(tx *gorm.DB, orderNo string, status int64) error {
+	if err := tx.Table("transfer").Where("order_no = ?", orderNo).Update("status", status).Error; err != nil {
+		log.Errorf("更新转账记录状态失败:%+v", err)
+		return err
+	}
+	return nil
+}
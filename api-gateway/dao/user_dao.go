@@ -41,8 +41,8 @@ func (s *UserDao) GetUserByUserName(ctx context.Context, userName string) (*mode
 // GetUserByUID 根据UID查询用户
 func (s *UserDao) GetUserByUID(ctx context.Context, uid int64) (*model.User, error) {
 	var user *model.User
-	sql := "select * from users where id = ?"
-	err := db.StockDB().WithContext(ctx).Raw(sql, uid).Take(&user).Error
+	// 使用查询构造,代理商只能查询名下用户
+	err := db.StockDB().WithContext(ctx).Table("users").Where("id = ?", uid).Take(&user).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) { // 空记录
 			return nil, serr.New(serr.ErrCodeBusinessFail, "用户不存在")
@@ -53,10 +53,23 @@ func (s *UserDao) GetUserByUID(ctx context.Context, uid int64) (*model.User, err
 	return user, nil
 }
 
+// GetUserByUIDForUpdateWithTx 事务内查询用户并加行锁,直到事务结束
+func (s *UserDao) GetUserByUIDForUpdateWithTx(tx *gorm.DB, uid int64) (*model.User, error) {
+	var user *model.User
+	err := tx.Table("users").Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", uid).Take(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, serr.New(serr.ErrCodeBusinessFail, "用户不存在")
+		}
+		log.Errorf("查询用户失败:uid[%+v],err:%+v", uid, err)
+		return nil, serr.New(serr.ErrCodeBusinessFail, "查询用户失败")
+	}
+	return user, nil
+}
+
 // SetCurrentContract 设置用户当前合约
 func (s *UserDao) SetCurrentContract(ctx context.Context, uid, contractID int64) error {
-	sql := "update users set current_contract_id = ? where id = ?"
-	err := db.StockDB().WithContext(ctx).Exec(sql, contractID, uid).Error
+	err := db.StockDB().WithContext(ctx).Table("users").Where("id = ?", uid).Update("current_contract_id", contractID).Error
 	if err != nil {
 		log.Errorf("更新用户当前合约失败:uid[%v] current_contract_id[%v]", uid, contractID)
 		return serr.New(serr.ErrCodeBusinessFail, "更新用户当前合约失败")
@@ -75,6 +88,20 @@ func (s *UserDao) CreateUser(ctx context.Context, user *model.User) error {
 	return nil
 }
 
+// UpdateVerify 更新实名认证信息:只更新姓名、身份证号、认证状态,已认证的用户不再更新
+func (s *UserDao) UpdateVerify(ctx context.Context, user *model.User) error {
+	updateMap := make(map[string]interface{})
+	updateMap["name"] = user.Name
+	updateMap["icc_id"] = user.ICCID
+	updateMap["verify_status"] = user.VerifyStatus
+	if err := db.StockDB().WithContext(ctx).Table("users").Where("id = ? and verify_status != ?", user.ID, model.UserVerifyPass).
+		Updates(updateMap).Error; err != nil {
+		log.Errorf("更新实名认证信息失败:%+v", err)
+		return err
+	}
+	return nil
+}
+
 // UpdateUserWithTx 更新用户
 func (s *UserDao) UpdateUserWithTx(tx *gorm.DB, user *model.User) error {
 	if err := tx.Table("users").Clauses(clause.OnConflict{
@@ -99,8 +126,7 @@ func (s *UserDao) ModifyUserPassword(ctx context.Context, userName, newPassword
 // CheckUserExist 检查用户是否存在,存在返回true,不存在返回false
 func (s *UserDao) CheckUserExist(ctx context.Context, uid int64) error {
 	var user *model.User
-	sql := `select * from users where id = ?`
-	err := db.StockDB().WithContext(ctx).Raw(sql, uid).Take(&user).Error
+	err := db.StockDB().WithContext(ctx).Table("users").Where("id = ?", uid).Take(&user).Error
 	if err != nil || user.ID == 0 {
 		return serr.Errorf(serr.ErrCodeNoLogin, "请登录")
 	}
@@ -109,8 +135,7 @@ func (s *UserDao) CheckUserExist(ctx context.Context, uid int64) error {
 
 // UpdateCurrentContractID 更新用户当前合约id
 func (s *UserDao) UpdateCurrentContractID(ctx context.Context, uid, contractID int64) error {
-	sql := "update users set current_contract_id = ? where id = ?"
-	if err := db.StockDB().WithContext(ctx).Exec(sql, contractID, uid).Error; err != nil {
+	if err := db.StockDB().WithContext(ctx).Table("users").Where("id = ?", uid).Update("current_contract_id", contractID).Error; err != nil {
 		log.Errorf("更新用户当前合约ID失败:%+v", err)
 		return serr.ErrBusiness("更新合约失败")
 	}
@@ -22,6 +22,9 @@ func Init(ctx context.Context) {
 	if err != nil {
 		log.Panic(ctx, "connect to db err", err)
 	}
+	if err := registerUIDScope(db); err != nil {
+		log.Panic(ctx, "register uid scope err", err)
+	}
 
 	// 连接池设置
 	sqlDB, err := db.DB()
@@ -0,0 +1,160 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"sort"
+	"stock/api-gateway/serr"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// uidScopeKey 数据范围:仅允许访问的用户ID
+type uidScopeKey struct{}
+
+// uidScopeTables 按用户隔离的数据表及其用户ID字段
+var uidScopeTables = map[string]string{
+	"users":             "id",
+	"transfer":          "uid",
+	"portfolio":         "uid",
+	"entrust":           "uid",
+	"buy":               "uid",
+	"sell":              "uid",
+	"position":          "uid",
+	"his_position":      "uid",
+	"contract":          "uid",
+	"msg":               "uid",
+	"dividend":          "uid",
+	"contract_fee":      "uid",
+	"contract_record":   "uid",
+	"broker_entrust":    "uid",
+	"conditional_order": "uid",
+	"reverse_repo":      "uid",
+}
+
+// rawScopeTable 匹配原生SQL中访问的用户数据表
+var rawScopeTable = func() *regexp.Regexp {
+	tables := make([]string, 0, len(uidScopeTables))
+	for it := range uidScopeTables {
+		tables = append(tables, regexp.QuoteMeta(it))
+	}
+	sort.Strings(tables)
+	return regexp.MustCompile("(?i)\\b(from|join|update|into)\\s+`?(" + strings.Join(tables, "|") + ")`?\\b")
+}()
+
+// WithUIDScope 限定ctx内的查询、更新、删除只能访问uids中的用户数据
+func WithUIDScope(ctx context.Context, uids []int64) context.Context {
+	return context.WithValue(ctx, uidScopeKey{}, uids)
+}
+
+// UIDScope ctx的数据范围,ok为false表示不限制
+func UIDScope(ctx context.Context) ([]int64, bool) {
+	uids, ok := ctx.Value(uidScopeKey{}).([]int64)
+	return uids, ok
+}
+
+// statementScope 语句的数据范围及用户ID字段,不限制或非用户数据表时ok为false
+func statementScope(tx *gorm.DB) (uids []int64, column string, ok bool) {
+	if tx.Statement.Context == nil {
+		return nil, "", false
+	}
+	if uids, ok = UIDScope(tx.Statement.Context); !ok {
+		return nil, "", false
+	}
+	column, ok = uidScopeTables[tx.Statement.Table]
+	return uids, column, ok
+}
+
+// checkUpsertScope upsert无法追加过滤条件,逐行校验用户ID字段在数据范围内,新增记录(字段为零值)不校验
+func checkUpsertScope(tx *gorm.DB) {
+	if _, ok := tx.Statement.Clauses["ON CONFLICT"]; !ok || tx.Statement.Schema == nil {
+		return
+	}
+	uids, column, ok := statementScope(tx)
+	if !ok {
+		return
+	}
+	field := tx.Statement.Schema.LookUpField(column)
+	if field == nil {
+		return
+	}
+	allowed := make(map[int64]bool, len(uids))
+	for _, it := range uids {
+		allowed[it] = true
+	}
+	check := func(rv reflect.Value) bool {
+		value := rv.FieldByIndex(field.StructField.Index)
+		if value.Kind() != reflect.Int64 {
+			return false
+		}
+		return value.Int() == 0 || allowed[value.Int()]
+	}
+	rv := reflect.Indirect(tx.Statement.ReflectValue)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if !check(reflect.Indirect(rv.Index(i))) {
+				tx.AddError(serr.ErrBusiness("权限不足"))
+				return
+			}
+		}
+	case reflect.Struct:
+		if !check(rv) {
+			tx.AddError(serr.ErrBusiness("权限不足"))
+		}
+	}
+}
+
+// checkRawScope 原生SQL无法追加过滤条件,有数据范围时拒绝访问用户数据表的原生SQL
+func checkRawScope(tx *gorm.DB) {
+	if tx.Statement.SQL.Len() == 0 || tx.Statement.Context == nil {
+		return
+	}
+	if _, ok := UIDScope(tx.Statement.Context); !ok {
+		return
+	}
+	if rawScopeTable.MatchString(tx.Statement.SQL.String()) {
+		tx.AddError(serr.ErrBusiness("权限不足"))
+	}
+}
+
+// registerUIDScope 注册gorm回调:按ctx中的数据范围为用户数据表追加过滤条件,upsert逐行校验用户ID;
+// 原生SQL访问用户数据表时拒绝,需要数据范围的查询应使用查询构造
+func registerUIDScope(db *gorm.DB) error {
+	scope := func(tx *gorm.DB) {
+		if tx.Statement.SQL.Len() > 0 {
+			checkRawScope(tx)
+			return
+		}
+		uids, column, ok := statementScope(tx)
+		if !ok {
+			return
+		}
+		values := make([]interface{}, 0, len(uids))
+		for _, it := range uids {
+			values = append(values, it)
+		}
+		tx.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+			clause.IN{Column: clause.Column{Table: tx.Statement.Table, Name: column}, Values: values},
+		}})
+	}
+	if err := db.Callback().Query().Before("gorm:query").Register("uid_scope:query", scope); err != nil {
+		return err
+	}
+	if err := db.Callback().Row().Before("gorm:row").Register("uid_scope:row", scope); err != nil {
+		return err
+	}
+	if err := db.Callback().Raw().Before("gorm:raw").Register("uid_scope:raw", checkRawScope); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register("uid_scope:update", scope); err != nil {
+		return err
+	}
+	if err := db.Callback().Create().Before("gorm:create").Register("uid_scope:create", checkUpsertScope); err != nil {
+		return err
+	}
+	return db.Callback().Delete().Before("gorm:delete").Register("uid_scope:delete", scope)
+}
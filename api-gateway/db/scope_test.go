@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// contract、user 测试用的合约、用户表结构
+type contract struct {
+	ID  int64 `gorm:"column:id"`
+	UID int64 `gorm:"column:uid"`
+}
+
+type user struct {
+	ID int64 `gorm:"column:id"`
+}
+
+// newDryRunDB 只生成SQL不执行的数据库连接
+func newDryRunDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		DSN:                       "test:test@tcp(127.0.0.1:3306)/stock",
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatalf("open: %+v", err)
+	}
+	if err := registerUIDScope(db); err != nil {
+		t.Fatalf("register: %+v", err)
+	}
+	return db
+}
+
+// TestUIDScopeQuery 代理商查询其他代理商用户的合约、用户:追加用户ID过滤条件
+func TestUIDScopeQuery(t *testing.T) {
+	db := newDryRunDB(t)
+	ctx := WithUIDScope(context.Background(), []int64{1, 2})
+
+	var c *contract
+	sql := db.WithContext(ctx).Table("contract").Where("id = ?", 3).Take(&c).Statement.SQL.String()
+	if !strings.Contains(sql, "`contract`.`uid` IN (?,?)") {
+		t.Fatalf("expect uid scope: %s", sql)
+	}
+	var u *user
+	sql = db.WithContext(ctx).Table("users").Where("id = ?", 3).Take(&u).Statement.SQL.String()
+	if !strings.Contains(sql, "`users`.`id` IN (?,?)") {
+		t.Fatalf("expect uid scope: %s", sql)
+	}
+	// 不限制数据范围
+	sql = db.WithContext(context.Background()).Table("contract").Where("id = ?", 3).Take(&c).Statement.SQL.String()
+	if strings.Contains(sql, "IN") {
+		t.Fatalf("expect no scope: %s", sql)
+	}
+}
+
+// TestUIDScopeUpsert 代理商upsert其他代理商用户的合约、用户被拒绝,名下用户及新增记录允许
+func TestUIDScopeUpsert(t *testing.T) {
+	db := newDryRunDB(t)
+	ctx := WithUIDScope(context.Background(), []int64{1, 2})
+	upsert := func(table string, value interface{}) error {
+		return db.WithContext(ctx).Table(table).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "id"}},
+			UpdateAll: true,
+		}).Create(value).Error
+	}
+
+	if err := upsert("contract", &contract{ID: 10, UID: 3}); err == nil {
+		t.Fatal("expect denied contract")
+	}
+	if err := upsert("users", &user{ID: 3}); err == nil {
+		t.Fatal("expect denied user")
+	}
+	if err := upsert("contract", []*contract{{ID: 10, UID: 1}, {ID: 11, UID: 3}}); err == nil {
+		t.Fatal("expect denied batch")
+	}
+	if err := upsert("contract", &contract{ID: 10, UID: 1}); err != nil {
+		t.Fatalf("expect allowed: %+v", err)
+	}
+	if err := upsert("users", &user{}); err != nil {
+		t.Fatalf("expect allowed new user: %+v", err)
+	}
+	// 普通写入不校验
+	if err := db.WithContext(ctx).Table("contract").Create(&contract{UID: 3}).Error; err != nil {
+		t.Fatalf("expect allowed create: %+v", err)
+	}
+}
+
+// TestUIDScopeRaw 有数据范围时访问用户数据表的原生SQL被拒绝,其他表及不限制数据范围时允许
+func TestUIDScopeRaw(t *testing.T) {
+	db := newDryRunDB(t)
+	ctx := WithUIDScope(context.Background(), []int64{1, 2})
+
+	var list []*contract
+	if err := db.WithContext(ctx).Raw("select * from contract where id = ?", 3).Find(&list).Error; err == nil {
+		t.Fatal("expect denied raw query")
+	}
+	if err := db.WithContext(ctx).Exec("update `position` set freeze_amount = 0 where id = ?", 3).Error; err == nil {
+		t.Fatal("expect denied raw exec")
+	}
+	if err := db.WithContext(ctx).Raw("select * from contract_record_log where contract_id = ?", 3).Find(&list).Error; err != nil {
+		t.Fatalf("expect allowed other table: %+v", err)
+	}
+	if err := db.WithContext(context.Background()).Raw("select * from contract where id = ?", 3).Find(&list).Error; err != nil {
+		t.Fatalf("expect allowed without scope: %+v", err)
+	}
+	// 查询构造统计同样追加用户ID过滤条件
+	row := db.WithContext(ctx).Table("contract").Select("count(*)").Where("id = ?", 3)
+	row.Row()
+	if sql := row.Statement.SQL.String(); !strings.Contains(sql, "`contract`.`uid` IN (?,?)") {
+		t.Fatalf("expect uid scope: %s", sql)
+	}
+}
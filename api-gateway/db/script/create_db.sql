@@ -395,4 +395,189 @@ CREATE TABLE if not exists  `broker_error_log` (
     `url` VARCHAR(1024) NOT NULL COMMENT '请求地址',
     `error` VARCHAR(2048) NOT NULL COMMENT '错误信息',
     `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
-    )ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
\ No newline at end of file
+    )ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+-- 条件单:持仓止损、止盈、跟踪止损
+CREATE TABLE if not exists  `conditional_order` (
+    `id` BIGINT(11) PRIMARY KEY AUTO_INCREMENT COMMENT '主键',
+    `uid` BIGINT(11) NOT NULL COMMENT '用户ID',
+    `contract_id` INT(11) NOT NULL COMMENT '合约编号',
+    `position_id` INT(11) NOT NULL COMMENT '持仓表id',
+    `stock_code` VARCHAR(16) NOT NULL COMMENT '股票代码',
+    `stock_name` VARCHAR(32) NOT NULL COMMENT '股票名称',
+    `type` INT(2) NOT NULL COMMENT '类型:1止损 2止盈 3跟踪止损',
+    `trigger_price` DECIMAL(15,2) NOT NULL DEFAULT 0 COMMENT '触发价格:止损价、止盈价',
+    `trail_pct` DECIMAL(6,5) NOT NULL DEFAULT 0 COMMENT '跟踪止损:回撤比例',
+    `high_price` DECIMAL(15,2) NOT NULL DEFAULT 0 COMMENT '跟踪止损:监控期间最高价',
+    `amount` INT(11) NOT NULL DEFAULT 0 COMMENT '卖出数量:0表示全部可卖股数',
+    `status` INT(2) NOT NULL COMMENT '状态:1监控中 2已触发 3已取消 4触发失败',
+    `remark` VARCHAR(1024) NOT NULL DEFAULT '' COMMENT '备注:触发结果',
+    `create_time` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    `update_time` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '更新时间',
+    INDEX `idx_conditional_order_contract_id` (`contract_id`),
+    INDEX `idx_conditional_order_status` (`status`)
+)ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+-- 国债逆回购:融出资金委托及到期结算
+CREATE TABLE if not exists  `reverse_repo` (
+    `id` BIGINT(11) PRIMARY KEY AUTO_INCREMENT COMMENT '主键',
+    `uid` BIGINT(11) NOT NULL COMMENT '用户ID',
+    `contract_id` INT(11) NOT NULL DEFAULT 0 COMMENT '合约编号:资金来源为钱包时为0',
+    `source` INT(2) NOT NULL COMMENT '资金来源:1合约资金 2钱包余额',
+    `code` VARCHAR(16) NOT NULL COMMENT '证券代码',
+    `name` VARCHAR(32) NOT NULL COMMENT '证券简称',
+    `tenor` INT(11) NOT NULL COMMENT '期限(天)',
+    `entrust_bs` INT(2) NOT NULL DEFAULT 3 COMMENT '交易类型:3融出',
+    `entrust_prop` INT(2) NOT NULL COMMENT '委托类型:1限价 2市价',
+    `entrust_rate` DECIMAL(10,3) NOT NULL DEFAULT 0 COMMENT '委托年化利率(%)',
+    `money` DECIMAL(15,2) NOT NULL COMMENT '融出金额(本金)',
+    `rate` DECIMAL(10,3) NOT NULL DEFAULT 0 COMMENT '成交年化利率(%)',
+    `fee` DECIMAL(15,2) NOT NULL DEFAULT 0 COMMENT '手续费',
+    `interest` DECIMAL(15,2) NOT NULL DEFAULT 0 COMMENT '到期利息',
+    `accrued_interest` DECIMAL(15,2) NOT NULL DEFAULT 0 COMMENT '已计提利息',
+    `interest_days` INT(11) NOT NULL DEFAULT 0 COMMENT '计息天数',
+    `trade_date` INT(11) NOT NULL DEFAULT 0 COMMENT '成交日',
+    `settle_date` INT(11) NOT NULL DEFAULT 0 COMMENT '首次交收日:起息日',
+    `expire_date` INT(11) NOT NULL DEFAULT 0 COMMENT '到期交收日',
+    `available_date` INT(11) NOT NULL DEFAULT 0 COMMENT '资金可用日:本息到账',
+    `status` INT(2) NOT NULL COMMENT '状态:1未成交 2计息中 3已撤单 4已到期',
+    `remark` VARCHAR(1024) NOT NULL DEFAULT '' COMMENT '备注',
+    `create_time` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '委托时间',
+    `update_time` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '更新时间',
+    INDEX `idx_reverse_repo_uid` (`uid`),
+    INDEX `idx_reverse_repo_contract_id` (`contract_id`),
+    INDEX `idx_reverse_repo_status` (`status`)
+)ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+-- 定时任务执行记录
+CREATE TABLE if not exists  `job_run` (
+    `id` BIGINT(11) PRIMARY KEY AUTO_INCREMENT COMMENT '主键',
+    `name` VARCHAR(64) NOT NULL COMMENT '任务名称',
+    `run_date` INT(11) NOT NULL COMMENT '业务日期',
+    `trigger` INT(2) NOT NULL COMMENT '触发方式:1定时触发 2重启补跑 3手动触发',
+    `status` INT(2) NOT NULL COMMENT '执行状态:1执行中 2成功 3失败',
+    `attempts` INT(11) NOT NULL DEFAULT 0 COMMENT '执行次数:含失败重试',
+    `error` VARCHAR(1024) NOT NULL DEFAULT '' COMMENT '最后一次失败原因',
+    `operator` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '手动触发的操作员',
+    `start_time` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '开始时间',
+    `end_time` TIMESTAMP NULL DEFAULT NULL COMMENT '结束时间',
+    INDEX `idx_job_run_name_date` (`name`, `run_date`)
+)ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+-- 记账分录:只增不改,同一凭证的分录金额之和为0
+CREATE TABLE if not exists  `ledger_entry` (
+    `id` BIGINT(11) PRIMARY KEY AUTO_INCREMENT COMMENT '主键',
+    `journal_no` VARCHAR(32) NOT NULL COMMENT '凭证号',
+    `biz_type` INT(4) NOT NULL COMMENT '业务类型',
+    `biz_id` BIGINT(11) NOT NULL DEFAULT 0 COMMENT '业务ID',
+    `account_type` INT(4) NOT NULL COMMENT '账户类型:1用户钱包 2提现冻结 3合约保证金 4手续费收入 5利息收入 6券商资金 7外部资金 8证券市场 9逆回购融出 10人工调账',
+    `owner_id` BIGINT(11) NOT NULL DEFAULT 0 COMMENT '账户所属ID:用户ID、合约ID、券商ID,平台账户为0',
+    `amount` DECIMAL(15,2) NOT NULL COMMENT '金额:正数余额增加,负数余额减少',
+    `remark` VARCHAR(255) NOT NULL DEFAULT '' COMMENT '备注',
+    `create_time` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '记账时间',
+    INDEX `idx_ledger_entry_account` (`account_type`, `owner_id`),
+    INDEX `idx_ledger_entry_journal_no` (`journal_no`),
+    INDEX `idx_ledger_entry_biz` (`biz_type`, `biz_id`)
+)ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+-- 后台操作审计日志:只增不改,禁止更新、删除
+CREATE TABLE if not exists  `audit_log` (
+    `id` BIGINT(11) PRIMARY KEY AUTO_INCREMENT COMMENT '主键',
+    `operator` VARCHAR(64) NOT NULL COMMENT '操作员',
+    `ip` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '操作员IP',
+    `method` VARCHAR(16) NOT NULL COMMENT '请求方法',
+    `path` VARCHAR(255) NOT NULL COMMENT '接口地址',
+    `module` INT(4) NOT NULL DEFAULT 0 COMMENT '所属目录模块',
+    `target_type` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '操作对象类型',
+    `target_id` BIGINT(11) NOT NULL DEFAULT 0 COMMENT '操作对象ID',
+    `params` TEXT COMMENT '请求参数',
+    `before_data` TEXT COMMENT '修改前快照',
+    `after_data` TEXT COMMENT '修改后快照',
+    `diff` TEXT COMMENT '变化字段',
+    `code` INT(11) NOT NULL DEFAULT 0 COMMENT '返回码:0成功',
+    `msg` VARCHAR(1024) NOT NULL DEFAULT '' COMMENT '返回信息',
+    `create_time` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '操作时间',
+    INDEX `idx_audit_log_operator` (`operator`, `create_time`),
+    INDEX `idx_audit_log_target` (`target_type`, `target_id`),
+    INDEX `idx_audit_log_create_time` (`create_time`)
+)ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+DROP TRIGGER IF EXISTS `audit_log_no_update`;
+CREATE TRIGGER `audit_log_no_update` BEFORE UPDATE ON `audit_log` FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_log is append-only';
+DROP TRIGGER IF EXISTS `audit_log_no_delete`;
+CREATE TRIGGER `audit_log_no_delete` BEFORE DELETE ON `audit_log` FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_log is append-only';
+-- 充值渠道对账报告
+CREATE TABLE if not exists  `reconcile_report` (
+    `id` BIGINT(11) PRIMARY KEY AUTO_INCREMENT COMMENT '主键',
+    `channel` VARCHAR(64) NOT NULL COMMENT '充值渠道',
+    `bill_date` INT(11) NOT NULL COMMENT '对账日期',
+    `matched` INT(11) NOT NULL DEFAULT 0 COMMENT '一致笔数',
+    `matched_money` DECIMAL(15,2) NOT NULL DEFAULT 0 COMMENT '一致金额',
+    `diff_count` INT(11) NOT NULL DEFAULT 0 COMMENT '差异笔数',
+    `diff` TEXT COMMENT '差异明细',
+    `status` INT(2) NOT NULL COMMENT '对账结果:1一致 2有差异',
+    `operator` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '操作员',
+    `create_time` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '对账时间',
+    INDEX `idx_reconcile_report_date` (`channel`, `bill_date`)
+)ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+-- 充值渠道配置:单笔金额限制与手续费
+CREATE TABLE if not exists  `payment_channel` (
+    `id` BIGINT(11) PRIMARY KEY AUTO_INCREMENT COMMENT '主键',
+    `code` VARCHAR(32) NOT NULL COMMENT '渠道编码:alipay bank qrcode',
+    `min_money` DECIMAL(15,2) NOT NULL DEFAULT 0.01 COMMENT '单笔最低充值金额',
+    `max_money` DECIMAL(15,2) NOT NULL DEFAULT 0 COMMENT '单笔最高充值金额,0不限制',
+    `fee_rate` DECIMAL(10,6) NOT NULL DEFAULT 0 COMMENT '手续费率',
+    `min_fee` DECIMAL(15,2) NOT NULL DEFAULT 0 COMMENT '最低手续费',
+    `sort` INT(4) NOT NULL DEFAULT 0 COMMENT '排序,越小越靠前',
+    UNIQUE KEY `uk_payment_channel_code` (`code`)
+)ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+insert into `payment_channel`(code,sort) values('alipay',1),('bank',2),('qrcode',3);
+
+-- 设备:请求签名使用的设备密钥
+CREATE TABLE if not exists  `device` (
+    `id` BIGINT(11) PRIMARY KEY AUTO_INCREMENT COMMENT '主键',
+    `device_id` VARCHAR(64) NOT NULL COMMENT '设备ID',
+    `secret` VARCHAR(255) NOT NULL DEFAULT '' COMMENT '签名密钥(加密)',
+    `platform` VARCHAR(32) NOT NULL DEFAULT '' COMMENT '客户端平台',
+    `create_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '注册时间',
+    UNIQUE KEY `uk_device_id` (`device_id`)
+)ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- 券商对账
+CREATE TABLE if not exists  `broker_reconcile` (
+    `id` BIGINT(11) PRIMARY KEY AUTO_INCREMENT COMMENT '主键',
+    `broker_id` INT(11) NOT NULL COMMENT '券商ID',
+    `bill_date` INT(11) NOT NULL COMMENT '对账日期',
+    `expected_cash` DECIMAL(15,2) NOT NULL DEFAULT 0 COMMENT '推算资金余额',
+    `broker_cash` DECIMAL(15,2) NOT NULL DEFAULT 0 COMMENT '券商资金余额',
+    `break_count` INT(11) NOT NULL DEFAULT 0 COMMENT '差异条数',
+    `status` INT(2) NOT NULL COMMENT '对账结果:1一致 2有差异 3查询失败',
+    `remark` VARCHAR(255) NOT NULL DEFAULT '' COMMENT '备注',
+    `operator` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '操作员,定时任务为空',
+    `create_time` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '对账时间',
+    INDEX `idx_broker_reconcile_broker_date` (`broker_id`, `bill_date`)
+    )ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- 券商对账差异
+CREATE TABLE if not exists  `broker_reconcile_break` (
+    `id` BIGINT(11) PRIMARY KEY AUTO_INCREMENT COMMENT '主键',
+    `reconcile_id` BIGINT(11) NOT NULL COMMENT '券商对账表ID',
+    `broker_id` INT(11) NOT NULL COMMENT '券商ID',
+    `bill_date` INT(11) NOT NULL COMMENT '对账日期',
+    `type` INT(2) NOT NULL COMMENT '差异类型:1持仓 2资金',
+    `stock_code` VARCHAR(8) NOT NULL DEFAULT '' COMMENT '股票代码',
+    `stock_name` VARCHAR(32) NOT NULL DEFAULT '' COMMENT '股票名称',
+    `expected` DECIMAL(15,2) NOT NULL DEFAULT 0 COMMENT '推算股数/资金',
+    `actual` DECIMAL(15,2) NOT NULL DEFAULT 0 COMMENT '券商股数/资金',
+    `diff` DECIMAL(15,2) NOT NULL DEFAULT 0 COMMENT '差异:券商-推算',
+    `severity` INT(2) NOT NULL COMMENT '差异级别:1低 2中 3高',
+    `create_time` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '对账时间',
+    INDEX `idx_broker_reconcile_break_reconcile_id` (`reconcile_id`)
+    )ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- 券商对账持仓:对账时券商查询的持仓,作为下次对账的期初
+CREATE TABLE if not exists  `broker_reconcile_position` (
+    `id` BIGINT(11) PRIMARY KEY AUTO_INCREMENT COMMENT '主键',
+    `reconcile_id` BIGINT(11) NOT NULL COMMENT '券商对账表ID',
+    `broker_id` INT(11) NOT NULL COMMENT '券商ID',
+    `bill_date` INT(11) NOT NULL COMMENT '对账日期',
+    `stock_code` VARCHAR(8) NOT NULL DEFAULT '' COMMENT '股票代码',
+    `stock_name` VARCHAR(32) NOT NULL DEFAULT '' COMMENT '股票名称',
+    `amount` BIGINT(11) NOT NULL DEFAULT 0 COMMENT '券商持仓股数',
+    `create_time` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '对账时间',
+    INDEX `idx_broker_reconcile_position_reconcile_id` (`reconcile_id`)
+    )ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -11,3 +11,71 @@ alter table entrust add `mode` INT NOT NULL DEFAULT 0 COMMENT '委托方式:0用
 
 -- 买入卖出记录，根据entrust的is_delete标志筛选出;
 -- 禁止非超级管理员用户直到用户密码
+
+-- 密码hash及密码策略
+alter table users modify `password` VARCHAR(254) NOT NULL COMMENT '密码(bcrypt hash)';
+alter table sysparam add `password_min_len` INT NOT NULL DEFAULT 6 COMMENT '密码最小长度';
+alter table sysparam add `password_require_letter` BOOL NOT NULL DEFAULT FALSE COMMENT '密码必须包含字母';
+alter table sysparam add `password_require_digit` BOOL NOT NULL DEFAULT FALSE COMMENT '密码必须包含数字';
+alter table sysparam add `password_require_symbol` BOOL NOT NULL DEFAULT FALSE COMMENT '密码必须包含特殊字符';
+
+-- 非券商委托模拟撮合方式
+alter table sysparam add `match_mode` INT NOT NULL DEFAULT 1 COMMENT '模拟撮合方式:1最新价 2五档盘口 3成交量参与(VWAP)';
+alter table sysparam add `match_volume_pct` DECIMAL(6,5) NOT NULL DEFAULT 0.1 COMMENT '成交量参与撮合:可成交数量占区间成交量的比例';
+
+-- 条件单:支持条件买入、开盘买入
+alter table conditional_order add `entrust_bs` INT(2) NOT NULL DEFAULT 2 COMMENT '交易类型:1买入 2卖出';
+alter table conditional_order add `trade_date` INT(11) NOT NULL DEFAULT 0 COMMENT '生效交易日:买入条件单仅在该交易日有效,0表示长期有效';
+
+-- 目录模块读写权限:原有模块权限默认只读,与此前非管理员不可写一致
+alter table role_module add `can_write` BOOL NOT NULL DEFAULT FALSE COMMENT '是否有写权限';
+
+-- 提现、人工充值两人审批:达到复核金额须初审、复核两人审批,复核人不能是初审人
+alter table sysparam add `withdraw_review_amount` DECIMAL(15,2) NOT NULL DEFAULT 0 COMMENT '提现复核金额:达到该金额须第二人复核,0不复核';
+alter table sysparam add `recharge_review_amount` DECIMAL(15,2) NOT NULL DEFAULT 0 COMMENT '人工充值复核金额:达到该金额须第二人复核,0不复核';
+alter table transfer modify `status` INT(2) NOT NULL DEFAULT '0' COMMENT '状态:0预插入 1待审核 2成功 3失败 4待复核';
+alter table transfer add `reviewer` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '初审人';
+alter table transfer add `checker` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '复核人';
+alter table transfer add `reason` VARCHAR(255) NOT NULL DEFAULT '' COMMENT '驳回原因';
+
+-- 支付宝异步通知验签、主动查询:记录支付宝交易号
+alter table transfer add `trade_no` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '渠道交易号';
+
+-- 充值渠道:记录充值手续费
+alter table transfer add `fee` DECIMAL(15,2) NOT NULL DEFAULT 0 COMMENT '充值手续费';
+
+-- 提现风控:单笔、每日、每周限额,每日次数,充值后提现冷却期
+alter table sysparam add `withdraw_min_money` DECIMAL(15,2) NOT NULL DEFAULT 0 COMMENT '单笔最低提现金额';
+alter table sysparam add `withdraw_max_money` DECIMAL(15,2) NOT NULL DEFAULT 0 COMMENT '单笔最高提现金额,0不限制';
+alter table sysparam add `withdraw_day_money` DECIMAL(15,2) NOT NULL DEFAULT 0 COMMENT '每日累计提现金额上限,0不限制';
+alter table sysparam add `withdraw_day_count` INT(11) NOT NULL DEFAULT 0 COMMENT '每日提现次数上限,0不限制';
+alter table sysparam add `withdraw_week_money` DECIMAL(15,2) NOT NULL DEFAULT 0 COMMENT '每周累计提现金额上限,0不限制';
+alter table sysparam add `withdraw_cooldown_hours` INT(11) NOT NULL DEFAULT 0 COMMENT '充值后N小时内不能提现,0不限制';
+
+-- 实名认证状态:已填写姓名与身份证号的历史用户视为已认证
+alter table users add `verify_status` INT(2) NOT NULL DEFAULT 0 COMMENT '实名认证状态:0未认证 1已认证 2认证失败';
+update users set `verify_status` = 1 where `name` <> '' and `icc_id` <> '';
+
+-- 敏感字段加密存储:密文格式enc:v<密钥版本>:<AES密文>,加宽字段后执行 -migrate-secret 加密历史数据
+alter table users modify `icc_id` VARCHAR(255) NOT NULL DEFAULT '' COMMENT '身份证号码(加密)';
+alter table users modify `bank_number` VARCHAR(255) NOT NULL DEFAULT '' COMMENT '银行卡号码(加密)';
+alter table broker modify `trade_password` VARCHAR(255) NOT NULL DEFAULT '' COMMENT '交易密码(加密)';
+alter table broker modify `tx_password` VARCHAR(255) NOT NULL DEFAULT '' COMMENT '通讯密码(加密)';
+alter table transfer modify `bank_no` VARCHAR(255) NOT NULL DEFAULT '' COMMENT '提现银行卡号(加密)';
+
+-- 券商分配策略:系统默认策略,券商可单独设置策略及当日委托金额、单股集中度上限;券商委托记录分配依据
+alter table sysparam add `broker_route_strategy` VARCHAR(32) NOT NULL DEFAULT '' COMMENT '券商分配策略:priority优先级 least_loaded最少委托 round_robin轮询 proportional按资金比例 sticky用户固定券商';
+alter table broker add `route_strategy` VARCHAR(32) NOT NULL DEFAULT '' COMMENT '分配策略,为空使用系统参数';
+alter table broker add `day_turnover_cap` DECIMAL(15,2) NOT NULL DEFAULT 0 COMMENT '当日委托金额上限,0不限制';
+alter table broker add `stock_cap_ratio` DECIMAL(15,4) NOT NULL DEFAULT 0 COMMENT '单只股票持仓市值占总资产上限,0不限制';
+alter table broker_entrust add `route_strategy` VARCHAR(32) NOT NULL DEFAULT '' COMMENT '分配策略';
+alter table broker_entrust add `route_reason` VARCHAR(255) NOT NULL DEFAULT '' COMMENT '分配依据';
+
+-- 券商通道熔断:断开后未成交委托处理方式
+alter table sysparam add `broker_failover_mode` INT(2) NOT NULL DEFAULT 0 COMMENT '券商断开后未成交委托:0等待重连 1转模拟撮合';
+
+-- 券商委托增量查询:按券商查询未终态委托
+alter table broker_entrust add index idx_broker_entrust_broker_status(`broker_id`,`status`);
+
+-- 资金流水完成时间:充值后提现冷却期从入账时间起算
+alter table transfer add `finish_time` TIMESTAMP NULL DEFAULT NULL COMMENT '完成时间:充值入账、审批通过或驳回的时间';
@@ -0,0 +1,875 @@
+package fake
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"stock/api-gateway/model"
+	"stock/api-gateway/serr"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 内存数据表的增删改查,与dao包中MySQL实现的查询条件、更新字段及错误返回保持一致
+
+type contractTable struct {
+	s *Store
+}
+
+func (d *contractTable) UpdateContract(ctx context.Context, contract *model.Contract) error {
+	_, err := d.CreateContract(ctx, contract)
+	return err
+}
+
+func (d *contractTable) UpdateWithTx(tx *gorm.DB, contract *model.Contract) error {
+	_, err := d.CreateContract(context.Background(), contract)
+	return err
+}
+
+func (d *contractTable) CreateContract(ctx context.Context, contract *model.Contract) (*model.Contract, error) {
+	err := d.s.update(func(t *tables) error {
+		if contract.ID == 0 {
+			contract.ID = t.nextID()
+		}
+		t.contracts[contract.ID] = *contract
+		return nil
+	})
+	return contract, err
+}
+
+func (d *contractTable) GetContractByID(ctx context.Context, contractID int64) (*model.Contract, error) {
+	contract, ok := d.get(contractID)
+	if !ok {
+		return nil, serr.New(serr.ErrCodeBusinessFail, "合约不存在")
+	}
+	return contract, nil
+}
+
+func (d *contractTable) GetContractByIDWithTx(tx *gorm.DB, contractID int64) (*model.Contract, error) {
+	contract, ok := d.get(contractID)
+	if !ok {
+		return nil, errors.New("合约不存在")
+	}
+	return contract, nil
+}
+
+// GetContractByIDForUpdateWithTx 行锁模式下加锁后读取
+func (d *contractTable) GetContractByIDForUpdateWithTx(tx *gorm.DB, contractID int64) (*model.Contract, error) {
+	d.s.lockRow(tx, "contract", contractID)
+	return d.GetContractByIDWithTx(tx, contractID)
+}
+
+func (d *contractTable) get(contractID int64) (*model.Contract, bool) {
+	var contract *model.Contract
+	d.s.view(func(t *tables) {
+		if it, ok := t.contracts[contractID]; ok {
+			contract = &it
+		}
+	})
+	return contract, contract != nil
+}
+
+func (d *contractTable) GetEnableContractByUID(ctx context.Context, uid int64) (*model.Contract, error) {
+	list, _ := d.GetContractsByUID(ctx, uid)
+	for _, it := range list {
+		if it.Status == model.ContractStatusEnable {
+			return it, nil
+		}
+	}
+	return nil, serr.New(serr.ErrCodeContractNoFound, "请申请合约")
+}
+
+func (d *contractTable) UpdateContractValMoney(ctx context.Context, valMoney float64, contractID int64) error {
+	return d.s.update(func(t *tables) error {
+		if it, ok := t.contracts[contractID]; ok {
+			it.ValMoney = valMoney
+			t.contracts[contractID] = it
+		}
+		return nil
+	})
+}
+
+func (d *contractTable) GetContractsByUID(ctx context.Context, uid int64) ([]*model.Contract, error) {
+	list, _ := d.GetContracts(ctx)
+	result := make([]*model.Contract, 0)
+	for _, it := range list {
+		if it.UID == uid {
+			result = append(result, it)
+		}
+	}
+	return result, nil
+}
+
+func (d *contractTable) GetContracts(ctx context.Context) ([]*model.Contract, error) {
+	list := make([]*model.Contract, 0)
+	d.s.view(func(t *tables) {
+		for _, it := range t.contracts {
+			contract := it
+			list = append(list, &contract)
+		}
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+type positionTable struct {
+	s *Store
+}
+
+func (d *positionTable) find(match func(p *model.Position) bool) []*model.Position {
+	list := make([]*model.Position, 0)
+	d.s.view(func(t *tables) {
+		for _, it := range t.positions {
+			position := it
+			if match(&position) {
+				list = append(list, &position)
+			}
+		}
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+func (d *positionTable) take(match func(p *model.Position) bool) (*model.Position, error) {
+	list := d.find(match)
+	if len(list) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return list[0], nil
+}
+
+func (d *positionTable) GetPositionByContractID(ctx context.Context, contractID int64) ([]*model.Position, error) {
+	return d.find(func(p *model.Position) bool { return p.ContractID == contractID }), nil
+}
+
+func (d *positionTable) GetPositionByEntrustID(ctx context.Context, entrustID int64) (*model.Position, error) {
+	return d.take(func(p *model.Position) bool { return p.EntrustID == entrustID })
+}
+
+func (d *positionTable) GetPositions(ctx context.Context) ([]*model.Position, error) {
+	return d.find(func(p *model.Position) bool { return true }), nil
+}
+
+func (d *positionTable) CreateWithTx(tx *gorm.DB, position *model.Position) (*model.Position, error) {
+	err := d.s.update(func(t *tables) error {
+		if position.ID == 0 {
+			position.ID = t.nextID()
+		}
+		t.positions[position.ID] = *position
+		return nil
+	})
+	return position, err
+}
+
+func (d *positionTable) UpdateWithTx(tx *gorm.DB, position *model.Position) error {
+	return d.s.update(func(t *tables) error {
+		it, ok := t.positions[position.ID]
+		if !ok {
+			return nil
+		}
+		it.OrderTime = position.OrderTime
+		it.Price = position.Price
+		it.Amount = position.Amount
+		it.FreezeAmount = position.FreezeAmount
+		it.Balance = position.Balance
+		t.positions[position.ID] = it
+		return nil
+	})
+}
+
+func (d *positionTable) Update(ctx context.Context, position *model.Position) error {
+	_, err := d.CreateWithTx(nil, position)
+	return err
+}
+
+func (d *positionTable) DeleteWithTx(tx *gorm.DB, position *model.Position) error {
+	return d.s.update(func(t *tables) error {
+		delete(t.positions, position.ID)
+		return nil
+	})
+}
+
+func (d *positionTable) UnFreezeAmount(ctx context.Context, contractID int64, code string, amount int64) error {
+	return d.FreezeAmountWithTx(nil, contractID, code, -amount)
+}
+
+func (d *positionTable) UnFreezeAmountWithTx(tx *gorm.DB, contractID int64, code string, amount int64) error {
+	return d.FreezeAmountWithTx(tx, contractID, code, -amount)
+}
+
+func (d *positionTable) GetContractPositionByCode(ctx context.Context, contractID int64, code string) (*model.Position, error) {
+	return d.take(func(p *model.Position) bool { return p.ContractID == contractID && p.StockCode == code })
+}
+
+func (d *positionTable) GetContractPositionByCodeWithTx(tx *gorm.DB, contractID int64, code string) (*model.Position, error) {
+	return d.GetContractPositionByCode(context.Background(), contractID, code)
+}
+
+func (d *positionTable) FreezeAmountWithTx(tx *gorm.DB, contractID int64, code string, amount int64) error {
+	return d.s.update(func(t *tables) error {
+		for id, it := range t.positions {
+			if it.ContractID == contractID && it.StockCode == code {
+				it.FreezeAmount += amount
+				t.positions[id] = it
+			}
+		}
+		return nil
+	})
+}
+
+func (d *positionTable) GetPositionByID(ctx context.Context, positionID int64) (*model.Position, error) {
+	return d.take(func(p *model.Position) bool { return p.ID == positionID })
+}
+
+type entrustTable struct {
+	s *Store
+}
+
+func (d *entrustTable) find(match func(e *model.Entrust) bool) []*model.Entrust {
+	list := make([]*model.Entrust, 0)
+	d.s.view(func(t *tables) {
+		for _, it := range t.entrusts {
+			entrust := it
+			if match(&entrust) {
+				list = append(list, &entrust)
+			}
+		}
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+func (d *entrustTable) GetTodayEntrust(ctx context.Context, contractID int64) ([]*model.Entrust, error) {
+	return d.find(func(e *model.Entrust) bool { return e.ContractID == contractID && isToday(e.OrderTime) }), nil
+}
+
+func (d *entrustTable) GetEntrustByContractID(ctx context.Context, contractID int64) ([]*model.Entrust, error) {
+	return d.find(func(e *model.Entrust) bool { return e.ContractID == contractID }), nil
+}
+
+func (d *entrustTable) GetEntrustByID(ctx context.Context, entrustID int64) (*model.Entrust, error) {
+	list := d.find(func(e *model.Entrust) bool { return e.ID == entrustID })
+	if len(list) == 0 {
+		return nil, serr.New(serr.ErrCodeBusinessFail, "委托记录不存在")
+	}
+	return list[0], nil
+}
+
+func (d *entrustTable) CreateWithTx(tx *gorm.DB, entrust *model.Entrust) (*model.Entrust, error) {
+	return d.Create(context.Background(), entrust)
+}
+
+func (d *entrustTable) Create(ctx context.Context, entrust *model.Entrust) (*model.Entrust, error) {
+	err := d.s.update(func(t *tables) error {
+		if entrust.ID == 0 {
+			entrust.ID = t.nextID()
+		}
+		it := *entrust
+		it.BrokerEntrust = nil
+		it.Fill = nil
+		it.Reason = ""
+		t.entrusts[entrust.ID] = it
+		return nil
+	})
+	return entrust, err
+}
+
+func (d *entrustTable) Update(ctx context.Context, entrust *model.Entrust) error {
+	return d.s.update(func(t *tables) error {
+		it, ok := t.entrusts[entrust.ID]
+		if !ok {
+			return nil
+		}
+		it.Amount = entrust.Amount
+		it.Price = entrust.Price
+		it.Balance = entrust.Balance
+		it.Status = entrust.Status
+		it.PositionID = entrust.PositionID
+		it.Fee = entrust.Fee
+		it.IsBrokerEntrust = entrust.IsBrokerEntrust
+		it.Remark = entrust.Remark
+		t.entrusts[entrust.ID] = it
+		return nil
+	})
+}
+
+func (d *entrustTable) UpdateWithTx(tx *gorm.DB, entrust *model.Entrust) error {
+	return d.s.update(func(t *tables) error {
+		it, ok := t.entrusts[entrust.ID]
+		if !ok {
+			return nil
+		}
+		it.Amount = entrust.Amount
+		it.Price = entrust.Price
+		it.Balance = entrust.Balance
+		it.Status = entrust.Status
+		it.PositionID = entrust.PositionID
+		it.DealAmount = entrust.DealAmount
+		it.Fee = entrust.Fee
+		it.IsBrokerEntrust = entrust.IsBrokerEntrust
+		t.entrusts[entrust.ID] = it
+		return nil
+	})
+}
+
+func (d *entrustTable) GetTodayEntrusts(ctx context.Context) ([]*model.Entrust, error) {
+	return d.find(func(e *model.Entrust) bool { return isToday(e.OrderTime) }), nil
+}
+
+func (d *entrustTable) UpdateStatusWithTx(tx *gorm.DB, entrust *model.Entrust) error {
+	return d.s.update(func(t *tables) error {
+		if it, ok := t.entrusts[entrust.ID]; ok {
+			it.Status = entrust.Status
+			t.entrusts[entrust.ID] = it
+		}
+		return nil
+	})
+}
+
+type brokerEntrustTable struct {
+	s *Store
+}
+
+func (d *brokerEntrustTable) find(match func(e *model.BrokerEntrust) bool) []*model.BrokerEntrust {
+	list := make([]*model.BrokerEntrust, 0)
+	d.s.view(func(t *tables) {
+		for _, it := range t.brokerEntrusts {
+			entrust := it
+			if match(&entrust) {
+				list = append(list, &entrust)
+			}
+		}
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+func (d *brokerEntrustTable) MCreate(ctx context.Context, list []*model.BrokerEntrust) error {
+	return d.s.update(func(t *tables) error {
+		for _, it := range list {
+			if it.ID == 0 {
+				it.ID = t.nextID()
+			}
+			entrust := *it
+			entrust.Broker = nil
+			t.brokerEntrusts[it.ID] = entrust
+		}
+		return nil
+	})
+}
+
+func (d *brokerEntrustTable) MCreateWithTx(tx *gorm.DB, list []*model.BrokerEntrust) error {
+	return d.MCreate(context.Background(), list)
+}
+
+func (d *brokerEntrustTable) GetByEntrustID(ctx context.Context, entrustID int64) ([]*model.BrokerEntrust, error) {
+	return d.find(func(e *model.BrokerEntrust) bool { return e.EntrustID == entrustID }), nil
+}
+
+func (d *brokerEntrustTable) GetTodayEntrusts(ctx context.Context) ([]*model.BrokerEntrust, error) {
+	return d.find(func(e *model.BrokerEntrust) bool { return isToday(e.OrderTime) }), nil
+}
+
+func (d *brokerEntrustTable) GetOpenByBrokerID(ctx context.Context, brokerID int64) ([]*model.BrokerEntrust, error) {
+	return d.find(func(e *model.BrokerEntrust) bool {
+		return e.BrokerID == brokerID && !e.IsFinallyState() && isToday(e.OrderTime)
+	}), nil
+}
+
+func (d *brokerEntrustTable) GetDeals(ctx context.Context, brokerID int64, stockCode string, begin, end time.Time) ([]*model.BrokerEntrust, error) {
+	return d.find(func(e *model.BrokerEntrust) bool {
+		return e.BrokerID == brokerID && e.DealAmount > 0 && (stockCode == "" || e.StockCode == stockCode) &&
+			(begin.IsZero() || !e.OrderTime.Before(begin)) && (end.IsZero() || e.OrderTime.Before(end))
+	}), nil
+}
+
+type buyTable struct {
+	s *Store
+}
+
+func (d *buyTable) find(match func(b *model.Buy) bool) []*model.Buy {
+	list := make([]*model.Buy, 0)
+	d.s.view(func(t *tables) {
+		for _, it := range t.buys {
+			buy := it
+			if match(&buy) {
+				list = append(list, &buy)
+			}
+		}
+	})
+	return list
+}
+
+func (d *buyTable) GetBuyByPositionIDs(ctx context.Context, positionID []int64) ([]*model.Buy, error) {
+	return d.find(func(b *model.Buy) bool { return containsID(positionID, b.PositionID) }), nil
+}
+
+func (d *buyTable) CreateWithTx(tx *gorm.DB, buy *model.Buy) error {
+	return d.s.update(func(t *tables) error {
+		buy.ID = t.nextID()
+		t.buys = append(t.buys, *buy)
+		return nil
+	})
+}
+
+func (d *buyTable) GetByContractID(ctx context.Context, contractID int64) ([]*model.Buy, error) {
+	return d.find(func(b *model.Buy) bool { return b.ContractID == contractID }), nil
+}
+
+type sellTable struct {
+	s *Store
+}
+
+func (d *sellTable) find(match func(b *model.Sell) bool) []*model.Sell {
+	list := make([]*model.Sell, 0)
+	d.s.view(func(t *tables) {
+		for _, it := range t.sells {
+			sell := it
+			if match(&sell) {
+				list = append(list, &sell)
+			}
+		}
+	})
+	return list
+}
+
+func (d *sellTable) GetByPositionIDs(ctx context.Context, positionID []int64) ([]*model.Sell, error) {
+	return d.find(func(b *model.Sell) bool { return containsID(positionID, b.PositionID) }), nil
+}
+
+func (d *sellTable) CreateWithTx(tx *gorm.DB, sell *model.Sell) (*model.Sell, error) {
+	err := d.s.update(func(t *tables) error {
+		sell.ID = t.nextID()
+		t.sells = append(t.sells, *sell)
+		return nil
+	})
+	return sell, err
+}
+
+func (d *sellTable) GetByContractID(ctx context.Context, contractID int64) ([]*model.Sell, error) {
+	return d.find(func(b *model.Sell) bool { return b.ContractID == contractID }), nil
+}
+
+type contractFeeTable struct {
+	s *Store
+}
+
+func (d *contractFeeTable) GetContractFeeByID(ctx context.Context, contractID int64) ([]*model.ContractFee, error) {
+	return d.GetContractFeeByIDs(ctx, []int64{contractID})
+}
+
+func (d *contractFeeTable) GetContractFeeByIDs(ctx context.Context, contractIDs []int64) ([]*model.ContractFee, error) {
+	list := make([]*model.ContractFee, 0)
+	d.s.view(func(t *tables) {
+		for _, it := range t.fees {
+			fee := it
+			if containsID(contractIDs, fee.ContractID) {
+				list = append(list, &fee)
+			}
+		}
+	})
+	return list, nil
+}
+
+func (d *contractFeeTable) CreateWithTx(tx *gorm.DB, fee *model.ContractFee) error {
+	return d.Create(context.Background(), fee)
+}
+
+func (d *contractFeeTable) Create(ctx context.Context, fee *model.ContractFee) error {
+	return d.s.update(func(t *tables) error {
+		fee.ID = t.nextID()
+		t.fees = append(t.fees, *fee)
+		return nil
+	})
+}
+
+type sysTable struct {
+	s *Store
+}
+
+func (d *sysTable) GetSysParam(ctx context.Context) (*model.SysParam, error) {
+	var sys *model.SysParam
+	d.s.view(func(t *tables) {
+		if t.sys != nil {
+			c := *t.sys
+			sys = &c
+		}
+	})
+	if sys == nil {
+		return nil, serr.New(serr.ErrCodeBusinessFail, "系统参数错误")
+	}
+	return sys, nil
+}
+
+type userTable struct {
+	s *Store
+}
+
+func (d *userTable) GetUserByUID(ctx context.Context, uid int64) (*model.User, error) {
+	var user *model.User
+	d.s.view(func(t *tables) {
+		if it, ok := t.users[uid]; ok {
+			user = &it
+		}
+	})
+	if user == nil {
+		return nil, serr.New(serr.ErrCodeBusinessFail, "用户不存在")
+	}
+	return user, nil
+}
+
+// GetUserByUIDForUpdateWithTx 行锁模式下加锁后读取
+func (d *userTable) GetUserByUIDForUpdateWithTx(tx *gorm.DB, uid int64) (*model.User, error) {
+	d.s.lockRow(tx, "users", uid)
+	return d.GetUserByUID(context.Background(), uid)
+}
+
+func (d *userTable) CreateUser(ctx context.Context, user *model.User) error {
+	return d.s.update(func(t *tables) error {
+		if user.ID == 0 {
+			user.ID = t.nextID()
+		}
+		t.users[user.ID] = *user
+		return nil
+	})
+}
+
+func (d *userTable) UpdateUserWithTx(tx *gorm.DB, user *model.User) error {
+	return d.CreateUser(context.Background(), user)
+}
+
+func (d *userTable) UpdateCurrentContractID(ctx context.Context, uid, contractID int64) error {
+	return d.s.update(func(t *tables) error {
+		if it, ok := t.users[uid]; ok {
+			it.CurrentContractID = contractID
+			t.users[uid] = it
+		}
+		return nil
+	})
+}
+
+// UpdateVerify 只更新姓名、身份证号、认证状态,已认证的用户不更新
+func (d *userTable) UpdateVerify(ctx context.Context, user *model.User) error {
+	return d.s.update(func(t *tables) error {
+		it, ok := t.users[user.ID]
+		if !ok || it.Verified() {
+			return nil
+		}
+		it.Name = user.Name
+		it.ICCID = user.ICCID
+		it.VerifyStatus = user.VerifyStatus
+		t.users[user.ID] = it
+		return nil
+	})
+}
+
+func (d *userTable) GetUsers(ctx context.Context) ([]*model.User, error) {
+	list := make([]*model.User, 0)
+	d.s.view(func(t *tables) {
+		for _, it := range t.users {
+			user := it
+			list = append(list, &user)
+		}
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+type msgTable struct {
+	s *Store
+}
+
+func (d *msgTable) Create(ctx context.Context, msg *model.Msg) error {
+	return d.s.update(func(t *tables) error {
+		msg.ID = t.nextID()
+		t.msgs = append(t.msgs, *msg)
+		return nil
+	})
+}
+
+func (d *msgTable) CreateWithTx(tx *gorm.DB, msg *model.Msg) error {
+	return d.Create(context.Background(), msg)
+}
+
+type transferTable struct {
+	s *Store
+}
+
+func (d *transferTable) Create(ctx context.Context, transfer *model.Transfer) error {
+	return d.s.update(func(t *tables) error {
+		if transfer.ID > 0 {
+			for i, it := range t.transfers {
+				if it.ID == transfer.ID {
+					t.transfers[i] = *transfer
+					return nil
+				}
+			}
+		} else {
+			transfer.ID = t.nextID()
+		}
+		t.transfers = append(t.transfers, *transfer)
+		return nil
+	})
+}
+
+func (d *transferTable) CreateWithTx(tx *gorm.DB, transfer *model.Transfer) error {
+	return d.Create(context.Background(), transfer)
+}
+
+func (d *transferTable) GetByUid(ctx context.Context, uid int64) ([]*model.Transfer, error) {
+	list := make([]*model.Transfer, 0)
+	d.s.view(func(t *tables) {
+		for _, it := range t.transfers {
+			if it.UID == uid {
+				c := it
+				list = append(list, &c)
+			}
+		}
+	})
+	return list, nil
+}
+
+// GetByOrderNoForUpdateWithTx 行锁模式下加锁后重新读取
+func (d *transferTable) GetByOrderNoForUpdateWithTx(tx *gorm.DB, orderNo string) (*model.Transfer, error) {
+	transfer, err := d.getByOrderNo(orderNo)
+	if err != nil {
+		return nil, err
+	}
+	return d.GetByIDForUpdateWithTx(tx, transfer.ID)
+}
+
+func (d *transferTable) getByOrderNo(orderNo string) (*model.Transfer, error) {
+	var transfer *model.Transfer
+	d.s.view(func(t *tables) {
+		for _, it := range t.transfers {
+			if it.OrderNo == orderNo {
+				c := it
+				transfer = &c
+			}
+		}
+	})
+	if transfer == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return transfer, nil
+}
+
+// GetByIDForUpdateWithTx 行锁模式下加锁后读取
+func (d *transferTable) GetByIDForUpdateWithTx(tx *gorm.DB, id int64) (*model.Transfer, error) {
+	d.s.lockRow(tx, "transfer", id)
+	return d.GetByID(context.Background(), id)
+}
+
+func (d *transferTable) GetByID(ctx context.Context, id int64) (*model.Transfer, error) {
+	var transfer *model.Transfer
+	d.s.view(func(t *tables) {
+		for _, it := range t.transfers {
+			if it.ID == id {
+				c := it
+				transfer = &c
+			}
+		}
+	})
+	if transfer == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return transfer, nil
+}
+
+type hisPositionTable struct {
+	s *Store
+}
+
+func (d *hisPositionTable) GetYesterdayPositionByContractID(ctx context.Context, contractID int64) ([]*model.Position, error) {
+	list := make([]*model.Position, 0)
+	d.s.view(func(t *tables) {
+		for _, it := range t.hisPositions {
+			position := it
+			if position.ContractID == contractID && isYesterday(position.OrderTime) {
+				list = append(list, &position)
+			}
+		}
+	})
+	return list, nil
+}
+
+type dividendTable struct {
+	s *Store
+}
+
+func (d *dividendTable) find(match func(e *model.Dividend) bool) []*model.Dividend {
+	list := make([]*model.Dividend, 0)
+	d.s.view(func(t *tables) {
+		for _, it := range t.dividends {
+			dividend := it
+			if match(&dividend) {
+				list = append(list, &dividend)
+			}
+		}
+	})
+	return list
+}
+
+func (d *dividendTable) GetDividendByPositionIDs(ctx context.Context, positionID int64) ([]*model.Dividend, error) {
+	return d.find(func(e *model.Dividend) bool { return e.PositionID == positionID }), nil
+}
+
+func (d *dividendTable) GetByContractID(ctx context.Context, contractID int64) ([]*model.Dividend, error) {
+	return d.find(func(e *model.Dividend) bool { return e.ContractID == contractID }), nil
+}
+
+type stockDataTable struct {
+	s *Store
+}
+
+func (d *stockDataTable) GetStockDataByCode(ctx context.Context, code string) (*model.StockData, error) {
+	var stock *model.StockData
+	d.s.view(func(t *tables) {
+		if it, ok := t.stockData[code]; ok {
+			stock = &it
+		}
+	})
+	if stock == nil {
+		return nil, serr.New(serr.ErrCodeBusinessFail, "证券代码不存在")
+	}
+	return stock, nil
+}
+
+type reverseRepoTable struct {
+	s *Store
+}
+
+func (d *reverseRepoTable) GetActiveByContractID(ctx context.Context, contractID int64) ([]*model.ReverseRepo, error) {
+	list := make([]*model.ReverseRepo, 0)
+	d.s.view(func(t *tables) {
+		for _, it := range t.repos {
+			repo := it
+			if repo.ContractID == contractID && repo.Source == model.ReverseRepoSourceContract &&
+				(repo.Status == model.ReverseRepoStatusUnDeal || repo.Status == model.ReverseRepoStatusDeal) {
+				list = append(list, &repo)
+			}
+		}
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+// containsID ids中是否包含id
+func containsID(ids []int64, id int64) bool {
+	for _, it := range ids {
+		if it == id {
+			return true
+		}
+	}
+	return false
+}
+
+type ledgerTable struct {
+	s *Store
+}
+
+func (d *ledgerTable) CreateWithTx(tx *gorm.DB, entries []*model.LedgerEntry) error {
+	return d.s.update(func(t *tables) error {
+		for _, it := range entries {
+			it.ID = t.nextID()
+			t.ledger = append(t.ledger, *it)
+		}
+		return nil
+	})
+}
+
+func (d *ledgerTable) GetBalances(ctx context.Context, accountType int64) (map[int64]float64, error) {
+	result := make(map[int64]float64)
+	d.s.view(func(t *tables) {
+		for _, it := range t.ledger {
+			if it.AccountType == accountType {
+				result[it.OwnerID] += it.Amount
+			}
+		}
+	})
+	return result, nil
+}
+
+type paymentChannelTable struct {
+	s *Store
+}
+
+func (d *paymentChannelTable) GetConfigs(ctx context.Context) (map[string]*model.PaymentChannelConfig, error) {
+	configs := make(map[string]*model.PaymentChannelConfig)
+	d.s.view(func(t *tables) {
+		for k, v := range t.payments {
+			c := v
+			configs[k] = &c
+		}
+	})
+	return configs, nil
+}
+
+type brokerTable struct {
+	s *Store
+}
+
+func (d *brokerTable) GetBrokers(ctx context.Context) ([]*model.Broker, error) {
+	list := make([]*model.Broker, 0)
+	d.s.view(func(t *tables) {
+		for _, it := range t.brokers {
+			broker := it
+			list = append(list, &broker)
+		}
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+type brokerReconcileTable struct {
+	s *Store
+}
+
+func (d *brokerReconcileTable) Create(ctx context.Context, report *model.BrokerReconcile, breaks []*model.BrokerReconcileBreak,
+	positions []*model.BrokerReconcilePosition) error {
+	return d.s.update(func(t *tables) error {
+		report.ID = t.nextID()
+		t.reconciles = append(t.reconciles, *report)
+		for _, it := range breaks {
+			it.ID = t.nextID()
+			it.ReconcileID = report.ID
+			t.breaks = append(t.breaks, *it)
+		}
+		for _, it := range positions {
+			it.ID = t.nextID()
+			it.ReconcileID = report.ID
+			t.reconcilePos = append(t.reconcilePos, *it)
+		}
+		return nil
+	})
+}
+
+func (d *brokerReconcileTable) GetPositions(ctx context.Context, reconcileID int64) ([]*model.BrokerReconcilePosition, error) {
+	list := make([]*model.BrokerReconcilePosition, 0)
+	d.s.view(func(t *tables) {
+		for _, it := range t.reconcilePos {
+			if it.ReconcileID == reconcileID {
+				p := it
+				list = append(list, &p)
+			}
+		}
+	})
+	return list, nil
+}
+
+func (d *brokerReconcileTable) GetLast(ctx context.Context, brokerID int64, billDate int32) (*model.BrokerReconcile, error) {
+	var last *model.BrokerReconcile
+	d.s.view(func(t *tables) {
+		for _, it := range t.reconciles {
+			if it.BrokerID != brokerID || it.BillDate >= billDate || it.Status == model.BrokerReconcileFail || !it.PostClose() {
+				continue
+			}
+			if last == nil || it.BillDate > last.BillDate || (it.BillDate == last.BillDate && it.ID > last.ID) {
+				report := it
+				last = &report
+			}
+		}
+	})
+	return last, nil
+}
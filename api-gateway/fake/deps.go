@@ -0,0 +1,229 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"stock/api-gateway/model"
+	"stock/api-gateway/serr"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Quote 行情源:返回Set设置的行情
+type Quote struct {
+	mu  sync.Mutex
+	qts map[string]*model.TencentQuote
+}
+
+// NewQuote 创建行情源
+func NewQuote() *Quote {
+	return &Quote{qts: make(map[string]*model.TencentQuote)}
+}
+
+// Set 设置股票行情
+func (q *Quote) Set(qt *model.TencentQuote) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	c := *qt
+	q.qts[qt.Code] = &c
+}
+
+// GetQuoteByTencent 查询行情,未设置行情的股票不返回
+func (q *Quote) GetQuoteByTencent(codes []string) (map[string]*model.TencentQuote, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	result := make(map[string]*model.TencentQuote)
+	for _, code := range codes {
+		if qt, ok := q.qts[code]; ok {
+			c := *qt
+			result[code] = &c
+		}
+	}
+	return result, nil
+}
+
+// cacheItem 缓存值
+type cacheItem struct {
+	value  string
+	expire time.Time
+}
+
+// Cache 内存缓存,与redis一致:过期时间小于等于0表示不过期
+type Cache struct {
+	mu    sync.Mutex
+	items map[string]*cacheItem
+}
+
+// NewCache 创建内存缓存
+func NewCache() *Cache {
+	return &Cache{items: make(map[string]*cacheItem)}
+}
+
+// get 查询未过期的缓存
+func (c *Cache) get(key string) (*cacheItem, bool) {
+	it, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	if !it.expire.IsZero() && time.Now().After(it.expire) {
+		delete(c.items, key)
+		return nil, false
+	}
+	return it, true
+}
+
+// set 写入缓存
+func (c *Cache) set(key string, value interface{}, expiration time.Duration) {
+	it := &cacheItem{value: fmt.Sprint(value)}
+	if expiration > 0 {
+		it.expire = time.Now().Add(expiration)
+	}
+	c.items[key] = it
+}
+
+func (c *Cache) Get(ctx context.Context, key string) *redis.StringCmd {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if it, ok := c.get(key); ok {
+		return redis.NewStringResult(it.value, nil)
+	}
+	return redis.NewStringResult("", redis.Nil)
+}
+
+func (c *Cache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(key, value, expiration)
+	return redis.NewStatusResult("OK", nil)
+}
+
+func (c *Cache) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.get(key); ok {
+		return redis.NewBoolResult(false, nil)
+	}
+	c.set(key, value, expiration)
+	return redis.NewBoolResult(true, nil)
+}
+
+func (c *Cache) Exists(ctx context.Context, keys ...string) *redis.IntCmd {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var n int64
+	for _, key := range keys {
+		if _, ok := c.get(key); ok {
+			n++
+		}
+	}
+	return redis.NewIntResult(n, nil)
+}
+
+func (c *Cache) Del(ctx context.Context, keys ...string) *redis.IntCmd {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var n int64
+	for _, key := range keys {
+		if _, ok := c.get(key); ok {
+			delete(c.items, key)
+			n++
+		}
+	}
+	return redis.NewIntResult(n, nil)
+}
+
+// Sms 短信:只记录发送内容
+type Sms struct {
+	mu   sync.Mutex
+	Sent []string
+}
+
+// SendSms 记录短信
+func (s *Sms) SendSms(ctx context.Context, content string, phone string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Sent = append(s.Sent, fmt.Sprintf("%s:%s", phone, content))
+	return nil
+}
+
+// Identity 实名认证:Reject中的身份证号核验不一致,其余均一致
+type Identity struct {
+	mu      sync.Mutex
+	Reject  map[string]bool
+	Err     error
+	Checked []string
+}
+
+// Verify 记录核验的身份证号,Err不为空时模拟核验服务不可用
+func (i *Identity) Verify(ctx context.Context, name, idNo string) (bool, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.Checked = append(i.Checked, idNo)
+	if i.Err != nil {
+		return false, i.Err
+	}
+	return !i.Reject[idNo], nil
+}
+
+// OrderNo 订单号:按序号递增
+type OrderNo struct {
+	mu  sync.Mutex
+	seq int64
+}
+
+// NextOrderNo 生成订单号
+func (o *OrderNo) NextOrderNo() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.seq++
+	return fmt.Sprintf("T%d", o.seq)
+}
+
+// Broker 券商通道:无可用券商,委托、撤单均返回失败
+type Broker struct {
+}
+
+// GetBrokers 券商列表
+func (b *Broker) GetBrokers() []*model.Broker {
+	return make([]*model.Broker, 0)
+}
+
+// Entrust 券商委托
+func (b *Broker) Entrust(entrust *model.Entrust) error {
+	return serr.ErrBusiness("无可用券商")
+}
+
+// Withdraw 券商撤单
+func (b *Broker) Withdraw(entrust *model.BrokerEntrust, broker *model.Broker, entrustNo string) error {
+	return serr.ErrBusiness("无可用券商")
+}
+
+// Calendar 交易时间:由测试用例设置
+type Calendar struct {
+	EntrustTime bool // 是否委托时间
+	TradeTime   bool // 是否交易时间
+	TradeDate   bool // 是否交易日
+}
+
+// NewOpenCalendar 交易日盘中
+func NewOpenCalendar() *Calendar {
+	return &Calendar{EntrustTime: true, TradeTime: true, TradeDate: true}
+}
+
+func (c *Calendar) IsEntrustTime(ctx context.Context) bool {
+	return c.EntrustTime
+}
+
+func (c *Calendar) IsTradeTime(ctx context.Context) bool {
+	return c.TradeTime
+}
+
+func (c *Calendar) IsTradeDate(ctx context.Context) bool {
+	return c.TradeDate
+}
+
+func (c *Calendar) IsTradeDay(ctx context.Context, date time.Time) bool {
+	return c.TradeDate
+}
@@ -0,0 +1,118 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"stock/api-gateway/model"
+	"stock/api-gateway/serr"
+	"strconv"
+	"sync"
+)
+
+// PaymentChannel 模拟在线充值渠道:下单后由测试用例调用Pay、Close模拟用户支付或交易关闭
+type PaymentChannel struct {
+	mu      sync.Mutex
+	orders  map[string]*model.PaymentResult
+	refunds map[string]float64
+}
+
+// NewPaymentChannel 创建模拟充值渠道
+func NewPaymentChannel() *PaymentChannel {
+	return &PaymentChannel{
+		orders:  make(map[string]*model.PaymentResult),
+		refunds: make(map[string]float64),
+	}
+}
+
+// Code 渠道编码
+func (p *PaymentChannel) Code() string {
+	return "mock"
+}
+
+// Name 渠道名称
+func (p *PaymentChannel) Name() string {
+	return "模拟支付"
+}
+
+// Enabled 始终开启
+func (p *PaymentChannel) Enabled(sys *model.SysParam) bool {
+	return true
+}
+
+// CreateOrder 下单,返回模拟支付链接
+func (p *PaymentChannel) CreateOrder(ctx context.Context, transfer *model.Transfer) (*model.PaymentOrder, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.orders[transfer.OrderNo] = &model.PaymentResult{
+		Status:  model.PaymentStatusPending,
+		OrderNo: transfer.OrderNo,
+		Money:   transfer.Money,
+	}
+	return &model.PaymentOrder{
+		Status: model.TransferStatusPre,
+		Data:   map[string]interface{}{"url": fmt.Sprintf("mock://pay/%s", transfer.OrderNo)},
+	}, nil
+}
+
+// Pay 模拟用户支付,money为实际支付金额
+func (p *PaymentChannel) Pay(orderNo string, money float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if it, ok := p.orders[orderNo]; ok {
+		it.Status = model.PaymentStatusPaid
+		it.TradeNo = "M" + orderNo
+		it.Money = money
+	}
+}
+
+// Close 模拟渠道关闭交易
+func (p *PaymentChannel) Close(orderNo string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if it, ok := p.orders[orderNo]; ok {
+		it.Status = model.PaymentStatusClosed
+	}
+}
+
+// Query 查询支付结果
+func (p *PaymentChannel) Query(ctx context.Context, transfer *model.Transfer) (*model.PaymentResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	it, ok := p.orders[transfer.OrderNo]
+	if !ok {
+		return &model.PaymentResult{Status: model.PaymentStatusPending, OrderNo: transfer.OrderNo}, nil
+	}
+	c := *it
+	return &c, nil
+}
+
+// VerifyCallback 异步通知:表单参数order_no、notify_id,签名sign须为mock
+func (p *PaymentChannel) VerifyCallback(ctx context.Context, req *http.Request) (*model.PaymentResult, error) {
+	if err := req.ParseForm(); err != nil {
+		return nil, err
+	}
+	if req.Form.Get("sign") != "mock" {
+		return nil, serr.ErrBusiness("验签失败")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	it, ok := p.orders[req.Form.Get("order_no")]
+	if !ok {
+		return nil, serr.ErrBusiness("订单不存在")
+	}
+	c := *it
+	c.NotifyID = req.Form.Get("notify_id")
+	if money := req.Form.Get("money"); money != "" {
+		c.Money, _ = strconv.ParseFloat(money, 64)
+	}
+	return &c, nil
+}
+
+// Refund 退款
+func (p *PaymentChannel) Refund(ctx context.Context, transfer *model.Transfer, money float64, reason string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.refunds[transfer.OrderNo] += money
+	return nil
+}
@@ -0,0 +1,370 @@
+package fake
+
+import (
+	"fmt"
+	"stock/api-gateway/dao"
+	"stock/api-gateway/model"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// tables 内存数据表,按值保存记录,读写时复制,避免调用方持有的指针修改到表内数据
+type tables struct {
+	seq            int64
+	sys            *model.SysParam
+	users          map[int64]model.User
+	contracts      map[int64]model.Contract
+	positions      map[int64]model.Position
+	entrusts       map[int64]model.Entrust
+	brokerEntrusts map[int64]model.BrokerEntrust
+	buys           []model.Buy
+	sells          []model.Sell
+	fees           []model.ContractFee
+	msgs           []model.Msg
+	transfers      []model.Transfer
+	hisPositions   []model.Position
+	dividends      []model.Dividend
+	stockData      map[string]model.StockData
+	repos          map[int64]model.ReverseRepo
+	ledger         []model.LedgerEntry
+	payments       map[string]model.PaymentChannelConfig
+	brokers        map[int64]model.Broker
+	reconciles     []model.BrokerReconcile
+	breaks         []model.BrokerReconcileBreak
+	reconcilePos   []model.BrokerReconcilePosition
+}
+
+func newTables() *tables {
+	return &tables{
+		users:          make(map[int64]model.User),
+		contracts:      make(map[int64]model.Contract),
+		positions:      make(map[int64]model.Position),
+		entrusts:       make(map[int64]model.Entrust),
+		brokerEntrusts: make(map[int64]model.BrokerEntrust),
+		stockData:      make(map[string]model.StockData),
+		repos:          make(map[int64]model.ReverseRepo),
+		payments:       make(map[string]model.PaymentChannelConfig),
+		brokers:        make(map[int64]model.Broker),
+	}
+}
+
+// clone 复制全部数据表,用于事务回滚
+func (t *tables) clone() *tables {
+	c := newTables()
+	c.seq = t.seq
+	if t.sys != nil {
+		sys := *t.sys
+		c.sys = &sys
+	}
+	for k, v := range t.users {
+		c.users[k] = v
+	}
+	for k, v := range t.contracts {
+		c.contracts[k] = v
+	}
+	for k, v := range t.positions {
+		c.positions[k] = v
+	}
+	for k, v := range t.entrusts {
+		c.entrusts[k] = v
+	}
+	for k, v := range t.brokerEntrusts {
+		c.brokerEntrusts[k] = v
+	}
+	for k, v := range t.stockData {
+		c.stockData[k] = v
+	}
+	for k, v := range t.repos {
+		c.repos[k] = v
+	}
+	for k, v := range t.payments {
+		c.payments[k] = v
+	}
+	for k, v := range t.brokers {
+		c.brokers[k] = v
+	}
+	c.buys = append(c.buys, t.buys...)
+	c.sells = append(c.sells, t.sells...)
+	c.fees = append(c.fees, t.fees...)
+	c.msgs = append(c.msgs, t.msgs...)
+	c.transfers = append(c.transfers, t.transfers...)
+	c.hisPositions = append(c.hisPositions, t.hisPositions...)
+	c.dividends = append(c.dividends, t.dividends...)
+	c.ledger = append(c.ledger, t.ledger...)
+	c.reconciles = append(c.reconciles, t.reconciles...)
+	c.breaks = append(c.breaks, t.breaks...)
+	c.reconcilePos = append(c.reconcilePos, t.reconcilePos...)
+	return c
+}
+
+// nextID 自增主键
+func (t *tables) nextID() int64 {
+	t.seq++
+	return t.seq
+}
+
+// Store 内存版数据访问,实现dao.Store中的各个接口,用于无MySQL环境下的单元测试
+//
+// 事务通过开启时的全表快照实现:未提交即回滚时恢复快照。事务内的写入对其他读取立即可见;
+// 事务之间串行执行,相当于对全部数据表加锁。UseRowLock后事务并发执行,只有FOR UPDATE读取加行锁,
+// 用于验证并发场景下业务是否正确加锁。
+type Store struct {
+	mu       sync.Mutex
+	txMu     sync.Mutex
+	t        *tables
+	rowLock  bool
+	rowLocks map[string]*sync.Mutex
+}
+
+// NewStore 创建空的内存数据表
+func NewStore() *Store {
+	return &Store{t: newTables()}
+}
+
+// Dao 交易核心使用的数据访问集合
+func (s *Store) Dao() *dao.Store {
+	return &dao.Store{
+		Tx:              &transactor{store: s},
+		Contract:        &contractTable{s},
+		Position:        &positionTable{s},
+		Entrust:         &entrustTable{s},
+		BrokerEntrust:   &brokerEntrustTable{s},
+		Buy:             &buyTable{s},
+		Sell:            &sellTable{s},
+		ContractFee:     &contractFeeTable{s},
+		Sys:             &sysTable{s},
+		User:            &userTable{s},
+		Msg:             &msgTable{s},
+		Transfer:        &transferTable{s},
+		HisPosition:     &hisPositionTable{s},
+		Dividend:        &dividendTable{s},
+		StockData:       &stockDataTable{s},
+		ReverseRepo:     &reverseRepoTable{s},
+		Ledger:          &ledgerTable{s},
+		PaymentChannel:  &paymentChannelTable{s},
+		BrokerAccount:   &brokerTable{s},
+		BrokerReconcile: &brokerReconcileTable{s},
+	}
+}
+
+// UseRowLock 事务改为并发执行,FOR UPDATE读取对行加锁直到事务结束。
+// 并发事务无法按快照回滚,回滚不恢复数据,业务须在加锁读取并校验通过后再写入
+func (s *Store) UseRowLock() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rowLock = true
+	s.rowLocks = make(map[string]*sync.Mutex)
+}
+
+// SetSysParam 设置系统参数
+func (s *Store) SetSysParam(sys *model.SysParam) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := *sys
+	s.t.sys = &c
+}
+
+// SetPaymentConfig 设置充值渠道配置
+func (s *Store) SetPaymentConfig(config *model.PaymentChannelConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.t.payments[config.Code] = *config
+}
+
+// PutUser 写入用户,ID为0时自动分配
+func (s *Store) PutUser(user *model.User) *model.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if user.ID == 0 {
+		user.ID = s.t.nextID()
+	}
+	s.t.users[user.ID] = *user
+	return user
+}
+
+// PutContract 写入合约,ID为0时自动分配
+func (s *Store) PutContract(contract *model.Contract) *model.Contract {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if contract.ID == 0 {
+		contract.ID = s.t.nextID()
+	}
+	s.t.contracts[contract.ID] = *contract
+	return contract
+}
+
+// PutBroker 写入券商母账户,ID为0时自动分配
+func (s *Store) PutBroker(broker *model.Broker) *model.Broker {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if broker.ID == 0 {
+		broker.ID = s.t.nextID()
+	}
+	s.t.brokers[broker.ID] = *broker
+	return broker
+}
+
+// PutStockData 写入股票池
+func (s *Store) PutStockData(stock *model.StockData) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.t.stockData[stock.Code] = *stock
+}
+
+// PutReverseRepo 写入逆回购委托,ID为0时自动分配
+func (s *Store) PutReverseRepo(repo *model.ReverseRepo) *model.ReverseRepo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if repo.ID == 0 {
+		repo.ID = s.t.nextID()
+	}
+	s.t.repos[repo.ID] = *repo
+	return repo
+}
+
+// PutHisPosition 写入历史持仓
+func (s *Store) PutHisPosition(position *model.Position) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.t.hisPositions = append(s.t.hisPositions, *position)
+}
+
+// PutDividend 写入分红送股记录
+func (s *Store) PutDividend(dividend *model.Dividend) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if dividend.ID == 0 {
+		dividend.ID = s.t.nextID()
+	}
+	s.t.dividends = append(s.t.dividends, *dividend)
+}
+
+// PutTransfer 写入资金流水,ID为0时自动分配
+func (s *Store) PutTransfer(transfer *model.Transfer) *model.Transfer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if transfer.ID == 0 {
+		transfer.ID = s.t.nextID()
+	}
+	s.t.transfers = append(s.t.transfers, *transfer)
+	return transfer
+}
+
+// Msgs 用户消息
+func (s *Store) Msgs(uid int64) []*model.Msg {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]*model.Msg, 0)
+	for _, it := range s.t.msgs {
+		if it.UID == uid {
+			msg := it
+			list = append(list, &msg)
+		}
+	}
+	return list
+}
+
+// Transfers 用户资金流水
+func (s *Store) Transfers(uid int64) []*model.Transfer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]*model.Transfer, 0)
+	for _, it := range s.t.transfers {
+		if it.UID == uid {
+			transfer := it
+			list = append(list, &transfer)
+		}
+	}
+	return list
+}
+
+// BrokerBreaks 券商对账差异
+func (s *Store) BrokerBreaks(reconcileID int64) []*model.BrokerReconcileBreak {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]*model.BrokerReconcileBreak, 0)
+	for _, it := range s.t.breaks {
+		if it.ReconcileID == reconcileID {
+			b := it
+			list = append(list, &b)
+		}
+	}
+	return list
+}
+
+// LedgerBalance 账簿中账户的余额
+func (s *Store) LedgerBalance(account model.LedgerAccount) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sum := 0.00
+	for _, it := range s.t.ledger {
+		if it.AccountType == account.Type && it.OwnerID == account.OwnerID {
+			sum += it.Amount
+		}
+	}
+	return sum
+}
+
+// isToday 是否为当天
+func isToday(t time.Time) bool {
+	return t.Format("2006-01-02") == time.Now().Format("2006-01-02")
+}
+
+// isYesterday 是否为前一天
+func isYesterday(t time.Time) bool {
+	return t.Format("2006-01-02") == time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+}
+
+// view 读取数据表
+func (s *Store) view(fn func(t *tables)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(s.t)
+}
+
+// update 写入数据表
+func (s *Store) update(fn func(t *tables) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fn(s.t)
+}
+
+// lockRow 行锁模式下事务对行加锁,同一事务重复加锁无效果;串行模式下事务已互斥,无需加锁
+func (s *Store) lockRow(tx *gorm.DB, table string, id int64) {
+	pool, ok := tx.Statement.ConnPool.(*txPool)
+	if !ok || pool.snapshot != nil {
+		return
+	}
+	key := fmt.Sprintf("%s:%d", table, id)
+	if pool.held[key] {
+		return
+	}
+	s.mu.Lock()
+	mu, ok := s.rowLocks[key]
+	if !ok {
+		mu = &sync.Mutex{}
+		s.rowLocks[key] = mu
+	}
+	s.mu.Unlock()
+	mu.Lock()
+	pool.held[key] = true
+	pool.locks = append(pool.locks, mu)
+	// 加锁后让出执行,放大并发事务在读取与写入之间的交错窗口
+	time.Sleep(time.Millisecond)
+}
+
+// snapshot 事务开启时的快照
+func (s *Store) snapshot() *tables {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.t.clone()
+}
+
+// restore 事务回滚,恢复快照
+func (s *Store) restore(t *tables) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.t = t
+}
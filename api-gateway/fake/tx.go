@@ -0,0 +1,153 @@
+package fake
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// errNoSQL 内存数据表不执行SQL,事务句柄只用于Commit/Rollback
+var errNoSQL = errors.New("fake: 内存数据表不支持执行SQL")
+
+// transactor 实现dao.Transactor:开启事务时保存快照,未提交时回滚到快照
+type transactor struct {
+	store *Store
+	once  sync.Once
+	db    *gorm.DB
+}
+
+// Begin 开启事务
+func (t *transactor) Begin(ctx context.Context) *gorm.DB {
+	t.once.Do(func() {
+		db, err := gorm.Open(&dialector{store: t.store}, &gorm.Config{
+			SkipDefaultTransaction: true,
+			Logger:                 logger.Default.LogMode(logger.Silent),
+		})
+		if err != nil {
+			panic(err)
+		}
+		t.db = db
+	})
+	return t.db.WithContext(ctx).Begin()
+}
+
+// dialector 空的gorm方言,连接池为内存数据表
+type dialector struct {
+	store *Store
+}
+
+func (d *dialector) Name() string {
+	return "fake"
+}
+
+func (d *dialector) Initialize(db *gorm.DB) error {
+	db.ConnPool = &connPool{store: d.store}
+	return nil
+}
+
+func (d *dialector) Migrator(db *gorm.DB) gorm.Migrator {
+	return nil
+}
+
+func (d *dialector) DataTypeOf(*schema.Field) string {
+	return ""
+}
+
+func (d *dialector) DefaultValueOf(*schema.Field) clause.Expression {
+	return clause.Expr{}
+}
+
+func (d *dialector) BindVarTo(writer clause.Writer, stmt *gorm.Statement, v interface{}) {
+	writer.WriteByte('?')
+}
+
+func (d *dialector) QuoteTo(writer clause.Writer, str string) {
+	writer.WriteString(str)
+}
+
+func (d *dialector) Explain(sql string, vars ...interface{}) string {
+	return sql
+}
+
+// connPool 连接池:只支持开启事务
+type connPool struct {
+	store *Store
+}
+
+func (p *connPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return nil, errNoSQL
+}
+
+func (p *connPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return nil, errNoSQL
+}
+
+func (p *connPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return nil, errNoSQL
+}
+
+func (p *connPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return nil
+}
+
+// BeginTx 开启事务:串行模式下等待其他事务结束后保存当前数据快照,行锁模式下直接开启
+func (p *connPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	p.store.mu.Lock()
+	rowLock := p.store.rowLock
+	p.store.mu.Unlock()
+	if rowLock {
+		return &txPool{connPool: p, held: make(map[string]bool)}, nil
+	}
+	p.store.txMu.Lock()
+	return &txPool{connPool: p, snapshot: p.store.snapshot()}, nil
+}
+
+// txPool 事务,行锁模式下snapshot为nil
+type txPool struct {
+	*connPool
+	snapshot *tables
+	held     map[string]bool
+	locks    []*sync.Mutex
+	done     bool
+}
+
+// Commit 提交事务,丢弃快照
+func (p *txPool) Commit() error {
+	if p.done {
+		return sql.ErrTxDone
+	}
+	p.done = true
+	p.unlock()
+	return nil
+}
+
+// Rollback 回滚事务,恢复快照;已提交的事务回滚无效果
+func (p *txPool) Rollback() error {
+	if p.done {
+		return nil
+	}
+	p.done = true
+	if p.snapshot != nil {
+		p.store.restore(p.snapshot)
+	}
+	p.unlock()
+	return nil
+}
+
+// unlock 事务结束:串行模式下释放事务锁,行锁模式下释放全部行锁
+func (p *txPool) unlock() {
+	if p.snapshot != nil {
+		p.store.txMu.Unlock()
+		return
+	}
+	for _, it := range p.locks {
+		it.Unlock()
+	}
+	p.locks = nil
+}
@@ -29,7 +29,7 @@ require (
 	github.com/tealeg/xlsx v1.0.5
 	github.com/temoto/robotstxt v1.1.2 // indirect
 	github.com/tencentyun/cos-go-sdk-v5 v0.7.39
-	golang.org/x/crypto v0.0.0-20210616213533-5ff15b29337e // indirect
+	golang.org/x/crypto v0.0.0-20210616213533-5ff15b29337e
 	golang.org/x/net v0.0.0-20221004154528-8021a29435af // indirect
 	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c
 	google.golang.org/appengine v1.6.7 // indirect
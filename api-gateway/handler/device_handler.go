@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"stock/api-gateway/service"
+	"stock/api-gateway/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeviceHandler 设备
+type DeviceHandler struct {
+}
+
+// NewDeviceHandler 单例
+func NewDeviceHandler() *DeviceHandler {
+	return &DeviceHandler{}
+}
+
+// Register 注册handler
+func (h *DeviceHandler) Register(e *gin.Engine) {
+	// 设备注册:下发设备ID和签名密钥,该接口不验签
+	e.GET("/device/register", JSONWrapper(h.Create))
+}
+
+// Create 注册设备
+func (h *DeviceHandler) Create(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	device, err := service.DeviceServiceInstance().Register(ctx, c.Request.Form.Get("platform"))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"device_id": device.DeviceID,
+		"secret":    device.Secret.String(),
+	}, nil
+}
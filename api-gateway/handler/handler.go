@@ -18,11 +18,14 @@ var handlers = []Handler{
 	NewSearchHandler(),    // 搜索
 	NewMyHandler(),        // 我的
 	NewStockHandler(),     // 股票列表
+	NewDeviceHandler(),    // 设备
 
 }
 
 // Register 注册所有的API入口
 func Register(e *gin.Engine) {
+	// 从访问token中解析登录用户
+	e.Use(Auth)
 	for _, h := range handlers {
 		h.Register(e)
 	}
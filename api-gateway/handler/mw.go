@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"stock/api-gateway/service"
+	"stock/api-gateway/util"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ctxKeyUID       = "__UID"        // 当前登录用户ID
+	ctxKeySessionID = "__SESSION_ID" // 当前登录会话ID
+)
+
+// Auth 从访问token中解析登录用户,token无效时不拦截请求,由UserID决定是否需要登录
+func Auth(c *gin.Context) {
+	token := AccessToken(c)
+	if len(token) == 0 {
+		c.Next()
+		return
+	}
+	uid, sessionID, err := service.SessionServiceInstance().Verify(util.RPCContext(c), token)
+	if err == nil {
+		c.Set(ctxKeyUID, uid)
+		c.Set(ctxKeySessionID, sessionID)
+	}
+	c.Next()
+}
+
+// AccessToken 获取访问token:优先读取 Authorization: Bearer <token>,其次读取token参数
+func AccessToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+	}
+	return c.Request.Form.Get("token")
+}
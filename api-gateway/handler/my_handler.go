@@ -3,8 +3,10 @@ package handler
 import (
 	"bufio"
 	"io"
+	"net/http"
 	"os"
 	"stock/api-gateway/dao"
+	"stock/api-gateway/model"
 	"stock/api-gateway/serr"
 	"stock/api-gateway/service"
 	"stock/api-gateway/util"
@@ -38,8 +40,12 @@ func (h *MyHandler) Register(e *gin.Engine) {
 	e.GET("/my/recharge/get", JSONWrapper(h.GetRecharge))
 	// 充值-支付宝
 	e.GET("/my/recharge/alipay", PureJSONWrapper(h.RechargeAlipay))
+	// 充值下单
+	e.GET("/my/recharge/commit", JSONWrapper(h.RechargeCommit))
 	// 支付宝回调
-	e.POST("/alipay/callback", JSONWrapper(h.AliPayNotify))
+	e.POST("/alipay/callback", h.AliPayNotify)
+	// 充值渠道回调
+	e.POST("/payment/callback/:code", h.PaymentNotify)
 	// 银行卡充值,获取充值银行卡的账号信息
 	e.GET("/my/recharge/bank", JSONWrapper(h.RechargeBank))
 	// 银行卡充值,提交
@@ -149,11 +155,10 @@ func (h *MyHandler) RechargeQrcodeCommit(c *gin.Context) (interface{}, error) {
 	if money < 0.001 {
 		return nil, serr.ErrBusiness("转入金额错误")
 	}
-	orderNo, err := String(c, "order_no")
-	if err != nil {
+	if _, err := String(c, "order_no"); err != nil {
 		return nil, err
 	}
-	if err := service.MyServiceInstance().RechargeQrcodeCommit(ctx, uid, money, orderNo); err != nil {
+	if _, _, err := service.RechargeServiceInstance().Create(ctx, uid, "qrcode", money); err != nil {
 		return nil, err
 	}
 	return map[string]interface{}{
@@ -189,7 +194,7 @@ func (h *MyHandler) RechargeBankCommit(c *gin.Context) (interface{}, error) {
 	if money < 0.001 {
 		return nil, serr.ErrBusiness("转入金额错误")
 	}
-	if err := service.MyServiceInstance().RechargeBankCommit(ctx, uid, money); err != nil {
+	if _, _, err := service.RechargeServiceInstance().Create(ctx, uid, "bank", money); err != nil {
 		return nil, err
 	}
 	return map[string]interface{}{
@@ -211,26 +216,14 @@ func (h *MyHandler) RechargeBank(c *gin.Context) (interface{}, error) {
 	}, nil
 }
 
-func (h *MyHandler) AliPayNotify(c *gin.Context) (interface{}, error) {
-	ctx := util.RPCContext(c)
-	type request struct {
-		AppID       string  `form:"app_id" json:"app_id"`
-		TradeNo     string  `form:"out_trade_no" json:"out_trade_no"`
-		Buyer       string  `form:"buyer_logon_id" json:"buyer_logon_id"`
-		TradeStatus string  `form:"trade_status" json:"trade_status"`
-		TotalAmount float64 `form:"total_amount" json:"total_amount"`
-	}
-	var req request
-	if err := c.Bind(&req); err != nil {
-		log.Errorf("绑定参数失败:%+v", err)
-		return nil, err
-	}
-	log.Infof("%+v", req)
-	if req.TradeStatus != "TRADE_SUCCESS" {
-		return nil, nil
-	}
-	service.AlipayServiceInstance().AliPayNotify(ctx, req.TradeNo, req.Buyer, req.TotalAmount)
-	return "success", nil
+// AliPayNotify 支付宝异步通知,处理成功返回success,否则返回fail由支付宝重试
+func (h *MyHandler) AliPayNotify(c *gin.Context) {
+	if err := service.RechargeServiceInstance().Notify(util.RPCContext(c), "alipay", c.Request); err != nil {
+		log.Errorf("处理支付宝通知失败:%+v", err)
+		c.String(http.StatusOK, "fail")
+		return
+	}
+	c.String(http.StatusOK, "success")
 }
 
 func (h *MyHandler) RechargeAlipay(c *gin.Context) (interface{}, error) {
@@ -243,28 +236,67 @@ func (h *MyHandler) RechargeAlipay(c *gin.Context) (interface{}, error) {
 	if money < 0.01 {
 		return nil, serr.ErrBusiness("转入金额错误")
 	}
-	url, err := service.MyServiceInstance().RechargeAlipay(ctx, uid, money)
+	_, order, err := service.RechargeServiceInstance().Create(ctx, uid, "alipay", money)
 	if err != nil {
 		return nil, err
 	}
-	return map[string]interface{}{
-		"url": url,
-	}, nil
+	return order.Data, nil
 }
 
+// GetRecharge 充值页面初始化:已开启的充值渠道及各渠道金额限制、手续费规则
 func (h *MyHandler) GetRecharge(c *gin.Context) (interface{}, error) {
 	ctx := util.RPCContext(c)
 	sys, err := dao.SysDaoInstance().GetSysParam(ctx)
 	if err != nil {
 		return nil, err
 	}
+	channels, err := service.RechargeServiceInstance().Channels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"bank":     sys.BankChannel,
+		"alipay":   sys.AlipayChannel,
+		"qrcode":   sys.QrcodeChannel,
+		"channels": channels,
+	}, nil
+}
+
+// RechargeCommit 按渠道充值下单,返回订单号、手续费及渠道支付信息
+func (h *MyHandler) RechargeCommit(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	uid, err := UserID(c)
+	if err != nil {
+		return nil, err
+	}
+	code, err := StringWithException(c, "channel", "请选择充值渠道")
+	if err != nil {
+		return nil, err
+	}
+	money, _ := Float64(c, "money")
+	transfer, order, err := service.RechargeServiceInstance().Create(ctx, uid, code, money)
+	if err != nil {
+		return nil, err
+	}
 	return map[string]interface{}{
-		"bank":   sys.BankChannel,
-		"alipay": sys.AlipayChannel,
-		"qrcode": sys.QrcodeChannel,
+		"order_no": transfer.OrderNo,
+		"status":   model.TransferStatusMap[transfer.Status],
+		"money":    transfer.Money,
+		"fee":      transfer.Fee,
+		"data":     order.Data,
 	}, nil
 }
 
+// PaymentNotify 充值渠道异步通知,处理成功返回success,否则返回fail由渠道重试
+func (h *MyHandler) PaymentNotify(c *gin.Context) {
+	if err := service.RechargeServiceInstance().Notify(util.RPCContext(c), c.Param("code"), c.Request); err != nil {
+		log.Errorf("处理充值通知失败:%+v", err)
+		c.String(http.StatusOK, "fail")
+		return
+	}
+	c.String(http.StatusOK, "success")
+}
+
 // Balance 资金明细
 func (h *MyHandler) Balance(c *gin.Context) (interface{}, error) {
 	ctx := util.RPCContext(c)
@@ -293,7 +325,7 @@ func (h *MyHandler) Authentication(c *gin.Context) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
-	if err := service.MyServiceInstance().Authentication(ctx, uid, name, idNo); err != nil {
+	if err := service.IdentityServiceInstance().Verify(ctx, uid, name, idNo); err != nil {
 		return nil, err
 	}
 	return map[string]interface{}{
@@ -312,8 +344,9 @@ func (h *MyHandler) GetAuthentication(c *gin.Context) (interface{}, error) {
 		return nil, err
 	}
 	return map[string]interface{}{
-		"name":  user.Name,
-		"id_no": user.ICCID,
+		"name":          user.Name,
+		"id_no":         util.MaskIDCard(user.ICCID.String()),
+		"verify_status": user.VerifyStatus,
 	}, nil
 }
 
@@ -113,10 +113,10 @@ func Int64WithDefault(c *gin.Context, key string, defaultValue int64) int64 {
 	return value
 }
 
-// UserID 获取userID
+// UserID 获取userID,用户ID只能由Auth中间件从访问token中解析得到
 func UserID(c *gin.Context) (int64, error) {
-	uid, err := Int64(c, "uid")
-	if err != nil {
+	uid := c.GetInt64(ctxKeyUID)
+	if uid == 0 {
 		return 0, serr.New(serr.ErrCodeNoLogin, "请登录")
 	}
 	// 检查用户是否允许交易
@@ -134,6 +134,15 @@ func UserID(c *gin.Context) (int64, error) {
 	return uid, nil
 }
 
+// SessionID 获取当前登录会话ID
+func SessionID(c *gin.Context) (string, error) {
+	sessionID := c.GetString(ctxKeySessionID)
+	if len(sessionID) == 0 {
+		return "", serr.New(serr.ErrCodeNoLogin, "请登录")
+	}
+	return sessionID, nil
+}
+
 // PositionID 获取委托编号
 func PositionID(c *gin.Context) (int64, error) {
 	v, err := Int64(c, "position_id")
@@ -175,10 +184,18 @@ func ContractID(c *gin.Context) (int64, error) {
 	if err != nil {
 		return 0, serr.ErrBusiness("合约不存在")
 	}
-	_, err = dao.ContractDaoInstance().GetContractByID(util.RPCContext(c), value)
+	uid, err := UserID(c)
 	if err != nil {
 		return 0, err
 	}
+	contract, err := dao.ContractDaoInstance().GetContractByID(util.RPCContext(c), value)
+	if err != nil {
+		return 0, err
+	}
+	// 只能操作自己的合约
+	if contract.UID != uid {
+		return 0, serr.ErrBusiness("合约不存在")
+	}
 	return value, nil
 }
 
@@ -188,6 +205,18 @@ func EntrustID(c *gin.Context) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
+	uid, err := UserID(c)
+	if err != nil {
+		return 0, err
+	}
+	entrust, err := dao.EntrustDaoInstance().GetEntrustByID(util.RPCContext(c), value)
+	if err != nil {
+		return 0, err
+	}
+	// 只能操作自己的委托
+	if entrust.UID != uid {
+		return 0, serr.ErrBusiness("委托不存在")
+	}
 	return value, nil
 }
 
@@ -49,6 +49,100 @@ func (s *TradeHandler) Register(e *gin.Engine) {
 	e.GET("/trade/entrust/list", JSONWrapper(s.GetEntrustList))
 	// 查询-已清仓股票
 	e.GET("/trade/sell_out", JSONWrapper(s.GetSellOut))
+	// 条件单-创建
+	e.GET("/trade/condition/create", JSONWrapper(s.CreateCondition))
+	// 条件单-查询
+	e.GET("/trade/condition/list", JSONWrapper(s.ConditionList))
+	// 条件单-取消
+	e.GET("/trade/condition/cancel", JSONWrapper(s.CancelCondition))
+	// 国债逆回购-品种及利率
+	e.GET("/trade/repo/products", JSONWrapper(s.RepoProducts))
+	// 国债逆回购-融出
+	e.GET("/trade/repo/lend", JSONWrapper(s.RepoLend))
+	// 国债逆回购-查询
+	e.GET("/trade/repo/list", JSONWrapper(s.RepoList))
+	// 国债逆回购-撤单
+	e.GET("/trade/repo/withdraw", JSONWrapper(s.RepoWithdraw))
+}
+
+// CreateCondition 条件单-创建:止损、止盈、跟踪止损、条件买入、开盘买入
+func (h *TradeHandler) CreateCondition(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	uid, err := UserID(c)
+	if err != nil {
+		return nil, err
+	}
+	contractID, err := ContractID(c)
+	if err != nil {
+		return nil, err
+	}
+	typ, err := Int64(c, "type") // 类型:1止损 2止盈 3跟踪止损 4条件买入 5开盘买入
+	if err != nil {
+		return nil, serr.ErrBusiness("条件单类型错误")
+	}
+	order := &model.ConditionalOrder{
+		UID:        uid,
+		ContractID: contractID,
+		Type:       typ,
+	}
+	switch typ {
+	case model.ConditionalOrderTypeBuyPrice, model.ConditionalOrderTypeBuyAtOpen:
+		// 买入条件单:按股票代码下单
+		if order.StockCode, err = StockCode(c); err != nil {
+			return nil, err
+		}
+	default:
+		// 卖出条件单:按持仓下单
+		if order.PositionID, err = PositionID(c); err != nil {
+			return nil, err
+		}
+	}
+	if order.TriggerPrice, err = Price(c); err != nil { // 止损价、止盈价、条件买入价
+		return nil, err
+	}
+	order.TrailPct, _ = Float64(c, "trail_pct")     // 跟踪止损回撤比例
+	order.Amount = Int64WithDefault(c, "amount", 0) // 数量:卖出为0表示全部可卖股数
+	if err := service.ConditionalOrderServiceInstance().Create(ctx, order); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"result": true,
+	}, nil
+}
+
+// ConditionList 条件单-查询
+func (h *TradeHandler) ConditionList(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	contractID, err := ContractID(c)
+	if err != nil {
+		return nil, err
+	}
+	list, err := service.ConditionalOrderServiceInstance().List(ctx, contractID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"list": list,
+	}, nil
+}
+
+// CancelCondition 条件单-取消
+func (h *TradeHandler) CancelCondition(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	uid, err := UserID(c)
+	if err != nil {
+		return nil, err
+	}
+	id, err := Int64(c, "id")
+	if err != nil {
+		return nil, err
+	}
+	if err := service.ConditionalOrderServiceInstance().Cancel(ctx, uid, id); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"result": true,
+	}, nil
 }
 
 // GetSellOut 查询-已清仓股票
@@ -349,3 +443,83 @@ func (s *TradeHandler) TradeDetail(c *gin.Context) (interface{}, error) {
 	}
 	return service.TradeServiceInstance().TradeDetail(ctx, entrustID)
 }
+
+// RepoProducts 国债逆回购-品种及利率
+func (h *TradeHandler) RepoProducts(c *gin.Context) (interface{}, error) {
+	list, err := service.ReverseRepoServiceInstance().Products(util.RPCContext(c))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"list": list,
+	}, nil
+}
+
+// RepoLend 国债逆回购-融出:传合约ID使用合约可用资金,否则使用钱包余额
+func (h *TradeHandler) RepoLend(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	uid, err := UserID(c)
+	if err != nil {
+		return nil, err
+	}
+	var contractID int64
+	if len(c.Request.Form.Get("contract_id")) > 0 {
+		if contractID, err = ContractID(c); err != nil {
+			return nil, err
+		}
+	}
+	code, err := String(c, "code")
+	if err != nil {
+		return nil, serr.ErrBusiness("请选择逆回购品种")
+	}
+	money, err := Float64(c, "money") // 融出金额
+	if err != nil {
+		return nil, serr.ErrBusiness("请输入融出金额")
+	}
+	entrustProp, err := EntrustProp(c) // 委托类型:1限价 0市价
+	if err != nil {
+		return nil, err
+	}
+	rate, _ := Float64(c, "rate") // 限价委托年化利率(%)
+	if err := service.ReverseRepoServiceInstance().Lend(ctx, uid, contractID, code, money, entrustProp, rate); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"result": true,
+	}, nil
+}
+
+// RepoList 国债逆回购-查询
+func (h *TradeHandler) RepoList(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	uid, err := UserID(c)
+	if err != nil {
+		return nil, err
+	}
+	list, err := service.ReverseRepoServiceInstance().List(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"list": list,
+	}, nil
+}
+
+// RepoWithdraw 国债逆回购-撤单
+func (h *TradeHandler) RepoWithdraw(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	uid, err := UserID(c)
+	if err != nil {
+		return nil, err
+	}
+	id, err := Int64(c, "id")
+	if err != nil {
+		return nil, err
+	}
+	if err := service.ReverseRepoServiceInstance().Withdraw(ctx, uid, id); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"result": true,
+	}, nil
+}
@@ -25,6 +25,12 @@ func (h *UserHandler) Register(e *gin.Engine) {
 	e.GET("/user/register", JSONWrapper(h.RegisterUser))
 	// 找回密码
 	e.GET("/user/update_password", JSONWrapper(h.UpdatePassword))
+	// 刷新token
+	e.GET("/user/refresh_token", JSONWrapper(h.RefreshToken))
+	// 退出登录
+	e.GET("/user/logout", JSONWrapper(h.Logout))
+	// 退出所有设备
+	e.GET("/user/logout_all", JSONWrapper(h.LogoutAll))
 }
 
 // Login 用户登录
@@ -49,8 +55,50 @@ func (h *UserHandler) Login(c *gin.Context) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
+	return service.SessionServiceInstance().Create(ctx, user.ID)
+}
+
+// RefreshToken 使用刷新token换取新的token
+func (h *UserHandler) RefreshToken(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	refreshToken, err := StringWithException(c, "refresh_token", "请登录")
+	if err != nil {
+		return nil, serr.New(serr.ErrCodeNoLogin, "请登录")
+	}
+	return service.SessionServiceInstance().Refresh(ctx, refreshToken)
+}
+
+// Logout 退出登录
+func (h *UserHandler) Logout(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	uid, err := UserID(c)
+	if err != nil {
+		return nil, err
+	}
+	sessionID, err := SessionID(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := service.SessionServiceInstance().Logout(ctx, uid, sessionID); err != nil {
+		return nil, err
+	}
 	return map[string]interface{}{
-		"uid": user.ID,
+		"result": true,
+	}, nil
+}
+
+// LogoutAll 退出所有设备
+func (h *UserHandler) LogoutAll(c *gin.Context) (interface{}, error) {
+	ctx := util.RPCContext(c)
+	uid, err := UserID(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := service.SessionServiceInstance().LogoutAll(ctx, uid); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"result": true,
 	}, nil
 }
 
@@ -76,9 +124,6 @@ func (h *UserHandler) RegisterUser(c *gin.Context) (interface{}, error) {
 	if len(req.Code) == 0 {
 		return nil, serr.ErrBusiness("请输入验证码")
 	}
-	if len(req.Password) < 6 {
-		return nil, serr.ErrBusiness("请重新设置密码，密码长度不低于6位")
-	}
 	if err := service.UserServiceInstance().RegisterUser(ctx, req.UserName, req.Password, req.Code, req.RegisterCode); err != nil {
 		return nil, err
 	}
@@ -109,9 +154,6 @@ func (h *UserHandler) UpdatePassword(c *gin.Context) (interface{}, error) {
 	if len(req.Code) == 0 {
 		return nil, serr.ErrBusiness("请输入验证码")
 	}
-	if len(req.Password) < 6 {
-		return nil, serr.ErrBusiness("新密码长度大于6位")
-	}
 	if err := service.UserServiceInstance().UpdatePassword(ctx, req.UserName, req.Password, req.Code); err != nil {
 		return nil, err
 	}
@@ -10,6 +10,7 @@ import (
 	"stock/api-gateway/mw"
 	"stock/api-gateway/service"
 	task "stock/api-gateway/task"
+	"stock/api-gateway/util"
 	"stock/common/env"
 	"stock/common/log"
 
@@ -19,18 +20,32 @@ import (
 func main() {
 	// 启动参数
 	var conf string
+	var migrateSecret bool
 	flag.StringVar(&conf, "conf", "conf/conf.json", "指定启动配置文件")
+	flag.BoolVar(&migrateSecret, "migrate-secret", false, "使用当前密钥加密敏感字段后退出")
 	flag.Parse()
 	// load env config
 	env.LoadGlobalEnv(conf)
 	ctx := context.Background()
+	// 敏感字段加密密钥
+	if err := util.InitFieldCipher(); err != nil {
+		log.Panic(ctx, "init field cipher err", err)
+	}
 	// init redis client
 	db.Init(ctx)
 	db.InitRedisClient()
 
+	if migrateSecret {
+		result, err := service.SecretServiceInstance().Migrate(ctx)
+		if err != nil {
+			log.Panic(ctx, "migrate secret err", err)
+		}
+		log.Infof("加密字段迁移完成:%+v", result)
+		return
+	}
+
 	engine := gin.Default()
-	engine.Use(mw.Recovery)
-	engine.Use(mw.ParseFormMiddleware)
+	mw.Use(engine, mw.NewSignVerifier(mw.SignModeFromEnv(), service.DeviceServiceInstance(), db.RedisClient()))
 	engine.GET("/ping", func(c *gin.Context) {
 		c.JSON(200, gin.H{
 			"message": "pong",
@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// AuditLog 后台操作审计日志表:只增不改
+type AuditLog struct {
+	ID         int64     `gorm:"column:id"`          // 主键ID
+	Operator   string    `gorm:"column:operator"`    // 操作员
+	IP         string    `gorm:"column:ip"`          // 操作员IP
+	Method     string    `gorm:"column:method"`      // 请求方法
+	Path       string    `gorm:"column:path"`        // 接口地址
+	Module     int64     `gorm:"column:module"`      // 所属目录模块
+	TargetType string    `gorm:"column:target_type"` // 操作对象类型:数据表名
+	TargetID   int64     `gorm:"column:target_id"`   // 操作对象ID
+	Params     string    `gorm:"column:params"`      // 请求参数(JSON,敏感字段已脱敏)
+	BeforeData string    `gorm:"column:before_data"` // 修改前快照(JSON)
+	AfterData  string    `gorm:"column:after_data"`  // 修改后快照(JSON)
+	Diff       string    `gorm:"column:diff"`        // 变化字段(JSON):字段名->[修改前,修改后]
+	Code       int64     `gorm:"column:code"`        // 返回码:0成功
+	Msg        string    `gorm:"column:msg"`         // 返回信息
+	CreateTime time.Time `gorm:"column:create_time"` // 操作时间
+}
+
+// AuditLogQuery 审计日志查询条件
+type AuditLogQuery struct {
+	Operator   string
+	Path       string
+	TargetType string
+	TargetID   int64
+	BeginTime  time.Time
+	EndTime    time.Time
+	Offset     int
+	Limit      int
+}
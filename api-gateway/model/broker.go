@@ -15,6 +15,51 @@ const (
 	TDXQueryTypeWithdraw     TDXQueryType = 4 // 可撤单
 )
 
+// 券商通道健康状态
+const (
+	BrokerHealthConnected = 1 // 正常
+	BrokerHealthDegraded  = 2 // 降级:查询失败,熔断不分配新委托
+	BrokerHealthDown      = 3 // 断开:按退避时间重连
+
+	BrokerFailoverWait     = 0 // 券商断开后未成交委托等待重连
+	BrokerFailoverSimulate = 1 // 券商断开后未成交委托转模拟撮合
+)
+
+// BrokerHealthMap 券商通道健康状态
+var BrokerHealthMap = map[int64]string{
+	BrokerHealthConnected: "正常",
+	BrokerHealthDegraded:  "降级",
+	BrokerHealthDown:      "断开",
+}
+
+// BrokerHealth 券商通道健康状态
+type BrokerHealth struct {
+	State      int64     // 状态:1正常 2降级 3断开
+	Failures   int64     // 连续查询失败次数
+	Retries    int64     // 连续重连失败次数
+	RetryTime  time.Time // 下次重连时间
+	LastError  string    // 最近一次错误
+	ChangeTime time.Time // 状态变更时间
+}
+
+// 券商分配策略
+const (
+	BrokerRoutePriority     = "priority"     // 按优先级:优先级高的券商资金足够则整笔申报,否则依次拆单
+	BrokerRouteLeastLoaded  = "least_loaded" // 当日委托金额最少的券商优先
+	BrokerRouteRoundRobin   = "round_robin"  // 轮询
+	BrokerRouteProportional = "proportional" // 按可用资金(卖出按可卖股数)比例拆单
+	BrokerRouteSticky       = "sticky"       // 用户固定在上次申报的券商
+)
+
+// ValidBrokerRoute 是否有效的分配策略,空表示未设置
+func ValidBrokerRoute(strategy string) bool {
+	switch strategy {
+	case "", BrokerRoutePriority, BrokerRouteLeastLoaded, BrokerRouteRoundRobin, BrokerRouteProportional, BrokerRouteSticky:
+		return true
+	}
+	return false
+}
+
 type Broker struct {
 	ClientID        int64             `gorm:"-"`                        // 券商连接成功id
 	ID              int64             `gorm:"column:id"`                // 主键
@@ -24,8 +69,8 @@ type Broker struct {
 	BranchNo        int64             `gorm:"column:branch_no"`         // 营业部代码
 	FundAccount     string            `gorm:"column:account"`           // 资金账号
 	TradeAccount    string            `gorm:"column:trade_account"`     // 交易账号
-	TradePassword   string            `gorm:"column:trade_password"`    // 交易密码
-	TxPassword      string            `gorm:"column:tx_password"`       // 通讯密码
+	TradePassword   Secret            `gorm:"column:trade_password"`    // 交易密码
+	TxPassword      Secret            `gorm:"column:tx_password"`       // 通讯密码
 	SHHolderAccount string            `gorm:"column:sh_holder_account"` // 上海股东代码
 	SZHolderAccount string            `gorm:"column:sz_holder_account"` // 深证股东代码
 	Priority        int64             `gorm:"column:priority"`          // 顺序,数字越大,优先级越高
@@ -33,9 +78,11 @@ type Broker struct {
 	BrokerName      string            `gorm:"column:broker_name"`       // 券商名称
 	ValMoney        float64           `gorm:"column:val_money"`         // 可用资金
 	Asset           float64           `gorm:"column:asset"`             // 总资产
+	RouteStrategy   string            `gorm:"column:route_strategy"`    // 分配策略:为空使用系统参数
+	DayTurnoverCap  float64           `gorm:"column:day_turnover_cap"`  // 当日委托金额上限:0不限制
+	StockCapRatio   float64           `gorm:"column:stock_cap_ratio"`   // 单只股票持仓市值占总资产上限:0不限制
 	CreateTime      time.Time         `gorm:"column:create_at"`         // 时间
 	BrokerPosition  []*BrokerPosition `gorm:"-"`                        // 券商持仓
-	IoTimes         int64             `gorm:"-"`                        // 超时次数
 }
 
 // BrokerPosition 券商持仓
@@ -26,6 +26,8 @@ type BrokerEntrust struct {
 	Fee              float64   `gorm:"column:fee"`                // 券商交易总手续费
 	BrokerEntrustNo  string    `gorm:"column:broker_entrust_no"`  // 券商委托编号
 	BrokerWithdrawNo string    `gorm:"column:broker_withdraw_no"` // 券商撤单编号
+	RouteStrategy    string    `gorm:"column:route_strategy"`     // 分配策略
+	RouteReason      string    `gorm:"column:route_reason"`       // 分配依据:候选券商及跳过原因,供事后复核
 	Broker           *Broker   `gorm:"-"`                         // 券商信息
 }
 
@@ -37,3 +39,27 @@ func (e *BrokerEntrust) IsFinallyState() bool {
 	}
 	return false
 }
+
+// 券商委托事件类型
+const (
+	BrokerOrderFill   = 1 // 成交:累计成交数量增加或全部成交
+	BrokerOrderCancel = 2 // 撤单:已撤、部撤
+	BrokerOrderReject = 3 // 废单
+)
+
+// BrokerOrderEventMap 券商委托事件类型
+var BrokerOrderEventMap = map[int64]string{
+	BrokerOrderFill:   "成交",
+	BrokerOrderCancel: "撤单",
+	BrokerOrderReject: "废单",
+}
+
+// BrokerOrderEvent 券商委托事件:柜台委托相对券商委托表的状态变化
+type BrokerOrderEvent struct {
+	Type       int64   // 事件类型
+	BrokerID   int64   // 券商ID
+	EntrustNo  string  // 券商委托编号
+	DealAmount int64   // 累计成交数量
+	DealPrice  float64 // 成交均价
+	Final      bool    // 柜台委托已终态
+}
@@ -0,0 +1,83 @@
+package model
+
+import "time"
+
+const (
+	BrokerReconcileMatch = 1 // 券商对账结果:一致
+	BrokerReconcileDiff  = 2 // 券商对账结果:有差异
+	BrokerReconcileFail  = 3 // 券商对账结果:查询券商失败
+
+	BrokerBreakPosition = 1 // 差异类型:持仓
+	BrokerBreakCash     = 2 // 差异类型:资金
+
+	BrokerBreakLow    = 1 // 差异级别:低
+	BrokerBreakMedium = 2 // 差异级别:中
+	BrokerBreakHigh   = 3 // 差异级别:高
+)
+
+var BrokerReconcileStatusMap = map[int64]string{
+	BrokerReconcileMatch: "一致",
+	BrokerReconcileDiff:  "有差异",
+	BrokerReconcileFail:  "查询失败",
+}
+
+var BrokerBreakTypeMap = map[int64]string{
+	BrokerBreakPosition: "持仓",
+	BrokerBreakCash:     "资金",
+}
+
+var BrokerBreakSeverityMap = map[int64]string{
+	BrokerBreakLow:    "低",
+	BrokerBreakMedium: "中",
+	BrokerBreakHigh:   "高",
+}
+
+// BrokerReconcile 券商对账表:每个券商每次对账一条
+type BrokerReconcile struct {
+	ID           int64     `gorm:"column:id"`            // 主键ID
+	BrokerID     int64     `gorm:"column:broker_id"`     // 券商ID
+	BillDate     int32     `gorm:"column:bill_date"`     // 对账日期
+	ExpectedCash float64   `gorm:"column:expected_cash"` // 推算资金余额
+	BrokerCash   float64   `gorm:"column:broker_cash"`   // 券商资金余额
+	BreakCount   int64     `gorm:"column:break_count"`   // 差异条数
+	Status       int64     `gorm:"column:status"`        // 对账结果:1一致 2有差异 3查询失败
+	Remark       string    `gorm:"column:remark"`        // 备注
+	Operator     string    `gorm:"column:operator"`      // 操作员,定时任务为空
+	CreateTime   time.Time `gorm:"column:create_time"`   // 对账时间
+}
+
+// BrokerReconcileCloseTime 收盘时间,此后的对账才作为下次对账的期初
+const BrokerReconcileCloseTime = "15:00:00"
+
+// PostClose 是否收盘后对账:盘中对账之后当日仍有成交,券商委托没有逐笔成交时间,无法拆分对账前后的成交
+func (r *BrokerReconcile) PostClose() bool {
+	return r.CreateTime.Format("15:04:05") >= BrokerReconcileCloseTime
+}
+
+// BrokerReconcilePosition 券商对账持仓表:对账时券商查询的持仓,作为下次对账推算持仓的期初
+type BrokerReconcilePosition struct {
+	ID          int64     `gorm:"column:id"`           // 主键ID
+	ReconcileID int64     `gorm:"column:reconcile_id"` // 券商对账表ID
+	BrokerID    int64     `gorm:"column:broker_id"`    // 券商ID
+	BillDate    int32     `gorm:"column:bill_date"`    // 对账日期
+	StockCode   string    `gorm:"column:stock_code"`   // 股票代码
+	StockName   string    `gorm:"column:stock_name"`   // 股票名称
+	Amount      int64     `gorm:"column:amount"`       // 券商持仓股数
+	CreateTime  time.Time `gorm:"column:create_time"`  // 对账时间
+}
+
+// BrokerReconcileBreak 券商对账差异表
+type BrokerReconcileBreak struct {
+	ID          int64     `gorm:"column:id"`           // 主键ID
+	ReconcileID int64     `gorm:"column:reconcile_id"` // 券商对账表ID
+	BrokerID    int64     `gorm:"column:broker_id"`    // 券商ID
+	BillDate    int32     `gorm:"column:bill_date"`    // 对账日期
+	Type        int64     `gorm:"column:type"`         // 差异类型:1持仓 2资金
+	StockCode   string    `gorm:"column:stock_code"`   // 股票代码,资金差异为空
+	StockName   string    `gorm:"column:stock_name"`   // 股票名称
+	Expected    float64   `gorm:"column:expected"`     // 按上次对账期初及之后券商委托成交推算的股数/资金
+	Actual      float64   `gorm:"column:actual"`       // 券商查询的股数/资金
+	Diff        float64   `gorm:"column:diff"`         // 差异:券商-推算
+	Severity    int64     `gorm:"column:severity"`     // 差异级别:1低 2中 3高
+	CreateTime  time.Time `gorm:"column:create_time"`  // 对账时间
+}
@@ -28,7 +28,10 @@ type RechargeResp struct {
 	Money    float64 `json:"money"`
 	OrderNo  string  `json:"order_no"`
 	Channel  string  `json:"channel"`
-	Status   int64   `json:"status"` // 1待审核 2成功 3失败
+	Status   int64   `json:"status"`   // 1待审核 2成功 3失败 4待复核
+	Reviewer string  `json:"reviewer"` // 初审人
+	Checker  string  `json:"checker"`  // 复核人
+	Reason   string  `json:"reason"`   // 驳回原因
 }
 
 type WithdrawResp struct {
@@ -40,7 +43,10 @@ type WithdrawResp struct {
 	Money    float64 `json:"money"`
 	BankName string  `json:"bank_name"`
 	BankNo   string  `json:"bank_no"`
-	Status   int64   `json:"status"` // 1待审核 2成功 3失败
+	Status   int64   `json:"status"`   // 1待审核 2成功 3失败 4待复核
+	Reviewer string  `json:"reviewer"` // 初审人
+	Checker  string  `json:"checker"`  // 复核人
+	Reason   string  `json:"reason"`   // 驳回原因
 }
 
 type TradeBuyResp struct {
@@ -185,6 +191,55 @@ type TradeEntrustResp struct {
 	Remark        string  `json:"remark"`
 }
 
+// TradeConditionResp 股票交易-条件单
+type TradeConditionResp struct {
+	ID           int64   `json:"id"`
+	UserName     string  `json:"user_name"`
+	Name         string  `json:"name"`
+	Agent        string  `json:"agent"`
+	Time         string  `json:"time"`
+	ContractID   int64   `json:"contract_id"`
+	ContractName string  `json:"contract_name"`
+	StockCode    string  `json:"stock_code"`
+	StockName    string  `json:"stock_name"`
+	Type         string  `json:"type"`          // 条件单类型
+	TriggerPrice float64 `json:"trigger_price"` // 触发价格
+	TrailPct     float64 `json:"trail_pct"`     // 跟踪止损回撤比例
+	HighPrice    float64 `json:"high_price"`    // 跟踪止损最高价
+	Amount       int64   `json:"amount"`        // 委托数量:卖出为0表示全部可卖股数
+	TradeDate    int32   `json:"trade_date"`    // 生效交易日:0表示长期有效
+	Status       string  `json:"status"`
+	UpdateTime   string  `json:"update_time"`
+	Remark       string  `json:"remark"`
+}
+
+// TradeRepoResp 股票交易-国债逆回购
+type TradeRepoResp struct {
+	ID              int64   `json:"id"`
+	UserName        string  `json:"user_name"`
+	Name            string  `json:"name"`
+	Agent           string  `json:"agent"`
+	Time            string  `json:"time"`
+	ContractID      int64   `json:"contract_id"`
+	ContractName    string  `json:"contract_name"`
+	Source          string  `json:"source"`           // 资金来源
+	Code            string  `json:"code"`             // 证券代码
+	RepoName        string  `json:"repo_name"`        // 证券简称
+	Tenor           int64   `json:"tenor"`            // 期限(天)
+	EntrustRate     float64 `json:"entrust_rate"`     // 委托年化利率(%)
+	Money           float64 `json:"money"`            // 融出金额
+	Rate            float64 `json:"rate"`             // 成交年化利率(%)
+	Fee             float64 `json:"fee"`              // 手续费
+	Interest        float64 `json:"interest"`         // 到期利息
+	AccruedInterest float64 `json:"accrued_interest"` // 已计提利息
+	InterestDays    int64   `json:"interest_days"`    // 计息天数
+	ExpireDate      int32   `json:"expire_date"`      // 到期交收日
+	AvailableDate   int32   `json:"available_date"`   // 资金可用日
+	Status          string  `json:"status"`
+	UpdateTime      string  `json:"update_time"`
+	Remark          string  `json:"remark"`
+}
+
 type CmsContractResp struct {
 	ID           int64   `json:"id"`            // 主键ID
 	ContractName string  `json:"contract_name"` // 合约名称
@@ -233,26 +288,36 @@ type CmsBrokerResp struct {
 	SHHolderAccount string  `form:"sh_holder_account" json:"sh_holder_account"`
 	SZHolderAccount string  `form:"sz_holder_account" json:"sz_holder_account"`
 	Status          bool    `form:"status" json:"status"`
-	Asset           float64 `json:"asset"` // 总资产
+	RouteStrategy   string  `form:"route_strategy" json:"route_strategy"`     // 分配策略:为空使用系统参数
+	DayTurnoverCap  float64 `form:"day_turnover_cap" json:"day_turnover_cap"` // 当日委托金额上限:0不限制
+	StockCapRatio   float64 `form:"stock_cap_ratio" json:"stock_cap_ratio"`   // 单只股票持仓市值占总资产上限:0不限制
+	Asset           float64 `json:"asset"`                                    // 总资产
 	ValMoney        float64 `json:"val_money"`
+	Health          string  `json:"health"`       // 通道状态:正常 降级 断开 未连接
+	HealthError     string  `json:"health_error"` // 最近一次错误
+	RetryTime       string  `json:"retry_time"`   // 下次重连时间
 }
 
 type CmsBrokerEntrustResp struct {
-	ID         int64   `json:"id"`          // 券商编号
-	BrokerName string  `json:"broker_name"` // 券商名称
-	UserName   string  `json:"user_name"`   // 用户账户
-	Name       string  `json:"name"`        // 用户姓名
-	Agent      string  `json:"agent"`       // 代理机构
-	Time       string  `json:"time"`        // 时间
-	StockCode  string  `json:"stock_code"`  // 股票代码
-	StockName  string  `json:"stock_name"`  // 股票名称
-	Price      float64 `json:"price"`       // 委托价格
-	Amount     int64   `json:"amount"`      // 委托数量
-	DealAmount int64   `json:"deal_amount"` // 成交数量
-	Status     string  `json:"status"`      // 状态
-	Type       string  `json:"type"`        // 交易类型
-	Prop       string  `json:"prop"`        // 委托类型
-	EntrustNo  string  `json:"entrust_no"`  // 券商委托编号
+	ID          int64   `json:"id"`           // 券商编号
+	BrokerName  string  `json:"broker_name"`  // 券商名称
+	UserName    string  `json:"user_name"`    // 用户账户
+	Name        string  `json:"name"`         // 用户姓名
+	Agent       string  `json:"agent"`        // 代理机构
+	Time        string  `json:"time"`         // 时间
+	StockCode   string  `json:"stock_code"`   // 股票代码
+	StockName   string  `json:"stock_name"`   // 股票名称
+	Price       float64 `json:"price"`        // 委托价格
+	Amount      int64   `json:"amount"`       // 委托数量
+	DealAmount  int64   `json:"deal_amount"`  // 成交数量
+	Status      string  `json:"status"`       // 状态
+	Type        string  `json:"type"`         // 交易类型
+	Prop        string  `json:"prop"`         // 委托类型
+	EntrustNo   string  `json:"entrust_no"`   // 券商委托编号
+	Route       string  `json:"route"`        // 分配策略
+	Reason      string  `json:"reason"`       // 分配依据
+	DealBalance float64 `json:"deal_balance"` // 成交金额
+	Fee         float64 `json:"fee"`          // 券商手续费
 }
 
 // CmsBrokerPositionResp 券商管理-持仓
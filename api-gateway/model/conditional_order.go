@@ -0,0 +1,119 @@
+package model
+
+import "time"
+
+const (
+	ConditionalOrderTypeStopLoss     = 1 // 条件单类型:止损,现价小于等于触发价卖出
+	ConditionalOrderTypeTakeProfit   = 2 // 条件单类型:止盈,现价大于等于触发价卖出
+	ConditionalOrderTypeTrailingStop = 3 // 条件单类型:跟踪止损,现价自监控期间最高价回撤达到回撤比例卖出
+	ConditionalOrderTypeBuyPrice     = 4 // 条件单类型:条件买入,现价小于等于触发价时以触发价限价买入
+	ConditionalOrderTypeBuyAtOpen    = 5 // 条件单类型:开盘买入,生效交易日开盘以市价买入
+
+	ConditionalOrderStatusActive    = 1 // 条件单状态:监控中
+	ConditionalOrderStatusTriggered = 2 // 条件单状态:已触发
+	ConditionalOrderStatusCancel    = 3 // 条件单状态:已取消
+	ConditionalOrderStatusFail      = 4 // 条件单状态:触发失败
+	ConditionalOrderStatusExpired   = 5 // 条件单状态:已过期(生效交易日收盘未触发)
+)
+
+var ConditionalOrderTypeMap = map[int64]string{
+	ConditionalOrderTypeStopLoss:     "止损",
+	ConditionalOrderTypeTakeProfit:   "止盈",
+	ConditionalOrderTypeTrailingStop: "跟踪止损",
+	ConditionalOrderTypeBuyPrice:     "条件买入",
+	ConditionalOrderTypeBuyAtOpen:    "开盘买入",
+}
+
+var ConditionalOrderStatusMap = map[int64]string{
+	ConditionalOrderStatusActive:    "监控中",
+	ConditionalOrderStatusTriggered: "已触发",
+	ConditionalOrderStatusCancel:    "已取消",
+	ConditionalOrderStatusFail:      "触发失败",
+	ConditionalOrderStatusExpired:   "已过期",
+}
+
+// ConditionalOrder 条件单表:持仓止损、止盈、跟踪止损,条件买入、开盘买入
+type ConditionalOrder struct {
+	ID           int64     `gorm:"column:id"`            // 主键ID
+	UID          int64     `gorm:"column:uid"`           // 用户ID
+	ContractID   int64     `gorm:"column:contract_id"`   // 合约编号
+	PositionID   int64     `gorm:"column:position_id"`   // 持仓表id(卖出条件单)
+	StockCode    string    `gorm:"column:stock_code"`    // 股票代码
+	StockName    string    `gorm:"column:stock_name"`    // 股票名称
+	EntrustBS    int64     `gorm:"column:entrust_bs"`    // 交易类型:1买入 2卖出
+	Type         int64     `gorm:"column:type"`          // 类型:1止损 2止盈 3跟踪止损 4条件买入 5开盘买入
+	TriggerPrice float64   `gorm:"column:trigger_price"` // 触发价格:止损价、止盈价、条件买入价
+	TrailPct     float64   `gorm:"column:trail_pct"`     // 跟踪止损:回撤比例
+	HighPrice    float64   `gorm:"column:high_price"`    // 跟踪止损:监控期间最高价
+	Amount       int64     `gorm:"column:amount"`        // 委托数量:卖出为0表示全部可卖股数
+	TradeDate    int32     `gorm:"column:trade_date"`    // 生效交易日:买入条件单仅在该交易日有效,0表示长期有效
+	Status       int64     `gorm:"column:status"`        // 状态:1监控中 2已触发 3已取消 4触发失败 5已过期
+	Remark       string    `gorm:"column:remark"`        // 备注:触发结果
+	CreateTime   time.Time `gorm:"column:create_time"`   // 创建时间
+	UpdateTime   time.Time `gorm:"column:update_time"`   // 更新时间
+}
+
+// TrailStopPrice 跟踪止损当前的止损价格
+func (o *ConditionalOrder) TrailStopPrice() float64 {
+	return o.HighPrice * (1 - o.TrailPct)
+}
+
+// IsTriggered 现价是否触发条件单
+func (o *ConditionalOrder) IsTriggered(price float64) bool {
+	if price < 0.01 {
+		return false
+	}
+	switch o.Type {
+	case ConditionalOrderTypeStopLoss, ConditionalOrderTypeBuyPrice:
+		return price <= o.TriggerPrice
+	case ConditionalOrderTypeTakeProfit:
+		return price >= o.TriggerPrice
+	case ConditionalOrderTypeTrailingStop:
+		return price <= o.TrailStopPrice()
+	case ConditionalOrderTypeBuyAtOpen:
+		return true
+	}
+	return false
+}
+
+// ConditionalOrderItem 条件单列表
+type ConditionalOrderItem struct {
+	ID           int64   `json:"id"`            // 条件单ID
+	PositionID   int64   `json:"position_id"`   // 持仓ID
+	StockCode    string  `json:"stock_code"`    // 股票代码
+	StockName    string  `json:"stock_name"`    // 股票名称
+	EntrustBS    int64   `json:"entrust_bs"`    // 交易类型:1买入 2卖出
+	Type         int64   `json:"type"`          // 类型:1止损 2止盈 3跟踪止损 4条件买入 5开盘买入
+	TypeDesc     string  `json:"type_desc"`     // 类型描述
+	TriggerPrice float64 `json:"trigger_price"` // 触发价格
+	TrailPct     float64 `json:"trail_pct"`     // 跟踪止损回撤比例
+	HighPrice    float64 `json:"high_price"`    // 跟踪止损监控期间最高价
+	Amount       int64   `json:"amount"`        // 委托数量:卖出为0表示全部可卖股数
+	TradeDate    int32   `json:"trade_date"`    // 生效交易日:0表示长期有效
+	Status       int64   `json:"status"`        // 状态:1监控中 2已触发 3已取消 4触发失败 5已过期
+	StatusDesc   string  `json:"status_desc"`   // 状态描述
+	Remark       string  `json:"remark"`        // 备注
+	Time         string  `json:"time"`          // 创建时间
+}
+
+// ConvertConditionalOrderItem 条件单列表
+func ConvertConditionalOrderItem(o *ConditionalOrder) *ConditionalOrderItem {
+	return &ConditionalOrderItem{
+		ID:           o.ID,
+		PositionID:   o.PositionID,
+		StockCode:    o.StockCode,
+		StockName:    o.StockName,
+		EntrustBS:    o.EntrustBS,
+		Type:         o.Type,
+		TypeDesc:     ConditionalOrderTypeMap[o.Type],
+		TriggerPrice: o.TriggerPrice,
+		TrailPct:     o.TrailPct,
+		HighPrice:    o.HighPrice,
+		Amount:       o.Amount,
+		TradeDate:    o.TradeDate,
+		Status:       o.Status,
+		StatusDesc:   ConditionalOrderStatusMap[o.Status],
+		Remark:       o.Remark,
+		Time:         o.CreateTime.Format("2006-01-02 15:04:05"),
+	}
+}
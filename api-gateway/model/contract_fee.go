@@ -6,14 +6,16 @@ const (
 	ContractFeeDirectionPay    int64 = 1 // 费用方向:支出
 	ContractFeeDirectionIncome int64 = 2 // 费用方向:收入
 
-	ContractFeeTypeBuy         int64 = 1 // 买入费用
-	ContractFeeTypeSell        int64 = 2 // 卖出费用
-	ContractFeeTypeInterest    int64 = 3 // 合约利息费用
-	ContractFeeTypeProfit      int64 = 4 // 卖出盈亏
-	ContractFeeTypeAppendMoney int64 = 5 // 合约追加保证金
-	ContractFeeTypeExpandMoney int64 = 6 // 合约扩大资金
-	ContractFeeTypeClose       int64 = 7 // 合约结算资金
-	ContractFeeTypeGetProfit   int64 = 8 // 合约提盈
+	ContractFeeTypeBuy         int64 = 1  // 买入费用
+	ContractFeeTypeSell        int64 = 2  // 卖出费用
+	ContractFeeTypeInterest    int64 = 3  // 合约利息费用
+	ContractFeeTypeProfit      int64 = 4  // 卖出盈亏
+	ContractFeeTypeAppendMoney int64 = 5  // 合约追加保证金
+	ContractFeeTypeExpandMoney int64 = 6  // 合约扩大资金
+	ContractFeeTypeClose       int64 = 7  // 合约结算资金
+	ContractFeeTypeGetProfit   int64 = 8  // 合约提盈
+	ContractFeeTypeRepoFee     int64 = 9  // 国债逆回购手续费
+	ContractFeeTypeRepoIncome  int64 = 10 // 国债逆回购利息
 )
 
 var ContractFeeTypeMap = map[int64]string{
@@ -25,6 +27,8 @@ var ContractFeeTypeMap = map[int64]string{
 	ContractFeeTypeExpandMoney: "扩大资金",
 	ContractFeeTypeClose:       "合约结算",
 	ContractFeeTypeGetProfit:   "合约提盈",
+	ContractFeeTypeRepoFee:     "逆回购手续费",
+	ContractFeeTypeRepoIncome:  "逆回购利息",
 }
 
 func ContractFeeType(feeType string) int64 {
@@ -48,5 +52,5 @@ type ContractFee struct {
 	Direction  int64     `json:"direction"`  // 方向:1支出 2:收入
 	Money      float64   `json:"money"`      // 金额
 	Detail     string    `json:"detail"`     // 明细
-	Type       int64     `json:"type"`       // 费用类型1:买入手续费 2:卖出手续费 3:合约利息 4:卖出盈亏 5:追加保证金 6:扩大资金 7:合约结算
+	Type       int64     `json:"type"`       // 费用类型1:买入手续费 2:卖出手续费 3:合约利息 4:卖出盈亏 5:追加保证金 6:扩大资金 7:合约结算 8:合约提盈 9:逆回购手续费 10:逆回购利息
 }
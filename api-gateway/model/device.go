@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// Device 设备表:客户端首次启动时注册,之后的请求使用设备密钥签名
+type Device struct {
+	ID         int64     `gorm:"column:id"`        // 主键ID
+	DeviceID   string    `gorm:"column:device_id"` // 设备ID
+	Secret     Secret    `gorm:"column:secret"`    // 签名密钥,hex编码
+	Platform   string    `gorm:"column:platform"`  // 客户端平台:ios android h5
+	CreateTime time.Time `gorm:"column:create_at"` // 注册时间
+}
@@ -10,6 +10,7 @@ import (
 const (
 	EntrustBsTypeBuy  = 1 // 委托类型:买入
 	EntrustBsTypeSell = 2 // 委托类型:卖出
+	EntrustBsTypeLend = 3 // 委托类型:融出(国债逆回购)
 
 	EntrustStatusTypeUnDeal               = 1 // 委托状态:未成交
 	EntrustStatusTypeDeal                 = 2 // 委托状态:全部成交
@@ -19,6 +20,7 @@ const (
 	EntrustStatusTypeReported             = 6 // 委托状态:已申报,未成交
 	EntrustStatusTypePartDeal             = 7 // 部分成交
 	EntrustStatusTypeCancel               = 8 // 委托状态:废单
+	EntrustStatusTypeFailoverCancel       = 9 // 券商委托状态:通道断开转模拟撮合,等待重连后撤单
 
 	EntrustPropTypeLimitPrice  = 1 // 限价
 	EntrustPropTypeMarketPrice = 2 // 市价
@@ -33,6 +35,7 @@ var EntrustStatusMap = map[int64]string{
 	EntrustStatusTypeReported:             "已申报",
 	EntrustStatusTypePartDeal:             "部分成交",
 	EntrustStatusTypeCancel:               "废单",
+	EntrustStatusTypeFailoverCancel:       "待撤单",
 }
 
 // Entrust 委托表
@@ -54,9 +57,31 @@ type Entrust struct {
 	Fee             float64          `gorm:"column:fee"`               // 总交易费用
 	IsBrokerEntrust bool             `gorm:"column:is_broker_entrust"` // 是否券商委托
 	Remark          string           `gorm:"column:remark"`            // 备注:委托失败
-	Mode            int64            `gorm:"column:mode"`              // 类型:0 主动卖出 1系统平仓
+	Mode            int64            `gorm:"column:mode"`              // 类型:0 主动卖出 1系统平仓 2条件单触发
 	Reason          string           `gorm:"-"`                        // 系统平仓原因
 	BrokerEntrust   []*BrokerEntrust `gorm:"-"`                        // 券商委托
+	Fill            *EntrustFill     `gorm:"-"`                        // 本次成交明细:模拟撮合分笔成交时设置
+}
+
+// EntrustFill 委托单次成交明细
+type EntrustFill struct {
+	Amount   int64   // 本次成交数量(股)
+	Price    float64 // 本次成交均价
+	Fee      float64 // 本次成交手续费
+	Unfreeze int64   // 卖出:本次解冻持仓股数
+}
+
+// DealFill 本次成交明细,未设置Fill时视为委托一次性成交(成交数量、价格、手续费取委托表)
+func (e *Entrust) DealFill() *EntrustFill {
+	if e.Fill != nil {
+		return e.Fill
+	}
+	return &EntrustFill{
+		Amount:   e.DealAmount,
+		Price:    e.Price,
+		Fee:      e.Fee,
+		Unfreeze: e.Amount,
+	}
 }
 
 func (i *Entrust) ConvertEntrustBsToString() string {
@@ -83,3 +108,13 @@ func (e *Entrust) IsFinallyState() bool {
 	}
 	return false
 }
+
+// SetWithdraw 设置撤单状态:未成交为已撤单;模拟撮合已部分成交为部成部撤,价格更新为成交均价
+func (e *Entrust) SetWithdraw() {
+	if e.DealAmount > 0 && !e.IsBrokerEntrust {
+		e.Status = EntrustStatusTypePartDealPartWithdraw
+		e.Price = e.Balance / float64(e.DealAmount)
+		return
+	}
+	e.Status = EntrustStatusTypeWithdraw
+}
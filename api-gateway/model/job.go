@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+const (
+	JobRunStatusRunning = 1 // 任务执行状态:执行中
+	JobRunStatusSuccess = 2 // 任务执行状态:成功
+	JobRunStatusFail    = 3 // 任务执行状态:失败
+
+	JobTriggerCron    = 1 // 触发方式:定时触发
+	JobTriggerCatchUp = 2 // 触发方式:重启后补跑
+	JobTriggerManual  = 3 // 触发方式:后台手动触发
+)
+
+var JobRunStatusMap = map[int64]string{
+	JobRunStatusRunning: "执行中",
+	JobRunStatusSuccess: "成功",
+	JobRunStatusFail:    "失败",
+}
+
+var JobTriggerMap = map[int64]string{
+	JobTriggerCron:    "定时触发",
+	JobTriggerCatchUp: "重启补跑",
+	JobTriggerManual:  "手动触发",
+}
+
+// JobRun 定时任务执行记录表
+type JobRun struct {
+	ID        int64     `gorm:"column:id"`         // 主键ID
+	Name      string    `gorm:"column:name"`       // 任务名称
+	RunDate   int32     `gorm:"column:run_date"`   // 业务日期:任务所属的日期
+	Trigger   int64     `gorm:"column:trigger"`    // 触发方式:1定时触发 2重启补跑 3手动触发
+	Status    int64     `gorm:"column:status"`     // 执行状态:1执行中 2成功 3失败
+	Attempts  int64     `gorm:"column:attempts"`   // 执行次数:含失败重试
+	Error     string    `gorm:"column:error"`      // 最后一次失败原因
+	Operator  string    `gorm:"column:operator"`   // 手动触发的操作员
+	StartTime time.Time `gorm:"column:start_time"` // 开始时间
+	EndTime   time.Time `gorm:"column:end_time"`   // 结束时间
+}
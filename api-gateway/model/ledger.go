@@ -0,0 +1,179 @@
+package model
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	LedgerAccountUserWallet     = 1  // 账户类型:用户钱包
+	LedgerAccountUserFreeze     = 2  // 账户类型:用户提现冻结资金
+	LedgerAccountContractMargin = 3  // 账户类型:合约保证金
+	LedgerAccountFeeIncome      = 4  // 账户类型:平台手续费收入
+	LedgerAccountInterestIncome = 5  // 账户类型:平台利息收入
+	LedgerAccountBrokerCash     = 6  // 账户类型:券商资金
+	LedgerAccountExternal       = 7  // 账户类型:外部资金(充值、提现渠道)
+	LedgerAccountMarket         = 8  // 账户类型:证券市场(交易盈亏、分红、逆回购利息)
+	LedgerAccountRepo           = 9  // 账户类型:国债逆回购融出资金
+	LedgerAccountAdjust         = 10 // 账户类型:人工调账、期初余额
+)
+
+var LedgerAccountMap = map[int64]string{
+	LedgerAccountUserWallet:     "用户钱包",
+	LedgerAccountUserFreeze:     "提现冻结",
+	LedgerAccountContractMargin: "合约保证金",
+	LedgerAccountFeeIncome:      "手续费收入",
+	LedgerAccountInterestIncome: "利息收入",
+	LedgerAccountBrokerCash:     "券商资金",
+	LedgerAccountExternal:       "外部资金",
+	LedgerAccountMarket:         "证券市场",
+	LedgerAccountRepo:           "逆回购融出",
+	LedgerAccountAdjust:         "人工调账",
+}
+
+const (
+	LedgerBizOpening          = 1  // 业务类型:期初余额
+	LedgerBizRecharge         = 2  // 业务类型:充值
+	LedgerBizWithdrawApply    = 3  // 业务类型:提现申请
+	LedgerBizWithdrawPass     = 4  // 业务类型:提现成功
+	LedgerBizWithdrawReject   = 5  // 业务类型:提现驳回
+	LedgerBizContractCreate   = 6  // 业务类型:申请合约
+	LedgerBizAppendMoney      = 7  // 业务类型:追加保证金
+	LedgerBizExpandMoney      = 8  // 业务类型:扩大资金
+	LedgerBizWithdrawProfit   = 9  // 业务类型:合约提盈
+	LedgerBizSettlement       = 10 // 业务类型:合约结算
+	LedgerBizContractInterest = 11 // 业务类型:合约利息
+	LedgerBizBuy              = 12 // 业务类型:买入成交
+	LedgerBizSell             = 13 // 业务类型:卖出成交
+	LedgerBizDividend         = 14 // 业务类型:现金分红
+	LedgerBizRepoLend         = 15 // 业务类型:逆回购融出
+	LedgerBizRepoWithdraw     = 16 // 业务类型:逆回购撤单
+	LedgerBizRepoDeal         = 17 // 业务类型:逆回购成交
+	LedgerBizRepoSettle       = 18 // 业务类型:逆回购到期
+	LedgerBizAdjust           = 19 // 业务类型:人工调账
+	LedgerBizBrokerDeal       = 20 // 业务类型:券商成交
+)
+
+var LedgerBizMap = map[int64]string{
+	LedgerBizOpening:          "期初余额",
+	LedgerBizRecharge:         "充值",
+	LedgerBizWithdrawApply:    "提现申请",
+	LedgerBizWithdrawPass:     "提现成功",
+	LedgerBizWithdrawReject:   "提现驳回",
+	LedgerBizContractCreate:   "申请合约",
+	LedgerBizAppendMoney:      "追加保证金",
+	LedgerBizExpandMoney:      "扩大资金",
+	LedgerBizWithdrawProfit:   "合约提盈",
+	LedgerBizSettlement:       "合约结算",
+	LedgerBizContractInterest: "合约利息",
+	LedgerBizBuy:              "买入成交",
+	LedgerBizSell:             "卖出成交",
+	LedgerBizDividend:         "现金分红",
+	LedgerBizRepoLend:         "逆回购融出",
+	LedgerBizRepoWithdraw:     "逆回购撤单",
+	LedgerBizRepoDeal:         "逆回购成交",
+	LedgerBizRepoSettle:       "逆回购到期",
+	LedgerBizAdjust:           "人工调账",
+	LedgerBizBrokerDeal:       "券商成交",
+}
+
+// LedgerAccount 记账账户:账户类型+所属ID,平台账户所属ID为0
+type LedgerAccount struct {
+	Type    int64
+	OwnerID int64
+}
+
+// WalletAccount 用户钱包账户
+func WalletAccount(uid int64) LedgerAccount {
+	return LedgerAccount{Type: LedgerAccountUserWallet, OwnerID: uid}
+}
+
+// FreezeAccount 用户提现冻结账户
+func FreezeAccount(uid int64) LedgerAccount {
+	return LedgerAccount{Type: LedgerAccountUserFreeze, OwnerID: uid}
+}
+
+// MarginAccount 合约保证金账户
+func MarginAccount(contractID int64) LedgerAccount {
+	return LedgerAccount{Type: LedgerAccountContractMargin, OwnerID: contractID}
+}
+
+// BrokerCashAccount 券商资金账户
+func BrokerCashAccount(brokerID int64) LedgerAccount {
+	return LedgerAccount{Type: LedgerAccountBrokerCash, OwnerID: brokerID}
+}
+
+var (
+	FeeIncomeAccount      = LedgerAccount{Type: LedgerAccountFeeIncome}      // 平台手续费收入
+	InterestIncomeAccount = LedgerAccount{Type: LedgerAccountInterestIncome} // 平台利息收入
+	ExternalAccount       = LedgerAccount{Type: LedgerAccountExternal}       // 外部资金
+	MarketAccount         = LedgerAccount{Type: LedgerAccountMarket}         // 证券市场
+	RepoAccount           = LedgerAccount{Type: LedgerAccountRepo}           // 逆回购融出
+	AdjustAccount         = LedgerAccount{Type: LedgerAccountAdjust}         // 人工调账
+)
+
+// LedgerEntry 记账分录表:只增不改,账户余额为该账户全部分录金额之和
+type LedgerEntry struct {
+	ID          int64     `gorm:"column:id"`           // 主键ID
+	JournalNo   string    `gorm:"column:journal_no"`   // 凭证号:同一凭证的分录金额之和为0
+	BizType     int64     `gorm:"column:biz_type"`     // 业务类型
+	BizID       int64     `gorm:"column:biz_id"`       // 业务ID:委托、转账记录、逆回购等
+	AccountType int64     `gorm:"column:account_type"` // 账户类型
+	OwnerID     int64     `gorm:"column:owner_id"`     // 账户所属ID:用户ID、合约ID、券商ID,平台账户为0
+	Amount      float64   `gorm:"column:amount"`       // 金额:正数余额增加,负数余额减少
+	Remark      string    `gorm:"column:remark"`       // 备注
+	CreateTime  time.Time `gorm:"column:create_time"`  // 记账时间
+}
+
+// LedgerEntryQuery 记账分录查询条件
+type LedgerEntryQuery struct {
+	AccountType int64
+	OwnerID     int64
+	BizType     int64
+	JournalNo   string
+	Offset      int
+	Limit       int
+}
+
+// Journal 记账凭证
+type Journal struct {
+	BizType int64
+	BizID   int64
+	Remark  string
+	Entries []*LedgerEntry
+}
+
+// NewJournal 创建记账凭证
+func NewJournal(bizType, bizID int64, remark string) *Journal {
+	return &Journal{BizType: bizType, BizID: bizID, Remark: remark}
+}
+
+// Move 资金从from账户转入to账户,金额为负数时反向转移,金额为0时不记账
+func (j *Journal) Move(from, to LedgerAccount, money float64) *Journal {
+	money = math.Round(money*100) / 100
+	if money == 0 {
+		return j
+	}
+	j.Entries = append(j.Entries,
+		&LedgerEntry{AccountType: from.Type, OwnerID: from.OwnerID, Amount: -money},
+		&LedgerEntry{AccountType: to.Type, OwnerID: to.OwnerID, Amount: money},
+	)
+	return j
+}
+
+// Balanced 借贷是否平衡:全部分录金额之和为0
+func (j *Journal) Balanced() bool {
+	sum := 0.00
+	for _, it := range j.Entries {
+		sum += it.Amount
+	}
+	return math.Abs(sum) < 0.005
+}
+
+// LedgerDiff 账簿余额与业务表余额不一致的账户
+type LedgerDiff struct {
+	AccountType int64   `json:"account_type"` // 账户类型
+	OwnerID     int64   `json:"owner_id"`     // 账户所属ID
+	Book        float64 `json:"book"`         // 业务表余额
+	Ledger      float64 `json:"ledger"`       // 账簿余额
+}
@@ -0,0 +1,57 @@
+package model
+
+import "math"
+
+const (
+	PaymentStatusPending = 1 // 渠道支付状态:待支付
+	PaymentStatusPaid    = 2 // 渠道支付状态:已支付
+	PaymentStatusClosed  = 3 // 渠道支付状态:已关闭
+)
+
+// PaymentChannelConfig 充值渠道配置表:单笔金额限制与手续费规则,渠道开关仍在系统参数中设置
+type PaymentChannelConfig struct {
+	ID       int64   `gorm:"column:id"`        // 主键ID
+	Code     string  `gorm:"column:code"`      // 渠道编码
+	MinMoney float64 `gorm:"column:min_money"` // 单笔最低充值金额
+	MaxMoney float64 `gorm:"column:max_money"` // 单笔最高充值金额,0不限制
+	FeeRate  float64 `gorm:"column:fee_rate"`  // 手续费率
+	MinFee   float64 `gorm:"column:min_fee"`   // 最低手续费
+	Sort     int64   `gorm:"column:sort"`      // 排序,越小越靠前
+}
+
+// Fee 充值手续费:按费率计算并保留两位小数,不低于最低手续费,不超过充值金额
+func (c *PaymentChannelConfig) Fee(money float64) float64 {
+	fee := math.Round(money*c.FeeRate*100) / 100
+	if fee < c.MinFee {
+		fee = c.MinFee
+	}
+	if fee > money {
+		fee = money
+	}
+	return fee
+}
+
+// PaymentOrder 渠道下单结果
+type PaymentOrder struct {
+	Status int64                  // 充值订单初始状态:在线支付为预插入,人工转账为待审核
+	Data   map[string]interface{} // 客户端完成支付所需的信息:支付链接、收款账户、收款码等
+}
+
+// PaymentResult 渠道支付结果
+type PaymentResult struct {
+	Status   int64   // 渠道支付状态
+	OrderNo  string  // 商户订单号
+	TradeNo  string  // 渠道交易号
+	Money    float64 // 实际支付金额
+	NotifyID string  // 异步通知ID,用于防重放,主动查询时为空
+}
+
+// RechargeChannel 充值页面可用渠道
+type RechargeChannel struct {
+	Code     string  `json:"code"`      // 渠道编码
+	Name     string  `json:"name"`      // 渠道名称
+	MinMoney float64 `json:"min_money"` // 单笔最低充值金额
+	MaxMoney float64 `json:"max_money"` // 单笔最高充值金额,0不限制
+	FeeRate  float64 `json:"fee_rate"`  // 手续费率
+	MinFee   float64 `json:"min_fee"`   // 最低手续费
+}
@@ -0,0 +1,56 @@
+package model
+
+import "time"
+
+const (
+	ReconcileStatusMatch = 1 // 对账结果:一致
+	ReconcileStatusDiff  = 2 // 对账结果:有差异
+
+	ReconcileDiffUnpaid     = 1 // 差异类型:渠道已支付,本地未入账
+	ReconcileDiffNoBill     = 2 // 差异类型:本地已入账,对账单无记录
+	ReconcileDiffAmount     = 3 // 差异类型:金额不一致
+	ReconcileDiffNoTransfer = 4 // 差异类型:对账单有记录,本地无订单
+)
+
+var ReconcileStatusMap = map[int64]string{
+	ReconcileStatusMatch: "一致",
+	ReconcileStatusDiff:  "有差异",
+}
+
+var ReconcileDiffMap = map[int64]string{
+	ReconcileDiffUnpaid:     "渠道已支付,本地未入账",
+	ReconcileDiffNoBill:     "本地已入账,对账单无记录",
+	ReconcileDiffAmount:     "金额不一致",
+	ReconcileDiffNoTransfer: "本地无订单",
+}
+
+// ReconcileReport 充值渠道对账报告表
+type ReconcileReport struct {
+	ID           int64     `gorm:"column:id"`            // 主键ID
+	Channel      string    `gorm:"column:channel"`       // 充值渠道
+	BillDate     int32     `gorm:"column:bill_date"`     // 对账日期
+	Matched      int64     `gorm:"column:matched"`       // 一致笔数
+	MatchedMoney float64   `gorm:"column:matched_money"` // 一致金额
+	DiffCount    int64     `gorm:"column:diff_count"`    // 差异笔数
+	Diff         string    `gorm:"column:diff"`          // 差异明细(JSON)
+	Status       int64     `gorm:"column:status"`        // 对账结果:1一致 2有差异
+	Operator     string    `gorm:"column:operator"`      // 操作员,定时任务为空
+	CreateTime   time.Time `gorm:"column:create_time"`   // 对账时间
+}
+
+// ChannelBill 渠道对账单明细
+type ChannelBill struct {
+	TradeNo string  // 渠道交易号
+	OrderNo string  // 商户订单号
+	Money   float64 // 订单金额
+}
+
+// ReconcileDiff 对账差异
+type ReconcileDiff struct {
+	Type        int64   `json:"type"`         // 差异类型
+	OrderNo     string  `json:"order_no"`     // 商户订单号
+	TradeNo     string  `json:"trade_no"`     // 渠道交易号
+	LocalMoney  float64 `json:"local_money"`  // 本地订单金额
+	BillMoney   float64 `json:"bill_money"`   // 对账单金额
+	LocalStatus int64   `json:"local_status"` // 本地订单状态
+}
@@ -0,0 +1,166 @@
+package model
+
+import "time"
+
+const (
+	ReverseRepoSourceContract = 1 // 资金来源:合约可用资金
+	ReverseRepoSourceWallet   = 2 // 资金来源:钱包余额
+
+	ReverseRepoStatusUnDeal   = 1 // 逆回购状态:未成交
+	ReverseRepoStatusDeal     = 2 // 逆回购状态:已成交,计息中
+	ReverseRepoStatusWithdraw = 3 // 逆回购状态:已撤单
+	ReverseRepoStatusSettled  = 4 // 逆回购状态:已到期结算
+
+	ReverseRepoUnit = 1000 // 逆回购委托金额:1000元起,1000元整数倍
+)
+
+var ReverseRepoSourceMap = map[int64]string{
+	ReverseRepoSourceContract: "合约资金",
+	ReverseRepoSourceWallet:   "钱包余额",
+}
+
+var ReverseRepoStatusMap = map[int64]string{
+	ReverseRepoStatusUnDeal:   "未成交",
+	ReverseRepoStatusDeal:     "计息中",
+	ReverseRepoStatusWithdraw: "已撤单",
+	ReverseRepoStatusSettled:  "已到期",
+}
+
+// RepoProduct 国债逆回购品种
+type RepoProduct struct {
+	Code    string  // 证券代码
+	Name    string  // 证券简称
+	Market  string  // 交易市场:SH上交所 SZ深交所
+	Tenor   int     // 期限(天)
+	FeeRate float64 // 佣金费率:按成交金额收取
+}
+
+// RepoProducts 沪深交易所国债逆回购品种
+var RepoProducts = []*RepoProduct{
+	{Code: "204001", Name: "GC001", Market: "SH", Tenor: 1, FeeRate: 0.00001},
+	{Code: "204002", Name: "GC002", Market: "SH", Tenor: 2, FeeRate: 0.00002},
+	{Code: "204003", Name: "GC003", Market: "SH", Tenor: 3, FeeRate: 0.00003},
+	{Code: "204004", Name: "GC004", Market: "SH", Tenor: 4, FeeRate: 0.00004},
+	{Code: "204007", Name: "GC007", Market: "SH", Tenor: 7, FeeRate: 0.00005},
+	{Code: "204014", Name: "GC014", Market: "SH", Tenor: 14, FeeRate: 0.0001},
+	{Code: "204028", Name: "GC028", Market: "SH", Tenor: 28, FeeRate: 0.0002},
+	{Code: "204091", Name: "GC091", Market: "SH", Tenor: 91, FeeRate: 0.0003},
+	{Code: "204182", Name: "GC182", Market: "SH", Tenor: 182, FeeRate: 0.0003},
+	{Code: "131810", Name: "R-001", Market: "SZ", Tenor: 1, FeeRate: 0.00001},
+	{Code: "131811", Name: "R-002", Market: "SZ", Tenor: 2, FeeRate: 0.00002},
+	{Code: "131800", Name: "R-003", Market: "SZ", Tenor: 3, FeeRate: 0.00003},
+	{Code: "131809", Name: "R-004", Market: "SZ", Tenor: 4, FeeRate: 0.00004},
+	{Code: "131801", Name: "R-007", Market: "SZ", Tenor: 7, FeeRate: 0.00005},
+	{Code: "131802", Name: "R-014", Market: "SZ", Tenor: 14, FeeRate: 0.0001},
+	{Code: "131803", Name: "R-028", Market: "SZ", Tenor: 28, FeeRate: 0.0002},
+	{Code: "131805", Name: "R-091", Market: "SZ", Tenor: 91, FeeRate: 0.0003},
+	{Code: "131806", Name: "R-182", Market: "SZ", Tenor: 182, FeeRate: 0.0003},
+}
+
+// GetRepoProduct 根据证券代码查询逆回购品种
+func GetRepoProduct(code string) (*RepoProduct, bool) {
+	for _, it := range RepoProducts {
+		if it.Code == code {
+			return it, true
+		}
+	}
+	return nil, false
+}
+
+// ReverseRepo 国债逆回购表:融出资金委托及到期结算
+type ReverseRepo struct {
+	ID              int64     `gorm:"column:id"`               // 主键ID
+	UID             int64     `gorm:"column:uid"`              // 用户ID
+	ContractID      int64     `gorm:"column:contract_id"`      // 合约编号:资金来源为钱包时为0
+	Source          int64     `gorm:"column:source"`           // 资金来源:1合约资金 2钱包余额
+	Code            string    `gorm:"column:code"`             // 证券代码
+	Name            string    `gorm:"column:name"`             // 证券简称
+	Tenor           int64     `gorm:"column:tenor"`            // 期限(天)
+	EntrustBS       int64     `gorm:"column:entrust_bs"`       // 交易类型:3融出
+	EntrustProp     int64     `gorm:"column:entrust_prop"`     // 委托类型:1限价 2市价
+	EntrustRate     float64   `gorm:"column:entrust_rate"`     // 委托年化利率(%):限价委托时不低于该利率成交
+	Money           float64   `gorm:"column:money"`            // 融出金额(本金)
+	Rate            float64   `gorm:"column:rate"`             // 成交年化利率(%)
+	Fee             float64   `gorm:"column:fee"`              // 手续费
+	Interest        float64   `gorm:"column:interest"`         // 到期利息
+	AccruedInterest float64   `gorm:"column:accrued_interest"` // 已计提利息
+	InterestDays    int64     `gorm:"column:interest_days"`    // 计息天数
+	TradeDate       int32     `gorm:"column:trade_date"`       // 成交日
+	SettleDate      int32     `gorm:"column:settle_date"`      // 首次交收日:起息日
+	ExpireDate      int32     `gorm:"column:expire_date"`      // 到期交收日
+	AvailableDate   int32     `gorm:"column:available_date"`   // 资金可用日:本息到账
+	Status          int64     `gorm:"column:status"`           // 状态:1未成交 2计息中 3已撤单 4已到期
+	Remark          string    `gorm:"column:remark"`           // 备注
+	CreateTime      time.Time `gorm:"column:create_time"`      // 委托时间
+	UpdateTime      time.Time `gorm:"column:update_time"`      // 更新时间
+}
+
+// Frozen 占用资金:未成交冻结本金和手续费,已成交占用本金(手续费已扣除)
+func (r *ReverseRepo) Frozen() float64 {
+	switch r.Status {
+	case ReverseRepoStatusUnDeal:
+		return r.Money + r.Fee
+	case ReverseRepoStatusDeal:
+		return r.Money
+	}
+	return 0
+}
+
+// RepoProductItem 逆回购品种行情
+type RepoProductItem struct {
+	Code   string  `json:"code"`   // 证券代码
+	Name   string  `json:"name"`   // 证券简称
+	Market string  `json:"market"` // 交易市场
+	Tenor  int     `json:"tenor"`  // 期限(天)
+	Rate   float64 `json:"rate"`   // 最新年化利率(%)
+}
+
+// ReverseRepoItem 逆回购委托
+type ReverseRepoItem struct {
+	ID              int64   `json:"id"`               // 主键ID
+	ContractID      int64   `json:"contract_id"`      // 合约编号
+	Source          int64   `json:"source"`           // 资金来源:1合约资金 2钱包余额
+	SourceDesc      string  `json:"source_desc"`      // 资金来源描述
+	Code            string  `json:"code"`             // 证券代码
+	Name            string  `json:"name"`             // 证券简称
+	Tenor           int64   `json:"tenor"`            // 期限(天)
+	EntrustRate     float64 `json:"entrust_rate"`     // 委托年化利率(%)
+	Money           float64 `json:"money"`            // 融出金额
+	Rate            float64 `json:"rate"`             // 成交年化利率(%)
+	Fee             float64 `json:"fee"`              // 手续费
+	Interest        float64 `json:"interest"`         // 到期利息
+	AccruedInterest float64 `json:"accrued_interest"` // 已计提利息
+	InterestDays    int64   `json:"interest_days"`    // 计息天数
+	ExpireDate      int32   `json:"expire_date"`      // 到期交收日
+	AvailableDate   int32   `json:"available_date"`   // 资金可用日
+	Status          int64   `json:"status"`           // 状态:1未成交 2计息中 3已撤单 4已到期
+	StatusDesc      string  `json:"status_desc"`      // 状态描述
+	Remark          string  `json:"remark"`           // 备注
+	Time            string  `json:"time"`             // 委托时间
+}
+
+// ConvertReverseRepoItem 转换逆回购委托
+func ConvertReverseRepoItem(r *ReverseRepo) *ReverseRepoItem {
+	return &ReverseRepoItem{
+		ID:              r.ID,
+		ContractID:      r.ContractID,
+		Source:          r.Source,
+		SourceDesc:      ReverseRepoSourceMap[r.Source],
+		Code:            r.Code,
+		Name:            r.Name,
+		Tenor:           r.Tenor,
+		EntrustRate:     r.EntrustRate,
+		Money:           r.Money,
+		Rate:            r.Rate,
+		Fee:             r.Fee,
+		Interest:        r.Interest,
+		AccruedInterest: r.AccruedInterest,
+		InterestDays:    r.InterestDays,
+		ExpireDate:      r.ExpireDate,
+		AvailableDate:   r.AvailableDate,
+		Status:          r.Status,
+		StatusDesc:      ReverseRepoStatusMap[r.Status],
+		Remark:          r.Remark,
+		Time:            r.CreateTime.Format("2006-01-02 15:04:05"),
+	}
+}
@@ -5,6 +5,7 @@ type RoleModule struct {
 	RoleID   int64  `gorm:"column:role_id" json:"role_id"`
 	Module   string `gorm:"column:module" json:"module"`
 	ModuleID int64  `gorm:"column:module_id" json:"module_id"`
+	CanWrite bool   `gorm:"column:can_write" json:"can_write"` // 是否有写权限,否则只读
 }
 
 const (
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"stock/api-gateway/util"
+)
+
+// Secret 加密存储的字段:写库时使用当前版本密钥加密,读库时解密,内存中为明文;序列化为JSON时脱敏
+type Secret string
+
+// String 明文
+func (s Secret) String() string {
+	return string(s)
+}
+
+// Value 写库加密,未配置密钥时明文存储
+func (s Secret) Value() (driver.Value, error) {
+	c := util.GetFieldCipher()
+	if c == nil {
+		return string(s), nil
+	}
+	return c.Encrypt(string(s))
+}
+
+// Scan 读库解密,兼容未加密的历史数据
+func (s *Secret) Scan(v interface{}) error {
+	var raw string
+	switch t := v.(type) {
+	case nil:
+	case []byte:
+		raw = string(t)
+	case string:
+		raw = t
+	default:
+		return fmt.Errorf("unsupported secret type: %T", v)
+	}
+	c := util.GetFieldCipher()
+	if c == nil {
+		if util.IsFieldEncrypted(raw) {
+			return fmt.Errorf("no field key to decrypt")
+		}
+		*s = Secret(raw)
+		return nil
+	}
+	txt, err := c.Decrypt(raw)
+	if err != nil {
+		return err
+	}
+	*s = Secret(txt)
+	return nil
+}
+
+// MarshalJSON 脱敏,避免审计日志等序列化场景泄露明文
+func (s Secret) MarshalJSON() ([]byte, error) {
+	if len(s) == 0 {
+		return json.Marshal("")
+	}
+	return json.Marshal("***")
+}
+
+// SecretColumns 加密存储的表字段,密钥轮换时逐行重新加密
+var SecretColumns = map[string][]string{
+	"users":    {"icc_id", "bank_number"},
+	"broker":   {"trade_password", "tx_password"},
+	"transfer": {"bank_no"},
+}
@@ -0,0 +1,32 @@
+package model
+
+// token类型
+const (
+	TokenTypeAccess  = "access"  // 访问token
+	TokenTypeRefresh = "refresh" // 刷新token
+)
+
+// AppSession App用户登录会话,存储在redis
+type AppSession struct {
+	SessionID  string `json:"session_id"`  // 会话ID
+	UID        int64  `json:"uid"`         // 用户ID
+	CreateTime string `json:"create_time"` // 创建时间
+	ExpireTime string `json:"expire_time"` // 过期时间(refresh token过期时间)
+}
+
+// TokenClaims token中携带的信息
+type TokenClaims struct {
+	UID       int64  `json:"uid"` // 用户ID
+	SessionID string `json:"sid"` // 会话ID
+	Type      string `json:"typ"` // token类型:access/refresh
+	ExpireAt  int64  `json:"exp"` // 过期时间戳(秒)
+}
+
+// AppToken 登录返回的token
+type AppToken struct {
+	UID             int64  `json:"uid"`               // 用户ID
+	AccessToken     string `json:"access_token"`      // 访问token
+	RefreshToken    string `json:"refresh_token"`     // 刷新token
+	AccessExpireAt  int64  `json:"access_expire_at"`  // 访问token过期时间戳(秒)
+	RefreshExpireAt int64  `json:"refresh_expire_at"` // 刷新token过期时间戳(秒)
+}
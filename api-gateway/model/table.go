@@ -8,6 +8,10 @@ import (
 const (
 	UserStatusActive = 1 // 激活状态
 	UserStatusFrezze = 2 // 冻结状态
+
+	UserVerifyNone = 0 // 实名认证:未认证
+	UserVerifyPass = 1 // 实名认证:已认证
+	UserVerifyFail = 2 // 实名认证:认证失败
 )
 
 // User 用户信息
@@ -18,18 +22,30 @@ type User struct {
 	Status            int8      `gorm:"column:status"`                           // 1:激活 2:冻结
 	CurrentContractID int64     `gorm:"column:current_contract_id"`              // 当前合约ID
 	Name              string    `gorm:"column:name"`                             // 姓名
-	ICCID             string    `gorm:"column:icc_id"`                           // 身份证号码
-	BankNumber        string    `gorm:"column:bank_number"`                      // 银行卡号码
+	ICCID             Secret    `gorm:"column:icc_id"`                           // 身份证号码
+	BankNumber        Secret    `gorm:"column:bank_number"`                      // 银行卡号码
 	RoleID            int64     `gorm:"column:role_id"`                          // 代理商
 	CreateAt          time.Time `gorm:"column:created_at"`                       // 创建时间
 	Money             float64   `gorm:"column:money"`                            // 保证金
 	FreezeMoney       float64   `gorm:"column:freeze_money" json:"freeze_money"` // 冻结资金
+	VerifyStatus      int64     `gorm:"column:verify_status"`                    // 实名认证状态:0未认证 1已认证 2认证失败
+}
+
+// Verified 是否已实名认证
+func (u *User) Verified() bool {
+	return u.VerifyStatus == UserVerifyPass
 }
 
 ///////////////////////////////////users表///////////////////////////////////
 
 ///////////////////////////////////sysParam表///////////////////////////////////
 
+const (
+	MatchModeLastPrice = 1 // 模拟撮合方式:最新价穿越限价即全部成交
+	MatchModeOrderBook = 2 // 模拟撮合方式:按五档盘口深度成交,可部分成交
+	MatchModeVolume    = 3 // 模拟撮合方式:按区间成交量参与比例、以区间均价(VWAP)成交
+)
+
 // SysParam 系统参数表
 type SysParam struct {
 	StartWithdrawTime      string  `gorm:"column:start_withdraw_time"`         // 提现开始时间
@@ -69,6 +85,22 @@ type SysParam struct {
 	MiniChargeFee          float64 `gorm:"column:mini_charge_fee"`             // 最低交易手续费:0不生效
 	IsSupportBroker        bool    `gorm:"column:is_support_broker"`           // 是否对接券商
 	AdminPhone             string  `json:"admin_phone"`                        // 管理员手机号码
+	PasswordMinLen         int64   `gorm:"column:password_min_len"`            // 密码最小长度
+	PasswordRequireLetter  bool    `gorm:"column:password_require_letter"`     // 密码必须包含字母
+	PasswordRequireDigit   bool    `gorm:"column:password_require_digit"`      // 密码必须包含数字
+	PasswordRequireSymbol  bool    `gorm:"column:password_require_symbol"`     // 密码必须包含特殊字符
+	MatchMode              int64   `gorm:"column:match_mode"`                  // 非券商委托模拟撮合方式:1最新价 2五档盘口 3成交量参与(VWAP)
+	MatchVolumePct         float64 `gorm:"column:match_volume_pct"`            // 成交量参与撮合:可成交数量占区间成交量的比例
+	WithdrawReviewAmount   float64 `gorm:"column:withdraw_review_amount"`      // 提现复核金额:达到该金额须第二人复核,0不复核
+	RechargeReviewAmount   float64 `gorm:"column:recharge_review_amount"`      // 人工充值复核金额:达到该金额须第二人复核,0不复核
+	WithdrawMinMoney       float64 `gorm:"column:withdraw_min_money"`          // 单笔最低提现金额
+	WithdrawMaxMoney       float64 `gorm:"column:withdraw_max_money"`          // 单笔最高提现金额,0不限制
+	WithdrawDayMoney       float64 `gorm:"column:withdraw_day_money"`          // 每日累计提现金额上限,0不限制
+	WithdrawDayCount       int64   `gorm:"column:withdraw_day_count"`          // 每日提现次数上限,0不限制
+	WithdrawWeekMoney      float64 `gorm:"column:withdraw_week_money"`         // 每周累计提现金额上限,0不限制
+	WithdrawCooldownHours  int64   `gorm:"column:withdraw_cooldown_hours"`     // 充值后N小时内不能提现,0不限制
+	BrokerRouteStrategy    string  `gorm:"column:broker_route_strategy"`       // 券商分配策略,为空按优先级
+	BrokerFailoverMode     int64   `gorm:"column:broker_failover_mode"`        // 券商断开后未成交委托:0等待重连 1转模拟撮合
 }
 
 ///////////////////////////////////sysParam表///////////////////////////////////
@@ -152,7 +184,7 @@ type Sell struct {
 	EntrustProp   int64     `gorm:"column:entrust_prop"`   // 委托类型:1限价 2市价
 	Fee           float64   `gorm:"column:fee"`            // 交易手续费
 	PositionID    int64     `gorm:"column:position_id"`    // 持仓表序号
-	Mode          int64     `gorm:"column:mode"`           // 类型:1 主动卖出 2系统平仓
+	Mode          int64     `gorm:"column:mode"`           // 类型:0 主动卖出 1系统平仓 2条件单触发
 	Reason        string    `gorm:"column:reason"`         // 系统平仓原因
 }
 
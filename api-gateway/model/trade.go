@@ -97,10 +97,11 @@ type PositionResp struct {
 
 // InitBuy 买入界面初始化
 type InitBuy struct {
-	ContractID   int64   `json:"contract_id"`
-	ContractName string  `json:"contract_name"`
-	MaxBuyAmount int64   `json:"max_buy_amount"` // 最大可买数量
-	PanKou       *PanKou `json:"pan_kou"`        // 盘口信息
+	ContractID   int64      `json:"contract_id"`
+	ContractName string     `json:"contract_name"`
+	MaxBuyAmount int64      `json:"max_buy_amount"` // 最大可买数量
+	PanKou       *PanKou    `json:"pan_kou"`        // 盘口信息
+	PriceBand    *PriceBand `json:"price_band"`     // 涨跌停价格区间
 }
 
 // PanKou 盘口信息
@@ -136,10 +137,47 @@ type PanKou struct {
 
 // InitSell 卖出初始化
 type InitSell struct {
-	ContractID    int64   `json:"contract_id"`
-	ContractName  string  `json:"contract_name"`
-	MaxSellAmount int64   `json:"max_sell_amount"` // 最大可卖数量
-	PanKou        *PanKou `json:"pan_kou"`         // 盘口信息
+	ContractID    int64      `json:"contract_id"`
+	ContractName  string     `json:"contract_name"`
+	MaxSellAmount int64      `json:"max_sell_amount"` // 最大可卖数量
+	PanKou        *PanKou    `json:"pan_kou"`         // 盘口信息
+	PriceBand     *PriceBand `json:"price_band"`      // 涨跌停价格区间
+}
+
+// PriceBand 涨跌停价格区间,LimitPct为0表示无涨跌幅限制
+type PriceBand struct {
+	StockCode      string  `json:"stock_code"`       // 股票代码
+	ClosePrice     float64 `json:"close_price"`      // 昨收
+	LimitPct       float64 `json:"limit_pct"`        // 涨跌幅限制比例
+	LimitUpPrice   float64 `json:"limit_up_price"`   // 涨停价
+	LimitDownPrice float64 `json:"limit_down_price"` // 跌停价
+}
+
+// IsLimited 是否有涨跌幅限制
+func (b *PriceBand) IsLimited() bool {
+	return b.LimitPct > 0 && b.LimitUpPrice > 0
+}
+
+// Contains 价格是否在涨跌停价格区间内
+func (b *PriceBand) Contains(price float64) bool {
+	if !b.IsLimited() {
+		return true
+	}
+	return price >= b.LimitDownPrice-1e-6 && price <= b.LimitUpPrice+1e-6
+}
+
+// Clamp 将价格限制在涨跌停价格区间内
+func (b *PriceBand) Clamp(price float64) float64 {
+	if !b.IsLimited() {
+		return price
+	}
+	if price > b.LimitUpPrice {
+		return b.LimitUpPrice
+	}
+	if price < b.LimitDownPrice {
+		return b.LimitDownPrice
+	}
+	return price
 }
 
 // Withdraw 撤单
@@ -158,8 +196,9 @@ type Withdraw struct {
 }
 
 const (
-	UserMode   = 0 // 用户模式
-	SystemMode = 1 // 系统模式
+	UserMode        = 0 // 用户模式
+	SystemMode      = 1 // 系统模式
+	ConditionalMode = 2 // 条件单触发
 )
 
 // EntrustPackage 交易委托pack
@@ -170,7 +209,7 @@ type EntrustPackage struct {
 	Price       float64 // 股票价格
 	Amount      int64   // 股票数量
 	EntrustProp int64   // 委托类型:1限价 2市价
-	Mode        int64   // 类型:0 主动卖出 1系统平仓
+	Mode        int64   // 类型:0 主动卖出 1系统平仓 2条件单触发
 }
 
 // SellOut 清仓股票
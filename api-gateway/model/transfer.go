@@ -10,24 +10,76 @@ const (
 	TransferTypeCloseContract  = 5 // 终止合约
 	TransferTypeGetMoney       = 6 // 合约提盈
 	TransferTypeCreateContract = 7 // 创建合约
+	TransferTypeRepoLend       = 8 // 国债逆回购融出
+	TransferTypeRepoSettle     = 9 // 国债逆回购到期
 	TransferStatusPre          = 0 // 预插入
 	TransferStatusWaitExam     = 1 // 待审核
 	TransferStatusSuccess      = 2 // 成功
 	TransferStatusFail         = 3 // 失败
+	TransferStatusWaitReview   = 4 // 待复核:初审通过,金额达到复核金额,等待第二人复核
 )
 
+const (
+	TransferChannelAlipay = "支付宝"  // 充值渠道:支付宝
+	TransferChannelBank   = "银行卡"  // 充值渠道:银行卡转账
+	TransferChannelQrcode = "扫码支付" // 充值渠道:扫码转账
+)
+
+var TransferStatusMap = map[int64]string{
+	TransferStatusPre:        "预插入",
+	TransferStatusWaitExam:   "待审核",
+	TransferStatusSuccess:    "成功",
+	TransferStatusFail:       "失败",
+	TransferStatusWaitReview: "待复核",
+}
+
+// transferTransitions 充值、提现审批允许的状态变化
+var transferTransitions = map[int64][]int64{
+	TransferStatusWaitExam:   {TransferStatusWaitReview, TransferStatusSuccess, TransferStatusFail},
+	TransferStatusWaitReview: {TransferStatusSuccess, TransferStatusFail},
+}
+
+// CanTransferTransit 审批状态能否从from变为to
+func CanTransferTransit(from, to int64) bool {
+	for _, it := range transferTransitions[from] {
+		if it == to {
+			return true
+		}
+	}
+	return false
+}
+
 // Transfer 银行转账表
 type Transfer struct {
-	ID        int64     `gorm:"column:id"`         // 主键ID
-	UID       int64     `gorm:"column:uid"`        // 用户ID
-	OrderTime time.Time `gorm:"column:order_time"` // 订单时间
-	Money     float64   `gorm:"column:money"`      // 金额
-	Type      int64     `gorm:"column:type"`       // 类型：1充值 2提现
-	Status    int64     `gorm:"column:status"`     // 状态:0预插入 1待审核 2成功 3失败
-	Name      string    `gorm:"column:name"`       // 提现收款人
-	BankNo    string    `gorm:"column:bank_no"`    // 提现银行卡号
-	Channel   string    `gorm:"column:channel"`    // 渠道
-	OrderNo   string    `gorm:"column:order_no"`   // 订单号流水
+	ID         int64      `gorm:"column:id"`          // 主键ID
+	UID        int64      `gorm:"column:uid"`         // 用户ID
+	OrderTime  time.Time  `gorm:"column:order_time"`  // 订单时间
+	Money      float64    `gorm:"column:money"`       // 金额
+	Type       int64      `gorm:"column:type"`        // 类型：1充值 2提现
+	Status     int64      `gorm:"column:status"`      // 状态:0预插入 1待审核 2成功 3失败
+	Name       string     `gorm:"column:name"`        // 提现收款人
+	BankNo     Secret     `gorm:"column:bank_no"`     // 提现银行卡号
+	Channel    string     `gorm:"column:channel"`     // 渠道
+	OrderNo    string     `gorm:"column:order_no"`    // 订单号流水
+	Reviewer   string     `gorm:"column:reviewer"`    // 初审人
+	Checker    string     `gorm:"column:checker"`     // 复核人
+	Reason     string     `gorm:"column:reason"`      // 驳回原因
+	TradeNo    string     `gorm:"column:trade_no"`    // 渠道交易号:支付宝交易号
+	Fee        float64    `gorm:"column:fee"`         // 充值手续费:到账金额为金额减手续费
+	FinishTime *time.Time `gorm:"column:finish_time"` // 完成时间:充值入账、审批通过或驳回的时间,未完成为空
+}
+
+// DoneTime 完成时间,历史记录无完成时间时取订单时间
+func (t *Transfer) DoneTime() time.Time {
+	if t.FinishTime != nil {
+		return *t.FinishTime
+	}
+	return t.OrderTime
+}
+
+// Arrival 充值到账金额
+func (t *Transfer) Arrival() float64 {
+	return t.Money - t.Fee
 }
 
 func (t *Transfer) TransferConvertTitle() string {
@@ -47,6 +99,10 @@ func (t *Transfer) TransferConvertTitle() string {
 		title = "合约提盈"
 	case TransferTypeCreateContract:
 		title = "创建合约"
+	case TransferTypeRepoLend:
+		title = "国债逆回购融出"
+	case TransferTypeRepoSettle:
+		title = "国债逆回购到期"
 	}
 
 	switch t.Status {
@@ -0,0 +1,13 @@
+package mw
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// Use 注册全局中间件:验签须在表单解析之前,按原始请求体计算签名
+func Use(engine *gin.Engine, verifier *SignVerifier) {
+	engine.Use(Recovery)
+	// 请求签名:app、行情接口验签,后台管理与第三方回调不验签
+	engine.Use(verifier.Handle)
+	engine.Use(ParseFormMiddleware)
+}
@@ -11,18 +11,19 @@ import (
 
 // ParseFormMiddleware parse form, such as device
 func ParseFormMiddleware(c *gin.Context) {
-	if err := c.Request.ParseForm(); err != nil {
-		log.Errorf("parse form failed: %v", err)
-	}
-
 	// 在middleware中调用一次bind读完后，后续无法使用body
 	// gin里面的ShouldBindBodyWith也不是很好
+	// 先读出body再解析表单,避免表单解析读完body后后续中间件(验签)拿到空body
 	body, err := ioutil.ReadAll(c.Request.Body)
 	if err != nil {
 		log.Errorf("read request body error: %v", err)
 	}
 	c.Request.Body = ioutil.NopCloser(bytes.NewBuffer(body))
 
+	if err := c.Request.ParseForm(); err != nil {
+		log.Errorf("parse form failed: %v", err)
+	}
+
 	//// 绑定设备
 	//var d model.Device
 	//if err := c.ShouldBind(&d); err != nil {
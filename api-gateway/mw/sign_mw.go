@@ -1,116 +1,196 @@
 package mw
 
 import (
-	"crypto/md5"
-	"encoding/base64"
-	"fmt"
-	"io"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
 	"net/http"
 	"net/url"
-	"sort"
+	"strconv"
 	"strings"
 	"time"
 
-	"stock/api-gateway/util"
 	"stock/common/env"
 	"stock/common/log"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
 )
 
-// VerifySignMiddleware 签名验证
-func VerifySignMiddleware(c *gin.Context) {
-	// 非生产环境不验签
-	if !env.GlobalEnv().IsProd() {
-		c.Next()
-		return
-	}
+// 签名请求头
+const (
+	HeaderDeviceID  = "X-Device-Id"   // 设备ID:设备注册时下发
+	HeaderTimestamp = "X-Timestamp"   // 请求时间:unix秒
+	HeaderNonce     = "X-Nonce"       // 随机串:同一设备在有效期内不能重复
+	HeaderSignature = "X-Signature"   // 签名:hex(HMAC-SHA256(设备密钥, 待签名串)),待签名串见Sign
+	signTolerance   = 5 * time.Minute // 请求时间与服务器时间允许的误差
+)
 
-	// 开始验证签名
-	// 获取随机串
-	rd := c.Request.Form.Get("rd")
-	if len(rd) == 0 {
-		c.JSON(http.StatusOK, map[string]interface{}{
-			"code": -1,
-			"msg":  "no rd param",
-		})
-		return
+// SignMode 验签模式,逐步上线:关闭->只记录日志->强制
+type SignMode string
+
+const (
+	SignModeOff     SignMode = "off"     // 不验签
+	SignModeLog     SignMode = "log"     // 验签失败只记录日志,不拦截
+	SignModeEnforce SignMode = "enforce" // 验签失败拒绝请求
+)
+
+var (
+	errSignMissing   = errors.New("缺少签名参数")
+	errSignExpired   = errors.New("请求已过期,请校准手机时间")
+	errSignNonce     = errors.New("随机串格式错误")
+	errSignDevice    = errors.New("设备未注册")
+	errSignInvalid   = errors.New("签名错误")
+	errSignReplay    = errors.New("重复的请求")
+	errSignNonceFail = errors.New("系统繁忙,请稍后再试")
+)
+
+// signExemptPrefixes 不验签的路径:后台管理、第三方支付回调、设备注册
+var signExemptPrefixes = []string{"/ping", "/cms/", "/alipay/callback", "/payment/callback/", "/device/register"}
+
+// SignKeyStore 设备签名密钥
+type SignKeyStore interface {
+	DeviceKey(ctx context.Context, deviceID string) ([]byte, error)
+}
+
+// NonceStore 随机串去重,redis.Client即可
+type NonceStore interface {
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd
+}
+
+// SignVerifier 请求签名验证
+type SignVerifier struct {
+	mode   SignMode
+	keys   SignKeyStore
+	nonces NonceStore
+	now    func() time.Time
+}
+
+// NewSignVerifier 创建验签中间件
+func NewSignVerifier(mode SignMode, keys SignKeyStore, nonces NonceStore) *SignVerifier {
+	return &SignVerifier{mode: mode, keys: keys, nonces: nonces, now: time.Now}
+}
+
+// SignModeFromEnv 验签模式:SIGN_MODE 取值off、log、enforce,默认off
+func SignModeFromEnv() SignMode {
+	v, ok := env.GlobalEnv().Get("SIGN_MODE")
+	if !ok {
+		return SignModeOff
 	}
+	switch mode := SignMode(strings.ToLower(v)); mode {
+	case SignModeLog, SignModeEnforce:
+		return mode
+	}
+	return SignModeOff
+}
 
-	// 使用这个随机串作为盐，去md5所有参数，得到签名
-	sn := c.Request.Form.Get("sn")
-	if len(sn) == 0 {
-		c.JSON(http.StatusOK, map[string]interface{}{
-			"code": -1,
-			"msg":  "no sn param",
-		})
+// Handle 验签中间件
+func (v *SignVerifier) Handle(c *gin.Context) {
+	if v.mode == SignModeOff || isSignExempt(c.Request.URL.Path) {
+		c.Next()
 		return
 	}
-
-	fn := func(tm time.Time) (bool, error) {
-		rd, err := decryptRandomString(rd, tm)
-		if err != nil {
-			log.Warnf("rd invalid %s", rd)
-			return false, err
-		}
-		expect := Sign(c.Request.Form, rd)
-		if sn != expect {
-			log.Warnf("sign invalid %s != %s(expect)", sn, expect)
-			return false, nil
+	if err := v.Verify(c.Request); err != nil {
+		log.Warnf("验签失败[%s]:%s %s device[%s],err:%v", v.mode, c.Request.Method, c.Request.URL.Path,
+			c.GetHeader(HeaderDeviceID), err)
+		if v.mode == SignModeEnforce {
+			c.AbortWithStatusJSON(http.StatusOK, map[string]interface{}{
+				"code": -1,
+				"msg":  err.Error(),
+			})
+			return
 		}
-		return true, nil
 	}
+	c.Next()
+}
 
-	// 使用今天或者昨天的日期当作密钥来解这个随机串
-	ok, err := fn(time.Now())
+// Verify 验证签名:请求时间在误差范围内,签名正确,随机串未使用过
+func (v *SignVerifier) Verify(r *http.Request) error {
+	deviceID := r.Header.Get(HeaderDeviceID)
+	timestamp := r.Header.Get(HeaderTimestamp)
+	nonce := r.Header.Get(HeaderNonce)
+	signature := r.Header.Get(HeaderSignature)
+	if len(deviceID) == 0 || len(timestamp) == 0 || len(nonce) == 0 || len(signature) == 0 {
+		return errSignMissing
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusOK, map[string]interface{}{
-			"code": -1,
-			"msg":  err.Error(),
-		})
-		return
+		return errSignExpired
 	}
-	if !ok {
-		ok, err = fn(time.Now().AddDate(0, 0, -1))
+	if diff := v.now().Sub(time.Unix(ts, 0)); diff > signTolerance || diff < -signTolerance {
+		return errSignExpired
 	}
-	if !ok || err != nil {
-		c.JSON(http.StatusOK, map[string]interface{}{
-			"code": -1,
-			"msg":  "invalid sn param",
-		})
-		return
+	if len(nonce) < 8 || len(nonce) > 64 {
+		return errSignNonce
+	}
+	ctx := r.Context()
+	key, err := v.keys.DeviceKey(ctx, deviceID)
+	if err != nil {
+		return errSignDevice
+	}
+	body, err := readBody(r)
+	if err != nil {
+		return errSignInvalid
+	}
+	if err := r.ParseForm(); err != nil {
+		return errSignInvalid
+	}
+	// 表单解析会读完请求体,恢复后供后续处理读取
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	expect := Sign(key, r.Method, r.URL.Path, r.Form, BodyHash(body), timestamp, nonce)
+	if !hmac.Equal([]byte(expect), []byte(strings.ToLower(signature))) {
+		return errSignInvalid
+	}
+	// 签名正确后再占用随机串,随机串保留到请求时间过期之后
+	ok, err := v.nonces.SetNX(ctx, "sign_nonce_"+deviceID+"_"+nonce, 1, 2*signTolerance).Result()
+	if err != nil {
+		log.Errorf("保存签名随机串失败:%+v", err)
+		return errSignNonceFail
 	}
+	if !ok {
+		return errSignReplay
+	}
+	return nil
+}
 
-	// 签名正常
-	c.Next()
+// Sign 签名:hex(HMAC-SHA256(key, METHOD\nPATH\nTIMESTAMP\nNONCE\n参数\n请求体哈希)),
+// 参数为查询串与表单按key排序后的url编码,请求体哈希见BodyHash
+func Sign(key []byte, method, path string, values url.Values, bodyHash, timestamp, nonce string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(strings.Join([]string{strings.ToUpper(method), path, timestamp, nonce, values.Encode(), bodyHash}, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
 }
 
-// Sign return signature
-func Sign(values url.Values, salt string) string {
-	list := make([]string, 0, len(values))
-	for k, v := range values {
-		if k == "sn" {
-			continue
-		}
-		kv := k + "="
-		if len(v) > 0 {
-			kv += v[0]
-		}
-		list = append(list, kv)
-	}
-	sort.Strings(list)
-	buf := strings.Join(list, "|")
-	buf += salt
+// BodyHash 请求体哈希:hex(SHA256(原始请求体)),无请求体时为空串的哈希。
+// JSON、文件上传等不进入表单的请求体也在签名范围内
+func BodyHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
 
-	h := md5.New()
-	if _, err := io.WriteString(h, buf); err != nil {
-		return ""
+// readBody 读取原始请求体并放回,不影响表单解析及后续处理
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	_ = r.Body.Close()
+	if err != nil {
+		return nil, err
 	}
-	return base64.URLEncoding.EncodeToString(h.Sum(nil))
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
 }
 
-func decryptRandomString(ciphertext string, tm time.Time) (string, error) {
-	key := RandomSecret(tm)
-	secret := []byte(fmt.Sprintf("%16b", key))
-	return util.AESDecrypt(ciphertext, secret)
+func isSignExempt(path string) bool {
+	for _, it := range signExemptPrefixes {
+		if strings.HasPrefix(path, it) {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,169 @@
+package mw
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"stock/api-gateway/fake"
+
+	"github.com/gin-gonic/gin"
+)
+
+type deviceKeys map[string][]byte
+
+func (d deviceKeys) DeviceKey(ctx context.Context, deviceID string) ([]byte, error) {
+	key, ok := d[deviceID]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return key, nil
+}
+
+func signedRequest(key []byte, deviceID, nonce string, tm time.Time, form url.Values) *http.Request {
+	all := url.Values{"code": []string{"600000"}}
+	for k, v := range form {
+		all[k] = v
+	}
+	return signedBodyRequest(key, deviceID, nonce, tm, "application/x-www-form-urlencoded", form.Encode(), all)
+}
+
+// signedBodyRequest 客户端签名:查询串、表单参数及原始请求体哈希
+func signedBodyRequest(key []byte, deviceID, nonce string, tm time.Time, contentType, body string, values url.Values) *http.Request {
+	timestamp := strconv.FormatInt(tm.Unix(), 10)
+	r := httptest.NewRequest(http.MethodPost, "/trade/buy?code=600000", strings.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+	r.Header.Set(HeaderDeviceID, deviceID)
+	r.Header.Set(HeaderTimestamp, timestamp)
+	r.Header.Set(HeaderNonce, nonce)
+	r.Header.Set(HeaderSignature, Sign(key, http.MethodPost, "/trade/buy", values, BodyHash([]byte(body)), timestamp, nonce))
+	return r
+}
+
+func TestSignVerify(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	now := time.Now()
+	v := NewSignVerifier(SignModeEnforce, deviceKeys{"d1": key}, fake.NewCache())
+	form := url.Values{"price": []string{"10.5"}, "amount": []string{"100"}}
+
+	if err := v.Verify(signedRequest(key, "d1", "nonce-0001", now, form)); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	// 重放
+	if err := v.Verify(signedRequest(key, "d1", "nonce-0001", now, form)); err != errSignReplay {
+		t.Fatalf("expect replay, got %v", err)
+	}
+	// 篡改参数
+	r := signedRequest(key, "d1", "nonce-0002", now, form)
+	r.URL.RawQuery = "code=600001"
+	if err := v.Verify(r); err != errSignInvalid {
+		t.Fatalf("expect invalid, got %v", err)
+	}
+	// 签名失败不占用随机串
+	if err := v.Verify(signedRequest(key, "d1", "nonce-0002", now, form)); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if err := v.Verify(signedRequest(key, "d1", "nonce-0003", now.Add(-6*time.Minute), form)); err != errSignExpired {
+		t.Fatalf("expect expired, got %v", err)
+	}
+	if err := v.Verify(signedRequest(key, "d2", "nonce-0004", now, form)); err != errSignDevice {
+		t.Fatalf("expect device, got %v", err)
+	}
+	if err := v.Verify(signedRequest([]byte("other"), "d1", "nonce-0005", now, form)); err != errSignInvalid {
+		t.Fatalf("expect invalid, got %v", err)
+	}
+}
+
+// TestSignVerifyBody JSON请求体不进入表单,篡改请求体签名失败,验签后请求体可再次读取
+func TestSignVerifyBody(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	now := time.Now()
+	v := NewSignVerifier(SignModeEnforce, deviceKeys{"d1": key}, fake.NewCache())
+	query := url.Values{"code": []string{"600000"}}
+	body := `{"price":10.5,"amount":100}`
+
+	r := signedBodyRequest(key, "d1", "nonce-0101", now, "application/json", body, query)
+	if err := v.Verify(r); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if got, _ := ioutil.ReadAll(r.Body); string(got) != body {
+		t.Fatalf("expect body restored, got %s", got)
+	}
+	// 篡改请求体
+	r = signedBodyRequest(key, "d1", "nonce-0102", now, "application/json", body, query)
+	r.Body = ioutil.NopCloser(strings.NewReader(`{"price":10.5,"amount":10000}`))
+	if err := v.Verify(r); err != errSignInvalid {
+		t.Fatalf("expect invalid, got %v", err)
+	}
+	// 表单请求验签后请求体同样可读取
+	form := url.Values{"amount": []string{"100"}}
+	r = signedRequest(key, "d1", "nonce-0103", now, form)
+	if err := v.Verify(r); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if got, _ := ioutil.ReadAll(r.Body); string(got) != form.Encode() || r.Form.Get("amount") != "100" {
+		t.Fatalf("expect body and form, got %s %v", got, r.Form)
+	}
+}
+
+// TestSignMiddlewareChain 按main.go的中间件顺序:表单请求验签通过,后续处理仍能读取表单及请求体
+func TestSignMiddlewareChain(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	key := []byte("0123456789abcdef0123456789abcdef")
+	now := time.Now()
+	e := gin.New()
+	Use(e, NewSignVerifier(SignModeEnforce, deviceKeys{"d1": key}, fake.NewCache()))
+	e.POST("/trade/buy", func(c *gin.Context) {
+		body, _ := ioutil.ReadAll(c.Request.Body)
+		c.String(http.StatusCreated, c.PostForm("amount")+"|"+string(body))
+	})
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, r)
+		return w
+	}
+
+	form := url.Values{"amount": []string{"100"}}
+	if w := serve(signedRequest(key, "d1", "nonce-0201", now, form)); w.Code != http.StatusCreated || w.Body.String() != "100|"+form.Encode() {
+		t.Fatalf("expect passed: %d %s", w.Code, w.Body.String())
+	}
+	// 篡改表单
+	r := signedRequest(key, "d1", "nonce-0202", now, form)
+	r.Body = ioutil.NopCloser(strings.NewReader("amount=10000"))
+	if w := serve(r); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), errSignInvalid.Error()) {
+		t.Fatalf("expect rejected: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestSignMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	serve := func(mode SignMode, path string) int {
+		e := gin.New()
+		e.Use(NewSignVerifier(mode, deviceKeys{}, fake.NewCache()).Handle)
+		e.Any(path, func(c *gin.Context) {
+			c.String(http.StatusCreated, "ok")
+		})
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+	if code := serve(SignModeOff, "/my"); code != http.StatusCreated {
+		t.Fatalf("off: %d", code)
+	}
+	if code := serve(SignModeLog, "/my"); code != http.StatusCreated {
+		t.Fatalf("log: %d", code)
+	}
+	if code := serve(SignModeEnforce, "/my"); code != http.StatusOK {
+		t.Fatalf("enforce: %d", code)
+	}
+	if code := serve(SignModeEnforce, "/cms/user/list"); code != http.StatusCreated {
+		t.Fatalf("exempt: %d", code)
+	}
+}
@@ -0,0 +1,17 @@
+package quote
+
+// GetRepoRate 查询国债逆回购最新年化利率(%):逆回购行情的最新价即为年化利率
+func (s *QtService) GetRepoRate(codes []string) (map[string]float64, error) {
+	qts, err := s.GetQuoteByTencent(codes)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]float64)
+	for code, qt := range qts {
+		if qt.CurrentPrice <= 0 {
+			continue
+		}
+		result[code] = qt.CurrentPrice
+	}
+	return result, nil
+}
@@ -1,6 +1,7 @@
 package serr
 
 import (
+	"errors"
 	"fmt"
 )
 
@@ -36,3 +37,12 @@ func ErrBusiness(msg string) *StockError {
 func ErrNoLogin() *StockError {
 	return New(ErrCodeNoLogin, "请登录")
 }
+
+// Message 获取可以反馈给用户看的消息,非业务错误返回原始错误信息
+func Message(err error) string {
+	var stockErr *StockError
+	if errors.As(err, &stockErr) {
+		return stockErr.Msg
+	}
+	return err.Error()
+}
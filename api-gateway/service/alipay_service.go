@@ -2,17 +2,26 @@ package service
 
 import (
 	"context"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
-	"net"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
 	"stock/api-gateway/dao"
 	"stock/api-gateway/model"
 	"stock/api-gateway/serr"
+	"stock/api-gateway/util"
 	"stock/common/env"
 	"stock/common/log"
+	"stock/common/timeconv"
 	"strconv"
+	"strings"
 	"sync"
-
-	"github.com/smartwalle/alipay/v3"
+	"time"
+	"unicode/utf8"
 )
 
 // AlipayService 服务
@@ -26,98 +35,171 @@ var (
 
 // AlipayServiceInstance 实例
 func AlipayServiceInstance() *AlipayService {
-	myOnce.Do(func() {
+	alipayOnce.Do(func() {
 		alipayService = &AlipayService{}
 	})
 	return alipayService
 }
 
-// AliPayNotify 支付宝回调通知
-func (s *AlipayService) AliPayNotify(ctx context.Context, orderNo, buyer string, amount float64) {
-	transfer, err := dao.TransferDaoInstance().GetByOrderNo(ctx, orderNo)
+// ReconcileDaily 每日对账:读取对账单目录(ALIPAY_BILL_DIR)下date前一日的对账单(yyyymmdd.csv),未配置目录时不对账
+func (s *AlipayService) ReconcileDaily(ctx context.Context, date time.Time) error {
+	dir, ok := env.GlobalEnv().Get("ALIPAY_BILL_DIR")
+	if !ok {
+		log.Infof("no ALIPAY_BILL_DIR config, skip alipay reconcile")
+		return nil
+	}
+	date = date.AddDate(0, 0, -1)
+	file, err := os.Open(filepath.Join(dir, fmt.Sprintf("%s.csv", date.Format("20060102"))))
 	if err != nil {
-		log.Errorf("订单号不存在:%+v", err)
-		return
+		return err
 	}
-	if transfer.Status != model.TransferStatusPre {
-		return
+	defer file.Close()
+	_, err = s.Reconcile(ctx, date, file, "")
+	return err
+}
+
+// Reconcile 按支付宝对账单核对当日支付宝充值订单,保存对账报告
+func (s *AlipayService) Reconcile(ctx context.Context, date time.Time, r io.Reader, operator string) (*model.ReconcileReport, error) {
+	bills, err := parseAlipayBill(r)
+	if err != nil {
+		return nil, err
 	}
-	user, err := dao.UserDaoInstance().GetUserByUID(ctx, transfer.UID)
+	begin := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.Local)
+	transfers, err := dao.TransferDaoInstance().GetRechargeByChannel(ctx, model.TransferChannelAlipay, begin, begin.AddDate(0, 0, 1))
 	if err != nil {
-		return
+		return nil, err
 	}
-
-	transfer.Status = model.TransferStatusSuccess
-	if err := dao.TransferDaoInstance().Create(ctx, transfer); err != nil {
-		return
+	// 跨日支付的订单按订单号补充查询
+	orderMap := make(map[string]bool)
+	for _, it := range transfers {
+		orderMap[it.OrderNo] = true
+	}
+	for _, it := range bills {
+		if orderMap[it.OrderNo] {
+			continue
+		}
+		if transfer, err := dao.TransferDaoInstance().GetByOrderNo(ctx, it.OrderNo); err == nil {
+			transfers = append(transfers, transfer)
+			orderMap[it.OrderNo] = true
+		}
 	}
 
-	// 设置用户资金
-	user.Money += transfer.Money
-	if err := dao.UserDaoInstance().CreateUser(ctx, user); err != nil {
-		return
+	report := reconcileBills(transfers, bills)
+	report.Channel = model.TransferChannelAlipay
+	report.BillDate = timeconv.TimeToInt32(begin)
+	report.Operator = operator
+	report.CreateTime = time.Now()
+	if err := dao.ReconcileReportDaoInstance().Create(ctx, report); err != nil {
+		return nil, err
+	}
+	if report.Status == model.ReconcileStatusDiff {
+		log.Errorf("支付宝%d对账有差异:%s", report.BillDate, report.Diff)
 	}
+	return report, nil
 }
 
-func (s *AlipayService) GetAlipayURL(orderNo string, money float64) (string, error) {
-	appID, ok := env.GlobalEnv().Get("APPID")
-	if !ok {
-		log.Errorf("no APPID config")
-		return "", serr.ErrBusiness("渠道错误")
-	}
-	privateKey, ok := env.GlobalEnv().Get("PRIVATEKEY") // 应用私钥
-	if !ok {
-		log.Errorf("no PRIVATEKEY config")
-		return "", serr.ErrBusiness("渠道错误")
+// reconcileBills 核对本地订单与对账单:对账单中的订单须已入账且金额一致,已入账的订单须在对账单中
+func reconcileBills(transfers []*model.Transfer, bills []*model.ChannelBill) *model.ReconcileReport {
+	report := &model.ReconcileReport{Status: model.ReconcileStatusMatch}
+	diffs := make([]*model.ReconcileDiff, 0)
+	transferMap := make(map[string]*model.Transfer)
+	for _, it := range transfers {
+		transferMap[it.OrderNo] = it
 	}
-	aliPublicKey, ok := env.GlobalEnv().Get("PUBLICKEY") // 支付宝公钥
-	if !ok {
-		log.Errorf("no PUBLICKEY config")
-		return "", serr.ErrBusiness("渠道错误")
+	billed := make(map[string]bool)
+	for _, it := range bills {
+		billed[it.OrderNo] = true
+		transfer, ok := transferMap[it.OrderNo]
+		switch {
+		case !ok:
+			diffs = append(diffs, &model.ReconcileDiff{Type: model.ReconcileDiffNoTransfer, OrderNo: it.OrderNo, TradeNo: it.TradeNo, BillMoney: it.Money})
+		case transfer.Status != model.TransferStatusSuccess:
+			diffs = append(diffs, &model.ReconcileDiff{Type: model.ReconcileDiffUnpaid, OrderNo: it.OrderNo, TradeNo: it.TradeNo,
+				LocalMoney: transfer.Money, BillMoney: it.Money, LocalStatus: transfer.Status})
+		case math.Abs(transfer.Money-it.Money) >= 0.005:
+			diffs = append(diffs, &model.ReconcileDiff{Type: model.ReconcileDiffAmount, OrderNo: it.OrderNo, TradeNo: it.TradeNo,
+				LocalMoney: transfer.Money, BillMoney: it.Money, LocalStatus: transfer.Status})
+		default:
+			report.Matched++
+			report.MatchedMoney += it.Money
+		}
 	}
-	localIP, ok := env.GlobalEnv().Get("IP") // 获取本机外网IP
-	if !ok {
-		log.Errorf("no IP config")
-		return "", serr.ErrBusiness("渠道错误")
+	for _, it := range transfers {
+		if it.Status == model.TransferStatusSuccess && !billed[it.OrderNo] {
+			diffs = append(diffs, &model.ReconcileDiff{Type: model.ReconcileDiffNoBill, OrderNo: it.OrderNo, TradeNo: it.TradeNo,
+				LocalMoney: it.Money, LocalStatus: it.Status})
+		}
 	}
-	client, err := alipay.New(appID, privateKey, env.GlobalEnv().IsProd())
-	if err != nil {
-		log.Errorf("alipay New err:%+v", err)
-		return "", err
-	}
-	if err := client.LoadAliPayPublicKey(aliPublicKey); err != nil {
-		return "", err
-	}
-	p := alipay.TradePagePay{}
-	p.NotifyURL = fmt.Sprintf("http://%s:8080/alipay/callback", localIP) //支付结果通知
-	p.Subject = "trade"
-	p.OutTradeNo = orderNo
-	p.TotalAmount = strconv.FormatFloat(money, 'f', 2, 64)
-	p.ProductCode = "FAST_INSTANT_TRADE_PAY"
-	alipayURL, err := client.TradePagePay(p)
-	if err != nil {
-		return "", err
+	report.MatchedMoney = util.FloatRound(report.MatchedMoney, 2)
+	report.DiffCount = int64(len(diffs))
+	if len(diffs) > 0 {
+		report.Status = model.ReconcileStatusDiff
 	}
-	return alipayURL.String(), nil
+	data, _ := json.Marshal(diffs)
+	report.Diff = string(data)
+	return report
 }
 
-// getPublicIP 获取本地外网
-func (s *AlipayService) getPublicIP() (string, error) {
-	// 获取本机外网地址
-	as, err := net.InterfaceAddrs()
+// parseAlipayBill 解析支付宝业务明细对账单(CSV,GBK编码,#开头为说明行),只取交易记录
+func parseAlipayBill(r io.Reader) ([]*model.ChannelBill, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	text := string(data)
+	if !utf8.ValidString(text) {
+		text = util.ConvertToString(text, "gbk", "utf-8")
+	}
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	records, err := reader.ReadAll()
 	if err != nil {
-		return "", err
+		log.Errorf("解析对账单失败:%+v", err)
+		return nil, serr.ErrBusiness("对账单格式错误")
 	}
-	for _, a := range as {
-		ipNet, ok := a.(*net.IPNet)
-		if !ok || ipNet.IP.IsLoopback() {
+
+	tradeIdx, orderIdx, moneyIdx, typeIdx := -1, -1, -1, -1
+	bills := make([]*model.ChannelBill, 0)
+	for _, record := range records {
+		if orderIdx < 0 {
+			for i, it := range record {
+				it = strings.TrimSpace(it)
+				switch {
+				case it == "支付宝交易号":
+					tradeIdx = i
+				case it == "商户订单号":
+					orderIdx = i
+				case strings.HasPrefix(it, "订单金额"):
+					moneyIdx = i
+				case it == "业务类型":
+					typeIdx = i
+				}
+			}
+			if orderIdx >= 0 && (tradeIdx < 0 || moneyIdx < 0) {
+				return nil, serr.ErrBusiness("对账单格式错误")
+			}
+			continue
+		}
+		if len(record) <= orderIdx || len(record) <= moneyIdx || len(record) <= tradeIdx {
 			continue
 		}
-		ip := ipNet.IP.To4()
-		if ip != nil &&
-			(ip[0] != 10 && ip[0] != 172 && ip[0] != 192 && ip[1] != 168) {
-			return ip.String(), nil
+		if typeIdx >= 0 && typeIdx < len(record) && strings.TrimSpace(record[typeIdx]) != "交易" {
+			continue
 		}
+		money, err := strconv.ParseFloat(strings.TrimSpace(record[moneyIdx]), 64)
+		if err != nil {
+			continue
+		}
+		bills = append(bills, &model.ChannelBill{
+			TradeNo: strings.TrimSpace(record[tradeIdx]),
+			OrderNo: strings.TrimSpace(record[orderIdx]),
+			Money:   money,
+		})
+	}
+	if orderIdx < 0 {
+		return nil, serr.ErrBusiness("对账单格式错误")
 	}
-	return "", serr.ErrBusiness("no found valid ip")
+	return bills, nil
 }
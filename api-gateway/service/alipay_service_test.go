@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"stock/api-gateway/model"
+)
+
+const testAlipayBill = `#支付宝业务明细查询
+#账号:[20880000000000000156]
+#起始日期:[2021年06月01日 00:00:00]   终止日期:[2021年06月02日 00:00:00]
+#-----------------------------------------业务明细列表----------------------------------------
+支付宝交易号,商户订单号,业务类型,商品名称,创建时间,完成时间,门店编号,门店名称,操作员,终端号,对方账户,订单金额（元）,商家实收（元）
+2021060122001\t,A001\t,交易,trade,2021-06-01 10:00:00,2021-06-01 10:00:05,,,,,abc***@qq.com,100.00,100.00
+2021060122002\t,A002\t,交易,trade,2021-06-01 11:00:00,2021-06-01 11:00:05,,,,,abc***@qq.com,50.00,50.00
+2021060122003\t,A003\t,交易,trade,2021-06-01 12:00:00,2021-06-01 12:00:05,,,,,abc***@qq.com,20.00,20.00
+2021060122004\t,A004\t,交易,trade,2021-06-01 13:00:00,2021-06-01 13:00:05,,,,,abc***@qq.com,30.00,30.00
+2021060122001\t,A001\t,退款,trade,2021-06-01 14:00:00,2021-06-01 14:00:05,,,,,abc***@qq.com,-100.00,-100.00
+#-----------------------------------------业务明细列表结束------------------------------------
+#交易合计：4笔，商家实收共200.00元
+`
+
+// TestReconcileBills 对账单与本地订单逐笔核对,识别未入账、无对账记录、金额不一致与本地无订单
+func TestReconcileBills(t *testing.T) {
+	bills, err := parseAlipayBill(strings.NewReader(strings.ReplaceAll(testAlipayBill, `\t`, "\t")))
+	if err != nil || len(bills) != 4 {
+		t.Fatalf("parse bill: %+v %+v", bills, err)
+	}
+	if bills[0].TradeNo != "2021060122001" || bills[0].OrderNo != "A001" || bills[0].Money != 100 {
+		t.Fatalf("bill: %+v", bills[0])
+	}
+	if _, err := parseAlipayBill(strings.NewReader("a,b,c\n1,2,3\n")); err == nil {
+		t.Fatal("expect invalid bill fail")
+	}
+
+	transfers := []*model.Transfer{
+		{OrderNo: "A001", Money: 100, Status: model.TransferStatusSuccess},
+		{OrderNo: "A002", Money: 50, Status: model.TransferStatusPre},
+		{OrderNo: "A003", Money: 25, Status: model.TransferStatusSuccess},
+		{OrderNo: "A005", Money: 60, Status: model.TransferStatusSuccess},
+		{OrderNo: "A006", Money: 70, Status: model.TransferStatusFail},
+	}
+	report := reconcileBills(transfers, bills)
+	if report.Status != model.ReconcileStatusDiff || report.Matched != 1 || report.DiffCount != 4 {
+		t.Fatalf("report: %+v", report)
+	}
+	assertMoney(t, "matched money", report.MatchedMoney, 100)
+	for _, want := range []string{`"order_no":"A002","trade_no":"2021060122002"`, `"type":3,"order_no":"A003"`,
+		`"type":4,"order_no":"A004"`, `"type":2,"order_no":"A005"`} {
+		if !strings.Contains(report.Diff, want) {
+			t.Fatalf("expect diff %s in %s", want, report.Diff)
+		}
+	}
+
+	report = reconcileBills(transfers[:1], bills[:1])
+	if report.Status != model.ReconcileStatusMatch || report.Diff != "[]" {
+		t.Fatalf("report: %+v", report)
+	}
+}
+
+// newTestAlipayChannel 使用测试密钥的支付宝渠道,返回模拟支付宝签名的私钥
+func newTestAlipayChannel(t *testing.T) (*alipayChannel, *rsa.PrivateKey) {
+	appKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("generate key: %+v", err)
+	}
+	aliKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("generate key: %+v", err)
+	}
+	aliPublicKey, err := x509.MarshalPKIXPublicKey(&aliKey.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal key: %+v", err)
+	}
+	return &alipayChannel{
+		appID:      "2021000000000001",
+		privateKey: base64.StdEncoding.EncodeToString(x509.MarshalPKCS1PrivateKey(appKey)),
+		publicKey:  base64.StdEncoding.EncodeToString(aliPublicKey),
+	}, aliKey
+}
+
+// signAlipayNotify 按支付宝RSA2规则签名:除sign、sign_type外的非空参数按参数名排序拼接
+func signAlipayNotify(t *testing.T, key *rsa.PrivateKey, form url.Values) url.Values {
+	keys := make([]string, 0, len(form))
+	for k := range form {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if v := form.Get(k); v != "" {
+			pairs = append(pairs, k+"="+v)
+		}
+	}
+	hash := sha256.Sum256([]byte(strings.Join(pairs, "&")))
+	sign, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		t.Fatalf("sign: %+v", err)
+	}
+	form.Set("sign", base64.StdEncoding.EncodeToString(sign))
+	form.Set("sign_type", "RSA2")
+	return form
+}
+
+// TestAlipayNotify 支付宝异步通知:验签失败、应用ID不一致、通知过期不入账,同一notify_id只处理一次
+func TestAlipayNotify(t *testing.T) {
+	ctx := context.Background()
+	store, _, svc := newFakeServices()
+	channel, aliKey := newTestAlipayChannel(t)
+	registerPaymentChannel(t, channel)
+	store.SetSysParam(&model.SysParam{AlipayChannel: true})
+	user := store.PutUser(&model.User{Status: model.UserStatusActive})
+	for _, orderNo := range []string{"A100", "A200"} {
+		store.PutTransfer(&model.Transfer{UID: user.ID, Money: 100, Type: model.TransferTypeRecharge,
+			Status: model.TransferStatusPre, Channel: model.TransferChannelAlipay, OrderNo: orderNo, OrderTime: time.Now()})
+	}
+	notify := func(orderNo, notifyID, appID string, notifyTime time.Time) url.Values {
+		return url.Values{
+			"app_id":       {appID},
+			"notify_id":    {notifyID},
+			"notify_time":  {notifyTime.Format("2006-01-02 15:04:05")},
+			"notify_type":  {"trade_status_sync"},
+			"out_trade_no": {orderNo},
+			"trade_no":     {"T" + orderNo},
+			"trade_status": {"TRADE_SUCCESS"},
+			"total_amount": {"100.00"},
+		}
+	}
+	status := func(orderNo string) int64 {
+		for _, it := range store.Transfers(user.ID) {
+			if it.OrderNo == orderNo {
+				return it.Status
+			}
+		}
+		return 0
+	}
+
+	// 1.验签失败:签名后篡改金额、其他密钥签名
+	form := signAlipayNotify(t, aliKey, notify("A100", "n1", channel.appID, time.Now()))
+	form.Set("total_amount", "1.00")
+	if err := svc.Recharge.Notify(ctx, "alipay", notifyRequest(form)); err == nil {
+		t.Fatal("expect tampered notify fail")
+	}
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	form = signAlipayNotify(t, otherKey, notify("A100", "n1", channel.appID, time.Now()))
+	if err := svc.Recharge.Notify(ctx, "alipay", notifyRequest(form)); err == nil {
+		t.Fatal("expect bad signature fail")
+	}
+	// 2.应用ID不一致
+	form = signAlipayNotify(t, aliKey, notify("A100", "n1", "2021000000000002", time.Now()))
+	if err := svc.Recharge.Notify(ctx, "alipay", notifyRequest(form)); err == nil {
+		t.Fatal("expect wrong app_id fail")
+	}
+	// 3.通知已过期
+	form = signAlipayNotify(t, aliKey, notify("A100", "n1", channel.appID, time.Now().Add(-alipayNotifyExpire-time.Hour)))
+	if err := svc.Recharge.Notify(ctx, "alipay", notifyRequest(form)); err == nil {
+		t.Fatal("expect stale notify fail")
+	}
+	if s := status("A100"); s != model.TransferStatusPre {
+		t.Fatalf("expect pre after rejected notify, got %d", s)
+	}
+
+	// 4.验签通过入账
+	form = signAlipayNotify(t, aliKey, notify("A100", "n1", channel.appID, time.Now()))
+	if err := svc.Recharge.Notify(ctx, "alipay", notifyRequest(form)); err != nil {
+		t.Fatalf("notify: %+v", err)
+	}
+	if s := status("A100"); s != model.TransferStatusSuccess {
+		t.Fatalf("expect success, got %d", s)
+	}
+
+	// 5.已处理的notify_id重放不再处理
+	form = signAlipayNotify(t, aliKey, notify("A200", "n1", channel.appID, time.Now()))
+	if err := svc.Recharge.Notify(ctx, "alipay", notifyRequest(form)); err != nil {
+		t.Fatalf("replay: %+v", err)
+	}
+	if s := status("A200"); s != model.TransferStatusPre {
+		t.Fatalf("expect replayed notify ignored, got %d", s)
+	}
+	u, _ := svc.core.User.GetUserByUID(ctx, user.ID)
+	assertMoney(t, "money", u.Money, 100)
+}
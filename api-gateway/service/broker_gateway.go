@@ -0,0 +1,49 @@
+package service
+
+import (
+	"stock/api-gateway/model"
+	"stock/api-gateway/quote"
+	"stock/common/env"
+	"stock/common/log"
+	"strconv"
+)
+
+// BrokerGateway 券商柜台:登录、委托、撤单及资金、持仓、当日委托、可撤单查询;
+// QueryOrderEvents 只查询指定的未终态券商委托,返回相对券商委托表的成交、撤单、废单事件
+type BrokerGateway interface {
+	Login(broker *model.Broker) (int64, error)
+	Entrust(entrust *model.BrokerEntrust) error
+	CancelOrder(entrust *model.BrokerEntrust, broker *model.Broker, entrustNo string) error
+	QueryFund(broker *model.Broker) (*model.TDXBrokerFund, error)
+	QueryPosition(broker *model.Broker) ([]*model.TDXPosition, error)
+	QueryTodayEntrust(broker *model.Broker) ([]*model.TDXTodayEntrust, error)
+	QueryWithdraw(broker *model.Broker) ([]*model.TDXWithdraw, error)
+	QueryOrderEvents(broker *model.Broker, open []*model.BrokerEntrust) ([]*model.BrokerOrderEvent, error)
+}
+
+var (
+	_ BrokerGateway = (*TDXService)(nil)
+	_ BrokerGateway = (*BrokerSimulator)(nil)
+)
+
+const (
+	brokerGatewayTDX       = "tdx"      // 通达信HTTP桥
+	brokerGatewaySimulator = "sim"      // 本地模拟柜台
+	brokerSimulatorCash    = 10000000.0 // 模拟柜台资金账号初始资金
+)
+
+// newBrokerGateway 券商柜台:BROKER_GATEWAY=sim 使用本地模拟柜台,初始资金BROKER_SIM_CASH;默认通达信
+func newBrokerGateway() BrokerGateway {
+	gateway, _ := env.GlobalEnv().Get("BROKER_GATEWAY")
+	if gateway != brokerGatewaySimulator {
+		return TDXServiceInstance()
+	}
+	cash := brokerSimulatorCash
+	if v, ok := env.GlobalEnv().Get("BROKER_SIM_CASH"); ok {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 {
+			cash = f
+		}
+	}
+	log.Infof("使用模拟券商柜台,初始资金:%0.2f", cash)
+	return NewBrokerSimulator(quote.QtServiceInstance(), cash)
+}
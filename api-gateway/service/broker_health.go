@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"stock/api-gateway/model"
+	"stock/common/log"
+	"time"
+)
+
+const (
+	brokerDownFailures = 3                // 连续查询失败N次断开连接
+	brokerRetryBase    = 5 * time.Second  // 重连退避初始间隔
+	brokerRetryMax     = 10 * time.Minute // 重连退避最大间隔
+	brokerConnTick     = 5 * time.Second  // 检查重连间隔,实际重连时间由退避控制
+)
+
+// Health 券商通道健康状态,未连接过的券商返回nil
+func (s *BrokerService) Health(brokerID int64) *model.BrokerHealth {
+	brokerMutex.Lock()
+	defer brokerMutex.Unlock()
+	h, ok := s.health[brokerID]
+	if !ok {
+		return nil
+	}
+	res := *h
+	return &res
+}
+
+// healthy 熔断:只向状态正常的券商分配新委托,未记录状态的视为正常
+func (s *BrokerService) healthy(brokerID int64) bool {
+	h := s.Health(brokerID)
+	return h == nil || h.State == model.BrokerHealthConnected
+}
+
+// canRetry 是否到达重连时间
+func (s *BrokerService) canRetry(brokerID int64, now time.Time) bool {
+	h := s.Health(brokerID)
+	return h == nil || !now.Before(h.RetryTime)
+}
+
+// markConnected 登录或查询成功,恢复正常
+func (s *BrokerService) markConnected(ctx context.Context, broker *model.Broker) {
+	brokerMutex.Lock()
+	h := s.getHealth(broker.ID)
+	h.Failures = 0
+	h.Retries = 0
+	h.RetryTime = time.Time{}
+	old := s.setState(h, model.BrokerHealthConnected, "")
+	brokerMutex.Unlock()
+	s.notifyHealth(ctx, broker, old, h)
+}
+
+// markQueryFailure 查询失败:降级熔断,连续失败达到次数则断开连接,等待退避重连
+func (s *BrokerService) markQueryFailure(ctx context.Context, broker *model.Broker, err error) {
+	brokerMutex.Lock()
+	h := s.getHealth(broker.ID)
+	h.Failures++
+	state := int64(model.BrokerHealthDegraded)
+	if h.Failures >= brokerDownFailures {
+		state = model.BrokerHealthDown
+		h.Retries = 0
+		h.RetryTime = time.Now().Add(brokerBackoff(h.Retries))
+		delete(s.brokerMap, broker.ID)
+	}
+	old := s.setState(h, state, err.Error())
+	brokerMutex.Unlock()
+	s.notifyHealth(ctx, broker, old, h)
+	if old != model.BrokerHealthDown && state == model.BrokerHealthDown {
+		if err := s.failover(ctx, broker.ID); err != nil {
+			log.Errorf("failover err:%+v", err)
+		}
+	}
+}
+
+// markConnFailure 登录失败,退避时间翻倍
+func (s *BrokerService) markConnFailure(ctx context.Context, broker *model.Broker, err error) {
+	brokerMutex.Lock()
+	h := s.getHealth(broker.ID)
+	h.Retries++
+	h.RetryTime = time.Now().Add(brokerBackoff(h.Retries))
+	old := s.setState(h, model.BrokerHealthDown, err.Error())
+	brokerMutex.Unlock()
+	s.notifyHealth(ctx, broker, old, h)
+}
+
+// getHealth 调用方持有brokerMutex
+func (s *BrokerService) getHealth(brokerID int64) *model.BrokerHealth {
+	h, ok := s.health[brokerID]
+	if !ok {
+		h = &model.BrokerHealth{}
+		s.health[brokerID] = h
+	}
+	return h
+}
+
+// setState 更新状态,返回原状态
+func (s *BrokerService) setState(h *model.BrokerHealth, state int64, lastError string) int64 {
+	old := h.State
+	if lastError != "" {
+		h.LastError = lastError
+	}
+	if old != state {
+		h.State = state
+		h.ChangeTime = time.Now()
+	}
+	return old
+}
+
+// notifyHealth 状态变更时短信提醒管理员,首次连接成功不提醒
+func (s *BrokerService) notifyHealth(ctx context.Context, broker *model.Broker, old int64, h *model.BrokerHealth) {
+	if old == h.State || (old == 0 && h.State == model.BrokerHealthConnected) {
+		return
+	}
+	content := fmt.Sprintf("券商通道[%d]%s 资金账号:%s 状态:%s", broker.ID, broker.BrokerName, broker.FundAccount, model.BrokerHealthMap[h.State])
+	if h.State != model.BrokerHealthConnected {
+		content += ",原因:" + h.LastError
+	}
+	log.Infof("%s", content)
+	sys, err := s.core.Sys.GetSysParam(ctx)
+	if err != nil {
+		log.Errorf("GetSysParam err:%+v", err)
+		return
+	}
+	if len(sys.AdminPhone) == 0 {
+		return
+	}
+	if err := s.core.Sms.SendSms(ctx, content, sys.AdminPhone); err != nil {
+		log.Errorf("SendSms err:%+v", err)
+	}
+}
+
+// brokerBackoff 第n次重连失败后的等待时间:指数退避,不超过最大间隔
+func brokerBackoff(retries int64) time.Duration {
+	d := brokerRetryBase
+	for i := int64(0); i < retries; i++ {
+		d *= 2
+		if d >= brokerRetryMax {
+			return brokerRetryMax
+		}
+	}
+	return d
+}
+
+// failover 券商断开后按系统参数处理未成交委托:等待重连后继续查询成交,或转模拟撮合。
+// 只转移全部申报在该券商且未成交的委托,部分成交、分笔到其他券商的委托等待重连
+func (s *BrokerService) failover(ctx context.Context, brokerID int64) error {
+	sys, err := s.core.Sys.GetSysParam(ctx)
+	if err != nil {
+		return err
+	}
+	if sys.BrokerFailoverMode != model.BrokerFailoverSimulate {
+		return nil
+	}
+	entrusts, err := s.core.Entrust.GetTodayEntrusts(ctx)
+	if err != nil {
+		return err
+	}
+	for _, entrust := range entrusts {
+		if !entrust.IsBrokerEntrust || entrust.Status != model.EntrustStatusTypeReported || entrust.DealAmount > 0 {
+			continue
+		}
+		if err := s.failoverEntrust(ctx, brokerID, entrust.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// failoverEntrust 委托全部申报在断开的券商且无成交时转模拟撮合,与券商委托事件、撤单互斥。
+// 券商委托标记为待撤单,券商重连后撤单,撤单前的成交仍按委托事件入账后才终态
+func (s *BrokerService) failoverEntrust(ctx context.Context, brokerID int64, entrustID int64) error {
+	defer lockEntrust(entrustID)()
+	entrust, err := s.core.Entrust.GetEntrustByID(ctx, entrustID)
+	if err != nil {
+		return err
+	}
+	if !entrust.IsBrokerEntrust || entrust.Status != model.EntrustStatusTypeReported || entrust.DealAmount > 0 {
+		return nil
+	}
+	list, err := s.core.BrokerEntrust.GetByEntrustID(ctx, entrustID)
+	if err != nil || len(list) == 0 {
+		return err
+	}
+	for _, it := range list {
+		if it.BrokerID != brokerID || it.DealAmount > 0 || it.IsFinallyState() {
+			return nil
+		}
+	}
+	for _, it := range list {
+		it.Status = model.EntrustStatusTypeFailoverCancel
+	}
+	if err := s.core.BrokerEntrust.MCreate(ctx, list); err != nil {
+		return err
+	}
+	entrust.IsBrokerEntrust = false
+	entrust.Status = model.EntrustStatusTypeUnDeal
+	entrust.Remark = "券商通道断开,转模拟撮合"
+	if err := s.core.Entrust.Update(ctx, entrust); err != nil {
+		return err
+	}
+	log.Infof("券商:%d 断开,委托:%d 转模拟撮合", brokerID, entrust.ID)
+	return nil
+}
+
+// cancelFailover 券商重连后撤销转模拟撮合的券商委托,撤单成功后等待撤单;
+// 撤单失败(已成交、已撤单或柜台异常)保持待撤单,由委托事件终态或下次查询重试
+func (s *BrokerService) cancelFailover(ctx context.Context, broker *model.Broker, row *model.BrokerEntrust) error {
+	defer lockEntrust(row.EntrustID)()
+	rows, err := s.core.BrokerEntrust.GetByEntrustID(ctx, row.EntrustID)
+	if err != nil {
+		return err
+	}
+	for _, it := range rows {
+		if it.ID != row.ID || it.Status != model.EntrustStatusTypeFailoverCancel {
+			continue
+		}
+		if err := s.gateway.CancelOrder(it, broker, it.BrokerEntrustNo); err != nil {
+			log.Errorf("券商:%d 委托编号:%s 转模拟撮合撤单失败:%+v", broker.ID, it.BrokerEntrustNo, err)
+			return nil
+		}
+		it.Status = model.EntrustStatusTypeWithdrawing
+		if err := s.core.BrokerEntrust.MCreate(ctx, []*model.BrokerEntrust{it}); err != nil {
+			return err
+		}
+		row.Status = it.Status
+	}
+	return nil
+}
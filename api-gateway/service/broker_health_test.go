@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"stock/api-gateway/fake"
+	"stock/api-gateway/model"
+)
+
+// TestBrokerHealth 查询失败降级熔断->断开转模拟撮合->退避重连->恢复
+func TestBrokerHealth(t *testing.T) {
+	ctx := context.Background()
+	store, _, sim, broker := newSimBrokerCore(t)
+	store.SetSysParam(&model.SysParam{
+		BuyFee: 0.0003, SellFee: 0.0013, MiniChargeFee: 5, LowWarnCanBuy: true, IsSupportBroker: true,
+		AdminPhone: "13800000000", BrokerFailoverMode: model.BrokerFailoverSimulate,
+	})
+	sms := &fake.Sms{}
+	broker.core.Sms = sms
+	brokerID := broker.GetBrokers()[0].ID
+	if h := broker.Health(brokerID); h == nil || h.State != model.BrokerHealthConnected {
+		t.Fatalf("expect connected: %+v", h)
+	}
+
+	user := store.PutUser(&model.User{Status: model.UserStatusActive})
+	contract := store.PutContract(&model.Contract{
+		UID: user.ID, InitMoney: 10000, Money: 10000, ValMoney: 20000, Lever: 1, Status: model.ContractStatusEnable,
+	})
+	if err := broker.trade.Buy(ctx, &model.EntrustPackage{
+		UID: user.ID, ContractID: contract.ID, Code: "600000", Price: 10, Amount: 1000, EntrustProp: model.EntrustPropTypeLimitPrice,
+	}); err != nil {
+		t.Fatalf("buy: %+v", err)
+	}
+	entrust := waitBrokerEntrust(t, broker.core, contract.ID)
+
+	// 1.查询失败降级:不影响查询轮次,熔断不分配新委托
+	sim.SetOffline("sim001", true)
+	if err := broker.query(ctx); err != nil {
+		t.Fatalf("query: %+v", err)
+	}
+	if h := broker.Health(brokerID); h.State != model.BrokerHealthDegraded || h.Failures != 1 {
+		t.Fatalf("expect degraded: %+v", h)
+	}
+	if _, err := broker.route(ctx, routeBuy(user.ID, 100)); err == nil {
+		t.Fatal("expect circuit open")
+	}
+
+	// 2.连续失败断开:未成交委托转模拟撮合
+	for i := 0; i < brokerDownFailures-1; i++ {
+		if err := broker.query(ctx); err != nil {
+			t.Fatalf("query: %+v", err)
+		}
+	}
+	h := broker.Health(brokerID)
+	if h.State != model.BrokerHealthDown || len(broker.GetBrokers()) != 0 || !h.RetryTime.After(time.Now()) {
+		t.Fatalf("expect down: %+v", h)
+	}
+	e, _ := broker.core.Entrust.GetEntrustByID(ctx, entrust.ID)
+	if e.IsBrokerEntrust || e.Status != model.EntrustStatusTypeUnDeal {
+		t.Fatalf("expect simulated entrust: %+v", e)
+	}
+	brokerEntrusts, _ := broker.core.BrokerEntrust.GetByEntrustID(ctx, entrust.ID)
+	if len(brokerEntrusts) != 1 || brokerEntrusts[0].Status != model.EntrustStatusTypeFailoverCancel {
+		t.Fatalf("expect broker entrust pending cancel: %+v", brokerEntrusts)
+	}
+
+	// 3.未到重连时间不重连,重连失败退避翻倍
+	if err := broker.clientConn(ctx); err != nil {
+		t.Fatalf("clientConn: %+v", err)
+	}
+	if h := broker.Health(brokerID); h.Retries != 0 {
+		t.Fatalf("expect no retry before backoff: %+v", h)
+	}
+	broker.health[brokerID].RetryTime = time.Time{}
+	if err := broker.clientConn(ctx); err != nil {
+		t.Fatalf("clientConn: %+v", err)
+	}
+	if h := broker.Health(brokerID); h.Retries != 1 || h.RetryTime.Sub(time.Now()) <= brokerRetryBase {
+		t.Fatalf("expect backoff: %+v", h)
+	}
+
+	// 4.恢复连接
+	sim.SetOffline("sim001", false)
+	broker.health[brokerID].RetryTime = time.Time{}
+	if err := broker.clientConn(ctx); err != nil {
+		t.Fatalf("clientConn: %+v", err)
+	}
+	if h := broker.Health(brokerID); h.State != model.BrokerHealthConnected || len(broker.GetBrokers()) != 1 {
+		t.Fatalf("expect reconnected: %+v", h)
+	}
+	// 重连后撤销待撤单的券商委托
+	if _, _, err := broker.pollBroker(ctx, broker.GetBrokers()[0], false); err != nil {
+		t.Fatalf("poll: %+v", err)
+	}
+	brokerEntrusts, _ = broker.core.BrokerEntrust.GetByEntrustID(ctx, entrust.ID)
+	if brokerEntrusts[0].Status != model.EntrustStatusTypeWithdraw {
+		t.Fatalf("expect broker entrust withdrawn: %+v", brokerEntrusts[0])
+	}
+
+	// 降级、断开、恢复各提醒一次
+	if len(sms.Sent) != 3 || !strings.Contains(sms.Sent[2], "正常") {
+		t.Fatalf("alerts: %+v", sms.Sent)
+	}
+}
+
+// TestBrokerFailoverFill 转模拟撮合后券商在断开期间成交:重连后撤单失败,成交入账后券商委托才终态
+func TestBrokerFailoverFill(t *testing.T) {
+	ctx := context.Background()
+	store, _, sim, broker := newSimBrokerCore(t)
+	store.SetSysParam(&model.SysParam{
+		BuyFee: 0.0003, SellFee: 0.0013, MiniChargeFee: 5, LowWarnCanBuy: true, IsSupportBroker: true,
+		BrokerFailoverMode: model.BrokerFailoverSimulate,
+	})
+	online := broker.GetBrokers()[0]
+	user := store.PutUser(&model.User{Status: model.UserStatusActive})
+	contract := store.PutContract(&model.Contract{
+		UID: user.ID, InitMoney: 10000, Money: 10000, ValMoney: 20000, Lever: 1, Status: model.ContractStatusEnable,
+	})
+	if err := broker.trade.Buy(ctx, &model.EntrustPackage{
+		UID: user.ID, ContractID: contract.ID, Code: "600000", Price: 10, Amount: 1000, EntrustProp: model.EntrustPropTypeLimitPrice,
+	}); err != nil {
+		t.Fatalf("buy: %+v", err)
+	}
+	entrust := waitBrokerEntrust(t, broker.core, contract.ID)
+
+	sim.SetOffline("sim001", true)
+	for i := 0; i < brokerDownFailures; i++ {
+		if err := broker.query(ctx); err != nil {
+			t.Fatalf("query: %+v", err)
+		}
+	}
+	if e, _ := broker.core.Entrust.GetEntrustByID(ctx, entrust.ID); e.IsBrokerEntrust {
+		t.Fatalf("expect simulated entrust: %+v", e)
+	}
+	// 断开期间券商成交
+	sim.SetOffline("sim001", false)
+	if _, err := sim.QueryTodayEntrust(online); err != nil {
+		t.Fatalf("match: %+v", err)
+	}
+	broker.health[online.ID].RetryTime = time.Time{}
+	if err := broker.clientConn(ctx); err != nil {
+		t.Fatalf("clientConn: %+v", err)
+	}
+	if _, events, err := broker.pollBroker(ctx, broker.GetBrokers()[0], false); err != nil || events != 1 {
+		t.Fatalf("poll: %d %+v", events, err)
+	}
+	brokerEntrusts, _ := broker.core.BrokerEntrust.GetByEntrustID(ctx, entrust.ID)
+	if brokerEntrusts[0].Status != model.EntrustStatusTypeDeal || brokerEntrusts[0].DealAmount != 1000 {
+		t.Fatalf("expect broker deal: %+v", brokerEntrusts[0])
+	}
+	assertMoney(t, "broker cash", store.LedgerBalance(model.BrokerCashAccount(online.ID)), -10000-brokerEntrusts[0].Fee)
+	if e, _ := broker.core.Entrust.GetEntrustByID(ctx, entrust.ID); e.IsBrokerEntrust {
+		t.Fatalf("expect simulated entrust unchanged: %+v", e)
+	}
+}
+
+func TestBrokerBackoff(t *testing.T) {
+	for retries, want := range []time.Duration{5 * time.Second, 10 * time.Second, 20 * time.Second} {
+		if got := brokerBackoff(int64(retries)); got != want {
+			t.Fatalf("backoff(%d): expect %v, got %v", retries, want, got)
+		}
+	}
+	if got := brokerBackoff(100); got != brokerRetryMax {
+		t.Fatalf("expect max backoff, got %v", got)
+	}
+}
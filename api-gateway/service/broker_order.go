@@ -0,0 +1,361 @@
+package service
+
+import (
+	"context"
+	"stock/api-gateway/model"
+	"stock/common/log"
+	"sync"
+	"time"
+)
+
+const (
+	brokerPollMin     = 500 * time.Millisecond // 有成交、撤单事件或刚申报时的查询间隔
+	brokerPollMax     = 3 * time.Second        // 有未终态委托但无事件时,查询间隔逐步放慢到该值
+	brokerPollIdle    = 5 * time.Second        // 无未终态委托时的查询间隔
+	brokerRefreshTick = 10 * time.Second       // 无事件时资金、持仓的刷新间隔
+)
+
+// entrustLocks 委托锁:同一委托的券商事件、撤单、申报、断开转移串行处理,按委托ID分段
+var entrustLocks [64]sync.Mutex
+
+// lockEntrust 锁定委托,返回解锁函数
+func lockEntrust(entrustID int64) func() {
+	mu := &entrustLocks[uint64(entrustID)%uint64(len(entrustLocks))]
+	mu.Lock()
+	return mu.Unlock
+}
+
+// tdxOrderEvents 按通达信当日委托状态生成未终态券商委托的事件:已成、部撤、已撤、废单为终态事件,
+// 其他状态成交数量增加时为部分成交事件
+func tdxOrderEvents(broker *model.Broker, list []*model.TDXTodayEntrust, open []*model.BrokerEntrust) []*model.BrokerOrderEvent {
+	tdxMap := make(map[string]*model.TDXTodayEntrust, len(list))
+	for _, it := range list {
+		tdxMap[it.EntrustNo] = it
+	}
+	events := make([]*model.BrokerOrderEvent, 0)
+	for _, it := range open {
+		tdx, ok := tdxMap[it.BrokerEntrustNo]
+		if !ok || it.BrokerEntrustNo == "" {
+			continue
+		}
+		event := &model.BrokerOrderEvent{
+			BrokerID:   broker.ID,
+			EntrustNo:  tdx.EntrustNo,
+			DealAmount: tdx.DealAmount,
+			DealPrice:  tdx.DealPrice,
+			Final:      true,
+		}
+		switch tdx.Status {
+		case "已成":
+			event.Type = model.BrokerOrderFill
+		case "部撤", "已撤":
+			event.Type = model.BrokerOrderCancel
+		case "废单":
+			event.Type = model.BrokerOrderReject
+		default:
+			if tdx.DealAmount <= it.DealAmount {
+				continue
+			}
+			event.Type = model.BrokerOrderFill
+			event.Final = false
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// applyOrderEvent 券商委托状态机,返回状态是否变化:
+// 已申报/部分成交/等待撤单/待撤单 -成交-> 部分成交/全部成交;-撤单-> 已撤单/部成部撤;-废单-> 废单。
+// 终态不再变化,成交数量只增不减,重复或过期的事件忽略
+func applyOrderEvent(row *model.BrokerEntrust, event *model.BrokerOrderEvent) bool {
+	if row.IsFinallyState() || event.DealAmount < row.DealAmount {
+		return false
+	}
+	setDeal := func() {
+		if event.DealAmount <= 0 {
+			return
+		}
+		row.DealAmount = event.DealAmount
+		row.DealPrice = event.DealPrice
+		row.DealBalance = float64(row.DealAmount) * row.DealPrice
+	}
+	switch event.Type {
+	case model.BrokerOrderFill:
+		if event.DealAmount == row.DealAmount && !event.Final {
+			return false
+		}
+		setDeal()
+		if event.Final || row.DealAmount >= row.EntrustAmount {
+			row.Status = model.EntrustStatusTypeDeal
+		} else if row.Status != model.EntrustStatusTypeWithdrawing && row.Status != model.EntrustStatusTypeFailoverCancel {
+			// 撤单中、待撤单的委托部分成交状态不变,等待撤单结果
+			row.Status = model.EntrustStatusTypePartDeal
+		}
+	case model.BrokerOrderCancel:
+		setDeal()
+		row.Status = model.EntrustStatusTypeWithdraw
+		if row.DealAmount > 0 {
+			row.Status = model.EntrustStatusTypePartDealPartWithdraw
+		}
+	case model.BrokerOrderReject:
+		row.Status = model.EntrustStatusTypeCancel
+	default:
+		return false
+	}
+	return true
+}
+
+// onOrderEvent 处理券商委托事件:更新券商委托表,委托拆分的券商委托全部终态后结算委托,
+// 结算失败时券商委托表不更新,下次查询重新生成事件
+func (s *BrokerService) onOrderEvent(ctx context.Context, entrustID int64, event *model.BrokerOrderEvent) error {
+	defer lockEntrust(entrustID)()
+	rows, err := s.core.BrokerEntrust.GetByEntrustID(ctx, entrustID)
+	if err != nil {
+		return err
+	}
+	var row *model.BrokerEntrust
+	for _, it := range rows {
+		if it.BrokerID == event.BrokerID && it.BrokerEntrustNo == event.EntrustNo {
+			row = it
+			break
+		}
+	}
+	if row == nil || !applyOrderEvent(row, event) {
+		return nil
+	}
+	log.Infof("券商委托事件:券商:%d 委托编号:%s %s 累计成交:%d 券商委托:%d 状态:%s", event.BrokerID, event.EntrustNo,
+		model.BrokerOrderEventMap[event.Type], event.DealAmount, row.ID, model.EntrustStatusMap[row.Status])
+
+	for _, it := range rows {
+		if !it.IsFinallyState() {
+			return s.core.BrokerEntrust.MCreate(ctx, []*model.BrokerEntrust{row})
+		}
+	}
+	entrust, err := s.core.Entrust.GetEntrustByID(ctx, entrustID)
+	if err != nil {
+		return err
+	}
+	// 已转模拟撮合后券商仍有成交:券商委托表与券商成交凭证同时入账,保持券商资金、持仓可对账
+	if !entrust.IsBrokerEntrust {
+		return s.settleFailover(ctx, entrust, rows)
+	}
+	// 已结算的委托只更新券商委托表
+	if entrust.IsFinallyState() {
+		return s.core.BrokerEntrust.MCreate(ctx, rows)
+	}
+	return s.trade.brokerSettle(ctx, s.trade.genEntrust(ctx, entrust, rows))
+}
+
+// settleFailover 转模拟撮合的委托在券商终态:有成交时记入券商资金,委托已按模拟撮合结算不再变化
+func (s *BrokerService) settleFailover(ctx context.Context, entrust *model.Entrust, rows []*model.BrokerEntrust) error {
+	entrust.BrokerEntrust = rows
+	journal := brokerDealJournal(entrust)
+	if len(journal.Entries) == 0 {
+		return s.core.BrokerEntrust.MCreate(ctx, rows)
+	}
+	log.Warnf("委托:%d 转模拟撮合后券商成交,券商持仓、资金按成交入账,请核对", entrust.ID)
+	tx := s.core.Tx.Begin(ctx)
+	defer tx.Rollback()
+	if err := s.core.BrokerEntrust.MCreateWithTx(tx, rows); err != nil {
+		return err
+	}
+	if err := s.ledger.PostWithTx(tx, journal); err != nil {
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// pollBroker 增量查询单个券商:只查询未终态的券商委托并处理事件,有事件或refresh时刷新资金、持仓。
+// 券商查询失败记入通道健康状态,返回数据库错误
+func (s *BrokerService) pollBroker(ctx context.Context, broker *model.Broker, refresh bool) (open int, events int, err error) {
+	// 与该券商的对账互斥,避免对账期间成交入账
+	mu := s.brokerLock(broker.ID)
+	mu.Lock()
+	defer mu.Unlock()
+
+	list, err := s.core.BrokerEntrust.GetOpenByBrokerID(ctx, broker.ID)
+	if err != nil {
+		log.Errorf("GetOpenByBrokerID err:%+v", err)
+		return 0, 0, err
+	}
+	if len(list) > 0 {
+		// 先撤销转模拟撮合的委托,撤单结果及撤单前的成交由本次查询的委托事件处理
+		for _, it := range list {
+			if it.Status != model.EntrustStatusTypeFailoverCancel {
+				continue
+			}
+			if err := s.cancelFailover(ctx, broker, it); err != nil {
+				log.Errorf("cancelFailover err:%+v", err)
+			}
+		}
+		orderEvents, err := s.gateway.QueryOrderEvents(broker, list)
+		if err != nil {
+			log.Errorf("资金账号:%+v 查询委托失败:%+v", broker.FundAccount, err)
+			s.markQueryFailure(ctx, broker, err)
+			return len(list), 0, nil
+		}
+		entrustIDs := make(map[string]int64, len(list))
+		for _, it := range list {
+			entrustIDs[it.BrokerEntrustNo] = it.EntrustID
+		}
+		for _, event := range orderEvents {
+			if err := s.onOrderEvent(ctx, entrustIDs[event.EntrustNo], event); err != nil {
+				log.Errorf("券商委托事件处理失败:%+v err:%+v", event, err)
+			}
+		}
+		events = len(orderEvents)
+	}
+	if refresh || events > 0 {
+		if err := s.refreshBroker(broker); err != nil {
+			s.markQueryFailure(ctx, broker, err)
+			return len(list), events, nil
+		}
+	}
+	if len(list) > 0 || refresh || events > 0 {
+		s.markConnected(ctx, broker)
+	}
+	return len(list), events, nil
+}
+
+// refreshBroker 查询资金、持仓,替换已连接券商的信息;申报时读取的券商信息不在原对象上修改
+func (s *BrokerService) refreshBroker(broker *model.Broker) error {
+	begin := time.Now()
+	fund, err := s.gateway.QueryFund(broker)
+	if err != nil {
+		log.Errorf("资金账号:%+v 查询资金失败:%+v", broker.FundAccount, err)
+		return err
+	}
+	positions, err := s.gateway.QueryPosition(broker)
+	if err != nil {
+		log.Errorf("资金账号:%+v 查询持仓失败:%+v", broker.FundAccount, err)
+		return err
+	}
+	brokerPosition := make([]*model.BrokerPosition, 0)
+	for _, it := range positions {
+		brokerPosition = append(brokerPosition, &model.BrokerPosition{
+			StockCode:     it.StockCode,     // 股票代码
+			StockName:     it.StockName,     // 股票名称
+			Amount:        it.Amount,        // 总数量
+			FreezeAmount:  it.FreezeAmount,  // 冻结数量
+			PositionPrice: it.PositionPrice, // 持仓价格
+			CurrentPrice:  it.CurrentPrice,  // 当前价格
+		})
+	}
+	refreshed := *broker
+	refreshed.ValMoney = fund.ValMoney
+	refreshed.Asset = fund.Asset
+	refreshed.BrokerPosition = brokerPosition
+
+	brokerMutex.Lock()
+	if s.brokerMap[broker.ID] == broker {
+		s.brokerMap[broker.ID] = &refreshed
+	}
+	brokerMutex.Unlock()
+	// 查询前已入库的委托已体现在券商资金、持仓中
+	routeMutex.Lock()
+	s.releaseHolds(broker.ID, begin)
+	routeMutex.Unlock()
+	return nil
+}
+
+// startPollers 已连接的券商启动查询协程,每个券商一个,券商断开后协程退出,重连后重新启动
+func (s *BrokerService) startPollers(ctx context.Context) {
+	brokerMutex.Lock()
+	defer brokerMutex.Unlock()
+	for id := range s.brokerMap {
+		if _, ok := s.pollers[id]; ok {
+			continue
+		}
+		wake := make(chan struct{}, 1)
+		s.pollers[id] = wake
+		go s.poll(ctx, id, wake)
+	}
+}
+
+// poll 单个券商的查询协程,按nextPollInterval调整查询间隔,申报、撤单后立即查询
+func (s *BrokerService) poll(ctx context.Context, brokerID int64, wake chan struct{}) {
+	interval := brokerPollMin
+	var refreshTime time.Time
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		refresh := false
+		select {
+		case <-timer.C:
+		case <-wake:
+			refresh = true
+			interval = brokerPollMin
+			if !timer.Stop() {
+				<-timer.C
+			}
+		}
+
+		brokerMutex.Lock()
+		broker, ok := s.brokerMap[brokerID]
+		if !ok {
+			delete(s.pollers, brokerID)
+		}
+		brokerMutex.Unlock()
+		if !ok {
+			log.Infof("券商:%d 已断开,停止查询", brokerID)
+			return
+		}
+
+		now := time.Now()
+		if now.Sub(refreshTime) >= brokerRefreshTick {
+			refresh = true
+		}
+		open, events, err := s.pollBroker(ctx, broker, refresh)
+		if err != nil {
+			log.Errorf("券商:%d 查询失败:%+v", brokerID, err)
+		} else if refresh || events > 0 {
+			refreshTime = now
+		}
+		interval = nextPollInterval(interval, open, events)
+		timer.Reset(interval)
+	}
+}
+
+// wake 立即查询券商:申报、撤单后尽快获取成交、撤单结果并刷新资金、持仓
+func (s *BrokerService) wake(brokerID int64) {
+	brokerMutex.Lock()
+	wake, ok := s.pollers[brokerID]
+	brokerMutex.Unlock()
+	if !ok {
+		return
+	}
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// nextPollInterval 查询间隔:有事件时最短,无未终态委托时空闲间隔,否则逐步翻倍到最长间隔
+func nextPollInterval(interval time.Duration, open, events int) time.Duration {
+	switch {
+	case events > 0:
+		return brokerPollMin
+	case open == 0:
+		return brokerPollIdle
+	}
+	interval *= 2
+	if interval < brokerPollMin {
+		interval = brokerPollMin
+	}
+	if interval > brokerPollMax {
+		interval = brokerPollMax
+	}
+	return interval
+}
+
+// brokerLock 券商查询锁:同一券商的委托查询与对账互斥,不同券商互不影响
+func (s *BrokerService) brokerLock(brokerID int64) *sync.Mutex {
+	brokerMutex.Lock()
+	defer brokerMutex.Unlock()
+	mu, ok := s.brokerLocks[brokerID]
+	if !ok {
+		mu = &sync.Mutex{}
+		s.brokerLocks[brokerID] = mu
+	}
+	return mu
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"stock/api-gateway/model"
+)
+
+func TestApplyOrderEvent(t *testing.T) {
+	row := &model.BrokerEntrust{EntrustAmount: 1000, Status: model.EntrustStatusTypeReported}
+	fill := func(amount int64, final bool) *model.BrokerOrderEvent {
+		return &model.BrokerOrderEvent{Type: model.BrokerOrderFill, DealAmount: amount, DealPrice: 10, Final: final}
+	}
+
+	if !applyOrderEvent(row, fill(300, false)) || row.Status != model.EntrustStatusTypePartDeal || row.DealBalance != 3000 {
+		t.Fatalf("expect part deal: %+v", row)
+	}
+	// 重复、过期的成交事件忽略
+	if applyOrderEvent(row, fill(300, false)) || applyOrderEvent(row, fill(100, false)) {
+		t.Fatalf("expect ignored: %+v", row)
+	}
+	// 撤单中部分成交仍为撤单中
+	row.Status = model.EntrustStatusTypeWithdrawing
+	if !applyOrderEvent(row, fill(500, false)) || row.Status != model.EntrustStatusTypeWithdrawing || row.DealAmount != 500 {
+		t.Fatalf("expect withdrawing: %+v", row)
+	}
+	if !applyOrderEvent(row, &model.BrokerOrderEvent{Type: model.BrokerOrderCancel, DealAmount: 500, DealPrice: 10, Final: true}) ||
+		row.Status != model.EntrustStatusTypePartDealPartWithdraw {
+		t.Fatalf("expect part withdraw: %+v", row)
+	}
+	// 终态不再变化
+	if applyOrderEvent(row, fill(1000, true)) {
+		t.Fatalf("expect final: %+v", row)
+	}
+
+	row = &model.BrokerEntrust{EntrustAmount: 1000, Status: model.EntrustStatusTypeReported}
+	if !applyOrderEvent(row, &model.BrokerOrderEvent{Type: model.BrokerOrderReject, Final: true}) || row.Status != model.EntrustStatusTypeCancel {
+		t.Fatalf("expect cancel: %+v", row)
+	}
+}
+
+func TestTdxOrderEvents(t *testing.T) {
+	broker := &model.Broker{ID: 1}
+	list := []*model.TDXTodayEntrust{
+		{EntrustNo: "1", Status: "已报"},
+		{EntrustNo: "2", Status: "部成", DealAmount: 200, DealPrice: 10},
+		{EntrustNo: "3", Status: "部成", DealAmount: 200, DealPrice: 10},
+		{EntrustNo: "4", Status: "已成", DealAmount: 500, DealPrice: 10},
+		{EntrustNo: "5", Status: "废单"},
+		{EntrustNo: "6", Status: "已撤"},
+	}
+	open := []*model.BrokerEntrust{
+		{BrokerEntrustNo: "1"},
+		{BrokerEntrustNo: "2", DealAmount: 100},
+		{BrokerEntrustNo: "3", DealAmount: 200},
+		{BrokerEntrustNo: "4"},
+		{BrokerEntrustNo: "5"},
+	}
+	events := tdxOrderEvents(broker, list, open)
+	want := []struct {
+		no    string
+		typ   int64
+		final bool
+	}{
+		{"2", model.BrokerOrderFill, false},
+		{"4", model.BrokerOrderFill, true},
+		{"5", model.BrokerOrderReject, true},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expect %d events, got %+v", len(want), events)
+	}
+	for i, it := range want {
+		if e := events[i]; e.EntrustNo != it.no || e.Type != it.typ || e.Final != it.final || e.BrokerID != 1 {
+			t.Fatalf("event %d: %+v", i, e)
+		}
+	}
+}
+
+// TestBrokerOrderPoll 增量查询:部分成交只更新券商委托,全部成交后结算委托,终态后不再查询
+func TestBrokerOrderPoll(t *testing.T) {
+	ctx := context.Background()
+	store, _, sim, broker := newSimBrokerCore(t)
+	sim.FillLot = 300
+	brokerID := broker.GetBrokers()[0].ID
+	user := store.PutUser(&model.User{Status: model.UserStatusActive})
+	contract := store.PutContract(&model.Contract{
+		UID: user.ID, InitMoney: 10000, Money: 10000, ValMoney: 20000, Lever: 1, Status: model.ContractStatusEnable,
+	})
+	if err := broker.trade.Buy(ctx, &model.EntrustPackage{
+		UID: user.ID, ContractID: contract.ID, Code: "600000", Price: 10, Amount: 1000, EntrustProp: model.EntrustPropTypeLimitPrice,
+	}); err != nil {
+		t.Fatalf("buy: %+v", err)
+	}
+	entrust := waitBrokerEntrust(t, broker.core, contract.ID)
+
+	// 1.部分成交
+	open, events, err := broker.pollBroker(ctx, broker.GetBrokers()[0], false)
+	if err != nil || open != 1 || events != 1 {
+		t.Fatalf("poll: %d %d %+v", open, events, err)
+	}
+	brokerEntrusts, _ := broker.core.BrokerEntrust.GetByEntrustID(ctx, entrust.ID)
+	if brokerEntrusts[0].Status != model.EntrustStatusTypePartDeal || brokerEntrusts[0].DealAmount != 300 {
+		t.Fatalf("expect broker part deal: %+v", brokerEntrusts[0])
+	}
+	if e, _ := broker.core.Entrust.GetEntrustByID(ctx, entrust.ID); e.Status != model.EntrustStatusTypeReported {
+		t.Fatalf("expect reported, got %d", e.Status)
+	}
+	// 成交后刷新资金
+	assertMoney(t, "broker val money", broker.GetBrokers()[0].ValMoney, 90000)
+
+	// 2.全部成交
+	sim.FillLot = 0
+	if _, events, err = broker.pollBroker(ctx, broker.GetBrokers()[0], false); err != nil || events != 1 {
+		t.Fatalf("poll: %d %+v", events, err)
+	}
+	e, _ := broker.core.Entrust.GetEntrustByID(ctx, entrust.ID)
+	if e.Status != model.EntrustStatusTypeDeal || e.DealAmount != 1000 {
+		t.Fatalf("expect deal 1000, got %d %d", e.Status, e.DealAmount)
+	}
+	position, _ := broker.core.Position.GetContractPositionByCode(ctx, contract.ID, "600000")
+	if position.Amount != 1000 {
+		t.Fatalf("expect position 1000, got %d", position.Amount)
+	}
+	// 券商成交金额及手续费从券商资金付出
+	brokerEntrusts, _ = broker.core.BrokerEntrust.GetByEntrustID(ctx, entrust.ID)
+	assertMoney(t, "broker cash", store.LedgerBalance(model.BrokerCashAccount(brokerID)), -10000-brokerEntrusts[0].Fee)
+
+	// 3.无未终态委托,不查询柜台
+	sim.SetOffline("sim001", true)
+	if open, events, err = broker.pollBroker(ctx, broker.GetBrokers()[0], false); err != nil || open != 0 || events != 0 {
+		t.Fatalf("poll: %d %d %+v", open, events, err)
+	}
+	if h := broker.Health(brokerID); h.State != model.BrokerHealthConnected {
+		t.Fatalf("expect connected: %+v", h)
+	}
+}
+
+// TestBrokerPoller 券商查询协程:申报后立即查询成交,券商断开后退出
+func TestBrokerPoller(t *testing.T) {
+	ctx := context.Background()
+	store, _, _, broker := newSimBrokerCore(t)
+	brokerID := broker.GetBrokers()[0].ID
+	broker.startPollers(ctx)
+	stop := func() {
+		brokerMutex.Lock()
+		delete(broker.brokerMap, brokerID)
+		brokerMutex.Unlock()
+		broker.wake(brokerID)
+		for i := 0; i < 100; i++ {
+			brokerMutex.Lock()
+			n := len(broker.pollers)
+			brokerMutex.Unlock()
+			if n == 0 {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatal("poller not stopped")
+	}
+	defer stop()
+
+	user := store.PutUser(&model.User{Status: model.UserStatusActive})
+	contract := store.PutContract(&model.Contract{
+		UID: user.ID, InitMoney: 10000, Money: 10000, ValMoney: 20000, Lever: 1, Status: model.ContractStatusEnable,
+	})
+	if err := broker.trade.Buy(ctx, &model.EntrustPackage{
+		UID: user.ID, ContractID: contract.ID, Code: "600000", Price: 10, Amount: 500, EntrustProp: model.EntrustPropTypeLimitPrice,
+	}); err != nil {
+		t.Fatalf("buy: %+v", err)
+	}
+	for i := 0; i < 200; i++ {
+		list, _ := broker.core.Entrust.GetTodayEntrust(ctx, contract.ID)
+		if len(list) == 1 && list[0].Status == model.EntrustStatusTypeDeal {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("entrust not dealt")
+}
+
+func TestNextPollInterval(t *testing.T) {
+	if got := nextPollInterval(brokerPollMax, 1, 1); got != brokerPollMin {
+		t.Fatalf("expect min on events, got %v", got)
+	}
+	if got := nextPollInterval(brokerPollMin, 0, 0); got != brokerPollIdle {
+		t.Fatalf("expect idle without open entrusts, got %v", got)
+	}
+	if got := nextPollInterval(brokerPollMin, 1, 0); got != 2*brokerPollMin {
+		t.Fatalf("expect backoff, got %v", got)
+	}
+	if got := nextPollInterval(brokerPollMax, 1, 0); got != brokerPollMax {
+		t.Fatalf("expect max, got %v", got)
+	}
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"stock/api-gateway/model"
+	"stock/api-gateway/util"
+	"stock/common/log"
+	"stock/common/timeconv"
+	"time"
+)
+
+const (
+	brokerCashTolerance = 1.0     // 资金差异小于1元视为一致
+	brokerCashLowLimit  = 100.0   // 资金差异小于100元为低级别,一般为分笔委托手续费
+	brokerCashHighLimit = 10000.0 // 资金差异达到1万元为高级别
+)
+
+// Reconcile 券商对账:按券商委托成交推算各券商持仓、资金,与券商查询结果核对,保存对账结果、差异及券商持仓。
+// 持仓、资金均以上一次对账的券商持仓、资金为期初,加上之后的成交推算;首次对账以券商查询结果为期初
+func (s *BrokerService) Reconcile(ctx context.Context, operator string) ([]*model.BrokerReconcile, error) {
+	list, err := s.core.BrokerAccount.GetBrokers(ctx)
+	if err != nil {
+		log.Errorf("GetBrokers err:%+v", err)
+		return nil, err
+	}
+
+	online := make(map[int64]*model.Broker)
+	for _, it := range s.GetBrokers() {
+		online[it.ID] = it
+	}
+	now := time.Now()
+	reports := make([]*model.BrokerReconcile, 0)
+	for _, broker := range list {
+		if broker.Status != model.BrokerStatusEnable {
+			continue
+		}
+		// 与该券商的委托查询互斥,避免对账期间成交入账
+		mu := s.brokerLock(broker.ID)
+		mu.Lock()
+		report, breaks, positions, err := s.reconcileBroker(ctx, broker, online[broker.ID], now)
+		mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		report.Operator = operator
+		if err := s.core.BrokerReconcile.Create(ctx, report, breaks, positions); err != nil {
+			return nil, err
+		}
+		if report.Status != model.BrokerReconcileMatch {
+			log.Errorf("券商[%d]%s 对账%s:差异%d条 %s", broker.ID, broker.BrokerName,
+				model.BrokerReconcileStatusMap[report.Status], report.BreakCount, report.Remark)
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// reconcileBroker 核对单个券商,券商未连接或查询失败记为查询失败,返回对账结果、差异及本次券商持仓
+func (s *BrokerService) reconcileBroker(ctx context.Context, broker, online *model.Broker, now time.Time) (
+	*model.BrokerReconcile, []*model.BrokerReconcileBreak, []*model.BrokerReconcilePosition, error) {
+	report := &model.BrokerReconcile{
+		BrokerID:   broker.ID,
+		BillDate:   timeconv.TimeToInt32(now),
+		Status:     model.BrokerReconcileMatch,
+		CreateTime: now,
+	}
+	if online == nil || online.ClientID == 0 {
+		report.Status = model.BrokerReconcileFail
+		report.Remark = "券商未连接"
+		return report, nil, nil, nil
+	}
+	fund, err := s.gateway.QueryFund(online)
+	if err != nil {
+		report.Status = model.BrokerReconcileFail
+		report.Remark = fmt.Sprintf("查询资金失败:%s", err.Error())
+		return report, nil, nil, nil
+	}
+	positions, err := s.gateway.QueryPosition(online)
+	if err != nil {
+		report.Status = model.BrokerReconcileFail
+		report.Remark = fmt.Sprintf("查询持仓失败:%s", err.Error())
+		return report, nil, nil, nil
+	}
+	report.BrokerCash = util.FloatRound(fund.Asset-fund.MarketValue, 2)
+
+	// 本次券商持仓,作为下次对账的期初
+	actual := make(map[string]int64)
+	names := make(map[string]string)
+	for _, it := range positions {
+		names[it.StockCode] = it.StockName
+		actual[it.StockCode] += it.Amount
+	}
+	codes := make([]string, 0, len(names))
+	for code := range names {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	snapshot := make([]*model.BrokerReconcilePosition, 0, len(codes))
+	for _, code := range codes {
+		snapshot = append(snapshot, &model.BrokerReconcilePosition{
+			BrokerID:   broker.ID,
+			BillDate:   report.BillDate,
+			StockCode:  code,
+			StockName:  names[code],
+			Amount:     actual[code],
+			CreateTime: now,
+		})
+	}
+
+	// 期初:之前最近一次收盘后的对账
+	last, err := s.core.BrokerReconcile.GetLast(ctx, broker.ID, report.BillDate)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if last == nil {
+		report.ExpectedCash = report.BrokerCash
+		report.Remark = "首次对账,以券商资金、持仓为期初"
+		return report, nil, snapshot, nil
+	}
+	opening, err := s.core.BrokerReconcile.GetPositions(ctx, last.ID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	// 上次对账日之后的成交
+	deals, err := s.core.BrokerEntrust.GetDeals(ctx, broker.ID, "", timeconv.Int32ToTime(last.BillDate).AddDate(0, 0, 1), time.Time{})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	newBreak := func(typ int64, code, name string, expected, actual float64, severity int64) *model.BrokerReconcileBreak {
+		return &model.BrokerReconcileBreak{
+			BrokerID:   broker.ID,
+			BillDate:   report.BillDate,
+			Type:       typ,
+			StockCode:  code,
+			StockName:  name,
+			Expected:   expected,
+			Actual:     actual,
+			Diff:       util.FloatRound(actual-expected, 2),
+			Severity:   severity,
+			CreateTime: now,
+		}
+	}
+	breaks := make([]*model.BrokerReconcileBreak, 0)
+
+	// 1.持仓:券商少于推算为高级别(用户持仓没有券商持仓对应),多于推算为中级别
+	expected := make(map[string]int64)
+	for _, it := range opening {
+		if _, ok := names[it.StockCode]; !ok {
+			names[it.StockCode] = it.StockName
+			codes = append(codes, it.StockCode)
+		}
+		expected[it.StockCode] += it.Amount
+	}
+	cash := last.BrokerCash
+	for _, it := range deals {
+		if _, ok := names[it.StockCode]; !ok {
+			names[it.StockCode] = it.StockName
+			codes = append(codes, it.StockCode)
+		}
+		if it.EntrustBs == model.EntrustBsTypeBuy {
+			expected[it.StockCode] += it.DealAmount
+			cash -= it.DealBalance
+		} else {
+			expected[it.StockCode] -= it.DealAmount
+			cash += it.DealBalance
+		}
+		cash -= it.Fee
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		if expected[code] == actual[code] {
+			continue
+		}
+		severity := int64(model.BrokerBreakMedium)
+		if actual[code] < expected[code] {
+			severity = model.BrokerBreakHigh
+		}
+		breaks = append(breaks, newBreak(model.BrokerBreakPosition, code, names[code], float64(expected[code]), float64(actual[code]), severity))
+	}
+
+	// 2.资金:资金余额=总资产-市值,含委托冻结资金
+	report.ExpectedCash = util.FloatRound(cash, 2)
+	if diff := math.Abs(report.BrokerCash - report.ExpectedCash); diff >= brokerCashTolerance {
+		severity := int64(model.BrokerBreakMedium)
+		if diff < brokerCashLowLimit {
+			severity = model.BrokerBreakLow
+		} else if diff >= brokerCashHighLimit {
+			severity = model.BrokerBreakHigh
+		}
+		breaks = append(breaks, newBreak(model.BrokerBreakCash, "", "", report.ExpectedCash, report.BrokerCash, severity))
+	}
+
+	report.BreakCount = int64(len(breaks))
+	if len(breaks) > 0 {
+		report.Status = model.BrokerReconcileDiff
+	}
+	return report, breaks, snapshot, nil
+}
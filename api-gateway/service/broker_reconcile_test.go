@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"stock/api-gateway/model"
+	"stock/common/timeconv"
+)
+
+// TestBrokerReconcile 券商对账:一致->持仓、资金差异->查询失败
+func TestBrokerReconcile(t *testing.T) {
+	ctx := context.Background()
+	store, _, sim, broker := newSimBrokerCore(t)
+	brokerID := broker.GetBrokers()[0].ID
+
+	// 1.首次对账:以券商资金、持仓为期初
+	reports, err := broker.Reconcile(ctx, "admin")
+	if err != nil || len(reports) != 1 {
+		t.Fatalf("reconcile: %d %+v", len(reports), err)
+	}
+	if reports[0].Status != model.BrokerReconcileMatch || reports[0].BrokerCash != 100000 {
+		t.Fatalf("expect match: %+v", reports[0])
+	}
+
+	// 2.买入1000股全部成交,持仓、资金按前一日对账推算,前一日之前的成交已在期初中
+	user := store.PutUser(&model.User{Status: model.UserStatusActive})
+	contract := store.PutContract(&model.Contract{
+		UID: user.ID, InitMoney: 10000, Money: 10000, ValMoney: 20000, Lever: 1, Status: model.ContractStatusEnable,
+	})
+	if err := broker.trade.Buy(ctx, &model.EntrustPackage{
+		UID: user.ID, ContractID: contract.ID, Code: "600000", Price: 10, Amount: 1000, EntrustProp: model.EntrustPropTypeLimitPrice,
+	}); err != nil {
+		t.Fatalf("buy: %+v", err)
+	}
+	entrust := waitBrokerEntrust(t, broker.core, contract.ID)
+	if err := broker.query(ctx); err != nil {
+		t.Fatalf("query: %+v", err)
+	}
+	if e, _ := broker.core.Entrust.GetEntrustByID(ctx, entrust.ID); e.Status != model.EntrustStatusTypeDeal {
+		t.Fatalf("expect deal, got %d", e.Status)
+	}
+	if err := broker.core.BrokerEntrust.MCreate(ctx, []*model.BrokerEntrust{{
+		BrokerID: brokerID, OrderTime: time.Now().AddDate(0, 0, -2), StockCode: "600000", StockName: "浦发银行",
+		EntrustAmount: 500, DealAmount: 500, DealBalance: 5000, Status: model.EntrustStatusTypeDeal, EntrustBs: model.EntrustBsTypeBuy,
+	}}); err != nil {
+		t.Fatalf("MCreate: %+v", err)
+	}
+	yesterday := timeconv.TimeToInt32(time.Now().AddDate(0, 0, -1))
+	closed := timeconv.Int32ToTime(yesterday).Add(15*time.Hour + 30*time.Minute)
+	if err := broker.core.BrokerReconcile.Create(ctx, &model.BrokerReconcile{
+		BrokerID: brokerID, BillDate: yesterday, BrokerCash: 100000, Status: model.BrokerReconcileMatch, CreateTime: closed,
+	}, nil, nil); err != nil {
+		t.Fatalf("create: %+v", err)
+	}
+	reports, err = broker.Reconcile(ctx, "")
+	if err != nil {
+		t.Fatalf("reconcile: %+v", err)
+	}
+	// 模拟柜台不收手续费,券商委托记录的手续费为低级别资金差异
+	breaks := store.BrokerBreaks(reports[0].ID)
+	if reports[0].Status != model.BrokerReconcileDiff || len(breaks) != 1 {
+		t.Fatalf("expect fee break: %+v %+v", reports[0], breaks)
+	}
+	if breaks[0].Type != model.BrokerBreakCash || breaks[0].Severity != model.BrokerBreakLow {
+		t.Fatalf("expect low cash break: %+v", breaks[0])
+	}
+	assertMoney(t, "broker cash", reports[0].BrokerCash, 90000)
+	// 保存券商持仓作为下次对账期初
+	if positions, _ := broker.core.BrokerReconcile.GetPositions(ctx, reports[0].ID); len(positions) != 1 || positions[0].Amount != 1000 {
+		t.Fatalf("expect position snapshot: %+v", positions)
+	}
+
+	// 3.券商委托有成交而券商无持仓:持仓高级别差异,资金中级别差异
+	if err := broker.core.BrokerEntrust.MCreate(ctx, []*model.BrokerEntrust{{
+		BrokerID: brokerID, OrderTime: time.Now(), StockCode: "600000", StockName: "浦发银行",
+		EntrustAmount: 200, DealAmount: 200, DealBalance: 2000, Status: model.EntrustStatusTypeDeal, EntrustBs: model.EntrustBsTypeBuy,
+	}}); err != nil {
+		t.Fatalf("MCreate: %+v", err)
+	}
+	reports, err = broker.Reconcile(ctx, "")
+	if err != nil {
+		t.Fatalf("reconcile: %+v", err)
+	}
+	breaks = store.BrokerBreaks(reports[0].ID)
+	if len(breaks) != 2 {
+		t.Fatalf("expect 2 breaks: %+v", breaks)
+	}
+	if b := breaks[0]; b.Type != model.BrokerBreakPosition || b.Severity != model.BrokerBreakHigh || b.Diff != -200 {
+		t.Fatalf("position break: %+v", b)
+	}
+	if b := breaks[1]; b.Type != model.BrokerBreakCash || b.Severity != model.BrokerBreakMedium {
+		t.Fatalf("cash break: %+v", b)
+	}
+
+	// 4.期初持仓:上次对账券商持有、本次没有且无卖出成交,高级别差异;盘中对账不作为期初
+	if err := broker.core.BrokerReconcile.Create(ctx, &model.BrokerReconcile{
+		BrokerID: brokerID, BillDate: yesterday, BrokerCash: 100000, Status: model.BrokerReconcileMatch, CreateTime: closed,
+	}, nil, []*model.BrokerReconcilePosition{{BrokerID: brokerID, BillDate: yesterday, StockCode: "600036", StockName: "招商银行", Amount: 300}}); err != nil {
+		t.Fatalf("create: %+v", err)
+	}
+	if err := broker.core.BrokerReconcile.Create(ctx, &model.BrokerReconcile{
+		BrokerID: brokerID, BillDate: yesterday, BrokerCash: 100000, Status: model.BrokerReconcileMatch, CreateTime: closed.Add(-5 * time.Hour),
+	}, nil, []*model.BrokerReconcilePosition{{BrokerID: brokerID, BillDate: yesterday, StockCode: "600036", StockName: "招商银行", Amount: 999}}); err != nil {
+		t.Fatalf("create: %+v", err)
+	}
+	reports, err = broker.Reconcile(ctx, "")
+	if err != nil {
+		t.Fatalf("reconcile: %+v", err)
+	}
+	breaks = store.BrokerBreaks(reports[0].ID)
+	if len(breaks) != 3 {
+		t.Fatalf("expect 3 breaks: %+v", breaks)
+	}
+	if b := breaks[1]; b.StockCode != "600036" || b.Severity != model.BrokerBreakHigh || b.Expected != 300 || b.Actual != 0 {
+		t.Fatalf("opening position break: %+v", b)
+	}
+
+	// 5.券商查询失败
+	sim.SetOffline("sim001", true)
+	reports, err = broker.Reconcile(ctx, "")
+	if err != nil {
+		t.Fatalf("reconcile: %+v", err)
+	}
+	if reports[0].Status != model.BrokerReconcileFail {
+		t.Fatalf("expect fail: %+v", reports[0])
+	}
+}
@@ -0,0 +1,459 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"stock/api-gateway/model"
+	"stock/api-gateway/serr"
+	"stock/common/log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	routeReasonMaxLen = 255                 // broker_entrust.route_reason字段长度
+	brokerStickyTTL   = 30 * 24 * time.Hour // 用户固定券商有效期
+)
+
+// routeCandidate 候选券商
+type routeCandidate struct {
+	broker   *model.Broker
+	load     float64           // 当日委托金额,含申报中的委托
+	capacity int64             // 可申报股数
+	reserved *routeReservation // 申报中、券商资金持仓未刷新的委托占用
+}
+
+// routeReservation 已分配、未入库的券商委托占用:这些委托不在当日委托中,券商资金、持仓也尚未刷新,
+// 分配时扣除,避免并发申报重复使用同一笔资金、持仓
+type routeReservation struct {
+	money   float64          // 买入占用资金,含手续费
+	load    float64          // 委托金额
+	amounts map[string]int64 // map[股票代码]卖出占用股数
+}
+
+// routeHold 已入库的券商委托对资金、持仓的占用:当日委托已计入,但券商资金、持仓要等入库后的首次刷新才扣除
+type routeHold struct {
+	brokerEntrust *model.BrokerEntrust
+	storedAt      time.Time // 入库时间
+}
+
+// valMoney 扣除申报中买入占用后的可用资金
+func (c *routeCandidate) valMoney() float64 {
+	if c.reserved == nil {
+		return c.broker.ValMoney
+	}
+	return c.broker.ValMoney - c.reserved.money
+}
+
+// reservedAmount 申报中卖出占用的股数
+func (c *routeCandidate) reservedAmount(code string) int64 {
+	if c.reserved == nil {
+		return 0
+	}
+	return c.reserved.amounts[code]
+}
+
+// routeStrategy 分配策略:券商单独设置了策略的(按优先级取第一个)优先,其次系统参数,默认按优先级
+func routeStrategy(sys *model.SysParam, brokers []*model.Broker) string {
+	for _, broker := range brokers {
+		if broker.RouteStrategy != "" {
+			return broker.RouteStrategy
+		}
+	}
+	if sys != nil && sys.BrokerRouteStrategy != "" {
+		return sys.BrokerRouteStrategy
+	}
+	return model.BrokerRoutePriority
+}
+
+// route 按分配策略选择券商并拆分委托数量,分配依据记录到券商委托表
+func (s *BrokerService) route(ctx context.Context, e *model.Entrust) ([]*model.BrokerEntrust, error) {
+	if e.EntrustBS != model.EntrustBsTypeBuy && e.EntrustBS != model.EntrustBsTypeSell {
+		return nil, serr.ErrBusiness("无效券商通道")
+	}
+	brokers := s.GetBrokers()
+	sys, err := s.core.Sys.GetSysParam(ctx)
+	if err != nil {
+		log.Errorf("GetSysParam err:%+v", err)
+		return nil, err
+	}
+	strategy := routeStrategy(sys, brokers)
+	loads, err := s.brokerLoads(ctx)
+	if err != nil {
+		log.Errorf("brokerLoads err:%+v", err)
+		return nil, err
+	}
+
+	// 过滤资金、持仓、限额不满足的券商
+	candidates := make([]*routeCandidate, 0, len(brokers))
+	skipped := make([]string, 0)
+	for _, broker := range brokers {
+		// 熔断:通道异常的券商不分配新委托
+		if !s.healthy(broker.ID) {
+			skipped = append(skipped, fmt.Sprintf("%d跳过:通道异常", broker.ID))
+			continue
+		}
+		c := &routeCandidate{broker: broker, load: loads[broker.ID], reserved: s.reservation(broker.ID)}
+		c.load += c.reserved.load
+		capacity, reason := routeCapacity(e, c)
+		if capacity <= 0 {
+			skipped = append(skipped, fmt.Sprintf("%d跳过:%s", broker.ID, reason))
+			continue
+		}
+		c.capacity = capacity
+		candidates = append(candidates, c)
+	}
+	candidates = s.sortCandidates(ctx, strategy, e, candidates)
+
+	var amounts []int64
+	if strategy == model.BrokerRouteProportional {
+		amounts = allocateProportional(e, candidates)
+	} else {
+		amounts = allocateOrdered(e, candidates)
+	}
+
+	var entrustedAmount int64
+	for _, amount := range amounts {
+		entrustedAmount += amount
+	}
+	if entrustedAmount < e.Amount {
+		log.Infof("股票代码:%+v 策略:%+v 母账户可委托数量:%+v 跳过:%+v", e.StockCode, strategy, entrustedAmount, skipped)
+		if e.EntrustBS == model.EntrustBsTypeBuy {
+			return nil, errors.New("无效券商通道或母账户资金不足")
+		}
+		return nil, errors.New("母账户持有该股票可卖股份不足")
+	}
+
+	reasons := make([]string, 0, len(candidates)+len(skipped))
+	brokerEntrusts := make([]*model.BrokerEntrust, 0)
+	for i, c := range candidates {
+		reasons = append(reasons, fmt.Sprintf("%d:当日委托%.2f可申报%d股分配%d股", c.broker.ID, c.load, c.capacity, amounts[i]))
+		if amounts[i] == 0 {
+			continue
+		}
+		brokerEntrusts = append(brokerEntrusts, &model.BrokerEntrust{
+			UID:             e.UID,                           // 用户ID
+			ContractID:      e.ContractID,                    // 合约编号
+			BrokerID:        c.broker.ID,                     // 券商ID
+			EntrustID:       e.ID,                            // 委托表ID
+			OrderTime:       e.OrderTime,                     // 订单时间
+			StockCode:       e.StockCode,                     // 股票代码
+			StockName:       e.StockName,                     // 股票名称
+			EntrustAmount:   amounts[i],                      // 委托总股数
+			EntrustPrice:    e.Price,                         // 委托价格
+			EntrustBalance:  e.Price * float64(amounts[i]),   // 委托总金额
+			Status:          model.EntrustStatusTypeReported, // 订单状态:已申报
+			EntrustBs:       e.EntrustBS,                     // 交易类型:1买入 2卖出
+			EntrustProp:     e.EntrustProp,                   // 委托类型:1限价 2市价
+			BrokerEntrustNo: "",                              // 券商委托编号
+			RouteStrategy:   strategy,                        // 分配策略
+			Broker:          c.broker,                        // 券商
+		})
+	}
+	reason := routeReason(append(reasons, skipped...))
+	for _, it := range brokerEntrusts {
+		it.RouteReason = reason
+	}
+	// 整笔申报时券商手续费即委托手续费,分笔申报券商手续费不准,填写0
+	if len(brokerEntrusts) == 1 {
+		brokerEntrusts[0].EntrustBalance = e.Balance
+		brokerEntrusts[0].Fee = e.Fee
+	}
+
+	if strategy == model.BrokerRouteSticky {
+		key := s.stickyCacheKey(e.UID)
+		if err := s.core.Cache.Set(ctx, key, brokerEntrusts[0].BrokerID, brokerStickyTTL).Err(); err != nil {
+			log.Errorf("Set %s err:%+v", key, err)
+		}
+	}
+	return brokerEntrusts, nil
+}
+
+// reserve 占用分配给各券商的资金、持仓及当日委托金额,调用方持有routeMutex
+func (s *BrokerService) reserve(brokerEntrusts []*model.BrokerEntrust) {
+	for _, it := range brokerEntrusts {
+		r, ok := s.reserved[it.BrokerID]
+		if !ok {
+			r = &routeReservation{amounts: make(map[string]int64)}
+			s.reserved[it.BrokerID] = r
+		}
+		r.load += it.EntrustBalance
+		if it.EntrustBs == model.EntrustBsTypeBuy {
+			r.money += it.EntrustBalance + it.Fee
+		} else {
+			r.amounts[it.StockCode] += it.EntrustAmount
+		}
+	}
+}
+
+// release 释放reserve的占用,调用方持有routeMutex
+func (s *BrokerService) release(brokerEntrusts []*model.BrokerEntrust) {
+	for _, it := range brokerEntrusts {
+		r, ok := s.reserved[it.BrokerID]
+		if !ok {
+			continue
+		}
+		r.load -= it.EntrustBalance
+		if it.EntrustBs == model.EntrustBsTypeBuy {
+			r.money -= it.EntrustBalance + it.Fee
+		} else {
+			r.amounts[it.StockCode] -= it.EntrustAmount
+			if r.amounts[it.StockCode] <= 0 {
+				delete(r.amounts, it.StockCode)
+			}
+		}
+		if len(r.amounts) == 0 && r.load < 0.01 {
+			delete(s.reserved, it.BrokerID)
+		}
+	}
+}
+
+// hold 券商委托入库后继续占用资金、持仓,直到入库后开始的刷新完成,调用方持有routeMutex
+func (s *BrokerService) hold(brokerEntrusts []*model.BrokerEntrust) {
+	now := time.Now()
+	for _, it := range brokerEntrusts {
+		s.holds[it.BrokerID] = append(s.holds[it.BrokerID], &routeHold{brokerEntrust: it, storedAt: now})
+	}
+}
+
+// releaseHolds 释放begin之前入库的委托占用:begin之后查询的券商资金、持仓已包含这些委托,调用方持有routeMutex
+func (s *BrokerService) releaseHolds(brokerID int64, begin time.Time) {
+	holds := make([]*routeHold, 0, len(s.holds[brokerID]))
+	for _, h := range s.holds[brokerID] {
+		if !h.storedAt.Before(begin) {
+			holds = append(holds, h)
+		}
+	}
+	if len(holds) == 0 {
+		delete(s.holds, brokerID)
+		return
+	}
+	s.holds[brokerID] = holds
+}
+
+// reservation 券商的占用:申报中的委托占用资金、持仓及当日委托金额,已入库未刷新的委托只占用资金、持仓,
+// 调用方持有routeMutex
+func (s *BrokerService) reservation(brokerID int64) *routeReservation {
+	r := &routeReservation{amounts: make(map[string]int64)}
+	if it, ok := s.reserved[brokerID]; ok {
+		r.money, r.load = it.money, it.load
+		for code, amount := range it.amounts {
+			r.amounts[code] = amount
+		}
+	}
+	for _, h := range s.holds[brokerID] {
+		it := h.brokerEntrust
+		if it.EntrustBs == model.EntrustBsTypeBuy {
+			r.money += it.EntrustBalance + it.Fee
+		} else {
+			r.amounts[it.StockCode] += it.EntrustAmount
+		}
+	}
+	return r
+}
+
+// brokerLoads 各券商当日委托金额:终态按成交金额,未成交按委托金额,废单不计
+func (s *BrokerService) brokerLoads(ctx context.Context) (map[int64]float64, error) {
+	list, err := s.core.BrokerEntrust.GetTodayEntrusts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	loads := make(map[int64]float64)
+	for _, it := range list {
+		switch {
+		case it.Status == model.EntrustStatusTypeCancel:
+		case it.IsFinallyState():
+			loads[it.BrokerID] += it.DealBalance
+		default:
+			loads[it.BrokerID] += it.EntrustBalance
+		}
+	}
+	return loads, nil
+}
+
+// routeCapacity 券商可申报股数,为0时返回跳过原因
+func routeCapacity(e *model.Entrust, c *routeCandidate) (int64, string) {
+	if e.Price <= 0 {
+		return 0, "委托价格无效"
+	}
+	broker := c.broker
+	var amount int64
+	switch e.EntrustBS {
+	case model.EntrustBsTypeBuy:
+		// 扣除手续费后的可用资金
+		amount = lotAmount(int64((c.valMoney() - e.Fee) / e.Price))
+		if amount <= 0 {
+			return 0, "可用资金不足"
+		}
+		if broker.StockCapRatio > 0 {
+			var held float64
+			for _, it := range broker.BrokerPosition {
+				if it.StockCode == e.StockCode {
+					held += float64(it.Amount) * it.CurrentPrice
+				}
+			}
+			limit := lotAmount(int64((broker.StockCapRatio*broker.Asset - held) / e.Price))
+			if limit <= 0 {
+				return 0, "超出单股集中度上限"
+			}
+			if limit < amount {
+				amount = limit
+			}
+		}
+	case model.EntrustBsTypeSell:
+		oddAmount := e.Amount % 100 // 零股
+		reserved := c.reservedAmount(e.StockCode)
+		for _, it := range broker.BrokerPosition {
+			if it.StockCode != e.StockCode {
+				continue
+			}
+			valAmount := it.Amount - it.FreezeAmount
+			// 扣除申报中卖出占用的股数
+			if reserved > 0 && valAmount > 0 {
+				used := reserved
+				if used > valAmount {
+					used = valAmount
+				}
+				valAmount -= used
+				reserved -= used
+			}
+			if valAmount <= 0 {
+				continue
+			}
+			// 零股须一次性卖出,只有零股数一致的券商可以申报
+			amount += lotAmount(valAmount)
+			if oddAmount > 0 && valAmount%100 == oddAmount {
+				amount += oddAmount
+			}
+		}
+		if amount <= 0 {
+			return 0, "无可卖持仓"
+		}
+	}
+
+	if broker.DayTurnoverCap > 0 {
+		limit := lotAmount(int64((broker.DayTurnoverCap - c.load) / e.Price))
+		if limit <= 0 {
+			return 0, "超出当日委托金额上限"
+		}
+		if limit < amount {
+			amount = limit
+		}
+	}
+	return amount, ""
+}
+
+// sortCandidates 按策略排列候选券商,靠前的优先分配
+func (s *BrokerService) sortCandidates(ctx context.Context, strategy string, e *model.Entrust, candidates []*routeCandidate) []*routeCandidate {
+	if len(candidates) <= 1 {
+		return candidates
+	}
+	switch strategy {
+	case model.BrokerRouteLeastLoaded:
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].load < candidates[j].load
+		})
+	case model.BrokerRouteRoundRobin:
+		// 申报时持有routeMutex,无需原子操作
+		start := int(s.routeSeq % uint64(len(candidates)))
+		s.routeSeq++
+		rotated := append([]*routeCandidate{}, candidates[start:]...)
+		candidates = append(rotated, candidates[:start]...)
+	case model.BrokerRouteSticky:
+		brokerID, _ := strconv.ParseInt(s.core.Cache.Get(ctx, s.stickyCacheKey(e.UID)).Val(), 10, 64)
+		for i, c := range candidates {
+			if c.broker.ID == brokerID {
+				sorted := append([]*routeCandidate{c}, candidates[:i]...)
+				candidates = append(sorted, candidates[i+1:]...)
+				break
+			}
+		}
+	}
+	return candidates
+}
+
+// allocateOrdered 依次分配:有券商可整笔申报则不拆单,否则按顺序拆单
+func allocateOrdered(e *model.Entrust, candidates []*routeCandidate) []int64 {
+	amounts := make([]int64, len(candidates))
+	for i, c := range candidates {
+		if c.capacity >= e.Amount {
+			amounts[i] = e.Amount
+			return amounts
+		}
+	}
+	fillAmounts(candidates, amounts, e.Amount)
+	return amounts
+}
+
+// allocateProportional 按比例拆单:买入按可用资金,卖出按可卖股数,整手分配后剩余部分按顺序补足
+func allocateProportional(e *model.Entrust, candidates []*routeCandidate) []int64 {
+	amounts := make([]int64, len(candidates))
+	weights := make([]float64, len(candidates))
+	var total float64
+	for i, c := range candidates {
+		weights[i] = float64(c.capacity)
+		if e.EntrustBS == model.EntrustBsTypeBuy {
+			weights[i] = c.valMoney()
+		}
+		total += weights[i]
+	}
+	if total <= 0 {
+		return amounts
+	}
+	remain := e.Amount
+	for i, c := range candidates {
+		amount := lotAmount(int64(float64(e.Amount) * weights[i] / total))
+		if amount > c.capacity {
+			amount = lotAmount(c.capacity)
+		}
+		amounts[i] = amount
+		remain -= amount
+	}
+	fillAmounts(candidates, amounts, remain)
+	return amounts
+}
+
+// fillAmounts 按顺序用剩余可申报股数补足remain
+func fillAmounts(candidates []*routeCandidate, amounts []int64, remain int64) {
+	for i, c := range candidates {
+		if remain <= 0 {
+			return
+		}
+		left := c.capacity - amounts[i]
+		amount := left
+		if amount > remain {
+			amount = remain
+		}
+		// 零股只能由零股数一致的券商申报
+		if amount%100 != left%100 {
+			amount = lotAmount(amount)
+		}
+		amounts[i] += amount
+		remain -= amount
+	}
+}
+
+// lotAmount 向下取整手
+func lotAmount(amount int64) int64 {
+	if amount <= 0 {
+		return 0
+	}
+	return amount / 100 * 100
+}
+
+// routeReason 分配依据,截断到字段长度
+func routeReason(reasons []string) string {
+	reason := []rune(strings.Join(reasons, ";"))
+	if len(reason) > routeReasonMaxLen {
+		reason = reason[:routeReasonMaxLen]
+	}
+	return string(reason)
+}
+
+// stickyCacheKey 用户固定券商缓存
+func (s *BrokerService) stickyCacheKey(uid int64) string {
+	return fmt.Sprintf("broker_sticky_uid_%d", uid)
+}
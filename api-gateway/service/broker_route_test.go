@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"stock/api-gateway/model"
+)
+
+// newRouteService 两个母账户:券商1优先级高、可用5万,券商2可用10万
+func newRouteService(strategy string) (*BrokerService, map[int64]*model.Broker) {
+	store, _, svc := newFakeServices()
+	store.SetSysParam(&model.SysParam{BrokerRouteStrategy: strategy})
+	brokers := map[int64]*model.Broker{
+		1: {ID: 1, Priority: 2, ValMoney: 50000, Asset: 100000},
+		2: {ID: 2, Priority: 1, ValMoney: 100000, Asset: 100000},
+	}
+	s := newBrokerService(svc, nil)
+	s.brokerMap = brokers
+	return s, brokers
+}
+
+func routeBuy(uid, amount int64) *model.Entrust {
+	return &model.Entrust{
+		UID: uid, StockCode: "600000", Price: 10, Amount: amount, Balance: 10 * float64(amount), Fee: 5,
+		EntrustBS: model.EntrustBsTypeBuy, OrderTime: time.Now(),
+	}
+}
+
+// assertRoute 断言各券商分配股数
+func assertRoute(t *testing.T, s *BrokerService, e *model.Entrust, want map[int64]int64) []*model.BrokerEntrust {
+	t.Helper()
+	list, err := s.route(context.Background(), e)
+	if err != nil {
+		t.Fatalf("route: %+v", err)
+	}
+	got := make(map[int64]int64)
+	for _, it := range list {
+		got[it.BrokerID] += it.EntrustAmount
+		if it.RouteStrategy == "" || it.RouteReason == "" {
+			t.Fatalf("route decision not recorded: %+v", it)
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expect %v, got %v", want, got)
+	}
+	for id, amount := range want {
+		if got[id] != amount {
+			t.Fatalf("expect %v, got %v", want, got)
+		}
+	}
+	return list
+}
+
+// TestBrokerRouteStrategy 各分配策略
+func TestBrokerRouteStrategy(t *testing.T) {
+	ctx := context.Background()
+
+	// 优先级:资金足够整笔申报,不足时由资金足够的券商整笔申报,都不足则拆单
+	s, brokers := newRouteService("")
+	list := assertRoute(t, s, routeBuy(1, 1000), map[int64]int64{1: 1000})
+	if list[0].Fee != 5 || list[0].RouteStrategy != model.BrokerRoutePriority {
+		t.Fatalf("single entrust: %+v", list[0])
+	}
+	assertRoute(t, s, routeBuy(1, 8000), map[int64]int64{2: 8000})
+	list = assertRoute(t, s, routeBuy(1, 12000), map[int64]int64{1: 4900, 2: 7100})
+	if list[0].Fee != 0 {
+		t.Fatalf("split entrust fee: %+v", list[0])
+	}
+	if _, err := s.route(ctx, routeBuy(1, 20000)); err == nil {
+		t.Fatal("expect not enough money")
+	}
+
+	// 券商单独设置的策略优先于系统参数
+	brokers[2].RouteStrategy = model.BrokerRouteProportional
+	assertRoute(t, s, routeBuy(1, 3000), map[int64]int64{1: 1000, 2: 2000})
+
+	// 最少委托:券商1当日已委托2万
+	s, _ = newRouteService(model.BrokerRouteLeastLoaded)
+	if err := s.core.BrokerEntrust.MCreate(ctx, []*model.BrokerEntrust{
+		{ID: 1, BrokerID: 1, OrderTime: time.Now(), EntrustBalance: 20000, Status: model.EntrustStatusTypeReported},
+		{ID: 2, BrokerID: 2, OrderTime: time.Now(), EntrustBalance: 90000, Status: model.EntrustStatusTypeCancel},
+	}); err != nil {
+		t.Fatalf("MCreate: %+v", err)
+	}
+	assertRoute(t, s, routeBuy(1, 1000), map[int64]int64{2: 1000})
+
+	// 轮询
+	s, _ = newRouteService(model.BrokerRouteRoundRobin)
+	assertRoute(t, s, routeBuy(1, 1000), map[int64]int64{1: 1000})
+	assertRoute(t, s, routeBuy(1, 1000), map[int64]int64{2: 1000})
+	assertRoute(t, s, routeBuy(1, 1000), map[int64]int64{1: 1000})
+
+	// 用户固定券商:首次因资金不足落到券商2,之后保持在券商2
+	s, brokers = newRouteService(model.BrokerRouteSticky)
+	brokers[1].ValMoney = 1000
+	assertRoute(t, s, routeBuy(1, 1000), map[int64]int64{2: 1000})
+	brokers[1].ValMoney = 50000
+	assertRoute(t, s, routeBuy(1, 1000), map[int64]int64{2: 1000})
+	assertRoute(t, s, routeBuy(2, 1000), map[int64]int64{1: 1000})
+}
+
+// TestBrokerRouteCap 当日委托金额上限、单股集中度上限、零股卖出
+func TestBrokerRouteCap(t *testing.T) {
+	ctx := context.Background()
+	s, brokers := newRouteService(model.BrokerRoutePriority)
+
+	// 券商1当日委托上限5000元,只能申报500股
+	brokers[1].DayTurnoverCap = 5000
+	assertRoute(t, s, routeBuy(1, 1000), map[int64]int64{2: 1000})
+	assertRoute(t, s, routeBuy(1, 10000), map[int64]int64{1: 500, 2: 9500})
+
+	// 券商2已持有5万市值,单股上限60%,只能再买1000股
+	brokers[1].DayTurnoverCap = 0
+	brokers[1].ValMoney = 0
+	brokers[2].StockCapRatio = 0.6
+	brokers[2].BrokerPosition = []*model.BrokerPosition{{StockCode: "600000", Amount: 5000, CurrentPrice: 10}}
+	assertRoute(t, s, routeBuy(1, 1000), map[int64]int64{2: 1000})
+	if _, err := s.route(ctx, routeBuy(1, 1100)); err == nil {
+		t.Fatal("expect concentration cap")
+	}
+
+	// 卖出按持仓:零股只能由零股数一致的券商申报
+	brokers[1].BrokerPosition = []*model.BrokerPosition{{StockCode: "600000", Amount: 300}}
+	brokers[2].BrokerPosition = []*model.BrokerPosition{{StockCode: "600000", Amount: 1050, FreezeAmount: 100}}
+	sell := &model.Entrust{UID: 1, StockCode: "600000", Price: 10, Amount: 1150, EntrustBS: model.EntrustBsTypeSell, OrderTime: time.Now()}
+	assertRoute(t, s, sell, map[int64]int64{1: 300, 2: 850})
+	sell.Amount = 1300
+	if _, err := s.route(ctx, sell); err == nil {
+		t.Fatal("expect not enough position")
+	}
+}
+
+// blockGateway 券商1申报阻塞,模拟柜台响应慢
+type blockGateway struct {
+	BrokerGateway
+	entered chan struct{}
+	done    chan struct{}
+}
+
+func (g *blockGateway) Entrust(entrust *model.BrokerEntrust) error {
+	if entrust.BrokerID == 1 {
+		g.entered <- struct{}{}
+		<-g.done
+	}
+	return nil
+}
+
+// TestBrokerEntrustReserve 申报不持有分配锁:券商1申报阻塞时其他委托仍可分配,且不重复占用券商1的资金
+func TestBrokerEntrustReserve(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newRouteService(model.BrokerRoutePriority)
+	gateway := &blockGateway{entered: make(chan struct{}), done: make(chan struct{})}
+	s.gateway = gateway
+
+	first := make(chan func())
+	go func() {
+		_, release, err := s.entrust(ctx, routeBuy(1, 4000))
+		if err != nil {
+			t.Errorf("entrust: %+v", err)
+		}
+		first <- release
+	}()
+	<-gateway.entered
+
+	// 券商1剩余可用资金不足4000股,分配给券商2
+	list, release, err := s.entrust(ctx, routeBuy(2, 4000))
+	if err != nil || len(list) != 1 || list[0].BrokerID != 2 {
+		t.Fatalf("expect broker 2: %+v %+v", list, err)
+	}
+	release()
+	close(gateway.done)
+	(<-first)()
+
+	routeMutex.Lock()
+	n := len(s.reserved)
+	routeMutex.Unlock()
+	if n != 0 {
+		t.Fatalf("expect released, got %d", n)
+	}
+	// 释放后按券商资金重新分配
+	assertRoute(t, s, routeBuy(1, 4000), map[int64]int64{1: 4000})
+}
+
+// fundGateway 申报成功并扣除柜台可用资金,查询资金返回柜台当前可用资金
+type fundGateway struct {
+	BrokerGateway
+	valMoney map[int64]float64
+}
+
+func (g *fundGateway) Entrust(entrust *model.BrokerEntrust) error {
+	g.valMoney[entrust.BrokerID] -= entrust.EntrustBalance + entrust.Fee
+	return nil
+}
+
+func (g *fundGateway) QueryFund(broker *model.Broker) (*model.TDXBrokerFund, error) {
+	return &model.TDXBrokerFund{ValMoney: g.valMoney[broker.ID], Asset: broker.Asset}, nil
+}
+
+func (g *fundGateway) QueryPosition(broker *model.Broker) ([]*model.TDXPosition, error) {
+	return nil, nil
+}
+
+// TestBrokerEntrustHold 券商委托入库后、券商资金刷新前,已申报的资金仍被占用;刷新后按柜台资金分配,不重复扣除
+func TestBrokerEntrustHold(t *testing.T) {
+	s, brokers := newRouteService(model.BrokerRoutePriority)
+	s.gateway = &fundGateway{valMoney: map[int64]float64{1: 50000, 2: 100000}}
+
+	e := routeBuy(1, 4000)
+	e.IsBrokerEntrust = true
+	if err := s.Entrust(e); err != nil || e.Status != model.EntrustStatusTypeReported {
+		t.Fatalf("entrust: %d %+v", e.Status, err)
+	}
+	// 券商1资金未刷新,剩余可用不足4000股,分配给券商2
+	assertRoute(t, s, routeBuy(2, 4000), map[int64]int64{2: 4000})
+
+	if err := s.refreshBroker(brokers[1]); err != nil {
+		t.Fatalf("refresh: %+v", err)
+	}
+	routeMutex.Lock()
+	n := len(s.holds)
+	routeMutex.Unlock()
+	if n != 0 {
+		t.Fatalf("expect holds released, got %d", n)
+	}
+	// 刷新后券商1可用9995元
+	assertRoute(t, s, routeBuy(3, 900), map[int64]int64{1: 900})
+}
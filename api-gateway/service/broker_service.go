@@ -6,7 +6,6 @@ import (
 	"sort"
 	"stock/api-gateway/dao"
 	"stock/api-gateway/model"
-	"stock/api-gateway/serr"
 	"stock/common/log"
 	"sync"
 	"time"
@@ -14,53 +13,70 @@ import (
 
 // BrokerService 券商服务
 type BrokerService struct {
-	brokerMap map[int64]*model.Broker
+	core        *Core
+	trade       *TradeService
+	contract    *ContractService
+	ledger      *LedgerService
+	gateway     BrokerGateway
+	brokerMap   map[int64]*model.Broker
+	routeSeq    uint64                        // 轮询分配序号
+	health      map[int64]*model.BrokerHealth // 券商通道健康状态
+	pollers     map[int64]chan struct{}       // 券商查询协程,用于申报、撤单后立即查询
+	brokerLocks map[int64]*sync.Mutex         // 券商查询锁
+	reserved    map[int64]*routeReservation   // 申报中的券商委托占用,routeMutex保护
+	holds       map[int64][]*routeHold        // 已入库、券商资金持仓未刷新的券商委托占用,routeMutex保护
 }
 
 var (
 	brokerService *BrokerService
 	brokerOnce    sync.Once
-	mutex         sync.Mutex
 	brokerMutex   sync.Mutex
+	routeMutex    sync.Mutex // 分配互斥:按券商资金、持仓分配并占用,申报不在锁内
 )
 
 // BrokerServiceInstance 实例
 func BrokerServiceInstance() *BrokerService {
 	brokerOnce.Do(func() {
-		brokerService = &BrokerService{
-			brokerMap: make(map[int64]*model.Broker),
-		}
+		brokerService = newBrokerService(defaultServices(), newBrokerGateway())
 		ctx := context.Background()
 		// 等待券商通道连接:查询数据库配置的券商,未连接的自动连接
 		if err := brokerService.clientConn(ctx); err != nil {
 			log.Errorf("clientConn err:%+v", err)
 		}
+		brokerService.startPollers(ctx)
 
 		go func() {
-			// 从数据库读取券商信息,调用客户端读取券商数据&更新券商信息到brokers
-			for range time.Tick(2 * time.Minute) {
-				mutex.Lock()
+			// 从数据库读取券商信息,调用客户端读取券商数据&更新券商信息到brokers,已连接的券商各自查询成交
+			for range time.Tick(brokerConnTick) {
 				if err := brokerService.clientConn(ctx); err != nil {
 					log.Errorf("clientConn err:%+v", err)
 					// 发送短信提醒
 				}
-				mutex.Unlock()
-			}
-		}()
-		go func() {
-			// 通过通道查询是否成交
-			for range time.Tick(1 * time.Second) {
-				mutex.Lock()
-				if err := brokerService.query(ctx); err != nil {
-					log.Errorf("query err:%+v", err)
-				}
-				mutex.Unlock()
+				brokerService.startPollers(ctx)
 			}
 		}()
 	})
 	return brokerService
 }
 
+// newBrokerService 使用交易核心服务及指定券商柜台,不启动连接、查询任务
+func newBrokerService(svc *Services, gateway BrokerGateway) *BrokerService {
+	return &BrokerService{
+		core:        svc.core,
+		trade:       svc.Trade,
+		contract:    svc.Contract,
+		ledger:      svc.Ledger,
+		gateway:     gateway,
+		brokerMap:   make(map[int64]*model.Broker),
+		health:      make(map[int64]*model.BrokerHealth),
+		pollers:     make(map[int64]chan struct{}),
+		brokerLocks: make(map[int64]*sync.Mutex),
+		reserved:    make(map[int64]*routeReservation),
+		holds:       make(map[int64][]*routeHold),
+	}
+}
+
+// GetBrokers 已连接的券商,按优先级排序
 func (s *BrokerService) GetBrokers() []*model.Broker {
 	brokers := make([]*model.Broker, 0)
 	brokerMutex.Lock()
@@ -69,105 +85,30 @@ func (s *BrokerService) GetBrokers() []*model.Broker {
 	}
 	brokerMutex.Unlock()
 	sort.SliceStable(brokers, func(i, j int) bool {
+		if brokers[i].Priority == brokers[j].Priority {
+			return brokers[i].ID < brokers[j].ID
+		}
 		return brokers[i].Priority > brokers[j].Priority
 	})
 	return brokers
 }
 
-// query 查询订单是否成交
+// query 逐个券商查询一次未终态委托及资金、持仓,单个券商查询失败不影响其他券商
 func (s *BrokerService) query(ctx context.Context) error {
-	brokers := s.GetBrokers()
-	if len(brokers) == 0 {
-		return nil
-	}
-	brokerTdxEntrustMap := make(map[int64][]*model.TDXTodayEntrust, 0)
-	for _, broker := range brokers {
+	for _, broker := range s.GetBrokers() {
 		if broker.ClientID == 0 {
 			continue
 		}
-		// 1. 查询资金
-		if err := s.queryFund(broker); err != nil {
-			log.Errorf("queryFund err:%+v", err)
-			s.disConnect(broker)
-			return err
-		}
-
-		// 2. 查询持仓
-		if err := s.queryPosition(broker); err != nil {
-			log.Errorf("资金账号:%+v 查询持仓失败:%+v", broker.FundAccount, err)
-			s.disConnect(broker)
-			return err
-		}
-
-		// 3.查询成交
-		tdxEntrusts, err := TDXServiceInstance().QueryTodayEntrust(broker)
-		if err != nil {
-			log.Errorf("资金账号:%+v 查询今日委托失败:%+v", broker.FundAccount, err)
-			s.disConnect(broker)
+		if _, _, err := s.pollBroker(ctx, broker, true); err != nil {
 			return err
 		}
-		if len(tdxEntrusts) != 0 {
-			brokerTdxEntrustMap[broker.ID] = tdxEntrusts
-		}
-	}
-
-	// 检查券商是否成交|撤单
-	if err := TradeServiceInstance().BrokerTrade(ctx, brokerTdxEntrustMap); err != nil {
-		log.Errorf("券商成交失败,BrokerTrade err:%+v", err)
-		return err
-	}
-	return nil
-}
-
-// queryFund 查询资金
-func (s *BrokerService) queryFund(broker *model.Broker) error {
-	fund, err := TDXServiceInstance().QueryFund(broker)
-	if err != nil {
-		log.Errorf("QueryFund err:%+v", err)
-		return err
 	}
-	broker.ValMoney = fund.ValMoney
-	broker.Asset = fund.Asset
 	return nil
 }
 
-// queryPosition 查询持仓
-func (s *BrokerService) queryPosition(broker *model.Broker) error {
-	positions, err := TDXServiceInstance().QueryPosition(broker)
-	if err != nil {
-		log.Errorf("资金账号:%+v 查询持仓失败:%+v", broker.FundAccount, err)
-		return err
-	}
-	brokerPosition := make([]*model.BrokerPosition, 0)
-	for _, it := range positions {
-		brokerPosition = append(brokerPosition, &model.BrokerPosition{
-			StockCode:     it.StockCode,     // 股票代码
-			StockName:     it.StockName,     // 股票名称
-			Amount:        it.Amount,        // 总数量
-			FreezeAmount:  it.FreezeAmount,  // 冻结数量
-			PositionPrice: it.PositionPrice, // 持仓价格
-			CurrentPrice:  it.CurrentPrice,  // 当前价格
-		})
-	}
-	broker.BrokerPosition = brokerPosition
-	return nil
-}
-
-// disConnect 处理断开连接的券商，优化:连续2次查询失败则断开连接
-func (s *BrokerService) disConnect(broker *model.Broker) {
-	broker.IoTimes++
-	brokerMutex.Lock()
-	if broker.IoTimes > 3 {
-		delete(s.brokerMap, broker.ID)
-	} else {
-		s.brokerMap[broker.ID] = broker
-	}
-	brokerMutex.Unlock()
-}
-
-// clientConn 客户端连接
+// clientConn 客户端连接,断开的券商到达退避时间后重连
 func (s *BrokerService) clientConn(ctx context.Context) error {
-	list, err := dao.BrokerDaoInstance().GetBrokers(ctx)
+	list, err := s.core.BrokerAccount.GetBrokers(ctx)
 	if err != nil {
 		log.Errorf("GetBrokers err:%+v", err)
 		return err
@@ -176,13 +117,17 @@ func (s *BrokerService) clientConn(ctx context.Context) error {
 		return nil
 	}
 
+	now := time.Now()
 	conn := make(map[int64]*model.Broker)
 	for _, broker := range list {
 		// 过滤掉不生效的券商
 		if broker.Status != model.BrokerStatusEnable {
 			continue
 		}
-		if _, ok := s.brokerMap[broker.ID]; !ok {
+		brokerMutex.Lock()
+		_, ok := s.brokerMap[broker.ID]
+		brokerMutex.Unlock()
+		if !ok && s.canRetry(broker.ID, now) {
 			conn[broker.ID] = broker
 		}
 	}
@@ -191,9 +136,10 @@ func (s *BrokerService) clientConn(ctx context.Context) error {
 	}
 
 	for _, broker := range conn {
-		clientID, err := TDXServiceInstance().Login(broker)
+		clientID, err := s.gateway.Login(broker)
 		if err != nil {
-			log.Errorf("连接券商:%+v 失败:%+v", broker, err)
+			log.Errorf("连接券商:%+v 失败:%+v", broker.BrokerName, err)
+			s.markConnFailure(ctx, broker, err)
 			continue
 		}
 		broker.ClientID = clientID
@@ -201,181 +147,57 @@ func (s *BrokerService) clientConn(ctx context.Context) error {
 		brokerMutex.Lock()
 		s.brokerMap[broker.ID] = broker
 		brokerMutex.Unlock()
+		s.markConnected(ctx, broker)
 		log.Infof("券商:%+v 资金账号:%+v 连接成功!client_id:%+v", broker.BrokerName, broker.FundAccount, broker.ClientID)
 	}
 
 	return nil
 }
 
-// getMatchBroker 查询匹配的券商
-func (s *BrokerService) getMatchBroker(e *model.Entrust) ([]*model.BrokerEntrust, error) {
-	// 买入:找到匹配的券商
-	buy := func() ([]*model.BrokerEntrust, error) {
-		for _, broker := range s.GetBrokers() {
-			// 加上手续费,是为了找出更合适的
-			if broker.ValMoney > e.Balance+e.Fee {
-				return []*model.BrokerEntrust{
-					{
-						UID:             e.UID,                           // 用户ID
-						ContractID:      e.ContractID,                    // 合约编号
-						BrokerID:        broker.ID,                       // 券商ID
-						EntrustID:       e.ID,                            // 委托表ID
-						OrderTime:       e.OrderTime,                     // 订单时间
-						StockCode:       e.StockCode,                     // 股票代码
-						StockName:       e.StockName,                     // 股票名称
-						EntrustAmount:   e.Amount,                        // 委托总股数
-						EntrustPrice:    e.Price,                         // 委托价格
-						EntrustBalance:  e.Balance,                       // 委托总金额
-						DealAmount:      0,                               // 成交数量
-						DealPrice:       0,                               // 成交价格
-						DealBalance:     0,                               // 成交总金额
-						Status:          model.EntrustStatusTypeReported, // 订单状态:已申报
-						EntrustBs:       e.EntrustBS,                     // 交易类型:1买入 2卖出
-						EntrustProp:     e.EntrustProp,                   // 委托类型:1限价 2市价
-						Fee:             e.Fee,                           // 券商交易总手续费
-						BrokerEntrustNo: "",                              // 券商委托编号
-						Broker:          broker,                          // 券商
-					},
-				}, nil
-			}
-		}
-
-		// 多母账户分笔订单买入
-		brokerEntrusts := make([]*model.BrokerEntrust, 0)
-		var entrustedAmount int64
-		for _, broker := range s.GetBrokers() {
-			amount := (int64((broker.ValMoney-e.Fee)/e.Price) / 100) * 100
-			if amount < 100 {
-				continue
-			}
-			if amount >= e.Amount-entrustedAmount {
-				amount = e.Amount - entrustedAmount
-			}
-			entrustedAmount += amount
-			brokerEntrusts = append(brokerEntrusts, &model.BrokerEntrust{
-				UID:             e.UID,                           // 用户ID
-				ContractID:      e.ContractID,                    // 合约编号
-				BrokerID:        broker.ID,                       // 券商ID
-				EntrustID:       e.ID,                            // 委托表ID
-				OrderTime:       e.OrderTime,                     // 订单时间
-				StockCode:       e.StockCode,                     // 股票代码
-				StockName:       e.StockName,                     // 股票名称
-				EntrustAmount:   amount,                          // 委托总股数
-				EntrustPrice:    e.Price,                         // 委托价格
-				EntrustBalance:  e.Price * float64(amount),       // 委托总金额
-				DealAmount:      0,                               // 成交数量
-				DealPrice:       0,                               // 成交价格
-				DealBalance:     0,                               // 成交总金额
-				Status:          model.EntrustStatusTypeReported, // 订单状态:已申报
-				EntrustBs:       e.EntrustBS,                     // 交易类型:1买入 2卖出
-				EntrustProp:     e.EntrustProp,                   // 委托类型:1限价 2市价
-				Fee:             0,                               // 券商交易总手续费(分笔买入券商手续费不准，填写0)
-				BrokerEntrustNo: "",                              // 券商委托编号
-				Broker:          broker,                          // 券商
-			})
-			if entrustedAmount == e.Amount {
-				return brokerEntrusts, nil
-			}
-		}
-		return nil, errors.New("无效券商通道或母账户资金不足")
-	}
-
-	// 卖出:找到持仓该股票的券商
-	sell := func() ([]*model.BrokerEntrust, error) {
-		var entrustedAmount int64
-		brokerEntrusts := make([]*model.BrokerEntrust, 0)
-		oddAmount := e.Amount % 100 // 零股
-		for _, broker := range s.GetBrokers() {
-			for _, it := range broker.BrokerPosition {
-				if it.StockCode != e.StockCode {
-					continue
-				}
-				// 零股卖出处理
-				valAmount := it.Amount - it.FreezeAmount
-				if valAmount <= 0 {
-					continue
-				}
-				amount := int64((valAmount)/100) * 100
-				if oddAmount > 0 && valAmount%100 == oddAmount {
-					amount += oddAmount
-				}
-
-				if amount >= e.Amount-entrustedAmount {
-					amount = e.Amount - entrustedAmount
-				}
-				entrustedAmount += amount
-				brokerEntrusts = append(brokerEntrusts, &model.BrokerEntrust{
-					UID:             e.UID,                           // 用户ID
-					ContractID:      e.ContractID,                    // 合约编号
-					BrokerID:        broker.ID,                       // 券商ID
-					EntrustID:       e.ID,                            // 委托表ID
-					OrderTime:       e.OrderTime,                     // 订单时间
-					StockCode:       e.StockCode,                     // 股票代码
-					StockName:       e.StockName,                     // 股票名称
-					EntrustAmount:   amount,                          // 委托总股数
-					EntrustPrice:    e.Price,                         // 委托价格
-					EntrustBalance:  e.Price * float64(amount),       // 委托总金额
-					DealAmount:      0,                               // 成交数量
-					DealPrice:       0,                               // 成交价格
-					DealBalance:     0,                               // 成交总金额
-					Status:          model.EntrustStatusTypeReported, // 订单状态：已申报
-					EntrustBs:       e.EntrustBS,                     // 交易类型:1买入 2卖出
-					EntrustProp:     e.EntrustProp,                   // 委托类型:1限价 2市价
-					Fee:             e.Fee,                           // 券商交易总手续费
-					BrokerEntrustNo: "",                              // 券商委托编号
-					Broker:          broker,                          // 券商客户ID
-				})
-				if entrustedAmount == e.Amount {
-					return brokerEntrusts, nil
-				}
-			}
-		}
-		if entrustedAmount < e.Amount {
-			log.Infof("股票代码:%+v 母账户可委托卖出数量:%+v", e.StockCode, entrustedAmount)
-			return nil, errors.New("母账户持有该股票可卖股份不足")
-		}
-		return brokerEntrusts, nil
-	}
-
-	switch e.EntrustBS {
-	case model.EntrustBsTypeBuy:
-		return buy()
-	case model.EntrustBsTypeSell:
-		return sell()
-	}
-	return nil, serr.ErrBusiness("无效券商通道")
-}
-
-// entrust 调用券商通道申报订单
-func (s *BrokerService) entrust(entrust *model.Entrust) ([]*model.BrokerEntrust, error) {
+// entrust 调用券商通道申报订单:分配券商时占用资金、持仓,申报在routeMutex外逐笔进行,
+// 柜台响应慢不阻塞其他委托的分配。返回释放占用的函数,券商委托入库失败时调用
+func (s *BrokerService) entrust(ctx context.Context, entrust *model.Entrust) ([]*model.BrokerEntrust, func(), error) {
 	brokers := s.GetBrokers()
 	if len(brokers) == 0 {
-		return nil, errors.New("未连接券商通道")
+		return nil, nil, errors.New("未连接券商通道")
 	}
 
-	// 买入,卖出寻找合适的券商
-	brokerEntrusts, err := s.getMatchBroker(entrust)
+	// 买入,卖出按分配策略选择券商
+	routeMutex.Lock()
+	brokerEntrusts, err := s.route(ctx, entrust)
+	if err == nil {
+		s.reserve(brokerEntrusts)
+	}
+	routeMutex.Unlock()
 	if err != nil {
-		log.Errorf("getMatchBroker err:%+v", err)
-		return nil, err
+		log.Errorf("route err:%+v", err)
+		return nil, nil, err
+	}
+	release := func() {
+		routeMutex.Lock()
+		s.release(brokerEntrusts)
+		routeMutex.Unlock()
 	}
 
 	// 逐笔委托
 	for _, brokerEntrust := range brokerEntrusts {
-		if err := TDXServiceInstance().Entrust(brokerEntrust); err != nil {
-			log.Errorf("通达信委托失败:%+v", err)
-			return nil, err
+		if err := s.gateway.Entrust(brokerEntrust); err != nil {
+			log.Errorf("券商委托失败:%+v", err)
+			release()
+			return nil, nil, err
 		}
 	}
 
-	return brokerEntrusts, nil
+	return brokerEntrusts, release, nil
 }
 
-// Withdraw 撤单委托申请
+// Withdraw 撤单委托申请,撤单后立即查询撤单结果
 func (s *BrokerService) Withdraw(entrust *model.BrokerEntrust, broker *model.Broker, entrustNo string) error {
-	mutex.Lock()
-	defer mutex.Unlock()
-	return TDXServiceInstance().CancelOrder(entrust, broker, entrustNo)
+	if err := s.gateway.CancelOrder(entrust, broker, entrustNo); err != nil {
+		return err
+	}
+	s.wake(broker.ID)
+	return nil
 }
 
 // Entrust 券商委托申报
@@ -385,61 +207,82 @@ func (s *BrokerService) Entrust(entrust *model.Entrust) error {
 		return nil
 	}
 
-	mutex.Lock()
-	defer mutex.Unlock()
+	defer lockEntrust(entrust.ID)()
 	// 券商通道正常,则进行委托撤单
-	brokerEntrusts, err := s.entrust(entrust)
+	brokerEntrusts, release, err := s.entrust(ctx, entrust)
 	if err != nil {
 		// 券商委托失败,则废单处理
 		log.Errorf("订单委托失败:%+v,err:%+v", entrust, err)
 		return s.cancelEntrust(ctx, entrust, err.Error())
 	}
+	// 入库前失败则释放占用
+	stored := false
+	defer func() {
+		if !stored {
+			release()
+		}
+	}()
 
 	// 更新委托表
 	entrust.Status = model.EntrustStatusTypeReported // 委托状态:已申报,未成交
-	if err := dao.EntrustDaoInstance().Update(ctx, entrust); err != nil {
+	if err := s.core.Entrust.Update(ctx, entrust); err != nil {
 		log.Errorf("订单申报填写委托表失败 err:%+v", err)
 		return err
 	}
 
 	// 创建券商委托表
-	return dao.BrokerEntrustDaoInstance().MCreate(ctx, brokerEntrusts)
+	if err := s.core.BrokerEntrust.MCreate(ctx, brokerEntrusts); err != nil {
+		return err
+	}
+	// 入库后计入当日委托,资金、持仓占用保留到券商资金、持仓刷新
+	routeMutex.Lock()
+	s.release(brokerEntrusts)
+	s.hold(brokerEntrusts)
+	routeMutex.Unlock()
+	stored = true
+	for _, it := range brokerEntrusts {
+		s.wake(it.BrokerID)
+	}
+	return nil
 }
 
 // cancelEntrust 券商委托失败，委托作废
 func (s *BrokerService) cancelEntrust(ctx context.Context, entrust *model.Entrust, cancelReason string) error {
 	entrust.Status = model.EntrustStatusTypeCancel
 	entrust.Remark = cancelReason
-	if err := dao.EntrustDaoInstance().Update(ctx, entrust); err != nil {
+	if err := s.core.Entrust.Update(ctx, entrust); err != nil {
 		return err
 	}
 
 	// 废单卖出更新冻结
 	if entrust.EntrustBS == model.EntrustBsTypeSell {
-		position, err := dao.PositionDaoInstance().GetPositionByID(ctx, entrust.PositionID)
+		position, err := s.core.Position.GetPositionByID(ctx, entrust.PositionID)
 		if err != nil {
 			log.Errorf("卖出废单,查询持仓失败;GetPositionByID err:%+v", err)
 			return err
 		}
 		position.FreezeAmount -= entrust.Amount
-		if err := dao.PositionDaoInstance().Update(ctx, position); err != nil {
+		if err := s.core.Position.Update(ctx, position); err != nil {
 			log.Errorf("更新持仓失败:%+v", err)
 			return err
 		}
 	}
 
 	// 更新可用资金
-	if err := ContractServiceInstance().UpdateValMoneyByID(ctx, entrust.ContractID); err != nil {
+	if err := s.contract.UpdateValMoneyByID(ctx, entrust.ContractID); err != nil {
 		return err
 	}
 	return nil
 }
 
-// Create 创建券商
+// Create 创建券商,修改配置后断开连接,下次检查时按新配置重连
 func (s *BrokerService) Create(ctx context.Context, broker *model.Broker) error {
 	if err := dao.BrokerDaoInstance().Create(ctx, broker); err != nil {
 		return err
 	}
-	s.disConnect(broker)
+	brokerMutex.Lock()
+	delete(s.brokerMap, broker.ID)
+	delete(s.health, broker.ID)
+	brokerMutex.Unlock()
 	return nil
 }
@@ -0,0 +1,447 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"stock/api-gateway/model"
+	"stock/api-gateway/util"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 模拟柜台委托状态,与通达信当日委托的状态说明一致
+const (
+	simStatusReported     = "已报"
+	simStatusPartDeal     = "部成"
+	simStatusDeal         = "已成"
+	simStatusPartWithdraw = "部撤"
+	simStatusWithdraw     = "已撤"
+	simEntrustNoStart     = 100000     // 委托编号起始值
+	simDateLayout         = "20060102" // 交易日格式
+	simEntrustTimeLayout  = "15:04:05" // 委托时间格式
+)
+
+// BrokerSimulator 本地模拟券商柜台:按资金账号保存资金、持仓和当日委托,查询当日委托时按最新行情撮合,
+// 不计手续费。用于无券商环境下联调券商路由、分笔成交和撤单
+type BrokerSimulator struct {
+	// FillLot 每次撮合最多成交的股数,0表示一次全部成交,用于模拟部分成交
+	FillLot int64
+
+	mu       sync.Mutex
+	quote    QuoteSource
+	initCash float64
+	now      func() time.Time
+	seq      int64
+	clients  map[int64]*simAccount  // map[客户ID]资金账号
+	accounts map[string]*simAccount // map[资金账号]资金账号
+	offline  map[string]bool        // map[资金账号]模拟通道故障
+}
+
+// simAccount 模拟资金账号
+type simAccount struct {
+	cash       float64                 // 资金余额,含冻结
+	frozenCash float64                 // 买入委托冻结资金
+	positions  map[string]*simPosition // map[股票代码]持仓
+	orders     []*simOrder             // 当日委托
+	date       string                  // 交易日,跨日时清理当日委托、当日买入
+}
+
+// simPosition 模拟持仓
+type simPosition struct {
+	code     string
+	name     string
+	amount   int64   // 持仓数量
+	todayBuy int64   // 当日买入数量,次日可卖
+	frozen   int64   // 卖出委托冻结数量
+	cost     float64 // 持仓成本
+	price    float64 // 最新价
+}
+
+// simOrder 模拟委托
+type simOrder struct {
+	no          string
+	time        time.Time
+	code        string
+	name        string
+	bs          int64
+	market      bool
+	price       float64 // 委托价格:买入按委托价格冻结资金
+	amount      int64
+	dealAmount  int64
+	dealBalance float64
+	status      string
+}
+
+// NewBrokerSimulator 创建模拟柜台,资金账号首次登录时的资金为initCash
+func NewBrokerSimulator(quote QuoteSource, initCash float64) *BrokerSimulator {
+	return &BrokerSimulator{
+		quote:    quote,
+		initCash: initCash,
+		now:      time.Now,
+		clients:  make(map[int64]*simAccount),
+		accounts: make(map[string]*simAccount),
+		offline:  make(map[string]bool),
+	}
+}
+
+// SetOffline 模拟资金账号通道故障:登录、委托、查询均返回错误,用于演练熔断和重连
+func (s *BrokerSimulator) SetOffline(fundAccount string, offline bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offline[fundAccount] = offline
+}
+
+// Login 登录资金账号,重复登录返回新的客户ID,资金、持仓保持不变
+func (s *BrokerSimulator) Login(broker *model.Broker) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.offline[broker.FundAccount] {
+		return 0, errors.New("连接超时")
+	}
+	acct, ok := s.accounts[broker.FundAccount]
+	if !ok {
+		acct = &simAccount{cash: s.initCash, positions: make(map[string]*simPosition), date: s.today()}
+		s.accounts[broker.FundAccount] = acct
+	}
+	s.seq++
+	s.clients[s.seq] = acct
+	return s.seq, nil
+}
+
+// Entrust 委托:买入冻结资金,卖出冻结可卖股份
+func (s *BrokerSimulator) Entrust(entrust *model.BrokerEntrust) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acct, err := s.account(entrust.Broker)
+	if err != nil {
+		return err
+	}
+	quotes, err := s.quote.GetQuoteByTencent([]string{entrust.StockCode})
+	if err != nil {
+		return errors.New("无法获取行情")
+	}
+	qt, ok := quotes[entrust.StockCode]
+	if !ok || qt.CurrentPrice <= 0 {
+		return errors.New("无法获取行情")
+	}
+	order := &simOrder{
+		time:   s.now(),
+		code:   entrust.StockCode,
+		name:   qt.Name,
+		bs:     entrust.EntrustBs,
+		market: entrust.EntrustProp == model.EntrustPropTypeMarketPrice,
+		price:  entrust.EntrustPrice,
+		amount: entrust.EntrustAmount,
+		status: simStatusReported,
+	}
+	// 市价委托没有保护价格时按最新价冻结
+	if order.market && order.price <= 0 {
+		order.price = qt.CurrentPrice
+	}
+	if order.amount <= 0 || order.price <= 0 {
+		return errors.New("委托数量或价格错误")
+	}
+
+	switch order.bs {
+	case model.EntrustBsTypeBuy:
+		balance := order.price * float64(order.amount)
+		if acct.cash-acct.frozenCash < balance {
+			return errors.New("可用资金不足")
+		}
+		acct.frozenCash += balance
+	case model.EntrustBsTypeSell:
+		position, ok := acct.positions[order.code]
+		if !ok || position.amount-position.todayBuy-position.frozen < order.amount {
+			return errors.New("可卖数量不足")
+		}
+		position.frozen += order.amount
+	default:
+		return errors.New("委托数量或价格错误")
+	}
+
+	s.seq++
+	order.no = strconv.FormatInt(simEntrustNoStart+s.seq, 10)
+	acct.orders = append(acct.orders, order)
+	entrust.BrokerEntrustNo = order.no
+	return nil
+}
+
+// CancelOrder 撤单:释放未成交部分冻结的资金或股份
+func (s *BrokerSimulator) CancelOrder(entrust *model.BrokerEntrust, broker *model.Broker, entrustNo string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acct, err := s.account(broker)
+	if err != nil {
+		return err
+	}
+	for _, order := range acct.orders {
+		if order.no != entrustNo {
+			continue
+		}
+		if !order.open() {
+			return errors.New("委托已成交或已撤单")
+		}
+		acct.release(order)
+		order.status = simStatusWithdraw
+		if order.dealAmount > 0 {
+			order.status = simStatusPartWithdraw
+		}
+		return nil
+	}
+	return errors.New("委托不存在")
+}
+
+// QueryFund 查询资金
+func (s *BrokerSimulator) QueryFund(broker *model.Broker) (*model.TDXBrokerFund, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acct, err := s.account(broker)
+	if err != nil {
+		return nil, err
+	}
+	var marketValue float64
+	for _, it := range acct.positions {
+		marketValue += it.price * float64(it.amount)
+	}
+	return &model.TDXBrokerFund{
+		ClientID:    broker.ClientID,
+		FundAccount: broker.FundAccount,
+		ValMoney:    util.FloatRound(acct.cash-acct.frozenCash, 2),
+		Asset:       util.FloatRound(acct.cash+marketValue, 2),
+		MarketValue: util.FloatRound(marketValue, 2),
+	}, nil
+}
+
+// QueryPosition 查询持仓:冻结数量为当日买入与卖出委托冻结之和
+func (s *BrokerSimulator) QueryPosition(broker *model.Broker) ([]*model.TDXPosition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acct, err := s.account(broker)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*model.TDXPosition, 0, len(acct.positions))
+	for _, it := range acct.positions {
+		list = append(list, &model.TDXPosition{
+			ClientID:      broker.ClientID,
+			FundAccount:   broker.FundAccount,
+			StockCode:     it.code,
+			StockName:     it.name,
+			Amount:        it.amount,
+			FreezeAmount:  it.todayBuy + it.frozen,
+			PositionPrice: util.FloatRound(it.cost/float64(it.amount), 3),
+			CurrentPrice:  it.price,
+		})
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].StockCode < list[j].StockCode
+	})
+	return list, nil
+}
+
+// QueryTodayEntrust 查询当日委托,查询前按最新行情撮合未成交委托
+func (s *BrokerSimulator) QueryTodayEntrust(broker *model.Broker) ([]*model.TDXTodayEntrust, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acct, err := s.account(broker)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.match(acct); err != nil {
+		return nil, err
+	}
+	list := make([]*model.TDXTodayEntrust, 0, len(acct.orders))
+	for _, it := range acct.orders {
+		entrust := &model.TDXTodayEntrust{
+			ClientID:    broker.ClientID,
+			FundAccount: broker.FundAccount,
+			EntrustNo:   it.no,
+			StockCode:   it.code,
+			StockName:   it.name,
+			EntrustBs:   int(it.bs),
+			Price:       it.price,
+			Amount:      it.amount,
+			DealAmount:  it.dealAmount,
+			DealBalance: util.FloatRound(it.dealBalance, 2),
+			Status:      it.status,
+		}
+		entrust.EntrustTime, _ = time.Parse(simEntrustTimeLayout, it.time.Format(simEntrustTimeLayout))
+		if it.dealAmount > 0 {
+			entrust.DealPrice = util.FloatRound(it.dealBalance/float64(it.dealAmount), 3)
+		}
+		list = append(list, entrust)
+	}
+	return list, nil
+}
+
+// QueryOrderEvents 撮合后生成未终态券商委托的状态变化事件,与通达信当日委托的状态一致
+func (s *BrokerSimulator) QueryOrderEvents(broker *model.Broker, open []*model.BrokerEntrust) ([]*model.BrokerOrderEvent, error) {
+	list, err := s.QueryTodayEntrust(broker)
+	if err != nil {
+		return nil, err
+	}
+	return tdxOrderEvents(broker, list, open), nil
+}
+
+// QueryWithdraw 查询可撤单
+func (s *BrokerSimulator) QueryWithdraw(broker *model.Broker) ([]*model.TDXWithdraw, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acct, err := s.account(broker)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*model.TDXWithdraw, 0)
+	for _, it := range acct.orders {
+		if !it.open() {
+			continue
+		}
+		item := &model.TDXWithdraw{
+			ClientID:      broker.ClientID,
+			FundAccount:   broker.FundAccount,
+			StockCode:     it.code,
+			StockName:     it.name,
+			EntrustBs:     int(it.bs),
+			EntrustPrice:  it.price,
+			EntrustAmount: it.amount,
+			EntrustNo:     it.no,
+			DealAmount:    it.dealAmount,
+		}
+		item.EntrustTime, _ = time.Parse(simEntrustTimeLayout, it.time.Format(simEntrustTimeLayout))
+		list = append(list, item)
+	}
+	return list, nil
+}
+
+// account 已登录的资金账号,跨日时未成交委托作废,当日买入变为可卖
+func (s *BrokerSimulator) account(broker *model.Broker) (*simAccount, error) {
+	if broker == nil {
+		return nil, errors.New("资金账号未登录")
+	}
+	if s.offline[broker.FundAccount] {
+		return nil, errors.New("连接超时")
+	}
+	acct, ok := s.clients[broker.ClientID]
+	if !ok {
+		return nil, errors.New("资金账号未登录")
+	}
+	if today := s.today(); acct.date != today {
+		for _, it := range acct.orders {
+			if it.open() {
+				acct.release(it)
+			}
+		}
+		acct.orders = nil
+		for _, it := range acct.positions {
+			it.todayBuy = 0
+		}
+		acct.date = today
+	}
+	return acct, nil
+}
+
+// match 按最新价撮合:买入委托价格不低于最新价、卖出委托价格不高于最新价时按最新价成交,市价委托直接成交
+func (s *BrokerSimulator) match(acct *simAccount) error {
+	codes := make([]string, 0)
+	for _, it := range acct.orders {
+		if it.open() {
+			codes = append(codes, it.code)
+		}
+	}
+	for code := range acct.positions {
+		codes = append(codes, code)
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	quotes, err := s.quote.GetQuoteByTencent(codes)
+	if err != nil {
+		return fmt.Errorf("无法获取行情:%v", err)
+	}
+	for code, it := range acct.positions {
+		if qt, ok := quotes[code]; ok && qt.CurrentPrice > 0 {
+			it.price = qt.CurrentPrice
+		}
+	}
+	for _, order := range acct.orders {
+		if !order.open() {
+			continue
+		}
+		qt, ok := quotes[order.code]
+		if !ok || qt.CurrentPrice <= 0 {
+			continue
+		}
+		price := qt.CurrentPrice
+		if !order.market {
+			if order.bs == model.EntrustBsTypeBuy && order.price < price {
+				continue
+			}
+			if order.bs == model.EntrustBsTypeSell && order.price > price {
+				continue
+			}
+		}
+		fill := order.amount - order.dealAmount
+		if s.FillLot > 0 && fill > s.FillLot {
+			fill = s.FillLot
+		}
+		acct.fill(order, fill, price)
+	}
+	return nil
+}
+
+func (s *BrokerSimulator) today() string {
+	return s.now().Format(simDateLayout)
+}
+
+// open 委托是否可继续成交、可撤
+func (o *simOrder) open() bool {
+	return o.status == simStatusReported || o.status == simStatusPartDeal
+}
+
+// fill 成交:买入按委托价格解冻资金后按成交价扣款,卖出扣减冻结股份后按成交价入账
+func (a *simAccount) fill(order *simOrder, amount int64, price float64) {
+	balance := price * float64(amount)
+	position, ok := a.positions[order.code]
+	if !ok {
+		position = &simPosition{code: order.code, name: order.name}
+		a.positions[order.code] = position
+	}
+	position.price = price
+	switch order.bs {
+	case model.EntrustBsTypeBuy:
+		a.frozenCash -= order.price * float64(amount)
+		a.cash -= balance
+		position.amount += amount
+		position.todayBuy += amount
+		position.cost += balance
+	case model.EntrustBsTypeSell:
+		position.cost -= position.cost * float64(amount) / float64(position.amount)
+		position.amount -= amount
+		position.frozen -= amount
+		a.cash += balance
+		if position.amount == 0 {
+			delete(a.positions, order.code)
+		}
+	}
+	order.dealAmount += amount
+	order.dealBalance += balance
+	order.status = simStatusPartDeal
+	if order.dealAmount == order.amount {
+		order.status = simStatusDeal
+	}
+}
+
+// release 释放委托未成交部分冻结的资金或股份
+func (a *simAccount) release(order *simOrder) {
+	remain := order.amount - order.dealAmount
+	switch order.bs {
+	case model.EntrustBsTypeBuy:
+		a.frozenCash -= order.price * float64(remain)
+	case model.EntrustBsTypeSell:
+		if position, ok := a.positions[order.code]; ok {
+			position.frozen -= remain
+		}
+	}
+}
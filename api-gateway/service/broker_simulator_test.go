@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"stock/api-gateway/fake"
+	"stock/api-gateway/model"
+)
+
+// newSimBrokerCore 券商委托模式,一个模拟柜台母账户
+func newSimBrokerCore(t *testing.T) (*fake.Store, *fake.Quote, *BrokerSimulator, *BrokerService) {
+	ctx := context.Background()
+	store, qt, svc := newFakeServices()
+	store.SetSysParam(&model.SysParam{BuyFee: 0.0003, SellFee: 0.0013, MiniChargeFee: 5, LowWarnCanBuy: true, IsSupportBroker: true})
+	store.PutStockData(&model.StockData{Code: "600000", Status: model.StockDataStatusEnable})
+	store.PutBroker(&model.Broker{FundAccount: "sim001", Status: model.BrokerStatusEnable, Priority: 1, BrokerName: "模拟券商"})
+	qt.Set(&model.TencentQuote{Code: "600000", Name: "浦发银行", CurrentPrice: 10, ClosePrice: 10})
+
+	sim := NewBrokerSimulator(qt, 100000)
+	broker := newBrokerService(svc, sim)
+	svc.core.Broker = broker
+	if err := broker.clientConn(ctx); err != nil || len(broker.GetBrokers()) != 1 {
+		t.Fatalf("clientConn: %d %+v", len(broker.GetBrokers()), err)
+	}
+	if err := broker.query(ctx); err != nil {
+		t.Fatalf("query: %+v", err)
+	}
+	return store, qt, sim, broker
+}
+
+// waitBrokerEntrust 等待异步申报的券商委托
+func waitBrokerEntrust(t *testing.T, c *Core, contractID int64) *model.Entrust {
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		list, _ := c.Entrust.GetTodayEntrust(ctx, contractID)
+		for _, it := range list {
+			if it.Status != model.EntrustStatusTypeReported {
+				continue
+			}
+			if brokerEntrusts, _ := c.BrokerEntrust.GetByEntrustID(ctx, it.ID); len(brokerEntrusts) > 0 {
+				return it
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("broker entrust timeout")
+	return nil
+}
+
+// TestBrokerSimulatorTrade 券商委托:模拟柜台分笔成交->撤单部撤->全部成交
+func TestBrokerSimulatorTrade(t *testing.T) {
+	ctx := context.Background()
+	store, _, sim, broker := newSimBrokerCore(t)
+	sim.FillLot = 300
+	user := store.PutUser(&model.User{Status: model.UserStatusActive})
+	contract := store.PutContract(&model.Contract{
+		UID: user.ID, InitMoney: 10000, Money: 10000, ValMoney: 20000, Lever: 1, Status: model.ContractStatusEnable,
+	})
+
+	// 1.买入1000股,每次撮合成交300股,部成不是终态
+	if err := broker.trade.Buy(ctx, &model.EntrustPackage{
+		UID: user.ID, ContractID: contract.ID, Code: "600000", Price: 10, Amount: 1000, EntrustProp: model.EntrustPropTypeLimitPrice,
+	}); err != nil {
+		t.Fatalf("buy: %+v", err)
+	}
+	entrust := waitBrokerEntrust(t, broker.core, contract.ID)
+	if err := broker.query(ctx); err != nil {
+		t.Fatalf("query: %+v", err)
+	}
+	if e, _ := broker.core.Entrust.GetEntrustByID(ctx, entrust.ID); e.Status != model.EntrustStatusTypeReported {
+		t.Fatalf("expect reported, got %d", e.Status)
+	}
+	fund, _ := sim.QueryFund(broker.GetBrokers()[0])
+	assertMoney(t, "sim val money", fund.ValMoney, 90000)
+
+	// 2.撤单:已成交300股,剩余部分撤单
+	if err := broker.trade.Withdraw(ctx, entrust.ID); err != nil {
+		t.Fatalf("withdraw: %+v", err)
+	}
+	if err := broker.query(ctx); err != nil {
+		t.Fatalf("query: %+v", err)
+	}
+	e, _ := broker.core.Entrust.GetEntrustByID(ctx, entrust.ID)
+	if e.Status != model.EntrustStatusTypePartDealPartWithdraw || e.DealAmount != 300 {
+		t.Fatalf("expect part withdraw 300, got %d %d", e.Status, e.DealAmount)
+	}
+	position, err := broker.core.Position.GetContractPositionByCode(ctx, contract.ID, "600000")
+	if err != nil || position.Amount != 300 {
+		t.Fatalf("position: %+v %+v", position, err)
+	}
+	fund, _ = sim.QueryFund(broker.GetBrokers()[0])
+	assertMoney(t, "sim val money after withdraw", fund.ValMoney, 97000)
+
+	// 3.再买入500股,一次全部成交
+	sim.FillLot = 0
+	if err := broker.trade.Buy(ctx, &model.EntrustPackage{
+		UID: user.ID, ContractID: contract.ID, Code: "600000", Price: 10, Amount: 500, EntrustProp: model.EntrustPropTypeLimitPrice,
+	}); err != nil {
+		t.Fatalf("buy: %+v", err)
+	}
+	entrust = waitBrokerEntrust(t, broker.core, contract.ID)
+	if err := broker.query(ctx); err != nil {
+		t.Fatalf("query: %+v", err)
+	}
+	if e, _ := broker.core.Entrust.GetEntrustByID(ctx, entrust.ID); e.Status != model.EntrustStatusTypeDeal || e.DealAmount != 500 {
+		t.Fatalf("expect deal 500, got %d %d", e.Status, e.DealAmount)
+	}
+	position, _ = broker.core.Position.GetContractPositionByCode(ctx, contract.ID, "600000")
+	if position.Amount != 800 {
+		t.Fatalf("expect position 800, got %d", position.Amount)
+	}
+	positions, _ := sim.QueryPosition(broker.GetBrokers()[0])
+	if len(positions) != 1 || positions[0].Amount != 800 || positions[0].FreezeAmount != 800 {
+		t.Fatalf("sim position: %+v", positions[0])
+	}
+}
+
+// TestBrokerSimulatorRules 模拟柜台:资金、可卖数量检查,T+1,跨日未成交委托作废
+func TestBrokerSimulatorRules(t *testing.T) {
+	qt := fake.NewQuote()
+	qt.Set(&model.TencentQuote{Code: "000001", Name: "平安银行", CurrentPrice: 10})
+	sim := NewBrokerSimulator(qt, 10000)
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.Local)
+	sim.now = func() time.Time { return now }
+	broker := &model.Broker{FundAccount: "sim002"}
+	broker.ClientID, _ = sim.Login(broker)
+	entrust := func(bs int64, price float64, amount int64) (*model.BrokerEntrust, error) {
+		e := &model.BrokerEntrust{Broker: broker, StockCode: "000001", EntrustBs: bs, EntrustPrice: price, EntrustAmount: amount}
+		return e, sim.Entrust(e)
+	}
+
+	if _, err := entrust(model.EntrustBsTypeBuy, 10, 1100); err == nil {
+		t.Fatal("expect no money")
+	}
+	if _, err := entrust(model.EntrustBsTypeBuy, 10, 500); err != nil {
+		t.Fatalf("buy: %+v", err)
+	}
+	// 限价低于最新价不成交,跨日作废
+	if _, err := entrust(model.EntrustBsTypeBuy, 9, 500); err != nil {
+		t.Fatalf("buy: %+v", err)
+	}
+	list, _ := sim.QueryTodayEntrust(broker)
+	if len(list) != 2 || list[0].Status != "已成" || list[1].Status != "已报" {
+		t.Fatalf("today entrust: %+v %+v", list[0], list[1])
+	}
+	if _, err := entrust(model.EntrustBsTypeSell, 10, 500); err == nil {
+		t.Fatal("expect T+1")
+	}
+
+	now = now.AddDate(0, 0, 1)
+	fund, _ := sim.QueryFund(broker)
+	assertMoney(t, "val money next day", fund.ValMoney, 5000)
+	if list, _ := sim.QueryWithdraw(broker); len(list) != 0 {
+		t.Fatalf("expect no withdraw, got %d", len(list))
+	}
+	sell, err := entrust(model.EntrustBsTypeSell, 11, 500)
+	if err != nil {
+		t.Fatalf("sell: %+v", err)
+	}
+	if _, err := entrust(model.EntrustBsTypeSell, 10, 100); err == nil {
+		t.Fatal("expect no sellable amount")
+	}
+	if err := sim.CancelOrder(sell, broker, sell.BrokerEntrustNo); err != nil {
+		t.Fatalf("cancel: %+v", err)
+	}
+	if err := sim.CancelOrder(sell, broker, sell.BrokerEntrustNo); err == nil {
+		t.Fatal("expect cancel finished")
+	}
+	qt.Set(&model.TencentQuote{Code: "000001", Name: "平安银行", CurrentPrice: 12})
+	if _, err := entrust(model.EntrustBsTypeSell, 11, 500); err != nil {
+		t.Fatalf("sell: %+v", err)
+	}
+	list, _ = sim.QueryTodayEntrust(broker)
+	if len(list) != 2 || list[0].Status != "已撤" || list[1].Status != "已成" || list[1].DealPrice != 12 {
+		t.Fatalf("today entrust: %+v %+v", list[0], list[1])
+	}
+	fund, _ = sim.QueryFund(broker)
+	assertMoney(t, "val money after sell", fund.ValMoney, 11000)
+	if positions, _ := sim.QueryPosition(broker); len(positions) != 0 {
+		t.Fatalf("expect no position, got %d", len(positions))
+	}
+}
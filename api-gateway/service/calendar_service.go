@@ -131,6 +131,25 @@ func (s *CalendarService) IsTradeDate(ctx context.Context) bool {
 	return isTradeDate
 }
 
+// IsTradeDay date是否为交易日,交易日历未覆盖的日期按工作日估算
+func (s *CalendarService) IsTradeDay(ctx context.Context, date time.Time) bool {
+	if isTradeDate, ok := s.calendar[timeconv.TimeToInt32(date)]; ok {
+		return isTradeDate
+	}
+	return date.Weekday() != time.Saturday && date.Weekday() != time.Sunday
+}
+
+// NextTradeDate date之后(不含当天)的第一个交易日,交易日历未覆盖时返回0
+func (s *CalendarService) NextTradeDate(ctx context.Context, date time.Time) int32 {
+	for i := 1; i <= 31; i++ {
+		d := timeconv.TimeToInt32(date.AddDate(0, 0, i))
+		if s.calendar[d] {
+			return d
+		}
+	}
+	return 0
+}
+
 // update 网络请求更新交易日历
 func (s *CalendarService) update(ctx context.Context) error {
 	calendar := make([]*model.TradeCalendar, 0)
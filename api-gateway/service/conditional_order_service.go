@@ -0,0 +1,372 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"stock/api-gateway/dao"
+	"stock/api-gateway/model"
+	"stock/api-gateway/quote"
+	"stock/api-gateway/serr"
+	"stock/common/log"
+	"stock/common/timeconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ConditionalOrderService 条件单服务:监控行情,达到止损、止盈、跟踪止损条件时自动委托卖出,达到条件买入条件或开盘时自动委托买入
+type ConditionalOrderService struct {
+}
+
+var (
+	conditionalOrderService *ConditionalOrderService
+	conditionalOrderOnce    sync.Once
+)
+
+// ConditionalOrderServiceInstance ConditionalOrderServiceInstance实例
+func ConditionalOrderServiceInstance() *ConditionalOrderService {
+	conditionalOrderOnce.Do(func() {
+		conditionalOrderService = &ConditionalOrderService{}
+		ctx := context.Background()
+
+		// 交易时间内监控条件单
+		go func() {
+			for range time.Tick(2 * time.Second) {
+				if err := conditionalOrderService.monitor(ctx); err != nil {
+					log.Errorf("条件单监控失败:%+v", err)
+				}
+			}
+		}()
+	})
+	return conditionalOrderService
+}
+
+// Create 创建条件单
+func (s *ConditionalOrderService) Create(ctx context.Context, order *model.ConditionalOrder) error {
+	switch order.Type {
+	case model.ConditionalOrderTypeBuyPrice, model.ConditionalOrderTypeBuyAtOpen:
+		return s.createBuy(ctx, order)
+	}
+	return s.createSell(ctx, order)
+}
+
+// createBuy 创建买入条件单:允许非交易时间创建,在生效交易日触发
+func (s *ConditionalOrderService) createBuy(ctx context.Context, order *model.ConditionalOrder) error {
+	contract, err := dao.ContractDaoInstance().GetContractByID(ctx, order.ContractID)
+	if err != nil || contract.UID != order.UID {
+		return serr.ErrBusiness("合约不存在")
+	}
+	if contract.Status != model.ContractStatusEnable {
+		return serr.ErrBusiness("无效合约")
+	}
+	if order.Amount <= 0 || order.Amount%100 != 0 {
+		return serr.ErrBusiness("委托股数应为100整倍数")
+	}
+	if order.Type == model.ConditionalOrderTypeBuyPrice && order.TriggerPrice < 0.01 {
+		return serr.ErrBusiness("请输入条件买入价格")
+	}
+	stock, err := StockDataServiceInstance().GetStockDataByCode(ctx, order.StockCode)
+	if err != nil {
+		return err
+	}
+
+	// 生效交易日:条件买入为当日(收盘前)或下一交易日,开盘买入为当日(开盘前)或下一交易日
+	now := time.Now()
+	open := CalendarServiceInstance().IsTradeDate(ctx) && now.Hour()*100+now.Minute() < 930
+	if order.Type == model.ConditionalOrderTypeBuyPrice {
+		open = CalendarServiceInstance().IsEntrustTime(ctx)
+	}
+	order.TradeDate = timeconv.TimeToInt32(now)
+	if !open {
+		order.TradeDate = CalendarServiceInstance().NextTradeDate(ctx, now)
+	}
+	if order.TradeDate == 0 {
+		return serr.ErrBusiness("获取交易日失败")
+	}
+
+	order.StockName = stock.Name
+	order.EntrustBS = model.EntrustBsTypeBuy
+	order.PositionID = 0
+	order.TrailPct = 0
+	order.Status = model.ConditionalOrderStatusActive
+	return dao.ConditionalOrderDaoInstance().Create(ctx, order)
+}
+
+// createSell 创建卖出条件单:止损、止盈、跟踪止损
+func (s *ConditionalOrderService) createSell(ctx context.Context, order *model.ConditionalOrder) error {
+	position, err := dao.PositionDaoInstance().GetPositionByID(ctx, order.PositionID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return serr.ErrBusiness("持仓不存在")
+		}
+		return err
+	}
+	if position.ContractID != order.ContractID || position.UID != order.UID {
+		return serr.ErrBusiness("持仓不存在")
+	}
+	if order.Amount < 0 || order.Amount > position.Amount {
+		return serr.ErrBusiness("卖出数量错误")
+	}
+	if order.Amount > 0 && order.Amount%100 != 0 && order.Amount != position.Amount {
+		return serr.ErrBusiness("卖出数量应为100整倍数")
+	}
+
+	qts, err := quote.QtServiceInstance().GetQuoteByTencent([]string{position.StockCode})
+	if err != nil {
+		return err
+	}
+	qt, ok := qts[position.StockCode]
+	if !ok || qt.CurrentPrice < 0.01 {
+		return serr.ErrBusiness("获取行情失败")
+	}
+
+	switch order.Type {
+	case model.ConditionalOrderTypeStopLoss:
+		if order.TriggerPrice <= 0 || order.TriggerPrice >= qt.CurrentPrice {
+			return serr.ErrBusiness("止损价必须低于现价")
+		}
+		order.TrailPct = 0
+	case model.ConditionalOrderTypeTakeProfit:
+		if order.TriggerPrice <= qt.CurrentPrice {
+			return serr.ErrBusiness("止盈价必须高于现价")
+		}
+		order.TrailPct = 0
+	case model.ConditionalOrderTypeTrailingStop:
+		if order.TrailPct <= 0 || order.TrailPct >= 1 {
+			return serr.ErrBusiness("回撤比例错误")
+		}
+		order.TriggerPrice = 0
+		order.HighPrice = qt.CurrentPrice
+	default:
+		return serr.ErrBusiness("条件单类型错误")
+	}
+
+	order.StockCode = position.StockCode
+	order.StockName = position.StockName
+	order.EntrustBS = model.EntrustBsTypeSell
+	order.TradeDate = 0
+	order.Status = model.ConditionalOrderStatusActive
+	return dao.ConditionalOrderDaoInstance().Create(ctx, order)
+}
+
+// List 查询合约条件单
+func (s *ConditionalOrderService) List(ctx context.Context, contractID int64) ([]*model.ConditionalOrderItem, error) {
+	list, err := dao.ConditionalOrderDaoInstance().GetByContractID(ctx, contractID)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*model.ConditionalOrderItem, 0)
+	for _, it := range list {
+		result = append(result, model.ConvertConditionalOrderItem(it))
+	}
+	return result, nil
+}
+
+// Cancel 取消条件单
+func (s *ConditionalOrderService) Cancel(ctx context.Context, uid, id int64) error {
+	order, err := dao.ConditionalOrderDaoInstance().GetByID(ctx, id)
+	if err != nil || order.UID != uid {
+		return serr.ErrBusiness("条件单不存在")
+	}
+	ok, err := dao.ConditionalOrderDaoInstance().UpdateStatus(ctx, id, model.ConditionalOrderStatusCancel, "用户取消")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return serr.ErrBusiness("条件单已" + model.ConditionalOrderStatusMap[order.Status])
+	}
+	return nil
+}
+
+// monitor 监控条件单:交易时间内检查触发条件,收盘后处理过期条件单
+func (s *ConditionalOrderService) monitor(ctx context.Context) error {
+	tradeTime := CalendarServiceInstance().IsTradeTime(ctx)
+	closed := CalendarServiceInstance().IsTradeDate(ctx) && !CalendarServiceInstance().IsEntrustTime(ctx)
+	if !tradeTime && !closed {
+		return nil
+	}
+	list, err := dao.ConditionalOrderDaoInstance().GetActive(ctx)
+	if err != nil {
+		return err
+	}
+
+	today := timeconv.TimeToInt32(time.Now())
+	orders := make([]*model.ConditionalOrder, 0)
+	codes := make([]string, 0)
+	for _, it := range list {
+		// 生效交易日已收盘未触发则过期
+		if it.TradeDate > 0 && (it.TradeDate < today || (it.TradeDate == today && closed)) {
+			s.expire(ctx, it)
+			continue
+		}
+		if !tradeTime || it.TradeDate > today {
+			continue
+		}
+		orders = append(orders, it)
+		codes = append(codes, it.StockCode)
+	}
+	if len(orders) == 0 {
+		return nil
+	}
+	qts, err := quote.QtServiceInstance().GetQuoteByTencent(codes)
+	if err != nil {
+		return err
+	}
+	for _, order := range orders {
+		qt, ok := qts[order.StockCode]
+		if !ok || qt.CurrentPrice < 0.01 {
+			continue
+		}
+		// 跟踪止损:记录监控期间最高价
+		if order.Type == model.ConditionalOrderTypeTrailingStop && qt.CurrentPrice > order.HighPrice {
+			order.HighPrice = qt.CurrentPrice
+			if err := dao.ConditionalOrderDaoInstance().UpdateHighPrice(ctx, order.ID, order.HighPrice); err != nil {
+				log.Errorf("UpdateHighPrice err:%+v", err)
+			}
+			continue
+		}
+		if !order.IsTriggered(qt.CurrentPrice) {
+			continue
+		}
+		trigger := s.triggerSell
+		if order.EntrustBS == model.EntrustBsTypeBuy {
+			trigger = s.triggerBuy
+		}
+		if err := trigger(ctx, order, qt); err != nil {
+			log.Errorf("条件单触发失败,条件单编号:%d err:%+v", order.ID, err)
+		}
+	}
+	return nil
+}
+
+// expire 条件单过期
+func (s *ConditionalOrderService) expire(ctx context.Context, order *model.ConditionalOrder) {
+	ok, err := dao.ConditionalOrderDaoInstance().UpdateStatus(ctx, order.ID, model.ConditionalOrderStatusExpired, "生效交易日未触发")
+	if err != nil || !ok {
+		return
+	}
+	s.notify(ctx, order, fmt.Sprintf("%s条件单已过期:生效交易日%d未触发", model.ConditionalOrderTypeMap[order.Type], order.TradeDate))
+}
+
+// triggerBuy 触发买入条件单:条件买入以触发价限价买入,开盘买入以市价买入;资金和风控在买入委托时重新校验
+func (s *ConditionalOrderService) triggerBuy(ctx context.Context, order *model.ConditionalOrder, qt *model.TencentQuote) error {
+	// 抢占条件单,防止重复触发
+	ok, err := dao.ConditionalOrderDaoInstance().UpdateStatus(ctx, order.ID, model.ConditionalOrderStatusTriggered, "")
+	if err != nil || !ok {
+		return err
+	}
+	typ := model.ConditionalOrderTypeMap[order.Type]
+	log.Infof("条件单触发:%s,条件单编号:%d,现价:%0.2f,买入:%d股", typ, order.ID, qt.CurrentPrice, order.Amount)
+
+	p := &model.EntrustPackage{
+		UID:         order.UID,                       // 用户UID
+		ContractID:  order.ContractID,                // 合约ID
+		Code:        order.StockCode,                 // 股票代码
+		Price:       order.TriggerPrice,              // 股票价格
+		Amount:      order.Amount,                    // 股票数量
+		EntrustProp: model.EntrustPropTypeLimitPrice, // 委托类型:1限价 2市价
+		Mode:        model.ConditionalMode,           // 委托方式:条件单触发
+	}
+	if order.Type == model.ConditionalOrderTypeBuyAtOpen {
+		p.Price = qt.CurrentPrice
+		p.EntrustProp = model.EntrustPropTypeMarketPrice
+	}
+	err = s.checkUser(ctx, order.UID)
+	if err == nil {
+		err = TradeServiceInstance().Buy(ctx, p)
+	}
+	if err != nil {
+		remark := fmt.Sprintf("%s触发,现价%0.2f元,委托买入失败:%s", typ, qt.CurrentPrice, serr.Message(err))
+		if e := dao.ConditionalOrderDaoInstance().UpdateResult(ctx, order.ID, model.ConditionalOrderStatusFail, remark); e != nil {
+			log.Errorf("UpdateResult err:%+v", e)
+		}
+		s.notify(ctx, order, remark)
+		return err
+	}
+
+	remark := fmt.Sprintf("%s触发,现价%0.2f元,委托买入%d股", typ, qt.CurrentPrice, order.Amount)
+	if err := dao.ConditionalOrderDaoInstance().UpdateResult(ctx, order.ID, model.ConditionalOrderStatusTriggered, remark); err != nil {
+		log.Errorf("UpdateResult err:%+v", err)
+	}
+	s.notify(ctx, order, remark)
+	return nil
+}
+
+// checkUser 触发时检查用户是否允许交易
+func (s *ConditionalOrderService) checkUser(ctx context.Context, uid int64) error {
+	user, err := dao.UserDaoInstance().GetUserByUID(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if user.Status != model.UserStatusActive {
+		return serr.ErrBusiness("用户已被冻结")
+	}
+	return nil
+}
+
+// notify 条件单结果写入消息表
+func (s *ConditionalOrderService) notify(ctx context.Context, order *model.ConditionalOrder, content string) {
+	if err := dao.MsgDaoInstance().Create(ctx, &model.Msg{
+		UID:        order.UID,
+		Title:      "条件单",
+		Content:    fmt.Sprintf("合约[%d]:%s(%s)%s", order.ContractID, order.StockName, order.StockCode, content),
+		CreateTime: time.Now(),
+	}); err != nil {
+		log.Errorf("创建消息失败:%+v", err)
+	}
+}
+
+// triggerSell 触发卖出条件单:以市价委托卖出
+func (s *ConditionalOrderService) triggerSell(ctx context.Context, order *model.ConditionalOrder, qt *model.TencentQuote) error {
+	position, err := dao.PositionDaoInstance().GetPositionByID(ctx, order.PositionID)
+	if err == gorm.ErrRecordNotFound {
+		// 持仓已清仓
+		_, err := dao.ConditionalOrderDaoInstance().UpdateStatus(ctx, order.ID, model.ConditionalOrderStatusCancel, "持仓已清仓")
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	// 可卖股数为0(当日买入或已委托卖出)时继续监控
+	available := position.Amount - position.FreezeAmount
+	if available <= 0 {
+		return nil
+	}
+	amount := order.Amount
+	if amount == 0 || amount > available {
+		amount = available
+	}
+
+	// 抢占条件单,防止重复触发
+	ok, err := dao.ConditionalOrderDaoInstance().UpdateStatus(ctx, order.ID, model.ConditionalOrderStatusTriggered, "")
+	if err != nil || !ok {
+		return err
+	}
+	typ := model.ConditionalOrderTypeMap[order.Type]
+	log.Infof("条件单触发:%s,条件单编号:%d,现价:%0.2f,卖出:%d股", typ, order.ID, qt.CurrentPrice, amount)
+
+	if err := TradeServiceInstance().Sell(ctx, &model.EntrustPackage{
+		UID:         order.UID,                        // 用户UID
+		ContractID:  order.ContractID,                 // 合约ID
+		Code:        order.StockCode,                  // 股票代码
+		Price:       qt.CurrentPrice,                  // 股票价格
+		Amount:      amount,                           // 股票数量
+		EntrustProp: model.EntrustPropTypeMarketPrice, // 委托类型:1限价 2市价
+		Mode:        model.ConditionalMode,            // 委托方式:条件单触发
+	}); err != nil {
+		remark := fmt.Sprintf("%s触发,现价%0.2f元,委托卖出失败:%s", typ, qt.CurrentPrice, serr.Message(err))
+		if e := dao.ConditionalOrderDaoInstance().UpdateResult(ctx, order.ID, model.ConditionalOrderStatusFail, remark); e != nil {
+			log.Errorf("UpdateResult err:%+v", e)
+		}
+		s.notify(ctx, order, remark)
+		return err
+	}
+
+	remark := fmt.Sprintf("%s触发,现价%0.2f元,市价委托卖出%d股", typ, qt.CurrentPrice, amount)
+	if err := dao.ConditionalOrderDaoInstance().UpdateResult(ctx, order.ID, model.ConditionalOrderStatusTriggered, remark); err != nil {
+		log.Errorf("UpdateResult err:%+v", err)
+	}
+	s.notify(ctx, order, remark)
+	return nil
+}
@@ -5,10 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"sort"
-	"stock/api-gateway/dao"
-	"stock/api-gateway/db"
 	"stock/api-gateway/model"
-	"stock/api-gateway/quote"
 	"stock/api-gateway/serr"
 	"stock/api-gateway/util"
 	"stock/common/errgroup"
@@ -22,6 +19,10 @@ import (
 
 // ContractService 合约服务
 type ContractService struct {
+	core     *Core
+	ledger   *LedgerService
+	position *PositionService
+	trade    *TradeService
 }
 
 var (
@@ -32,14 +33,13 @@ var (
 // ContractServiceInstance ContractServiceInstance
 func ContractServiceInstance() *ContractService {
 	contractOnce.Do(func() {
-		contractService = &ContractService{}
+		contractService = defaultServices().Contract
 
 		ctx := context.Background()
-		cs := CalendarServiceInstance()
 		// 检测是否触达警戒线:触发警戒线,短信通知;一天仅通知一次
 		go func() {
 			for range time.Tick(5 * time.Second) {
-				if !cs.IsTradeTime(ctx) {
+				if !contractService.core.Calendar.IsTradeTime(ctx) {
 					continue
 				}
 				// 合约检测:检测是否触发警戒线、平仓线
@@ -48,52 +48,33 @@ func ContractServiceInstance() *ContractService {
 				}
 			}
 		}()
-
-		// 扣取合约利息
-		go func() {
-			for range time.Tick(5 * time.Second) {
-				// 3点15分开始收取利息费用
-				if time.Now().Hour() >= 15 && time.Now().Minute() >= 15 {
-					// 检查redis是否已经收取
-					key := fmt.Sprintf("manage_fee_cache_key_%v", timeconv.TimeToInt32(time.Now()))
-					if db.RedisClient().Get(ctx, key).Val() == "1" {
-						continue
-					}
-					if err := contractService.contractFee(ctx); err != nil {
-						log.Errorf("收取合约利息费用失败:%+v", err)
-						continue
-					}
-					// 设置redis,已经收取合约利息
-					if err := db.RedisClient().Set(ctx, key, "1", 12*time.Hour).Err(); err != nil {
-						log.Errorf("设置redis收取管理利息费失败,err:%+v", err)
-					}
-				}
-			}
-		}()
-
 	})
 	return contractService
 }
 
-// contractFee 合约费用
-func (s *ContractService) contractFee(ctx context.Context) error {
-	sys, err := dao.SysDaoInstance().GetSysParam(ctx)
+// ChargeInterest 收取合约利息(管理费):由定时任务每日15:15执行,date为收取日期,补跑时为错过的日期
+func (s *ContractService) ChargeInterest(ctx context.Context, date time.Time) error {
+	sys, err := s.core.Sys.GetSysParam(ctx)
 	if err != nil {
 		log.Errorf("GetSysParam err:%+v", err)
 		return err
 	}
-	contracts, err := dao.ContractDaoInstance().GetContracts(ctx)
+	contracts, err := s.core.Contract.GetContracts(ctx)
 	if err != nil {
 		log.Errorf("GetContracts err:%+v", err)
 		return err
 	}
-	ok := CalendarServiceInstance().IsTradeDate(ctx)
+	ok := s.core.Calendar.IsTradeDay(ctx, date)
 
 	for _, it := range contracts {
 		contract := it
 		if it.Status != model.ContractStatusEnable {
 			continue
 		}
+		// 补跑时收取日期之后开立的合约不收取
+		if timeconv.TimeToInt32(contract.OrderTime) > timeconv.TimeToInt32(date) {
+			continue
+		}
 		// 判断今天是否应该收取管理费
 		switch contract.Type {
 		case model.ContractTypeDay: // 按天合约,节假日是否收取留仓费
@@ -103,26 +84,26 @@ func (s *ContractService) contractFee(ctx context.Context) error {
 			}
 		case model.ContractTypeWeek:
 			// 当天开的按周合约,不收取费用
-			if timeconv.TimeToInt32(contract.OrderTime) == timeconv.TimeToInt32(time.Now()) {
+			if timeconv.TimeToInt32(contract.OrderTime) == timeconv.TimeToInt32(date) {
 				continue
 			}
 			// 合约是否到周期
-			if contract.OrderTime.Weekday() != time.Now().Weekday() {
+			if contract.OrderTime.Weekday() != date.Weekday() {
 				continue
 			}
 		case model.ContractTypeMonth:
 			// 当天开的按月合约,不收取费用
-			if timeconv.TimeToInt32(contract.OrderTime) == timeconv.TimeToInt32(time.Now()) {
+			if timeconv.TimeToInt32(contract.OrderTime) == timeconv.TimeToInt32(date) {
 				continue
 			}
 			isCharge := false
 			for index := 1; index <= 12; index++ {
 				// 下个月提前一天扣费
 				chargeDate := contract.OrderTime.AddDate(0, index, -1)
-				if timeconv.TimeToInt32(chargeDate) > timeconv.TimeToInt32(time.Now()) {
+				if timeconv.TimeToInt32(chargeDate) > timeconv.TimeToInt32(date) {
 					break
 				}
-				if timeconv.TimeToInt32(chargeDate) == timeconv.TimeToInt32(time.Now()) {
+				if timeconv.TimeToInt32(chargeDate) == timeconv.TimeToInt32(date) {
 					isCharge = true
 					break
 				}
@@ -133,28 +114,12 @@ func (s *ContractService) contractFee(ctx context.Context) error {
 		}
 
 		interest := model.Interest(contract, sys, contract.InitMoney)
-		it.Money -= interest
-		if err := dao.ContractDaoInstance().UpdateContract(ctx, contract); err != nil {
+		if err := s.chargeInterest(ctx, contract, interest); err != nil {
 			log.Errorf("收取合约[%v],金额:[%v]管理费失败:%+v", contract.ID, interest, err)
 			continue
 		}
-		if err := dao.ContractFeeDaoInstance().Create(ctx, &model.ContractFee{
-			UID:        contract.UID,
-			ContractID: contract.ID,
-			Code:       "",
-			Name:       "",
-			Amount:     0,
-			OrderTime:  time.Now(),
-			Direction:  model.ContractFeeDirectionPay,
-			Money:      interest,
-			Detail:     fmt.Sprintf("扣取合约资金利息费用:%0.2f", interest),
-			Type:       model.ContractFeeTypeInterest,
-		}); err != nil {
-			log.Errorf("记录:收取合约[%v],金额:[%v],管理费失败:%+v", contract.ID, interest, err)
-			continue
-		}
 		// 填写消息表
-		if err := dao.MsgDaoInstance().Create(ctx, &model.Msg{
+		if err := s.core.Msg.Create(ctx, &model.Msg{
 			UID:        contract.UID,
 			Title:      "递延费",
 			Content:    fmt.Sprintf("您合约[%d]申请借款资金:%0.2f元,扣取递延利息费用:%0.2f元,请留意您的资金变动。", contract.ID, contract.InitMoney*float64(contract.Lever), interest),
@@ -171,9 +136,44 @@ func (s *ContractService) contractFee(ctx context.Context) error {
 	return nil
 }
 
+// chargeInterest 单个合约扣取利息:更新保证金、记录合约费用、记账在同一事务内完成
+func (s *ContractService) chargeInterest(ctx context.Context, contract *model.Contract, interest float64) error {
+	contract.Money -= interest
+
+	tx := s.core.Tx.Begin(ctx)
+	defer tx.Rollback()
+	if err := s.core.Contract.UpdateWithTx(tx, contract); err != nil {
+		return err
+	}
+	if err := s.core.ContractFee.CreateWithTx(tx, &model.ContractFee{
+		UID:        contract.UID,
+		ContractID: contract.ID,
+		Code:       "",
+		Name:       "",
+		Amount:     0,
+		OrderTime:  time.Now(),
+		Direction:  model.ContractFeeDirectionPay,
+		Money:      interest,
+		Detail:     fmt.Sprintf("扣取合约资金利息费用:%0.2f", interest),
+		Type:       model.ContractFeeTypeInterest,
+	}); err != nil {
+		return err
+	}
+	journal := model.NewJournal(model.LedgerBizContractInterest, contract.ID, "扣取合约资金利息").
+		Move(model.MarginAccount(contract.ID), model.InterestIncomeAccount, interest)
+	if err := s.ledger.PostWithTx(tx, journal); err != nil {
+		return err
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Errorf("事务提交失败:%+v", err)
+		return err
+	}
+	return nil
+}
+
 // GetWithdrawStatus 查询是合约是否可以撤单
 func (s *ContractService) GetWithdrawStatus(ctx context.Context, contractID int64) model.ContractWithdrawStatus {
-	if db.Get(ctx, s.withdrawOrderCacheKey(contractID)).Val() == "1" {
+	if s.core.Cache.Get(ctx, s.withdrawOrderCacheKey(contractID)).Val() == "1" {
 		return model.ContractStatusDisabled // 不可撤单
 	}
 	return model.ContractWithdrawStatusEnable // 可撤单
@@ -191,7 +191,7 @@ func (s *ContractService) closeContractCacheKey(contractID int64) string {
 
 // checkContact 合约检查:检查是否触发警戒线,平仓线
 func (s *ContractService) checkContact(ctx context.Context) error {
-	contracts, err := dao.ContractDaoInstance().GetContracts(ctx)
+	contracts, err := s.core.Contract.GetContracts(ctx)
 	if err != nil {
 		log.Errorf("GetContracts err:%+v", err)
 		return err
@@ -212,18 +212,18 @@ func (s *ContractService) checkContact(ctx context.Context) error {
 		case model.ContractRiskLevelHealth:
 			{
 				// 正常合约,检查redis是否设置了禁止撤单标志,是则删除掉
-				if db.Get(ctx, s.withdrawOrderCacheKey(contract.ID)).Val() == "1" {
+				if s.core.Cache.Get(ctx, s.withdrawOrderCacheKey(contract.ID)).Val() == "1" {
 					log.Infof("删除撤单标识,合约编号:%+v", contract.ID)
-					if err := db.Delete(ctx, s.withdrawOrderCacheKey(contract.ID)).Err(); err != nil {
+					if err := s.core.Cache.Del(ctx, s.withdrawOrderCacheKey(contract.ID)).Err(); err != nil {
 						log.Errorf("删除禁止撤单标志失败:%+v", err)
 						return err
 					}
 					log.Infof("删除撤单标识完毕!!!!!!合约编号:%+v", contract.ID)
 				}
 				// 正常合约,检查是否设置了爆仓短信提醒标志,是则删除掉
-				if db.Get(ctx, s.closeContractCacheKey(contract.ID)).Val() == "1" {
+				if s.core.Cache.Get(ctx, s.closeContractCacheKey(contract.ID)).Val() == "1" {
 					log.Infof("删除爆仓短信标志,合约编号:%+v", contract.ID)
-					if err := db.Delete(ctx, s.closeContractCacheKey(contract.ID)).Err(); err != nil {
+					if err := s.core.Cache.Del(ctx, s.closeContractCacheKey(contract.ID)).Err(); err != nil {
 						log.Errorf("删除禁止撤单标志失败:%+v", err)
 						return err
 					}
@@ -233,10 +233,10 @@ func (s *ContractService) checkContact(ctx context.Context) error {
 		case model.ContractRiskLevelClose: // 触发平仓线:是否有股票,有股票则全部卖出;
 			{
 				// 检查合约是否已经设置了今日禁止撤单标志.
-				if db.Get(ctx, s.withdrawOrderCacheKey(contract.ID)).Val() == "1" {
+				if s.core.Cache.Get(ctx, s.withdrawOrderCacheKey(contract.ID)).Val() == "1" {
 					continue
 				}
-				positions, err := dao.PositionDaoInstance().GetPositionByContractID(ctx, contract.ID)
+				positions, err := s.core.Position.GetPositionByContractID(ctx, contract.ID)
 				if err != nil {
 					log.Errorf("GetPositionByContractID err:%+v", err)
 					return nil
@@ -247,15 +247,15 @@ func (s *ContractService) checkContact(ctx context.Context) error {
 				}
 				log.Infof("合约爆仓:%+v", contract.ID)
 				// 撤销所有的正在委托订单
-				entrusts, err := dao.EntrustDaoInstance().GetEntrustByContractID(ctx, contract.ID)
+				entrusts, err := s.core.Entrust.GetEntrustByContractID(ctx, contract.ID)
 				if err != nil {
 					log.Errorf("GetEntrustByContractID err:%+v", err)
 					return err
 				}
 				for _, it := range entrusts {
 					// 今日未成交订单,发起委托撤单
-					if timeconv.TimeToInt32(it.OrderTime) == timeconv.TimeToInt32(time.Now()) && it.Status == model.EntrustStatusTypeUnDeal {
-						if err := TradeServiceInstance().Withdraw(ctx, it.ID); err != nil {
+					if timeconv.TimeToInt32(it.OrderTime) == timeconv.TimeToInt32(time.Now()) && (it.Status == model.EntrustStatusTypeUnDeal || it.Status == model.EntrustStatusTypePartDeal) {
+						if err := s.trade.Withdraw(ctx, it.ID); err != nil {
 							log.Errorf("爆仓撤单失败,Withdraw err:%+v", err)
 							return err
 						}
@@ -269,7 +269,7 @@ func (s *ContractService) checkContact(ctx context.Context) error {
 					if it.Amount-it.FreezeAmount <= 0 {
 						continue
 					}
-					if err := TradeServiceInstance().Sell(ctx, &model.EntrustPackage{
+					if err := s.trade.Sell(ctx, &model.EntrustPackage{
 						UID:         it.UID,
 						ContractID:  it.ContractID,
 						Code:        it.StockCode,
@@ -282,26 +282,26 @@ func (s *ContractService) checkContact(ctx context.Context) error {
 						return err
 					}
 				}
-				user, err := dao.UserDaoInstance().GetUserByUID(ctx, contract.UID)
+				user, err := s.core.User.GetUserByUID(ctx, contract.UID)
 				if err != nil {
 					log.Errorf("GetUserByUID err:%+v", err)
 					return err
 				}
 				// 设置禁止撤单标志
-				if _, err := db.Set(ctx, s.withdrawOrderCacheKey(contract.ID), "1", 7*time.Hour).Result(); err != nil {
+				if _, err := s.core.Cache.Set(ctx, s.withdrawOrderCacheKey(contract.ID), "1", 7*time.Hour).Result(); err != nil {
 					log.Errorf("设置禁止撤单标志失败:%+v", err)
 					return err
 				}
 
 				// 是否已经短信提醒
-				if db.Get(ctx, s.closeContractCacheKey(contract.ID)).Val() == "1" {
+				if s.core.Cache.Get(ctx, s.closeContractCacheKey(contract.ID)).Val() == "1" {
 					continue
 				}
-				if _, err := db.Set(ctx, s.closeContractCacheKey(contract.ID), "1", -1).Result(); err != nil {
+				if _, err := s.core.Cache.Set(ctx, s.closeContractCacheKey(contract.ID), "1", -1).Result(); err != nil {
 					log.Errorf("爆仓设置短信redis提醒标志失败,err:%+v", err)
 					return err
 				}
-				if err := SmsServiceInstance().SendSms(ctx, fmt.Sprintf("尊敬的客户,由于您的%s:[%d]保证金已触达平仓水平,合约持仓股票将按市况执行平仓处理，请知悉。", contract.FullName(), contract.ID), user.UserName); err != nil {
+				if err := s.core.Sms.SendSms(ctx, fmt.Sprintf("尊敬的客户,由于您的%s:[%d]保证金已触达平仓水平,合约持仓股票将按市况执行平仓处理，请知悉。", contract.FullName(), contract.ID), user.UserName); err != nil {
 					log.Errorf("SendSms err:%+v", err)
 				}
 				log.Infof("合约爆仓完毕，合约编号:%+v", contract.ID)
@@ -310,27 +310,27 @@ func (s *ContractService) checkContact(ctx context.Context) error {
 		case model.ContractRiskLevelWarn:
 			{
 				// 触发警戒线: 发送短信提醒,仅限交易日发送
-				if CalendarServiceInstance().IsEntrustTime(ctx) {
+				if s.core.Calendar.IsEntrustTime(ctx) {
 
 					// 检测今天是否已经发送短信;
 					cacheKey := fmt.Sprintf("sms_to_warn_contract_id_%d", contract.ID)
-					if db.Get(ctx, cacheKey).Val() == "1" {
+					if s.core.Cache.Get(ctx, cacheKey).Val() == "1" {
 						continue
 					}
 					log.Infof("合约触发警戒线，合约编号:%+v", contract.ID)
 
 					content := fmt.Sprintf("尊敬的客户,您的%s[%d]保证金已触达警戒水平,请知悉。", contract.FullName(), contract.ID)
-					user, err := dao.UserDaoInstance().GetUserByUID(ctx, contract.UID)
+					user, err := s.core.User.GetUserByUID(ctx, contract.UID)
 					if err != nil {
 						log.Errorf("GetUserByUID err:%+v", err)
 						return err
 					}
-					if err := SmsServiceInstance().SendSms(ctx, content, user.UserName); err != nil {
+					if err := s.core.Sms.SendSms(ctx, content, user.UserName); err != nil {
 						log.Errorf("SendSms err:%+v", err)
 					}
 
 					// 设置redis,为已发送短信
-					if err := db.Set(ctx, cacheKey, "1", 8*time.Hour).Err(); err != nil {
+					if err := s.core.Cache.Set(ctx, cacheKey, "1", 8*time.Hour).Err(); err != nil {
 						log.Errorf("set redis err:%+v", err)
 					}
 
@@ -345,7 +345,7 @@ func (s *ContractService) checkContact(ctx context.Context) error {
 
 // List 查询合约
 func (s *ContractService) List(ctx context.Context, uid int64) ([]*model.ValidContract, error) {
-	list, err := dao.ContractDaoInstance().GetContractsByUID(ctx, uid)
+	list, err := s.core.Contract.GetContractsByUID(ctx, uid)
 	if err != nil {
 		return nil, serr.ErrBusiness("查询合约失败")
 	}
@@ -358,7 +358,7 @@ func (s *ContractService) List(ctx context.Context, uid int64) ([]*model.ValidCo
 	// 查询系统参数
 	var sys *model.SysParam
 	eg.Go(func() error {
-		ret, err := dao.SysDaoInstance().GetSysParam(ctx)
+		ret, err := s.core.Sys.GetSysParam(ctx)
 		if err != nil {
 			return err
 		}
@@ -368,7 +368,7 @@ func (s *ContractService) List(ctx context.Context, uid int64) ([]*model.ValidCo
 	// 查询用户
 	var user *model.User
 	eg.Go(func() error {
-		ret, err := dao.UserDaoInstance().GetUserByUID(ctx, uid)
+		ret, err := s.core.User.GetUserByUID(ctx, uid)
 		if err != nil {
 			return err
 		}
@@ -386,7 +386,7 @@ func (s *ContractService) List(ctx context.Context, uid int64) ([]*model.ValidCo
 		if contract.Status != model.ContractStatusEnable {
 			continue
 		}
-		positions, err := PositionServiceInstance().GetPositionByContractID(ctx, contract.ID)
+		positions, err := s.position.GetPositionByContractID(ctx, contract.ID)
 		if err != nil {
 			return nil, err
 		}
@@ -427,7 +427,7 @@ func (s *ContractService) List(ctx context.Context, uid int64) ([]*model.ValidCo
 
 // ApplyInit 合约申请初始化
 func (s *ContractService) ApplyInit(ctx context.Context, uid int64) (*model.ContractConf, error) {
-	sys, err := dao.SysDaoInstance().GetSysParam(ctx)
+	sys, err := s.core.Sys.GetSysParam(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -458,7 +458,7 @@ func (s *ContractService) ApplyInit(ctx context.Context, uid int64) (*model.Cont
 		})
 	}
 	// 合约可用资金
-	user, err := dao.UserDaoInstance().GetUserByUID(ctx, uid)
+	user, err := s.core.User.GetUserByUID(ctx, uid)
 	if err != nil {
 		return nil, err
 	}
@@ -471,11 +471,14 @@ func (s *ContractService) ApplyInit(ctx context.Context, uid int64) (*model.Cont
 
 // ContractApply 创建合约
 func (s *ContractService) ContractApply(ctx context.Context, uid int64, money float64, contractType, contractLever int64) (*model.ContractApply, error) {
-	user, err := dao.UserDaoInstance().GetUserByUID(ctx, uid)
+	user, err := s.core.User.GetUserByUID(ctx, uid)
 	if err != nil {
 		return nil, err
 	}
-	contract, err := dao.ContractDaoInstance().CreateContract(ctx, &model.Contract{
+	if !user.Verified() {
+		return nil, serr.ErrBusiness("请先完成实名认证")
+	}
+	contract, err := s.core.Contract.CreateContract(ctx, &model.Contract{
 		UID:       user.ID,                              // 用户ID
 		InitMoney: money,                                // 原始保证金
 		Money:     money,                                // 现保证金
@@ -490,7 +493,7 @@ func (s *ContractService) ContractApply(ctx context.Context, uid int64, money fl
 		log.Errorf("创建合约失败,err:%+v", err)
 		return nil, serr.ErrBusiness("创建合约失败")
 	}
-	sys, err := dao.SysDaoInstance().GetSysParam(ctx)
+	sys, err := s.core.Sys.GetSysParam(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -537,14 +540,14 @@ func (s *ContractService) ContractFee(contract *model.Contract, sys *model.SysPa
 
 // UpdateValMoneyByID 刷新合约资金
 func (s *ContractService) UpdateValMoneyByID(ctx context.Context, contractID int64) error {
-	contract, err := dao.ContractDaoInstance().GetContractByID(ctx, contractID)
+	contract, err := s.core.Contract.GetContractByID(ctx, contractID)
 	if err != nil {
 		return serr.ErrBusiness("合约不存在")
 	}
 	if contract.Status != model.ContractStatusEnable {
 		return serr.ErrBusiness("合约非有效状态")
 	}
-	positions, err := dao.PositionDaoInstance().GetPositionByContractID(ctx, contract.ID)
+	positions, err := s.core.Position.GetPositionByContractID(ctx, contract.ID)
 	if err != nil {
 		return err
 	}
@@ -552,7 +555,7 @@ func (s *ContractService) UpdateValMoneyByID(ctx context.Context, contractID int
 	positionAsset := model.CalculatePositionAsset(positions)
 
 	// 2.查询委托
-	entrusts, err := dao.EntrustDaoInstance().GetTodayEntrust(ctx, contract.ID)
+	entrusts, err := s.core.Entrust.GetTodayEntrust(ctx, contract.ID)
 	if err != nil {
 		return err
 	}
@@ -561,17 +564,28 @@ func (s *ContractService) UpdateValMoneyByID(ctx context.Context, contractID int
 		if it.EntrustBS != model.EntrustBsTypeBuy {
 			continue
 		}
-		if it.Status == model.EntrustStatusTypeUnDeal || it.Status == model.EntrustStatusTypeReported {
-			entrustAsset += it.Price * float64(it.Amount)
+		// 部分成交的委托,已成交部分计入持仓市值,未成交部分按委托价格计算
+		if it.Status == model.EntrustStatusTypeUnDeal || it.Status == model.EntrustStatusTypeReported || it.Status == model.EntrustStatusTypePartDeal {
+			entrustAsset += it.Price * float64(it.Amount-it.DealAmount)
 		}
 	}
 
-	// 3.计算可用资金 = (InitMoney(现保证金)*lever(倍数) + 保证金) - 持仓市值 - 委托市值
-	valMoney := (contract.InitMoney*float64(contract.Lever) + contract.Money) - positionAsset - entrustAsset
+	// 3.国债逆回购占用资金
+	repos, err := s.core.ReverseRepo.GetActiveByContractID(ctx, contract.ID)
+	if err != nil {
+		return err
+	}
+	repoAsset := 0.00
+	for _, it := range repos {
+		repoAsset += it.Frozen()
+	}
+
+	// 4.计算可用资金 = (InitMoney(现保证金)*lever(倍数) + 保证金) - 持仓市值 - 委托市值 - 逆回购占用资金
+	valMoney := (contract.InitMoney*float64(contract.Lever) + contract.Money) - positionAsset - entrustAsset - repoAsset
 	if valMoney < 0 {
 		valMoney = 0
 	}
-	if err := dao.ContractDaoInstance().UpdateContractValMoney(ctx, valMoney, contract.ID); err != nil {
+	if err := s.core.Contract.UpdateContractValMoney(ctx, valMoney, contract.ID); err != nil {
 		log.Errorf("刷新可用资金失败:%+v", err)
 		return err
 	}
@@ -581,7 +595,7 @@ func (s *ContractService) UpdateValMoneyByID(ctx context.Context, contractID int
 
 // Create 确认合约
 func (s *ContractService) Create(ctx context.Context, uid, contractID int64) error {
-	sys, err := dao.SysDaoInstance().GetSysParam(ctx)
+	sys, err := s.core.Sys.GetSysParam(ctx)
 	if err != nil {
 		return err
 	}
@@ -589,7 +603,7 @@ func (s *ContractService) Create(ctx context.Context, uid, contractID int64) err
 	wg := errgroup.GroupWithCount(2)
 	var contract *model.Contract
 	wg.Go(func() error {
-		ret, err := dao.ContractDaoInstance().GetContractByID(ctx, contractID)
+		ret, err := s.core.Contract.GetContractByID(ctx, contractID)
 		if err != nil {
 			log.Errorf("合约不存在 err:%+v", err)
 			return serr.ErrBusiness("合约不存在")
@@ -599,7 +613,7 @@ func (s *ContractService) Create(ctx context.Context, uid, contractID int64) err
 	})
 	var user *model.User
 	wg.Go(func() error {
-		ret, err := dao.UserDaoInstance().GetUserByUID(ctx, uid)
+		ret, err := s.core.User.GetUserByUID(ctx, uid)
 		if err != nil {
 			return serr.ErrBusiness("用户不存在")
 		}
@@ -609,6 +623,9 @@ func (s *ContractService) Create(ctx context.Context, uid, contractID int64) err
 	if err := wg.Wait(); err != nil {
 		return err
 	}
+	if !user.Verified() {
+		return serr.ErrBusiness("请先完成实名认证")
+	}
 
 	// 检查合约状态
 	if contract.Status != model.ContractStatusApply {
@@ -626,17 +643,20 @@ func (s *ContractService) Create(ctx context.Context, uid, contractID int64) err
 	}
 	user.Money = user.Money - payMoney   // 扣除用户资金
 	user.CurrentContractID = contract.ID // 设置当前合约为选中合约
-	eg := errgroup.GroupWithCount(3)
+
+	tx := s.core.Tx.Begin(ctx)
+	defer tx.Rollback()
+	eg := errgroup.GroupWithCount(4)
 	eg.Go(func() error {
-		if err := dao.UserDaoInstance().CreateUser(ctx, user); err != nil {
-			log.Errorf("CreateUser err:%+v", err)
+		if err := s.core.User.UpdateUserWithTx(tx, user); err != nil {
+			log.Errorf("UpdateUserWithTx err:%+v", err)
 			return err
 		}
 		return nil
 	})
 	eg.Go(func() error {
 		// 扣取合约费用
-		if err := dao.ContractFeeDaoInstance().Create(ctx, &model.ContractFee{
+		if err := s.core.ContractFee.CreateWithTx(tx, &model.ContractFee{
 			UID:        contract.UID,
 			ContractID: contractID,
 			Code:       "",
@@ -656,17 +676,28 @@ func (s *ContractService) Create(ctx context.Context, uid, contractID int64) err
 	eg.Go(func() error {
 		// 设置合约状态
 		contract.Status = model.ContractStatusEnable
-		if err := dao.ContractDaoInstance().UpdateContract(ctx, contract); err != nil {
-			log.Errorf("UpdateContract err:%+v", err)
+		if err := s.core.Contract.UpdateWithTx(tx, contract); err != nil {
+			log.Errorf("UpdateWithTx err:%+v", err)
 			return err
 		}
 		return nil
 	})
+	eg.Go(func() error {
+		// 记账:保证金转入合约,利息转入平台
+		journal := model.NewJournal(model.LedgerBizContractCreate, contract.ID, fmt.Sprintf("%s申请成功", contract.FullName())).
+			Move(model.WalletAccount(user.ID), model.MarginAccount(contract.ID), contract.Money).
+			Move(model.WalletAccount(user.ID), model.InterestIncomeAccount, payMoney-contract.Money)
+		return s.ledger.PostWithTx(tx, journal)
+	})
 	if err := eg.Wait(); err != nil {
 		return err
 	}
+	if err := tx.Commit().Error; err != nil {
+		log.Errorf("事务提交失败:%+v", err)
+		return err
+	}
 
-	if err := dao.TransferDaoInstance().Create(ctx, &model.Transfer{
+	if err := s.core.Transfer.Create(ctx, &model.Transfer{
 		UID:       contract.UID,
 		OrderTime: time.Now(),
 		Money:     contract.Money,
@@ -686,24 +717,24 @@ func (s *ContractService) Create(ctx context.Context, uid, contractID int64) err
 
 // Detail 合约详情
 func (s *ContractService) Detail(ctx context.Context, contractID int64) (*model.ContractDetail, error) {
-	contract, err := dao.ContractDaoInstance().GetContractByID(ctx, contractID)
+	contract, err := s.core.Contract.GetContractByID(ctx, contractID)
 	if err != nil {
 		return nil, serr.ErrBusiness("合约不存在")
 	}
 	// 获取持仓盈亏比例
-	positions, err := PositionServiceInstance().GetPositionByContractID(ctx, contractID)
+	positions, err := s.position.GetPositionByContractID(ctx, contractID)
 	if err != nil {
 		log.Errorf("获取持仓盈亏失败:%+v", err)
 		return nil, serr.ErrBusiness("查询持仓失败")
 	}
 	profit := model.CalculatePositionProfit(positions)
-	sys, err := dao.SysDaoInstance().GetSysParam(ctx)
+	sys, err := s.core.Sys.GetSysParam(ctx)
 	if err != nil {
 		return nil, err
 	}
 	// 提盈金额
 	getProfitMoney := 0.00
-	list, err := dao.PositionDaoInstance().GetPositionByContractID(ctx, contractID)
+	list, err := s.core.Position.GetPositionByContractID(ctx, contractID)
 	if err != nil {
 		return nil, err
 	}
@@ -737,11 +768,11 @@ func (s *ContractService) Detail(ctx context.Context, contractID int64) (*model.
 
 // GetContractRiskLevel 合约风险登记
 func (s *ContractService) GetContractRiskLevel(ctx context.Context, contract *model.Contract) (model.ContractRiskLevel, error) {
-	sys, err := dao.SysDaoInstance().GetSysParam(ctx)
+	sys, err := s.core.Sys.GetSysParam(ctx)
 	if err != nil {
 		return 0, err
 	}
-	positions, err := PositionServiceInstance().GetPositionByContractID(ctx, contract.ID)
+	positions, err := s.position.GetPositionByContractID(ctx, contract.ID)
 	if err != nil {
 		return 0, err
 	}
@@ -779,7 +810,7 @@ func (s *ContractService) Settlement(ctx context.Context, contractID int64) erro
 	wg := errgroup.GroupWithCount(2)
 	var contract *model.Contract
 	wg.Go(func() error {
-		ret, err := dao.ContractDaoInstance().GetContractByID(ctx, contractID)
+		ret, err := s.core.Contract.GetContractByID(ctx, contractID)
 		if err != nil {
 			log.Errorf("GetContractByID err:%+v", err)
 			return serr.ErrBusiness("合约不存在")
@@ -790,7 +821,7 @@ func (s *ContractService) Settlement(ctx context.Context, contractID int64) erro
 
 	var positions []*model.Position
 	wg.Go(func() error {
-		ret, err := PositionServiceInstance().GetPositionByContractID(ctx, contractID)
+		ret, err := s.position.GetPositionByContractID(ctx, contractID)
 		if err != nil {
 			log.Errorf("查询持仓失败:%+v", err)
 			return serr.ErrBusiness("合约结算失败")
@@ -811,24 +842,31 @@ func (s *ContractService) Settlement(ctx context.Context, contractID int64) erro
 	if len(positions) > 0 {
 		return serr.ErrBusiness("合约结算失败:未清仓股票")
 	}
+	repos, err := s.core.ReverseRepo.GetActiveByContractID(ctx, contractID)
+	if err != nil {
+		return serr.ErrBusiness("合约结算失败")
+	}
+	if len(repos) > 0 {
+		return serr.ErrBusiness("合约结算失败:存在未到期的国债逆回购")
+	}
 
-	user, err := dao.UserDaoInstance().GetUserByUID(ctx, contract.UID)
+	user, err := s.core.User.GetUserByUID(ctx, contract.UID)
 	if err != nil {
 		log.Errorf("GetUserByUID err:%+v", err)
 		return serr.ErrBusiness("用户不存在")
 	}
 
 	// 设置合约状态 & 转移合约资金到钱包
-	tx := db.StockDB().WithContext(ctx).Begin()
+	tx := s.core.Tx.Begin(ctx)
 	defer tx.Rollback()
 	contract.Status = model.ContractStatusDisabled
 	contract.CloseTime = time.Now()
 	contract.CloseExplain = "主动关闭"
 
-	eg := errgroup.GroupWithCount(3)
+	eg := errgroup.GroupWithCount(4)
 	// 设置合约状态
 	eg.Go(func() error {
-		if err := dao.ContractDaoInstance().UpdateWithTx(tx, contract); err != nil {
+		if err := s.core.Contract.UpdateWithTx(tx, contract); err != nil {
 			log.Errorf("合约设置状态失败:%+v", err)
 			return serr.ErrBusiness("合约结算失败")
 		}
@@ -837,15 +875,21 @@ func (s *ContractService) Settlement(ctx context.Context, contractID int64) erro
 	// 更新用户资金
 	eg.Go(func() error {
 		user.Money += contract.Money
-		if err := dao.UserDaoInstance().UpdateUserWithTx(tx, user); err != nil {
+		if err := s.core.User.UpdateUserWithTx(tx, user); err != nil {
 			log.Errorf("UpdateUserWithTx err:%+v", err)
 			return serr.ErrBusiness("合约结算失败")
 		}
 		return nil
 	})
+	// 记账:保证金转入钱包
+	eg.Go(func() error {
+		journal := model.NewJournal(model.LedgerBizSettlement, contract.ID, "合约结算").
+			Move(model.MarginAccount(contract.ID), model.WalletAccount(user.ID), contract.Money)
+		return s.ledger.PostWithTx(tx, journal)
+	})
 	// 填写合约结算费用
 	eg.Go(func() error {
-		if err := dao.ContractFeeDaoInstance().CreateWithTx(tx, &model.ContractFee{
+		if err := s.core.ContractFee.CreateWithTx(tx, &model.ContractFee{
 			UID:        contract.UID,
 			ContractID: contractID,
 			Code:       "",
@@ -871,7 +915,7 @@ func (s *ContractService) Settlement(ctx context.Context, contractID int64) erro
 	}
 
 	// 发送消息
-	if err := dao.MsgDaoInstance().Create(ctx, &model.Msg{
+	if err := s.core.Msg.Create(ctx, &model.Msg{
 		UID:        contract.UID,                                                                           // 用户ID
 		Title:      fmt.Sprintf("合约[%d]:结算成功", contract.ID),                                                // 标题
 		Content:    fmt.Sprintf("合约[%d]:结算成功,结算金额:%0.2f", contract.ID, util.FloatRound(contract.Money, 2)), // 内容
@@ -881,7 +925,7 @@ func (s *ContractService) Settlement(ctx context.Context, contractID int64) erro
 		return nil
 	}
 
-	if err := dao.TransferDaoInstance().Create(ctx, &model.Transfer{
+	if err := s.core.Transfer.Create(ctx, &model.Transfer{
 		UID:       contract.UID,
 		OrderTime: time.Now(),
 		Money:     contract.Money,
@@ -897,9 +941,9 @@ func (s *ContractService) Settlement(ctx context.Context, contractID int64) erro
 	}
 
 	// 设置用户有效的合约为当前合约
-	curContract, err := dao.ContractDaoInstance().GetEnableContractByUID(ctx, contract.UID)
+	curContract, err := s.core.Contract.GetEnableContractByUID(ctx, contract.UID)
 	if err == nil {
-		if err := dao.UserDaoInstance().UpdateCurrentContractID(ctx, curContract.UID, curContract.ID); err != nil {
+		if err := s.core.User.UpdateCurrentContractID(ctx, curContract.UID, curContract.ID); err != nil {
 			log.Errorf("更新用户的有效合约失败:%+v", err)
 		}
 	}
@@ -910,7 +954,7 @@ func (s *ContractService) Settlement(ctx context.Context, contractID int64) erro
 // AppendMoney 追加保证金
 func (s *ContractService) AppendMoney(ctx context.Context, contractID int64, money float64) error {
 	// 1. 检查资金是否足够 & 合约状态是否正常
-	contract, err := dao.ContractDaoInstance().GetContractByID(ctx, contractID)
+	contract, err := s.core.Contract.GetContractByID(ctx, contractID)
 	if err != nil {
 		log.Errorf("合约不存在:%+v", err)
 		return err
@@ -920,7 +964,7 @@ func (s *ContractService) AppendMoney(ctx context.Context, contractID int64, mon
 		return serr.ErrBusiness("合约状态错误")
 	}
 
-	user, err := dao.UserDaoInstance().GetUserByUID(ctx, contract.UID)
+	user, err := s.core.User.GetUserByUID(ctx, contract.UID)
 	if err != nil {
 		log.Errorf("GetUserByUID err:%+v", err)
 		return serr.ErrBusiness("用户不存在")
@@ -933,25 +977,30 @@ func (s *ContractService) AppendMoney(ctx context.Context, contractID int64, mon
 	contract.Money += money       // 保证金
 	contract.AppendMoney += money // 追加保证金
 
-	tx := db.StockDB().WithContext(ctx).Begin()
+	tx := s.core.Tx.Begin(ctx)
 	defer tx.Rollback()
-	wg := errgroup.GroupWithCount(3)
+	wg := errgroup.GroupWithCount(4)
+	wg.Go(func() error {
+		journal := model.NewJournal(model.LedgerBizAppendMoney, contract.ID, "追加保证金").
+			Move(model.WalletAccount(user.ID), model.MarginAccount(contract.ID), money)
+		return s.ledger.PostWithTx(tx, journal)
+	})
 	wg.Go(func() error {
-		if err := dao.ContractDaoInstance().UpdateWithTx(tx, contract); err != nil {
+		if err := s.core.Contract.UpdateWithTx(tx, contract); err != nil {
 			log.Errorf("更新合约失败:%+v", err)
 			return err
 		}
 		return nil
 	})
 	wg.Go(func() error {
-		if err := dao.UserDaoInstance().UpdateUserWithTx(tx, user); err != nil {
+		if err := s.core.User.UpdateUserWithTx(tx, user); err != nil {
 			log.Errorf("更新用户失败:%+v", err)
 			return err
 		}
 		return nil
 	})
 	wg.Go(func() error {
-		if err := dao.ContractFeeDaoInstance().CreateWithTx(tx, &model.ContractFee{
+		if err := s.core.ContractFee.CreateWithTx(tx, &model.ContractFee{
 			UID:        contract.UID,
 			ContractID: contract.ID,
 			OrderTime:  time.Now(),                         // 订单时间
@@ -973,7 +1022,7 @@ func (s *ContractService) AppendMoney(ctx context.Context, contractID int64, mon
 		return err
 	}
 
-	if err := dao.MsgDaoInstance().Create(ctx, &model.Msg{
+	if err := s.core.Msg.Create(ctx, &model.Msg{
 		UID:        contract.UID,                                                            // 用户ID
 		Title:      "追加保证金成功",                                                               // 标题
 		Content:    fmt.Sprintf("合约[%d],追加保证金:%f成功", contractID, util.FloatRound(money, 2)), // 内容
@@ -983,7 +1032,7 @@ func (s *ContractService) AppendMoney(ctx context.Context, contractID int64, mon
 		return nil
 	}
 
-	if err := dao.TransferDaoInstance().Create(ctx, &model.Transfer{
+	if err := s.core.Transfer.Create(ctx, &model.Transfer{
 		UID:       contract.UID,
 		OrderTime: time.Now(),
 		Money:     money,
@@ -1007,11 +1056,11 @@ func (s *ContractService) AppendMoney(ctx context.Context, contractID int64, mon
 
 // GetAppendMoney 追加保证金页面初始化
 func (s *ContractService) GetAppendMoney(ctx context.Context, contractID int64) (*model.AppendExpandContract, error) {
-	contract, err := dao.ContractDaoInstance().GetContractByID(ctx, contractID)
+	contract, err := s.core.Contract.GetContractByID(ctx, contractID)
 	if err != nil {
 		return nil, err
 	}
-	user, err := dao.UserDaoInstance().GetUserByUID(ctx, contract.UID)
+	user, err := s.core.User.GetUserByUID(ctx, contract.UID)
 	if err != nil {
 		return nil, err
 	}
@@ -1026,7 +1075,7 @@ func (s *ContractService) GetAppendMoney(ctx context.Context, contractID int64)
 // ExpandMoney 扩大合约资金
 func (s *ContractService) ExpandMoney(ctx context.Context, contractID int64, money float64) error {
 	// 1. 检查资金是否足够 & 合约状态是否正常
-	contract, err := dao.ContractDaoInstance().GetContractByID(ctx, contractID)
+	contract, err := s.core.Contract.GetContractByID(ctx, contractID)
 	if err != nil {
 		log.Errorf("合约不存在:%+v", err)
 		return err
@@ -1036,7 +1085,7 @@ func (s *ContractService) ExpandMoney(ctx context.Context, contractID int64, mon
 		return serr.ErrBusiness("合约状态错误")
 	}
 
-	user, err := dao.UserDaoInstance().GetUserByUID(ctx, contract.UID)
+	user, err := s.core.User.GetUserByUID(ctx, contract.UID)
 	if err != nil {
 		log.Errorf("GetUserByUID err:%+v", err)
 		return serr.ErrBusiness("用户不存在")
@@ -1045,7 +1094,7 @@ func (s *ContractService) ExpandMoney(ctx context.Context, contractID int64, mon
 		return serr.ErrBusiness("账户余额不足")
 	}
 
-	sys, err := dao.SysDaoInstance().GetSysParam(ctx)
+	sys, err := s.core.Sys.GetSysParam(ctx)
 	if err != nil {
 		log.Errorf("GetSysParam err:%+v", err)
 		return serr.ErrBusiness("网络错误")
@@ -1057,25 +1106,31 @@ func (s *ContractService) ExpandMoney(ctx context.Context, contractID int64, mon
 	// 扣取利息
 	interest := model.Interest(contract, sys, money)
 	contract.Money -= interest
-	tx := db.StockDB().WithContext(ctx).Begin()
+	tx := s.core.Tx.Begin(ctx)
 	defer tx.Rollback()
-	wg := errgroup.GroupWithCount(3)
+	wg := errgroup.GroupWithCount(4)
 	wg.Go(func() error {
-		if err := dao.ContractDaoInstance().UpdateWithTx(tx, contract); err != nil {
+		journal := model.NewJournal(model.LedgerBizExpandMoney, contract.ID, "扩大资金").
+			Move(model.WalletAccount(user.ID), model.MarginAccount(contract.ID), money).
+			Move(model.MarginAccount(contract.ID), model.InterestIncomeAccount, interest)
+		return s.ledger.PostWithTx(tx, journal)
+	})
+	wg.Go(func() error {
+		if err := s.core.Contract.UpdateWithTx(tx, contract); err != nil {
 			log.Errorf("UpdateWithTx更新合约失败:%+v", err)
 			return err
 		}
 		return nil
 	})
 	wg.Go(func() error {
-		if err := dao.UserDaoInstance().UpdateUserWithTx(tx, user); err != nil {
+		if err := s.core.User.UpdateUserWithTx(tx, user); err != nil {
 			log.Errorf("更新用户失败:%+v", err)
 			return err
 		}
 		return nil
 	})
 	wg.Go(func() error {
-		if err := dao.ContractFeeDaoInstance().CreateWithTx(tx, &model.ContractFee{
+		if err := s.core.ContractFee.CreateWithTx(tx, &model.ContractFee{
 			UID:        contract.UID,
 			ContractID: contract.ID,
 			Code:       "",
@@ -1100,7 +1155,7 @@ func (s *ContractService) ExpandMoney(ctx context.Context, contractID int64, mon
 		return err
 	}
 
-	if err := dao.MsgDaoInstance().Create(ctx, &model.Msg{
+	if err := s.core.Msg.Create(ctx, &model.Msg{
 		UID:        contract.UID,                                                                                                             // 用户ID
 		Title:      "扩大资金成功",                                                                                                                 // 标题
 		Content:    fmt.Sprintf("合约[%d],扩大保证金:%f元成功,收取扩大资金利息费用:%0.2f元", contractID, util.FloatRound(money, 2), util.FloatRound(interest, 2)), // 内容
@@ -1110,7 +1165,7 @@ func (s *ContractService) ExpandMoney(ctx context.Context, contractID int64, mon
 		return err
 	}
 
-	if err := dao.TransferDaoInstance().Create(ctx, &model.Transfer{
+	if err := s.core.Transfer.Create(ctx, &model.Transfer{
 		UID:       contract.UID,
 		OrderTime: time.Now(),
 		Money:     money,
@@ -1133,7 +1188,7 @@ func (s *ContractService) ExpandMoney(ctx context.Context, contractID int64, mon
 
 // HisContract 查询历史合约
 func (s *ContractService) HisContract(ctx context.Context, uid int64) ([]*model.HisContract, error) {
-	contracts, err := dao.ContractDaoInstance().GetContractsByUID(ctx, uid)
+	contracts, err := s.core.Contract.GetContractsByUID(ctx, uid)
 	if err != nil {
 		return nil, err
 	}
@@ -1152,7 +1207,7 @@ func (s *ContractService) HisContract(ctx context.Context, uid int64) ([]*model.
 	if len(closeIDs) == 0 {
 		return result, nil
 	}
-	list, err := dao.ContractFeeDaoInstance().GetContractFeeByIDs(ctx, closeIDs)
+	list, err := s.core.ContractFee.GetContractFeeByIDs(ctx, closeIDs)
 	if err != nil {
 		log.Errorf("GetContractFeeByIDs 查询合约费用失败,err:%+v", err)
 		return nil, err
@@ -1208,7 +1263,7 @@ func (s *ContractService) GetContract(ctx context.Context, uid int64) ([]*model.
 	wg := errgroup.GroupWithCount(2)
 	var contracts []*model.Contract
 	wg.Go(func() error {
-		ret, err := dao.ContractDaoInstance().GetContractsByUID(ctx, uid)
+		ret, err := s.core.Contract.GetContractsByUID(ctx, uid)
 		if err != nil {
 			return err
 		}
@@ -1217,7 +1272,7 @@ func (s *ContractService) GetContract(ctx context.Context, uid int64) ([]*model.
 	})
 	var user *model.User
 	wg.Go(func() error {
-		ret, err := dao.UserDaoInstance().GetUserByUID(ctx, uid)
+		ret, err := s.core.User.GetUserByUID(ctx, uid)
 		if err != nil {
 			return err
 		}
@@ -1250,14 +1305,14 @@ func (s *ContractService) GetContract(ctx context.Context, uid int64) ([]*model.
 // Select 选中合约
 func (s *ContractService) Select(ctx context.Context, uid, contractID int64) error {
 	// 检查合约是否存在
-	contract, err := dao.ContractDaoInstance().GetContractByID(ctx, contractID)
+	contract, err := s.core.Contract.GetContractByID(ctx, contractID)
 	if err != nil {
 		return err
 	}
 	if contract.Status != model.ContractStatusEnable {
 		return serr.ErrBusiness("非有效合约")
 	}
-	return dao.UserDaoInstance().UpdateCurrentContractID(ctx, uid, contractID)
+	return s.core.User.UpdateCurrentContractID(ctx, uid, contractID)
 }
 
 // GetTodayProfitByContractID 当日盈亏=当前价格*当前持仓数量 - 昨日收盘价*昨日持仓数量+当日卖出金额（含费）-当日买入金额（含费）
@@ -1266,7 +1321,7 @@ func (s *ContractService) GetTodayProfitByContractID(ctx context.Context, contra
 	positions := make([]*model.Position, 0)
 	// 查询合约持仓
 	wg.Go(func() error {
-		ret, err := dao.PositionDaoInstance().GetPositionByContractID(ctx, contractID)
+		ret, err := s.core.Position.GetPositionByContractID(ctx, contractID)
 		if err != nil {
 			return err
 		}
@@ -1276,7 +1331,7 @@ func (s *ContractService) GetTodayProfitByContractID(ctx context.Context, contra
 	// 查询今日委托
 	entrusts := make([]*model.Entrust, 0)
 	wg.Go(func() error {
-		ret, err := dao.EntrustDaoInstance().GetTodayEntrust(ctx, contractID)
+		ret, err := s.core.Entrust.GetTodayEntrust(ctx, contractID)
 		if err != nil {
 			return err
 		}
@@ -1286,7 +1341,7 @@ func (s *ContractService) GetTodayProfitByContractID(ctx context.Context, contra
 	// 查询昨日持仓
 	yesterdayPosition := make([]*model.Position, 0)
 	wg.Go(func() error {
-		ret, err := dao.HisPositionDaoInstance().GetYesterdayPositionByContractID(ctx, contractID)
+		ret, err := s.core.HisPosition.GetYesterdayPositionByContractID(ctx, contractID)
 		if err != nil {
 			return err
 		}
@@ -1296,7 +1351,7 @@ func (s *ContractService) GetTodayProfitByContractID(ctx context.Context, contra
 	// 今日是否为交易日
 	isTradeDate := false
 	wg.Go(func() error {
-		if ok := CalendarServiceInstance().IsTradeDate(ctx); ok {
+		if ok := s.core.Calendar.IsTradeDate(ctx); ok {
 			isTradeDate = true
 		}
 		return nil
@@ -1321,7 +1376,7 @@ func (s *ContractService) GetTodayProfitByContractID(ctx context.Context, contra
 	for _, it := range positions {
 		codes = append(codes, it.StockCode)
 	}
-	qts, err := quote.QtServiceInstance().GetQuoteByTencent(codes)
+	qts, err := s.core.Quote.GetQuoteByTencent(codes)
 	if err != nil {
 		return 0.00, err
 	}
@@ -1352,7 +1407,7 @@ func (s *ContractService) GetTodayProfitByContractID(ctx context.Context, contra
 
 // GetWithdrawProfit 查询合约提盈
 func (s *ContractService) GetWithdrawProfit(ctx context.Context, contractID int64) (*model.AppendExpandContract, error) {
-	contract, err := dao.ContractDaoInstance().GetContractByID(ctx, contractID)
+	contract, err := s.core.Contract.GetContractByID(ctx, contractID)
 	if err != nil {
 		return nil, err
 	}
@@ -1361,7 +1416,7 @@ func (s *ContractService) GetWithdrawProfit(ctx context.Context, contractID int6
 		Name:  contract.FullName(),
 		Money: 0.00,
 	}
-	list, err := dao.PositionDaoInstance().GetPositionByContractID(ctx, contractID)
+	list, err := s.core.Position.GetPositionByContractID(ctx, contractID)
 	if err != nil {
 		return nil, err
 	}
@@ -1379,34 +1434,40 @@ func (s *ContractService) GetWithdrawProfit(ctx context.Context, contractID int6
 
 // WithdrawProfit 合约提盈
 func (s *ContractService) WithdrawProfit(ctx context.Context, contractID int64, money float64) error {
-	list, err := dao.PositionDaoInstance().GetPositionByContractID(ctx, contractID)
+	list, err := s.core.Position.GetPositionByContractID(ctx, contractID)
 	if err != nil {
 		return err
 	}
 	if len(list) > 0 {
 		return serr.ErrBusiness("合约持有股票,提盈失败")
 	}
-	contract, err := dao.ContractDaoInstance().GetContractByID(ctx, contractID)
+	contract, err := s.core.Contract.GetContractByID(ctx, contractID)
 	if err != nil {
 		return err
 	}
 	if money > contract.Money-contract.InitMoney {
 		return serr.ErrBusiness("提盈金额大于可提取金额")
 	}
-	user, err := dao.UserDaoInstance().GetUserByUID(ctx, contract.UID)
+	user, err := s.core.User.GetUserByUID(ctx, contract.UID)
 	if err != nil {
 		log.Errorf("GetUserByUID err:%+v", err)
 		return serr.ErrBusiness("提盈失败:用户不存在")
 	}
 
-	tx := db.StockDB().WithContext(ctx).Begin()
+	tx := s.core.Tx.Begin(ctx)
 	defer tx.Rollback()
 
-	wg := errgroup.GroupWithCount(4)
+	wg := errgroup.GroupWithCount(5)
+	// 记账:保证金转入钱包
+	wg.Go(func() error {
+		journal := model.NewJournal(model.LedgerBizWithdrawProfit, contract.ID, "合约提盈").
+			Move(model.MarginAccount(contract.ID), model.WalletAccount(user.ID), money)
+		return s.ledger.PostWithTx(tx, journal)
+	})
 	// 扣除合约保证金,更新合约
 	contract.Money = contract.Money - money
 	wg.Go(func() error {
-		if err := dao.ContractDaoInstance().UpdateWithTx(tx, contract); err != nil {
+		if err := s.core.Contract.UpdateWithTx(tx, contract); err != nil {
 			log.Errorf("UpdateContract err:%+v", err)
 			return err
 		}
@@ -1415,7 +1476,7 @@ func (s *ContractService) WithdrawProfit(ctx context.Context, contractID int64,
 
 	user.Money += money
 	wg.Go(func() error {
-		if err := dao.UserDaoInstance().UpdateUserWithTx(tx, user); err != nil {
+		if err := s.core.User.UpdateUserWithTx(tx, user); err != nil {
 			log.Errorf("更新用户资金失败:%+v", err)
 			return err
 		}
@@ -1424,7 +1485,7 @@ func (s *ContractService) WithdrawProfit(ctx context.Context, contractID int64,
 
 	// 填写合约费用表
 	wg.Go(func() error {
-		if err := dao.ContractFeeDaoInstance().CreateWithTx(tx, &model.ContractFee{
+		if err := s.core.ContractFee.CreateWithTx(tx, &model.ContractFee{
 			UID:        contract.UID,
 			ContractID: contract.ID,
 			Code:       "",
@@ -1444,7 +1505,7 @@ func (s *ContractService) WithdrawProfit(ctx context.Context, contractID int64,
 
 	// 填写msg表
 	wg.Go(func() error {
-		if err := dao.MsgDaoInstance().CreateWithTx(tx, &model.Msg{
+		if err := s.core.Msg.CreateWithTx(tx, &model.Msg{
 			UID:        contract.UID,
 			Title:      "提盈成功",
 			Content:    fmt.Sprintf("%s[%d]提盈成功,提取金额:%0.2f元,请留意资金变动。", contract.FullName(), contract.ID, money),
@@ -1468,7 +1529,7 @@ func (s *ContractService) WithdrawProfit(ctx context.Context, contractID int64,
 		log.Errorf("更新合约资金失败:%+v", err)
 	}
 
-	if err := dao.TransferDaoInstance().Create(ctx, &model.Transfer{
+	if err := s.core.Transfer.Create(ctx, &model.Transfer{
 		UID:       contract.UID,
 		OrderTime: time.Now(),
 		Money:     money,
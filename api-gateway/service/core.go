@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"stock/api-gateway/dao"
+	"stock/api-gateway/db"
+	"stock/api-gateway/id_gen"
+	"stock/api-gateway/model"
+	"stock/api-gateway/quote"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// QuoteSource 行情源
+type QuoteSource interface {
+	GetQuoteByTencent(codes []string) (map[string]*model.TencentQuote, error)
+}
+
+// Cache 缓存:redis.Client的子集
+type Cache interface {
+	Get(ctx context.Context, key string) *redis.StringCmd
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd
+	Exists(ctx context.Context, keys ...string) *redis.IntCmd
+	Del(ctx context.Context, keys ...string) *redis.IntCmd
+}
+
+// SmsSender 短信发送
+type SmsSender interface {
+	SendSms(ctx context.Context, content string, phone string) error
+}
+
+// BrokerRouter 券商通道:按资金、持仓选择母账户申报委托及撤单
+type BrokerRouter interface {
+	GetBrokers() []*model.Broker
+	Entrust(entrust *model.Entrust) error
+	Withdraw(entrust *model.BrokerEntrust, broker *model.Broker, entrustNo string) error
+}
+
+// TradeCalendar 交易时间判断
+type TradeCalendar interface {
+	IsEntrustTime(ctx context.Context) bool
+	IsTradeTime(ctx context.Context) bool
+	IsTradeDate(ctx context.Context) bool
+	IsTradeDay(ctx context.Context, date time.Time) bool
+}
+
+// IdentityVerifier 实名认证第三方核验:姓名与身份证号是否一致,核验服务不可用(网络、服务未开通等)时返回error
+type IdentityVerifier interface {
+	Verify(ctx context.Context, name, idNo string) (bool, error)
+}
+
+// OrderNoGenerator 订单号生成:充值订单号等全局唯一编号
+type OrderNoGenerator interface {
+	NextOrderNo() string
+}
+
+var (
+	_ QuoteSource   = (*quote.QtService)(nil)
+	_ Cache         = (*redis.Client)(nil)
+	_ SmsSender     = (*SmsService)(nil)
+	_ BrokerRouter  = (*BrokerService)(nil)
+	_ TradeCalendar = (*CalendarService)(nil)
+)
+
+// Core 交易核心的外部依赖:数据表、行情、缓存、短信、券商通道等,由NewServices注入各服务
+type Core struct {
+	*dao.Store
+	Quote    QuoteSource
+	Cache    Cache
+	Sms      SmsSender
+	Broker   BrokerRouter
+	Calendar TradeCalendar
+	Identity IdentityVerifier
+	OrderNo  OrderNoGenerator
+}
+
+// Services 使用同一组依赖构造的交易核心服务,服务之间互相调用时使用同一组实例
+type Services struct {
+	core *Core
+
+	Trade          *TradeService
+	Contract       *ContractService
+	Buy            *BuyService
+	Sell           *SellService
+	Position       *PositionService
+	Ledger         *LedgerService
+	Recharge       *RechargeService
+	Identity       *IdentityService
+	TransferReview *TransferReviewService
+	WithdrawPolicy *WithdrawPolicyService
+	My             *MyService
+	StockData      *StockDataService
+	PriceBand      *PriceBandService
+}
+
+// NewServices 按依赖构造交易核心服务,不启动后台任务
+func NewServices(c *Core) *Services {
+	svc := &Services{
+		core:           c,
+		Position:       &PositionService{core: c},
+		Ledger:         &LedgerService{core: c},
+		Identity:       &IdentityService{core: c},
+		WithdrawPolicy: newWithdrawPolicyService(c),
+		StockData:      &StockDataService{core: c},
+		PriceBand:      &PriceBandService{core: c},
+	}
+	svc.Contract = &ContractService{core: c, ledger: svc.Ledger, position: svc.Position}
+	svc.Buy = &BuyService{core: c, contract: svc.Contract, ledger: svc.Ledger}
+	svc.Sell = &SellService{core: c, contract: svc.Contract, ledger: svc.Ledger}
+	svc.Trade = &TradeService{
+		core:      c,
+		contract:  svc.Contract,
+		buy:       svc.Buy,
+		sell:      svc.Sell,
+		position:  svc.Position,
+		priceBand: svc.PriceBand,
+		stockData: svc.StockData,
+	}
+	svc.Contract.trade = svc.Trade
+	svc.Recharge = &RechargeService{core: c, ledger: svc.Ledger}
+	svc.TransferReview = &TransferReviewService{core: c, ledger: svc.Ledger}
+	svc.My = &MyService{core: c, ledger: svc.Ledger, withdrawPolicy: svc.WithdrawPolicy}
+	return svc
+}
+
+var (
+	defaultSvc     *Services
+	defaultSvcOnce sync.Once
+)
+
+// defaultServices 使用MySQL、redis及线上行情、券商通道的交易核心服务,供各服务的Instance方法使用
+func defaultServices() *Services {
+	defaultSvcOnce.Do(func() {
+		defaultSvc = NewServices(defaultCore())
+	})
+	return defaultSvc
+}
+
+// defaultCore 线上依赖
+func defaultCore() *Core {
+	return &Core{
+		Store:    dao.DefaultStore(),
+		Quote:    quote.QtServiceInstance(),
+		Cache:    db.RedisClient(),
+		Sms:      SmsServiceInstance(),
+		Broker:   &lazyBroker{},
+		Calendar: CalendarServiceInstance(),
+		Identity: newIdentityVerifier(),
+		OrderNo:  &sonyflakeOrderNo{},
+	}
+}
+
+// sonyflakeOrderNo 雪花算法订单号,首次使用时才初始化(依赖本机内网IP)
+type sonyflakeOrderNo struct {
+}
+
+// NextOrderNo 生成订单号
+func (g *sonyflakeOrderNo) NextOrderNo() string {
+	return strconv.FormatInt(id_gen.GetNextID(), 10)
+}
+
+// lazyBroker 首次使用时才连接券商通道
+type lazyBroker struct {
+}
+
+// GetBrokers 券商列表
+func (b *lazyBroker) GetBrokers() []*model.Broker {
+	return BrokerServiceInstance().GetBrokers()
+}
+
+// Entrust 券商委托
+func (b *lazyBroker) Entrust(entrust *model.Entrust) error {
+	return BrokerServiceInstance().Entrust(entrust)
+}
+
+// Withdraw 券商撤单
+func (b *lazyBroker) Withdraw(entrust *model.BrokerEntrust, broker *model.Broker, entrustNo string) error {
+	return BrokerServiceInstance().Withdraw(entrust, broker, entrustNo)
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"stock/api-gateway/dao"
+	"stock/api-gateway/db"
+	"stock/api-gateway/model"
+	"stock/api-gateway/serr"
+	"stock/api-gateway/util"
+	"stock/common/log"
+	"sync"
+	"time"
+)
+
+// deviceKeyTTL 设备密钥缓存时间
+const deviceKeyTTL = 24 * time.Hour
+
+// DeviceService 设备注册与签名密钥
+type DeviceService struct {
+}
+
+var (
+	deviceService *DeviceService
+	deviceOnce    sync.Once
+)
+
+// DeviceServiceInstance 实例
+func DeviceServiceInstance() *DeviceService {
+	deviceOnce.Do(func() {
+		deviceService = &DeviceService{}
+	})
+	return deviceService
+}
+
+// Register 注册设备,由服务端生成设备ID和签名密钥
+func (s *DeviceService) Register(ctx context.Context, platform string) (*model.Device, error) {
+	deviceID, err := util.RandomHex(16)
+	if err != nil {
+		log.Errorf("RandomHex err:%+v", err)
+		return nil, serr.ErrBusiness("设备注册失败")
+	}
+	secret, err := util.RandomHex(32)
+	if err != nil {
+		log.Errorf("RandomHex err:%+v", err)
+		return nil, serr.ErrBusiness("设备注册失败")
+	}
+	device := &model.Device{
+		DeviceID:   deviceID,
+		Secret:     model.Secret(secret),
+		Platform:   platform,
+		CreateTime: time.Now(),
+	}
+	if err := dao.DeviceDaoInstance().Create(ctx, device); err != nil {
+		return nil, serr.ErrBusiness("设备注册失败")
+	}
+	return device, nil
+}
+
+// DeviceKey 设备签名密钥
+func (s *DeviceService) DeviceKey(ctx context.Context, deviceID string) ([]byte, error) {
+	key := s.deviceKeyCacheKey(deviceID)
+	secret, err := db.RedisClient().Get(ctx, key).Result()
+	if err != nil || len(secret) == 0 {
+		device, err := dao.DeviceDaoInstance().GetByDeviceID(ctx, deviceID)
+		if err != nil {
+			return nil, err
+		}
+		secret = device.Secret.String()
+		if err := db.RedisClient().Set(ctx, key, secret, deviceKeyTTL).Err(); err != nil {
+			log.Errorf("缓存设备密钥失败:%+v", err)
+		}
+	}
+	return hex.DecodeString(secret)
+}
+
+func (s *DeviceService) deviceKeyCacheKey(deviceID string) string {
+	return fmt.Sprintf("device_key_%s", deviceID)
+}
@@ -28,15 +28,6 @@ var (
 func DividendServiceInstance() *DividendService {
 	dividendOnce.Do(func() {
 		dividendService = &DividendService{}
-		ctx := context.Background()
-		go func() {
-			for range time.Tick(10 * time.Second) {
-				if err := dividendService.load(ctx); err != nil {
-					log.Errorf("getDividendFromEastMoney err:%+v", err)
-					continue
-				}
-			}
-		}()
 	})
 	return dividendService
 }
@@ -77,22 +68,8 @@ func (s *DividendService) getDividendFromEastMoney(ctx context.Context, url stri
 	return &item, nil
 }
 
-func (s *DividendService) cacheKey() string {
-	return fmt.Sprintf("dividend_cache_key_date:%+v", timeconv.TimeToInt32(time.Now()))
-}
-
-// load load
-func (s *DividendService) load(ctx context.Context) error {
-	// 下午4点开始检查分红
-	if time.Now().Hour() < 16 {
-		return nil
-	}
-
-	// redis 检查:今日是否已经检查过分红
-	if db.Get(ctx, s.cacheKey()).Val() == "1" {
-		return nil
-	}
-
+// Load 持仓股票分红除权除息:由定时任务交易日16:00执行,处理除权除息日为date的分红
+func (s *DividendService) Load(ctx context.Context, date time.Time) error {
 	list, err := dao.PositionDaoInstance().GetPositions(ctx)
 	if err != nil {
 		log.Errorf("GetPositions err:%+v", err)
@@ -115,7 +92,7 @@ func (s *DividendService) load(ctx context.Context) error {
 				continue
 			}
 
-			if timeconv.TimeToInt32(dividendTime) != timeconv.TimeToInt32(time.Now()) {
+			if timeconv.TimeToInt32(dividendTime) != timeconv.TimeToInt32(date) {
 				continue
 			}
 
@@ -126,12 +103,6 @@ func (s *DividendService) load(ctx context.Context) error {
 		}
 	}
 
-	// redis 检查:今日是否已经检查过分红
-	if err := db.Set(ctx, s.cacheKey(), "1", 7*24*time.Hour).Err(); err != nil {
-		log.Errorf("设置redis失败:%+v", err)
-		panic("设置redis失败")
-	}
-
 	return nil
 }
 
@@ -190,8 +161,19 @@ func (s *DividendService) dividend(ctx context.Context, position *model.Position
 			return err
 		}
 		contract.Money += dividendMoney
-		if err := dao.ContractDaoInstance().UpdateContract(ctx, contract); err != nil {
-			log.Errorf("UpdateContract err:%+v", err)
+		tx := db.StockDB().WithContext(ctx).Begin()
+		defer tx.Rollback()
+		if err := dao.ContractDaoInstance().UpdateWithTx(tx, contract); err != nil {
+			log.Errorf("UpdateWithTx err:%+v", err)
+			return err
+		}
+		journal := model.NewJournal(model.LedgerBizDividend, position.ID, fmt.Sprintf("%s(%s)现金分红", position.StockName, position.StockCode)).
+			Move(model.MarketAccount, model.MarginAccount(contract.ID), dividendMoney)
+		if err := LedgerServiceInstance().PostWithTx(tx, journal); err != nil {
+			return err
+		}
+		if err := tx.Commit().Error; err != nil {
+			log.Errorf("事务提交失败:%+v", err)
 			return err
 		}
 	}
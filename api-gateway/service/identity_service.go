@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"stock/api-gateway/model"
+	"stock/api-gateway/serr"
+	"stock/api-gateway/util"
+	"stock/common/env"
+	"stock/common/log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	identityMinAge     = 18                                                     // 开户最低年龄
+	aliyunIdentityURL  = "https://idcardcert.market.alicloudapi.com/idCardCert" // 阿里云身份证二要素核验
+	aliyunIdentityPass = "01"                                                   // 核验结果:一致
+	aliyunIdentityFail = "02"                                                   // 核验结果:不一致
+)
+
+// IdentityService 实名认证:本地校验身份证号码与年龄后,由第三方核验姓名与身份证号是否一致
+type IdentityService struct {
+	core *Core
+}
+
+var (
+	identityService *IdentityService
+	identityOnce    sync.Once
+)
+
+// IdentityServiceInstance 实例
+func IdentityServiceInstance() *IdentityService {
+	identityOnce.Do(func() {
+		identityService = defaultServices().Identity
+	})
+	return identityService
+}
+
+// Verify 实名认证,第三方核验不一致时记录认证失败;核验服务不可用时不记录,用户可稍后重试
+func (s *IdentityService) Verify(ctx context.Context, uid int64, name, idNo string) error {
+	name = strings.TrimSpace(name)
+	idNo = strings.ToUpper(strings.TrimSpace(idNo))
+	user, err := s.core.User.GetUserByUID(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if user.Verified() {
+		return serr.ErrBusiness("已实名认证")
+	}
+	if len(name) == 0 {
+		return serr.ErrBusiness("请填写真实姓名")
+	}
+	birthday, err := util.ValidateIDCard(idNo, time.Now())
+	if err != nil {
+		return serr.ErrBusiness(err.Error())
+	}
+	if util.Age(birthday, time.Now()) < identityMinAge {
+		return serr.ErrBusiness(fmt.Sprintf("未满%d周岁不能开户", identityMinAge))
+	}
+
+	// 第三方核验较慢,只更新实名认证字段,不覆盖期间对用户其他字段的修改
+	pass, err := s.core.Identity.Verify(ctx, name, idNo)
+	if err != nil {
+		return err
+	}
+	if !pass {
+		user.VerifyStatus = model.UserVerifyFail
+		if err := s.core.User.UpdateVerify(ctx, user); err != nil {
+			log.Errorf("UpdateVerify err:%+v", err)
+		}
+		return serr.ErrBusiness("姓名与身份证号不一致")
+	}
+	user.Name = name
+	user.ICCID = model.Secret(idNo)
+	user.VerifyStatus = model.UserVerifyPass
+	if err := s.core.User.UpdateVerify(ctx, user); err != nil {
+		log.Errorf("UpdateVerify err:%+v", err)
+		return serr.ErrBusiness("实名认证失败")
+	}
+	return nil
+}
+
+// newIdentityVerifier 配置了IDCARD_APPCODE时使用阿里云二要素核验,否则非生产环境使用本地核验
+func newIdentityVerifier() IdentityVerifier {
+	appCode, ok := env.GlobalEnv().Get("IDCARD_APPCODE")
+	if ok || env.GlobalEnv().IsProd() {
+		return &aliyunIdentityVerifier{appCode: appCode, client: &http.Client{Timeout: 10 * time.Second}}
+	}
+	return &localIdentityVerifier{}
+}
+
+// aliyunIdentityVerifier 阿里云身份证二要素核验
+type aliyunIdentityVerifier struct {
+	appCode string
+	client  *http.Client
+}
+
+// Verify 核验姓名与身份证号是否一致
+func (v *aliyunIdentityVerifier) Verify(ctx context.Context, name, idNo string) (bool, error) {
+	if len(v.appCode) == 0 {
+		log.Errorf("no IDCARD_APPCODE config")
+		return false, serr.ErrBusiness("实名认证服务未开通")
+	}
+	query := url.Values{}
+	query.Set("idCard", idNo)
+	query.Set("name", name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, aliyunIdentityURL+"?"+query.Encode(), nil)
+	if err != nil {
+		log.Errorf("NewRequest err:%+v", err)
+		return false, serr.ErrBusiness("实名认证失败")
+	}
+	req.Header.Add("Authorization", "APPCODE "+v.appCode)
+	resp, err := v.client.Do(req)
+	if err != nil {
+		log.Errorf("实名认证请求失败:%+v", err)
+		return false, serr.ErrBusiness("实名认证服务繁忙,请稍后再试")
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.Errorf("实名认证读取返回失败:%+v", err)
+		return false, serr.ErrBusiness("实名认证服务繁忙,请稍后再试")
+	}
+	result := &struct {
+		Status string `json:"status"`
+		Msg    string `json:"msg"`
+	}{}
+	if err := json.Unmarshal(body, result); err != nil {
+		log.Errorf("实名认证返回错误:%s", string(body))
+		return false, serr.ErrBusiness("实名认证服务繁忙,请稍后再试")
+	}
+	switch result.Status {
+	case aliyunIdentityPass:
+		return true, nil
+	case aliyunIdentityFail:
+		return false, nil
+	}
+	// 无法核验、库中无此号等情况不视为不一致
+	log.Errorf("实名认证无法核验:%s %s", result.Status, result.Msg)
+	return false, serr.ErrBusiness(result.Msg)
+}
+
+// localIdentityVerifier 本地核验:不调用第三方,仅用于开发测试环境
+type localIdentityVerifier struct {
+}
+
+// Verify 始终一致
+func (v *localIdentityVerifier) Verify(ctx context.Context, name, idNo string) (bool, error) {
+	log.Infof("本地实名认证:%s", name)
+	return true, nil
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"stock/api-gateway/fake"
+	"stock/api-gateway/model"
+)
+
+// identityHook 核验期间执行hook,模拟第三方核验较慢时用户数据被修改
+type identityHook struct {
+	*fake.Identity
+	hook func()
+}
+
+func (i *identityHook) Verify(ctx context.Context, name, idNo string) (bool, error) {
+	i.hook()
+	return i.Identity.Verify(ctx, name, idNo)
+}
+
+// TestIdentityVerify 本地校验不通过不调用第三方,第三方不一致记录认证失败,核验服务不可用不记录,认证通过后可申请合约
+func TestIdentityVerify(t *testing.T) {
+	ctx := context.Background()
+	store, _, svc := newFakeServices()
+	identity := &fake.Identity{Reject: map[string]bool{"110101200002290018": true}}
+	svc.core.Identity = identity
+	store.SetSysParam(&model.SysParam{})
+	user := store.PutUser(&model.User{Status: model.UserStatusActive})
+
+	if _, err := svc.Contract.ContractApply(ctx, user.ID, 2000, 1, 1); err == nil {
+		t.Fatal("expect contract rejected before verify")
+	}
+	for _, idNo := range []string{"110101194912310021", "990105194912310029", "110101201501010011"} {
+		if err := svc.Identity.Verify(ctx, user.ID, "张三", idNo); err == nil {
+			t.Fatalf("expect %s rejected", idNo)
+		}
+	}
+	if len(identity.Checked) != 0 {
+		t.Fatalf("expect no provider call, got %v", identity.Checked)
+	}
+
+	if err := svc.Identity.Verify(ctx, user.ID, "张三", "110101200002290018"); err == nil {
+		t.Fatal("expect provider reject")
+	}
+	u, _ := svc.core.User.GetUserByUID(ctx, user.ID)
+	if u.VerifyStatus != model.UserVerifyFail || len(u.ICCID) != 0 {
+		t.Fatalf("expect verify fail, got %d %s", u.VerifyStatus, u.ICCID)
+	}
+
+	// 核验服务不可用:不记录认证失败
+	identity.Err = errors.New("timeout")
+	u.VerifyStatus = model.UserVerifyNone
+	store.PutUser(u)
+	if err := svc.Identity.Verify(ctx, user.ID, "张三", "11010519491231002X"); err == nil {
+		t.Fatal("expect provider error")
+	}
+	u, _ = svc.core.User.GetUserByUID(ctx, user.ID)
+	if u.VerifyStatus != model.UserVerifyNone {
+		t.Fatalf("expect verify status kept, got %d", u.VerifyStatus)
+	}
+	identity.Err = nil
+
+	// 核验期间修改的其他字段不被覆盖
+	svc.core.Identity = &identityHook{Identity: identity, hook: func() {
+		_ = svc.core.User.UpdateCurrentContractID(ctx, user.ID, 99)
+	}}
+	if err := svc.Identity.Verify(ctx, user.ID, " 张三 ", "11010519491231002x"); err != nil {
+		t.Fatalf("verify: %+v", err)
+	}
+	u, _ = svc.core.User.GetUserByUID(ctx, user.ID)
+	if !u.Verified() || u.Name != "张三" || u.ICCID != "11010519491231002X" || u.CurrentContractID != 99 {
+		t.Fatalf("expect verified, got %+v", u)
+	}
+	if err := svc.Identity.Verify(ctx, user.ID, "张三", "11010519491231002X"); err == nil {
+		t.Fatal("expect already verified")
+	}
+	if _, err := svc.Contract.ContractApply(ctx, user.ID, 2000, 1, 1); err != nil {
+		t.Fatalf("contract apply: %+v", err)
+	}
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"stock/api-gateway/model"
+	"stock/api-gateway/serr"
+	"stock/api-gateway/util"
+	"stock/common/log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LedgerService 复式记账:所有资金变动在同一事务内写入记账凭证,账户余额可由分录汇总并与业务表核对
+type LedgerService struct {
+	core *Core
+}
+
+var (
+	ledgerService *LedgerService
+	ledgerOnce    sync.Once
+)
+
+// LedgerServiceInstance 实例
+func LedgerServiceInstance() *LedgerService {
+	ledgerOnce.Do(func() {
+		ledgerService = defaultServices().Ledger
+	})
+	return ledgerService
+}
+
+// PostWithTx 在业务事务内记账,借贷不平衡的凭证拒绝写入
+func (s *LedgerService) PostWithTx(tx *gorm.DB, journal *model.Journal) error {
+	if len(journal.Entries) == 0 {
+		return nil
+	}
+	if !journal.Balanced() {
+		log.Errorf("记账凭证借贷不平衡:%+v", journal)
+		return serr.ErrBusiness("记账失败")
+	}
+	no, err := util.RandomHex(8)
+	if err != nil {
+		return err
+	}
+	journalNo := fmt.Sprintf("%s%s", time.Now().Format("20060102150405"), no)
+	now := time.Now()
+	for _, it := range journal.Entries {
+		it.JournalNo = journalNo
+		it.BizType = journal.BizType
+		it.BizID = journal.BizID
+		it.Remark = journal.Remark
+		it.CreateTime = now
+	}
+	if err := s.core.Ledger.CreateWithTx(tx, journal.Entries); err != nil {
+		log.Errorf("记账失败:%+v", err)
+		return err
+	}
+	return nil
+}
+
+// brokerDealJournal 券商成交凭证:买入成交金额从券商资金付出,卖出成交金额转入券商资金,券商手续费从券商资金付出
+func brokerDealJournal(entrust *model.Entrust) *model.Journal {
+	journal := model.NewJournal(model.LedgerBizBrokerDeal, entrust.ID,
+		fmt.Sprintf("%s(%s)%s券商成交", entrust.StockName, entrust.StockCode, entrust.ConvertEntrustBsToString()))
+	for _, it := range entrust.BrokerEntrust {
+		if it.DealAmount <= 0 {
+			continue
+		}
+		account := model.BrokerCashAccount(it.BrokerID)
+		if entrust.EntrustBS == model.EntrustBsTypeBuy {
+			journal.Move(account, model.MarketAccount, it.DealBalance)
+		} else {
+			journal.Move(model.MarketAccount, account, it.DealBalance)
+		}
+		journal.Move(account, model.ExternalAccount, it.Fee)
+	}
+	return journal
+}
+
+// Check 核对账簿与业务表余额:用户钱包、提现冻结、合约保证金;非操盘中合约的保证金账户余额应为0
+func (s *LedgerService) Check(ctx context.Context) ([]*model.LedgerDiff, error) {
+	books, err := s.books(ctx)
+	if err != nil {
+		return nil, err
+	}
+	diffs := make([]*model.LedgerDiff, 0)
+	for accountType, book := range books {
+		balances, err := s.core.Ledger.GetBalances(ctx, accountType)
+		if err != nil {
+			return nil, err
+		}
+		for ownerID, money := range book {
+			if math.Abs(money-balances[ownerID]) >= 0.01 {
+				diffs = append(diffs, &model.LedgerDiff{AccountType: accountType, OwnerID: ownerID, Book: money, Ledger: balances[ownerID]})
+			}
+		}
+		// 账簿中存在而业务表中已不存在的账户
+		for ownerID, balance := range balances {
+			if _, ok := book[ownerID]; !ok && math.Abs(balance) >= 0.01 {
+				diffs = append(diffs, &model.LedgerDiff{AccountType: accountType, OwnerID: ownerID, Ledger: balance})
+			}
+		}
+	}
+	return diffs, nil
+}
+
+// Open 期初余额:启用记账前已存在的账户,按业务表余额从人工调账账户转入;已有分录的账户不处理
+func (s *LedgerService) Open(ctx context.Context, operator string) (int, error) {
+	books, err := s.books(ctx)
+	if err != nil {
+		return 0, err
+	}
+	journal := model.NewJournal(model.LedgerBizOpening, 0, fmt.Sprintf("期初余额,操作员:%s", operator))
+	for accountType, book := range books {
+		balances, err := s.core.Ledger.GetBalances(ctx, accountType)
+		if err != nil {
+			return 0, err
+		}
+		for ownerID, money := range book {
+			if _, ok := balances[ownerID]; ok {
+				continue
+			}
+			journal.Move(model.AdjustAccount, model.LedgerAccount{Type: accountType, OwnerID: ownerID}, money)
+		}
+	}
+
+	tx := s.core.Tx.Begin(ctx)
+	defer tx.Rollback()
+	if err := s.PostWithTx(tx, journal); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Errorf("事务提交失败:%+v", err)
+		return 0, err
+	}
+	return len(journal.Entries) / 2, nil
+}
+
+// books 业务表中的账户余额:账户类型->账户所属ID->余额
+func (s *LedgerService) books(ctx context.Context) (map[int64]map[int64]float64, error) {
+	users, err := s.core.User.GetUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	contracts, err := s.core.Contract.GetContracts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	books := map[int64]map[int64]float64{
+		model.LedgerAccountUserWallet:     {},
+		model.LedgerAccountUserFreeze:     {},
+		model.LedgerAccountContractMargin: {},
+	}
+	for _, it := range users {
+		books[model.LedgerAccountUserWallet][it.ID] = it.Money
+		books[model.LedgerAccountUserFreeze][it.ID] = it.FreezeMoney
+	}
+	for _, it := range contracts {
+		// 申请中、已结算的合约保证金不在合约内
+		if it.Status != model.ContractStatusEnable {
+			books[model.LedgerAccountContractMargin][it.ID] = 0
+			continue
+		}
+		books[model.LedgerAccountContractMargin][it.ID] = it.Money
+	}
+	return books, nil
+}
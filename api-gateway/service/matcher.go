@@ -0,0 +1,214 @@
+package service
+
+import (
+	"stock/api-gateway/model"
+	"stock/api-gateway/util"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMatchVolumePct = 0.1         // 成交量参与撮合:默认参与比例
+	volumeSnapshotTTL     = time.Minute // 成交量参与撮合:行情快照有效期,过期则重新记录起点
+)
+
+// Matcher 非券商委托模拟撮合
+type Matcher interface {
+	// Match 按行情撮合同一只股票的未完成委托,entrusts需按时间优先排序,返回本次成交明细
+	Match(qt *model.TencentQuote, entrusts []*model.Entrust) []*MatchResult
+}
+
+// MatchResult 撮合结果
+type MatchResult struct {
+	Entrust *model.Entrust // 委托
+	Amount  int64          // 本次成交数量(股)
+	Price   float64        // 本次成交均价
+}
+
+// NewMatcher 根据系统参数选择撮合方式,默认最新价撮合
+func NewMatcher(sys *model.SysParam) Matcher {
+	switch sys.MatchMode {
+	case model.MatchModeOrderBook:
+		return &orderBookMatcher{}
+	case model.MatchModeVolume:
+		pct := sys.MatchVolumePct
+		if pct <= 0 || pct > 1 {
+			pct = defaultMatchVolumePct
+		}
+		return &volumeMatcher{pct: pct, snapshots: volumeSnapshots}
+	}
+	return &lastPriceMatcher{}
+}
+
+// isCross 成交价格是否满足委托限价:买入不高于委托价,卖出不低于委托价
+func isCross(entrust *model.Entrust, price float64) bool {
+	if price <= 0 {
+		return false
+	}
+	if entrust.EntrustBS == model.EntrustBsTypeBuy {
+		return price <= entrust.Price
+	}
+	return price >= entrust.Price
+}
+
+// fillAmount 可成交数量:不足剩余委托数量时按整手成交
+func fillAmount(remain, available int64) int64 {
+	if available >= remain {
+		return remain
+	}
+	return available / 100 * 100
+}
+
+// lastPriceMatcher 最新价撮合:最新价穿越委托价则按委托价全部成交
+type lastPriceMatcher struct {
+}
+
+// Match 撮合
+func (m *lastPriceMatcher) Match(qt *model.TencentQuote, entrusts []*model.Entrust) []*MatchResult {
+	results := make([]*MatchResult, 0)
+	for _, entrust := range entrusts {
+		remain := entrust.Amount - entrust.DealAmount
+		if remain <= 0 || !isCross(entrust, qt.CurrentPrice) {
+			continue
+		}
+		results = append(results, &MatchResult{Entrust: entrust, Amount: remain, Price: entrust.Price})
+	}
+	return results
+}
+
+// orderBookMatcher 五档盘口撮合:买入吃卖盘、卖出吃买盘,按时间优先分配盘口挂单量,深度不足则部分成交
+type orderBookMatcher struct {
+}
+
+// bookLevel 盘口档位
+type bookLevel struct {
+	price  float64 // 价格
+	amount int64   // 挂单量(股)
+}
+
+// book 对手盘五档,盘口量单位为手
+func (m *orderBookMatcher) book(qt *model.TencentQuote, entrustBS int64) []*bookLevel {
+	if entrustBS == model.EntrustBsTypeBuy {
+		return []*bookLevel{
+			{price: qt.SellPrice1, amount: qt.SellVol1 * 100},
+			{price: qt.SellPrice2, amount: qt.SellVol2 * 100},
+			{price: qt.SellPrice3, amount: qt.SellVol3 * 100},
+			{price: qt.SellPrice4, amount: qt.SellVol4 * 100},
+			{price: qt.SellPrice5, amount: qt.SellVol5 * 100},
+		}
+	}
+	return []*bookLevel{
+		{price: qt.BuyPrice1, amount: qt.BuyVol1 * 100},
+		{price: qt.BuyPrice2, amount: qt.BuyVol2 * 100},
+		{price: qt.BuyPrice3, amount: qt.BuyVol3 * 100},
+		{price: qt.BuyPrice4, amount: qt.BuyVol4 * 100},
+		{price: qt.BuyPrice5, amount: qt.BuyVol5 * 100},
+	}
+}
+
+// Match 撮合
+func (m *orderBookMatcher) Match(qt *model.TencentQuote, entrusts []*model.Entrust) []*MatchResult {
+	books := map[int64][]*bookLevel{
+		model.EntrustBsTypeBuy:  m.book(qt, model.EntrustBsTypeBuy),
+		model.EntrustBsTypeSell: m.book(qt, model.EntrustBsTypeSell),
+	}
+	results := make([]*MatchResult, 0)
+	for _, entrust := range entrusts {
+		remain := entrust.Amount - entrust.DealAmount
+		levels, ok := books[entrust.EntrustBS]
+		if remain <= 0 || !ok {
+			continue
+		}
+		// 价格满足委托价的档位可成交数量
+		var available int64
+		for _, level := range levels {
+			if !isCross(entrust, level.price) {
+				break
+			}
+			available += level.amount
+		}
+		amount := fillAmount(remain, available)
+		if amount <= 0 {
+			continue
+		}
+		// 由优到劣逐档成交,扣减盘口挂单量
+		var balance float64
+		left := amount
+		for _, level := range levels {
+			if left == 0 {
+				break
+			}
+			take := level.amount
+			if take > left {
+				take = left
+			}
+			balance += level.price * float64(take)
+			level.amount -= take
+			left -= take
+		}
+		results = append(results, &MatchResult{Entrust: entrust, Amount: amount, Price: util.FloatRound(balance/float64(amount), 3)})
+	}
+	return results
+}
+
+// quoteSnapshot 行情成交量快照
+type quoteSnapshot struct {
+	totalVol    int64     // 成交量(手)
+	totalAmount float64   // 成交额(万)
+	time        time.Time // 记录时间
+}
+
+// quoteSnapshots 成交量参与撮合的行情快照,跨撮合周期保存
+type quoteSnapshots struct {
+	mu sync.Mutex
+	m  map[string]*quoteSnapshot
+}
+
+// volumeSnapshots 成交量参与撮合的行情快照
+var volumeSnapshots = &quoteSnapshots{m: make(map[string]*quoteSnapshot)}
+
+// swap 记录最新快照,返回上一次快照
+func (s *quoteSnapshots) swap(code string, snapshot *quoteSnapshot) (*quoteSnapshot, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	last, ok := s.m[code]
+	s.m[code] = snapshot
+	return last, ok
+}
+
+// volumeMatcher 成交量参与撮合:以两次行情之间的成交量按参与比例作为可成交数量,以区间成交均价(VWAP)成交
+type volumeMatcher struct {
+	pct       float64         // 参与比例
+	snapshots *quoteSnapshots // 行情快照
+}
+
+// Match 撮合
+func (m *volumeMatcher) Match(qt *model.TencentQuote, entrusts []*model.Entrust) []*MatchResult {
+	results := make([]*MatchResult, 0)
+	now := time.Now()
+	last, ok := m.snapshots.swap(qt.Code, &quoteSnapshot{totalVol: qt.TotalVol, totalAmount: qt.TotalAmount, time: now})
+	// 首次记录或快照过期,只记录起点
+	if !ok || now.Sub(last.time) > volumeSnapshotTTL {
+		return results
+	}
+	vol := (qt.TotalVol - last.totalVol) * 100
+	amount := (qt.TotalAmount - last.totalAmount) * 10000
+	if vol <= 0 || amount <= 0 {
+		return results
+	}
+	vwap := util.FloatRound(amount/float64(vol), 3)
+	capacity := int64(float64(vol) * m.pct)
+	for _, entrust := range entrusts {
+		remain := entrust.Amount - entrust.DealAmount
+		if remain <= 0 || !isCross(entrust, vwap) {
+			continue
+		}
+		deal := fillAmount(remain, capacity)
+		if deal <= 0 {
+			break
+		}
+		capacity -= deal
+		results = append(results, &MatchResult{Entrust: entrust, Amount: deal, Price: vwap})
+	}
+	return results
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"stock/api-gateway/model"
+)
+
+func TestLastPriceMatcher(t *testing.T) {
+	m := NewMatcher(&model.SysParam{})
+	buy := &model.Entrust{ID: 1, EntrustBS: model.EntrustBsTypeBuy, Price: 10, Amount: 1000}
+	sell := &model.Entrust{ID: 2, EntrustBS: model.EntrustBsTypeSell, Price: 10.5, Amount: 500}
+
+	results := m.Match(&model.TencentQuote{CurrentPrice: 10.2}, []*model.Entrust{buy, sell})
+	if len(results) != 0 {
+		t.Fatalf("expect no deal, got %d", len(results))
+	}
+	results = m.Match(&model.TencentQuote{CurrentPrice: 9.9}, []*model.Entrust{buy, sell})
+	if len(results) != 1 || results[0].Entrust != buy || results[0].Amount != 1000 || results[0].Price != 10 {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+}
+
+func TestOrderBookMatcher(t *testing.T) {
+	m := NewMatcher(&model.SysParam{MatchMode: model.MatchModeOrderBook})
+	qt := &model.TencentQuote{
+		SellPrice1: 10.00, SellVol1: 3,
+		SellPrice2: 10.01, SellVol2: 5,
+		SellPrice3: 10.02, SellVol3: 10,
+		BuyPrice1: 9.99, BuyVol1: 2,
+	}
+	// 时间优先:第一笔吃掉卖一和部分卖二,第二笔只剩卖二余量
+	first := &model.Entrust{ID: 1, EntrustBS: model.EntrustBsTypeBuy, Price: 10.01, Amount: 500}
+	second := &model.Entrust{ID: 2, EntrustBS: model.EntrustBsTypeBuy, Price: 10.01, Amount: 1000}
+	sell := &model.Entrust{ID: 3, EntrustBS: model.EntrustBsTypeSell, Price: 9.99, Amount: 350, DealAmount: 0}
+	results := m.Match(qt, []*model.Entrust{first, second, sell})
+	if len(results) != 3 {
+		t.Fatalf("expect 3 results, got %d", len(results))
+	}
+	if results[0].Amount != 500 || results[0].Price != 10.004 {
+		t.Fatalf("unexpected first: %+v", results[0])
+	}
+	if results[1].Amount != 300 || results[1].Price != 10.01 {
+		t.Fatalf("unexpected second: %+v", results[1])
+	}
+	// 深度不足时按整手部分成交
+	if results[2].Amount != 200 || results[2].Price != 9.99 {
+		t.Fatalf("unexpected sell: %+v", results[2])
+	}
+
+	// 剩余不足一手的零股可以一次成交
+	odd := &model.Entrust{ID: 4, EntrustBS: model.EntrustBsTypeSell, Price: 9.99, Amount: 350, DealAmount: 300}
+	results = m.Match(qt, []*model.Entrust{odd})
+	if len(results) != 1 || results[0].Amount != 50 {
+		t.Fatalf("unexpected odd: %+v", results)
+	}
+}
+
+func TestVolumeMatcher(t *testing.T) {
+	m := &volumeMatcher{pct: 0.1, snapshots: &quoteSnapshots{m: make(map[string]*quoteSnapshot)}}
+	buy := &model.Entrust{ID: 1, EntrustBS: model.EntrustBsTypeBuy, Price: 10.1, Amount: 1000}
+	sell := &model.Entrust{ID: 2, EntrustBS: model.EntrustBsTypeSell, Price: 10.1, Amount: 1000}
+
+	// 首次只记录起点
+	if results := m.Match(&model.TencentQuote{Code: "600000", TotalVol: 1000, TotalAmount: 100}, []*model.Entrust{buy, sell}); len(results) != 0 {
+		t.Fatalf("expect no deal, got %+v", results)
+	}
+	// 区间成交 50手、5万 => 均价10元,可成交 500股
+	results := m.Match(&model.TencentQuote{Code: "600000", TotalVol: 1050, TotalAmount: 105}, []*model.Entrust{buy, sell})
+	if len(results) != 1 || results[0].Entrust != buy || results[0].Amount != 500 || results[0].Price != 10 {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+
+	// 快照过期重新记录起点
+	m.snapshots.m["600000"].time = time.Now().Add(-2 * volumeSnapshotTTL)
+	if results := m.Match(&model.TencentQuote{Code: "600000", TotalVol: 2000, TotalAmount: 1000}, []*model.Entrust{buy}); len(results) != 0 {
+		t.Fatalf("expect no deal, got %+v", results)
+	}
+}
@@ -2,25 +2,23 @@ package service
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
-	"net/http"
 	"sort"
 	"stock/api-gateway/dao"
-	"stock/api-gateway/db"
-	"stock/api-gateway/id_gen"
 	"stock/api-gateway/model"
 	"stock/api-gateway/serr"
+	"stock/api-gateway/util"
 	"stock/common/log"
 	"stock/common/timeconv"
-	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
 // MyService 服务
 type MyService struct {
+	core           *Core
+	ledger         *LedgerService
+	withdrawPolicy *WithdrawPolicyService
 }
 
 var (
@@ -31,7 +29,7 @@ var (
 // MyServiceInstance 实例
 func MyServiceInstance() *MyService {
 	myOnce.Do(func() {
-		myService = &MyService{}
+		myService = defaultServices().My
 	})
 	return myService
 }
@@ -47,32 +45,52 @@ func (s *MyService) WithdrawCommit(ctx context.Context, uid int64, money float64
 	if err != nil || !ok {
 		return serr.ErrBusiness("验证码错误")
 	}
+	// 冻结资金:锁定用户后校验提现风控,避免并发申请绕过每日、每周限额
+	tx := s.core.Tx.Begin(ctx)
+	defer tx.Rollback()
+	user, err = s.core.User.GetUserByUIDForUpdateWithTx(tx, uid)
+	if err != nil {
+		return err
+	}
+	transfers, err := s.core.Transfer.GetByUid(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if bankNo, err = unmaskBankNo(bankNo, user, transfers); err != nil {
+		return err
+	}
+	if err := s.withdrawPolicy.Check(ctx, user, money, name, bankNo); err != nil {
+		return err
+	}
 	if user.Money < money {
 		return serr.ErrBusiness("转出金额大于可提现金额")
 	}
-	// 冻结资金
-	tx := db.StockDB().WithContext(ctx).Begin()
-	defer tx.Rollback()
 
 	user.Money = user.Money - money
 	user.FreezeMoney += money
-	if err := dao.UserDaoInstance().UpdateUserWithTx(tx, user); err != nil {
+	if err := s.core.User.UpdateUserWithTx(tx, user); err != nil {
 		log.Errorf("变更用户资金失败:%+v", err)
 		return serr.ErrBusiness("资金转出失败")
 	}
 
-	if err := dao.TransferDaoInstance().CreateWithTx(tx, &model.Transfer{
+	transfer := &model.Transfer{
 		UID:       uid,                          // 用户ID
 		OrderTime: time.Now(),                   // 订单时间
 		Money:     money,                        // 金额
 		Type:      model.TransferTypeWithdraw,   // 类型：1充值 2提现
 		Status:    model.TransferStatusWaitExam, // 状态:0预插入 1待审核 2成功 3失败
-		Name:      name,                         // 提现收款人
-		BankNo:    bankNo,                       // 提现银行卡号
-	}); err != nil {
+		Name:      strings.TrimSpace(name),      // 提现收款人
+		BankNo:    model.Secret(bankNo),         // 提现银行卡号
+	}
+	if err := s.core.Transfer.CreateWithTx(tx, transfer); err != nil {
 		log.Errorf("CreateWithTx:%+v", err)
 		return serr.ErrBusiness("转出失败")
 	}
+	journal := model.NewJournal(model.LedgerBizWithdrawApply, transfer.ID, "提现申请,冻结资金").
+		Move(model.WalletAccount(uid), model.FreezeAccount(uid), money)
+	if err := s.ledger.PostWithTx(tx, journal); err != nil {
+		return serr.ErrBusiness("转出失败")
+	}
 	if err := tx.Commit().Error; err != nil {
 		log.Errorf("事务提交失败:%+v", err)
 		return err
@@ -84,44 +102,51 @@ func (s *MyService) WithdrawCommit(ctx context.Context, uid int64, money float64
 	return nil
 }
 
-// Withdraw 提现初始化:收款人、收款银行卡号、可提现金额
+// Withdraw 提现初始化:收款人、脱敏后的收款银行卡号、可提现金额
 func (s *MyService) Withdraw(ctx context.Context, uid int64) (string, string, float64, error) {
 	// 查询以前是否提现成功过
 	list, err := dao.TransferDaoInstance().GetByUid(ctx, uid)
 	if err != nil {
 		return "", "", 0, err
 	}
+	user, err := dao.UserDaoInstance().GetUserByUID(ctx, uid)
+	if err != nil {
+		return "", "", 0, err
+	}
+	name, bankNo := withdrawCard(user, list)
+	return name, util.MaskBankNo(bankNo), user.Money, nil
+}
+
+// withdrawCard 提现页面预填的收款人及银行卡号:收款人须与实名一致,已绑定银行卡的只能提现到绑定的银行卡,
+// 未绑定的取以前提现成功的银行卡
+func withdrawCard(user *model.User, transfers []*model.Transfer) (string, string) {
 	var name, bankNo string
-	for _, it := range list {
+	for _, it := range transfers {
 		if it.Type == model.TransferTypeWithdraw && it.Status == model.TransferStatusSuccess {
 			name = it.Name
-			bankNo = it.BankNo
+			bankNo = it.BankNo.String()
 			break
 		}
 	}
-
-	user, err := dao.UserDaoInstance().GetUserByUID(ctx, uid)
-	if err != nil {
-		return "", "", 0, err
+	if len(user.Name) > 0 {
+		name = user.Name
 	}
-	return name, bankNo, user.Money, nil
+	if len(user.BankNumber) > 0 {
+		bankNo = user.BankNumber.String()
+	}
+	return name, bankNo
 }
 
-func (s *MyService) RechargeQrcodeCommit(ctx context.Context, uid int64, money float64, orderNo string) error {
-	if err := s.isExistsCommit(ctx, uid); err != nil {
-		return err
+// unmaskBankNo 提现页面展示的是脱敏后的预填银行卡号,与之一致时还原为完整卡号;仍含脱敏字符的卡号拒绝
+func unmaskBankNo(bankNo string, user *model.User, transfers []*model.Transfer) (string, error) {
+	bankNo = strings.TrimSpace(bankNo)
+	if _, card := withdrawCard(user, transfers); len(card) > 0 && bankNo == util.MaskBankNo(card) {
+		bankNo = card
 	}
-	if err := dao.TransferDaoInstance().Create(ctx, &model.Transfer{
-		UID:       uid,
-		OrderTime: time.Now(),
-		Money:     money,
-		Type:      model.TransferTypeRecharge,
-		Channel:   "扫码支付",
-		Status:    model.TransferStatusWaitExam,
-	}); err != nil {
-		return serr.ErrBusiness("转入错误")
+	if strings.Contains(bankNo, "*") {
+		return "", serr.ErrBusiness("银行卡号错误")
 	}
-	return nil
+	return bankNo, nil
 }
 
 // RechargeQrcode 二维码初始化
@@ -140,71 +165,7 @@ func (s *MyService) RechargeQrcode(ctx context.Context, uid int64, money float64
 	//	log.Errorf("json.Unmarshal error: %v", err)
 	//	return &model.Data{}
 	//}
-	return "https://test-1252629308.cos.ap-guangzhou.myqcloud.com/122.png", strconv.FormatInt(id_gen.GetNextID(), 10), nil
-}
-
-// isExistsCommit 是否存在转入提交
-func (s *MyService) isExistsCommit(ctx context.Context, uid int64) error {
-	list, err := dao.TransferDaoInstance().GetByUid(ctx, uid)
-	if err != nil {
-		return err
-	}
-	// 检查是否允许用户提交
-	for _, it := range list {
-		// 非待审核状态,充值则跳过
-		if it.Status != model.TransferStatusWaitExam || it.Type != model.TransferTypeRecharge {
-			continue
-		}
-		if timeconv.TimeToInt32(it.OrderTime) == timeconv.TimeToInt32(time.Now()) {
-			return serr.ErrBusiness("您有一笔订单处于待审核状态,请稍后再提交。")
-		}
-	}
-	return nil
-}
-
-// RechargeBankCommit 银行转入资金提交
-func (s *MyService) RechargeBankCommit(ctx context.Context, uid int64, money float64) error {
-	if err := s.isExistsCommit(ctx, uid); err != nil {
-		return err
-	}
-	if err := dao.TransferDaoInstance().Create(ctx, &model.Transfer{
-		UID:       uid,
-		OrderTime: time.Now(),
-		Money:     money,
-		Type:      model.TransferTypeRecharge,
-		Channel:   "银行卡",
-		Status:    model.TransferStatusWaitExam, // 等待审核
-	}); err != nil {
-		log.Errorf("Create err:%+v", err)
-		return serr.ErrBusiness("转入资金错误")
-	}
-	return nil
-}
-
-func (s *MyService) RechargeAlipay(ctx context.Context, uid int64, money float64) (string, error) {
-	sys, err := dao.SysDaoInstance().GetSysParam(ctx)
-	if err != nil {
-		return "", err
-	}
-	if !sys.AlipayChannel {
-		return "", serr.ErrBusiness("转入资金渠道未开放")
-	}
-	orderNo := strconv.FormatInt(id_gen.GetNextID(), 10)
-	if err := dao.TransferDaoInstance().Create(ctx, &model.Transfer{
-		UID:       uid,                        // 用户ID
-		OrderTime: time.Now(),                 // 订单时间
-		Money:     money,                      // 金额
-		Type:      model.TransferTypeRecharge, // 类型：1充值 2提现
-		Status:    model.TransferStatusPre,    // 状态:0预插入 1待审核 2成功 3失败
-		Name:      "",                         // 提现收款人
-		Channel:   "支付宝",
-		BankNo:    "",      // 提现银行卡号
-		OrderNo:   orderNo, // 订单号流水
-	}); err != nil {
-		log.Errorf("插入转账记录表失败:%+v", err)
-		return "", serr.ErrBusiness("渠道错误")
-	}
-	return AlipayServiceInstance().GetAlipayURL(orderNo, money)
+	return qrcodeImage, s.core.OrderNo.NextOrderNo(), nil
 }
 
 func (s *MyService) Balance(ctx context.Context, uid int64) ([]*model.MyBalance, float64, error) {
@@ -227,6 +188,12 @@ func (s *MyService) Balance(ctx context.Context, uid int64) ([]*model.MyBalance,
 		switch it.Type {
 		case model.TransferTypeWithdraw, model.TransferTypeAppendMoney, model.TransferTypeExpandMoney, model.TransferTypeCreateContract:
 			it.Money *= -1
+		case model.TransferTypeRepoLend:
+			// 逆回购撤单,资金已返还
+			if it.Status == model.TransferStatusFail {
+				continue
+			}
+			it.Money *= -1
 		}
 		balance = append(balance, &model.MyBalance{
 			Title:   it.TransferConvertTitle(),
@@ -237,51 +204,6 @@ func (s *MyService) Balance(ctx context.Context, uid int64) ([]*model.MyBalance,
 	return balance, user.Money, nil
 }
 
-func (s *MyService) Authentication(ctx context.Context, uid int64, name, idNo string) error {
-	user, err := dao.UserDaoInstance().GetUserByUID(ctx, uid)
-	if err != nil {
-		return err
-	}
-	if len(user.Name) > 0 && len(user.ICCID) > 0 {
-		return serr.ErrBusiness("已实名认证")
-	}
-	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("https://idcardcert.market.alicloudapi.com/idCardCert?idCard=%s&name=%s", idNo, name), nil)
-	if err != nil {
-		log.Errorf("NewRequest err:%+v", err)
-		return fmt.Errorf("验证码发送失败")
-	}
-	req.Header.Add("Authorization", "APPCODE xxxxxxxxxxxxxxxxxxxxxgi")
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return err
-	}
-	type t struct {
-		Status string `json:"status"`
-		Msg    string `json:"msg"`
-	}
-	result := &t{}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return err
-	}
-	if result.Status != "01" {
-		return serr.ErrBusiness(result.Msg)
-	}
-	// 更新用户信息到数据库表
-	user.Name = name
-	user.ICCID = idNo
-	if err := dao.UserDaoInstance().CreateUser(ctx, user); err != nil {
-		log.Errorf("CreateUser err:%+v", err)
-		return serr.ErrBusiness("实名认证失败")
-	}
-	return nil
-}
-
 func (s *MyService) Msg(ctx context.Context, uid int64) ([]*model.MyMsg, error) {
 	list, err := dao.MsgDaoInstance().GetByUid(ctx, uid)
 	if err != nil {
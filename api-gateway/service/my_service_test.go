@@ -0,0 +1,34 @@
+package service
+
+import (
+	"testing"
+
+	"stock/api-gateway/model"
+)
+
+// TestUnmaskBankNo 提现页面预填的脱敏卡号还原为完整卡号:已绑定取绑定卡,未绑定取以前提现成功的卡
+func TestUnmaskBankNo(t *testing.T) {
+	transfers := []*model.Transfer{
+		{Type: model.TransferTypeWithdraw, Status: model.TransferStatusSuccess, Name: "张三", BankNo: "6222020000001234"},
+	}
+	tests := []struct {
+		name   string
+		bankNo string
+		bound  string
+		want   string
+		ok     bool
+	}{
+		{"bound card", "6222********5678", "6222020000005678", "6222020000005678", true},
+		{"previous card", "6222********1234", "", "6222020000001234", true},
+		{"full card", "6228480000009999", "", "6228480000009999", true},
+		{"mask of other card", "6228********9999", "", "", false},
+		{"previous card mask when bound", "6222********1234", "6222020000005678", "", false},
+	}
+	for _, tt := range tests {
+		user := &model.User{BankNumber: model.Secret(tt.bound)}
+		got, err := unmaskBankNo(tt.bankNo, user, transfers)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("%s: expect %s %v, got %s %+v", tt.name, tt.want, tt.ok, got, err)
+		}
+	}
+}
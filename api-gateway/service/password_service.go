@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"stock/api-gateway/dao"
+	"stock/api-gateway/serr"
+	"stock/api-gateway/util"
+	"stock/common/log"
+	"sync"
+	"unicode"
+)
+
+// defaultPasswordMinLen 系统参数未配置时的密码最小长度
+const defaultPasswordMinLen = 6
+
+// PasswordService 密码服务:密码hash以及密码策略校验
+type PasswordService struct {
+}
+
+var (
+	passwordService *PasswordService
+	passwordOnce    sync.Once
+)
+
+// PasswordServiceInstance 实例
+func PasswordServiceInstance() *PasswordService {
+	passwordOnce.Do(func() {
+		passwordService = &PasswordService{}
+	})
+	return passwordService
+}
+
+// Hash 生成密码hash
+func (s *PasswordService) Hash(password string) (string, error) {
+	hashed, err := util.HashPassword(password)
+	if err != nil {
+		log.Errorf("HashPassword err:%+v", err)
+		return "", serr.ErrBusiness("密码设置失败")
+	}
+	return hashed, nil
+}
+
+// Verify 校验密码,返回是否匹配;若库中为明文或hash强度过低,则返回新的hash用于升级
+func (s *PasswordService) Verify(stored, password string) (bool, string) {
+	ok, upgrade := util.ComparePassword(stored, password)
+	if !ok || !upgrade {
+		return ok, ""
+	}
+	hashed, err := util.HashPassword(password)
+	if err != nil {
+		log.Errorf("HashPassword err:%+v", err)
+		return true, ""
+	}
+	return true, hashed
+}
+
+// CheckPolicy 按系统参数配置的密码策略校验密码
+func (s *PasswordService) CheckPolicy(ctx context.Context, userName, password string) error {
+	sys, err := dao.SysDaoInstance().GetSysParam(ctx)
+	if err != nil {
+		return err
+	}
+	minLen := int(sys.PasswordMinLen)
+	if minLen <= 0 {
+		minLen = defaultPasswordMinLen
+	}
+	if len(password) < minLen {
+		return serr.ErrBusiness(fmt.Sprintf("密码长度不能低于%d位", minLen))
+	}
+	if len(password) > util.PasswordMaxLen {
+		return serr.ErrBusiness(fmt.Sprintf("密码长度不能超过%d位", util.PasswordMaxLen))
+	}
+	if password == userName {
+		return serr.ErrBusiness("密码不能与账号相同")
+	}
+	var hasLetter, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case r > unicode.MaxASCII || unicode.IsSpace(r):
+			return serr.ErrBusiness("密码只能包含字母、数字和特殊字符")
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		default:
+			hasSymbol = true
+		}
+	}
+	if sys.PasswordRequireLetter && !hasLetter {
+		return serr.ErrBusiness("密码必须包含字母")
+	}
+	if sys.PasswordRequireDigit && !hasDigit {
+		return serr.ErrBusiness("密码必须包含数字")
+	}
+	if sys.PasswordRequireSymbol && !hasSymbol {
+		return serr.ErrBusiness("密码必须包含特殊字符")
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"stock/api-gateway/model"
+	"stock/api-gateway/serr"
+	"stock/common/env"
+	"stock/common/log"
+	"strconv"
+	"time"
+
+	"github.com/smartwalle/alipay/v3"
+)
+
+const (
+	alipayNotifyExpire  = 26 * time.Hour        // 异步通知有效期:支付宝在25小时内重试通知
+	alipayTradeNotExist = "ACQ.TRADE_NOT_EXIST" // 支付宝交易不存在:用户未打开支付页面
+)
+
+// alipayChannel 支付宝电脑网站支付,应用ID及密钥为空时读取配置,指定时使用沙箱环境
+type alipayChannel struct {
+	appID      string // 应用ID
+	privateKey string // 应用私钥
+	publicKey  string // 支付宝公钥
+}
+
+// Code 渠道编码
+func (p *alipayChannel) Code() string {
+	return "alipay"
+}
+
+// Name 渠道名称
+func (p *alipayChannel) Name() string {
+	return model.TransferChannelAlipay
+}
+
+// Enabled 支付宝唤醒支付是否开启
+func (p *alipayChannel) Enabled(sys *model.SysParam) bool {
+	return sys.AlipayChannel
+}
+
+// client 支付宝客户端及应用ID
+func (p *alipayChannel) client() (*alipay.Client, string, error) {
+	appID, privateKey, aliPublicKey := p.appID, p.privateKey, p.publicKey
+	isProd := false
+	if len(appID) == 0 {
+		var ok bool
+		isProd = env.GlobalEnv().IsProd()
+		if appID, ok = env.GlobalEnv().Get("APPID"); !ok {
+			log.Errorf("no APPID config")
+			return nil, "", serr.ErrBusiness("渠道错误")
+		}
+		if privateKey, ok = env.GlobalEnv().Get("PRIVATEKEY"); !ok { // 应用私钥
+			log.Errorf("no PRIVATEKEY config")
+			return nil, "", serr.ErrBusiness("渠道错误")
+		}
+		if aliPublicKey, ok = env.GlobalEnv().Get("PUBLICKEY"); !ok { // 支付宝公钥
+			log.Errorf("no PUBLICKEY config")
+			return nil, "", serr.ErrBusiness("渠道错误")
+		}
+	}
+	client, err := alipay.New(appID, privateKey, isProd)
+	if err != nil {
+		log.Errorf("alipay New err:%+v", err)
+		return nil, "", err
+	}
+	if err := client.LoadAliPayPublicKey(aliPublicKey); err != nil {
+		log.Errorf("LoadAliPayPublicKey err:%+v", err)
+		return nil, "", err
+	}
+	return client, appID, nil
+}
+
+// CreateOrder 生成支付宝支付链接,订单预插入,支付结果以异步通知或主动查询为准
+func (p *alipayChannel) CreateOrder(ctx context.Context, transfer *model.Transfer) (*model.PaymentOrder, error) {
+	client, _, err := p.client()
+	if err != nil {
+		return nil, err
+	}
+	localIP, ok := env.GlobalEnv().Get("IP") // 获取本机外网IP
+	if !ok {
+		log.Errorf("no IP config")
+		return nil, serr.ErrBusiness("渠道错误")
+	}
+	pay := alipay.TradePagePay{}
+	pay.NotifyURL = fmt.Sprintf("http://%s:8080/alipay/callback", localIP) //支付结果通知
+	pay.Subject = "trade"
+	pay.OutTradeNo = transfer.OrderNo
+	pay.TotalAmount = strconv.FormatFloat(transfer.Money, 'f', 2, 64)
+	pay.ProductCode = "FAST_INSTANT_TRADE_PAY"
+	alipayURL, err := client.TradePagePay(pay)
+	if err != nil {
+		log.Errorf("TradePagePay err:%+v", err)
+		return nil, serr.ErrBusiness("渠道错误")
+	}
+	return &model.PaymentOrder{
+		Status: model.TransferStatusPre,
+		Data:   map[string]interface{}{"url": alipayURL.String()},
+	}, nil
+}
+
+// Query 调用alipay.trade.query查询交易状态,交易不存在视为待支付
+func (p *alipayChannel) Query(ctx context.Context, transfer *model.Transfer) (*model.PaymentResult, error) {
+	client, _, err := p.client()
+	if err != nil {
+		return nil, err
+	}
+	rsp, err := client.TradeQuery(alipay.TradeQuery{OutTradeNo: transfer.OrderNo})
+	if err != nil {
+		log.Errorf("支付宝订单[%s]查询失败:%+v", transfer.OrderNo, err)
+		return nil, err
+	}
+	result := &model.PaymentResult{Status: model.PaymentStatusPending, OrderNo: transfer.OrderNo}
+	if !rsp.IsSuccess() {
+		if rsp.Content.SubCode != alipayTradeNotExist {
+			log.Errorf("支付宝订单[%s]查询失败:%s", transfer.OrderNo, rsp.Content.SubCode)
+			return nil, serr.ErrBusiness("渠道查询失败")
+		}
+		return result, nil
+	}
+	result.TradeNo = rsp.Content.TradeNo
+	result.Status = alipayStatus(rsp.Content.TradeStatus)
+	if result.Money, err = strconv.ParseFloat(rsp.Content.TotalAmount, 64); err != nil {
+		log.Errorf("支付宝订单[%s]金额错误:%s", transfer.OrderNo, rsp.Content.TotalAmount)
+		return nil, serr.ErrBusiness("金额错误")
+	}
+	return result, nil
+}
+
+// VerifyCallback 支付宝公钥验签,校验应用ID与通知时间
+func (p *alipayChannel) VerifyCallback(ctx context.Context, req *http.Request) (*model.PaymentResult, error) {
+	client, appID, err := p.client()
+	if err != nil {
+		return nil, err
+	}
+	noti, err := client.GetTradeNotification(req)
+	if err != nil {
+		log.Errorf("支付宝通知验签失败:%+v", err)
+		return nil, serr.ErrBusiness("验签失败")
+	}
+	log.Infof("支付宝通知:%+v", noti)
+	if noti.AppId != appID {
+		log.Errorf("支付宝通知应用ID不一致:%s", noti.AppId)
+		return nil, serr.ErrBusiness("应用ID不一致")
+	}
+	notifyTime, err := time.ParseInLocation("2006-01-02 15:04:05", noti.NotifyTime, time.Local)
+	if err != nil || time.Since(notifyTime) > alipayNotifyExpire {
+		log.Errorf("支付宝通知已过期:%s", noti.NotifyTime)
+		return nil, serr.ErrBusiness("通知已过期")
+	}
+	money, err := strconv.ParseFloat(noti.TotalAmount, 64)
+	if err != nil {
+		log.Errorf("支付宝订单[%s]金额错误:%s", noti.OutTradeNo, noti.TotalAmount)
+		return nil, serr.ErrBusiness("金额错误")
+	}
+	return &model.PaymentResult{
+		Status:   alipayStatus(noti.TradeStatus),
+		OrderNo:  noti.OutTradeNo,
+		TradeNo:  noti.TradeNo,
+		Money:    money,
+		NotifyID: noti.NotifyId,
+	}, nil
+}
+
+// Refund 支付宝原路退款,以订单号加退款金额作为退款请求号,重复请求不会重复退款
+func (p *alipayChannel) Refund(ctx context.Context, transfer *model.Transfer, money float64, reason string) error {
+	client, _, err := p.client()
+	if err != nil {
+		return err
+	}
+	amount := strconv.FormatFloat(money, 'f', 2, 64)
+	rsp, err := client.TradeRefund(alipay.TradeRefund{
+		OutTradeNo:   transfer.OrderNo,
+		RefundAmount: amount,
+		RefundReason: reason,
+		OutRequestNo: fmt.Sprintf("%s_%s", transfer.OrderNo, amount),
+	})
+	if err != nil {
+		log.Errorf("支付宝订单[%s]退款失败:%+v", transfer.OrderNo, err)
+		return err
+	}
+	if !rsp.IsSuccess() {
+		log.Errorf("支付宝订单[%s]退款失败:%s", transfer.OrderNo, rsp.Content.SubMsg)
+		return serr.ErrBusiness("退款失败")
+	}
+	return nil
+}
+
+// alipayStatus 支付宝交易状态转换为渠道支付状态
+func alipayStatus(status alipay.TradeStatus) int64 {
+	switch status {
+	case alipay.TradeStatusSuccess, alipay.TradeStatusFinished:
+		return model.PaymentStatusPaid
+	case alipay.TradeStatusClosed:
+		return model.PaymentStatusClosed
+	}
+	return model.PaymentStatusPending
+}
+
+// getPublicIP 获取本地外网
+func (p *alipayChannel) getPublicIP() (string, error) {
+	// 获取本机外网地址
+	as, err := net.InterfaceAddrs()
+	if err != nil {
+		return "", err
+	}
+	for _, a := range as {
+		ipNet, ok := a.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() {
+			continue
+		}
+		ip := ipNet.IP.To4()
+		if ip != nil &&
+			(ip[0] != 10 && ip[0] != 172 && ip[0] != 192 && ip[1] != 168) {
+			return ip.String(), nil
+		}
+	}
+	return "", serr.ErrBusiness("no found valid ip")
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"stock/api-gateway/model"
+	"sync"
+)
+
+// PaymentChannel 充值渠道
+type PaymentChannel interface {
+	// Code 渠道编码,客户端按编码选择渠道
+	Code() string
+	// Name 渠道名称,记录在充值订单的channel字段
+	Name() string
+	// Enabled 系统参数中是否开启
+	Enabled(sys *model.SysParam) bool
+	// CreateOrder 渠道下单,transfer已填写用户、金额、手续费与订单号,返回订单初始状态及客户端支付所需信息
+	CreateOrder(ctx context.Context, transfer *model.Transfer) (*model.PaymentOrder, error)
+	// Query 向渠道查询订单支付结果
+	Query(ctx context.Context, transfer *model.Transfer) (*model.PaymentResult, error)
+	// VerifyCallback 校验渠道异步通知并解析支付结果
+	VerifyCallback(ctx context.Context, req *http.Request) (*model.PaymentResult, error)
+	// Refund 原路退款
+	Refund(ctx context.Context, transfer *model.Transfer, money float64, reason string) error
+}
+
+var (
+	paymentChannels = []PaymentChannel{
+		&alipayChannel{},
+		&bankChannel{},
+		&qrcodeChannel{},
+	}
+	paymentChannelMu sync.RWMutex
+)
+
+// RegisterPaymentChannel 注册充值渠道,编码相同则替换
+func RegisterPaymentChannel(channel PaymentChannel) {
+	paymentChannelMu.Lock()
+	defer paymentChannelMu.Unlock()
+	for i, it := range paymentChannels {
+		if it.Code() == channel.Code() {
+			paymentChannels[i] = channel
+			return
+		}
+	}
+	paymentChannels = append(paymentChannels, channel)
+}
+
+// PaymentChannels 已注册的充值渠道
+func PaymentChannels() []PaymentChannel {
+	paymentChannelMu.RLock()
+	defer paymentChannelMu.RUnlock()
+	return append([]PaymentChannel{}, paymentChannels...)
+}
+
+// PaymentChannelByCode 按渠道编码查找充值渠道
+func PaymentChannelByCode(code string) (PaymentChannel, bool) {
+	for _, it := range PaymentChannels() {
+		if it.Code() == code {
+			return it, true
+		}
+	}
+	return nil, false
+}
+
+// paymentChannelByName 按充值订单记录的渠道名称查找充值渠道
+func paymentChannelByName(name string) (PaymentChannel, bool) {
+	for _, it := range PaymentChannels() {
+		if it.Name() == name {
+			return it, true
+		}
+	}
+	return nil, false
+}
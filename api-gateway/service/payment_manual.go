@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"stock/api-gateway/dao"
+	"stock/api-gateway/model"
+	"stock/api-gateway/serr"
+)
+
+const (
+	qrcodeImage = "https://test-1252629308.cos.ap-guangzhou.myqcloud.com/122.png" // 扫码转账收款码
+)
+
+// manualChannel 人工转账:用户线下转账后提交,待后台审核入账,无异步通知与主动查询
+type manualChannel struct {
+}
+
+// Query 人工转账以后台审核为准,始终待支付
+func (p *manualChannel) Query(ctx context.Context, transfer *model.Transfer) (*model.PaymentResult, error) {
+	return &model.PaymentResult{Status: model.PaymentStatusPending, OrderNo: transfer.OrderNo}, nil
+}
+
+// VerifyCallback 人工转账无异步通知
+func (p *manualChannel) VerifyCallback(ctx context.Context, req *http.Request) (*model.PaymentResult, error) {
+	return nil, serr.ErrBusiness("渠道不支持通知")
+}
+
+// Refund 人工转账须线下退款
+func (p *manualChannel) Refund(ctx context.Context, transfer *model.Transfer, money float64, reason string) error {
+	return serr.ErrBusiness("人工转账请线下退款")
+}
+
+// bankChannel 银行卡转账,收款银行卡读取系统参数,未指定时使用MySQL
+type bankChannel struct {
+	manualChannel
+	sys dao.SysStore
+}
+
+// Code 渠道编码
+func (p *bankChannel) Code() string {
+	return "bank"
+}
+
+// Name 渠道名称
+func (p *bankChannel) Name() string {
+	return model.TransferChannelBank
+}
+
+// Enabled 银行卡收款渠道是否开启
+func (p *bankChannel) Enabled(sys *model.SysParam) bool {
+	return sys.BankChannel
+}
+
+// CreateOrder 提交转账申请,待审核,返回收款银行卡
+func (p *bankChannel) CreateOrder(ctx context.Context, transfer *model.Transfer) (*model.PaymentOrder, error) {
+	store := p.sys
+	if store == nil {
+		store = dao.SysDaoInstance()
+	}
+	sys, err := store.GetSysParam(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &model.PaymentOrder{
+		Status: model.TransferStatusWaitExam,
+		Data: map[string]interface{}{
+			"bank_no": sys.BankNo,
+			"name":    sys.BankName,
+			"address": sys.BankAddr,
+		},
+	}, nil
+}
+
+// qrcodeChannel 扫码转账
+type qrcodeChannel struct {
+	manualChannel
+}
+
+// Code 渠道编码
+func (p *qrcodeChannel) Code() string {
+	return "qrcode"
+}
+
+// Name 渠道名称
+func (p *qrcodeChannel) Name() string {
+	return model.TransferChannelQrcode
+}
+
+// Enabled 二维码收款渠道是否开启
+func (p *qrcodeChannel) Enabled(sys *model.SysParam) bool {
+	return sys.QrcodeChannel
+}
+
+// CreateOrder 提交转账申请,待审核,返回收款码
+func (p *qrcodeChannel) CreateOrder(ctx context.Context, transfer *model.Transfer) (*model.PaymentOrder, error) {
+	return &model.PaymentOrder{
+		Status: model.TransferStatusWaitExam,
+		Data:   map[string]interface{}{"img": qrcodeImage},
+	}, nil
+}
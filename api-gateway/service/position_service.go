@@ -2,20 +2,15 @@ package service
 
 import (
 	"context"
-	"fmt"
-	"stock/api-gateway/dao"
-	"stock/api-gateway/db"
 	"stock/api-gateway/model"
-	"stock/api-gateway/quote"
 	"stock/api-gateway/serr"
 	"stock/common/log"
-	"stock/common/timeconv"
 	"sync"
-	"time"
 )
 
 // PositionService 持仓服务
 type PositionService struct {
+	core *Core
 }
 
 var (
@@ -26,41 +21,17 @@ var (
 // PositionServiceInstance PositionServiceInstance实例
 func PositionServiceInstance() *PositionService {
 	positionOnce.Do(func() {
-		positionService = &PositionService{}
-
-		ctx := context.Background()
-
-		// 解冻所有的股票
-		go func() {
-			for range time.Tick(5 * time.Second) {
-				if !CalendarServiceInstance().IsTradeDate(ctx) || time.Now().Hour() < 16 || time.Now().Minute() < 30 {
-					continue
-				}
-				// 检查redis是否已经执行过
-				key := fmt.Sprintf("position_unfreeze_amount_task_%v", timeconv.TimeToInt32(time.Now()))
-				if db.RedisClient().Get(ctx, key).Val() == "1" {
-					continue
-				}
-				if err := positionService.unFreezeStockTask(ctx); err != nil {
-					log.Errorf("unFreezeStock err:%+v", err)
-					continue
-				}
-				if err := db.RedisClient().Set(ctx, key, "1", -1).Err(); err != nil {
-					log.Errorf("设置自动撤单失败:%+v", err)
-				}
-			}
-		}()
-
+		positionService = defaultServices().Position
 	})
 	return positionService
 }
 
-// unFreezeStockTask 交易日16:30解冻股票
-func (s *PositionService) unFreezeStockTask(ctx context.Context) error {
-	list, err := dao.PositionDaoInstance().GetPositions(ctx)
+// UnfreezeAll 解冻全部持仓股票:由定时任务交易日16:30执行
+func (s *PositionService) UnfreezeAll(ctx context.Context) error {
+	list, err := s.core.Position.GetPositions(ctx)
 	if err != nil {
 		log.Errorf("查询持仓失败:%+v", err)
-		return nil
+		return err
 	}
 	if len(list) == 0 {
 		return nil
@@ -69,9 +40,9 @@ func (s *PositionService) unFreezeStockTask(ctx context.Context) error {
 	for _, item := range list {
 		position := item
 		position.FreezeAmount = 0
-		if err := dao.PositionDaoInstance().Update(ctx, position); err != nil {
+		if err := s.core.Position.Update(ctx, position); err != nil {
 			log.Errorf("解冻股票失败:%+v", err)
-			return nil
+			return err
 		}
 	}
 	log.Infof("解冻股数任务完成!")
@@ -80,7 +51,7 @@ func (s *PositionService) unFreezeStockTask(ctx context.Context) error {
 
 // getPositionQuote 查询持仓数据刷新行情缓存
 func (s *PositionService) getPositionQuote(ctx context.Context) error {
-	position, err := dao.PositionDaoInstance().GetPositions(ctx)
+	position, err := s.core.Position.GetPositions(ctx)
 	if err != nil {
 		return err
 	}
@@ -88,7 +59,7 @@ func (s *PositionService) getPositionQuote(ctx context.Context) error {
 	for _, it := range position {
 		codes = append(codes, it.StockCode)
 	}
-	if _, err := quote.QtServiceInstance().GetQuoteByTencent(codes); err != nil {
+	if _, err := s.core.Quote.GetQuoteByTencent(codes); err != nil {
 		return err
 	}
 	return nil
@@ -96,7 +67,7 @@ func (s *PositionService) getPositionQuote(ctx context.Context) error {
 
 // GetPositionByContractID 根据合约ID查询持仓
 func (s *PositionService) GetPositionByContractID(ctx context.Context, contractID int64) ([]*model.Position, error) {
-	positions, err := dao.PositionDaoInstance().GetPositionByContractID(ctx, contractID)
+	positions, err := s.core.Position.GetPositionByContractID(ctx, contractID)
 	if err != nil {
 		log.Errorf("GetPositionByContractID err:%+v", err)
 		return nil, err
@@ -109,7 +80,7 @@ func (s *PositionService) GetPositionByContractID(ctx context.Context, contractI
 
 // GetPositionByEntrustID 通过持仓编号查询持仓记录
 func (s *PositionService) GetPositionByEntrustID(ctx context.Context, entrustID int64) (*model.Position, error) {
-	position, err := dao.PositionDaoInstance().GetPositionByEntrustID(ctx, entrustID)
+	position, err := s.core.Position.GetPositionByEntrustID(ctx, entrustID)
 	if err != nil {
 		log.Errorf("GetPositionByEntrustID err:%+v", err)
 		return nil, err
@@ -131,7 +102,7 @@ func (s *PositionService) setCurPrice(positions []*model.Position) ([]*model.Pos
 	for _, it := range positions {
 		codes = append(codes, it.StockCode)
 	}
-	qts, err := quote.QtServiceInstance().GetQuoteByTencent(codes)
+	qts, err := s.core.Quote.GetQuoteByTencent(codes)
 	if err != nil {
 		log.Errorf("GetQuoteByTencent err:%+v", err)
 		return nil, err
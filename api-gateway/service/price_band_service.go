@@ -0,0 +1,68 @@
+package service
+
+import (
+	"fmt"
+	"stock/api-gateway/model"
+	"stock/api-gateway/serr"
+	"stock/api-gateway/util"
+	"sync"
+)
+
+// PriceBandService 涨跌停价格区间服务
+type PriceBandService struct {
+	core *Core
+}
+
+var (
+	priceBandService *PriceBandService
+	priceBandOnce    sync.Once
+)
+
+// PriceBandServiceInstance 实例
+func PriceBandServiceInstance() *PriceBandService {
+	priceBandOnce.Do(func() {
+		priceBandService = defaultServices().PriceBand
+	})
+	return priceBandService
+}
+
+// Band 根据昨收价和所属板块计算涨跌停价格区间:主板±10%,ST±5%,创业板/科创板±20%,北交所±30%
+func (s *PriceBandService) Band(qt *model.TencentQuote) *model.PriceBand {
+	band := &model.PriceBand{
+		StockCode:  qt.Code,
+		ClosePrice: qt.ClosePrice,
+		LimitPct:   util.PriceLimitPct(qt.Code, qt.Name),
+	}
+	if util.IsZero(band.LimitPct) {
+		return band
+	}
+	if qt.ClosePrice < 0.01 {
+		// 无昨收价,以行情涨跌停价为准
+		band.LimitUpPrice = qt.LimitUpPrice
+		band.LimitDownPrice = qt.LimitDownPrice
+		return band
+	}
+	band.LimitUpPrice, band.LimitDownPrice = util.PriceLimit(qt.ClosePrice, band.LimitPct)
+	return band
+}
+
+// GetBand 查询股票涨跌停价格区间
+func (s *PriceBandService) GetBand(code string) (*model.PriceBand, error) {
+	qts, err := s.core.Quote.GetQuoteByTencent([]string{code})
+	if err != nil {
+		return nil, err
+	}
+	qt, ok := qts[code]
+	if !ok {
+		return nil, serr.ErrBusiness("证券代码不存在")
+	}
+	return s.Band(qt), nil
+}
+
+// Check 校验限价委托价格是否在涨跌停价格区间内
+func (s *PriceBandService) Check(band *model.PriceBand, price float64) error {
+	if band.Contains(price) {
+		return nil
+	}
+	return serr.ErrBusiness(fmt.Sprintf("委托失败:委托价格超出涨跌停价格范围[%0.2f-%0.2f]", band.LimitDownPrice, band.LimitUpPrice))
+}
@@ -0,0 +1,308 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"stock/api-gateway/dao"
+	"stock/api-gateway/model"
+	"stock/api-gateway/serr"
+	"stock/common/log"
+	"stock/common/timeconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRechargeMinMoney = 0.01            // 未配置渠道时的单笔最低充值金额
+	paymentNotifyTTL        = 48 * time.Hour  // 已处理的通知ID保存时间,用于防重放
+	paymentQueryDelay       = 5 * time.Minute // 下单后未收到通知,开始主动查询的时间
+	paymentQueryWindow      = 72 * time.Hour  // 主动查询的订单范围
+	paymentOrderExpire      = 24 * time.Hour  // 下单后超过该时间仍未支付,关闭订单
+)
+
+// RechargeService 充值:按渠道下单,处理渠道通知与主动查询结果
+type RechargeService struct {
+	core   *Core
+	ledger *LedgerService
+}
+
+var (
+	rechargeService *RechargeService
+	rechargeOnce    sync.Once
+)
+
+// RechargeServiceInstance 实例
+func RechargeServiceInstance() *RechargeService {
+	rechargeOnce.Do(func() {
+		rechargeService = defaultServices().Recharge
+	})
+	return rechargeService
+}
+
+// config 渠道配置,未配置时仅限制最低金额
+func (s *RechargeService) config(configs map[string]*model.PaymentChannelConfig, code string) *model.PaymentChannelConfig {
+	if config, ok := configs[code]; ok {
+		return config
+	}
+	return &model.PaymentChannelConfig{Code: code, MinMoney: defaultRechargeMinMoney}
+}
+
+// Channels 已开启的充值渠道,含单笔金额限制与手续费规则
+func (s *RechargeService) Channels(ctx context.Context) ([]*model.RechargeChannel, error) {
+	sys, err := s.core.Sys.GetSysParam(ctx)
+	if err != nil {
+		return nil, err
+	}
+	configs, err := s.core.PaymentChannel.GetConfigs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	channels := make([]PaymentChannel, 0)
+	for _, it := range PaymentChannels() {
+		if it.Enabled(sys) {
+			channels = append(channels, it)
+		}
+	}
+	sort.SliceStable(channels, func(i, j int) bool {
+		return s.config(configs, channels[i].Code()).Sort < s.config(configs, channels[j].Code()).Sort
+	})
+	list := make([]*model.RechargeChannel, 0)
+	for _, it := range channels {
+		config := s.config(configs, it.Code())
+		list = append(list, &model.RechargeChannel{
+			Code:     it.Code(),
+			Name:     it.Name(),
+			MinMoney: config.MinMoney,
+			MaxMoney: config.MaxMoney,
+			FeeRate:  config.FeeRate,
+			MinFee:   config.MinFee,
+		})
+	}
+	return list, nil
+}
+
+// Create 充值下单:校验渠道开关、单笔金额限制,计算手续费后由渠道下单
+func (s *RechargeService) Create(ctx context.Context, uid int64, code string, money float64) (*model.Transfer, *model.PaymentOrder, error) {
+	channel, ok := PaymentChannelByCode(code)
+	if !ok {
+		return nil, nil, serr.ErrBusiness("充值渠道不存在")
+	}
+	sys, err := s.core.Sys.GetSysParam(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !channel.Enabled(sys) {
+		return nil, nil, serr.ErrBusiness("转入资金渠道未开放")
+	}
+	configs, err := s.core.PaymentChannel.GetConfigs(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	config := s.config(configs, code)
+	money = math.Round(money*100) / 100
+	if money < config.MinMoney || money <= 0 {
+		return nil, nil, serr.ErrBusiness(fmt.Sprintf("单笔最低转入%0.2f元", math.Max(config.MinMoney, defaultRechargeMinMoney)))
+	}
+	if config.MaxMoney > 0 && money > config.MaxMoney {
+		return nil, nil, serr.ErrBusiness(fmt.Sprintf("单笔最高转入%0.2f元", config.MaxMoney))
+	}
+
+	transfer := &model.Transfer{
+		UID:       uid,
+		OrderTime: time.Now(),
+		Money:     money,
+		Fee:       config.Fee(money),
+		Type:      model.TransferTypeRecharge,
+		Channel:   channel.Name(),
+		OrderNo:   s.core.OrderNo.NextOrderNo(),
+	}
+	order, err := channel.CreateOrder(ctx, transfer)
+	if err != nil {
+		return nil, nil, err
+	}
+	if order.Status == model.TransferStatusWaitExam {
+		if err := s.checkPending(ctx, uid); err != nil {
+			return nil, nil, err
+		}
+	}
+	transfer.Status = order.Status
+	if err := s.core.Transfer.Create(ctx, transfer); err != nil {
+		log.Errorf("插入转账记录表失败:%+v", err)
+		return nil, nil, serr.ErrBusiness("转入资金错误")
+	}
+	return transfer, order, nil
+}
+
+// checkPending 人工转账当日已有待审核的充值时不允许再次提交
+func (s *RechargeService) checkPending(ctx context.Context, uid int64) error {
+	list, err := s.core.Transfer.GetByUid(ctx, uid)
+	if err != nil {
+		return err
+	}
+	for _, it := range list {
+		// 非待审核状态,充值则跳过
+		if it.Status != model.TransferStatusWaitExam || it.Type != model.TransferTypeRecharge {
+			continue
+		}
+		if timeconv.TimeToInt32(it.OrderTime) == timeconv.TimeToInt32(time.Now()) {
+			return serr.ErrBusiness("您有一笔订单处于待审核状态,请稍后再提交。")
+		}
+	}
+	return nil
+}
+
+// Notify 渠道异步通知:由渠道验签解析,同一通知只处理一次
+func (s *RechargeService) Notify(ctx context.Context, code string, req *http.Request) error {
+	channel, ok := PaymentChannelByCode(code)
+	if !ok {
+		return serr.ErrBusiness("充值渠道不存在")
+	}
+	result, err := channel.VerifyCallback(ctx, req)
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf("payment_notify_%s_%s", code, result.NotifyID)
+	if n, err := s.core.Cache.Exists(ctx, key).Result(); err == nil && n > 0 {
+		return nil
+	}
+	if err := s.settle(ctx, channel, result); err != nil {
+		return err
+	}
+	if err := s.core.Cache.Set(ctx, key, 1, paymentNotifyTTL).Err(); err != nil {
+		log.Errorf("记录充值通知失败:%+v", err)
+	}
+	return nil
+}
+
+// QueryPending 主动查询:下单后长时间未收到通知的订单,向渠道查询支付结果,支付成功入账,超时未支付关闭
+func (s *RechargeService) QueryPending(ctx context.Context) error {
+	now := time.Now()
+	var lastErr error
+	for _, channel := range PaymentChannels() {
+		transfers, err := dao.TransferDaoInstance().GetPreByChannel(ctx, channel.Name(), now.Add(-paymentQueryWindow), now.Add(-paymentQueryDelay))
+		if err != nil {
+			return err
+		}
+		for _, it := range transfers {
+			result, err := channel.Query(ctx, it)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			if result.Status == model.PaymentStatusPending && now.Sub(it.OrderTime) > paymentOrderExpire {
+				result.Status = model.PaymentStatusClosed
+			}
+			if err := s.settle(ctx, channel, result); err != nil {
+				lastErr = err
+			}
+		}
+	}
+	return lastErr
+}
+
+// settle 处理渠道支付结果:支付成功入账,交易关闭则订单失败,待支付不处理
+func (s *RechargeService) settle(ctx context.Context, channel PaymentChannel, result *model.PaymentResult) error {
+	switch result.Status {
+	case model.PaymentStatusPaid:
+		return s.pay(ctx, channel, result)
+	case model.PaymentStatusClosed:
+		return s.close(ctx, channel, result.OrderNo)
+	}
+	return nil
+}
+
+// pay 支付成功入账:锁定订单,仅预插入状态且支付金额与订单金额一致时入账,已入账的订单直接返回
+func (s *RechargeService) pay(ctx context.Context, channel PaymentChannel, result *model.PaymentResult) error {
+	tx := s.core.Tx.Begin(ctx)
+	defer tx.Rollback()
+	transfer, err := s.core.Transfer.GetByOrderNoForUpdateWithTx(tx, result.OrderNo)
+	if err != nil {
+		log.Errorf("订单号不存在:%s,%+v", result.OrderNo, err)
+		return serr.ErrBusiness("订单不存在")
+	}
+	if transfer.Type != model.TransferTypeRecharge || transfer.Channel != channel.Name() {
+		log.Errorf("订单[%s]不是%s充值:%+v", result.OrderNo, channel.Name(), transfer)
+		return serr.ErrBusiness("订单不存在")
+	}
+	if transfer.Status == model.TransferStatusSuccess {
+		return nil
+	}
+	if transfer.Status != model.TransferStatusPre {
+		// 订单已关闭后支付成功,需人工处理,对账时列为差异
+		log.Errorf("%s订单[%s]状态为%s,支付成功未入账", channel.Name(), result.OrderNo, model.TransferStatusMap[transfer.Status])
+		return serr.ErrBusiness("订单状态错误")
+	}
+	if math.Abs(result.Money-transfer.Money) >= 0.005 {
+		log.Errorf("%s订单[%s]支付金额%0.2f与订单金额%0.2f不一致", channel.Name(), result.OrderNo, result.Money, transfer.Money)
+		return serr.ErrBusiness("支付金额不一致")
+	}
+	user, err := s.core.User.GetUserByUIDForUpdateWithTx(tx, transfer.UID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	transfer.Status = model.TransferStatusSuccess
+	transfer.TradeNo = result.TradeNo
+	transfer.FinishTime = &now
+	if err := s.core.Transfer.CreateWithTx(tx, transfer); err != nil {
+		return err
+	}
+	user.Money += transfer.Arrival()
+	if err := s.core.User.UpdateUserWithTx(tx, user); err != nil {
+		return err
+	}
+	journal := rechargeJournal(transfer, fmt.Sprintf("%s充值,订单号:%s", channel.Name(), transfer.OrderNo))
+	if err := s.ledger.PostWithTx(tx, journal); err != nil {
+		return err
+	}
+	if err := s.core.Msg.CreateWithTx(tx, &model.Msg{
+		UID:        user.ID,
+		Title:      "充值成功",
+		Content:    fmt.Sprintf("您通过%s充值的%0.2f元已到账", channel.Name(), transfer.Arrival()),
+		CreateTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Errorf("事务提交失败:%+v", err)
+		return err
+	}
+	return nil
+}
+
+// close 关闭未支付的订单
+func (s *RechargeService) close(ctx context.Context, channel PaymentChannel, orderNo string) error {
+	tx := s.core.Tx.Begin(ctx)
+	defer tx.Rollback()
+	transfer, err := s.core.Transfer.GetByOrderNoForUpdateWithTx(tx, orderNo)
+	if err != nil {
+		log.Errorf("订单号不存在:%s,%+v", orderNo, err)
+		return serr.ErrBusiness("订单不存在")
+	}
+	if transfer.Status != model.TransferStatusPre || transfer.Channel != channel.Name() {
+		return nil
+	}
+	now := time.Now()
+	transfer.Status = model.TransferStatusFail
+	transfer.Reason = "交易关闭或超时未支付"
+	transfer.FinishTime = &now
+	if err := s.core.Transfer.CreateWithTx(tx, transfer); err != nil {
+		return err
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Errorf("事务提交失败:%+v", err)
+		return err
+	}
+	return nil
+}
+
+// rechargeJournal 充值入账凭证:到账金额转入用户钱包,手续费计入平台手续费收入
+func rechargeJournal(transfer *model.Transfer, remark string) *model.Journal {
+	return model.NewJournal(model.LedgerBizRecharge, transfer.ID, remark).
+		Move(model.ExternalAccount, model.WalletAccount(transfer.UID), transfer.Arrival()).
+		Move(model.ExternalAccount, model.FeeIncomeAccount, transfer.Fee)
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"stock/api-gateway/fake"
+	"stock/api-gateway/model"
+)
+
+// registerPaymentChannel 注册充值渠道,用例结束后恢复注册前的渠道
+func registerPaymentChannel(t *testing.T, channel PaymentChannel) {
+	saved := PaymentChannels()
+	RegisterPaymentChannel(channel)
+	t.Cleanup(func() {
+		paymentChannelMu.Lock()
+		defer paymentChannelMu.Unlock()
+		paymentChannels = saved
+	})
+}
+
+// newMockChannel 注册模拟充值渠道
+func newMockChannel(t *testing.T) *fake.PaymentChannel {
+	channel := fake.NewPaymentChannel()
+	registerPaymentChannel(t, channel)
+	return channel
+}
+
+// notifyRequest 模拟渠道异步通知
+func notifyRequest(form url.Values) *http.Request {
+	req, _ := http.NewRequest(http.MethodPost, "/payment/callback/mock", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+// TestRechargeChannels 充值页面只列出已开启的渠道,按配置排序并带出金额限制与手续费规则
+func TestRechargeChannels(t *testing.T) {
+	ctx := context.Background()
+	store, _, svc := newFakeServices()
+	newMockChannel(t)
+	registerPaymentChannel(t, &bankChannel{sys: store.Dao().Sys})
+	store.SetSysParam(&model.SysParam{BankChannel: true})
+	store.SetPaymentConfig(&model.PaymentChannelConfig{Code: "mock", MinMoney: 10, MaxMoney: 5000, FeeRate: 0.006, MinFee: 1, Sort: 1})
+	store.SetPaymentConfig(&model.PaymentChannelConfig{Code: "bank", MinMoney: 100, Sort: 2})
+
+	channels, err := svc.Recharge.Channels(ctx)
+	if err != nil || len(channels) != 2 {
+		t.Fatalf("channels: %+v %+v", channels, err)
+	}
+	if channels[0].Code != "mock" || channels[0].MaxMoney != 5000 || channels[1].Code != "bank" || channels[1].MinMoney != 100 {
+		t.Fatalf("channels: %+v %+v", channels[0], channels[1])
+	}
+
+	user := store.PutUser(&model.User{Status: model.UserStatusActive})
+	if _, _, err := svc.Recharge.Create(ctx, user.ID, "alipay", 100); err == nil {
+		t.Fatal("expect disabled channel fail")
+	}
+	if _, _, err := svc.Recharge.Create(ctx, user.ID, "mock", 5); err == nil {
+		t.Fatal("expect below min money fail")
+	}
+	if _, _, err := svc.Recharge.Create(ctx, user.ID, "mock", 5001); err == nil {
+		t.Fatal("expect above max money fail")
+	}
+	transfer, order, err := svc.Recharge.Create(ctx, user.ID, "bank", 100)
+	if err != nil || transfer.Status != model.TransferStatusWaitExam || order.Data["bank_no"] == nil {
+		t.Fatalf("create bank: %+v %+v %+v", transfer, order, err)
+	}
+	if _, _, err := svc.Recharge.Create(ctx, user.ID, "bank", 100); err == nil {
+		t.Fatal("expect pending manual recharge fail")
+	}
+}
+
+// TestRechargePay 渠道通知验签后入账:扣除手续费、重复通知只入账一次,金额不一致或已关闭订单不入账
+func TestRechargePay(t *testing.T) {
+	ctx := context.Background()
+	store, _, svc := newFakeServices()
+	channel := newMockChannel(t)
+	store.SetSysParam(&model.SysParam{})
+	store.SetPaymentConfig(&model.PaymentChannelConfig{Code: "mock", MinMoney: 1, FeeRate: 0.006, MinFee: 1})
+	user := store.PutUser(&model.User{Status: model.UserStatusActive, Money: 10})
+	if _, err := svc.Ledger.Open(ctx, "test"); err != nil {
+		t.Fatalf("ledger open: %+v", err)
+	}
+
+	paid, order, err := svc.Recharge.Create(ctx, user.ID, "mock", 1000)
+	if err != nil || paid.Status != model.TransferStatusPre || paid.Fee != 6 || order.Data["url"] == nil {
+		t.Fatalf("create: %+v %+v %+v", paid, order, err)
+	}
+	closed, _, err := svc.Recharge.Create(ctx, user.ID, "mock", 50)
+	if err != nil || closed.Fee != 1 {
+		t.Fatalf("create: %+v %+v", closed, err)
+	}
+
+	// 1.验签失败、支付金额不一致不入账
+	channel.Pay(paid.OrderNo, 1000)
+	if err := svc.Recharge.Notify(ctx, "mock", notifyRequest(url.Values{"order_no": {paid.OrderNo}, "notify_id": {"n1"}})); err == nil {
+		t.Fatal("expect invalid sign fail")
+	}
+	if err := svc.Recharge.Notify(ctx, "mock", notifyRequest(url.Values{"order_no": {paid.OrderNo}, "notify_id": {"n1"},
+		"sign": {"mock"}, "money": {"10.00"}})); err == nil {
+		t.Fatal("expect amount mismatch fail")
+	}
+
+	// 2.重复通知、主动查询只入账一次
+	for i := 0; i < 2; i++ {
+		if err := svc.Recharge.Notify(ctx, "mock", notifyRequest(url.Values{"order_no": {paid.OrderNo}, "notify_id": {"n2"}, "sign": {"mock"}})); err != nil {
+			t.Fatalf("notify: %+v", err)
+		}
+	}
+	result, _ := channel.Query(ctx, paid)
+	if err := svc.Recharge.settle(ctx, channel, result); err != nil {
+		t.Fatalf("settle: %+v", err)
+	}
+
+	// 3.渠道关闭的订单失败,关闭后支付不入账
+	channel.Close(closed.OrderNo)
+	result, _ = channel.Query(ctx, closed)
+	if err := svc.Recharge.settle(ctx, channel, result); err != nil {
+		t.Fatalf("close: %+v", err)
+	}
+	channel.Pay(closed.OrderNo, 50)
+	result, _ = channel.Query(ctx, closed)
+	if err := svc.Recharge.settle(ctx, channel, result); err == nil {
+		t.Fatal("expect pay closed order fail")
+	}
+
+	u, _ := svc.core.User.GetUserByUID(ctx, user.ID)
+	assertMoney(t, "money after pay", u.Money, 1004)
+	status := make(map[string]*model.Transfer)
+	for _, it := range store.Transfers(user.ID) {
+		status[it.OrderNo] = it
+	}
+	if it := status[paid.OrderNo]; it.Status != model.TransferStatusSuccess || it.TradeNo != "M"+paid.OrderNo {
+		t.Fatalf("paid transfer: %+v", it)
+	}
+	if it := status[closed.OrderNo]; it.Status != model.TransferStatusFail {
+		t.Fatalf("closed transfer: %+v", it)
+	}
+	if msgs := store.Msgs(user.ID); len(msgs) != 1 {
+		t.Fatalf("expect 1 msg, got %d", len(msgs))
+	}
+	assertMoney(t, "wallet ledger", store.LedgerBalance(model.WalletAccount(user.ID)), 1004)
+	assertMoney(t, "fee income ledger", store.LedgerBalance(model.FeeIncomeAccount), 6)
+	if diffs, err := svc.Ledger.Check(ctx); err != nil || len(diffs) != 0 {
+		t.Fatalf("ledger check: %+v %+v", diffs, err)
+	}
+}
+
+// TestManualRefund 人工转账渠道不支持原路退款
+func TestManualRefund(t *testing.T) {
+	ctx := context.Background()
+	transfer := &model.Transfer{OrderNo: "R100", Money: 100}
+	for _, it := range []PaymentChannel{&bankChannel{}, &qrcodeChannel{}} {
+		if err := it.Refund(ctx, transfer, 30, "用户申请退款"); err == nil {
+			t.Fatalf("expect %s refund unsupported", it.Code())
+		}
+	}
+}
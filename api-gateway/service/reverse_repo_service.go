@@ -1,11 +1,23 @@
 package service
 
 import (
+	"context"
+	"fmt"
+	"stock/api-gateway/dao"
+	"stock/api-gateway/db"
+	"stock/api-gateway/model"
+	"stock/api-gateway/quote"
+	"stock/api-gateway/serr"
+	"stock/api-gateway/util"
+	"stock/common/log"
+	"stock/common/timeconv"
 	"sync"
 	"time"
+
+	"gorm.io/gorm"
 )
 
-// ReverseRepoService 国债逆回购
+// ReverseRepoService 国债逆回购:合约闲置资金或钱包余额融出,按交易日历计息,到期本息返还
 type ReverseRepoService struct {
 }
 
@@ -18,14 +30,530 @@ var (
 func ReverseRepoServiceInstance() *ReverseRepoService {
 	reverseRepoOnce.Do(func() {
 		reverseRepoService = &ReverseRepoService{}
-		//ctx := context.Background()
+		ctx := context.Background()
+		cs := CalendarServiceInstance()
 
-		// 国债逆回购业务
+		// 交易时间:撮合未成交委托;到期结算、收盘处理由定时任务执行
 		go func() {
 			for range time.Tick(2 * time.Second) {
-
+				if !cs.IsTradeTime(ctx) {
+					continue
+				}
+				if err := reverseRepoService.match(ctx); err != nil {
+					log.Errorf("逆回购撮合失败:%+v", err)
+				}
 			}
 		}()
 	})
 	return reverseRepoService
 }
+
+// Products 逆回购品种及最新利率
+func (s *ReverseRepoService) Products(ctx context.Context) ([]*model.RepoProductItem, error) {
+	codes := make([]string, 0)
+	for _, it := range model.RepoProducts {
+		codes = append(codes, it.Code)
+	}
+	rates, err := quote.QtServiceInstance().GetRepoRate(codes)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*model.RepoProductItem, 0)
+	for _, it := range model.RepoProducts {
+		result = append(result, &model.RepoProductItem{
+			Code:   it.Code,
+			Name:   it.Name,
+			Market: it.Market,
+			Tenor:  it.Tenor,
+			Rate:   rates[it.Code],
+		})
+	}
+	return result, nil
+}
+
+// Lend 逆回购委托融出:contractID大于0时使用合约可用资金,否则使用钱包余额;市价委托按最新利率成交
+func (s *ReverseRepoService) Lend(ctx context.Context, uid, contractID int64, code string, money float64, entrustProp int64, rate float64) error {
+	if !CalendarServiceInstance().IsEntrustTime(ctx) {
+		return serr.ErrBusiness("委托失败:非交易时间")
+	}
+	product, ok := model.GetRepoProduct(code)
+	if !ok {
+		return serr.ErrBusiness("委托失败:逆回购品种不存在")
+	}
+	if money < model.ReverseRepoUnit || int64(money)%model.ReverseRepoUnit != 0 || float64(int64(money)) != money {
+		return serr.ErrBusiness(fmt.Sprintf("委托失败:融出金额应为%d元整数倍", model.ReverseRepoUnit))
+	}
+	switch entrustProp {
+	case model.EntrustPropTypeLimitPrice:
+		if rate <= 0 {
+			return serr.ErrBusiness("委托失败:请输入委托利率")
+		}
+	case model.EntrustPropTypeMarketPrice:
+		rate = 0
+	default:
+		return serr.ErrBusiness("委托失败:委托类型错误")
+	}
+
+	repo := &model.ReverseRepo{
+		UID:         uid,
+		ContractID:  contractID,
+		Source:      model.ReverseRepoSourceWallet,
+		Code:        product.Code,
+		Name:        product.Name,
+		Tenor:       int64(product.Tenor),
+		EntrustBS:   model.EntrustBsTypeLend,
+		EntrustProp: entrustProp,
+		EntrustRate: rate,
+		Money:       money,
+		Fee:         util.RepoFee(money, product.FeeRate),
+		Status:      model.ReverseRepoStatusUnDeal,
+	}
+	if contractID > 0 {
+		repo.Source = model.ReverseRepoSourceContract
+		return s.lendFromContract(ctx, repo)
+	}
+	return s.lendFromWallet(ctx, repo)
+}
+
+// lendFromContract 使用合约可用资金融出:委托后冻结本金及手续费,在刷新合约可用资金时扣除
+func (s *ReverseRepoService) lendFromContract(ctx context.Context, repo *model.ReverseRepo) error {
+	// 与买入委托共用合约锁,防止并发委托超用可用资金
+	key := fmt.Sprintf("buy_lock_contract_id_%+v", repo.ContractID)
+	if !db.RedisClient().SetNX(ctx, key, "1", 1*time.Minute).Val() {
+		return serr.ErrBusiness("委托失败:请勿重复提交")
+	}
+	defer db.RedisClient().Del(ctx, key)
+
+	contract, err := dao.ContractDaoInstance().GetContractByID(ctx, repo.ContractID)
+	if err != nil || contract.UID != repo.UID {
+		return serr.ErrBusiness("委托失败:合约不存在")
+	}
+	if contract.Status != model.ContractStatusEnable {
+		return serr.ErrBusiness("委托失败:无效合约")
+	}
+	if contract.ValMoney < repo.Money+repo.Fee {
+		return serr.ErrBusiness("委托失败:可用资金不足")
+	}
+	if err := dao.ReverseRepoDaoInstance().CreateWithTx(db.StockDB().WithContext(ctx), repo); err != nil {
+		return serr.ErrBusiness("委托失败")
+	}
+	if err := ContractServiceInstance().UpdateValMoneyByID(ctx, repo.ContractID); err != nil {
+		log.Errorf("更新合约资金失败:%+v", err)
+	}
+	return nil
+}
+
+// lendFromWallet 使用钱包余额融出:委托时扣除本金及手续费,撤单时返还
+func (s *ReverseRepoService) lendFromWallet(ctx context.Context, repo *model.ReverseRepo) error {
+	key := fmt.Sprintf("repo_lock_uid_%+v", repo.UID)
+	if !db.RedisClient().SetNX(ctx, key, "1", 1*time.Minute).Val() {
+		return serr.ErrBusiness("委托失败:请勿重复提交")
+	}
+	defer db.RedisClient().Del(ctx, key)
+
+	tx := db.StockDB().WithContext(ctx).Begin()
+	defer tx.Rollback()
+	// 事务内加行锁读取余额,防止与充值、提现等并发更新相互覆盖
+	user, err := dao.UserDaoInstance().GetUserByUIDForUpdateWithTx(tx, repo.UID)
+	if err != nil {
+		return err
+	}
+	if user.Money < repo.Money+repo.Fee {
+		return serr.ErrBusiness("委托失败:账户余额不足")
+	}
+	user.Money -= repo.Money + repo.Fee
+	if err := dao.ReverseRepoDaoInstance().CreateWithTx(tx, repo); err != nil {
+		return serr.ErrBusiness("委托失败")
+	}
+	if err := dao.UserDaoInstance().UpdateUserWithTx(tx, user); err != nil {
+		return serr.ErrBusiness("委托失败")
+	}
+	if err := dao.TransferDaoInstance().CreateWithTx(tx, &model.Transfer{
+		UID:       repo.UID,
+		OrderTime: time.Now(),
+		Money:     repo.Money + repo.Fee,
+		Type:      model.TransferTypeRepoLend,
+		Status:    model.TransferStatusSuccess,
+		Channel:   repo.Name,
+		OrderNo:   s.orderNo(repo),
+	}); err != nil {
+		return serr.ErrBusiness("委托失败")
+	}
+	journal := model.NewJournal(model.LedgerBizRepoLend, repo.ID, fmt.Sprintf("%s融出", repo.Name)).
+		Move(model.WalletAccount(repo.UID), model.RepoAccount, repo.Money).
+		Move(model.WalletAccount(repo.UID), model.FeeIncomeAccount, repo.Fee)
+	if err := LedgerServiceInstance().PostWithTx(tx, journal); err != nil {
+		return serr.ErrBusiness("委托失败")
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Errorf("事务提交失败:%+v", err)
+		return serr.ErrBusiness("委托失败")
+	}
+	return nil
+}
+
+// orderNo 钱包资金转账记录的订单号
+func (s *ReverseRepoService) orderNo(repo *model.ReverseRepo) string {
+	return fmt.Sprintf("RR%d", repo.ID)
+}
+
+// List 查询用户逆回购
+func (s *ReverseRepoService) List(ctx context.Context, uid int64) ([]*model.ReverseRepoItem, error) {
+	list, err := dao.ReverseRepoDaoInstance().GetByUID(ctx, uid)
+	if err != nil {
+		return nil, serr.ErrBusiness("查询失败")
+	}
+	result := make([]*model.ReverseRepoItem, 0)
+	for _, it := range list {
+		result = append(result, model.ConvertReverseRepoItem(it))
+	}
+	return result, nil
+}
+
+// Withdraw 撤销未成交的逆回购委托
+func (s *ReverseRepoService) Withdraw(ctx context.Context, uid, id int64) error {
+	repo, err := dao.ReverseRepoDaoInstance().GetByID(ctx, id)
+	if err != nil || repo.UID != uid {
+		return serr.ErrBusiness("委托不存在")
+	}
+	if repo.Status != model.ReverseRepoStatusUnDeal {
+		return serr.ErrBusiness("撤单失败:委托" + model.ReverseRepoStatusMap[repo.Status])
+	}
+	return s.withdraw(ctx, repo, "用户撤单")
+}
+
+// withdraw 撤单:返还冻结资金
+func (s *ReverseRepoService) withdraw(ctx context.Context, repo *model.ReverseRepo, remark string) error {
+	repo.Status = model.ReverseRepoStatusWithdraw
+	repo.Remark = remark
+
+	tx := db.StockDB().WithContext(ctx).Begin()
+	defer tx.Rollback()
+	ok, err := dao.ReverseRepoDaoInstance().UpdateWithTx(tx, repo, model.ReverseRepoStatusUnDeal)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return serr.ErrBusiness("撤单失败:委托状态已变化")
+	}
+	if repo.Source == model.ReverseRepoSourceWallet {
+		if err := s.refundWallet(tx, repo); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Errorf("事务提交失败:%+v", err)
+		return err
+	}
+	if repo.Source == model.ReverseRepoSourceContract {
+		if err := ContractServiceInstance().UpdateValMoneyByID(ctx, repo.ContractID); err != nil {
+			log.Errorf("更新合约资金失败:%+v", err)
+		}
+	}
+	return nil
+}
+
+// refundWallet 撤单返还钱包余额,融出转账记录置为失败
+func (s *ReverseRepoService) refundWallet(tx *gorm.DB, repo *model.ReverseRepo) error {
+	user, err := dao.UserDaoInstance().GetUserByUIDForUpdateWithTx(tx, repo.UID)
+	if err != nil {
+		return err
+	}
+	user.Money += repo.Money + repo.Fee
+	if err := dao.UserDaoInstance().UpdateUserWithTx(tx, user); err != nil {
+		return err
+	}
+	journal := model.NewJournal(model.LedgerBizRepoWithdraw, repo.ID, fmt.Sprintf("%s撤单", repo.Name)).
+		Move(model.RepoAccount, model.WalletAccount(repo.UID), repo.Money).
+		Move(model.FeeIncomeAccount, model.WalletAccount(repo.UID), repo.Fee)
+	if err := LedgerServiceInstance().PostWithTx(tx, journal); err != nil {
+		return err
+	}
+	return dao.TransferDaoInstance().UpdateStatusByOrderNoWithTx(tx, s.orderNo(repo), model.TransferStatusFail)
+}
+
+// match 撮合未成交委托:最新利率不低于委托利率(市价委托不限)时按最新利率全部成交
+func (s *ReverseRepoService) match(ctx context.Context) error {
+	list, err := dao.ReverseRepoDaoInstance().GetByStatus(ctx, model.ReverseRepoStatusUnDeal)
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		return nil
+	}
+	codes := make([]string, 0)
+	for _, it := range list {
+		codes = append(codes, it.Code)
+	}
+	rates, err := quote.QtServiceInstance().GetRepoRate(codes)
+	if err != nil {
+		return err
+	}
+	for _, it := range list {
+		rate, ok := rates[it.Code]
+		if !ok {
+			continue
+		}
+		if it.EntrustProp == model.EntrustPropTypeLimitPrice && rate < it.EntrustRate {
+			continue
+		}
+		if err := s.deal(ctx, it, rate); err != nil {
+			log.Errorf("逆回购成交失败,委托编号:%d err:%+v", it.ID, err)
+		}
+	}
+	return nil
+}
+
+// schedule 按交易日历计算交收日期、计息天数及到期利息
+func (s *ReverseRepoService) schedule(ctx context.Context, repo *model.ReverseRepo, tradeDate time.Time) error {
+	settle, expire, available, days, ok := util.RepoSchedule(tradeDate, int(repo.Tenor), func(date time.Time) bool {
+		return CalendarServiceInstance().IsTradeDay(ctx, date)
+	})
+	if !ok {
+		return serr.ErrBusiness("获取交易日失败")
+	}
+	repo.TradeDate = timeconv.TimeToInt32(tradeDate)
+	repo.SettleDate = timeconv.TimeToInt32(settle)
+	repo.ExpireDate = timeconv.TimeToInt32(expire)
+	repo.AvailableDate = timeconv.TimeToInt32(available)
+	repo.InterestDays = int64(days)
+	repo.Interest = util.RepoInterest(repo.Money, repo.Rate, days)
+	return nil
+}
+
+// deal 成交:计算交收日期及利息,合约资金扣除手续费
+func (s *ReverseRepoService) deal(ctx context.Context, repo *model.ReverseRepo, rate float64) error {
+	repo.Rate = rate
+	if err := s.schedule(ctx, repo, timeconv.Int32ToTime(timeconv.TimeToInt32(time.Now()))); err != nil {
+		return err
+	}
+	repo.Status = model.ReverseRepoStatusDeal
+
+	tx := db.StockDB().WithContext(ctx).Begin()
+	defer tx.Rollback()
+	ok, err := dao.ReverseRepoDaoInstance().UpdateWithTx(tx, repo, model.ReverseRepoStatusUnDeal)
+	if err != nil || !ok {
+		return err
+	}
+	if repo.Source == model.ReverseRepoSourceContract {
+		// 事务内加锁读取合约,避免覆盖同时成交的买卖对合约资金的修改
+		contract, err := dao.ContractDaoInstance().GetContractByIDForUpdateWithTx(tx, repo.ContractID)
+		if err != nil {
+			return err
+		}
+		contract.Money -= repo.Fee
+		if err := dao.ContractDaoInstance().UpdateWithTx(tx, contract); err != nil {
+			return err
+		}
+		journal := model.NewJournal(model.LedgerBizRepoDeal, repo.ID, fmt.Sprintf("%s成交手续费", repo.Name)).
+			Move(model.MarginAccount(contract.ID), model.FeeIncomeAccount, repo.Fee)
+		if err := LedgerServiceInstance().PostWithTx(tx, journal); err != nil {
+			return err
+		}
+		if err := dao.ContractFeeDaoInstance().CreateWithTx(tx, &model.ContractFee{
+			UID:        repo.UID,
+			ContractID: repo.ContractID,
+			Code:       repo.Code,
+			Name:       repo.Name,
+			Amount:     int64(repo.Money) / 100, // 逆回购数量:每张100元
+			OrderTime:  time.Now(),
+			Direction:  model.ContractFeeDirectionPay,
+			Money:      repo.Fee,
+			Detail:     fmt.Sprintf("%s融出%0.2f元,手续费:%0.2f元", repo.Name, repo.Money, repo.Fee),
+			Type:       model.ContractFeeTypeRepoFee,
+		}); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Errorf("事务提交失败:%+v", err)
+		return err
+	}
+
+	if repo.Source == model.ReverseRepoSourceContract {
+		if err := ContractServiceInstance().UpdateValMoneyByID(ctx, repo.ContractID); err != nil {
+			log.Errorf("更新合约资金失败:%+v", err)
+		}
+	}
+	s.notify(ctx, repo, "逆回购成交", fmt.Sprintf("%s融出%0.2f元成交,年化利率%0.3f%%,计息%d天,预计利息%0.2f元,%s资金可用。",
+		repo.Name, repo.Money, repo.Rate, repo.InterestDays, repo.Interest, timeconv.Int32ToTime(repo.AvailableDate).Format("2006-01-02")))
+	return nil
+}
+
+// Close 收盘处理:未成交委托撤单,计息中的逆回购按date计提利息
+func (s *ReverseRepoService) Close(ctx context.Context, date time.Time) error {
+	list, err := dao.ReverseRepoDaoInstance().GetByStatus(ctx, model.ReverseRepoStatusUnDeal)
+	if err != nil {
+		return err
+	}
+	for _, it := range list {
+		if err := s.withdraw(ctx, it, "收盘未成交自动撤单"); err != nil {
+			log.Errorf("逆回购撤单失败,委托编号:%d err:%+v", it.ID, err)
+			continue
+		}
+		s.notify(ctx, it, "逆回购撤单", fmt.Sprintf("%s融出%0.2f元收盘未成交,已自动撤单,冻结资金已返还。", it.Name, it.Money))
+	}
+
+	list, err = dao.ReverseRepoDaoInstance().GetByStatus(ctx, model.ReverseRepoStatusDeal)
+	if err != nil {
+		return err
+	}
+	today := timeconv.Int32ToTime(timeconv.TimeToInt32(date))
+	for _, it := range list {
+		// 自起息日起按自然日计提,含当日
+		days := int64(today.Sub(timeconv.Int32ToTime(it.SettleDate)).Hours()/24+0.5) + 1
+		if days <= 0 {
+			continue
+		}
+		if days > it.InterestDays {
+			days = it.InterestDays
+		}
+		if err := dao.ReverseRepoDaoInstance().UpdateAccrued(ctx, it.ID, util.RepoInterest(it.Money, it.Rate, int(days))); err != nil {
+			log.Errorf("逆回购计提利息失败,委托编号:%d err:%+v", it.ID, err)
+		}
+	}
+	return nil
+}
+
+// Settle 到期结算:资金可用日本息到账,合约资金利息计入保证金,钱包资金本息返还余额
+func (s *ReverseRepoService) Settle(ctx context.Context) error {
+	list, err := dao.ReverseRepoDaoInstance().GetByStatus(ctx, model.ReverseRepoStatusDeal)
+	if err != nil {
+		return err
+	}
+	today := timeconv.TimeToInt32(time.Now())
+	var failed bool
+	for _, it := range list {
+		if it.AvailableDate > today {
+			continue
+		}
+		if err := s.settleRepo(ctx, it); err != nil {
+			log.Errorf("逆回购到期结算失败,委托编号:%d err:%+v", it.ID, err)
+			failed = true
+		}
+	}
+	if failed {
+		return serr.ErrBusiness("逆回购到期结算失败")
+	}
+	return nil
+}
+
+// settleRepo 单笔逆回购到期结算
+func (s *ReverseRepoService) settleRepo(ctx context.Context, repo *model.ReverseRepo) error {
+	// 成交时交易日历可能未覆盖到期日,按最新交易日历重新计算交收日期及利息
+	if err := s.schedule(ctx, repo, timeconv.Int32ToTime(repo.TradeDate)); err != nil {
+		return err
+	}
+	if repo.AvailableDate > timeconv.TimeToInt32(time.Now()) {
+		tx := db.StockDB().WithContext(ctx)
+		_, err := dao.ReverseRepoDaoInstance().UpdateWithTx(tx, repo, model.ReverseRepoStatusDeal)
+		return err
+	}
+	repo.AccruedInterest = repo.Interest
+	repo.Status = model.ReverseRepoStatusSettled
+	repo.Remark = fmt.Sprintf("到期本息%0.2f元", repo.Money+repo.Interest)
+
+	tx := db.StockDB().WithContext(ctx).Begin()
+	defer tx.Rollback()
+	var contract *model.Contract
+	if repo.Source == model.ReverseRepoSourceContract {
+		// 事务内加锁读取合约,避免覆盖同时成交的买卖对合约资金的修改
+		ret, err := dao.ContractDaoInstance().GetContractByIDForUpdateWithTx(tx, repo.ContractID)
+		if err != nil {
+			return err
+		}
+		contract = ret
+		// 合约已结束则本息返还钱包
+		if contract.Status != model.ContractStatusEnable {
+			contract = nil
+			repo.Remark += ",合约已结束,返还钱包余额"
+		}
+	}
+	ok, err := dao.ReverseRepoDaoInstance().UpdateWithTx(tx, repo, model.ReverseRepoStatusDeal)
+	if err != nil || !ok {
+		return err
+	}
+	if contract != nil {
+		contract.Money += repo.Interest
+		if err := dao.ContractDaoInstance().UpdateWithTx(tx, contract); err != nil {
+			return err
+		}
+		journal := model.NewJournal(model.LedgerBizRepoSettle, repo.ID, fmt.Sprintf("%s到期利息", repo.Name)).
+			Move(model.MarketAccount, model.MarginAccount(contract.ID), repo.Interest)
+		if err := LedgerServiceInstance().PostWithTx(tx, journal); err != nil {
+			return err
+		}
+		if err := dao.ContractFeeDaoInstance().CreateWithTx(tx, &model.ContractFee{
+			UID:        repo.UID,
+			ContractID: repo.ContractID,
+			Code:       repo.Code,
+			Name:       repo.Name,
+			Amount:     int64(repo.Money) / 100, // 逆回购数量:每张100元
+			OrderTime:  time.Now(),
+			Direction:  model.ContractFeeDirectionIncome,
+			Money:      repo.Interest,
+			Detail:     fmt.Sprintf("%s到期,本金%0.2f元,利息:%0.2f元", repo.Name, repo.Money, repo.Interest),
+			Type:       model.ContractFeeTypeRepoIncome,
+		}); err != nil {
+			return err
+		}
+	} else {
+		user, err := dao.UserDaoInstance().GetUserByUIDForUpdateWithTx(tx, repo.UID)
+		if err != nil {
+			return err
+		}
+		user.Money += repo.Money + repo.Interest
+		if err := dao.UserDaoInstance().UpdateUserWithTx(tx, user); err != nil {
+			return err
+		}
+		journal := model.NewJournal(model.LedgerBizRepoSettle, repo.ID, fmt.Sprintf("%s到期本息", repo.Name)).
+			Move(model.MarketAccount, model.WalletAccount(repo.UID), repo.Interest)
+		if repo.Source == model.ReverseRepoSourceWallet {
+			journal.Move(model.RepoAccount, model.WalletAccount(repo.UID), repo.Money)
+		} else {
+			// 合约已结束:融出本金未从合约保证金转出,按证券市场返还记账
+			journal.Move(model.MarketAccount, model.WalletAccount(repo.UID), repo.Money)
+		}
+		if err := LedgerServiceInstance().PostWithTx(tx, journal); err != nil {
+			return err
+		}
+		if err := dao.TransferDaoInstance().CreateWithTx(tx, &model.Transfer{
+			UID:       repo.UID,
+			OrderTime: time.Now(),
+			Money:     repo.Money + repo.Interest,
+			Type:      model.TransferTypeRepoSettle,
+			Status:    model.TransferStatusSuccess,
+			Channel:   repo.Name,
+			OrderNo:   s.orderNo(repo),
+		}); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Errorf("事务提交失败:%+v", err)
+		return err
+	}
+
+	if contract != nil {
+		if err := ContractServiceInstance().UpdateValMoneyByID(ctx, contract.ID); err != nil {
+			log.Errorf("更新合约资金失败:%+v", err)
+		}
+	}
+	s.notify(ctx, repo, "逆回购到期", fmt.Sprintf("%s融出%0.2f元已到期,利息%0.2f元,%s。", repo.Name, repo.Money, repo.Interest, repo.Remark))
+	return nil
+}
+
+// notify 逆回购结果写入消息表
+func (s *ReverseRepoService) notify(ctx context.Context, repo *model.ReverseRepo, title, content string) {
+	if repo.Source == model.ReverseRepoSourceContract {
+		content = fmt.Sprintf("合约[%d]:%s", repo.ContractID, content)
+	}
+	if err := dao.MsgDaoInstance().Create(ctx, &model.Msg{
+		UID:        repo.UID,
+		Title:      title,
+		Content:    content,
+		CreateTime: time.Now(),
+	}); err != nil {
+		log.Errorf("创建消息失败:%+v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"stock/api-gateway/dao"
+	"stock/api-gateway/model"
+	"stock/api-gateway/util"
+	"stock/common/log"
+	"sync"
+)
+
+// secretBatch 每批迁移的行数
+const secretBatch = 500
+
+// SecretService 加密字段迁移:历史明文数据加密、密钥轮换后重新加密
+type SecretService struct {
+}
+
+var (
+	secretService *SecretService
+	secretOnce    sync.Once
+)
+
+// SecretServiceInstance 实例
+func SecretServiceInstance() *SecretService {
+	secretOnce.Do(func() {
+		secretService = &SecretService{}
+	})
+	return secretService
+}
+
+// Migrate 使用当前版本密钥重新加密全部加密字段,返回各表更新的行数。
+// 轮换密钥:FIELD_KEYS追加新版本并将FIELD_KEY_VERSION设为新版本,发布后执行迁移,完成后再移除旧版本密钥
+func (s *SecretService) Migrate(ctx context.Context) (map[string]int64, error) {
+	cipher := util.GetFieldCipher()
+	if cipher == nil {
+		return nil, fmt.Errorf("no FIELD_KEYS env set")
+	}
+	tables := make([]string, 0, len(model.SecretColumns))
+	for table := range model.SecretColumns {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	result := make(map[string]int64)
+	for _, table := range tables {
+		count, err := dao.SecretDaoInstance().Rotate(ctx, cipher, table, model.SecretColumns[table], secretBatch)
+		result[table] = count
+		if err != nil {
+			return result, err
+		}
+		log.Infof("加密字段迁移:%s 更新%d行,密钥版本v%d", table, count, cipher.Version())
+	}
+	return result, nil
+}
@@ -3,5 +3,7 @@ package service
 // Init 初始化各种service
 func Init() {
 	CalendarServiceInstance()
+	ConditionalOrderServiceInstance()
+	ReverseRepoServiceInstance()
 
 }
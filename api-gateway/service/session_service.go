@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"stock/api-gateway/db"
+	"stock/api-gateway/model"
+	"stock/api-gateway/serr"
+	"stock/api-gateway/util"
+	"stock/common/env"
+	"stock/common/log"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	accessTokenTTL  = 2 * time.Hour      // 访问token有效期
+	refreshTokenTTL = 7 * 24 * time.Hour // 刷新token有效期,同时也是会话有效期
+)
+
+// SessionService App用户会话服务
+type SessionService struct {
+}
+
+var (
+	sessionService *SessionService
+	sessionOnce    sync.Once
+)
+
+// SessionServiceInstance 实例
+func SessionServiceInstance() *SessionService {
+	sessionOnce.Do(func() {
+		sessionService = &SessionService{}
+	})
+	return sessionService
+}
+
+// sessionKey 单个会话的redis key
+func (s *SessionService) sessionKey(uid int64, sessionID string) string {
+	return fmt.Sprintf("app_user_session_%d_%s", uid, sessionID)
+}
+
+// sessionListKey 用户所有会话ID集合的redis key,用于退出所有设备
+func (s *SessionService) sessionListKey(uid int64) string {
+	return fmt.Sprintf("app_user_session_list_%d", uid)
+}
+
+// secret token签名密钥
+func (s *SessionService) secret() ([]byte, error) {
+	secret, ok := env.GlobalEnv().Get("TOKEN_SECRET")
+	if !ok || len(secret) == 0 {
+		log.Errorf("no TOKEN_SECRET config")
+		return nil, serr.ErrBusiness("登录服务异常")
+	}
+	return []byte(secret), nil
+}
+
+// Create 用户登录后创建会话,返回access/refresh token
+func (s *SessionService) Create(ctx context.Context, uid int64) (*model.AppToken, error) {
+	sessionID, err := util.RandomHex(16)
+	if err != nil {
+		log.Errorf("RandomHex err:%+v", err)
+		return nil, serr.ErrBusiness("登录失败")
+	}
+	now := time.Now()
+	session := &model.AppSession{
+		SessionID:  sessionID,
+		UID:        uid,
+		CreateTime: now.Format("2006-01-02 15:04:05"),
+		ExpireTime: now.Add(refreshTokenTTL).Format("2006-01-02 15:04:05"),
+	}
+	data, err := json.Marshal(session)
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := db.RedisClient().TxPipeline()
+	pipe.Set(ctx, s.sessionKey(uid, sessionID), string(data), refreshTokenTTL)
+	pipe.SAdd(ctx, s.sessionListKey(uid), sessionID)
+	pipe.Expire(ctx, s.sessionListKey(uid), refreshTokenTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Errorf("保存用户会话失败:%+v", err)
+		return nil, serr.ErrBusiness("登录失败")
+	}
+	return s.genToken(uid, sessionID, now)
+}
+
+// genToken 生成token对
+func (s *SessionService) genToken(uid int64, sessionID string, now time.Time) (*model.AppToken, error) {
+	secret, err := s.secret()
+	if err != nil {
+		return nil, err
+	}
+	result := &model.AppToken{
+		UID:             uid,
+		AccessExpireAt:  now.Add(accessTokenTTL).Unix(),
+		RefreshExpireAt: now.Add(refreshTokenTTL).Unix(),
+	}
+	result.AccessToken, err = util.SignToken(&model.TokenClaims{
+		UID:       uid,
+		SessionID: sessionID,
+		Type:      model.TokenTypeAccess,
+		ExpireAt:  result.AccessExpireAt,
+	}, secret)
+	if err != nil {
+		log.Errorf("SignToken err:%+v", err)
+		return nil, serr.ErrBusiness("登录失败")
+	}
+	result.RefreshToken, err = util.SignToken(&model.TokenClaims{
+		UID:       uid,
+		SessionID: sessionID,
+		Type:      model.TokenTypeRefresh,
+		ExpireAt:  result.RefreshExpireAt,
+	}, secret)
+	if err != nil {
+		log.Errorf("SignToken err:%+v", err)
+		return nil, serr.ErrBusiness("登录失败")
+	}
+	return result, nil
+}
+
+// parse 校验token签名、类型、过期时间以及会话是否已被注销
+func (s *SessionService) parse(ctx context.Context, token, tokenType string) (*model.TokenClaims, error) {
+	secret, err := s.secret()
+	if err != nil {
+		return nil, err
+	}
+	claims := &model.TokenClaims{}
+	if err := util.ParseToken(token, secret, claims); err != nil {
+		return nil, serr.New(serr.ErrCodeNoLogin, "请登录")
+	}
+	if claims.Type != tokenType || claims.UID == 0 || len(claims.SessionID) == 0 {
+		return nil, serr.New(serr.ErrCodeNoLogin, "请登录")
+	}
+	if time.Now().Unix() > claims.ExpireAt {
+		return nil, serr.New(serr.ErrCodeNoLogin, "登录已过期,请重新登录")
+	}
+	n, err := db.RedisClient().Exists(ctx, s.sessionKey(claims.UID, claims.SessionID)).Result()
+	if err != nil {
+		log.Errorf("查询用户会话失败:%+v", err)
+		return nil, serr.New(serr.ErrCodeNoLogin, "请登录")
+	}
+	if n == 0 {
+		return nil, serr.New(serr.ErrCodeNoLogin, "登录已失效,请重新登录")
+	}
+	return claims, nil
+}
+
+// Verify 校验访问token,返回用户ID和会话ID
+func (s *SessionService) Verify(ctx context.Context, accessToken string) (int64, string, error) {
+	claims, err := s.parse(ctx, accessToken, model.TokenTypeAccess)
+	if err != nil {
+		return 0, "", err
+	}
+	return claims.UID, claims.SessionID, nil
+}
+
+// Refresh 使用刷新token换取新的token对,旧会话同时失效
+func (s *SessionService) Refresh(ctx context.Context, refreshToken string) (*model.AppToken, error) {
+	claims, err := s.parse(ctx, refreshToken, model.TokenTypeRefresh)
+	if err != nil {
+		return nil, err
+	}
+	// 删除成功才允许换取新token,避免同一个刷新token被并发重复使用
+	n, err := db.RedisClient().Del(ctx, s.sessionKey(claims.UID, claims.SessionID)).Result()
+	if err != nil || n == 0 {
+		return nil, serr.New(serr.ErrCodeNoLogin, "登录已失效,请重新登录")
+	}
+	if err := db.RedisClient().SRem(ctx, s.sessionListKey(claims.UID), claims.SessionID).Err(); err != nil {
+		log.Errorf("SRem err:%+v", err)
+	}
+	return s.Create(ctx, claims.UID)
+}
+
+// Logout 退出当前会话
+func (s *SessionService) Logout(ctx context.Context, uid int64, sessionID string) error {
+	pipe := db.RedisClient().TxPipeline()
+	pipe.Del(ctx, s.sessionKey(uid, sessionID))
+	pipe.SRem(ctx, s.sessionListKey(uid), sessionID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Errorf("退出登录失败:%+v", err)
+		return serr.ErrBusiness("退出登录失败")
+	}
+	return nil
+}
+
+// LogoutAll 退出用户所有设备上的会话
+func (s *SessionService) LogoutAll(ctx context.Context, uid int64) error {
+	sessionIDs, err := db.RedisClient().SMembers(ctx, s.sessionListKey(uid)).Result()
+	if err != nil && err != redis.Nil {
+		log.Errorf("SMembers err:%+v", err)
+		return serr.ErrBusiness("退出登录失败")
+	}
+	keys := make([]string, 0, len(sessionIDs)+1)
+	for _, sessionID := range sessionIDs {
+		keys = append(keys, s.sessionKey(uid, sessionID))
+	}
+	keys = append(keys, s.sessionListKey(uid))
+	if err := db.RedisClient().Del(ctx, keys...).Err(); err != nil {
+		log.Errorf("退出所有设备失败:%+v", err)
+		return serr.ErrBusiness("退出登录失败")
+	}
+	return nil
+}
@@ -20,6 +20,7 @@ import (
 
 // StockDataService 服务
 type StockDataService struct {
+	core *Core
 }
 
 var (
@@ -30,7 +31,7 @@ var (
 // StockDataServiceInstance StockDataServiceInstance实例
 func StockDataServiceInstance() *StockDataService {
 	stockDataOnce.Do(func() {
-		stockDataService = &StockDataService{}
+		stockDataService = defaultServices().StockData
 		ctx := context.Background()
 		go func() {
 			defer func() {
@@ -141,7 +142,7 @@ func (s *StockDataService) get(ctx context.Context) error {
 
 // GetStockDataByCode 查询股票
 func (s *StockDataService) GetStockDataByCode(ctx context.Context, code string) (*model.StockData, error) {
-	stock, err := dao.StockDataDaoInstance().GetStockDataByCode(ctx, code)
+	stock, err := s.core.StockData.GetStockDataByCode(ctx, code)
 	if err != nil {
 		return nil, err
 	}
@@ -100,6 +100,12 @@ func (s *TDXService) Entrust(entrust *model.BrokerEntrust) error {
 		} else {
 			entrustProp = tdxEntrustPropTypeSZMarketPrice // 深圳市价
 		}
+		// 市价委托的保护价格不超出涨跌停价格区间
+		if band, err := PriceBandServiceInstance().GetBand(entrust.StockCode); err == nil {
+			entrust.EntrustPrice = band.Clamp(entrust.EntrustPrice)
+		} else {
+			log.Errorf("GetBand err:%+v", err)
+		}
 	}
 
 	// type:0买入,1卖出  priceType:0限价,1市价 gddm:上海|深圳股东代码  price:委托价格
@@ -236,6 +242,15 @@ func (s *TDXService) QueryTodayEntrust(broker *model.Broker) ([]*model.TDXTodayE
 	return model.ParseTdxTodayEntrust(broker, res), nil
 }
 
+// QueryOrderEvents 查询今日委托,生成未终态券商委托的状态变化事件
+func (s *TDXService) QueryOrderEvents(broker *model.Broker, open []*model.BrokerEntrust) ([]*model.BrokerOrderEvent, error) {
+	list, err := s.QueryTodayEntrust(broker)
+	if err != nil {
+		return nil, err
+	}
+	return tdxOrderEvents(broker, list, open), nil
+}
+
 // QueryPosition 查询持仓
 func (s *TDXService) QueryPosition(broker *model.Broker) ([]*model.TDXPosition, error) {
 	res, err := s.query(model.TDXQueryTypePosition, broker)
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"stock/api-gateway/fake"
+	"stock/api-gateway/model"
+)
+
+// newFakeServices 内存数据表、行情、缓存,交易日盘中
+func newFakeServices() (*fake.Store, *fake.Quote, *Services) {
+	store := fake.NewStore()
+	qt := fake.NewQuote()
+	svc := NewServices(&Core{
+		Store:    store.Dao(),
+		Quote:    qt,
+		Cache:    fake.NewCache(),
+		Sms:      &fake.Sms{},
+		Broker:   &fake.Broker{},
+		Calendar: fake.NewOpenCalendar(),
+		Identity: &fake.Identity{},
+		OrderNo:  &fake.OrderNo{},
+	})
+	return store, qt, svc
+}
+
+func assertMoney(t *testing.T, name string, got, want float64) {
+	t.Helper()
+	if math.Abs(got-want) > 0.001 {
+		t.Fatalf("%s: expect %.2f, got %.2f", name, want, got)
+	}
+}
+
+// TestTradeScenario 买入->模拟成交->次日解冻->卖出->模拟成交->合约结算
+func TestTradeScenario(t *testing.T) {
+	ctx := context.Background()
+	store, qt, svc := newFakeServices()
+	store.SetSysParam(&model.SysParam{BuyFee: 0.0003, SellFee: 0.0013, MiniChargeFee: 5, LowWarnCanBuy: true})
+	store.PutStockData(&model.StockData{Code: "600000", Status: model.StockDataStatusEnable})
+	user := store.PutUser(&model.User{Status: model.UserStatusActive})
+	contract := store.PutContract(&model.Contract{
+		UID: user.ID, InitMoney: 10000, Money: 10000, ValMoney: 20000, Lever: 1, Status: model.ContractStatusEnable,
+	})
+	qt.Set(&model.TencentQuote{Code: "600000", Name: "浦发银行", CurrentPrice: 10, ClosePrice: 10})
+	if n, err := svc.Ledger.Open(ctx, "test"); err != nil || n != 1 {
+		t.Fatalf("ledger open: %d %+v", n, err)
+	}
+
+	// 1.买入1000股,最新价撮合全部成交,买入手续费3元按最低5元收取
+	if err := svc.Trade.Buy(ctx, &model.EntrustPackage{
+		UID: user.ID, ContractID: contract.ID, Code: "600000", Price: 10, Amount: 1000, EntrustProp: model.EntrustPropTypeLimitPrice,
+	}); err != nil {
+		t.Fatalf("buy: %+v", err)
+	}
+	if err := svc.Trade.autoTrade(ctx); err != nil {
+		t.Fatalf("autoTrade: %+v", err)
+	}
+	c, _ := svc.core.Contract.GetContractByID(ctx, contract.ID)
+	assertMoney(t, "money after buy", c.Money, 9995)
+	assertMoney(t, "val money after buy", c.ValMoney, 9995)
+	position, err := svc.core.Position.GetContractPositionByCode(ctx, contract.ID, "600000")
+	if err != nil {
+		t.Fatalf("position: %+v", err)
+	}
+	if position.Amount != 1000 || position.FreezeAmount != 1000 {
+		t.Fatalf("unexpected position: %+v", position)
+	}
+
+	// 2.T+1:当日买入不可卖出,不可结算
+	sell := &model.EntrustPackage{
+		UID: user.ID, ContractID: contract.ID, Code: "600000", Price: 10.8, Amount: 1000, EntrustProp: model.EntrustPropTypeLimitPrice,
+	}
+	if err := svc.Trade.Sell(ctx, sell); err == nil {
+		t.Fatal("expect sell fail before unfreeze")
+	}
+	if err := svc.Contract.Settlement(ctx, contract.ID); err == nil {
+		t.Fatal("expect settlement fail with position")
+	}
+	if err := svc.Position.UnfreezeAll(ctx); err != nil {
+		t.Fatalf("unfreeze: %+v", err)
+	}
+
+	// 3.次日10.8元全部卖出,盈利800元,卖出手续费14.04元
+	qt.Set(&model.TencentQuote{Code: "600000", Name: "浦发银行", CurrentPrice: 10.8, ClosePrice: 10})
+	if err := svc.Trade.Sell(ctx, sell); err != nil {
+		t.Fatalf("sell: %+v", err)
+	}
+	if err := svc.Trade.autoTrade(ctx); err != nil {
+		t.Fatalf("autoTrade: %+v", err)
+	}
+	if positions, _ := svc.core.Position.GetPositionByContractID(ctx, contract.ID); len(positions) != 0 {
+		t.Fatalf("expect position cleared, got %+v", positions[0])
+	}
+	entrusts, _ := svc.core.Entrust.GetEntrustByContractID(ctx, contract.ID)
+	for _, it := range entrusts {
+		if it.Status != model.EntrustStatusTypeDeal {
+			t.Fatalf("expect entrust deal: %+v", it)
+		}
+	}
+	c, _ = svc.core.Contract.GetContractByID(ctx, contract.ID)
+	assertMoney(t, "money after sell", c.Money, 10780.96)
+
+	// 4.结算:合约资金转入钱包
+	if err := svc.Contract.Settlement(ctx, contract.ID); err != nil {
+		t.Fatalf("settlement: %+v", err)
+	}
+	c, _ = svc.core.Contract.GetContractByID(ctx, contract.ID)
+	if c.Status != model.ContractStatusDisabled {
+		t.Fatalf("expect contract disabled, got %d", c.Status)
+	}
+	u, _ := svc.core.User.GetUserByUID(ctx, user.ID)
+	assertMoney(t, "user money", u.Money, 10780.96)
+	transfers := store.Transfers(user.ID)
+	if len(transfers) != 1 || transfers[0].Type != model.TransferTypeCloseContract {
+		t.Fatalf("unexpected transfers: %+v", transfers)
+	}
+	fees, _ := svc.core.ContractFee.GetContractFeeByID(ctx, contract.ID)
+	if len(fees) != 4 {
+		t.Fatalf("expect 4 contract fees, got %d", len(fees))
+	}
+
+	// 5.账簿:余额与业务表一致,手续费收入=买入5元+卖出14.04元
+	diffs, err := svc.Ledger.Check(ctx)
+	if err != nil || len(diffs) != 0 {
+		t.Fatalf("ledger check: %+v %+v", diffs, err)
+	}
+	assertMoney(t, "ledger wallet", store.LedgerBalance(model.WalletAccount(user.ID)), 10780.96)
+	assertMoney(t, "ledger margin", store.LedgerBalance(model.MarginAccount(contract.ID)), 0)
+	assertMoney(t, "ledger fee income", store.LedgerBalance(model.FeeIncomeAccount), 19.04)
+	assertMoney(t, "ledger market", store.LedgerBalance(model.MarketAccount), -800)
+}
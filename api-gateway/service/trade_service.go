@@ -5,10 +5,7 @@ import (
 	"fmt"
 	"math"
 	"sort"
-	"stock/api-gateway/dao"
-	"stock/api-gateway/db"
 	"stock/api-gateway/model"
-	"stock/api-gateway/quote"
 	"stock/api-gateway/serr"
 	"stock/api-gateway/util"
 	"stock/common/errgroup"
@@ -23,6 +20,13 @@ import (
 
 // TradeService 内容服务
 type TradeService struct {
+	core      *Core
+	contract  *ContractService
+	buy       *BuyService
+	sell      *SellService
+	position  *PositionService
+	priceBand *PriceBandService
+	stockData *StockDataService
 }
 
 var (
@@ -33,7 +37,7 @@ var (
 // TradeServiceInstance TradeServiceInstance实例
 func TradeServiceInstance() *TradeService {
 	tradeOnce.Do(func() {
-		tradeService = &TradeService{}
+		tradeService = defaultServices().Trade
 		ctx := context.Background()
 
 		// 非券商委托状态下,检测交易自动成交
@@ -101,127 +105,51 @@ func (s *TradeService) genEntrust(ctx context.Context, entrust *model.Entrust, b
 	return entrust
 }
 
-// process 处理状态
-func (s *TradeService) isDeal(entrust *model.Entrust, brokerEntrustsList []*model.BrokerEntrust, brokerTdxEntrustMap map[int64][]*model.TDXTodayEntrust) ([]*model.BrokerEntrust, bool) {
-	if len(brokerEntrustsList) == 0 || len(brokerTdxEntrustMap) == 0 {
-		return make([]*model.BrokerEntrust, 0), false
-	}
-	// 找到券商委托记录
-	brokerEntrusts := make([]*model.BrokerEntrust, 0)
-	size := 0
-	for _, brokerEntrust := range brokerEntrustsList {
-		if entrust.ID != brokerEntrust.EntrustID {
-			continue
-		}
-		size++
-		tdxEntrusts, ok := brokerTdxEntrustMap[brokerEntrust.BrokerID]
-		if !ok {
-			continue
-		}
-		for _, tdx := range tdxEntrusts {
-			if tdx.EntrustNo != brokerEntrust.BrokerEntrustNo {
-				continue
-			}
-			switch tdx.Status {
-			case "已成":
-				brokerEntrust.Status = model.EntrustStatusTypeDeal
-				brokerEntrust.DealAmount = tdx.DealAmount
-				brokerEntrust.DealPrice = tdx.DealPrice
-				brokerEntrust.DealBalance = float64(brokerEntrust.DealAmount) * brokerEntrust.DealPrice
-			case "部撤":
-				brokerEntrust.Status = model.EntrustStatusTypePartDealPartWithdraw
-				brokerEntrust.DealAmount = tdx.DealAmount
-				brokerEntrust.DealPrice = tdx.DealPrice
-				brokerEntrust.DealBalance = float64(brokerEntrust.DealAmount) * brokerEntrust.DealPrice
-			case "已撤":
-				brokerEntrust.Status = model.EntrustStatusTypeWithdraw
-			case "废单":
-				brokerEntrust.Status = model.EntrustStatusTypeCancel
-			default:
-				// 其他状态则表示非终态
-				return make([]*model.BrokerEntrust, 0), false
-			}
-			brokerEntrusts = append(brokerEntrusts, brokerEntrust)
-		}
-	}
-
-	if size != len(brokerEntrusts) {
-		return make([]*model.BrokerEntrust, 0), false
-	}
-	return brokerEntrusts, true
-}
-
-// BrokerTrade 券商成交 brokerTdxEntrustMap[broker.id][]*model.TDXTodayEntrust
-func (s *TradeService) BrokerTrade(ctx context.Context, brokerTdxEntrustMap map[int64][]*model.TDXTodayEntrust) error {
-	if len(brokerTdxEntrustMap) == 0 {
+// brokerSettle 券商委托全部终态后结算委托:成交、部撤生成买卖记录,已撤解冻,废单作废
+func (s *TradeService) brokerSettle(ctx context.Context, e *model.Entrust) error {
+	if len(e.BrokerEntrust) == 0 {
 		return nil
 	}
-	entrusts, err := dao.EntrustDaoInstance().GetTodayEntrusts(ctx)
-	if err != nil {
-		return err
-	}
-	brokerEntrustsList, err := dao.BrokerEntrustDaoInstance().GetTodayEntrusts(ctx)
-	if err != nil {
-		return err
+	log.Infof("委托订单[entrust]:%+v", e)
+	for _, brokerEntrust := range e.BrokerEntrust {
+		log.Infof("券商委托订单[broker_entrust]:%+v", brokerEntrust)
 	}
-	for _, entrust := range entrusts {
-		// 非券商委托不处理
-		if !entrust.IsBrokerEntrust {
-			continue
-		}
-		// 终态不处理
-		if entrust.IsFinallyState() {
-			continue
-		}
-		brokerEntrusts, ok := s.isDeal(entrust, brokerEntrustsList, brokerTdxEntrustMap)
-		if !ok {
-			continue
-		}
-		e := s.genEntrust(ctx, entrust, brokerEntrusts)
-		if len(e.BrokerEntrust) == 0 {
-			continue
-		}
-		log.Infof("委托订单[entrust]:%+v", e)
-		for _, brokerEntrust := range e.BrokerEntrust {
-			log.Infof("券商委托订单[broker_entrust]:%+v", brokerEntrust)
-		}
-		switch e.Status {
-		case model.EntrustStatusTypeDeal:
-			{
-				// 已成
-				if err := s.brokerEntrustDeal(ctx, e); err != nil {
-					log.Errorf("brokerEntrustDeal err:%+v", err)
-					return err
-				}
-				log.Infof("已成:%+v", e)
+	switch e.Status {
+	case model.EntrustStatusTypeDeal:
+		{
+			// 已成
+			if err := s.brokerEntrustDeal(ctx, e); err != nil {
+				log.Errorf("brokerEntrustDeal err:%+v", err)
+				return err
 			}
-		case model.EntrustStatusTypePartDealPartWithdraw:
-			{
-				// 部撤
-				if err := s.brokerEntrustDeal(ctx, e); err != nil {
-					log.Errorf("brokerEntrustDeal err:%+v", err)
-					return err
-				}
-				log.Infof("部撤:%+v", e)
+			log.Infof("已成:%+v", e)
+		}
+	case model.EntrustStatusTypePartDealPartWithdraw:
+		{
+			// 部撤
+			if err := s.brokerEntrustDeal(ctx, e); err != nil {
+				log.Errorf("brokerEntrustDeal err:%+v", err)
+				return err
 			}
-		case model.EntrustStatusTypeWithdraw:
-			{
-				// 已撤
-				if err := s.brokerEntrustWithdraw(ctx, e); err != nil {
-					log.Errorf("brokerEntrustWithdraw err:%+v", err)
-					return err
-				}
-				log.Infof("撤单:%+v", e)
+			log.Infof("部撤:%+v", e)
+		}
+	case model.EntrustStatusTypeWithdraw:
+		{
+			// 已撤
+			if err := s.brokerEntrustWithdraw(ctx, e); err != nil {
+				log.Errorf("brokerEntrustWithdraw err:%+v", err)
+				return err
 			}
-		case model.EntrustStatusTypeCancel:
-			{
-				// 废单
-				if err := s.brokerCancelOrder(ctx, e); err != nil {
-					log.Errorf("brokerCancelOrder err:%+v", err)
-					return err
-				}
-				log.Infof("废单:%+v", e)
+			log.Infof("撤单:%+v", e)
+		}
+	case model.EntrustStatusTypeCancel:
+		{
+			// 废单
+			if err := s.brokerCancelOrder(ctx, e); err != nil {
+				log.Errorf("brokerCancelOrder err:%+v", err)
+				return err
 			}
+			log.Infof("废单:%+v", e)
 		}
 	}
 	return nil
@@ -231,23 +159,23 @@ func (s *TradeService) BrokerTrade(ctx context.Context, brokerTdxEntrustMap map[
 func (s *TradeService) brokerEntrustDeal(ctx context.Context, entrust *model.Entrust) error {
 	// 幂等:防止重复提交订单
 	key := fmt.Sprintf("broker_entrust_deal_entrust_id_%+v", entrust.ID)
-	if db.RedisClient().Exists(ctx, key).Val() == 1 {
+	if s.core.Cache.Exists(ctx, key).Val() == 1 {
 		log.Errorf("订单异常:%+v 正在处理该笔订单!", entrust)
 		return nil
 	}
-	db.RedisClient().SetNX(ctx, key, "1", 1*time.Minute)
-	defer db.RedisClient().Del(ctx, key)
+	s.core.Cache.SetNX(ctx, key, "1", 1*time.Minute)
+	defer s.core.Cache.Del(ctx, key)
 
 	// 买入成交
 	if entrust.EntrustBS == model.EntrustBsTypeBuy {
-		if err := BuyServiceInstance().CreateOrder(ctx, entrust); err != nil {
+		if err := s.buy.CreateOrder(ctx, entrust); err != nil {
 			log.Errorf("买入成交订单处理失败:%+v", err)
 			return err
 		}
 	}
 	// 卖出成交
 	if entrust.EntrustBS == model.EntrustBsTypeSell {
-		if err := SellServiceInstance().CreateOrder(ctx, entrust); err != nil {
+		if err := s.sell.CreateOrder(ctx, entrust); err != nil {
 			log.Errorf("卖出成交订单处理失败:%+v", err)
 		}
 	}
@@ -259,26 +187,26 @@ func (s *TradeService) brokerEntrustDeal(ctx context.Context, entrust *model.Ent
 func (s *TradeService) brokerEntrustWithdraw(ctx context.Context, entrust *model.Entrust) error {
 	// 幂等:防止重复提交订单
 	key := fmt.Sprintf("broker_entrust_withdraw_entrust_id_%+v", entrust.ID)
-	if db.RedisClient().Exists(ctx, key).Val() == 1 {
+	if s.core.Cache.Exists(ctx, key).Val() == 1 {
 		log.Errorf("订单异常:%+v 正在处理该笔订单!", entrust)
 		return nil
 	}
-	db.RedisClient().SetNX(ctx, key, "1", 1*time.Minute)
-	defer db.RedisClient().Del(ctx, key)
+	s.core.Cache.SetNX(ctx, key, "1", 1*time.Minute)
+	defer s.core.Cache.Del(ctx, key)
 
-	tx := db.StockDB().WithContext(ctx).Begin()
+	tx := s.core.Tx.Begin(ctx)
 	defer tx.Rollback()
 
 	// 券商委托表设置撤单状态
 	if entrust.IsBrokerEntrust && len(entrust.BrokerEntrust) > 0 {
-		if err := dao.BrokerEntrustDaoInstance().MCreateWithTx(tx, entrust.BrokerEntrust); err != nil {
+		if err := s.core.BrokerEntrust.MCreateWithTx(tx, entrust.BrokerEntrust); err != nil {
 			log.Errorf("券商委托表更新失败:%+v", err)
 			return err
 		}
 		log.Infof("撤单业务:委托编号:%+v [broker_entrust]更新券商委托表:%+v", entrust.ID, entrust.BrokerEntrust)
 	}
 
-	if err := dao.EntrustDaoInstance().UpdateStatusWithTx(tx, entrust); err != nil {
+	if err := s.core.Entrust.UpdateStatusWithTx(tx, entrust); err != nil {
 		log.Errorf("Update err:%+v", err)
 		return err
 	}
@@ -286,7 +214,7 @@ func (s *TradeService) brokerEntrustWithdraw(ctx context.Context, entrust *model
 
 	// 卖出撤单,解冻股票
 	if entrust.EntrustBS == model.EntrustBsTypeSell {
-		if err := dao.PositionDaoInstance().UnFreezeAmountWithTx(tx, entrust.ContractID, entrust.StockCode, entrust.Amount); err != nil {
+		if err := s.core.Position.UnFreezeAmountWithTx(tx, entrust.ContractID, entrust.StockCode, entrust.Amount); err != nil {
 			log.Errorf("解冻股票失败:%+v", err)
 			return serr.ErrBusiness("撤单失败")
 		}
@@ -299,7 +227,7 @@ func (s *TradeService) brokerEntrustWithdraw(ctx context.Context, entrust *model
 	}
 	log.Infof("撤单业务:委托编号:%+v 提交成功!", entrust.ID)
 
-	if err := ContractServiceInstance().UpdateValMoneyByID(ctx, entrust.ContractID); err != nil {
+	if err := s.contract.UpdateValMoneyByID(ctx, entrust.ContractID); err != nil {
 		log.Errorf("UpdateValMoneyByID err:%+v", err)
 	}
 	return nil
@@ -309,29 +237,29 @@ func (s *TradeService) brokerEntrustWithdraw(ctx context.Context, entrust *model
 func (s *TradeService) brokerCancelOrder(ctx context.Context, entrust *model.Entrust) error {
 	// 幂等:防止重复提交订单
 	key := fmt.Sprintf("broker_entrust_cancel_order_entrust_id_%+v", entrust.ID)
-	if db.RedisClient().Exists(ctx, key).Val() == 1 {
+	if s.core.Cache.Exists(ctx, key).Val() == 1 {
 		log.Errorf("订单异常:%+v 正在处理该笔订单!", entrust)
 		return nil
 	}
-	db.RedisClient().SetNX(ctx, key, "1", 1*time.Minute)
-	defer db.RedisClient().Del(ctx, key)
+	s.core.Cache.SetNX(ctx, key, "1", 1*time.Minute)
+	defer s.core.Cache.Del(ctx, key)
 
-	tx := db.StockDB().WithContext(ctx).Begin()
+	tx := s.core.Tx.Begin(ctx)
 	defer tx.Rollback()
 	// 更新委托状态
-	if err := dao.EntrustDaoInstance().UpdateWithTx(tx, entrust); err != nil {
+	if err := s.core.Entrust.UpdateWithTx(tx, entrust); err != nil {
 		log.Errorf("UpdateWithTx err:%+v", err)
 		return err
 	}
 	// 设置券商委托表
-	if err := dao.BrokerEntrustDaoInstance().MCreateWithTx(tx, entrust.BrokerEntrust); err != nil {
+	if err := s.core.BrokerEntrust.MCreateWithTx(tx, entrust.BrokerEntrust); err != nil {
 		log.Errorf("Update err:%+v", err)
 		return err
 	}
 
 	// 卖出废单,解除冻结股数
 	if entrust.EntrustBS == model.EntrustBsTypeSell {
-		if err := dao.PositionDaoInstance().UnFreezeAmountWithTx(tx, entrust.ContractID, entrust.StockCode, entrust.Amount); err != nil {
+		if err := s.core.Position.UnFreezeAmountWithTx(tx, entrust.ContractID, entrust.StockCode, entrust.Amount); err != nil {
 			log.Errorf("解冻股票失败:%+v", err)
 			return serr.ErrBusiness("撤单失败")
 		}
@@ -344,71 +272,118 @@ func (s *TradeService) brokerCancelOrder(ctx context.Context, entrust *model.Ent
 	}
 
 	// 更新可用资金
-	if err := ContractServiceInstance().UpdateValMoneyByID(ctx, entrust.ContractID); err != nil {
+	if err := s.contract.UpdateValMoneyByID(ctx, entrust.ContractID); err != nil {
 		log.Errorf("UpdateValMoneyByID err:%+v", err)
 	}
 	return nil
 }
 
-// autoTrade 自动成交
+// autoTrade 自动成交:非券商委托按系统参数选择的撮合方式模拟成交
 func (s *TradeService) autoTrade(ctx context.Context) error {
 	// 是否交易时间
-	if !CalendarServiceInstance().IsTradeTime(ctx) {
+	if !s.core.Calendar.IsTradeTime(ctx) {
 		return nil
 	}
 	// 查询今日委托记录
-	entrusts, err := dao.EntrustDaoInstance().GetTodayEntrusts(ctx)
+	entrusts, err := s.core.Entrust.GetTodayEntrusts(ctx)
 	if err != nil {
 		return err
 	}
 	if len(entrusts) == 0 {
 		return nil
 	}
+	// 未成交、部分成交的非券商委托按股票分组
 	codes := make([]string, 0)
+	entrustMap := make(map[string][]*model.Entrust)
 	for _, it := range entrusts {
-		if it.Status == model.EntrustStatusTypeUnDeal && !it.IsBrokerEntrust {
+		if it.IsBrokerEntrust || (it.Status != model.EntrustStatusTypeUnDeal && it.Status != model.EntrustStatusTypePartDeal) {
+			continue
+		}
+		if _, ok := entrustMap[it.StockCode]; !ok {
 			codes = append(codes, it.StockCode)
 		}
+		entrustMap[it.StockCode] = append(entrustMap[it.StockCode], it)
 	}
 	if len(codes) == 0 {
 		return nil
 	}
-	qts, err := quote.QtServiceInstance().GetQuoteByTencent(codes)
+	sys, err := s.core.Sys.GetSysParam(ctx)
+	if err != nil {
+		return err
+	}
+	qts, err := s.core.Quote.GetQuoteByTencent(codes)
 	if err != nil {
 		return err
 	}
 
-	for _, entrust := range entrusts {
-		// 券商委托,部分成交状态则不进行自动成交
-		if entrust.Status != model.EntrustStatusTypeUnDeal || entrust.IsBrokerEntrust {
-			continue
-		}
-		qt, ok := qts[entrust.StockCode]
+	matcher := NewMatcher(sys)
+	for _, code := range codes {
+		qt, ok := qts[code]
 		if !ok {
 			continue
 		}
-		// 买入成交
-		if entrust.EntrustBS == model.EntrustBsTypeBuy && qt.CurrentPrice <= entrust.Price {
-			entrust.DealAmount = entrust.Amount // 全部成交
-			entrust.Status = model.EntrustStatusTypeDeal
-			if err := BuyServiceInstance().CreateOrder(ctx, entrust); err != nil {
-				log.Errorf("买入成交订单处理失败:%+v", err)
-				return err
+		// 时间优先
+		list := entrustMap[code]
+		sort.SliceStable(list, func(i, j int) bool {
+			if list[i].OrderTime.Equal(list[j].OrderTime) {
+				return list[i].ID < list[j].ID
 			}
-		}
-
-		// 卖出成交
-		if entrust.EntrustBS == model.EntrustBsTypeSell && qt.CurrentPrice >= entrust.Price {
-			entrust.DealAmount = entrust.Amount // 全部成交
-			entrust.Status = model.EntrustStatusTypeDeal
-			if err := SellServiceInstance().CreateOrder(ctx, entrust); err != nil {
-				log.Errorf("卖出成交订单处理失败:%+v", err)
+			return list[i].OrderTime.Before(list[j].OrderTime)
+		})
+		band := s.priceBand.Band(qt)
+		for _, result := range matcher.Match(qt, list) {
+			// 市价委托成交价格不超出涨跌停价格区间
+			if result.Entrust.EntrustProp == model.EntrustPropTypeMarketPrice {
+				result.Price = band.Clamp(result.Price)
+			}
+			if err := s.simulateDeal(ctx, result); err != nil {
+				log.Errorf("模拟成交处理失败,委托编号:%d err:%+v", result.Entrust.ID, err)
 			}
 		}
 	}
 	return nil
 }
 
+// simulateDeal 模拟成交:委托表累计成交数量、成交金额和手续费,持仓和资金按本次成交处理
+func (s *TradeService) simulateDeal(ctx context.Context, result *MatchResult) error {
+	entrust := result.Entrust
+	// 未成交时委托表的金额、手续费为委托时的预估值,不计入累计
+	var dealBalance, dealFee float64
+	if entrust.DealAmount > 0 {
+		dealBalance = entrust.Balance
+		dealFee = entrust.Fee
+	}
+	dealAmount := entrust.DealAmount + result.Amount
+	dealBalance += result.Price * float64(result.Amount)
+	fee, err := s.GetTradeFee(ctx, dealBalance/float64(dealAmount), dealAmount, entrust.EntrustBS)
+	if err != nil {
+		return err
+	}
+
+	entrust.Fill = &model.EntrustFill{
+		Amount:   result.Amount,
+		Price:    result.Price,
+		Fee:      math.Max(fee-dealFee, 0),
+		Unfreeze: result.Amount,
+	}
+	entrust.DealAmount = dealAmount
+	entrust.Balance = dealBalance
+	entrust.Fee = fee
+	if dealAmount >= entrust.Amount {
+		// 全部成交,价格更新为成交均价
+		entrust.Status = model.EntrustStatusTypeDeal
+		entrust.Price = util.FloatRound(dealBalance/float64(dealAmount), 3)
+	} else {
+		// 部分成交,价格保留委托价格,用于继续撮合
+		entrust.Status = model.EntrustStatusTypePartDeal
+	}
+
+	if entrust.EntrustBS == model.EntrustBsTypeBuy {
+		return s.buy.CreateOrder(ctx, entrust)
+	}
+	return s.sell.CreateOrder(ctx, entrust)
+}
+
 func (s *TradeService) InitTrade(ctx context.Context, uid, contractID int64) (*model.InitTrade, error) {
 	// 查询证券账户是否存在
 	// 不存在则查找已选择的账户是否存在
@@ -427,7 +402,7 @@ func (s *TradeService) InitTrade(ctx context.Context, uid, contractID int64) (*m
 	positions := make([]*model.Position, 0)
 	wg := errgroup.GroupWithCount(4)
 	wg.Go(func() error {
-		ret, err := PositionServiceInstance().GetPositionByContractID(ctx, contractID)
+		ret, err := s.position.GetPositionByContractID(ctx, contractID)
 		if err != nil {
 			log.Error("GetPositionByContractID error:%+v", err)
 			return err
@@ -437,7 +412,7 @@ func (s *TradeService) InitTrade(ctx context.Context, uid, contractID int64) (*m
 	})
 	contract := &model.Contract{}
 	wg.Go(func() error {
-		ret, err := dao.ContractDaoInstance().GetContractByID(ctx, contractID)
+		ret, err := s.core.Contract.GetContractByID(ctx, contractID)
 		if err != nil {
 			return err
 		}
@@ -448,7 +423,7 @@ func (s *TradeService) InitTrade(ctx context.Context, uid, contractID int64) (*m
 	// 上个交易日的持仓
 	lastDayPosition := make([]*model.Position, 0)
 	wg.Go(func() error {
-		ret, err := dao.HisPositionDaoInstance().GetYesterdayPositionByContractID(ctx, contractID)
+		ret, err := s.core.HisPosition.GetYesterdayPositionByContractID(ctx, contractID)
 		if err != nil {
 			return err
 		}
@@ -458,7 +433,7 @@ func (s *TradeService) InitTrade(ctx context.Context, uid, contractID int64) (*m
 	// 今日盈亏
 	var todayProfit float64
 	wg.Go(func() error {
-		ret, err := ContractServiceInstance().GetTodayProfitByContractID(ctx, contractID)
+		ret, err := s.contract.GetTodayProfitByContractID(ctx, contractID)
 		if err != nil {
 			log.Errorf("GetTodayProfitByContractID err:%+v", err)
 			return err
@@ -480,7 +455,7 @@ func (s *TradeService) InitTrade(ctx context.Context, uid, contractID int64) (*m
 		for _, it := range lastDayPosition {
 			codes = append(codes, it.StockCode)
 		}
-		qts, err := quote.QtServiceInstance().GetQuoteByTencent(codes)
+		qts, err := s.core.Quote.GetQuoteByTencent(codes)
 		if err != nil {
 			return nil, err
 		}
@@ -511,25 +486,25 @@ func (s *TradeService) InitTrade(ctx context.Context, uid, contractID int64) (*m
 
 // getValidContract 查询用户有效的合约ID
 func (s *TradeService) getValidContract(ctx context.Context, uid int64) (*model.Contract, error) {
-	user, err := dao.UserDaoInstance().GetUserByUID(ctx, uid)
+	user, err := s.core.User.GetUserByUID(ctx, uid)
 	if err != nil {
 		return nil, err
 	}
 	if user.CurrentContractID != 0 {
-		contract, err := dao.ContractDaoInstance().GetContractByID(ctx, user.CurrentContractID)
+		contract, err := s.core.Contract.GetContractByID(ctx, user.CurrentContractID)
 		if err == nil && contract.Status == model.ContractStatusEnable {
 			return contract, nil
 		}
 	}
 
 	// 任意一个contractID
-	contract, err := dao.ContractDaoInstance().GetEnableContractByUID(ctx, uid)
+	contract, err := s.core.Contract.GetEnableContractByUID(ctx, uid)
 	if err != nil {
 		return nil, serr.New(serr.ErrCodeContractNoFound, "暂无有效合约")
 	}
 
 	// 更新用户当前合约id
-	if err := dao.UserDaoInstance().UpdateCurrentContractID(ctx, uid, contract.ID); err != nil {
+	if err := s.core.User.UpdateCurrentContractID(ctx, uid, contract.ID); err != nil {
 		log.Errorf("更新用户当前合约ID失败:%+v", err)
 	}
 	return contract, nil
@@ -567,7 +542,7 @@ func (s *TradeService) PositionDetail(ctx context.Context, positionID int64) (*m
 	// 查询买入
 	buy := make([]*model.Buy, 0)
 	wg.Go(func() error {
-		ret, err := dao.BuyDaoInstance().GetBuyByPositionIDs(ctx, []int64{positionID})
+		ret, err := s.core.Buy.GetBuyByPositionIDs(ctx, []int64{positionID})
 		if err != nil {
 			log.Errorf("查询GetBuyByPositionIDs错误:%+v", err)
 			return err
@@ -581,7 +556,7 @@ func (s *TradeService) PositionDetail(ctx context.Context, positionID int64) (*m
 	// 卖出
 	sell := make([]*model.Sell, 0)
 	wg.Go(func() error {
-		ret, err := dao.SellDaoInstance().GetByPositionIDs(ctx, []int64{positionID})
+		ret, err := s.core.Sell.GetByPositionIDs(ctx, []int64{positionID})
 		if err != nil {
 			log.Errorf("查询GetByPositionIDs err:%+v", err)
 			return err
@@ -593,7 +568,7 @@ func (s *TradeService) PositionDetail(ctx context.Context, positionID int64) (*m
 	// 查询分红
 	dividend := make([]*model.Dividend, 0)
 	wg.Go(func() error {
-		ret, err := dao.DividendDaoInstance().GetDividendByPositionIDs(ctx, positionID)
+		ret, err := s.core.Dividend.GetDividendByPositionIDs(ctx, positionID)
 		if err != nil {
 			log.Errorf("查询GetDividendByPositionIDs:%+v", err)
 			return err
@@ -604,7 +579,7 @@ func (s *TradeService) PositionDetail(ctx context.Context, positionID int64) (*m
 	// 查询持仓
 	position := &model.Position{}
 	wg.Go(func() error {
-		ret, err := dao.PositionDaoInstance().GetPositionByID(ctx, positionID)
+		ret, err := s.core.Position.GetPositionByID(ctx, positionID)
 		if err != nil {
 			if err == gorm.ErrRecordNotFound {
 				return nil
@@ -642,7 +617,7 @@ func (s *TradeService) PositionDetail(ctx context.Context, positionID int64) (*m
 
 	// 有持仓情况
 	if position.ID != 0 {
-		qts, err := quote.QtServiceInstance().GetQuoteByTencent([]string{position.StockCode})
+		qts, err := s.core.Quote.GetQuoteByTencent([]string{position.StockCode})
 		if err != nil {
 			return nil, serr.ErrBusiness("查询持仓明细失败")
 		}
@@ -711,7 +686,7 @@ func (s *TradeService) positionDetailList(buyRecord []*model.Buy, sellRecord []*
 
 // TodayDeal 今日成交
 func (s *TradeService) TodayDeal(ctx context.Context, contractID int64) ([]*model.TradeDeal, error) {
-	entrusts, err := dao.EntrustDaoInstance().GetTodayEntrust(ctx, contractID)
+	entrusts, err := s.core.Entrust.GetTodayEntrust(ctx, contractID)
 	if err != nil {
 		return nil, err
 	}
@@ -739,7 +714,7 @@ func (s *TradeService) TodayDeal(ctx context.Context, contractID int64) ([]*mode
 
 // HistoryDeal 查询历史成交
 func (s *TradeService) HistoryDeal(ctx context.Context, contractID int64) ([]*model.TradeDeal, error) {
-	entrusts, err := dao.EntrustDaoInstance().GetEntrustByContractID(ctx, contractID)
+	entrusts, err := s.core.Entrust.GetEntrustByContractID(ctx, contractID)
 	if err != nil {
 		return nil, serr.ErrBusiness("查询记录失败")
 	}
@@ -767,7 +742,7 @@ func (s *TradeService) HistoryDeal(ctx context.Context, contractID int64) ([]*mo
 
 // ContractFee 查询合约费用单
 func (s *TradeService) ContractFee(ctx context.Context, contractID int64) ([]*model.Fee, error) {
-	contractFee, err := dao.ContractFeeDaoInstance().GetContractFeeByID(ctx, contractID)
+	contractFee, err := s.core.ContractFee.GetContractFeeByID(ctx, contractID)
 	if err != nil {
 		log.Errorf("GetContractFeeByID失败:%+v", err)
 		return nil, serr.ErrBusiness("查询费用失败")
@@ -775,14 +750,14 @@ func (s *TradeService) ContractFee(ctx context.Context, contractID int64) ([]*mo
 	sort.SliceStable(contractFee, func(i, j int) bool {
 		return timeconv.TimeToInt64(contractFee[i].OrderTime) > timeconv.TimeToInt64(contractFee[j].OrderTime)
 	})
-	contract, err := dao.ContractDaoInstance().GetContractByID(ctx, contractID)
+	contract, err := s.core.Contract.GetContractByID(ctx, contractID)
 	if err != nil {
 		return nil, err
 	}
 	result := make([]*model.Fee, 0)
 	for _, it := range contractFee {
-		// 只下发买入、卖出手续费、合约利息
-		if it.Type != model.ContractFeeTypeBuy && it.Type != model.ContractFeeTypeSell && it.Type != model.ContractFeeTypeInterest {
+		// 只下发买入、卖出手续费、合约利息、逆回购手续费
+		if it.Type != model.ContractFeeTypeBuy && it.Type != model.ContractFeeTypeSell && it.Type != model.ContractFeeTypeInterest && it.Type != model.ContractFeeTypeRepoFee {
 			continue
 		}
 		name := it.Name
@@ -802,7 +777,7 @@ func (s *TradeService) ContractFee(ctx context.Context, contractID int64) ([]*mo
 
 // TradeDetail 交易-成交明细
 func (s *TradeService) TradeDetail(ctx context.Context, entrustID int64) (*model.TradeDetail, error) {
-	entrust, err := dao.EntrustDaoInstance().GetEntrustByID(ctx, entrustID)
+	entrust, err := s.core.Entrust.GetEntrustByID(ctx, entrustID)
 	if err != nil {
 		log.Errorf("GetEntrustByID失败:%+v", err)
 		return nil, serr.ErrBusiness("查询失败")
@@ -839,13 +814,13 @@ func (s *TradeService) Position(ctx context.Context, uid, contractID int64) (*mo
 		contractID = contract.ID
 	}
 
-	contract, err := dao.ContractDaoInstance().GetContractByID(ctx, contractID)
+	contract, err := s.core.Contract.GetContractByID(ctx, contractID)
 	if err != nil {
 		return nil, err
 	}
 
 	// 查询持仓
-	position, err := PositionServiceInstance().GetPositionByContractID(ctx, contract.ID)
+	position, err := s.position.GetPositionByContractID(ctx, contract.ID)
 	if err != nil {
 		log.Error("GetPositionByContractID error:%+v", err)
 		return nil, err
@@ -868,11 +843,11 @@ func (s *TradeService) InitBuy(ctx context.Context, uid, contractID int64, code
 		}
 		contractID = contract.ID
 	}
-	contract, err := dao.ContractDaoInstance().GetContractByID(ctx, contractID)
+	contract, err := s.core.Contract.GetContractByID(ctx, contractID)
 	if err != nil {
 		return nil, err
 	}
-	qt, err := quote.QtServiceInstance().GetQuoteByTencent([]string{code})
+	qt, err := s.core.Quote.GetQuoteByTencent([]string{code})
 	if err != nil {
 		return nil, serr.ErrBusiness("证券代码不存在")
 	}
@@ -886,6 +861,7 @@ func (s *TradeService) InitBuy(ctx context.Context, uid, contractID int64, code
 		ContractName: contract.FullName(),
 		MaxBuyAmount: maxBuyAmount,                  // 最大可买数量
 		PanKou:       model.ConvertPanKou(qt[code]), // 盘口信息
+		PriceBand:    s.priceBand.Band(qt[code]),    // 涨跌停价格区间
 	}, nil
 }
 
@@ -898,7 +874,7 @@ func (s *TradeService) maxBuyAmount(ctx context.Context, contract *model.Contrac
 	if maxBuyAmount <= 0 {
 		return 0, nil
 	}
-	sys, err := dao.SysDaoInstance().GetSysParam(ctx)
+	sys, err := s.core.Sys.GetSysParam(ctx)
 	if err != nil {
 		log.Errorf("获取系统参数失败:%+v", err)
 		return 0, err
@@ -945,7 +921,7 @@ func (s *TradeService) maxBuyAmount(ctx context.Context, contract *model.Contrac
 	var stockData *model.StockData
 	wg.Go(func() error {
 		// 股票代码是否存在&是否允许交易
-		ret, err := StockDataServiceInstance().GetStockDataByCode(ctx, stock.Code)
+		ret, err := s.stockData.GetStockDataByCode(ctx, stock.Code)
 		if err != nil {
 			log.Errorf("GetStockDataByCode err:%+v", err)
 			return err
@@ -957,7 +933,7 @@ func (s *TradeService) maxBuyAmount(ctx context.Context, contract *model.Contrac
 	isWarn := false
 	wg.Go(func() error {
 		// 低于警戒线是否允许开仓
-		level, err := ContractServiceInstance().GetContractRiskLevel(ctx, contract)
+		level, err := s.contract.GetContractRiskLevel(ctx, contract)
 		if err != nil {
 			log.Errorf("IsWarnContract err:%+v", err)
 			return err
@@ -969,7 +945,7 @@ func (s *TradeService) maxBuyAmount(ctx context.Context, contract *model.Contrac
 	})
 	var positions []*model.Position
 	wg.Go(func() error {
-		ret, err := dao.PositionDaoInstance().GetPositionByContractID(ctx, contract.ID)
+		ret, err := s.core.Position.GetPositionByContractID(ctx, contract.ID)
 		if err != nil {
 			log.Errorf("GetPositionByContractID err:%+v", err)
 			return err
@@ -979,7 +955,7 @@ func (s *TradeService) maxBuyAmount(ctx context.Context, contract *model.Contrac
 	})
 	var entrusts []*model.Entrust
 	wg.Go(func() error {
-		list, err := dao.EntrustDaoInstance().GetTodayEntrust(ctx, contract.ID)
+		list, err := s.core.Entrust.GetTodayEntrust(ctx, contract.ID)
 		if err != nil {
 			return err
 		}
@@ -1010,8 +986,8 @@ func (s *TradeService) maxBuyAmount(ctx context.Context, contract *model.Contrac
 		}
 		// 减去买入委托未成交的股票数量
 		for _, it := range entrusts {
-			if it.StockCode == stock.Code && it.EntrustBS == model.EntrustBsTypeBuy && it.Status == model.EntrustStatusTypeUnDeal {
-				maxAmount -= it.Amount
+			if it.StockCode == stock.Code && it.EntrustBS == model.EntrustBsTypeBuy && (it.Status == model.EntrustStatusTypeUnDeal || it.Status == model.EntrustStatusTypePartDeal) {
+				maxAmount -= it.Amount - it.DealAmount
 			}
 		}
 		maxAmount = (maxAmount / 100) * 100
@@ -1028,7 +1004,7 @@ func (s *TradeService) maxBuyAmount(ctx context.Context, contract *model.Contrac
 
 // HoldZeroShare 是否持有0股,true返回是,false返回否
 func (s *TradeService) HoldZeroShare(ctx context.Context, contractID int64) bool {
-	positions, err := dao.PositionDaoInstance().GetPositionByContractID(ctx, contractID)
+	positions, err := s.core.Position.GetPositionByContractID(ctx, contractID)
 	if err != nil {
 		return false
 	}
@@ -1043,23 +1019,23 @@ func (s *TradeService) HoldZeroShare(ctx context.Context, contractID int64) bool
 // Buy 交易:买入
 func (s *TradeService) Buy(ctx context.Context, p *model.EntrustPackage) error {
 	// 检查是否委托时间
-	if !CalendarServiceInstance().IsEntrustTime(ctx) {
+	if !s.core.Calendar.IsEntrustTime(ctx) {
 		return serr.ErrBusiness("委托失败:非交易时间")
 	}
 
 	// 幂等:防止重复提交订单
 	key := fmt.Sprintf("buy_lock_contract_id_%+v", p.ContractID)
-	if db.RedisClient().Exists(ctx, key).Val() == 1 {
+	if s.core.Cache.Exists(ctx, key).Val() == 1 {
 		log.Errorf("重复提交订单:%+v", p)
 		return nil
 	}
-	db.RedisClient().SetNX(ctx, key, "1", 1*time.Minute)
-	defer db.RedisClient().Del(ctx, key)
+	s.core.Cache.SetNX(ctx, key, "1", 1*time.Minute)
+	defer s.core.Cache.Del(ctx, key)
 
 	eg := errgroup.GroupWithCount(3)
 	var contract *model.Contract
 	eg.Go(func() error {
-		c, err := dao.ContractDaoInstance().GetContractByID(ctx, p.ContractID)
+		c, err := s.core.Contract.GetContractByID(ctx, p.ContractID)
 		if err != nil {
 			return serr.ErrBusiness("委托失败:合约不存在")
 		}
@@ -1068,7 +1044,7 @@ func (s *TradeService) Buy(ctx context.Context, p *model.EntrustPackage) error {
 	})
 	var sys *model.SysParam
 	eg.Go(func() error {
-		ret, err := dao.SysDaoInstance().GetSysParam(ctx)
+		ret, err := s.core.Sys.GetSysParam(ctx)
 		if err != nil {
 			return serr.ErrBusiness("委托失败")
 		}
@@ -1077,7 +1053,7 @@ func (s *TradeService) Buy(ctx context.Context, p *model.EntrustPackage) error {
 	})
 	var stock *model.TencentQuote
 	eg.Go(func() error {
-		ret, err := quote.QtServiceInstance().GetQuoteByTencent([]string{p.Code})
+		ret, err := s.core.Quote.GetQuoteByTencent([]string{p.Code})
 		if err != nil {
 			return err
 		}
@@ -1145,12 +1121,12 @@ func (s *TradeService) Buy(ctx context.Context, p *model.EntrustPackage) error {
 	wg.Go(func() error {
 		// 单只股票最大持仓生效:检查允许买入最大股数
 		if sys.SinglePositionPct > 0 && sys.SinglePositionPct < 1 {
-			positions, err := dao.PositionDaoInstance().GetPositionByContractID(ctx, contract.ID)
+			positions, err := s.core.Position.GetPositionByContractID(ctx, contract.ID)
 			if err != nil {
 				log.Errorf("GetPositionByContractID err:%+v", err)
 				return serr.ErrBusiness("委托失败")
 			}
-			entrusts, err := dao.EntrustDaoInstance().GetTodayEntrust(ctx, contract.ID)
+			entrusts, err := s.core.Entrust.GetTodayEntrust(ctx, contract.ID)
 			if err != nil {
 				log.Errorf("GetTodayEntrust err:%+v", err)
 				return serr.ErrBusiness("委托失败")
@@ -1166,8 +1142,8 @@ func (s *TradeService) Buy(ctx context.Context, p *model.EntrustPackage) error {
 			}
 			// 减去买入委托未成交的股票数量
 			for _, it := range entrusts {
-				if it.StockCode == stock.Code && it.EntrustBS == model.EntrustBsTypeBuy && it.Status == model.EntrustStatusTypeUnDeal {
-					maxAmount -= it.Amount
+				if it.StockCode == stock.Code && it.EntrustBS == model.EntrustBsTypeBuy && (it.Status == model.EntrustStatusTypeUnDeal || it.Status == model.EntrustStatusTypePartDeal) {
+					maxAmount -= it.Amount - it.DealAmount
 				}
 			}
 			maxAmount = (maxAmount / 100) * 100
@@ -1180,14 +1156,14 @@ func (s *TradeService) Buy(ctx context.Context, p *model.EntrustPackage) error {
 	})
 	wg.Go(func() error {
 		// 股票代码是否存在&是否允许交易
-		if err := StockDataServiceInstance().IsTrade(ctx, p.Code); err != nil {
+		if err := s.stockData.IsTrade(ctx, p.Code); err != nil {
 			return serr.ErrBusiness("委托失败,[风控提示]该股票不可交易")
 		}
 		return nil
 	})
 	wg.Go(func() error {
 		// 低于警戒线是否允许开仓
-		level, err := ContractServiceInstance().GetContractRiskLevel(ctx, contract)
+		level, err := s.contract.GetContractRiskLevel(ctx, contract)
 		if err != nil {
 			log.Errorf("IsWarnContract err:%+v", err)
 			return serr.ErrBusiness("委托失败")
@@ -1202,20 +1178,21 @@ func (s *TradeService) Buy(ctx context.Context, p *model.EntrustPackage) error {
 	}
 
 	// 价格检查
+	band := s.priceBand.Band(stock)
 	if p.EntrustProp == model.EntrustPropTypeLimitPrice {
+		// 限价委托,委托价格必须在涨跌停价格区间内
+		if err := s.priceBand.Check(band, p.Price); err != nil {
+			return err
+		}
 		// 限价委托,如果委托价格大于市价则以市价为准
 		if p.Price > stock.CurrentPrice {
 			p.Price = stock.CurrentPrice
 		}
-		// 限价委托,如果委托价格小于跌停价,则报错
-		if p.Price < stock.LimitDownPrice {
-			return serr.ErrBusiness("委托失败:委托价格不能低于跌停价")
-		}
 	}
 
-	// 市价委托,设置当前价格=市价
+	// 市价委托,设置当前价格=市价(不超出涨跌停价格区间)
 	if p.EntrustProp == model.EntrustPropTypeMarketPrice {
-		p.Price = stock.CurrentPrice
+		p.Price = band.Clamp(stock.CurrentPrice)
 	}
 
 	// 获取交易手续费
@@ -1237,6 +1214,7 @@ func (s *TradeService) Buy(ctx context.Context, p *model.EntrustPackage) error {
 		EntrustBS:   model.EntrustBsTypeBuy,        // 交易类型:1买入 2卖出
 		EntrustProp: p.EntrustProp,                 // 委托类型:1限价 2市价
 		Fee:         fee,                           // 总交易费用
+		Mode:        p.Mode,                        // 类型:0 用户委托 2条件单触发
 	}
 
 	// 券商委托
@@ -1245,21 +1223,21 @@ func (s *TradeService) Buy(ctx context.Context, p *model.EntrustPackage) error {
 	}
 
 	// 创建委托表
-	e, err := dao.EntrustDaoInstance().Create(ctx, entrust)
+	e, err := s.core.Entrust.Create(ctx, entrust)
 	if err != nil {
 		log.Errorf("买入创建委托表失败:%+v", err)
 		return serr.ErrBusiness("委托失败")
 	}
 
 	// 更新可用资金
-	if err := ContractServiceInstance().UpdateValMoneyByID(ctx, entrust.ContractID); err != nil {
+	if err := s.contract.UpdateValMoneyByID(ctx, entrust.ContractID); err != nil {
 		log.Errorf("更新可用资金失败:%+v", err)
 	}
 
 	// 券商委托,则发送
 	if entrust.IsBrokerEntrust {
 		go func() {
-			if err := BrokerServiceInstance().Entrust(e); err != nil {
+			if err := s.core.Broker.Entrust(e); err != nil {
 				log.Errorf("委托交易失败:%+v", err)
 				return
 			}
@@ -1271,15 +1249,15 @@ func (s *TradeService) Buy(ctx context.Context, p *model.EntrustPackage) error {
 
 // InitSell 卖出初始化
 func (s *TradeService) InitSell(ctx context.Context, contractID int64, code string) (interface{}, error) {
-	contract, err := dao.ContractDaoInstance().GetContractByID(ctx, contractID)
+	contract, err := s.core.Contract.GetContractByID(ctx, contractID)
 	if err != nil {
 		return nil, err
 	}
-	qt, err := quote.QtServiceInstance().GetQuoteByTencent([]string{code})
+	qt, err := s.core.Quote.GetQuoteByTencent([]string{code})
 	if err != nil {
 		return nil, err
 	}
-	list, err := dao.PositionDaoInstance().GetPositionByContractID(ctx, contractID)
+	list, err := s.core.Position.GetPositionByContractID(ctx, contractID)
 	if err != nil {
 		return nil, err
 	}
@@ -1295,12 +1273,13 @@ func (s *TradeService) InitSell(ctx context.Context, contractID int64, code stri
 		ContractName:  contract.FullName(),
 		MaxSellAmount: maxSellAmount,                 // 最大可卖数量
 		PanKou:        model.ConvertPanKou(qt[code]), // 盘口信息
+		PriceBand:     s.priceBand.Band(qt[code]),    // 涨跌停价格区间
 	}, nil
 }
 
 // GetTradeFee 交易手续费
 func (s *TradeService) GetTradeFee(ctx context.Context, price float64, amount int64, entrustBs int64) (float64, error) {
-	sys, err := dao.SysDaoInstance().GetSysParam(ctx)
+	sys, err := s.core.Sys.GetSysParam(ctx)
 	if err != nil {
 		return 0, err
 	}
@@ -1318,10 +1297,10 @@ func (s *TradeService) GetTradeFee(ctx context.Context, price float64, amount in
 
 // Sell 卖出
 func (s *TradeService) Sell(ctx context.Context, p *model.EntrustPackage) error {
-	if !CalendarServiceInstance().IsEntrustTime(ctx) {
+	if !s.core.Calendar.IsEntrustTime(ctx) {
 		return serr.ErrBusiness("委托失败,非交易时间")
 	}
-	contract, err := dao.ContractDaoInstance().GetContractByID(ctx, p.ContractID)
+	contract, err := s.core.Contract.GetContractByID(ctx, p.ContractID)
 	if err != nil {
 		return serr.ErrBusiness("委托失败:合约不存在")
 	}
@@ -1329,7 +1308,7 @@ func (s *TradeService) Sell(ctx context.Context, p *model.EntrustPackage) error
 		return serr.ErrBusiness("委托失败:无效合约")
 	}
 
-	sys, err := dao.SysDaoInstance().GetSysParam(ctx)
+	sys, err := s.core.Sys.GetSysParam(ctx)
 	if err != nil {
 		log.Errorf("GetSysParam err:%+v", err)
 		return serr.ErrBusiness("委托失败")
@@ -1337,7 +1316,7 @@ func (s *TradeService) Sell(ctx context.Context, p *model.EntrustPackage) error
 
 	// 检查可用股数是否满足卖出数量(卖出股数是否大于amount)
 	position := &model.Position{}
-	positions, err := dao.PositionDaoInstance().GetPositionByContractID(ctx, p.ContractID)
+	positions, err := s.core.Position.GetPositionByContractID(ctx, p.ContractID)
 	if err != nil {
 		return err
 	}
@@ -1355,7 +1334,7 @@ func (s *TradeService) Sell(ctx context.Context, p *model.EntrustPackage) error
 	}
 
 	// 行情
-	qts, err := quote.QtServiceInstance().GetQuoteByTencent([]string{p.Code})
+	qts, err := s.core.Quote.GetQuoteByTencent([]string{p.Code})
 	if err != nil {
 		return err
 	}
@@ -1364,19 +1343,20 @@ func (s *TradeService) Sell(ctx context.Context, p *model.EntrustPackage) error
 		return serr.ErrBusiness("委托交易失败")
 	}
 
-	// 限价委托,如果卖出价格小于市价则以市价为准
+	// 限价委托,委托价格必须在涨跌停价格区间内;如果卖出价格小于市价则以市价为准
+	band := s.priceBand.Band(qt)
 	if p.EntrustProp == model.EntrustPropTypeLimitPrice {
+		if err := s.priceBand.Check(band, p.Price); err != nil {
+			return err
+		}
 		if p.Price < qt.CurrentPrice {
 			p.Price = qt.CurrentPrice
 		}
-		if p.Price > qt.LimitUpPrice {
-			return serr.ErrBusiness("委托失败:委托价格高于涨停价")
-		}
 	}
 
-	// 市价委托,设置当前价格=市价
+	// 市价委托,设置当前价格=市价(不超出涨跌停价格区间)
 	if p.EntrustProp == model.EntrustPropTypeMarketPrice {
-		p.Price = qt.CurrentPrice
+		p.Price = band.Clamp(qt.CurrentPrice)
 	}
 
 	fee, err := s.GetTradeFee(ctx, p.Price, p.Amount, model.EntrustBsTypeSell)
@@ -1399,14 +1379,14 @@ func (s *TradeService) Sell(ctx context.Context, p *model.EntrustPackage) error
 		PositionID:      position.ID,                   // 持仓表id(卖出时需填写)
 		Fee:             fee,                           // 总交易费用
 		IsBrokerEntrust: sys.IsSupportBroker,           // 是否券商委托
-		Mode:            p.Mode,                        // 类型:0 主动卖出 1系统平仓
+		Mode:            p.Mode,                        // 类型:0 主动卖出 1系统平仓 2条件单触发
 	}
 
 	log.Infof("[业务]:委托卖出,持仓:%+v", position)
 	// 创建委托表
-	tx := db.StockDB().WithContext(ctx).Begin()
+	tx := s.core.Tx.Begin(ctx)
 	defer tx.Rollback()
-	e, err := dao.EntrustDaoInstance().CreateWithTx(tx, entrust)
+	e, err := s.core.Entrust.CreateWithTx(tx, entrust)
 	if err != nil {
 		log.Errorf("卖出创建委托表失败:%+v", err)
 		return serr.ErrBusiness("委托失败")
@@ -1414,7 +1394,7 @@ func (s *TradeService) Sell(ctx context.Context, p *model.EntrustPackage) error
 	log.Infof("[业务]:卖出,创建委托成功:%+v", e)
 
 	// 冻结持仓股数
-	if err := dao.PositionDaoInstance().FreezeAmountWithTx(tx, entrust.ContractID, entrust.StockCode, entrust.Amount); err != nil {
+	if err := s.core.Position.FreezeAmountWithTx(tx, entrust.ContractID, entrust.StockCode, entrust.Amount); err != nil {
 		log.Errorf("冻结持仓股数失败:%+v", err)
 		return serr.ErrBusiness("委托失败")
 	}
@@ -1425,7 +1405,7 @@ func (s *TradeService) Sell(ctx context.Context, p *model.EntrustPackage) error
 		return serr.ErrBusiness("委托失败")
 	}
 
-	pos, err := dao.PositionDaoInstance().GetContractPositionByCode(ctx, entrust.ContractID, entrust.StockCode)
+	pos, err := s.core.Position.GetContractPositionByCode(ctx, entrust.ContractID, entrust.StockCode)
 	if err != nil {
 		log.Errorf("查询卖出股票错误:%+v", err)
 	}
@@ -1433,7 +1413,7 @@ func (s *TradeService) Sell(ctx context.Context, p *model.EntrustPackage) error
 
 	// 提交交易,发送到交易所
 	go func() {
-		if err := BrokerServiceInstance().Entrust(e); err != nil {
+		if err := s.core.Broker.Entrust(e); err != nil {
 			log.Errorf("委托交易失败:%+v", err)
 			return
 		}
@@ -1445,7 +1425,7 @@ func (s *TradeService) Sell(ctx context.Context, p *model.EntrustPackage) error
 // InitWithdraw 撤单页初始化
 func (s *TradeService) InitWithdraw(ctx context.Context, contractID int64) (interface{}, error) {
 	result := make([]*model.Withdraw, 0)
-	list, err := dao.EntrustDaoInstance().GetTodayEntrust(ctx, contractID)
+	list, err := s.core.Entrust.GetTodayEntrust(ctx, contractID)
 	if err != nil {
 		return nil, err
 	}
@@ -1488,7 +1468,7 @@ func (s *TradeService) InitWithdraw(ctx context.Context, contractID int64) (inte
 
 // Withdraw 撤单
 func (s *TradeService) Withdraw(ctx context.Context, entrustID int64) error {
-	entrust, err := dao.EntrustDaoInstance().GetEntrustByID(ctx, entrustID)
+	entrust, err := s.core.Entrust.GetEntrustByID(ctx, entrustID)
 	if err != nil {
 		return serr.ErrBusiness("委托订单不存在")
 	}
@@ -1498,7 +1478,7 @@ func (s *TradeService) Withdraw(ctx context.Context, entrustID int64) error {
 		return serr.ErrBusiness("已申报,等待撤单中")
 	}
 	// 检查合约是否允许撤单
-	if ContractServiceInstance().GetWithdrawStatus(ctx, entrust.ContractID) == model.ContractWithdrawStatusDisable {
+	if s.contract.GetWithdrawStatus(ctx, entrust.ContractID) == model.ContractWithdrawStatusDisable {
 		return serr.ErrBusiness("合约冻结,撤单失败")
 	}
 
@@ -1512,14 +1492,15 @@ func (s *TradeService) Withdraw(ctx context.Context, entrustID int64) error {
 
 // WithdrawEntrust 撤单
 func (s *TradeService) WithdrawEntrust(ctx context.Context, entrust *model.Entrust) error {
-	entrust.Status = model.EntrustStatusTypeWithdraw
 	if entrust.DealAmount == entrust.Amount {
 		return serr.ErrBusiness("已成交:撤单失败")
 	}
+	entrust.SetWithdraw()
 	// 检查买入和委托是否相同数量,修正买入数量
 
+	// 卖出只解冻未成交部分,已成交部分在成交时已解冻
 	if entrust.EntrustBS == model.EntrustBsTypeSell {
-		if err := dao.PositionDaoInstance().UnFreezeAmount(ctx, entrust.ContractID, entrust.StockCode, entrust.Amount); err != nil {
+		if err := s.core.Position.UnFreezeAmount(ctx, entrust.ContractID, entrust.StockCode, entrust.Amount-entrust.DealAmount); err != nil {
 			log.Errorf("解冻股票失败:%+v", err)
 			return serr.ErrBusiness("撤单失败")
 		}
@@ -1527,27 +1508,35 @@ func (s *TradeService) WithdrawEntrust(ctx context.Context, entrust *model.Entru
 
 	// 券商委托表设置撤单状态
 	if entrust.IsBrokerEntrust && len(entrust.BrokerEntrust) > 0 {
-		if err := dao.BrokerEntrustDaoInstance().MCreate(ctx, entrust.BrokerEntrust); err != nil {
+		if err := s.core.BrokerEntrust.MCreate(ctx, entrust.BrokerEntrust); err != nil {
 			log.Errorf("券商委托表更新失败:%+v", err)
 			return err
 		}
 	}
 
-	if err := dao.EntrustDaoInstance().Update(ctx, entrust); err != nil {
+	if err := s.core.Entrust.Update(ctx, entrust); err != nil {
 		log.Errorf("Update err:%+v", err)
 		return err
 	}
 
-	if err := ContractServiceInstance().UpdateValMoneyByID(ctx, entrust.ContractID); err != nil {
+	if err := s.contract.UpdateValMoneyByID(ctx, entrust.ContractID); err != nil {
 		log.Errorf("更新可用资金失败:%+v", err)
 		return serr.ErrBusiness("更新可用资金失败")
 	}
 	return nil
 }
 
-// brokerWithdraw 券商撤单
+// brokerWithdraw 券商撤单,与券商委托事件互斥,重新读取委托避免撤单中状态覆盖已结算的委托
 func (s *TradeService) brokerWithdraw(ctx context.Context, entrust *model.Entrust) error {
-	brokerEntrusts, err := dao.BrokerEntrustDaoInstance().GetByEntrustID(ctx, entrust.ID)
+	defer lockEntrust(entrust.ID)()
+	entrust, err := s.core.Entrust.GetEntrustByID(ctx, entrust.ID)
+	if err != nil {
+		return serr.ErrBusiness("委托订单不存在")
+	}
+	if entrust.IsFinallyState() {
+		return serr.ErrBusiness("已撤单")
+	}
+	brokerEntrusts, err := s.core.BrokerEntrust.GetByEntrustID(ctx, entrust.ID)
 	if err != nil {
 		return err
 	}
@@ -1556,7 +1545,7 @@ func (s *TradeService) brokerWithdraw(ctx context.Context, entrust *model.Entrus
 	}
 
 	brokerMap := make(map[int64]*model.Broker)
-	for _, broker := range BrokerServiceInstance().GetBrokers() {
+	for _, broker := range s.core.Broker.GetBrokers() {
 		brokerMap[broker.ID] = broker
 	}
 
@@ -1572,7 +1561,7 @@ func (s *TradeService) brokerWithdraw(ctx context.Context, entrust *model.Entrus
 		if brokerEntrust.IsFinallyState() {
 			continue
 		}
-		if err := BrokerServiceInstance().Withdraw(brokerEntrust, broker, brokerEntrust.BrokerEntrustNo); err != nil {
+		if err := s.core.Broker.Withdraw(brokerEntrust, broker, brokerEntrust.BrokerEntrustNo); err != nil {
 			log.Errorf("券商撤单失败:%+v", err)
 			return serr.ErrBusiness("撤单失败")
 			// TODO 多次撤单,看返回错误结果,目前是对撤单错误结果忽略
@@ -1583,11 +1572,11 @@ func (s *TradeService) brokerWithdraw(ctx context.Context, entrust *model.Entrus
 
 	// 委托表、券商委托表变更状态:撤单中
 	entrust.Status = model.EntrustStatusTypeWithdrawing
-	if err := dao.EntrustDaoInstance().Update(ctx, entrust); err != nil {
+	if err := s.core.Entrust.Update(ctx, entrust); err != nil {
 		return err
 	}
 
-	if err := dao.BrokerEntrustDaoInstance().MCreate(ctx, withdrawBrokerEntrust); err != nil {
+	if err := s.core.BrokerEntrust.MCreate(ctx, withdrawBrokerEntrust); err != nil {
 		return err
 	}
 	return nil
@@ -1595,7 +1584,7 @@ func (s *TradeService) brokerWithdraw(ctx context.Context, entrust *model.Entrus
 
 // GetEntrustList 委托记录
 func (s *TradeService) GetEntrustList(ctx context.Context, contractID int64) ([]*model.Withdraw, error) {
-	list, err := dao.EntrustDaoInstance().GetEntrustByContractID(ctx, contractID)
+	list, err := s.core.Entrust.GetEntrustByContractID(ctx, contractID)
 	if err != nil {
 		return nil, err
 	}
@@ -1637,7 +1626,7 @@ func (s *TradeService) GetSellOut(ctx context.Context, contractID int64) ([]*mod
 	wg := errgroup.GroupWithCount(5)
 	var position []*model.Position
 	wg.Go(func() error {
-		list, err := dao.PositionDaoInstance().GetPositionByContractID(ctx, contractID)
+		list, err := s.core.Position.GetPositionByContractID(ctx, contractID)
 		if err != nil {
 			return err
 		}
@@ -1646,7 +1635,7 @@ func (s *TradeService) GetSellOut(ctx context.Context, contractID int64) ([]*mod
 	})
 	var entrust []*model.Entrust
 	wg.Go(func() error {
-		list, err := dao.EntrustDaoInstance().GetEntrustByContractID(ctx, contractID)
+		list, err := s.core.Entrust.GetEntrustByContractID(ctx, contractID)
 		if err != nil {
 			return err
 		}
@@ -1655,7 +1644,7 @@ func (s *TradeService) GetSellOut(ctx context.Context, contractID int64) ([]*mod
 	})
 	var sell []*model.Sell
 	wg.Go(func() error {
-		list, err := dao.SellDaoInstance().GetByContractID(ctx, contractID)
+		list, err := s.core.Sell.GetByContractID(ctx, contractID)
 		if err != nil {
 			return err
 		}
@@ -1664,7 +1653,7 @@ func (s *TradeService) GetSellOut(ctx context.Context, contractID int64) ([]*mod
 	})
 	var buy []*model.Buy
 	wg.Go(func() error {
-		list, err := dao.BuyDaoInstance().GetByContractID(ctx, contractID)
+		list, err := s.core.Buy.GetByContractID(ctx, contractID)
 		if err != nil {
 			return err
 		}
@@ -1673,7 +1662,7 @@ func (s *TradeService) GetSellOut(ctx context.Context, contractID int64) ([]*mod
 	})
 	var dividend []*model.Dividend
 	wg.Go(func() error {
-		list, err := dao.DividendDaoInstance().GetByContractID(ctx, contractID)
+		list, err := s.core.Dividend.GetByContractID(ctx, contractID)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"stock/api-gateway/model"
+	"stock/api-gateway/serr"
+	"stock/common/log"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TransferReviewService 提现、人工充值审批:达到复核金额的申请须初审、复核两人审批,复核人不能是初审人
+//
+// 审批为状态机:待审核->待复核/成功/失败,待复核->成功/失败。每次审批在一个事务内对申请和用户加行锁,
+// 校验状态变化后更新申请、用户资金并记账;重复提交已生效的审批直接返回当前结果,不重复处理资金。
+type TransferReviewService struct {
+	core   *Core
+	ledger *LedgerService
+}
+
+var (
+	transferReviewService *TransferReviewService
+	transferReviewOnce    sync.Once
+)
+
+// TransferReviewServiceInstance 实例
+func TransferReviewServiceInstance() *TransferReviewService {
+	transferReviewOnce.Do(func() {
+		transferReviewService = defaultServices().TransferReview
+	})
+	return transferReviewService
+}
+
+// Approve 审批通过:待审核的申请达到复核金额时转为待复核,否则直接完成;待复核的申请由初审人以外的操作员复核完成
+func (s *TransferReviewService) Approve(ctx context.Context, id int64, operator string) (*model.Transfer, error) {
+	return s.review(ctx, id, func(transfer *model.Transfer) (int64, error) {
+		switch transfer.Status {
+		case model.TransferStatusSuccess:
+			return transfer.Status, nil
+		case model.TransferStatusFail:
+			return 0, serr.ErrBusiness("申请已驳回")
+		case model.TransferStatusWaitExam:
+			transfer.Reviewer = operator
+			need, err := s.needCheck(ctx, transfer)
+			if err != nil {
+				return 0, err
+			}
+			if need {
+				return model.TransferStatusWaitReview, nil
+			}
+		case model.TransferStatusWaitReview:
+			// 初审人重复提交:保持待复核
+			if transfer.Reviewer == operator {
+				return transfer.Status, nil
+			}
+			transfer.Checker = operator
+		}
+		return model.TransferStatusSuccess, nil
+	})
+}
+
+// Reject 驳回:待审核、待复核的申请均可驳回,须填写原因,提现申请解冻资金退回钱包
+func (s *TransferReviewService) Reject(ctx context.Context, id int64, operator, reason string) (*model.Transfer, error) {
+	reason = strings.TrimSpace(reason)
+	if len(reason) == 0 {
+		return nil, serr.ErrBusiness("请填写驳回原因")
+	}
+	return s.review(ctx, id, func(transfer *model.Transfer) (int64, error) {
+		switch transfer.Status {
+		case model.TransferStatusFail:
+			return transfer.Status, nil
+		case model.TransferStatusSuccess:
+			return 0, serr.ErrBusiness("申请已通过")
+		case model.TransferStatusWaitExam:
+			transfer.Reviewer = operator
+		case model.TransferStatusWaitReview:
+			transfer.Checker = operator
+		}
+		transfer.Reason = reason
+		return model.TransferStatusFail, nil
+	})
+}
+
+// review 审批事务:锁定申请,由decide决定目标状态;状态未变化视为重复提交,直接返回
+func (s *TransferReviewService) review(ctx context.Context, id int64, decide func(transfer *model.Transfer) (int64, error)) (*model.Transfer, error) {
+	tx := s.core.Tx.Begin(ctx)
+	defer tx.Rollback()
+	transfer, err := s.core.Transfer.GetByIDForUpdateWithTx(tx, id)
+	if err != nil {
+		log.Errorf("GetByIDForUpdateWithTx err:%+v", err)
+		return nil, serr.ErrBusiness("申请不存在")
+	}
+	if transfer.Type != model.TransferTypeRecharge && transfer.Type != model.TransferTypeWithdraw {
+		return nil, serr.ErrBusiness("申请不存在")
+	}
+	from := transfer.Status
+	to, err := decide(transfer)
+	if err != nil {
+		return nil, err
+	}
+	if to == from {
+		return transfer, nil
+	}
+	if !model.CanTransferTransit(from, to) {
+		return nil, serr.ErrBusiness(fmt.Sprintf("申请状态为%s,不能变更为%s", model.TransferStatusMap[from], model.TransferStatusMap[to]))
+	}
+	transfer.Status = to
+
+	var user *model.User
+	if to == model.TransferStatusSuccess || to == model.TransferStatusFail {
+		now := time.Now()
+		transfer.FinishTime = &now
+		if user, err = s.finishWithTx(tx, transfer); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.core.Transfer.CreateWithTx(tx, transfer); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Errorf("事务提交失败:%+v", err)
+		return nil, err
+	}
+
+	if to == model.TransferStatusSuccess {
+		content := fmt.Sprintf("您转入资金到账:%0.2f,请打开App查看", transfer.Arrival())
+		if transfer.Type == model.TransferTypeWithdraw {
+			content = fmt.Sprintf("您转出资金到账:%0.2f,请打开App查看", transfer.Money)
+		}
+		if err := s.core.Sms.SendSms(ctx, content, user.UserName); err != nil {
+			log.Errorf("短信提醒失败:%+v", err)
+		}
+	}
+	return transfer, nil
+}
+
+// needCheck 申请金额是否达到复核金额
+func (s *TransferReviewService) needCheck(ctx context.Context, transfer *model.Transfer) (bool, error) {
+	sys, err := s.core.Sys.GetSysParam(ctx)
+	if err != nil {
+		return false, err
+	}
+	amount := sys.RechargeReviewAmount
+	if transfer.Type == model.TransferTypeWithdraw {
+		amount = sys.WithdrawReviewAmount
+	}
+	return amount > 0 && transfer.Money >= amount, nil
+}
+
+// finishWithTx 审批完成:锁定用户,更新用户资金、记账并通知用户
+func (s *TransferReviewService) finishWithTx(tx *gorm.DB, transfer *model.Transfer) (*model.User, error) {
+	user, err := s.core.User.GetUserByUIDForUpdateWithTx(tx, transfer.UID)
+	if err != nil {
+		return nil, err
+	}
+
+	var journal *model.Journal
+	msg := &model.Msg{UID: user.ID, CreateTime: time.Now()}
+	switch {
+	case transfer.Type == model.TransferTypeWithdraw && transfer.Status == model.TransferStatusSuccess:
+		user.FreezeMoney -= transfer.Money
+		// 首次提现成功后绑定收款银行卡
+		if len(user.BankNumber) == 0 {
+			user.BankNumber = transfer.BankNo
+		}
+		journal = model.NewJournal(model.LedgerBizWithdrawPass, transfer.ID, "提现成功").
+			Move(model.FreezeAccount(user.ID), model.ExternalAccount, transfer.Money)
+		msg.Title = "提现成功"
+		msg.Content = fmt.Sprintf("您申请的提现%0.2f元已审核通过,资金已转出", transfer.Money)
+	case transfer.Type == model.TransferTypeWithdraw:
+		user.Money += transfer.Money
+		user.FreezeMoney -= transfer.Money
+		journal = model.NewJournal(model.LedgerBizWithdrawReject, transfer.ID, "提现驳回,解冻资金").
+			Move(model.FreezeAccount(user.ID), model.WalletAccount(user.ID), transfer.Money)
+		msg.Title = "提现驳回"
+		msg.Content = fmt.Sprintf("您申请的提现%0.2f元已被驳回,资金已退回账户余额,原因:%s", transfer.Money, transfer.Reason)
+	case transfer.Status == model.TransferStatusSuccess:
+		user.Money += transfer.Arrival()
+		journal = rechargeJournal(transfer, "充值审核通过")
+		msg.Title = "充值成功"
+		msg.Content = fmt.Sprintf("您的充值%0.2f元已到账", transfer.Arrival())
+	default:
+		msg.Title = "充值驳回"
+		msg.Content = fmt.Sprintf("您的充值%0.2f元审核未通过,原因:%s", transfer.Money, transfer.Reason)
+	}
+	if user.FreezeMoney < -0.001 {
+		log.Errorf("提现冻结资金不足:transfer[%d],user:%+v", transfer.ID, user)
+		return nil, serr.ErrBusiness("冻结资金不足")
+	}
+
+	if journal != nil {
+		if err := s.core.User.UpdateUserWithTx(tx, user); err != nil {
+			return nil, err
+		}
+		if err := s.ledger.PostWithTx(tx, journal); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.core.Msg.CreateWithTx(tx, msg); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"stock/api-gateway/model"
+)
+
+// TestTransferReview 大额提现须两人审批且复核人不能是初审人,驳回时解冻资金并通知用户
+func TestTransferReview(t *testing.T) {
+	ctx := context.Background()
+	store, _, svc := newFakeServices()
+	store.SetSysParam(&model.SysParam{WithdrawReviewAmount: 1000})
+	user := store.PutUser(&model.User{Status: model.UserStatusActive, Money: 0, FreezeMoney: 1500})
+	small := store.PutTransfer(&model.Transfer{UID: user.ID, Money: 500, Type: model.TransferTypeWithdraw, Status: model.TransferStatusWaitExam})
+	large := store.PutTransfer(&model.Transfer{UID: user.ID, Money: 1000, Type: model.TransferTypeWithdraw, Status: model.TransferStatusWaitExam})
+	if _, err := svc.Ledger.Open(ctx, "test"); err != nil {
+		t.Fatalf("ledger open: %+v", err)
+	}
+
+	// 1.小额提现一人审批即完成
+	transfer, err := svc.TransferReview.Approve(ctx, small.ID, "alice")
+	if err != nil || transfer.Status != model.TransferStatusSuccess {
+		t.Fatalf("approve small: %+v %+v", transfer, err)
+	}
+	if transfer, err := svc.TransferReview.Approve(ctx, small.ID, "bob"); err != nil || transfer.Status != model.TransferStatusSuccess {
+		t.Fatalf("expect repeated approve idempotent: %+v %+v", transfer, err)
+	}
+	if _, err := svc.TransferReview.Reject(ctx, small.ID, "bob", "重复"); err == nil {
+		t.Fatal("expect reject approved transfer fail")
+	}
+
+	// 2.大额提现初审后待复核,初审人不能复核
+	transfer, err = svc.TransferReview.Approve(ctx, large.ID, "alice")
+	if err != nil || transfer.Status != model.TransferStatusWaitReview {
+		t.Fatalf("approve large: %+v %+v", transfer, err)
+	}
+	transfer, err = svc.TransferReview.Approve(ctx, large.ID, "alice")
+	if err != nil || transfer.Status != model.TransferStatusWaitReview {
+		t.Fatalf("expect self check keep wait review: %+v %+v", transfer, err)
+	}
+	u, _ := svc.core.User.GetUserByUID(ctx, user.ID)
+	assertMoney(t, "freeze money before check", u.FreezeMoney, 1000)
+
+	// 3.复核驳回须填写原因,驳回后资金退回钱包
+	if _, err := svc.TransferReview.Reject(ctx, large.ID, "bob", " "); err == nil {
+		t.Fatal("expect reject without reason fail")
+	}
+	transfer, err = svc.TransferReview.Reject(ctx, large.ID, "bob", "收款人与实名不符")
+	if err != nil || transfer.Status != model.TransferStatusFail || transfer.Reviewer != "alice" || transfer.Checker != "bob" {
+		t.Fatalf("reject large: %+v %+v", transfer, err)
+	}
+	u, _ = svc.core.User.GetUserByUID(ctx, user.ID)
+	assertMoney(t, "money after reject", u.Money, 1000)
+	assertMoney(t, "freeze money after reject", u.FreezeMoney, 0)
+	if msgs := store.Msgs(user.ID); len(msgs) != 2 {
+		t.Fatalf("expect 2 msgs, got %d", len(msgs))
+	}
+	if diffs, err := svc.Ledger.Check(ctx); err != nil || len(diffs) != 0 {
+		t.Fatalf("ledger check: %+v %+v", diffs, err)
+	}
+}
+
+// TestTransferReviewConcurrent 同一申请并发通过、驳回,只有一种结果生效,资金只变动一次
+func TestTransferReviewConcurrent(t *testing.T) {
+	ctx := context.Background()
+	store, _, svc := newFakeServices()
+	// 事务并发执行,只靠FOR UPDATE行锁互斥
+	store.UseRowLock()
+	store.SetSysParam(&model.SysParam{})
+	user := store.PutUser(&model.User{Status: model.UserStatusActive, Money: 0, FreezeMoney: 100})
+	withdraw := store.PutTransfer(&model.Transfer{UID: user.ID, Money: 100, Type: model.TransferTypeWithdraw, Status: model.TransferStatusWaitExam})
+	recharge := store.PutTransfer(&model.Transfer{UID: user.ID, Money: 50, Type: model.TransferTypeRecharge, Status: model.TransferStatusWaitExam})
+	if _, err := svc.Ledger.Open(ctx, "test"); err != nil {
+		t.Fatalf("ledger open: %+v", err)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	results := make(map[int64]int)
+	for i := 0; i < 20; i++ {
+		i := i
+		wg.Add(2)
+		operator := fmt.Sprintf("op%d", i)
+		go func() {
+			defer wg.Done()
+			var transfer *model.Transfer
+			var err error
+			if i%2 == 0 {
+				transfer, err = svc.TransferReview.Approve(ctx, withdraw.ID, operator)
+			} else {
+				transfer, err = svc.TransferReview.Reject(ctx, withdraw.ID, operator, "并发驳回")
+			}
+			if err == nil {
+				mu.Lock()
+				results[transfer.Status]++
+				mu.Unlock()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := svc.TransferReview.Approve(ctx, recharge.ID, operator); err != nil {
+				t.Errorf("approve recharge: %+v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 提现:只有先到的一种结果成功,另一种结果的请求全部失败
+	if len(results) != 1 || (results[model.TransferStatusSuccess] != 10 && results[model.TransferStatusFail] != 10) {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	u, _ := svc.core.User.GetUserByUID(ctx, user.ID)
+	want := 50.0
+	if results[model.TransferStatusFail] > 0 {
+		want += 100
+	}
+	assertMoney(t, "money", u.Money, want)
+	assertMoney(t, "freeze money", u.FreezeMoney, 0)
+	if msgs := store.Msgs(user.ID); len(msgs) != 2 {
+		t.Fatalf("expect 2 msgs, got %d", len(msgs))
+	}
+	if diffs, err := svc.Ledger.Check(ctx); err != nil || len(diffs) != 0 {
+		t.Fatalf("ledger check: %+v %+v", diffs, err)
+	}
+}
@@ -31,15 +31,25 @@ func UserServiceInstance() *UserService {
 // Login 用户登录
 func (s *UserService) Login(ctx context.Context, userName, password string) (*model.User, error) {
 	user, err := dao.UserDaoInstance().GetUserByUserName(ctx, userName)
-	if err != nil && err == gorm.ErrRecordNotFound {
-		return nil, serr.ErrBusiness("账号不存在")
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, serr.ErrBusiness("账号不存在")
+		}
+		return nil, serr.ErrBusiness("登录失败")
 	}
-	if user.Password != password {
+	ok, upgrade := PasswordServiceInstance().Verify(user.Password, password)
+	if !ok {
 		return nil, serr.ErrBusiness("密码错误")
 	}
 	if user.Status != model.UserStatusActive {
 		return nil, serr.ErrBusiness("账户被冻结")
 	}
+	// 历史明文密码登录成功后升级为hash存储
+	if len(upgrade) > 0 {
+		if err := dao.UserDaoInstance().ModifyUserPassword(ctx, userName, upgrade); err != nil {
+			log.Errorf("升级用户密码hash失败:%+v", err)
+		}
+	}
 
 	return user, nil
 }
@@ -82,9 +92,16 @@ func (s *UserService) RegisterUser(ctx context.Context, userName, password, code
 	}
 
 	// 4.注册
+	if err := PasswordServiceInstance().CheckPolicy(ctx, userName, password); err != nil {
+		return err
+	}
+	hashed, err := PasswordServiceInstance().Hash(password)
+	if err != nil {
+		return err
+	}
 	newUser := &model.User{
 		UserName: userName,
-		Password: password,
+		Password: hashed,
 		Status:   model.UserStatusActive,
 		RoleID:   roleID,
 		CreateAt: time.Now(),
@@ -126,7 +143,14 @@ func (s *UserService) UpdatePassword(ctx context.Context, userName, password, co
 	}
 
 	// 3.修改密码
-	if err := dao.UserDaoInstance().ModifyUserPassword(ctx, userName, password); err != nil {
+	if err := PasswordServiceInstance().CheckPolicy(ctx, userName, password); err != nil {
+		return err
+	}
+	hashed, err := PasswordServiceInstance().Hash(password)
+	if err != nil {
+		return err
+	}
+	if err := dao.UserDaoInstance().ModifyUserPassword(ctx, userName, hashed); err != nil {
 		return serr.ErrBusiness("修改密码失败")
 	}
 
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"stock/api-gateway/model"
+	"stock/api-gateway/serr"
+	"strings"
+	"sync"
+	"time"
+)
+
+// withdrawApply 提现申请
+type withdrawApply struct {
+	User      *model.User       // 申请用户
+	Money     float64           // 提现金额
+	Name      string            // 收款人
+	BankNo    string            // 收款银行卡号
+	Time      time.Time         // 申请时间
+	Transfers []*model.Transfer // 用户的资金流水
+}
+
+// withdrawRule 提现风控规则,不通过时返回原因
+type withdrawRule func(sys *model.SysParam, apply *withdrawApply) error
+
+// WithdrawPolicyService 提现风控:按系统参数依次校验提现时间、金额限制、收款人、每日每周限额与充值冷却期
+type WithdrawPolicyService struct {
+	core  *Core
+	rules []withdrawRule
+}
+
+var (
+	withdrawPolicyService *WithdrawPolicyService
+	withdrawPolicyOnce    sync.Once
+)
+
+// WithdrawPolicyServiceInstance 实例
+func WithdrawPolicyServiceInstance() *WithdrawPolicyService {
+	withdrawPolicyOnce.Do(func() {
+		withdrawPolicyService = defaultServices().WithdrawPolicy
+	})
+	return withdrawPolicyService
+}
+
+// newWithdrawPolicyService 按顺序校验的提现规则
+func newWithdrawPolicyService(c *Core) *WithdrawPolicyService {
+	return &WithdrawPolicyService{
+		core: c,
+		rules: []withdrawRule{
+			checkWithdrawTime,
+			checkWithdrawMoney,
+			checkWithdrawPayee,
+			checkWithdrawDayLimit,
+			checkWithdrawWeekLimit,
+			checkWithdrawCooldown,
+		},
+	}
+}
+
+// Check 校验提现申请,调用方须已锁定用户,避免并发申请绕过每日、每周限额
+func (s *WithdrawPolicyService) Check(ctx context.Context, user *model.User, money float64, name, bankNo string) error {
+	sys, err := s.core.Sys.GetSysParam(ctx)
+	if err != nil {
+		return err
+	}
+	transfers, err := s.core.Transfer.GetByUid(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	apply := &withdrawApply{
+		User:      user,
+		Money:     money,
+		Name:      strings.TrimSpace(name),
+		BankNo:    strings.TrimSpace(bankNo),
+		Time:      time.Now(),
+		Transfers: transfers,
+	}
+	return s.check(sys, apply)
+}
+
+// check 依次执行风控规则
+func (s *WithdrawPolicyService) check(sys *model.SysParam, apply *withdrawApply) error {
+	for _, rule := range s.rules {
+		if err := rule(sys, apply); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseClock 解析时分秒(15:04:05或15:04),返回当日零点起的时长
+func parseClock(v string) (time.Duration, bool) {
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.Parse(layout, strings.TrimSpace(v)); err == nil {
+			return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second, true
+		}
+	}
+	return 0, false
+}
+
+// checkWithdrawTime 提现时间段,开始时间晚于结束时间表示跨夜,未设置不限制
+func checkWithdrawTime(sys *model.SysParam, apply *withdrawApply) error {
+	begin, ok1 := parseClock(sys.StartWithdrawTime)
+	end, ok2 := parseClock(sys.StopWithdrawTime)
+	if !ok1 || !ok2 || begin == end {
+		return nil
+	}
+	now := apply.Time
+	clock := now.Sub(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()))
+	in := clock >= begin && clock <= end
+	if begin > end {
+		in = clock >= begin || clock <= end
+	}
+	if !in {
+		return serr.ErrBusiness(fmt.Sprintf("提现时间为每日%s至%s", sys.StartWithdrawTime, sys.StopWithdrawTime))
+	}
+	return nil
+}
+
+// checkWithdrawMoney 单笔最低、最高金额
+func checkWithdrawMoney(sys *model.SysParam, apply *withdrawApply) error {
+	if apply.Money < sys.WithdrawMinMoney {
+		return serr.ErrBusiness(fmt.Sprintf("单笔最低提现%0.2f元", sys.WithdrawMinMoney))
+	}
+	if sys.WithdrawMaxMoney > 0 && apply.Money > sys.WithdrawMaxMoney {
+		return serr.ErrBusiness(fmt.Sprintf("单笔最高提现%0.2f元", sys.WithdrawMaxMoney))
+	}
+	return nil
+}
+
+// checkWithdrawPayee 须实名认证,收款人须与实名一致;已绑定银行卡的只能提现到绑定的银行卡
+func checkWithdrawPayee(sys *model.SysParam, apply *withdrawApply) error {
+	if !apply.User.Verified() {
+		return serr.ErrBusiness("请先完成实名认证")
+	}
+	if apply.Name != apply.User.Name {
+		return serr.ErrBusiness("收款人须与实名认证姓名一致")
+	}
+	if len(apply.BankNo) == 0 {
+		return serr.ErrBusiness("请填写正确的银行卡号")
+	}
+	if len(apply.User.BankNumber) > 0 && apply.BankNo != apply.User.BankNumber.String() {
+		return serr.ErrBusiness("只能提现到已绑定的银行卡")
+	}
+	return nil
+}
+
+// withdrawSince since之后申请且未被驳回的提现笔数与金额
+func withdrawSince(apply *withdrawApply, since time.Time) (int64, float64) {
+	var count int64
+	var money float64
+	for _, it := range apply.Transfers {
+		if it.Type != model.TransferTypeWithdraw || it.Status == model.TransferStatusFail || it.OrderTime.Before(since) {
+			continue
+		}
+		count++
+		money += it.Money
+	}
+	return count, money
+}
+
+// checkWithdrawDayLimit 每日提现次数与累计金额
+func checkWithdrawDayLimit(sys *model.SysParam, apply *withdrawApply) error {
+	now := apply.Time
+	count, money := withdrawSince(apply, time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()))
+	if sys.WithdrawDayCount > 0 && count >= sys.WithdrawDayCount {
+		return serr.ErrBusiness(fmt.Sprintf("每日最多提现%d次", sys.WithdrawDayCount))
+	}
+	if sys.WithdrawDayMoney > 0 && money+apply.Money > sys.WithdrawDayMoney+0.001 {
+		return serr.ErrBusiness(fmt.Sprintf("每日累计最多提现%0.2f元,今日还可提现%0.2f元", sys.WithdrawDayMoney, remainMoney(sys.WithdrawDayMoney, money)))
+	}
+	return nil
+}
+
+// checkWithdrawWeekLimit 每周(周一起)累计金额
+func checkWithdrawWeekLimit(sys *model.SysParam, apply *withdrawApply) error {
+	if sys.WithdrawWeekMoney <= 0 {
+		return nil
+	}
+	now := apply.Time
+	weekday := (int(now.Weekday()) + 6) % 7
+	monday := time.Date(now.Year(), now.Month(), now.Day()-weekday, 0, 0, 0, 0, now.Location())
+	_, money := withdrawSince(apply, monday)
+	if money+apply.Money > sys.WithdrawWeekMoney+0.001 {
+		return serr.ErrBusiness(fmt.Sprintf("每周累计最多提现%0.2f元,本周还可提现%0.2f元", sys.WithdrawWeekMoney, remainMoney(sys.WithdrawWeekMoney, money)))
+	}
+	return nil
+}
+
+// remainMoney 剩余可提现额度
+func remainMoney(limit, used float64) float64 {
+	if used >= limit {
+		return 0
+	}
+	return limit - used
+}
+
+// checkWithdrawCooldown 最近一笔充值入账后N小时内不能提现,从审核通过或渠道入账时间起算
+func checkWithdrawCooldown(sys *model.SysParam, apply *withdrawApply) error {
+	if sys.WithdrawCooldownHours <= 0 {
+		return nil
+	}
+	var last time.Time
+	for _, it := range apply.Transfers {
+		if it.Type == model.TransferTypeRecharge && it.Status == model.TransferStatusSuccess && it.DoneTime().After(last) {
+			last = it.DoneTime()
+		}
+	}
+	if last.IsZero() {
+		return nil
+	}
+	if until := last.Add(time.Duration(sys.WithdrawCooldownHours) * time.Hour); apply.Time.Before(until) {
+		return serr.ErrBusiness(fmt.Sprintf("充值后%d小时内不能提现,请于%s后再试", sys.WithdrawCooldownHours, until.Format("2006-01-02 15:04")))
+	}
+	return nil
+}
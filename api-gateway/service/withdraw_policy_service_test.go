@@ -0,0 +1,105 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"stock/api-gateway/model"
+)
+
+// TestWithdrawPolicy 提现风控:时间段、单笔金额、收款人、每日次数与金额、每周金额、充值冷却期
+func TestWithdrawPolicy(t *testing.T) {
+	// 2021-06-09 周三 10:00
+	now := time.Date(2021, 6, 9, 10, 0, 0, 0, time.Local)
+	sys := &model.SysParam{
+		StartWithdrawTime:     "09:30:00",
+		StopWithdrawTime:      "16:00:00",
+		WithdrawMinMoney:      100,
+		WithdrawMaxMoney:      50000,
+		WithdrawDayMoney:      80000,
+		WithdrawDayCount:      3,
+		WithdrawWeekMoney:     100000,
+		WithdrawCooldownHours: 24,
+	}
+	user := &model.User{ID: 1, Name: "张三", ICCID: "110101199001011234", VerifyStatus: model.UserVerifyPass}
+	apply := func(money float64, name, bankNo string, at time.Time, transfers ...*model.Transfer) *withdrawApply {
+		return &withdrawApply{User: user, Money: money, Name: name, BankNo: bankNo, Time: at, Transfers: transfers}
+	}
+	withdraw := func(money float64, status int64, at time.Time) *model.Transfer {
+		return &model.Transfer{UID: 1, Money: money, Type: model.TransferTypeWithdraw, Status: status, OrderTime: at}
+	}
+	recharge := func(status int64, at time.Time) *model.Transfer {
+		return &model.Transfer{UID: 1, Money: 1000, Type: model.TransferTypeRecharge, Status: status, OrderTime: at}
+	}
+	finished := func(at, finish time.Time) *model.Transfer {
+		transfer := recharge(model.TransferStatusSuccess, at)
+		transfer.FinishTime = &finish
+		return transfer
+	}
+	policy := newWithdrawPolicyService(nil)
+
+	tests := []struct {
+		name  string
+		apply *withdrawApply
+		want  string
+	}{
+		{"ok", apply(1000, "张三", "6222", now), ""},
+		{"before window", apply(1000, "张三", "6222", now.Add(-time.Hour)), "提现时间为每日09:30:00至16:00:00"},
+		{"after window", apply(1000, "张三", "6222", now.Add(7*time.Hour)), "提现时间"},
+		{"min money", apply(50, "张三", "6222", now), "单笔最低提现100.00元"},
+		{"max money", apply(60000, "张三", "6222", now), "单笔最高提现50000.00元"},
+		{"payee", apply(1000, "李四", "6222", now), "收款人须与实名认证姓名一致"},
+		{"day count", apply(1000, "张三", "6222", now,
+			withdraw(100, model.TransferStatusSuccess, now.Add(-time.Hour)),
+			withdraw(100, model.TransferStatusWaitExam, now.Add(-time.Hour)),
+			withdraw(100, model.TransferStatusWaitReview, now.Add(-time.Hour))), "每日最多提现3次"},
+		{"rejected not counted", apply(1000, "张三", "6222", now,
+			withdraw(100, model.TransferStatusSuccess, now.Add(-time.Hour)),
+			withdraw(100, model.TransferStatusFail, now.Add(-time.Hour)),
+			withdraw(100, model.TransferStatusFail, now.Add(-time.Hour))), ""},
+		{"day money", apply(40000, "张三", "6222", now,
+			withdraw(45000, model.TransferStatusSuccess, now.Add(-time.Hour))), "今日还可提现35000.00元"},
+		{"week money", apply(30000, "张三", "6222", now,
+			withdraw(45000, model.TransferStatusSuccess, now.AddDate(0, 0, -2)),
+			withdraw(45000, model.TransferStatusSuccess, now.AddDate(0, 0, -1))), "本周还可提现10000.00元"},
+		{"last week not counted", apply(30000, "张三", "6222", now,
+			withdraw(45000, model.TransferStatusSuccess, now.AddDate(0, 0, -3)),
+			withdraw(45000, model.TransferStatusSuccess, now.AddDate(0, 0, -1))), ""},
+		{"cooldown", apply(1000, "张三", "6222", now, recharge(model.TransferStatusSuccess, now.Add(-2*time.Hour))), "充值后24小时内不能提现"},
+		{"pending recharge no cooldown", apply(1000, "张三", "6222", now, recharge(model.TransferStatusWaitExam, now.Add(-2*time.Hour))), ""},
+		{"cooldown passed", apply(1000, "张三", "6222", now, recharge(model.TransferStatusSuccess, now.Add(-25*time.Hour))), ""},
+		{"cooldown from finish time", apply(1000, "张三", "6222", now, finished(now.Add(-25*time.Hour), now.Add(-2*time.Hour))), "充值后24小时内不能提现"},
+	}
+	for _, tt := range tests {
+		err := policy.check(sys, tt.apply)
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("%s: expect ok, got %+v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expect %s, got %+v", tt.name, tt.want, err)
+		}
+	}
+
+	// 未实名认证、已绑定银行卡
+	user = &model.User{ID: 1}
+	if err := policy.check(sys, apply(1000, "", "6222", now)); err == nil || !strings.Contains(err.Error(), "请先完成实名认证") {
+		t.Errorf("expect authentication required, got %+v", err)
+	}
+	user = &model.User{ID: 1, Name: "张三", ICCID: "110101199001011234", VerifyStatus: model.UserVerifyPass, BankNumber: "6222"}
+	if err := policy.check(sys, apply(1000, "张三", "6333", now)); err == nil || !strings.Contains(err.Error(), "只能提现到已绑定的银行卡") {
+		t.Errorf("expect bound bank card, got %+v", err)
+	}
+
+	// 跨夜时间段
+	sys = &model.SysParam{StartWithdrawTime: "22:00", StopWithdrawTime: "02:00"}
+	if err := policy.check(sys, apply(1000, "张三", "6222", time.Date(2021, 6, 9, 23, 0, 0, 0, time.Local))); err != nil {
+		t.Errorf("expect overnight window ok, got %+v", err)
+	}
+	if err := policy.check(sys, apply(1000, "张三", "6222", now)); err == nil {
+		t.Error("expect outside overnight window fail")
+	}
+}
@@ -2,14 +2,10 @@ package service
 
 import (
 	"context"
-	"fmt"
 	"stock/api-gateway/dao"
-	"stock/api-gateway/db"
 	"stock/api-gateway/model"
 	"stock/common/log"
-	"stock/common/timeconv"
 	"sync"
-	"time"
 )
 
 // WithdrawService 服务
@@ -25,35 +21,12 @@ var (
 func WithdrawServiceInstance() *WithdrawService {
 	withdrawOnce.Do(func() {
 		withdrawService = &WithdrawService{}
-		ctx := context.Background()
-
-		// 未成交则自动撤单
-		go func() {
-			for range time.Tick(5 * time.Second) {
-				if !CalendarServiceInstance().IsTradeDate(ctx) || time.Now().Hour() <= 15 {
-					continue
-				}
-
-				// 检查redis是否已经执行过
-				key := fmt.Sprintf("auto_withdraw_key_%v", timeconv.TimeToInt32(time.Now()))
-				if db.RedisClient().Get(ctx, key).Val() == "1" {
-					continue
-				}
-				if err := withdrawService.withdrawTask(ctx); err != nil {
-					log.Errorf("withdrawTask err:%+v", err)
-				}
-				if err := db.RedisClient().Set(ctx, key, "1", 2*24*time.Hour).Err(); err != nil {
-					log.Errorf("设置自动撤单失败:%+v", err)
-				}
-				log.Infof("系统任务,自动撤单成功!")
-			}
-		}()
 	})
 	return withdrawService
 }
 
-// withdrawTask 未成交自动撤单
-func (s *WithdrawService) withdrawTask(ctx context.Context) error {
+// WithdrawAll 未成交委托自动撤单:由定时任务交易日收盘后执行
+func (s *WithdrawService) WithdrawAll(ctx context.Context) error {
 	list, err := dao.EntrustDaoInstance().GetTodayEntrusts(ctx)
 	if err != nil {
 		log.Errorf("GetTodayEntrusts err:%+v", err)
@@ -66,7 +39,7 @@ func (s *WithdrawService) withdrawTask(ctx context.Context) error {
 		if entrust.IsFinallyState() {
 			continue
 		}
-		entrust.Status = model.EntrustStatusTypeWithdraw
+		entrust.SetWithdraw()
 		if err := dao.EntrustDaoInstance().Update(ctx, entrust); err != nil {
 			return err
 		}
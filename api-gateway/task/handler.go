@@ -2,29 +2,31 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"stock/api-gateway/dao"
 	"stock/api-gateway/model"
+	"stock/api-gateway/service"
 	"stock/common/log"
 	"time"
 )
 
-// HisTrade 历史交易数据归档
-func HisTrade() {
-	ctx := context.Background()
+// HisTrade 历史交易数据归档,归档日期为date
+func HisTrade(ctx context.Context, date time.Time) error {
 	positions, err := dao.PositionDaoInstance().GetPositions(ctx)
 	if err != nil {
 		log.Errorf("历史数据归档失败:查询持仓表失败")
+		return err
 	}
 	if len(positions) == 0 {
 		log.Info("无持仓记录归档")
-		return
+		return nil
 	}
 	list := make([]*model.Position, 0)
 	for _, it := range positions {
 		list = append(list, &model.Position{
 			UID:          it.UID,
 			ContractID:   it.ContractID,
-			OrderTime:    time.Now(),
+			OrderTime:    date,
 			StockCode:    it.StockCode,
 			StockName:    it.StockName,
 			Price:        it.Price,
@@ -35,15 +37,54 @@ func HisTrade() {
 	}
 	if err := dao.HisPositionDaoInstance().Create(ctx, list); err != nil {
 		log.Error("历史数据归档失败")
+		return err
 	}
 	log.Infof("历史数据归档完毕!")
+	return nil
 }
 
-// ContractRecord 每天23:00分执行一次 归档本日合约
-func ContractRecord() {
-	ctx := context.Background()
+// ContractRecord 每天23:55分执行一次 归档本日合约
+func ContractRecord(ctx context.Context, date time.Time) error {
 	if err := dao.ContractRecordDaoInstance().Copy(ctx); err != nil {
 		log.Errorf("task ContractRecord Copy err:%+v", err)
+		return err
 	}
-	return
+	return nil
+}
+
+// LedgerCheck 核对资金账簿与业务表余额,存在差异时任务失败
+func LedgerCheck(ctx context.Context, date time.Time) error {
+	diffs, err := service.LedgerServiceInstance().Check(ctx)
+	if err != nil {
+		return err
+	}
+	for _, it := range diffs {
+		log.Errorf("资金账簿余额不一致:%s[%d] 业务余额:%0.2f 账簿余额:%0.2f",
+			model.LedgerAccountMap[it.AccountType], it.OwnerID, it.Book, it.Ledger)
+	}
+	if len(diffs) > 0 {
+		return fmt.Errorf("资金账簿余额不一致账户%d个", len(diffs))
+	}
+	return nil
+}
+
+// BrokerReconcile 券商持仓资金对账,未对接券商不处理;查询券商失败时返回错误以便重试
+func BrokerReconcile(ctx context.Context, date time.Time) error {
+	sys, err := dao.SysDaoInstance().GetSysParam(ctx)
+	if err != nil {
+		return err
+	}
+	if !sys.IsSupportBroker {
+		return nil
+	}
+	reports, err := service.BrokerServiceInstance().Reconcile(ctx, "")
+	if err != nil {
+		return err
+	}
+	for _, it := range reports {
+		if it.Status == model.BrokerReconcileFail {
+			return fmt.Errorf("券商[%d]对账失败:%s", it.BrokerID, it.Remark)
+		}
+	}
+	return nil
 }
@@ -1,31 +1,143 @@
 package service
 
 import (
+	"context"
+	"fmt"
+	"stock/api-gateway/dao"
+	"stock/api-gateway/db"
+	"stock/api-gateway/model"
+	"stock/api-gateway/serr"
+	"stock/api-gateway/service"
+	"stock/api-gateway/util"
 	"stock/common/log"
+	"stock/common/timeconv"
 	"sync"
+	"time"
 
 	"github.com/robfig/cron"
 )
 
+// Job 定时任务
+type Job struct {
+	Name          string                                          // 任务名称,唯一
+	Title         string                                          // 任务说明
+	Spec          string                                          // cron表达式(秒 分 时 日 月 周)
+	TradeDay      bool                                            // 是否仅交易日执行
+	CatchUp       bool                                            // 重启后是否补跑前24小时内错过的任务
+	SessionBound  bool                                            // 按当前委托、持仓等状态处理,无法按触发日期回溯:下一交易日开始后不再补跑
+	Repeat        bool                                            // 当日可多次执行,不检查当日是否已成功
+	Retry         int                                             // 失败重试次数
+	RetryInterval time.Duration                                   // 失败重试间隔
+	Run           func(ctx context.Context, date time.Time) error // 任务内容,date为触发时间,补跑时为错过的触发时间
+}
+
 // TaskService 定时器列表管理
 type TaskService struct {
 	mainCron *cron.Cron
-	schedule map[string]func()
+	jobs     []*Job
+	jobMap   map[string]*Job
 }
 
 var taskService *TaskService
 var taskServiceOnce sync.Once
 
+const (
+	jobLockTTL      = 30 * time.Minute // 任务分布式锁过期时间,每次执行、重试等待前续期
+	jobCatchUpRange = 24 * time.Hour   // 补跑范围:启动前24小时内错过的触发,含跨零点的前一日任务
+	jobErrorMaxLen  = 1024             // job_run.error字段长度
+)
+
+// jobUnlockScript 仅释放自己持有的锁
+const jobUnlockScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+
+// jobRenewScript 仅续期自己持有的锁
+const jobRenewScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`
+
 // TaskServiceInstance 单例，内部可以做内存cache
 func TaskServiceInstance() *TaskService {
 	taskServiceOnce.Do(func() {
 		taskService = &TaskService{
 			mainCron: cron.New(),
-			schedule: map[string]func(){
-				//"* * * * *":     testSecond, // test :每秒钟执行一次
-				"0 50 23 * * ?": HisTrade,       // 每天23:50分执行一次 持仓归档为历史持仓
-				"0 55 23 * * ?": ContractRecord, // 每天23:00分执行一次 归档本日合约
+			jobs: []*Job{
+				{
+					Name: "reverse_repo_settle", Title: "逆回购到期本息到账", Spec: "0 0 9 * * ?",
+					TradeDay: true, CatchUp: true, Retry: 3, RetryInterval: time.Minute,
+					// 结算资金可用日不晚于执行当日的逆回购,补跑时一并结算
+					Run: func(ctx context.Context, date time.Time) error {
+						return service.ReverseRepoServiceInstance().Settle(ctx)
+					},
+				},
+				{
+					Name: "reverse_repo_close", Title: "逆回购收盘撤单、计提利息", Spec: "0 0 15 * * ?",
+					TradeDay: true, CatchUp: true, SessionBound: true, Retry: 3, RetryInterval: time.Minute,
+					Run: func(ctx context.Context, date time.Time) error {
+						return service.ReverseRepoServiceInstance().Close(ctx, date)
+					},
+				},
+				{
+					Name: "entrust_withdraw", Title: "未成交委托自动撤单", Spec: "0 5 15 * * ?",
+					TradeDay: true, CatchUp: true, SessionBound: true, Retry: 3, RetryInterval: time.Minute,
+					Run: func(ctx context.Context, date time.Time) error {
+						return service.WithdrawServiceInstance().WithdrawAll(ctx)
+					},
+				},
+				{
+					Name: "broker_reconcile", Title: "券商持仓资金对账", Spec: "0 30 15 * * ?",
+					TradeDay: true, CatchUp: true, SessionBound: true, Retry: 3, RetryInterval: 5 * time.Minute,
+					Run: BrokerReconcile,
+				},
+				{
+					Name: "contract_interest", Title: "收取合约利息", Spec: "0 15 15 * * ?",
+					CatchUp: true, Retry: 3, RetryInterval: time.Minute,
+					Run: func(ctx context.Context, date time.Time) error {
+						return service.ContractServiceInstance().ChargeInterest(ctx, date)
+					},
+				},
+				{
+					Name: "dividend", Title: "持仓分红送股", Spec: "0 0 16 * * ?",
+					TradeDay: true, CatchUp: true, SessionBound: true, Retry: 3, RetryInterval: 5 * time.Minute,
+					Run: func(ctx context.Context, date time.Time) error {
+						return service.DividendServiceInstance().Load(ctx, date)
+					},
+				},
+				{
+					Name: "position_unfreeze", Title: "持仓股票解冻", Spec: "0 30 16 * * ?",
+					TradeDay: true, CatchUp: true, SessionBound: true, Retry: 3, RetryInterval: time.Minute,
+					Run: func(ctx context.Context, date time.Time) error {
+						return service.PositionServiceInstance().UnfreezeAll(ctx)
+					},
+				},
+				{
+					Name: "ledger_check", Title: "资金账簿余额核对", Spec: "0 30 23 * * ?",
+					CatchUp: true,
+					Run:     LedgerCheck,
+				},
+				{
+					Name: "recharge_query", Title: "查询未收到通知的充值订单", Spec: "0 */5 * * * ?",
+					Repeat: true,
+					Run: func(ctx context.Context, date time.Time) error {
+						return service.RechargeServiceInstance().QueryPending(ctx)
+					},
+				},
+				{
+					Name: "alipay_reconcile", Title: "支付宝前一日对账", Spec: "0 0 10 * * ?",
+					CatchUp: true, Retry: 3, RetryInterval: 10 * time.Minute,
+					Run: func(ctx context.Context, date time.Time) error {
+						return service.AlipayServiceInstance().ReconcileDaily(ctx, date)
+					},
+				},
+				{
+					Name: "his_trade", Title: "持仓归档为历史持仓", Spec: "0 50 23 * * ?",
+					CatchUp: true, SessionBound: true, Retry: 3, RetryInterval: time.Minute,
+					Run: HisTrade,
+				},
+				{
+					Name: "contract_record", Title: "归档本日合约", Spec: "0 55 23 * * ?",
+					CatchUp: true, SessionBound: true, Retry: 3, RetryInterval: time.Minute,
+					Run: ContractRecord,
+				},
 			},
+			jobMap: make(map[string]*Job),
 		}
 		taskService.loadTask()
 	})
@@ -33,12 +145,204 @@ func TaskServiceInstance() *TaskService {
 }
 
 func (s *TaskService) loadTask() {
-	for spec, fn := range s.schedule {
-		s.mainCron.AddFunc(spec, fn)
+	for _, job := range s.jobs {
+		job := job
+		s.jobMap[job.Name] = job
+		if err := s.mainCron.AddFunc(job.Spec, func() {
+			s.run(context.Background(), job, time.Now(), model.JobTriggerCron, "")
+		}); err != nil {
+			panic(fmt.Sprintf("定时任务[%s]表达式错误:%+v", job.Name, err))
+		}
 	}
 	s.mainCron.Start()
+
+	// 补跑重启期间错过的任务
+	go s.catchUp(context.Background())
+}
+
+// Jobs 任务列表
+func (s *TaskService) Jobs() []*Job {
+	return s.jobs
+}
+
+// Trigger 后台手动触发任务:不校验交易日与当日是否已执行
+func (s *TaskService) Trigger(ctx context.Context, name string, operator string) error {
+	job, ok := s.jobMap[name]
+	if !ok {
+		return serr.ErrBusiness("任务不存在")
+	}
+	exists, err := db.RedisClient().Exists(ctx, s.lockKey(job)).Result()
+	if err != nil {
+		return err
+	}
+	if exists > 0 {
+		return serr.ErrBusiness("任务正在执行中")
+	}
+	go s.run(context.Background(), job, time.Now(), model.JobTriggerManual, operator)
+	return nil
+}
+
+// catchUp 启动前jobCatchUpRange内已错过且未执行成功的任务,按触发日期补跑,每个日期补跑一次;
+// 按当前状态处理的任务在下一交易日开始后不再补跑,避免处理到新交易日的委托、持仓
+func (s *TaskService) catchUp(ctx context.Context) {
+	now := time.Now()
+	for _, job := range s.jobs {
+		if !job.CatchUp {
+			continue
+		}
+		schedule, err := cron.Parse(job.Spec)
+		if err != nil {
+			continue
+		}
+		for _, date := range missedRuns(schedule, now.Add(-jobCatchUpRange), now) {
+			if job.SessionBound && s.sessionStarted(ctx, date, now) {
+				log.Warnf("定时任务[%s]错过的%s触发已进入下一交易日,不再补跑", job.Name, date.Format("2006-01-02 15:04:05"))
+				continue
+			}
+			s.run(ctx, job, date, model.JobTriggerCatchUp, "")
+		}
+	}
+}
+
+// sessionStarted date之后的下一交易日是否已开始,交易日历未覆盖时按次日计算
+func (s *TaskService) sessionStarted(ctx context.Context, date, now time.Time) bool {
+	next := service.CalendarServiceInstance().NextTradeDate(ctx, date)
+	if next == 0 {
+		next = timeconv.TimeToInt32(date.AddDate(0, 0, 1))
+	}
+	return timeconv.TimeToInt32(now) >= next
+}
+
+// missedRuns (begin, end]内的触发时间,同一日期只保留最后一次,按时间先后排列
+func missedRuns(schedule cron.Schedule, begin, end time.Time) []time.Time {
+	runs := make([]time.Time, 0)
+	for next := schedule.Next(begin); !next.After(end); next = schedule.Next(next) {
+		if n := len(runs); n > 0 && timeconv.TimeToInt32(runs[n-1]) == timeconv.TimeToInt32(next) {
+			runs[n-1] = next
+			continue
+		}
+		runs = append(runs, next)
+	}
+	return runs
+}
+
+// lockKey 任务分布式锁
+func (s *TaskService) lockKey(job *Job) string {
+	return fmt.Sprintf("job_lock_%s", job.Name)
 }
 
-func testSecond() {
-	log.Info("testSecond xxxx")
+// run 执行任务:交易日检查->当日是否已成功->加锁->记录执行历史->失败重试
+func (s *TaskService) run(ctx context.Context, job *Job, date time.Time, trigger int64, operator string) {
+	runDate := timeconv.TimeToInt32(date)
+	if trigger != model.JobTriggerManual {
+		if job.TradeDay && !service.CalendarServiceInstance().IsTradeDay(ctx, date) {
+			return
+		}
+		if ok, err := s.succeeded(ctx, job, runDate); err != nil || ok {
+			return
+		}
+	}
+
+	// 分布式锁:多实例部署时仅一个实例执行
+	token, err := util.RandomHex(16)
+	if err != nil {
+		log.Errorf("定时任务[%s]生成锁标识失败:%+v", job.Name, err)
+		return
+	}
+	locked, err := db.RedisClient().SetNX(ctx, s.lockKey(job), token, jobLockTTL).Result()
+	if err != nil {
+		log.Errorf("定时任务[%s]加锁失败:%+v", job.Name, err)
+		return
+	}
+	if !locked {
+		return
+	}
+	defer func() {
+		if err := db.RedisClient().Eval(ctx, jobUnlockScript, []string{s.lockKey(job)}, token).Err(); err != nil {
+			log.Errorf("定时任务[%s]释放锁失败:%+v", job.Name, err)
+		}
+	}()
+
+	// 加锁后再次确认,避免其他实例刚刚执行完成
+	if trigger != model.JobTriggerManual {
+		if ok, err := s.succeeded(ctx, job, runDate); err != nil || ok {
+			return
+		}
+	}
+
+	now := time.Now()
+	record := &model.JobRun{
+		Name:      job.Name,
+		RunDate:   runDate,
+		Trigger:   trigger,
+		Status:    model.JobRunStatusRunning,
+		Operator:  operator,
+		StartTime: now,
+		EndTime:   now,
+	}
+	if err := dao.JobRunDaoInstance().Create(ctx, record); err != nil {
+		return
+	}
+
+	record.Status = model.JobRunStatusFail
+	for record.Attempts <= int64(job.Retry) {
+		if record.Attempts > 0 {
+			// 重试等待期间锁不过期
+			if !s.renew(ctx, job, token) {
+				record.Error = "任务锁已失效,停止重试"
+				break
+			}
+			time.Sleep(job.RetryInterval)
+		}
+		if !s.renew(ctx, job, token) {
+			record.Error = "任务锁已失效,停止重试"
+			break
+		}
+		record.Attempts++
+		if err := s.call(ctx, job, date); err != nil {
+			log.Errorf("定时任务[%s]第%d次执行失败:%+v", job.Name, record.Attempts, err)
+			record.Error = err.Error()
+			continue
+		}
+		record.Status = model.JobRunStatusSuccess
+		record.Error = ""
+		break
+	}
+	record.EndTime = time.Now()
+	// 按字符截断,避免截断多字节字符
+	if r := []rune(record.Error); len(r) > jobErrorMaxLen {
+		record.Error = string(r[:jobErrorMaxLen])
+	}
+	if err := dao.JobRunDaoInstance().Update(ctx, record); err != nil {
+		return
+	}
+	log.Infof("定时任务[%s]执行完毕,状态:%s,次数:%d", job.Name, model.JobRunStatusMap[record.Status], record.Attempts)
+}
+
+// renew 续期任务锁,锁已过期或被其他实例持有时返回false
+func (s *TaskService) renew(ctx context.Context, job *Job, token string) bool {
+	ok, err := db.RedisClient().Eval(ctx, jobRenewScript, []string{s.lockKey(job)}, token, jobLockTTL.Milliseconds()).Int()
+	if err != nil {
+		log.Errorf("定时任务[%s]续期锁失败:%+v", job.Name, err)
+		return false
+	}
+	return ok == 1
+}
+
+// succeeded 当日是否已执行成功,可重复执行的任务始终返回false
+func (s *TaskService) succeeded(ctx context.Context, job *Job, runDate int32) (bool, error) {
+	if job.Repeat {
+		return false, nil
+	}
+	return dao.JobRunDaoInstance().IsSuccess(ctx, job.Name, runDate)
+}
+
+// call 执行任务,panic视为失败
+func (s *TaskService) call(ctx context.Context, job *Job, date time.Time) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return job.Run(ctx, date)
 }
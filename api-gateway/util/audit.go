@@ -0,0 +1,67 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// sensitiveKeys 审计快照中需要脱敏的字段
+var sensitiveKeys = []string{"password", "token", "secret"}
+
+// Snapshot 将对象序列化为字段名->值,敏感字段替换为***;无法序列化为JSON对象时返回nil
+func Snapshot(v interface{}) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	result := make(map[string]interface{})
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil
+	}
+	for k := range result {
+		if IsSensitiveKey(k) {
+			result[k] = "***"
+		}
+	}
+	return result
+}
+
+// BodySnapshot JSON请求体序列化为字段名->值,敏感字段替换为***;空请求体或非JSON对象返回nil
+func BodySnapshot(body []byte) map[string]interface{} {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	return Snapshot(json.RawMessage(body))
+}
+
+// IsSensitiveKey 字段是否需要脱敏
+func IsSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, it := range sensitiveKeys {
+		if strings.Contains(key, it) {
+			return true
+		}
+	}
+	return false
+}
+
+// Diff 比较修改前后的快照,返回发生变化的字段:字段名->[修改前,修改后]
+func Diff(before, after map[string]interface{}) map[string][2]interface{} {
+	result := make(map[string][2]interface{})
+	for k, v := range before {
+		if n, ok := after[k]; !ok || !reflect.DeepEqual(v, n) {
+			result[k] = [2]interface{}{v, after[k]}
+		}
+	}
+	for k, v := range after {
+		if _, ok := before[k]; !ok {
+			result[k] = [2]interface{}{nil, v}
+		}
+	}
+	return result
+}
@@ -0,0 +1,34 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	a := assert.New(t)
+	type user struct {
+		ID       int64   `json:"id"`
+		Money    float64 `json:"money"`
+		Password string  `json:"password"`
+		Status   bool
+	}
+	before := Snapshot(&user{ID: 1, Money: 100, Password: "a", Status: true})
+	after := Snapshot(&user{ID: 1, Money: 200, Password: "b", Status: true})
+	a.Equal("***", before["password"])
+	a.Equal(map[string][2]interface{}{"money": {100.0, 200.0}}, Diff(before, after))
+
+	created := Diff(nil, Snapshot(map[string]interface{}{"id": 2}))
+	a.Equal(map[string][2]interface{}{"id": {nil, 2.0}}, created)
+	a.Nil(Snapshot([]int{1}))
+}
+
+func TestBodySnapshot(t *testing.T) {
+	a := assert.New(t)
+	body := BodySnapshot([]byte(`{"id":1,"money":100,"new_password":"a"}`))
+	a.Equal(map[string]interface{}{"id": 1.0, "money": 100.0, "new_password": "***"}, body)
+	a.Nil(BodySnapshot(nil))
+	a.Nil(BodySnapshot([]byte("id=1")))
+	a.Nil(BodySnapshot([]byte("[1]")))
+}
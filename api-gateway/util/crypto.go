@@ -5,6 +5,7 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"io"
 )
@@ -68,3 +69,12 @@ func AESDecrypt(s string, secret []byte) (string, error) {
 	ciphertext = ciphertext[aes.BlockSize : len(ciphertext)-padding]
 	return string(ciphertext), nil
 }
+
+// RandomHex 生成n字节的随机数,以16进制字符串返回
+func RandomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
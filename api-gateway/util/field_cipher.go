@@ -0,0 +1,202 @@
+package util
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"stock/common/env"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// fieldCipherPrefix 加密字段格式:enc:v<密钥版本>:<AES密文>,不带前缀的为未加密的历史数据
+const fieldCipherPrefix = "enc:v"
+
+// FieldCipher 字段加密:加密使用当前版本的密钥,解密按密文中的版本选择密钥,轮换密钥时新旧版本并存
+type FieldCipher struct {
+	version int
+	keys    map[int][]byte
+}
+
+// NewFieldCipher version为当前加密使用的密钥版本,密钥长度须为16、24或32字节
+func NewFieldCipher(version int, keys map[int][]byte) (*FieldCipher, error) {
+	if _, ok := keys[version]; !ok {
+		return nil, fmt.Errorf("field key v%d not found", version)
+	}
+	for v, key := range keys {
+		switch len(key) {
+		case 16, 24, 32:
+		default:
+			return nil, fmt.Errorf("field key v%d invalid length: %d", v, len(key))
+		}
+	}
+	return &FieldCipher{version: version, keys: keys}, nil
+}
+
+// ParseFieldKeys 解析密钥配置,格式:1:hex,2:hex,返回密钥及最大版本
+func ParseFieldKeys(s string) (map[int][]byte, int, error) {
+	keys := make(map[int][]byte)
+	latest := 0
+	for _, it := range strings.Split(s, ",") {
+		it = strings.TrimSpace(it)
+		if len(it) == 0 {
+			continue
+		}
+		kv := strings.SplitN(it, ":", 2)
+		if len(kv) != 2 {
+			return nil, 0, fmt.Errorf("invalid field key: %s", it)
+		}
+		version, err := strconv.Atoi(kv[0])
+		if err != nil || version <= 0 {
+			return nil, 0, fmt.Errorf("invalid field key version: %s", kv[0])
+		}
+		key, err := hex.DecodeString(kv[1])
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid field key v%d: %v", version, err)
+		}
+		keys[version] = key
+		if version > latest {
+			latest = version
+		}
+	}
+	if len(keys) == 0 {
+		return nil, 0, fmt.Errorf("no field key")
+	}
+	return keys, latest, nil
+}
+
+// Version 当前密钥版本
+func (c *FieldCipher) Version() int {
+	return c.version
+}
+
+// Versions 全部密钥版本
+func (c *FieldCipher) Versions() []int {
+	list := make([]int, 0, len(c.keys))
+	for v := range c.keys {
+		list = append(list, v)
+	}
+	sort.Ints(list)
+	return list
+}
+
+// Encrypt 使用当前版本密钥加密,空字符串不加密
+func (c *FieldCipher) Encrypt(txt string) (string, error) {
+	if len(txt) == 0 {
+		return "", nil
+	}
+	s, err := AESEncrypt(txt, c.keys[c.version])
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%d:%s", fieldCipherPrefix, c.version, s), nil
+}
+
+// Decrypt 按密文版本解密,未加密的历史数据原样返回
+func (c *FieldCipher) Decrypt(s string) (string, error) {
+	version, body, ok := parseFieldCipher(s)
+	if !ok {
+		return s, nil
+	}
+	key, ok := c.keys[version]
+	if !ok {
+		return "", fmt.Errorf("field key v%d not found", version)
+	}
+	return AESDecrypt(body, key)
+}
+
+// NeedRotate 未加密或不是当前版本加密的,需要重新加密
+func (c *FieldCipher) NeedRotate(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	version, _, ok := parseFieldCipher(s)
+	return !ok || version != c.version
+}
+
+// IsFieldEncrypted 是否为加密字段
+func IsFieldEncrypted(s string) bool {
+	_, _, ok := parseFieldCipher(s)
+	return ok
+}
+
+func parseFieldCipher(s string) (int, string, bool) {
+	if !strings.HasPrefix(s, fieldCipherPrefix) {
+		return 0, "", false
+	}
+	kv := strings.SplitN(s[len(fieldCipherPrefix):], ":", 2)
+	if len(kv) != 2 {
+		return 0, "", false
+	}
+	version, err := strconv.Atoi(kv[0])
+	if err != nil {
+		return 0, "", false
+	}
+	return version, kv[1], true
+}
+
+var (
+	fieldCipher   *FieldCipher
+	fieldCipherMu sync.RWMutex
+)
+
+// SetFieldCipher 设置字段加密,nil表示不加密
+func SetFieldCipher(c *FieldCipher) {
+	fieldCipherMu.Lock()
+	defer fieldCipherMu.Unlock()
+	fieldCipher = c
+}
+
+// GetFieldCipher 字段加密,未配置密钥时返回nil
+func GetFieldCipher() *FieldCipher {
+	fieldCipherMu.RLock()
+	defer fieldCipherMu.RUnlock()
+	return fieldCipher
+}
+
+// InitFieldCipher 加载字段加密密钥:FIELD_KEYS 格式1:hex,2:hex;FIELD_KEY_VERSION 当前版本,默认最大版本;
+// 生产环境必须配置
+func InitFieldCipher() error {
+	s, ok := env.GlobalEnv().Get("FIELD_KEYS")
+	if !ok || len(s) == 0 {
+		if env.GlobalEnv().IsProd() {
+			return fmt.Errorf("no FIELD_KEYS env set")
+		}
+		SetFieldCipher(nil)
+		return nil
+	}
+	keys, version, err := ParseFieldKeys(s)
+	if err != nil {
+		return err
+	}
+	if v, ok := env.GlobalEnv().Get("FIELD_KEY_VERSION"); ok && len(v) > 0 {
+		if version, err = strconv.Atoi(v); err != nil {
+			return fmt.Errorf("invalid FIELD_KEY_VERSION: %s", v)
+		}
+	}
+	c, err := NewFieldCipher(version, keys)
+	if err != nil {
+		return err
+	}
+	SetFieldCipher(c)
+	return nil
+}
+
+// MaskIDCard 身份证号脱敏:保留前3位和后4位
+func MaskIDCard(s string) string {
+	return mask(s, 3, 4)
+}
+
+// MaskBankNo 银行卡号脱敏:保留前4位和后4位
+func MaskBankNo(s string) string {
+	return mask(s, 4, 4)
+}
+
+func mask(s string, head, tail int) string {
+	r := []rune(s)
+	if len(r) <= head+tail {
+		return strings.Repeat("*", len(r))
+	}
+	return string(r[:head]) + strings.Repeat("*", len(r)-head-tail) + string(r[len(r)-tail:])
+}
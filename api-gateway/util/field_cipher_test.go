@@ -0,0 +1,60 @@
+package util
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFieldCipher(t *testing.T) {
+	keys, version, err := ParseFieldKeys("1:000102030405060708090a0b0c0d0e0f, 2:101112131415161718191a1b1c1d1e1f")
+	if err != nil || version != 2 || len(keys) != 2 {
+		t.Fatalf("parse: %v %d %+v", keys, version, err)
+	}
+	v1, err := NewFieldCipher(1, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, _ := NewFieldCipher(2, keys)
+
+	s, err := v1.Encrypt("11010519491231002X")
+	if err != nil || !strings.HasPrefix(s, "enc:v1:") {
+		t.Fatalf("encrypt: %s %+v", s, err)
+	}
+	// 轮换后旧版本密文仍可解密,且需要重新加密
+	if txt, err := v2.Decrypt(s); err != nil || txt != "11010519491231002X" {
+		t.Fatalf("decrypt: %s %+v", txt, err)
+	}
+	if v1.NeedRotate(s) || !v2.NeedRotate(s) {
+		t.Fatal("expect v2 rotate")
+	}
+	// 未加密的历史数据原样返回
+	if txt, _ := v2.Decrypt("6222000011112222"); txt != "6222000011112222" || !v2.NeedRotate(txt) {
+		t.Fatalf("plain: %s", txt)
+	}
+	if s, _ := v2.Encrypt(""); s != "" || v2.NeedRotate("") {
+		t.Fatal("expect empty")
+	}
+	// 缺少密钥
+	only2, _ := NewFieldCipher(2, map[int][]byte{2: keys[2]})
+	if _, err := only2.Decrypt(s); err == nil {
+		t.Fatal("expect key not found")
+	}
+	if _, err := NewFieldCipher(3, keys); err == nil {
+		t.Fatal("expect version not found")
+	}
+	if _, err := NewFieldCipher(1, map[int][]byte{1: []byte("short")}); err == nil {
+		t.Fatal("expect invalid key length")
+	}
+}
+
+func TestMask(t *testing.T) {
+	if s := MaskIDCard("11010519491231002X"); s != "110***********002X" {
+		t.Fatal(s)
+	}
+	if s := MaskBankNo("6222000011112222"); s != "6222********2222" {
+		t.Fatal(s)
+	}
+	if s := MaskBankNo("1234"); s != "****" {
+		t.Fatal(s)
+	}
+}
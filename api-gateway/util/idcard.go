@@ -0,0 +1,67 @@
+package util
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// idCardProvinces 居民身份证前两位省级行政区划代码
+var idCardProvinces = map[string]bool{
+	"11": true, "12": true, "13": true, "14": true, "15": true,
+	"21": true, "22": true, "23": true,
+	"31": true, "32": true, "33": true, "34": true, "35": true, "36": true, "37": true,
+	"41": true, "42": true, "43": true, "44": true, "45": true, "46": true,
+	"50": true, "51": true, "52": true, "53": true, "54": true,
+	"61": true, "62": true, "63": true, "64": true, "65": true,
+	"71": true, "81": true, "82": true, "83": true,
+}
+
+// idCardWeights 校验码加权因子
+var idCardWeights = []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+
+// idCardCheckCodes 加权和对11取模对应的校验码
+const idCardCheckCodes = "10X98765432"
+
+var (
+	ErrIDCardLength   = errors.New("身份证号码须为18位")
+	ErrIDCardRegion   = errors.New("身份证号码地区码错误")
+	ErrIDCardBirthday = errors.New("身份证号码出生日期错误")
+	ErrIDCardChecksum = errors.New("身份证号码校验位错误")
+)
+
+// ValidateIDCard 校验18位居民身份证号码:地区码、出生日期与校验位,返回出生日期
+func ValidateIDCard(idNo string, now time.Time) (time.Time, error) {
+	idNo = strings.ToUpper(strings.TrimSpace(idNo))
+	if len(idNo) != 18 {
+		return time.Time{}, ErrIDCardLength
+	}
+	sum := 0
+	for i := 0; i < 17; i++ {
+		c := idNo[i]
+		if c < '0' || c > '9' {
+			return time.Time{}, ErrIDCardLength
+		}
+		sum += int(c-'0') * idCardWeights[i]
+	}
+	if !idCardProvinces[idNo[:2]] {
+		return time.Time{}, ErrIDCardRegion
+	}
+	birthday, err := time.ParseInLocation("20060102", idNo[6:14], now.Location())
+	if err != nil || birthday.After(now) || birthday.Year() < 1900 {
+		return time.Time{}, ErrIDCardBirthday
+	}
+	if idNo[17] != idCardCheckCodes[sum%11] {
+		return time.Time{}, ErrIDCardChecksum
+	}
+	return birthday, nil
+}
+
+// Age 周岁年龄
+func Age(birthday, now time.Time) int {
+	age := now.Year() - birthday.Year()
+	if now.Month() < birthday.Month() || (now.Month() == birthday.Month() && now.Day() < birthday.Day()) {
+		age--
+	}
+	return age
+}
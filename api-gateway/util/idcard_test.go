@@ -0,0 +1,41 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateIDCard(t *testing.T) {
+	now := time.Date(2021, 6, 9, 0, 0, 0, 0, time.Local)
+	tests := []struct {
+		idNo string
+		want error
+	}{
+		{"11010519491231002X", nil},
+		{"11010519491231002x", nil},
+		{"110101200002290018", nil},
+		{"11010519491231002", ErrIDCardLength},
+		{"1101051949123100AX", ErrIDCardLength},
+		{"99010519491231002X", ErrIDCardRegion},
+		{"110101200102290018", ErrIDCardBirthday},
+		{"110101202201010018", ErrIDCardBirthday},
+		{"110105194912310021", ErrIDCardChecksum},
+	}
+	for _, tt := range tests {
+		if _, err := ValidateIDCard(tt.idNo, now); err != tt.want {
+			t.Errorf("%s: expect %v, got %v", tt.idNo, tt.want, err)
+		}
+	}
+
+	birthday, _ := ValidateIDCard("440304200806151239", now)
+	if age := Age(birthday, now); age != 12 {
+		t.Errorf("expect age 12, got %d", age)
+	}
+	birthday, _ = ValidateIDCard("110101200002290018", now)
+	if age := Age(birthday, time.Date(2018, 2, 28, 0, 0, 0, 0, time.Local)); age != 17 {
+		t.Errorf("expect age 17 before birthday, got %d", age)
+	}
+	if age := Age(birthday, time.Date(2018, 3, 1, 0, 0, 0, 0, time.Local)); age != 18 {
+		t.Errorf("expect age 18 after birthday, got %d", age)
+	}
+}
@@ -0,0 +1,45 @@
+package util
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordHashCost bcrypt计算强度,调高后旧密码在登录时会自动重新hash
+const PasswordHashCost = bcrypt.DefaultCost
+
+// PasswordMaxLen bcrypt最多只使用密码的前72个字节
+const PasswordMaxLen = 72
+
+// HashPassword 使用bcrypt生成密码hash,每次调用都会生成新的随机盐
+func HashPassword(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), PasswordHashCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+// IsPasswordHashed 判断数据库中存储的密码是否已经是hash,否则为历史明文密码
+func IsPasswordHashed(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$")
+}
+
+// ComparePassword 校验密码,返回是否匹配以及是否需要重新hash(明文存储或hash强度过低)
+func ComparePassword(stored, password string) (bool, bool) {
+	if len(stored) == 0 || len(password) == 0 {
+		return false, false
+	}
+	if !IsPasswordHashed(stored) {
+		// 历史明文密码
+		ok := subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+		return ok, ok
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)); err != nil {
+		return false, false
+	}
+	cost, err := bcrypt.Cost([]byte(stored))
+	return true, err == nil && cost < PasswordHashCost
+}
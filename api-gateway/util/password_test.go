@@ -0,0 +1,47 @@
+package util
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPassword(t *testing.T) {
+	hashed, err := HashPassword("abc123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsPasswordHashed(hashed) {
+		t.Fatalf("expect hashed password: %s", hashed)
+	}
+	// 相同密码每次生成的hash不同
+	other, _ := HashPassword("abc123456")
+	if other == hashed {
+		t.Fatal("expect different salt")
+	}
+
+	if ok, upgrade := ComparePassword(hashed, "abc123456"); !ok || upgrade {
+		t.Fatalf("ok:%v upgrade:%v", ok, upgrade)
+	}
+	if ok, _ := ComparePassword(hashed, "abc1234567"); ok {
+		t.Fatal("expect wrong password")
+	}
+
+	// 历史明文密码,匹配后需要升级
+	if ok, upgrade := ComparePassword("123456", "123456"); !ok || !upgrade {
+		t.Fatalf("ok:%v upgrade:%v", ok, upgrade)
+	}
+	if ok, upgrade := ComparePassword("123456", "1234567"); ok || upgrade {
+		t.Fatalf("ok:%v upgrade:%v", ok, upgrade)
+	}
+
+	// hash强度过低,匹配后需要升级
+	weak, _ := bcrypt.GenerateFromPassword([]byte("abc123456"), bcrypt.MinCost)
+	if ok, upgrade := ComparePassword(string(weak), "abc123456"); !ok || !upgrade {
+		t.Fatalf("ok:%v upgrade:%v", ok, upgrade)
+	}
+
+	if ok, _ := ComparePassword("", ""); ok {
+		t.Fatal("expect empty password not match")
+	}
+}
@@ -0,0 +1,55 @@
+package util
+
+import "time"
+
+// repoMaxSearchDays 逆回购交收日期向后查找交易日的最大天数
+const repoMaxSearchDays = 31
+
+// RepoInterest 逆回购利息:本金 * 年化利率(%) * 计息天数 / 365
+func RepoInterest(money, rate float64, days int) float64 {
+	if money <= 0 || rate <= 0 || days <= 0 {
+		return 0
+	}
+	return FloatRound(money*rate/100*float64(days)/365+1e-9, 2)
+}
+
+// RepoFee 逆回购手续费:按成交金额收取
+func RepoFee(money, feeRate float64) float64 {
+	return FloatRound(money*feeRate+1e-9, 2)
+}
+
+// RepoSchedule 逆回购交收日期:
+// 首次交收日为成交日的下一交易日(起息日);到期交收日为首次交收日加期限天数,遇非交易日顺延;
+// 资金可用日为到期交收日的前一交易日(不早于首次交收日);计息天数为首次交收日至到期交收日的实际天数
+func RepoSchedule(tradeDate time.Time, tenor int, isTradeDate func(time.Time) bool) (settle, expire, available time.Time, days int, ok bool) {
+	settle, ok = nextTradeDate(tradeDate, isTradeDate)
+	if !ok {
+		return
+	}
+	expire = settle.AddDate(0, 0, tenor)
+	if !isTradeDate(expire) {
+		if expire, ok = nextTradeDate(expire, isTradeDate); !ok {
+			return
+		}
+	}
+	available = settle
+	for d := expire.AddDate(0, 0, -1); d.After(settle); d = d.AddDate(0, 0, -1) {
+		if isTradeDate(d) {
+			available = d
+			break
+		}
+	}
+	days = int(expire.Sub(settle).Hours()/24 + 0.5)
+	return settle, expire, available, days, true
+}
+
+// nextTradeDate date之后(不含当天)的第一个交易日
+func nextTradeDate(date time.Time, isTradeDate func(time.Time) bool) (time.Time, bool) {
+	for i := 1; i <= repoMaxSearchDays; i++ {
+		d := date.AddDate(0, 0, i)
+		if isTradeDate(d) {
+			return d, true
+		}
+	}
+	return time.Time{}, false
+}
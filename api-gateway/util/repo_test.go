@@ -0,0 +1,59 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepoInterest(t *testing.T) {
+	a := assert.New(t)
+	a.Equal(27.4, RepoInterest(100000, 1, 10))
+	a.Equal(1.64, RepoInterest(10000, 2, 3))
+	a.Equal(0.0, RepoInterest(10000, 0, 3))
+	a.Equal(0.01, RepoFee(100000, 0.0000001))
+	a.Equal(1.0, RepoFee(100000, 0.00001))
+}
+
+func TestRepoSchedule(t *testing.T) {
+	a := assert.New(t)
+	// 2024-10-01 ~ 2024-10-07 国庆休市
+	isTradeDate := func(d time.Time) bool {
+		if d.Weekday() == time.Saturday || d.Weekday() == time.Sunday {
+			return false
+		}
+		return d.Before(time.Date(2024, 10, 1, 0, 0, 0, 0, time.Local)) || d.After(time.Date(2024, 10, 7, 0, 0, 0, 0, time.Local))
+	}
+	date := func(day int) time.Time {
+		return time.Date(2024, 9, day, 0, 0, 0, 0, time.Local)
+	}
+	cases := []struct {
+		trade     time.Time
+		tenor     int
+		settle    string
+		expire    string
+		available string
+		days      int
+	}{
+		// 周三1天期:周四起息,周五到期,计息1天
+		{trade: date(11), tenor: 1, settle: "2024-09-12", expire: "2024-09-13", available: "2024-09-12", days: 1},
+		// 周四1天期:周五起息,到期日周六顺延至周一,计息3天,周五资金可用
+		{trade: date(12), tenor: 1, settle: "2024-09-13", expire: "2024-09-16", available: "2024-09-13", days: 3},
+		// 周五1天期:周一起息,周二到期,计息1天
+		{trade: date(13), tenor: 1, settle: "2024-09-16", expire: "2024-09-17", available: "2024-09-16", days: 1},
+		// 节前7天期:顺延至节后
+		{trade: date(26), tenor: 7, settle: "2024-09-27", expire: "2024-10-08", available: "2024-09-30", days: 11},
+	}
+	for _, c := range cases {
+		settle, expire, available, days, ok := RepoSchedule(c.trade, c.tenor, isTradeDate)
+		a.True(ok)
+		a.Equal(c.settle, settle.Format("2006-01-02"))
+		a.Equal(c.expire, expire.Format("2006-01-02"))
+		a.Equal(c.available, available.Format("2006-01-02"))
+		a.Equal(c.days, days)
+	}
+
+	_, _, _, _, ok := RepoSchedule(date(11), 1, func(time.Time) bool { return false })
+	a.False(ok)
+}
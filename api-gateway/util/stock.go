@@ -24,6 +24,39 @@ func StockBord(code string) int64 {
 	return 0
 }
 
+// 涨跌幅限制比例
+const (
+	PriceLimitPctNormal = 0.1  // 主板
+	PriceLimitPctST     = 0.05 // 主板ST股票
+	PriceLimitPctGEM    = 0.2  // 创业板、科创板
+	PriceLimitPctBJ     = 0.3  // 北交所
+)
+
+// PriceLimitPct 股票涨跌幅限制比例;新股上市首日(N)、创业板和科创板新股上市前5日(C)无涨跌幅限制,返回0
+func PriceLimitPct(code, name string) float64 {
+	if strings.HasPrefix(name, "N") || strings.HasPrefix(name, "C") {
+		return 0
+	}
+	switch StockBord(code) {
+	case StockTypeKCBBORD, StockTypeCYBBORD:
+		return PriceLimitPctGEM
+	case StockTypeBJ:
+		return PriceLimitPctBJ
+	}
+	if strings.Contains(name, "ST") {
+		return PriceLimitPctST
+	}
+	return PriceLimitPctNormal
+}
+
+// PriceLimit 根据昨收价和涨跌幅限制比例计算涨停价、跌停价(四舍五入到分)
+func PriceLimit(closePrice, pct float64) (float64, float64) {
+	// 加上极小值,避免浮点误差导致x.xx5向下舍入
+	up := FloatRound(closePrice*(1+pct)+1e-9, 2)
+	down := FloatRound(closePrice*(1-pct)+1e-9, 2)
+	return up, down
+}
+
 const (
 	StockMarketTypeSZ = "SZ"
 	StockMarketTypeSH = "SH"
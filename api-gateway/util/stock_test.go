@@ -0,0 +1,38 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriceLimit(t *testing.T) {
+	a := assert.New(t)
+
+	cases := []struct {
+		code  string
+		name  string
+		close float64
+		pct   float64
+		up    float64
+		down  float64
+	}{
+		{code: "600000", name: "浦发银行", close: 7.35, pct: PriceLimitPctNormal, up: 8.09, down: 6.62},
+		{code: "000001", name: "平安银行", close: 10.05, pct: PriceLimitPctNormal, up: 11.06, down: 9.05},
+		{code: "600200", name: "*ST江苏吴中", close: 3.31, pct: PriceLimitPctST, up: 3.48, down: 3.14},
+		{code: "300750", name: "宁德时代", close: 180.01, pct: PriceLimitPctGEM, up: 216.01, down: 144.01},
+		{code: "688981", name: "中芯国际", close: 45.55, pct: PriceLimitPctGEM, up: 54.66, down: 36.44},
+		{code: "830799", name: "艾融软件", close: 20.15, pct: PriceLimitPctBJ, up: 26.2, down: 14.11},
+	}
+	for _, c := range cases {
+		pct := PriceLimitPct(c.code, c.name)
+		a.Equal(c.pct, pct, c.name)
+		up, down := PriceLimit(c.close, pct)
+		a.Equal(c.up, up, c.name)
+		a.Equal(c.down, down, c.name)
+	}
+
+	// 新股无涨跌幅限制
+	a.Equal(0.0, PriceLimitPct("301000", "N新股"))
+	a.Equal(0.0, PriceLimitPct("688000", "C新股"))
+}
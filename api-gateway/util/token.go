@@ -0,0 +1,53 @@
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// ErrInvalidToken token格式错误或签名不匹配
+var ErrInvalidToken = errors.New("invalid token")
+
+// SignToken 将claims序列化后使用HMAC-SHA256签名,格式为 base64(payload).base64(sign)
+func SignToken(claims interface{}, secret []byte) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	body := base64.RawURLEncoding.EncodeToString(payload)
+	return body + "." + base64.RawURLEncoding.EncodeToString(HMACSHA256([]byte(body), secret)), nil
+}
+
+// ParseToken 校验token签名并将payload解析到claims
+func ParseToken(token string, secret []byte, claims interface{}) error {
+	list := strings.Split(token, ".")
+	if len(list) != 2 {
+		return ErrInvalidToken
+	}
+	sign, err := base64.RawURLEncoding.DecodeString(list[1])
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if !hmac.Equal(sign, HMACSHA256([]byte(list[0]), secret)) {
+		return ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(list[0])
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// HMACSHA256 计算 hmac-sha256
+func HMACSHA256(data, secret []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write(data)
+	return h.Sum(nil)
+}
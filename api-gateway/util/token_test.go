@@ -0,0 +1,42 @@
+package util
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToken(t *testing.T) {
+	type claims struct {
+		UID       int64  `json:"uid"`
+		SessionID string `json:"sid"`
+	}
+	secret := []byte("this is a secret")
+	token, err := SignToken(&claims{UID: 10, SessionID: "abc"}, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var c claims
+	if err := ParseToken(token, secret, &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.UID != 10 || c.SessionID != "abc" {
+		t.Fatalf("unexpected claims: %+v", c)
+	}
+
+	// 错误的密钥
+	if err := ParseToken(token, []byte("other secret"), &c); err != ErrInvalidToken {
+		t.Fatal("expect invalid token with wrong secret")
+	}
+
+	// 篡改payload
+	forged, _ := SignToken(&claims{UID: 11, SessionID: "abc"}, secret)
+	forged = strings.Split(forged, ".")[0] + "." + strings.Split(token, ".")[1]
+	if err := ParseToken(forged, secret, &c); err != ErrInvalidToken {
+		t.Fatal("expect invalid token with forged payload")
+	}
+
+	if err := ParseToken("abc", secret, &c); err != ErrInvalidToken {
+		t.Fatal("expect invalid token with bad format")
+	}
+}